@@ -2,18 +2,29 @@ package main
 
 import (
 	"agent/api"
+	"agent/artifacts"
+	"agent/filters"
+	"agent/hooks"
+	"agent/index"
+	"agent/logging"
+	"agent/lsp"
+	"agent/miniagents"
 	"agent/models"
+	"agent/permissions"
+	"agent/tasks"
 	"agent/theme"
 	"agent/tools"
+	"agent/tracing"
 	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,22 +42,86 @@ type Agent struct {
 	Messages    []models.Message
 	LiveContext *LiveContext
 
-	commands        map[string]Command
-	config          *Config
-	currentModel    *models.Model
-	cancelFunc      context.CancelFunc
-	inProgress      bool
-	inProgressMutex sync.Mutex
-	sessionLogger   *SessionLogger
+	commands            map[string]Command
+	config              *Config
+	currentModel        *models.Model
+	cancelFunc          context.CancelFunc
+	inProgress          bool
+	inProgressMutex     sync.Mutex
+	asking              bool       // a confirmation is waiting for the user's answer
+	askMu               sync.Mutex // held while a question waits for its answer; sub-agents ask in parallel
+	interrupts          interruptTracker
+	queue               inputQueue // lines typed during the running turn
+	sessionLogger       *SessionLogger
+	journal             *tools.ChangeJournal
+	turn                int
+	checkpoints         []Checkpoint
+	checkpointTurn      int
+	changedFiles        []string // live-context files modified outside the agent before the current turn
+	lsp                 *lsp.Manager
+	searchIndex         *index.Index
+	artifacts           *artifacts.Store
+	turnStateMu         sync.Mutex // guards churn, watchdog, checkpointTurn, and flaggedSources, which sub-agents' tool calls update concurrently
+	churn               turnChurn
+	turnResultChars     int // characters of tool results added this turn, against the tool_results budget
+	watchdog            *watchdog
+	input               lineScanner    // shared by the prompt loop and confirmations during a turn
+	planMode            bool           // restricts the model to read-only tools until /execute
+	persona             string         // the active /persona, or empty
+	personaModel        *SelectedModel // the model selected before the persona switched it
+	dryRun              bool           // file tools show diffs without writing, see --dry-run and /dryrun
+	permissions         *permissions.Policy
+	hooks               *hooks.Runner    // configured commands run before and after tool calls
+	redactor            *filters.Secrets // masks credentials in tool results; nil when redaction is disabled
+	templates           *tools.FileTemplates
+	turnSeed            int64               // sampling seed sent with every request in the current turn
+	flaggedSources      map[string]bool     // files and tool results flagged for possible prompt injection this turn
+	lastRequest         *requestExplanation // what the last request sent and trimmed, for /why
+	contextPlacement    string              // where live context is sent, see ContextPlacement
+	previousRequest     *requestExplanation
+	miniagents          *miniagents.Scheduler
+	limiter             *miniagents.RateLimiter // shared by the main loop and miniagents
+	title               string
+	branches            []*sessionBranch // sessions opened with /fork, see branches.go
+	branch              int              // index of the branch in use
+	tasks               *tasks.Store
+	activeTask          int // ID of the task being worked on, 0 for none
+	shellHistory        *tools.ShellHistory
+	anchors             *tools.Anchors
+	board               *tools.TaskBoard
+	workDir             *tools.WorkingDirectory // where shell commands run and relative tool paths resolve
+	startupWarnings     []string                // config problems found when loading, shown with the welcome message
+	promptTemplate      string                  // the system prompt template after user and project overrides; empty for the built-in one
+	promptTemplateFiles []string                // the override templates that were applied
+	quiet               bool                    // --quiet: print only the conversation, no banner, prompts, or heartbeats
+	overrides           sessionOverrides        // sampling settings changed with /set for this session
+	telemetry           toolTelemetry           // per-tool call statistics for /stats
+	tracer              *tracing.Tracer         // nil unless an OTLP endpoint is configured
+	toolOutput          io.Writer               // where tool results are shown; nil prints them to stdout
+	formatter           *tools.Formatter
+
+	progressMu        sync.Mutex
+	progress          ProgressEvent
+	progressStart     time.Time
+	progressListeners []func(ProgressEvent)
 }
 
 func NewAgent() *Agent {
+	sessionLogger := NewSessionLogger()
 	agent := &Agent{
 		Messages:      make([]models.Message, 0),
 		LiveContext:   NewLiveContext(),
-		sessionLogger: NewSessionLogger(),
-
-		config: LoadConfig(),
+		sessionLogger: sessionLogger,
+		journal:       tools.NewChangeJournal(checkpointDir(sessionLogger.ID)),
+		artifacts:     artifacts.NewStore(artifactsDir(sessionLogger.ID)),
+		anchors:       tools.NewAnchors(filepath.Join(".agent", "anchors.json")),
+		board:         tools.NewTaskBoard(),
+	}
+	agent.input = newLineReader(os.Stdin, agent.interceptInput)
+	agent.config, agent.startupWarnings = loadEffectiveConfig()
+	var err error
+	if agent.tasks, err = tasks.NewStore(filepath.Join(".agent", "tasks.json")); err != nil {
+		agent.startupWarnings = append(agent.startupWarnings, fmt.Sprintf("Warning: tasks can't be changed until this is fixed: %v", err))
 	}
 
 	if agent.config.Model != nil {
@@ -55,6 +130,38 @@ func NewAgent() *Agent {
 			panic(err)
 		}
 	}
+	agent.startupWarnings = append(agent.startupWarnings, agent.applyConfig()...)
+	if removed, err := pruneSessions(agent.config.Sessions, sessionLogger.dir, sessionLogger.ID); err != nil {
+		logging.Warnf("Failed to delete old sessions: %v", err)
+	} else if len(removed) > 0 {
+		logging.Infof("Deleted %d old sessions: %s", len(removed), strings.Join(removed, ", "))
+	}
+
+	workDir, _ := os.Getwd()
+	agent.lsp = lsp.NewManager(workDir, agent.config.LSP)
+	searchIndex, err := index.Open(workDir, indexDir(), agent.config.Index.embeddingModel(), agent.embed)
+	if err != nil {
+		logging.Warnf("Failed to load semantic search index: %v", err)
+	}
+	agent.searchIndex = searchIndex
+	agent.templates = tools.NewFileTemplates(workDir)
+	agent.workDir = tools.NewWorkingDirectory(workDir)
+	agent.formatter = tools.NewFormatter(agent.config.Format)
+	agent.shellHistory = tools.NewShellHistory(thresholdOrDefault(agent.config.ShellHistory, 10))
+	agent.limiter = miniagents.NewRateLimiter(agent.config.Miniagents.RequestsPerMinute)
+	agent.miniagents = miniagents.NewScheduler(miniagents.NewBudget(agent.config.Miniagents.TokenBudget, agent.config.Miniagents.CostBudget), agent.limiter, logsDir(), sessionLogger.ID)
+	var reportTracing sync.Once
+	agent.tracer = tracing.New(agent.config.Tracing, func(err error) {
+		shown := false
+		reportTracing.Do(func() {
+			logging.Warnf("Tracing export failed (further failures are only written to the log file): %v", err)
+			shown = true
+		})
+		if !shown {
+			logging.Infof("Tracing export failed: %v", err)
+		}
+	})
+
 	agent.registerBuiltinCommands()
 	agent.registerTools()
 	agent.InitializeDefaultContext()
@@ -67,17 +174,37 @@ func (a *Agent) registerTools() {
 		return a.currentModel
 	}
 
-	a.tools = make(map[string]models.ToolDefinition)
-	a.tools["create_file"] = tools.NewCreateFileTool()
-	a.tools["edit_file"] = tools.NewEditFileTool()
-	a.tools["delete_file"] = tools.NewDeleteFileTool()
-	a.tools["shell"] = tools.NewShellTool(getModel)
-	a.tools["read_file"] = tools.NewReadFileTool(a.LiveContext)
-	a.tools["stop_reading_file"] = tools.NewStopReadingFileTool(a.LiveContext)
-	a.tools["read_directory"] = tools.NewReadDirectoryTool(a.LiveContext)
-	a.tools["stop_reading_directory"] = tools.NewStopReadingDirectoryTool(a.LiveContext)
-	a.tools["remove_message"] = tools.NewRemoveMessageTool(a.DeleteMessage)
-
+	var auditor *tools.CommandAuditor
+	if a.config.Security.AuditCommands {
+		auditor = tools.NewCommandAuditor(getModel, a.config.Security.Policy, a.Confirm)
+	}
+	a.tools = tools.NewToolRegistry(tools.Dependencies{
+		LiveContext:   a.LiveContext,
+		DeleteMessage: a.DeleteMessageByID,
+		Journal:       a.journal,
+		Templates:     a.templates,
+		Formatter:     a.formatter,
+		Sandbox:       a.config.Sandbox,
+		Auditor:       auditor,
+		ShellHistory:  a.shellHistory,
+		WorkDir:       a.workDir,
+		ShellEnv:      a.config.ShellEnv,
+		ShellOutput:   a.config.ShellOutput,
+		Summarize:     a.summarizeOutput,
+		Interactive:   a.config.ShellInteractive,
+		Ask:           a.Ask,
+		LSP:           a.lsp,
+		SearchIndex:   a.searchIndex,
+		Artifacts:     a.artifacts,
+		Anchors:       a.anchors,
+		Board:         a.board,
+		Docs:          a.config.Docs,
+		Spawn:         a.spawnSubAgent,
+		Vision: func() bool {
+			model := getModel()
+			return model != nil && model.AcceptsImages()
+		},
+	})
 }
 
 func (a *Agent) ProcessMessage(input string) {
@@ -89,26 +216,141 @@ func (a *Agent) ProcessMessage(input string) {
 	a.cancelFunc = cancelFunc
 	a.inProgressMutex.Unlock()
 
-	// Ensure we clear the in-progress flag when done
+	// Ensure we clear the in-progress flag when done. Changes made during the turn were made by the
+	// agent itself, so only changes made between turns are reported to the model.
 	defer func() {
+		a.LiveContext.ResetChanges()
 		a.inProgressMutex.Lock()
 		a.inProgress = false
+		a.releaseQueue()
 		a.inProgressMutex.Unlock()
 	}()
 
+	ctx, span := a.tracer.Start(ctx, "turn", tracing.KindInternal)
 	// Use the simplified agent processing
 	err := a.ProcesssMessageWithCancellation(ctx, a.currentModel, input)
+	span.SetAttribute("agent.session", a.sessionLogger.ID)
+	span.SetAttribute("agent.turn", a.turn)
+	span.SetError(err)
+	span.End()
+	if err == nil {
+		a.startBackgroundMiniagents(a.turn, input)
+	}
+	if a.churn.total() > 0 {
+		fmt.Println(theme.InfoText(churnBar(a.churn, a.config.Churn.MaxLines)))
+	}
 	if err != nil {
 		fmt.Println("")
 		if errors.Is(err, context.Canceled) {
 			fmt.Println(theme.WarningText("Cancelled request"))
+		} else if errors.Is(err, errStoppedByUser) {
+			fmt.Println(theme.WarningText(fmt.Sprintf("Turn %v", err)))
 		} else {
 			fmt.Println(theme.WarningText(fmt.Sprintf("Operation failed: %v", err)))
 		}
 	}
 }
 
+// CancelTurn cancels the running turn, reporting false when no turn is running
+func (a *Agent) CancelTurn() bool {
+	a.inProgressMutex.Lock()
+	defer a.inProgressMutex.Unlock()
+	if a.inProgress && a.cancelFunc != nil {
+		a.cancelFunc()
+		return true
+	}
+	return false
+}
+
+// AgentStatus summarizes the session for status displays
+type AgentStatus struct {
+	Model          string
+	HistoryTokens  int // estimated from the conversation history's size
+	ContextPercent float64
+	Busy           bool
+	Queued         int // messages typed during the turn, sent when it finishes
+}
+
+// Status reports the current model, context usage, and whether a turn is running
+func (a *Agent) Status() AgentStatus {
+	var status AgentStatus
+	a.mu.RLock()
+	if a.currentModel != nil && a.currentModel.Provider != nil {
+		status.Model = a.currentModel.Provider.ID + ":" + a.currentModel.ID
+	}
+	chars := 0
+	for _, message := range a.Messages {
+		chars += len(message.Content)
+		for _, call := range message.ToolCalls {
+			chars += len(call.Function.Arguments)
+		}
+	}
+	a.mu.RUnlock()
+	status.HistoryTokens = (chars + 3) / 4
+
+	_, _, status.ContextPercent = a.LiveContext.GetContextUsage()
+
+	a.inProgressMutex.Lock()
+	status.Busy = a.inProgress
+	status.Queued = a.queue.messages
+	a.inProgressMutex.Unlock()
+	return status
+}
+
+// toolOutputWriter is where tool results are printed
+func (a *Agent) toolOutputWriter() io.Writer {
+	if a.toolOutput != nil {
+		return a.toolOutput
+	}
+	return os.Stdout
+}
+
+// Confirm asks the user a yes/no question on the terminal
+func (a *Agent) Confirm(question string) bool {
+	answer, ok := a.Ask(question + " [y/N]")
+	if !ok {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// isAsking reports whether Ask is waiting for the user's answer
+func (a *Agent) isAsking() bool {
+	a.inProgressMutex.Lock()
+	defer a.inProgressMutex.Unlock()
+	return a.asking
+}
+
+// Ask prints question and reads a line of input from the user. ok is false when input has ended.
+// Questions asked at the same time wait their turn, so each answer goes to the question it follows.
+func (a *Agent) Ask(question string) (string, bool) {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+	fmt.Print(theme.WarningText(question + " "))
+	a.inProgressMutex.Lock()
+	a.asking = true
+	a.inProgressMutex.Unlock()
+	defer func() {
+		a.inProgressMutex.Lock()
+		a.asking = false
+		a.inProgressMutex.Unlock()
+	}()
+
+	if !a.input.Scan() {
+		return "", false
+	}
+	return a.input.Text(), true
+}
+
 func (a *Agent) Close() error {
+	a.miniagents.Close()
+	a.lsp.Close()
+	if err := a.LiveContext.Close(); err != nil {
+		logging.Warnf("Failed to stop file watcher: %v", err)
+	}
+	a.closeBranches()
+	a.tracer.Close(5 * time.Second)
 	return a.sessionLogger.Close()
 }
 
@@ -119,10 +361,6 @@ func (a *Agent) switchProvider(providerId string, modelId string) error {
 			if providerId == Provider.ID && modelId == Model.ID {
 				model = Model
 				model.Provider = Provider
-				if strings.HasPrefix(model.Provider.APIKey, "env:") {
-					envVar := strings.TrimPrefix(model.Provider.APIKey, "env:")
-					model.Provider.APIKey = os.Getenv(envVar)
-				}
 			}
 		}
 	}
@@ -132,29 +370,31 @@ func (a *Agent) switchProvider(providerId string, modelId string) error {
 	}
 
 	// Update chatbot state
+	a.mu.Lock()
 	a.currentModel = model
+	a.mu.Unlock()
 
-	// Update persistent configuration
 	a.config.Model = &SelectedModel{
 		Provider: providerId,
 		Model:    modelId,
 	}
 
-	// Save the updated configuration
-	if err := SaveConfig(a.config); err != nil {
-		log.Printf("Failed to save config after model switch: %v", err)
-	}
-
 	return nil
 }
 
 func (a *Agent) AddUserMessage(content string) {
+	a.AddUserMessageWithImages(content, nil)
+}
+
+// AddUserMessageWithImages adds a user message with attached images, given as URLs or data: URLs
+func (a *Agent) AddUserMessageWithImages(content string, images []string) {
 	message := models.Message{
 		ID:        uuid.New().String(),
 		Role:      "user",
 		Content:   content,
 		Timestamp: time.Now(),
 		Status:    "active",
+		Images:    images,
 	}
 
 	a.mu.Lock()
@@ -180,6 +420,29 @@ func (a *Agent) AddAgentMessage(content string) {
 	a.sessionLogger.LogMessage(message)
 }
 
+// AddCancelledAgentMessage records that the user cancelled the assistant's response, so the history
+// stays well-formed and resumed sessions can tell cancellations from failures. Content streamed before
+// the cancellation is kept as an "interrupted" response the user and model can refer back to.
+func (a *Agent) AddCancelledAgentMessage(partial string) {
+	message := models.Message{
+		ID:        uuid.New().String(),
+		Role:      "assistant",
+		Content:   "(response cancelled by the user)",
+		Timestamp: time.Now(),
+		Status:    "cancelled",
+	}
+	if strings.TrimSpace(partial) != "" {
+		message.Content = partial
+		message.Status = "interrupted"
+	}
+
+	a.mu.Lock()
+	a.Messages = append(a.Messages, message)
+	a.mu.Unlock()
+
+	a.sessionLogger.LogMessage(message)
+}
+
 func (a *Agent) AddAgentMessageWithToolCalls(content string, toolCalls []models.ToolCall) {
 	message := models.Message{
 		ID:        uuid.New().String(),
@@ -207,25 +470,80 @@ func (a *Agent) GetHistory() []models.Message {
 	return history
 }
 
-func (a *Agent) DeleteMessage(role, contentContains string) (bool, error) {
+// DeleteMessageByID marks the message with id deleted, so it is no longer sent to the model. Tool
+// calls and their results are removed together, since a request can't hold one without the other.
+// A tombstone record with the message's ID is logged for each removed message. Returns the removed
+// messages, the requested one first, or nil if no message has that ID.
+func (a *Agent) DeleteMessageByID(id string) ([]models.Message, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	index := -1
 	for i, msg := range a.Messages {
-		if msg.Role == role && strings.Contains(msg.Content, contentContains) && msg.Status == "active" {
+		if msg.ID == id {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, nil
+	}
+	if a.Messages[index].Status == "deleted" {
+		return nil, fmt.Errorf("message %s was already removed", id)
+	}
 
-			deletedMsg := msg
-			deletedMsg.ID = uuid.New().String()
-			deletedMsg.Timestamp = time.Now()
-			deletedMsg.Status = "deleted"
+	var removed []models.Message
+	for _, i := range toolCallGroup(a.Messages, index) {
+		if a.Messages[i].Status == "deleted" {
+			continue
+		}
+		removed = append(removed, a.Messages[i])
+		a.Messages[i].Status = "deleted"
+		a.sessionLogger.LogMessage(models.Message{
+			ID:         a.Messages[i].ID,
+			Role:       a.Messages[i].Role,
+			ToolCallID: a.Messages[i].ToolCallID,
+			Timestamp:  time.Now(),
+			Status:     "deleted",
+		})
+	}
+	return removed, nil
+}
 
-			a.sessionLogger.LogMessage(deletedMsg)
+// toolCallGroup returns the index of the message at index followed by the indices of the messages
+// that must be removed with it: an assistant message's tool results, or a tool result's call and the
+// other results of that call
+func toolCallGroup(messages []models.Message, index int) []int {
+	call := index
+	if messages[index].Role == "tool" {
+		call = -1
+		for i := index - 1; i >= 0 && call == -1; i-- {
+			for _, tc := range messages[i].ToolCalls {
+				if tc.ID == messages[index].ToolCallID {
+					call = i
+					break
+				}
+			}
+		}
+		if call == -1 {
+			return []int{index}
+		}
+	}
 
-			a.Messages[i].Status = "deleted"
-			return true, nil
+	callIDs := make(map[string]bool)
+	for _, tc := range messages[call].ToolCalls {
+		callIDs[tc.ID] = true
+	}
+	group := []int{index}
+	if call != index {
+		group = append(group, call)
+	}
+	for i := call + 1; i < len(messages); i++ {
+		if i != index && messages[i].Role == "tool" && callIDs[messages[i].ToolCallID] {
+			group = append(group, i)
 		}
 	}
-	return false, nil
+	return group
 }
 
 func (a *Agent) ClearHistory() {
@@ -235,10 +553,15 @@ func (a *Agent) ClearHistory() {
 }
 
 func (a *Agent) AddToolResultsMessage(toolResults []models.ToolResult) {
+	a.budgetToolResults(toolResults)
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	for _, result := range toolResults {
+		status := "active"
+		if result.IsCancelled {
+			status = "cancelled"
+		}
 		message := models.Message{
 			ID:         uuid.New().String(),
 			Role:       "tool",
@@ -246,7 +569,8 @@ func (a *Agent) AddToolResultsMessage(toolResults []models.ToolResult) {
 			Timestamp:  time.Now(),
 			ToolName:   result.Name,
 			ToolCallID: result.ID,
-			Status:     "active",
+			Status:     status,
+			Images:     result.Images,
 		}
 		a.Messages = append(a.Messages, message)
 		a.sessionLogger.LogMessage(message)
@@ -254,120 +578,473 @@ func (a *Agent) AddToolResultsMessage(toolResults []models.ToolResult) {
 }
 
 func (a *Agent) BuildSystemPrompt() string {
+	prompt, _, _ := a.buildSystemPrompt()
+	return prompt
+}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		cwd = "unknown"
+// buildSystemPrompt renders the system prompt and returns its sections, which /why reports on. The
+// per-turn data, and the live context when it's placed in a message, is returned separately for the
+// context message.
+func (a *Agent) buildSystemPrompt() (string, string, []promptSection) {
+	_, liveContextBudget, _ := a.config.Budget.Limits()
+	files, directories, _ := a.LiveContext.SerializeWithinBudget(liveContextBudget)
+
+	prompt, _ := a.renderPromptProviders(a.systemPromptTemplate(), true)
+	instructions := instructionsOnly(prompt)
+	prompt, providerSections := a.renderPromptProviders(prompt, false)
+	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_FILES}", files)
+	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORIES}", directories)
+	for path, findings := range a.LiveContext.Injections() {
+		a.noteInjection(path, findings)
 	}
 
-	currentSize, maxSize, usagePercent := a.LiveContext.GetContextUsage()
-	contextUsage := fmt.Sprintf("Context Usage: %d/%d bytes (%.1f%%)", currentSize, maxSize, usagePercent)
+	// Sections run from the least to the most often changing, so providers can cache the prompt up
+	// to the first section that changed: the static instructions, then plan and task instructions,
+	// then live context. Per-turn data goes after the history.
+	var modeInstructions []string
+	if instructions := a.personaInstructions(); instructions != "" {
+		modeInstructions = append(modeInstructions, instructions)
+	}
+	if a.InPlanMode() {
+		modeInstructions = append(modeInstructions, planModeInstructions)
+	}
+	if instructions := a.taskInstructions(); instructions != "" {
+		modeInstructions = append(modeInstructions, instructions)
+	}
+	modeSection := ""
+	if len(modeInstructions) > 0 {
+		modeSection = "\n" + strings.Join(modeInstructions, "\n\n") + "\n" + api.CacheBreakpoint
+	}
+	prompt = strings.ReplaceAll(prompt, "{MODE_INSTRUCTIONS}", modeSection)
+	prompt = strings.ReplaceAll(prompt, "{CACHE_BREAKPOINT}", api.CacheBreakpoint)
+
+	sections := []promptSection{
+		{"instructions", instructions},
+		{"plan and task instructions", strings.Join(modeInstructions, "\n\n")},
+		{"live context directories", directories},
+		{"live context files", files},
+	}
+	sections = append(sections, providerSections...)
+	marker := turnDataMarker
+	if a.ContextPlacement() == PlacementMessage {
+		marker = referenceDataMarker
+	}
+	prompt, reference := splitReferenceData(prompt, marker)
+	return prompt, reference, sections
+}
 
-	prompt := strings.ReplaceAll(systemPromptTemplate, "{ENV_OS}", runtime.GOOS)
-	prompt = strings.ReplaceAll(prompt, "{ENV_CWD}", cwd)
-	prompt = strings.ReplaceAll(prompt, "{CONTEXT_USAGE}", contextUsage)
-	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_FILES}", a.LiveContext.SerializeFiles())
-	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORIES}", a.LiveContext.SerializeDirectories())
+func (a *Agent) ExecuteToolCall(ctx context.Context, toolCall models.ToolCall) (models.ToolResultEnvelope, error) {
+	tool, exists := a.GetTools()[toolCall.Function.Name]
+	if !exists {
+		return models.ToolResultEnvelope{}, fmt.Errorf("tool '%s' not found", toolCall.Function.Name)
+	}
 
-	return prompt
+	// Unparseable arguments are reported to the model as the result so it can send them again
+	params, repairs, err := tools.ParseArguments(toolCall.Function.Arguments)
+	if err != nil {
+		fmt.Println(theme.WarningText(fmt.Sprintf("⚠ %s: %v", toolCall.Function.Name, err)))
+		return models.NewToolResultEnvelope("error", fmt.Sprintf("The tool was not run: %v. Call it again with the arguments as a single JSON object matching its schema.", err), nil), nil
+	}
+	return a.runTool(ctx, tool, toolCall, params, repairs, true)
 }
 
-func (a *Agent) ExecuteToolCall(ctx context.Context, toolCall models.ToolCall) (string, error) {
-	tool, exists := a.tools[toolCall.Function.Name]
-	if !exists {
-		return "", fmt.Errorf("tool '%s' not found", toolCall.Function.Name)
+// runTool takes a parsed tool call through the checks every call gets, whether the model or a
+// sub-agent made it: schema coercion, permission rules, hooks, the watchdog, the checkpoint, the
+// churn cap, injection marking, and redaction. With show, the call and its output are printed.
+func (a *Agent) runTool(ctx context.Context, tool models.ToolDefinition, toolCall models.ToolCall, params map[string]interface{}, repairs []string, show bool) (models.ToolResultEnvelope, error) {
+	coercions, err := tools.CoerceParams(tool.Schema, params)
+	if err != nil {
+		return models.ToolResultEnvelope{}, err
+	}
+	coercions = append(repairs, coercions...)
+	if len(coercions) > 0 && show {
+		fmt.Println(theme.DebugText("↺ " + strings.Join(coercions, "; ")))
+	}
+
+	a.resolveToolPaths(toolCall.Function.Name, params)
+
+	// Denied calls aren't run; the model gets the denial as the result so it can adapt
+	if err := a.checkPermission(toolCall.Function.Name, params); err != nil {
+		fmt.Println(theme.WarningText(fmt.Sprintf("🚫 %s denied: %v", toolCall.Function.Name, err)))
+		return models.NewToolResultEnvelope("denied", fmt.Sprintf("Permission denied: %v. Don't retry this call; find an allowed alternative or ask the user.", err), nil), nil
+	}
+
+	a.turnStateMu.Lock()
+	err = a.confirmAfterInjection(toolCall.Function.Name)
+	a.turnStateMu.Unlock()
+	if err != nil {
+		return models.NewToolResultEnvelope("denied", err.Error()+". Don't retry; explain to the user what you wanted to do.", nil), nil
+	}
+
+	notes, err := a.runPreHooks(ctx, toolCall.Function.Name, params)
+	if err != nil {
+		envelope := models.NewToolResultEnvelope("denied", fmt.Sprintf("%v. Don't retry this call unchanged; address what the hook reported or ask the user.", err), nil)
+		envelope.Notes = append(coercions, notes...)
+		return envelope, nil
+	}
+
+	if err := a.watch(func(w *watchdog) string { return w.observeCall(a.config.Watchdog, toolCall) }); err != nil {
+		return models.ToolResultEnvelope{}, err
+	}
+
+	dryRun := tools.IsDryRun(ctx) && dryRunTools[toolCall.Function.Name]
+	if a.config.Checkpoints && mutatingTools[toolCall.Function.Name] && !dryRun {
+		a.turnStateMu.Lock()
+		if a.checkpointTurn != a.turn {
+			a.createCheckpoint()
+		}
+		a.turnStateMu.Unlock()
+	}
+
+	if show {
+		fmt.Println(theme.ToolText(formatToolCall(toolCall.Function.Name, params, a.config.Preview)))
 	}
 
-	var params map[string]interface{}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
-		return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+	churnPaths := a.churnPaths(toolCall.Function.Name, params)
+	before := make(map[string]string, len(churnPaths))
+	for _, path := range churnPaths {
+		before[path] = readFileIfExists(path)
 	}
+	journaled := len(a.journal.Entries())
 
 	userMessage, agentMessage, err := tool.Func(ctx, params)
 
-	if userMessage != "" {
-		fmt.Print(lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderForeground(lipgloss.Color("2")). // Green
-			PaddingLeft(2))
+	if userMessage != "" && show {
+		fmt.Fprintln(a.toolOutputWriter(), formatToolOutput(userMessage, a.config.Preview))
 	}
 
-	return agentMessage, err
+	if err != nil {
+		return models.ToolResultEnvelope{}, err
+	}
+
+	if len(churnPaths) > 0 {
+		a.turnStateMu.Lock()
+		err := a.recordChurn(churnPaths, before, journaled)
+		a.turnStateMu.Unlock()
+		if err != nil {
+			return models.ToolResultEnvelope{}, err
+		}
+	}
+	for _, path := range churnPaths {
+		if err := a.watch(func(w *watchdog) string { return w.observeFile(a.config.Watchdog, path, before[path]) }); err != nil {
+			return models.ToolResultEnvelope{}, err
+		}
+	}
+
+	if !dryRun {
+		notes = append(notes, a.runPostHooks(ctx, toolCall.Function.Name, params, agentMessage)...)
+	}
+
+	var artifacts []string
+	if path, ok := params["path"].(string); ok && mutatingTools[toolCall.Function.Name] && !dryRun {
+		artifacts = append(artifacts, path)
+	}
+
+	// Tool output can carry fetched web pages, command output, or file contents written by others
+	if !a.config.Security.DisableInjectionScan {
+		if findings := tools.DetectInjection(agentMessage); len(findings) > 0 {
+			source := toolCall.Function.Name + " result"
+			a.turnStateMu.Lock()
+			a.noteInjection(source, findings)
+			a.turnStateMu.Unlock()
+			agentMessage = tools.WrapUntrusted(source, agentMessage, findings)
+		}
+	}
+
+	// Shell output and files read can hold credentials; mask them before they enter the history
+	agentMessage = a.redact(agentMessage)
+
+	envelope := models.NewToolResultEnvelope("success", agentMessage, artifacts)
+	envelope.Notes = append(coercions, notes...)
+	return envelope, nil
+}
+
+// workDirRelativeTools resolve their path argument against the working directory themselves
+var workDirRelativeTools = map[string]bool{
+	"set_working_directory": true,
+	"run_tests":             true,
+}
+
+// resolveToolPaths rewrites a relative path argument against the working directory, so tools that
+// resolve paths against the workspace root see the file the model meant. A shell cwd is made
+// absolute, so the policy and the shell tool agree on the directory.
+func (a *Agent) resolveToolPaths(tool string, params map[string]interface{}) {
+	if path, ok := params["path"].(string); ok && !workDirRelativeTools[tool] {
+		params["path"] = a.workDir.Resolve(path)
+	}
+	if cwd, ok := params["cwd"].(string); ok && cwd != "" && tool == "shell" {
+		cwd = a.workDir.Resolve(cwd)
+		if !filepath.IsAbs(cwd) && !strings.HasPrefix(cwd, "~") {
+			cwd = filepath.Join(a.workDir.Root(), cwd)
+		}
+		params["cwd"] = cwd
+	}
+}
+
+// patchPaths lists the files a patch changes, resolved against the working directory as apply_patch
+// resolves them
+func (a *Agent) patchPaths(patch string) []string {
+	paths := tools.PatchPaths(patch)
+	for i, path := range paths {
+		paths[i] = a.workDir.Resolve(path)
+	}
+	return paths
+}
+
+// checkPermission checks a tool call against the permission rules. Patches are checked file by
+// file, like calls to the file tools they stand in for.
+func (a *Agent) checkPermission(tool string, params map[string]interface{}) error {
+	if patch, ok := params["patch"].(string); ok && tool == "apply_patch" {
+		for _, path := range a.patchPaths(patch) {
+			if err := a.permissions.Check(tool, map[string]interface{}{"path": path}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return a.permissions.Check(tool, params)
+}
+
+// formatToolCall shows a tool call with one argument per line. Large arguments (e.g. whole files)
+// are only previewed; the tool still receives the full value.
+func formatToolCall(name string, params map[string]interface{}, preview PreviewConfig) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var call strings.Builder
+	call.WriteString("🔧 " + name)
+	for _, key := range keys {
+		value, ok := params[key].(string)
+		if !ok {
+			encoded, _ := json.Marshal(params[key])
+			value = string(encoded)
+		}
+		if strings.Contains(value, "\n") {
+			call.WriteString(fmt.Sprintf("\n  %s:\n%s", key, indent(previewText(value, preview), "    ")))
+		} else {
+			call.WriteString(fmt.Sprintf("\n  %s: %s", key, previewText(value, preview)))
+		}
+	}
+	return call.String()
+}
+
+// formatToolOutput boxes what a tool shows the user
+func formatToolOutput(output string, preview PreviewConfig) string {
+	return lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("2")). // Green
+		PaddingLeft(2).
+		Render(previewText(strings.TrimRight(output, "\n"), preview))
+}
+
+// previewText shortens long text to its first and last lines plus a note with the total size
+func previewText(text string, cfg PreviewConfig) string {
+	maxLines, headLines, tailLines := cfg.MaxLines, cfg.HeadLines, cfg.TailLines
+	if maxLines <= 0 {
+		maxLines = 30
+	}
+	if headLines <= 0 {
+		headLines = 10
+	}
+	if tailLines <= 0 {
+		tailLines = 5
+	}
+	maxChars := maxLines * 200
+
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxLines && len(text) <= maxChars {
+		return text
+	}
+
+	if len(lines) <= headLines+tailLines {
+		if len(text) <= maxChars {
+			return text
+		}
+		// Few but very long lines
+		return fmt.Sprintf("%s... (%d bytes total)", text[:cutBefore(text, maxChars)], len(text))
+	}
+
+	hidden := len(lines) - headLines - tailLines
+	preview := append(append([]string{}, lines[:headLines]...), fmt.Sprintf("⋮ %d lines hidden (%d lines, %d bytes total)", hidden, len(lines), len(text)))
+	return strings.Join(append(preview, lines[len(lines)-tailLines:]...), "\n")
+}
+
+// indent prefixes every line of text
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// startTurn moves on to the next turn and clears what's counted per turn
+func (a *Agent) startTurn() {
+	a.turn++
+	a.journal.SetTurn(a.turn)
+	a.churn = turnChurn{}
+	a.turnResultChars = 0
+	a.watchdog = newWatchdog()
+	a.flaggedSources = nil
 }
 
 // ProcesssMessageWithCancellation handles the complete conversation flow with tool calling
 func (a *Agent) ProcesssMessageWithCancellation(ctx context.Context, model *models.Model, userInput string) error {
-	a.AddUserMessage(userInput)
+	a.startTurn()
+	a.turnSeed = turnSeed(model)
+	a.changedFiles = a.LiveContext.ChangedFiles()
+	a.AddUserMessageWithImages(a.expandAttachments(userInput, model.AcceptsImages()))
 
-	maxIterations := -1
+	stopHeartbeat := a.startHeartbeat()
+	defer stopHeartbeat()
+
+	budget := newTurnBudget(a.config)
 	maxConsecutiveFailures := 3
 	consecutiveFailures := 0
+	warnedWindow := false
 
-	for iteration := 0; maxIterations == -1 || iteration < maxIterations; iteration++ {
-		systemPrompt := a.BuildSystemPrompt()
-
-		modelMessages := (a.GetHistory())
+	for iteration := 0; ; iteration++ {
+		if err := a.checkTurnBudget(budget, iteration); err != nil {
+			a.AddAgentMessage(fmt.Sprintf("Processing %v.", err))
+			return err
+		}
+		a.setProgress(iteration+1, "")
+		systemPrompt, reference, sections := a.buildSystemPrompt()
+
+		historyBudget := a.historyBudget(sections)
+		history := activeMessages(a.GetHistory())
+		_, pruneSpan := a.tracer.Start(ctx, "prune", tracing.KindInternal)
+		keptHistory, dropped := trimHistoryToBudget(history, historyBudget)
+		pruneSpan.SetAttribute("agent.messages_dropped", dropped)
+		pruneSpan.End()
+		modelMessages := keptHistory
+		if reference != "" {
+			modelMessages = withContextMessage(keptHistory, reference)
+		}
 
 		renderer := theme.NewMarkdownRenderer()
 		onReceiveContent := func(token string) {
 			renderer.Write([]byte(token))
 		}
 
+		requestModel := withSeed(a.withOverrides(a.routeRequest(model, systemPrompt, modelMessages), iteration > 0), a.turnSeed)
+		agentTools := a.GetTools()
+		snapshot := a.recordRequest(iteration+1, requestModel, systemPrompt, modelMessages, agentTools)
+		a.explainRequest(iteration+1, requestModel, sections, keptHistory, history[:dropped])
+		if warning := contextWindowWarning(requestModel, systemPrompt, modelMessages); warning != "" && !warnedWindow {
+			fmt.Println(theme.WarningText(warning))
+			warnedWindow = true
+		}
+
 		fmt.Print("🦜 ")
-		renderer.Flush()
 
+		if err := a.limiter.Wait(ctx, requestModel.Provider.ID); err != nil {
+			a.AddCancelledAgentMessage("")
+			return context.Canceled
+		}
+
+		tokensBefore, _ := reportedUsage()
+		invokeCtx, invokeSpan := a.tracer.Start(ctx, "api.invoke", tracing.KindClient)
+		invokeSpan.SetAttribute("gen_ai.system", requestModel.Provider.ID)
+		invokeSpan.SetAttribute("gen_ai.request.model", requestModel.ID)
+		invokeSpan.SetAttribute("agent.iteration", iteration+1)
 		content, toolCalls, err := api.Invoke(
-			ctx,
-			model,
+			invokeCtx,
+			requestModel,
 			modelMessages,
 			systemPrompt,
-			a.GetTools(),
+			agentTools,
 			onReceiveContent,
 		)
+		invokeSpan.SetAttribute("agent.tool_calls", len(toolCalls))
+		invokeSpan.SetError(err)
+		invokeSpan.End()
+		renderer.Flush()
 
 		if err != nil {
-			if err == context.Canceled {
-				fmt.Println("Cancelled by user")
-				return nil
+			if errors.Is(err, context.Canceled) {
+				a.AddCancelledAgentMessage(content)
+				return context.Canceled
 			}
 
 			return fmt.Errorf("AI response error: %w", err)
 		}
 
+		a.recordResponse(snapshot, content, toolCalls)
+		budget.recordRequest(tokensBefore, requestChars(systemPrompt, modelMessages, content, toolCalls))
+		a.renderDiagrams(content)
+
 		if len(toolCalls) > 0 {
 			a.AddAgentMessageWithToolCalls(content, toolCalls)
 
 			var toolResults []models.ToolResult
+			var stopErr error
 
 			for _, toolCall := range toolCalls {
-				result, err := a.ExecuteToolCall(ctx, toolCall)
-				if err != nil {
+				// Every tool call needs a result, including ones skipped or interrupted by cancellation
+				if ctx.Err() != nil {
+					toolResults = append(toolResults, cancelledToolResult(toolCall, "Cancelled by the user before the tool finished"))
+					continue
+				}
+				if stopErr != nil {
+					toolResults = append(toolResults, cancelledToolResult(toolCall, "Not run: "+stopErr.Error()))
+					continue
+				}
+
+				a.setProgress(iteration+1, toolCall.Function.Name)
+				toolCtx, images := tools.WithImageCollector(ctx)
+				toolCtx = tools.WithProgress(toolCtx, a.toolProgress())
+				if a.InDryRun() {
+					toolCtx = tools.WithDryRun(toolCtx)
+				}
+				toolCtx, toolSpan := a.tracer.Start(toolCtx, "tool "+toolCall.Function.Name, tracing.KindInternal)
+				started := time.Now()
+				result, err := a.ExecuteToolCall(toolCtx, toolCall)
+				elapsed := time.Since(started)
+				toolSpan.SetAttribute("gen_ai.tool.name", toolCall.Function.Name)
+				toolSpan.SetError(err)
+				toolSpan.End()
+				a.setProgress(iteration+1, "")
+				failed := false
+				if err != nil && ctx.Err() != nil {
+					toolResults = append(toolResults, cancelledToolResult(toolCall, "Cancelled by the user before the tool finished"))
+				} else if errors.Is(err, errStoppedByUser) {
+					stopErr = err
+					toolResults = append(toolResults, cancelledToolResult(toolCall, "Interrupted: "+err.Error()))
+				} else if err != nil {
 					consecutiveFailures++
+					failed = true
 
 					toolResults = append(toolResults, models.ToolResult{
 						ID:      toolCall.ID,
 						Name:    toolCall.Function.Name,
-						Content: fmt.Sprintf("Tool execution failed: %v", err),
+						Content: models.NewToolResultEnvelope("error", fmt.Sprintf("Tool execution failed: %v", err), nil).JSON(),
 						IsError: true,
 					})
-
-					if consecutiveFailures >= maxConsecutiveFailures {
-						a.AddToolResultsMessage(toolResults)
-						return fmt.Errorf("tool execution failed after %d consecutive attempts: %w", maxConsecutiveFailures, err)
-					}
 				} else {
 					consecutiveFailures = 0
 					toolResults = append(toolResults, models.ToolResult{
 						ID:      toolCall.ID,
 						Name:    toolCall.Function.Name,
-						Content: result,
+						Content: result.JSON(),
 						IsError: false,
+						Images:  images.Images(),
 					})
 				}
+				a.recordToolCall(iteration+1, toolResults[len(toolResults)-1], elapsed)
+
+				if failed && consecutiveFailures >= maxConsecutiveFailures {
+					a.AddToolResultsMessage(toolResults)
+					return fmt.Errorf("tool execution failed after %d consecutive attempts: %w", maxConsecutiveFailures, err)
+				}
 			}
 
 			a.AddToolResultsMessage(toolResults)
+			if ctx.Err() != nil {
+				return context.Canceled
+			}
+			if stopErr != nil {
+				return stopErr
+			}
 			continue
 		} else {
 			a.AddAgentMessage(content)
@@ -375,14 +1052,112 @@ func (a *Agent) ProcesssMessageWithCancellation(ctx context.Context, model *mode
 			return nil
 		}
 	}
+}
 
-	finalMsg := fmt.Sprintf("Reached maximum tool call iterations (%d). Processing stopped.", maxIterations)
-	a.AddAgentMessage(finalMsg)
-	return fmt.Errorf("reached maximum iterations")
+// cancelledToolResult is the result recorded for a tool call the user cancelled or stopped
+func cancelledToolResult(toolCall models.ToolCall, reason string) models.ToolResult {
+	return models.ToolResult{
+		ID:          toolCall.ID,
+		Name:        toolCall.Function.Name,
+		Content:     models.NewToolResultEnvelope("cancelled", reason, nil).JSON(),
+		IsCancelled: true,
+	}
 }
 
+// GetTools returns the tools available in the current mode
 func (a *Agent) GetTools() map[string]models.ToolDefinition {
-	return a.tools
+	agentTools := a.tools
+	if _, persona, ok := a.Persona(); ok {
+		agentTools = personaTools(agentTools, persona.Tools)
+	}
+	if a.InPlanMode() {
+		return planModeTools(agentTools)
+	}
+	return agentTools
+}
+
+// messageSize returns the number of characters a message contributes to a request
+func messageSize(msg models.Message) int {
+	size := len(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		size += len(tc.Function.Name) + len(tc.Function.Arguments)
+	}
+	return size
+}
+
+// activeMessages returns the messages sent to the model: without the ones removed from the
+// conversation, and with interrupted responses marked as such
+func activeMessages(messages []models.Message) []models.Message {
+	active := make([]models.Message, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Status {
+		case "deleted":
+			continue
+		case "interrupted":
+			// Tell the model its response was cut off rather than finished
+			msg.Content += interruptedMarker
+		}
+		active = append(active, msg)
+	}
+	return active
+}
+
+// historyBudget is the history's share of budget.total_chars, less what the system prompt takes
+// beyond its budget.system share, so a long prompt can't push the request past total_chars. Live
+// context is held to its own share. It's 0, for no limit, when budgeting is disabled.
+func (a *Agent) historyBudget(sections []promptSection) int {
+	systemBudget, _, historyBudget := a.config.Budget.Limits()
+	if historyBudget <= 0 {
+		return 0
+	}
+	return max(1, historyBudget-max(0, systemSize(sections)-systemBudget))
+}
+
+// systemSize is the size of the prompt sections that count against budget.system: everything
+// but live context
+func systemSize(sections []promptSection) int {
+	size := 0
+	for _, section := range sections {
+		if !strings.HasPrefix(section.Name, "live context") {
+			size += len(section.Text)
+		}
+	}
+	return size
+}
+
+// trimHistoryToBudget drops the oldest turns until the history fits in budget characters. Turns are
+// dropped whole, starting at a user message, so tool calls stay paired with their results. The most
+// recent turn is always kept. A budget of zero or less means unlimited. Returns the kept messages and
+// the number of messages dropped.
+func trimHistoryToBudget(messages []models.Message, budget int) ([]models.Message, int) {
+	if budget <= 0 {
+		return messages, 0
+	}
+
+	total := 0
+	for _, msg := range messages {
+		total += messageSize(msg)
+	}
+
+	start := 0
+	for total > budget {
+		next := -1
+		for i := start + 1; i < len(messages); i++ {
+			if messages[i].Role == "user" {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			break
+		}
+		for _, msg := range messages[start:next] {
+			total -= messageSize(msg)
+		}
+		start = next
+	}
+
+	return messages[start:], start
 }
 
 // GetContextCharacterCount calculates the total character count of the context
@@ -393,7 +1168,7 @@ func (a *Agent) GetContextCharacterCount() int {
 	totalChars := 0
 
 	for _, msg := range a.Messages {
-		if msg.Status == "active" {
+		if msg.Status != "deleted" {
 			totalChars += len(msg.Content)
 		}
 	}
@@ -406,10 +1181,11 @@ func (a *Agent) GetContextCharacterCount() int {
 	return totalChars
 }
 
-// InitializeDefaultContext sets up the default context with current directory and README.md
-func (a *Agent) InitializeDefaultContext() {
+// InitializeDefaultContext sets up the default context with current directory and README.md, and
+// the files with uncommitted changes when seed_git_changes is set. It returns the files seeded from git.
+func (a *Agent) InitializeDefaultContext() []string {
 	if a.LiveContext == nil {
-		return
+		return nil
 	}
 
 	_ = a.LiveContext.AddDirectory(".", true)
@@ -417,46 +1193,164 @@ func (a *Agent) InitializeDefaultContext() {
 	if _, err := os.Stat("README.md"); err == nil {
 		_ = a.LiveContext.AddFile("README.md", 1, nil)
 	}
+
+	if a.config != nil && a.config.SeedGitChanges {
+		return a.seedContextFromGit()
+	}
+	return nil
 }
 
 // SessionLogger logs messages to a session-specific JSONL file.
 type SessionLogger struct {
+	ID      string
+	dir     string
+	mu      sync.Mutex // miniagents and content filters log from other goroutines
 	logFile *os.File
 	encoder *json.Encoder
+	cipher  *sessionCipher // encrypts the lines written after its header; nil writes plaintext
+	stopped bool           // the log couldn't be encrypted, so nothing more is written
 }
 
 // NewSessionLogger creates a new SessionLogger for a given session.
 // It creates a new log file named with a timestamp in ~/.agent/sessions/.
 func NewSessionLogger() *SessionLogger {
+	logger, err := openSessionLogger(time.Now().Format("20060102150405"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return logger
+}
+
+// openSessionLogger opens the log of the session with the given ID in ~/.agent/sessions/,
+// appending to it if it exists
+func openSessionLogger(id string) (*SessionLogger, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatalf("failed to get user home directory: %v", err)
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
 	}
 
-	sessionDir := filepath.Join(homeDir, ".agent", "sessions")
+	sessionDir := sessionsDir(homeDir)
 	if err := os.MkdirAll(sessionDir, 0755); err != nil {
-		log.Fatalf("failed to create session directory: %v", err)
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
 	}
 
-	timestamp := time.Now().Format("20060102150405")
-	logFileName := filepath.Join(sessionDir, fmt.Sprintf("%s.jsonl", timestamp))
-
+	logFileName := filepath.Join(sessionDir, fmt.Sprintf("%s.jsonl", id))
 	logFile, err := os.OpenFile(logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Fatalf("failed to open log file: %v", err)
+		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
-	return &SessionLogger{
+	logger := &SessionLogger{
+		ID:      id,
+		dir:     sessionDir,
 		logFile: logFile,
 		encoder: json.NewEncoder(logFile),
 	}
+	if passphrase := sessionPassphrase.Load(); passphrase != nil && *passphrase != "" {
+		if err := logger.encrypt(*passphrase); err != nil {
+			logFile.Close()
+			return nil, fmt.Errorf("failed to encrypt session log: %w", err)
+		}
+	}
+	return logger, nil
+}
+
+// sessionsDir returns the directory holding session logs
+func sessionsDir(homeDir string) string {
+	return filepath.Join(homeDir, ".agent", "sessions")
+}
+
+// checkpointDir returns the directory holding original file contents for a session's change journal
+func checkpointDir(sessionID string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	return filepath.Join(homeDir, ".agent", "checkpoints", sessionID)
+}
+
+// artifactsDir returns the directory holding a session's artifacts, outside the workspace so bulky
+// outputs don't end up in the source tree
+func artifactsDir(sessionID string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	return filepath.Join(homeDir, ".agent", "artifacts", sessionID)
 }
 
 // LogMessage logs a single message to the session log file.
 func (sl *SessionLogger) LogMessage(message models.Message) {
-	if err := sl.encoder.Encode(message); err != nil {
-		fmt.Printf("Error encoding message to log file: %v\n", err)
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if err := sl.encode(message); err != nil {
+		logging.Errorf("Error encoding message to log file: %v", err)
+	}
+}
+
+// LogRecord logs a non-message record, such as a RequestRecord, to the session log file.
+func (sl *SessionLogger) LogRecord(record any) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if err := sl.encode(record); err != nil {
+		logging.Errorf("Error encoding record to log file: %v", err)
+	}
+}
+
+// encode writes v as one line of the log with its credentials masked, encrypted when the log is
+func (sl *SessionLogger) encode(v any) error {
+	if !sl.writable() {
+		return nil
+	}
+	data, err := redactJSON(v, false)
+	if err == nil && sl.cipher != nil {
+		data, err = sl.cipher.seal(data)
 	}
+	if err != nil {
+		return err
+	}
+	return sl.encoder.Encode(json.RawMessage(data))
+}
+
+// encrypt writes the rest of the log, and request snapshots, encrypted with a key derived from
+// passphrase. A log that is already encrypted keeps its key.
+func (sl *SessionLogger) encrypt(passphrase string) error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.cipher != nil {
+		return nil
+	}
+	cipher, err := newSessionCipher(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := sl.encoder.Encode(cipher.header); err != nil {
+		return err
+	}
+	sl.cipher = cipher
+	return nil
+}
+
+// writable reports whether lines may be written; it's false while the log should be encrypted but
+// can't be. The caller holds sl.mu.
+func (sl *SessionLogger) writable() bool {
+	return !sl.stopped && !sessionLogsStopped.Load()
+}
+
+// stop stops writing the log
+func (sl *SessionLogger) stop() {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.stopped = true
+}
+
+// SnapshotPath returns where the full request for a turn's iteration is saved.
+func (sl *SessionLogger) SnapshotPath(turn, iteration int) string {
+	return sl.sessionSnapshotPath(sl.ID, turn, iteration)
+}
+
+func (sl *SessionLogger) sessionSnapshotPath(sessionID string, turn, iteration int) string {
+	return filepath.Join(sl.dir, sessionID, "requests", fmt.Sprintf("%d-%d.json", turn, iteration))
 }
 
 // Close closes the session log file.
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"agent/models"
+	"agent/permissions"
+	"agent/tools"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteMessageByID(t *testing.T) {
+	var log bytes.Buffer
+	call := models.ToolCall{ID: "c1"}
+	call.Function.Name = "shell"
+	other := models.ToolCall{ID: "c2"}
+	other.Function.Name = "glob"
+	a := &Agent{
+		sessionLogger: &SessionLogger{encoder: json.NewEncoder(&log)},
+		Messages: []models.Message{
+			{ID: "1", Role: "user", Content: "build it", Status: "active"},
+			{ID: "2", Role: "assistant", ToolCalls: []models.ToolCall{call, other}, Status: "active"},
+			{ID: "3", Role: "tool", ToolCallID: "c1", Content: "long build log", Status: "active"},
+			{ID: "4", Role: "tool", ToolCallID: "c2", Content: "main.go", Status: "active"},
+			{ID: "5", Role: "assistant", Content: "done", Status: "active"},
+		},
+	}
+
+	removed, err := a.DeleteMessageByID("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, removed)
+
+	// Removing a result removes its call and the call's other results
+	removed, err = a.DeleteMessageByID("3")
+	assert.NoError(t, err)
+	var ids []string
+	for _, msg := range removed {
+		ids = append(ids, msg.ID)
+	}
+	assert.Equal(t, []string{"3", "2", "4"}, ids)
+
+	active := activeMessages(a.GetHistory())
+	assert.Len(t, active, 2)
+	assert.Equal(t, "1", active[0].ID)
+	assert.Equal(t, "5", active[1].ID)
+
+	// Each removed message leaves a tombstone with its ID and without its content
+	tombstones := strings.Split(strings.TrimSpace(log.String()), "\n")
+	assert.Len(t, tombstones, 3)
+	var tombstone models.Message
+	assert.NoError(t, json.Unmarshal([]byte(tombstones[0]), &tombstone))
+	assert.Equal(t, "3", tombstone.ID)
+	assert.Equal(t, "deleted", tombstone.Status)
+	assert.Empty(t, tombstone.Content)
+
+	_, err = a.DeleteMessageByID("2")
+	assert.ErrorContains(t, err, "already removed")
+}
+
+func TestAddCancelledAgentMessage(t *testing.T) {
+	var log bytes.Buffer
+	a := &Agent{sessionLogger: &SessionLogger{encoder: json.NewEncoder(&log)}}
+
+	a.AddCancelledAgentMessage("")
+	a.AddCancelledAgentMessage("The bug is in the parser: it")
+	assert.Equal(t, "cancelled", a.Messages[0].Status)
+	assert.Equal(t, "interrupted", a.Messages[1].Status)
+	assert.Equal(t, "The bug is in the parser: it", a.Messages[1].Content)
+	assert.Contains(t, log.String(), `"status":"interrupted"`)
+
+	// The model is told the response was cut off, without changing the stored message
+	active := activeMessages(a.Messages)
+	assert.Equal(t, "The bug is in the parser: it"+interruptedMarker, active[1].Content)
+	assert.Equal(t, "The bug is in the parser: it", a.Messages[1].Content)
+}
+
+func TestResolveToolPaths(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "api", "handlers"), 0755))
+	a := &Agent{workDir: tools.NewWorkingDirectory(root)}
+	_, err := a.workDir.Set("api")
+	assert.NoError(t, err)
+
+	params := map[string]interface{}{"path": "routes.go"}
+	a.resolveToolPaths("read_file", params)
+	assert.Equal(t, filepath.Join("api", "routes.go"), params["path"])
+
+	params = map[string]interface{}{"command": "ls", "cwd": "handlers"}
+	a.resolveToolPaths("shell", params)
+	assert.Equal(t, filepath.Join(root, "api", "handlers"), params["cwd"])
+
+	patch := "--- a/routes.go\n+++ b/routes.go\n@@ -1 +1 @@\n-a\n+b\n"
+	assert.Equal(t, []string{filepath.Join("api", "routes.go")}, a.patchPaths(patch))
+
+	// A shell cwd outside the workspace is checked like any other path
+	a.permissions, _ = permissions.New(root, []string{"deny *:./api/handlers/**"})
+	a.permissions.ConfineTo([]string{root})
+	params = map[string]interface{}{"command": "ls", "cwd": "../.."}
+	a.resolveToolPaths("shell", params)
+	assert.ErrorContains(t, a.checkPermission("shell", params), "outside the workspace")
+	params = map[string]interface{}{"command": "ls", "cwd": "handlers/v1"}
+	a.resolveToolPaths("shell", params)
+	assert.ErrorContains(t, a.checkPermission("shell", params), "denied by rule")
+	assert.ErrorContains(t, a.checkPermission("apply_patch", map[string]interface{}{"patch": "--- a/handlers/x.go\n+++ b/handlers/x.go\n@@ -1 +1 @@\n-a\n+b\n"}), "denied by rule")
+}
+
+func TestPreviewText(t *testing.T) {
+	small := PreviewConfig{MaxLines: 4, HeadLines: 1, TailLines: 1} // 800 characters
+	cases := []struct {
+		name     string
+		text     string
+		cfg      PreviewConfig
+		expected string
+	}{
+		{"short", "one\ntwo", small, "one\ntwo"},
+		{"at the line limit", "1\n2\n3\n4", small, "1\n2\n3\n4"},
+		{"over the line limit", "1\n2\n3\n4\n5\n6", small, "1\n⋮ 4 lines hidden (6 lines, 11 bytes total)\n6"},
+		{"default line limit", strings.Repeat("line\n", 40) + "end", PreviewConfig{}, strings.Repeat("line\n", 10) + "⋮ 26 lines hidden (41 lines, 203 bytes total)\n" + strings.Repeat("line\n", 4) + "end"},
+		{"at the char limit", strings.Repeat("x", 800), small, strings.Repeat("x", 800)},
+		{"over the char limit", strings.Repeat("x", 900), small, strings.Repeat("x", 800) + "... (900 bytes total)"},
+		{"multi-byte", "x" + strings.Repeat("é", 450), small, "x" + strings.Repeat("é", 399) + "... (901 bytes total)"},
+		{"multi-byte on a boundary", strings.Repeat("é", 450), small, strings.Repeat("é", 400) + "... (900 bytes total)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			preview := previewText(c.text, c.cfg)
+			assert.Equal(t, c.expected, preview)
+			assert.True(t, utf8.ValidString(preview))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"agent/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+)
+
+// CacheBreakpoint separates the sections of a system prompt, ordered from least to most often
+// changing. Invoke removes it before sending; for models that take explicit cache breakpoints it
+// marks the end of each section the provider should cache.
+const CacheBreakpoint = "\n<!-- cache breakpoint -->\n"
+
+// Prompt cache modes a model can set in its prompt_cache field
+const (
+	CacheAuto    = ""              // rely on the provider caching repeated prefixes by itself
+	CacheControl = "cache_control" // mark cached sections explicitly, as Anthropic models require
+	CacheKey     = "key"           // send a prompt_cache_key so OpenAI routes requests sharing a prefix together
+	CacheOff     = "off"           // send no cache hints and don't request usage
+)
+
+// maxCacheBreakpoints is the most cache_control markers Anthropic accepts in a request. One is kept
+// for the end of the history.
+const maxCacheBreakpoints = 4
+
+var cacheControlField = map[string]any{"cache_control": map[string]string{"type": "ephemeral"}}
+
+// CacheUsage counts the prompt tokens providers reported and how many of them were read from their cache
+type CacheUsage struct {
+	Requests     int
+	PromptTokens int
+	CachedTokens int
+}
+
+var cacheUsage struct {
+	sync.Mutex
+	CacheUsage
+}
+
+// PromptCacheUsage returns the prompt cache usage of every request sent so far
+func PromptCacheUsage() CacheUsage {
+	cacheUsage.Lock()
+	defer cacheUsage.Unlock()
+	return cacheUsage.CacheUsage
+}
+
+func recordCacheUsage(usage openai.CompletionUsage) {
+	if usage.PromptTokens == 0 {
+		return
+	}
+	cacheUsage.Lock()
+	defer cacheUsage.Unlock()
+	cacheUsage.Requests++
+	cacheUsage.PromptTokens += int(usage.PromptTokens)
+	cacheUsage.CachedTokens += int(usage.PromptTokensDetails.CachedTokens)
+}
+
+// cacheMode returns the model's prompt cache mode, picking one from the model and provider when unset
+func cacheMode(model *models.Model) string {
+	if model.PromptCache != CacheAuto {
+		return model.PromptCache
+	}
+	id := strings.ToLower(model.ID)
+	switch {
+	case strings.Contains(id, "claude") || strings.HasPrefix(id, "anthropic/"):
+		return CacheControl
+	case model.Provider != nil && strings.Contains(model.Provider.BaseURL, "api.openai.com"):
+		return CacheKey
+	}
+	return CacheAuto
+}
+
+// systemSections splits a system prompt at its cache breakpoints, dropping empty sections
+func systemSections(systemPrompt string) []string {
+	var sections []string
+	for _, section := range strings.Split(systemPrompt, CacheBreakpoint) {
+		if strings.TrimSpace(section) != "" {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// systemMessage builds the system message. With breakpoints, each section but the last is its own
+// content part marked for caching, and the last is cached with the history after it; otherwise the
+// sections are joined back into plain text.
+func systemMessage(systemPrompt string, breakpoints bool) openai.ChatCompletionMessageParamUnion {
+	sections := systemSections(systemPrompt)
+	if !breakpoints || len(sections) < 2 {
+		return openai.SystemMessage(strings.Join(sections, "\n"))
+	}
+
+	parts := make([]openai.ChatCompletionContentPartTextParam, len(sections))
+	for i, section := range sections {
+		parts[i] = openai.ChatCompletionContentPartTextParam{Text: section}
+		if i < len(sections)-1 && i < maxCacheBreakpoints-1 {
+			parts[i].SetExtraFields(cacheControlField)
+		}
+	}
+	return openai.SystemMessage(parts)
+}
+
+// markCached marks the end of a message for caching by sending its text as a content part with a
+// cache_control marker. It returns false for a message without text to mark, such as an assistant
+// message with only tool calls.
+func markCached(message *openai.ChatCompletionMessageParamUnion) bool {
+	cached := func(text string) openai.ChatCompletionContentPartTextParam {
+		part := openai.ChatCompletionContentPartTextParam{Text: text}
+		part.SetExtraFields(cacheControlField)
+		return part
+	}
+	switch {
+	case message.OfUser != nil:
+		content := &message.OfUser.Content
+		if content.OfString.Valid() {
+			part := cached(content.OfString.Value)
+			*content = openai.ChatCompletionUserMessageParamContentUnion{OfArrayOfContentParts: []openai.ChatCompletionContentPartUnionParam{{OfText: &part}}}
+			return true
+		}
+		for i := len(content.OfArrayOfContentParts) - 1; i >= 0; i-- {
+			if text := content.OfArrayOfContentParts[i].OfText; text != nil {
+				text.SetExtraFields(cacheControlField)
+				return true
+			}
+		}
+	case message.OfTool != nil:
+		content := &message.OfTool.Content
+		if content.OfString.Valid() {
+			*content = openai.ChatCompletionToolMessageParamContentUnion{OfArrayOfContentParts: []openai.ChatCompletionContentPartTextParam{cached(content.OfString.Value)}}
+			return true
+		}
+	case message.OfAssistant != nil:
+		content := &message.OfAssistant.Content
+		if content.OfString.Valid() && content.OfString.Value != "" {
+			part := cached(content.OfString.Value)
+			*content = openai.ChatCompletionAssistantMessageParamContentUnion{OfArrayOfContentParts: []openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion{{OfText: &part}}}
+			return true
+		}
+	}
+	return false
+}
+
+// promptCacheKey identifies the prompt's first, most stable section, so requests that can share a
+// cached prefix carry the same key
+func promptCacheKey(systemPrompt string) string {
+	sections := systemSections(systemPrompt)
+	if len(sections) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sections[0]))
+	return "agent-" + hex.EncodeToString(sum[:8])
+}
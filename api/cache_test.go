@@ -0,0 +1,125 @@
+package api
+
+import (
+	"agent/models"
+	"encoding/json"
+	"testing"
+)
+
+func TestSystemMessageCacheBreakpoints(t *testing.T) {
+	prompt := "instructions\n" + CacheBreakpoint + "live context\n" + CacheBreakpoint + "usage"
+
+	var sent struct {
+		Content []struct {
+			Text         string            `json:"text"`
+			CacheControl map[string]string `json:"cache_control"`
+		} `json:"content"`
+	}
+	data, err := json.Marshal(systemMessage(prompt, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &sent); err != nil || len(sent.Content) != 3 {
+		t.Fatalf("Expected three content parts, got %s", data)
+	}
+	for i, part := range sent.Content[:2] {
+		if part.CacheControl["type"] != "ephemeral" {
+			t.Errorf("Expected part %d to be cached, got %s", i, data)
+		}
+	}
+	if sent.Content[2].CacheControl != nil || sent.Content[2].Text != "usage" {
+		t.Errorf("Expected the last part to be uncached, got %s", data)
+	}
+
+	// Without breakpoints the markers are removed and the prompt is sent as text
+	var plain struct {
+		Content string `json:"content"`
+	}
+	data, err = json.Marshal(systemMessage(prompt, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &plain); err != nil || plain.Content != "instructions\n\nlive context\n\nusage" {
+		t.Errorf("Expected the sections joined as text, got %s", data)
+	}
+}
+
+func TestHistoryCacheBreakpoint(t *testing.T) {
+	messages := []models.Message{
+		{Role: "user", Content: "read main.go"},
+		{Role: "assistant", Content: "Reading it.", ToolCalls: []models.ToolCall{{ID: "1", Function: models.FunctionCall{Name: "read_file"}}}},
+		{Role: "tool", Content: "package main", ToolCallID: "1"},
+		{ID: models.ContextMessageID, Role: "user", Content: "Context Usage: 1%"},
+	}
+	cachedParts := func(cacheBreakpoints bool) []string {
+		data, err := json.Marshal(convertMessages(messages, "instructions", cacheBreakpoints))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sent []struct {
+			Content json.RawMessage `json:"content"`
+		}
+		if err := json.Unmarshal(data, &sent); err != nil {
+			t.Fatal(err)
+		}
+		var cached []string
+		for _, message := range sent {
+			var parts []struct {
+				Text         string            `json:"text"`
+				CacheControl map[string]string `json:"cache_control"`
+			}
+			json.Unmarshal(message.Content, &parts)
+			for _, part := range parts {
+				if part.CacheControl != nil {
+					cached = append(cached, part.Text)
+				}
+			}
+		}
+		return cached
+	}
+
+	// The last history message is cached, not the context message after it
+	if cached := cachedParts(true); len(cached) != 1 || cached[0] != "package main" {
+		t.Errorf("Expected only the tool result to be cached, got %q", cached)
+	}
+	if cached := cachedParts(false); len(cached) != 0 {
+		t.Errorf("Expected nothing cached without breakpoints, got %q", cached)
+	}
+
+	// A message without text to mark passes the breakpoint to the one before it
+	messages = []models.Message{
+		{Role: "user", Content: "read main.go"},
+		{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "1", Function: models.FunctionCall{Name: "read_file"}}}},
+	}
+	if cached := cachedParts(true); len(cached) != 1 || cached[0] != "read main.go" {
+		t.Errorf("Expected the user message to be cached, got %q", cached)
+	}
+}
+
+func TestCacheMode(t *testing.T) {
+	openrouter := &models.Provider{BaseURL: "https://openrouter.ai/api/v1"}
+	cases := []struct {
+		model    models.Model
+		expected string
+	}{
+		{models.Model{ID: "anthropic/claude-sonnet-4", Provider: openrouter}, CacheControl},
+		{models.Model{ID: "gpt-4.1", Provider: &models.Provider{BaseURL: "https://api.openai.com/v1"}}, CacheKey},
+		{models.Model{ID: "qwen/qwen3-coder", Provider: openrouter}, CacheAuto},
+		{models.Model{ID: "anthropic/claude-sonnet-4", PromptCache: CacheOff, Provider: openrouter}, CacheOff},
+	}
+	for _, c := range cases {
+		if mode := cacheMode(&c.model); mode != c.expected {
+			t.Errorf("Expected %s to use cache mode %q, got %q", c.model.ID, c.expected, mode)
+		}
+	}
+}
+
+func TestConvertToolsIsSorted(t *testing.T) {
+	tools := map[string]models.ToolDefinition{"shell": {Name: "shell"}, "edit_file": {Name: "edit_file"}, "view_image": {Name: "view_image"}}
+	for i := 0; i < 5; i++ {
+		converted := convertTools(tools)
+		if converted[0].Function.Name != "edit_file" || converted[1].Function.Name != "shell" || converted[2].Function.Name != "view_image" {
+			t.Fatalf("Expected tools in name order, got %v", converted)
+		}
+	}
+}
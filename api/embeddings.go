@@ -0,0 +1,48 @@
+package api
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Embed returns one embedding vector per text from an OpenAI-compatible embeddings endpoint. Local
+// servers (e.g. Ollama or llama.cpp) work too when configured as a provider with their base URL.
+func Embed(ctx context.Context, provider *models.Provider, model string, texts []string) ([][]float32, error) {
+	apiKey, err := ResolveAPIKey(provider.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s API key: %w", provider.Name, err)
+	}
+	client := openai.NewClient(
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(provider.BaseURL),
+		trackQuota(provider.ID),
+	)
+
+	response, err := client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: model,
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s embeddings error: %w", provider.Name, err)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", provider.Name, len(response.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || int(item.Index) >= len(texts) {
+			return nil, fmt.Errorf("%s returned an embedding with invalid index %d", provider.Name, item.Index)
+		}
+		vector := make([]float32, len(item.Embedding))
+		for i, value := range item.Embedding {
+			vector[i] = float32(value)
+		}
+		vectors[item.Index] = vector
+	}
+	return vectors, nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"agent/models"
+	"sync"
+)
+
+// OutboundFilter may transform or block a request's prompt before it is sent. It must not modify
+// messages in place; it returns the prompt to send instead.
+type OutboundFilter func(systemPrompt string, messages []models.Message) (string, []models.Message, error)
+
+var outbound struct {
+	sync.RWMutex
+	filter OutboundFilter
+}
+
+// SetOutboundFilter installs the filter every request goes through, including those from
+// miniagents, sub-agents, and the command auditor
+func SetOutboundFilter(filter OutboundFilter) {
+	outbound.Lock()
+	defer outbound.Unlock()
+	outbound.filter = filter
+}
+
+func filterOutbound(systemPrompt string, messages []models.Message) (string, []models.Message, error) {
+	outbound.RLock()
+	filter := outbound.filter
+	outbound.RUnlock()
+	if filter == nil {
+		return systemPrompt, messages, nil
+	}
+	return filter(systemPrompt, messages)
+}
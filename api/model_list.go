@@ -0,0 +1,138 @@
+package api
+
+import (
+	"agent/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// nonChatModels are substrings of model IDs that OpenAI's /models lists but that can't chat
+var nonChatModels = []string{"embedding", "tts", "whisper", "dall-e", "moderation", "transcribe", "davinci", "babbage"}
+
+// modelList is the response of an OpenAI-compatible /models endpoint. OpenRouter adds the name,
+// context length, pricing, modalities, and supported parameters.
+type modelList struct {
+	Data []struct {
+		ID            string `json:"id"`
+		Name          string `json:"name"`
+		ContextLength int    `json:"context_length"`
+		Pricing       *struct {
+			Prompt         string `json:"prompt"` // dollars per token
+			Completion     string `json:"completion"`
+			InputCacheRead string `json:"input_cache_read"`
+		} `json:"pricing"`
+		Architecture struct {
+			InputModalities []string `json:"input_modalities"`
+		} `json:"architecture"`
+		SupportedParameters []string `json:"supported_parameters"`
+	} `json:"data"`
+}
+
+// ollamaTags is the response of Ollama's /api/tags endpoint
+type ollamaTags struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// ListModels asks a provider which models it serves: Ollama's /api/tags for Ollama servers and the
+// OpenAI-compatible /models endpoint otherwise. The models have their ID and name, plus the context
+// window, pricing, and tool and image support when the provider reports them (OpenRouter does).
+func ListModels(ctx context.Context, provider *models.Provider) ([]*models.Model, error) {
+	if isOllama(provider.BaseURL) {
+		return listOllamaModels(ctx, provider)
+	}
+
+	var list modelList
+	if err := getJSON(ctx, provider, strings.TrimRight(provider.BaseURL, "/")+"/models", &list); err != nil {
+		return nil, err
+	}
+	var listed []*models.Model
+	for _, item := range list.Data {
+		if item.ID == "" || slices.ContainsFunc(nonChatModels, func(kind string) bool { return strings.Contains(item.ID, kind) }) {
+			continue
+		}
+		model := &models.Model{ID: item.ID, Name: item.Name}
+		model.Config.ContextWindow = item.ContextLength
+		if item.Pricing != nil {
+			model.Config.Pricing = &models.Pricing{
+				Input:       perMillion(item.Pricing.Prompt),
+				CachedInput: perMillion(item.Pricing.InputCacheRead),
+				Output:      perMillion(item.Pricing.Completion),
+			}
+		}
+		if len(item.SupportedParameters) > 0 {
+			supportsTools := slices.Contains(item.SupportedParameters, "tools")
+			model.Config.SupportsTools = &supportsTools
+		}
+		model.Config.SupportsVision = slices.Contains(item.Architecture.InputModalities, "image")
+		listed = append(listed, model)
+	}
+	return listed, nil
+}
+
+func listOllamaModels(ctx context.Context, provider *models.Provider) ([]*models.Model, error) {
+	base, err := url.Parse(provider.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	var tags ollamaTags
+	if err := getJSON(ctx, provider, base.Scheme+"://"+base.Host+"/api/tags", &tags); err != nil {
+		return nil, err
+	}
+	var listed []*models.Model
+	for _, tag := range tags.Models {
+		listed = append(listed, &models.Model{ID: tag.Name})
+	}
+	return listed, nil
+}
+
+// isOllama recognizes Ollama servers by their default port or their name in the URL
+func isOllama(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	return err == nil && (u.Port() == "11434" || strings.Contains(u.Host, "ollama"))
+}
+
+func getJSON(ctx context.Context, provider *models.Provider, endpoint string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	apiKey, err := ResolveAPIKey(provider.APIKey)
+	if err != nil {
+		return fmt.Errorf("%s API key: %w", provider.Name, err)
+	}
+	if apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("listing %s models: %w", provider.Name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 500))
+		return fmt.Errorf("listing %s models: %s: %s", provider.Name, response.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("listing %s models: %w", provider.Name, err)
+	}
+	return nil
+}
+
+// perMillion converts a price per token, as OpenRouter reports it, to dollars per million tokens
+func perMillion(price string) float64 {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value * 1e6
+}
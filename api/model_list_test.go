@@ -0,0 +1,73 @@
+package api
+
+import (
+	"agent/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data": [
+				{"id": "text-embedding-3-small"},
+				{"id": "gpt-4o"},
+				{"id": "vendor/vision-model", "name": "Vision Model", "context_length": 200000,
+				 "pricing": {"prompt": "0.000003", "completion": "0.000015", "input_cache_read": "0.0000003"},
+				 "architecture": {"input_modalities": ["text", "image"]},
+				 "supported_parameters": ["temperature", "tools"]},
+				{"id": "vendor/plain", "supported_parameters": ["temperature"]}
+			]}`))
+		case "/api/tags":
+			w.Write([]byte(`{"models": [{"name": "llama3:latest"}, {"name": "qwen2.5-coder:7b"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := &models.Provider{ID: "test", Name: "Test", BaseURL: server.URL + "/v1", APIKey: "test-key"}
+	listed, err := ListModels(context.Background(), provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 || listed[0].ID != "gpt-4o" {
+		t.Fatalf("Expected the chat models, got %+v", listed)
+	}
+	if listed[0].Config.SupportsTools != nil || listed[0].Config.Pricing != nil {
+		t.Errorf("Expected no metadata for a plain OpenAI listing, got %+v", listed[0].Config)
+	}
+	vision := listed[1]
+	if vision.Name != "Vision Model" || vision.Config.ContextWindow != 200000 || !vision.Config.SupportsVision || !vision.AcceptsTools() {
+		t.Errorf("Unexpected metadata %+v", vision.Config)
+	}
+	if p := vision.Config.Pricing; p == nil || p.Input < 2.999 || p.Input > 3.001 || p.Output < 14.999 || p.CachedInput < 0.2999 {
+		t.Errorf("Unexpected pricing %+v", vision.Config.Pricing)
+	}
+	if listed[2].AcceptsTools() {
+		t.Error("Expected a model without the tools parameter to not accept tools")
+	}
+
+	ollama, err := listOllamaModels(context.Background(), provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ollama) != 2 || ollama[1].ID != "qwen2.5-coder:7b" {
+		t.Errorf("Unexpected Ollama models %+v", ollama)
+	}
+
+	provider.APIKey = "wrong"
+	if _, err := ListModels(context.Background(), provider); err == nil {
+		t.Error("Expected an error for a rejected key")
+	}
+	if !isOllama("http://localhost:11434/v1") || isOllama("https://openrouter.ai/api/v1") {
+		t.Error("Unexpected Ollama detection")
+	}
+}
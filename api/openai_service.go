@@ -1,16 +1,19 @@
 package api
 
 import (
+	"agent/logging"
 	"agent/models"
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 )
 
-// Streaming request to the OpenAI-compatible API
+// Streaming request to the OpenAI-compatible API. When ctx is cancelled mid-stream, the content
+// received so far is returned with the error; unfinished tool calls are dropped.
 func Invoke(
 	ctx context.Context,
 	model *models.Model,
@@ -19,19 +22,51 @@ func Invoke(
 	availableTools map[string]models.ToolDefinition,
 	onReceiveContent func(string),
 ) (string, []models.ToolCall, error) {
+	systemPrompt, messages, err := filterOutbound(systemPrompt, messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKey, err := ResolveAPIKey(model.Provider.APIKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s API key: %w", model.Provider.Name, err)
+	}
 	client := openai.NewClient(
-		option.WithAPIKey(model.Provider.APIKey),
+		option.WithAPIKey(apiKey),
 		option.WithBaseURL(model.Provider.BaseURL),
+		trackQuota(model.Provider.ID),
 	)
 
 	// Create request parameters
+	mode := cacheMode(model)
 	request := openai.ChatCompletionNewParams{
 		Model:       model.ID,
-		Messages:    convertMessages(messages, systemPrompt),
+		Messages:    convertMessages(messages, systemPrompt, mode == CacheControl),
 		MaxTokens:   openai.Int(int64(model.Config.MaxTokens)),
 		Temperature: openai.Float(model.Config.Temperature),
 		TopP:        openai.Float(model.Config.TopP),
-		Tools:       convertTools(availableTools),
+	}
+	if model.AcceptsTools() {
+		request.Tools = convertTools(availableTools)
+	}
+	if model.Config.Seed != nil {
+		request.Seed = openai.Int(*model.Config.Seed)
+	}
+	if len(model.Config.Stop) > 0 {
+		request.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: model.Config.Stop}
+	}
+	if model.Config.FrequencyPenalty != nil {
+		request.FrequencyPenalty = openai.Float(*model.Config.FrequencyPenalty)
+	}
+	if model.Config.PresencePenalty != nil {
+		request.PresencePenalty = openai.Float(*model.Config.PresencePenalty)
+	}
+	if mode != CacheOff {
+		// The final chunk then reports usage, including how much of the prompt was cached
+		request.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	}
+	if mode == CacheKey {
+		request.SetExtraFields(map[string]any{"prompt_cache_key": promptCacheKey(systemPrompt)})
 	}
 
 	// Create streaming request
@@ -47,8 +82,14 @@ func Invoke(
 	for chatStream.Next() {
 		chunk := chatStream.Current()
 
+		logging.Chunk(model.Provider.ID+"/"+model.ID, chunk.RawJSON())
+
 		// Add chunk to accumulator
 		acc.AddChunk(chunk)
+		if chunk.Usage.PromptTokens > 0 {
+			recordCacheUsage(chunk.Usage)
+			recordUsage(model, chunk.Usage)
+		}
 
 		// Handle content tokens
 		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
@@ -75,7 +116,7 @@ func Invoke(
 
 	if err := chatStream.Err(); err != nil {
 		if errors.Is(err, context.Canceled) {
-			return "", nil, fmt.Errorf("request cancelled: %w", err)
+			return content, nil, fmt.Errorf("request cancelled: %w", err)
 		}
 		return "", nil, fmt.Errorf("%s stream error: %w", model.Provider.Name, err)
 	}
@@ -85,16 +126,22 @@ func Invoke(
 
 // Helper methods
 
-func convertMessages(messages []models.Message, systemPrompt string) []openai.ChatCompletionMessageParamUnion {
+func convertMessages(messages []models.Message, systemPrompt string, cacheBreakpoints bool) []openai.ChatCompletionMessageParamUnion {
 	var openaiMessages []openai.ChatCompletionMessageParamUnion
 
-	openaiMessages = append(openaiMessages, openai.SystemMessage(systemPrompt))
+	openaiMessages = append(openaiMessages, systemMessage(systemPrompt, cacheBreakpoints))
 
 	// Convert messages
-	for _, msg := range messages {
+	var toolImages []string
+	lastHistory := -1
+	for i, msg := range messages {
 		switch msg.Role {
 		case "user":
-			openaiMessages = append(openaiMessages, openai.UserMessage(msg.Content))
+			if len(msg.Images) > 0 {
+				openaiMessages = append(openaiMessages, imageMessage(msg.Content, msg.Images))
+			} else {
+				openaiMessages = append(openaiMessages, openai.UserMessage(msg.Content))
+			}
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
 				// Assistant message with tool calls
@@ -127,18 +174,55 @@ func convertMessages(messages []models.Message, systemPrompt string) []openai.Ch
 			}
 		case "tool":
 			openaiMessages = append(openaiMessages, openai.ToolMessage(msg.Content, msg.ToolCallID))
+			// Tool messages can only hold text, so images tools returned follow the last result of
+			// the batch in a user message
+			toolImages = append(toolImages, msg.Images...)
+			if len(toolImages) > 0 && (i+1 == len(messages) || messages[i+1].Role != "tool") {
+				openaiMessages = append(openaiMessages, imageMessage("Images returned by the tool calls above:", toolImages))
+				toolImages = nil
+			}
 		case "system":
 			openaiMessages = append(openaiMessages, openai.SystemMessage(msg.Content))
 		}
+		if msg.ID != models.ContextMessageID {
+			lastHistory = len(openaiMessages) - 1
+		}
+	}
+
+	// The history only grows between requests, so caching up to its last message lets the next
+	// request read all of it from the cache
+	if cacheBreakpoints {
+		for i := lastHistory; i > 0; i-- {
+			if markCached(&openaiMessages[i]) {
+				break
+			}
+		}
 	}
 
 	return openaiMessages
 }
 
+// imageMessage builds a user message with text followed by images, given as URLs or data: URLs
+func imageMessage(text string, images []string) openai.ChatCompletionMessageParamUnion {
+	parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(text)}
+	for _, image := range images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: image}))
+	}
+	return openai.UserMessage(parts)
+}
+
 func convertTools(availableTools map[string]models.ToolDefinition) []openai.ChatCompletionToolParam {
 	var openaiTools []openai.ChatCompletionToolParam
 
-	for _, tool := range availableTools {
+	// Tools come first in the prompt, so a stable order keeps it cacheable
+	names := make([]string, 0, len(availableTools))
+	for name := range availableTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tool := availableTools[name]
 		schema := tool.Schema
 
 		openaiTool := openai.ChatCompletionToolParam{
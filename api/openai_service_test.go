@@ -1,6 +1,7 @@
 package api
 
 import (
+	"agent/models"
 	"encoding/json"
 	"testing"
 
@@ -79,3 +80,67 @@ func TestToolCallMerging(t *testing.T) {
 		t.Errorf("Merged arguments should be valid JSON: %v", err)
 	}
 }
+
+func TestConvertMessagesWithImages(t *testing.T) {
+	messages := []models.Message{{Role: "user", Content: "what is this?", Images: []string{"data:image/png;base64,iVBORw0KGgo="}}}
+
+	data, err := json.Marshal(convertMessages(messages, "system", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []json.RawMessage
+	if err := json.Unmarshal(data, &sent); err != nil || len(sent) != 2 {
+		t.Fatalf("Expected system and user messages, got %s", data)
+	}
+
+	var user struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(sent[1], &user); err != nil {
+		t.Fatalf("Expected content parts, got %s: %v", sent[1], err)
+	}
+	if len(user.Content) != 2 || user.Content[0].Text != "what is this?" || user.Content[1].ImageURL.URL != messages[0].Images[0] {
+		t.Errorf("Unexpected content parts: %s", sent[1])
+	}
+}
+
+func TestConvertMessagesWithToolImages(t *testing.T) {
+	messages := []models.Message{
+		{Role: "assistant", ToolCalls: []models.ToolCall{{ID: "a", Function: models.FunctionCall{Name: "view_image"}}, {ID: "b", Function: models.FunctionCall{Name: "shell"}}}},
+		{Role: "tool", ToolCallID: "a", Content: "viewing", Images: []string{"https://example.com/mock.png"}},
+		{Role: "tool", ToolCallID: "b", Content: "ok"},
+		{Role: "assistant", Content: "done"},
+	}
+
+	data, err := json.Marshal(convertMessages(messages, "system", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []struct {
+		Role string `json:"role"`
+	}
+	if err := json.Unmarshal(data, &sent); err != nil {
+		t.Fatal(err)
+	}
+
+	// The images follow the whole batch of tool results, which must stay together
+	var roles []string
+	for _, message := range sent {
+		roles = append(roles, message.Role)
+	}
+	expected := []string{"system", "assistant", "tool", "tool", "user", "assistant"}
+	if len(roles) != len(expected) {
+		t.Fatalf("Expected roles %v, got %v", expected, roles)
+	}
+	for i := range expected {
+		if roles[i] != expected[i] {
+			t.Fatalf("Expected roles %v, got %v", expected, roles)
+		}
+	}
+}
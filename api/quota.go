@@ -0,0 +1,162 @@
+package api
+
+import (
+	"agent/models"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Quota is a provider's rate-limit headroom as reported by the headers of its latest response,
+// less the tokens of requests sent since
+type Quota struct {
+	RemainingRequests int // -1 when the provider doesn't report it
+	RemainingTokens   int // -1 when the provider doesn't report it
+	Reset             time.Time
+}
+
+// fits reports whether a request of tokens can be sent now without hitting the limit
+func (q Quota) fits(tokens int, now time.Time) bool {
+	if !q.Reset.IsZero() && now.After(q.Reset) {
+		return true // the window has refilled since the headers were read
+	}
+	if q.RemainingRequests == 0 {
+		return false
+	}
+	return q.RemainingTokens < 0 || q.RemainingTokens >= tokens
+}
+
+var quotas = struct {
+	sync.Mutex
+	byProvider map[string]Quota
+}{byProvider: make(map[string]Quota)}
+
+// ProviderQuota returns the last known quota of a provider; ok is false until it has answered a request
+func ProviderQuota(provider string) (Quota, bool) {
+	quotas.Lock()
+	defer quotas.Unlock()
+	quota, ok := quotas.byProvider[provider]
+	return quota, ok
+}
+
+// ReserveQuota counts a request against the provider's known headroom until the next response
+// reports the real figures, so concurrent requests don't all pick the same provider
+func ReserveQuota(provider string, tokens int) {
+	quotas.Lock()
+	defer quotas.Unlock()
+	quota, ok := quotas.byProvider[provider]
+	if !ok {
+		return
+	}
+	if quota.RemainingTokens > 0 {
+		quota.RemainingTokens = max(quota.RemainingTokens-tokens, 0)
+	}
+	if quota.RemainingRequests > 0 {
+		quota.RemainingRequests--
+	}
+	quotas.byProvider[provider] = quota
+}
+
+// recordQuota reads rate-limit headers from a provider's response. OpenAI-style headers
+// (x-ratelimit-remaining-tokens, x-ratelimit-reset-tokens as a duration) and the generic
+// x-ratelimit-remaining / x-ratelimit-reset (epoch milliseconds) used by OpenRouter are understood.
+func recordQuota(provider string, response *http.Response) {
+	header := response.Header
+	now := time.Now()
+	quota := Quota{
+		RemainingRequests: headerInt(header, "x-ratelimit-remaining-requests", "x-ratelimit-remaining"),
+		RemainingTokens:   headerInt(header, "x-ratelimit-remaining-tokens"),
+	}
+	if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-tokens")); err == nil {
+		quota.Reset = now.Add(reset)
+	} else if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-requests")); err == nil {
+		quota.Reset = now.Add(reset)
+	} else if millis, err := strconv.ParseInt(header.Get("x-ratelimit-reset"), 10, 64); err == nil {
+		quota.Reset = time.UnixMilli(millis)
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		quota.RemainingRequests = 0
+		if seconds, err := strconv.Atoi(header.Get("retry-after")); err == nil {
+			quota.Reset = now.Add(time.Duration(seconds) * time.Second)
+		} else if quota.Reset.IsZero() {
+			quota.Reset = now.Add(time.Minute)
+		}
+	} else if quota.RemainingRequests < 0 && quota.RemainingTokens < 0 {
+		return // nothing reported
+	}
+
+	quotas.Lock()
+	quotas.byProvider[provider] = quota
+	quotas.Unlock()
+}
+
+// trackQuota records the rate-limit headers of every response from provider
+func trackQuota(provider string) option.RequestOption {
+	return option.WithMiddleware(func(request *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		response, err := next(request)
+		if response != nil {
+			recordQuota(provider, response)
+		}
+		return response, err
+	})
+}
+
+func headerInt(header http.Header, names ...string) int {
+	for _, name := range names {
+		if value, err := strconv.Atoi(header.Get(name)); err == nil {
+			return value
+		}
+	}
+	return -1
+}
+
+// Route picks which of several models serving the same family should take a request of about
+// tokens. The preferred model is kept while its provider has headroom for the request; otherwise
+// the candidate with the most headroom is used. Providers that haven't answered yet are assumed to
+// have room. When nothing fits, the provider whose window resets first is picked.
+func Route(preferred *models.Model, candidates []*models.Model, tokens int) *models.Model {
+	now := time.Now()
+	quotas.Lock()
+	defer quotas.Unlock()
+
+	headroom := func(model *models.Model) (int, bool) {
+		quota, ok := quotas.byProvider[model.Provider.ID]
+		if !ok {
+			return math.MaxInt, true
+		}
+		fits := quota.fits(tokens, now)
+		if quota.RemainingTokens < 0 || (!quota.Reset.IsZero() && now.After(quota.Reset)) {
+			return math.MaxInt, fits
+		}
+		return quota.RemainingTokens, fits
+	}
+
+	if _, fits := headroom(preferred); fits {
+		return preferred
+	}
+
+	var best *models.Model
+	bestRoom := -1
+	for _, candidate := range candidates {
+		if room, fits := headroom(candidate); fits && room > bestRoom {
+			best, bestRoom = candidate, room
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	// Everything is exhausted; wait on whichever provider refills first
+	best = preferred
+	for _, candidate := range candidates {
+		if quotas.byProvider[candidate.Provider.ID].Reset.Before(quotas.byProvider[best.Provider.ID].Reset) {
+			best = candidate
+		}
+	}
+	return best
+}
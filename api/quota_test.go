@@ -0,0 +1,74 @@
+package api
+
+import (
+	"agent/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func quotaResponse(status int, headers map[string]string) *http.Response {
+	response := &http.Response{StatusCode: status, Header: http.Header{}}
+	for name, value := range headers {
+		response.Header.Set(name, value)
+	}
+	return response
+}
+
+func TestRecordQuota(t *testing.T) {
+	quotas.byProvider = make(map[string]Quota)
+
+	recordQuota("openai", quotaResponse(200, map[string]string{
+		"x-ratelimit-remaining-requests": "49",
+		"x-ratelimit-remaining-tokens":   "12000",
+		"x-ratelimit-reset-tokens":       "6s",
+	}))
+	quota, ok := ProviderQuota("openai")
+	if !ok || quota.RemainingRequests != 49 || quota.RemainingTokens != 12000 {
+		t.Errorf("Unexpected quota %+v", quota)
+	}
+	if until := time.Until(quota.Reset); until <= 0 || until > 6*time.Second {
+		t.Errorf("Expected reset in about 6s, got %v", until)
+	}
+
+	ReserveQuota("openai", 5000)
+	if quota, _ := ProviderQuota("openai"); quota.RemainingRequests != 48 || quota.RemainingTokens != 7000 {
+		t.Errorf("Expected reserved tokens to be deducted, got %+v", quota)
+	}
+
+	recordQuota("openrouter", quotaResponse(429, map[string]string{"retry-after": "30"}))
+	if quota, _ := ProviderQuota("openrouter"); quota.fits(1, time.Now()) {
+		t.Errorf("Expected a rate-limited provider to have no room, got %+v", quota)
+	}
+
+	recordQuota("local", quotaResponse(200, nil))
+	if _, ok := ProviderQuota("local"); ok {
+		t.Error("Expected responses without rate-limit headers to be ignored")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	quotas.byProvider = make(map[string]Quota)
+	model := func(provider string) *models.Model {
+		return &models.Model{ID: "gpt-4o", Provider: &models.Provider{ID: provider}}
+	}
+	primary, secondary, fresh := model("primary"), model("secondary"), model("fresh")
+	reset := time.Now().Add(time.Minute)
+	quotas.byProvider["primary"] = Quota{RemainingRequests: 10, RemainingTokens: 5000, Reset: reset}
+	quotas.byProvider["secondary"] = Quota{RemainingRequests: 10, RemainingTokens: 20000, Reset: reset}
+
+	if got := Route(primary, []*models.Model{primary, secondary}, 1000); got != primary {
+		t.Errorf("Expected small requests to stay on the preferred provider, got %s", got.Provider.ID)
+	}
+	if got := Route(primary, []*models.Model{primary, secondary}, 10000); got != secondary {
+		t.Errorf("Expected a large request to move to the provider with room, got %s", got.Provider.ID)
+	}
+	if got := Route(primary, []*models.Model{primary, secondary, fresh}, 10000); got != fresh {
+		t.Errorf("Expected an untried provider to be assumed to have room, got %s", got.Provider.ID)
+	}
+
+	quotas.byProvider["secondary"] = Quota{RemainingRequests: 0, RemainingTokens: -1, Reset: time.Now().Add(time.Second)}
+	if got := Route(primary, []*models.Model{primary, secondary}, 10000); got != secondary {
+		t.Errorf("Expected the provider that refills first when all are exhausted, got %s", got.Provider.ID)
+	}
+}
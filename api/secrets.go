@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefixes of api_key settings that name where the key is kept instead of holding it
+const (
+	EnvKeyPrefix      = "env:"      // env:OPENAI_API_KEY
+	KeychainKeyPrefix = "keychain:" // keychain:openai, from the macOS Keychain or libsecret
+	CommandKeyPrefix  = "cmd:"      // cmd:pass show openai, the command's output
+)
+
+// secretCommandTimeout bounds keychain lookups and cmd: commands, which may wait for an unlock prompt
+const secretCommandTimeout = time.Minute
+
+var (
+	secretsMu sync.Mutex
+	secrets   = make(map[string]string) // resolved keychain: and cmd: settings, so each runs once per session
+)
+
+// IsKeyReference reports whether an api_key setting refers to a key kept elsewhere
+func IsKeyReference(setting string) bool {
+	return strings.HasPrefix(setting, EnvKeyPrefix) || strings.HasPrefix(setting, KeychainKeyPrefix) || strings.HasPrefix(setting, CommandKeyPrefix)
+}
+
+// HasAPIKey reports whether an api_key setting can provide a key without resolving it: environment
+// variables must be set, while keychain and command references are assumed to work until used
+func HasAPIKey(setting string) bool {
+	if name, ok := strings.CutPrefix(setting, EnvKeyPrefix); ok {
+		return os.Getenv(name) != ""
+	}
+	return setting != ""
+}
+
+// ResolveAPIKey returns the key an api_key setting refers to. Keys are resolved when a request is
+// about to be sent, so a keychain prompt or command only runs for providers that are used, and
+// keychain and command results are kept for the rest of the session. Other settings are the key
+// itself. A missing environment variable resolves to no key.
+func ResolveAPIKey(setting string) (string, error) {
+	if name, ok := strings.CutPrefix(setting, EnvKeyPrefix); ok {
+		return os.Getenv(name), nil
+	}
+	if !IsKeyReference(setting) {
+		return setting, nil
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if key, ok := secrets[setting]; ok {
+		return key, nil
+	}
+
+	var key string
+	var err error
+	if name, ok := strings.CutPrefix(setting, KeychainKeyPrefix); ok {
+		key, err = readKeychain(name)
+	} else {
+		key, err = runSecretCommand(strings.TrimPrefix(setting, CommandKeyPrefix))
+	}
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("%s returned an empty key", setting)
+	}
+	secrets[setting] = key
+	return key, nil
+}
+
+// ResolvedAPIKey returns the key an api_key setting refers to if it's known without running
+// anything: environment variables are read, and keychain and command references are known once
+// ResolveAPIKey has resolved them this session
+func ResolvedAPIKey(setting string) (string, bool) {
+	if name, ok := strings.CutPrefix(setting, EnvKeyPrefix); ok {
+		key := os.Getenv(name)
+		return key, key != ""
+	}
+	if !IsKeyReference(setting) {
+		return setting, setting != ""
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	key, ok := secrets[setting]
+	return key, ok
+}
+
+// readKeychain looks up a generic password by service name in the macOS Keychain, or by the
+// "service" attribute with libsecret's secret-tool elsewhere
+func readKeychain(name string) (string, error) {
+	var command []string
+	switch runtime.GOOS {
+	case "darwin":
+		command = []string{"security", "find-generic-password", "-s", name, "-w"}
+	case "linux", "freebsd", "openbsd":
+		command = []string{"secret-tool", "lookup", "service", name}
+	default:
+		return "", fmt.Errorf("keychain: keys aren't supported on %s; use env: or cmd:", runtime.GOOS)
+	}
+	key, err := runSecret(command)
+	if err != nil {
+		return "", fmt.Errorf("reading %q from the keychain: %w", name, err)
+	}
+	return key, nil
+}
+
+// runSecretCommand runs a cmd: setting through the shell and returns its output
+func runSecretCommand(command string) (string, error) {
+	shell := []string{"sh", "-c", command}
+	if runtime.GOOS == "windows" {
+		shell = []string{"cmd", "/C", command}
+	}
+	key, err := runSecret(shell)
+	if err != nil {
+		return "", fmt.Errorf("running api_key command %q: %w", command, err)
+	}
+	return key, nil
+}
+
+func runSecret(command []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
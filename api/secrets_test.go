@@ -0,0 +1,80 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	t.Setenv("AGENT_TEST_KEY", "from-env")
+	if key, err := ResolveAPIKey("env:AGENT_TEST_KEY"); err != nil || key != "from-env" {
+		t.Errorf("env: resolved to %q, %v", key, err)
+	}
+	if key, err := ResolveAPIKey("sk-plain"); err != nil || key != "sk-plain" {
+		t.Errorf("Plain key resolved to %q, %v", key, err)
+	}
+	if key, err := ResolveAPIKey("env:AGENT_TEST_UNSET"); err != nil || key != "" {
+		t.Errorf("Unset variable resolved to %q, %v", key, err)
+	}
+
+	// Commands run once, then the key is reused
+	runs := filepath.Join(t.TempDir(), "runs")
+	setting := "cmd:echo run >> " + runs + "; echo ' from-cmd '"
+	for i := 0; i < 2; i++ {
+		if key, err := ResolveAPIKey(setting); err != nil || key != "from-cmd" {
+			t.Fatalf("cmd: resolved to %q, %v", key, err)
+		}
+	}
+	if data, _ := os.ReadFile(runs); strings.Count(string(data), "run") != 1 {
+		t.Errorf("Expected the command to run once, got %q", data)
+	}
+
+	if _, err := ResolveAPIKey("cmd:echo oops >&2; exit 3"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("Expected the command's error output, got %v", err)
+	}
+	if _, err := ResolveAPIKey("cmd:true"); err == nil {
+		t.Error("Expected an error for an empty key")
+	}
+}
+
+func TestHasAPIKey(t *testing.T) {
+	t.Setenv("AGENT_TEST_KEY", "set")
+	for setting, expected := range map[string]bool{
+		"":                     false,
+		"env:AGENT_TEST_KEY":   true,
+		"env:AGENT_TEST_UNSET": false,
+		"keychain:openai":      true,
+		"cmd:pass show openai": true,
+		"sk-plain":             true,
+	} {
+		if HasAPIKey(setting) != expected {
+			t.Errorf("HasAPIKey(%q) = %v, expected %v", setting, !expected, expected)
+		}
+	}
+}
+
+func TestResolvedAPIKey(t *testing.T) {
+	t.Setenv("AGENT_TEST_KEY", "from-env")
+	setting := "cmd:echo from-resolved-cmd"
+	if _, ok := ResolvedAPIKey(setting); ok {
+		t.Error("Expected a command that hasn't run to be unknown")
+	}
+	if _, err := ResolveAPIKey(setting); err != nil {
+		t.Fatal(err)
+	}
+
+	for setting, expected := range map[string]string{
+		"env:AGENT_TEST_KEY":   "from-env",
+		"env:AGENT_TEST_UNSET": "",
+		"keychain:unresolved":  "",
+		setting:                "from-resolved-cmd",
+		"sk-plain":             "sk-plain",
+		"":                     "",
+	} {
+		if key, ok := ResolvedAPIKey(setting); key != expected || ok != (expected != "") {
+			t.Errorf("ResolvedAPIKey(%q) = %q, %v, expected %q", setting, key, ok, expected)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"agent/models"
+	"sort"
+	"sync"
+
+	"github.com/openai/openai-go"
+)
+
+// ModelUsage counts the tokens one model's providers reported for the requests sent to it
+type ModelUsage struct {
+	Model            string // provider:model
+	Requests         int
+	PromptTokens     int
+	CachedTokens     int
+	CompletionTokens int
+	Pricing          *models.Pricing // nil when the model's config has no pricing
+}
+
+// Cost returns what the usage cost in dollars, and false when the model has no pricing
+func (u ModelUsage) Cost() (float64, bool) {
+	if u.Pricing == nil {
+		return 0, false
+	}
+	return u.Pricing.Cost(u.PromptTokens, u.CachedTokens, u.CompletionTokens), true
+}
+
+var usage struct {
+	sync.Mutex
+	byModel map[string]*ModelUsage
+}
+
+// Usage returns the token usage of every model sent requests so far, sorted by model. Models with
+// prompt_cache set to "off" don't request usage and aren't counted.
+func Usage() []ModelUsage {
+	usage.Lock()
+	defer usage.Unlock()
+	result := make([]ModelUsage, 0, len(usage.byModel))
+	for _, modelUsage := range usage.byModel {
+		result = append(result, *modelUsage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Model < result[j].Model })
+	return result
+}
+
+func recordUsage(model *models.Model, reported openai.CompletionUsage) {
+	usage.Lock()
+	defer usage.Unlock()
+	if usage.byModel == nil {
+		usage.byModel = make(map[string]*ModelUsage)
+	}
+	name := model.Provider.ID + ":" + model.ID
+	modelUsage, ok := usage.byModel[name]
+	if !ok {
+		modelUsage = &ModelUsage{Model: name}
+		usage.byModel[name] = modelUsage
+	}
+	modelUsage.Pricing = model.Config.Pricing
+	modelUsage.Requests++
+	modelUsage.PromptTokens += int(reported.PromptTokens)
+	modelUsage.CachedTokens += int(reported.PromptTokensDetails.CachedTokens)
+	modelUsage.CompletionTokens += int(reported.CompletionTokens)
+}
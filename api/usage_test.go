@@ -0,0 +1,39 @@
+package api
+
+import (
+	"agent/models"
+	"math"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestRecordUsage(t *testing.T) {
+	usage.byModel = nil
+	provider := &models.Provider{ID: "openai"}
+	priced := &models.Model{ID: "gpt-4o", Provider: provider, Config: models.ModelConfig{
+		Pricing: &models.Pricing{Input: 2.5, CachedInput: 1.25, Output: 10},
+	}}
+	unpriced := &models.Model{ID: "local", Provider: provider}
+
+	reported := openai.CompletionUsage{PromptTokens: 1000000, CompletionTokens: 100000}
+	reported.PromptTokensDetails.CachedTokens = 400000
+	recordUsage(priced, reported)
+	recordUsage(priced, openai.CompletionUsage{PromptTokens: 0, CompletionTokens: 0})
+	recordUsage(unpriced, openai.CompletionUsage{PromptTokens: 10, CompletionTokens: 5})
+
+	result := Usage()
+	if len(result) != 2 || result[0].Model != "openai:gpt-4o" || result[1].Model != "openai:local" {
+		t.Fatalf("Unexpected usage %+v", result)
+	}
+	if result[0].Requests != 2 || result[0].PromptTokens != 1000000 || result[0].CachedTokens != 400000 || result[0].CompletionTokens != 100000 {
+		t.Errorf("Unexpected totals %+v", result[0])
+	}
+	// 600k uncached at $2.50, 400k cached at $1.25, and 100k completion at $10 per million
+	if cost, ok := result[0].Cost(); !ok || math.Abs(cost-3.0) > 1e-9 {
+		t.Errorf("Expected $3.00, got %v (%v)", cost, ok)
+	}
+	if _, ok := result[1].Cost(); ok {
+		t.Error("Expected no cost for a model without pricing")
+	}
+}
@@ -0,0 +1,125 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const indexFileName = "index.json"
+
+// Artifact is a generated output saved outside the source tree
+type Artifact struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"` // report, script, diagram, data, or other
+	Description string    `json:"description,omitempty"`
+	Path        string    `json:"path"`
+	Size        int       `json:"size"`
+	Created     time.Time `json:"created"`
+}
+
+// Store saves artifacts in a directory alongside an index.json describing them
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	artifacts []Artifact
+}
+
+// NewStore opens the artifact store at dir, loading its index if one exists. The directory is
+// created on the first save.
+func NewStore(dir string) *Store {
+	store := &Store{dir: dir}
+	if data, err := os.ReadFile(filepath.Join(dir, indexFileName)); err == nil {
+		_ = json.Unmarshal(data, &store.artifacts)
+	}
+	return store
+}
+
+// Dir returns the directory artifacts are written to
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes content under a unique file name derived from name and records it in the index
+func (s *Store) Save(name, kind, description string, content []byte) (Artifact, error) {
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." || name == string(filepath.Separator) || name == indexFileName {
+		return Artifact{}, fmt.Errorf("invalid artifact name %q", name)
+	}
+	if kind == "" {
+		kind = "other"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return Artifact{}, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	// Never overwrite an earlier artifact: report.md becomes report-2.md
+	fileName := name
+	ext := filepath.Ext(name)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(s.dir, fileName)); os.IsNotExist(err) {
+			break
+		}
+		fileName = strings.TrimSuffix(name, ext) + "-" + strconv.Itoa(n) + ext
+	}
+
+	mode := os.FileMode(0644)
+	if kind == "script" {
+		mode = 0755
+	}
+	path := filepath.Join(s.dir, fileName)
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return Artifact{}, fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	artifact := Artifact{
+		ID:          len(s.artifacts) + 1,
+		Name:        fileName,
+		Kind:        kind,
+		Description: description,
+		Path:        path,
+		Size:        len(content),
+		Created:     time.Now(),
+	}
+	s.artifacts = append(s.artifacts, artifact)
+
+	data, err := json.MarshalIndent(s.artifacts, "", "  ")
+	if err != nil {
+		return Artifact{}, err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, indexFileName), data, 0644); err != nil {
+		return Artifact{}, fmt.Errorf("failed to update artifact index: %w", err)
+	}
+	return artifact, nil
+}
+
+// List returns every saved artifact in the order they were created
+func (s *Store) List() []Artifact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Artifact, len(s.artifacts))
+	copy(list, s.artifacts)
+	return list
+}
+
+// Get returns the artifact with the given ID
+func (s *Store) Get(id int) (Artifact, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, artifact := range s.artifacts {
+		if artifact.ID == id {
+			return artifact, true
+		}
+	}
+	return Artifact{}, false
+}
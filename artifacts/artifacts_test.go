@@ -0,0 +1,52 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "artifacts", "session")
+	store := NewStore(dir)
+
+	first, err := store.Save("report.md", "report", "weekly summary", []byte("# Report"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first.ID)
+	assert.Equal(t, filepath.Join(dir, "report.md"), first.Path)
+
+	// Names are never reused and paths can't escape the directory
+	second, err := store.Save("../report.md", "", "", []byte("# Again"))
+	assert.NoError(t, err)
+	assert.Equal(t, "report-2.md", second.Name)
+	assert.Equal(t, "other", second.Kind)
+
+	script, err := store.Save("fix.sh", "script", "", []byte("#!/bin/sh\n"))
+	assert.NoError(t, err)
+	info, err := os.Stat(script.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	_, err = store.Save("index.json", "", "", nil)
+	assert.Error(t, err)
+
+	// The index is reloaded by a new store
+	reopened := NewStore(dir)
+	assert.Len(t, reopened.List(), 3)
+	assert.Equal(t, "weekly summary", reopened.List()[0].Description)
+}
+
+func TestStoreGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+	saved, err := store.Save("out.txt", "data", "", []byte("output"))
+	assert.NoError(t, err)
+
+	artifact, ok := store.Get(saved.ID)
+	assert.True(t, ok)
+	assert.Equal(t, saved.Path, artifact.Path)
+
+	_, ok = store.Get(99)
+	assert.False(t, ok)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"agent/theme"
+	"agent/tools"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AttachmentsConfig controls how @path references in messages are attached
+type AttachmentsConfig struct {
+	InlineMaxBytes int `json:"inline_max_bytes"` // files up to this size are inlined into the message (default 4000); larger ones go to live context. -1 never inlines.
+}
+
+const defaultInlineMaxBytes = 4000
+
+var attachmentPattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// expandAttachments resolves @path references in the user's input. Small files are inlined into the
+// message, larger files and directories are added to live context, and images are returned as data
+// URLs when vision is true. References to paths that don't exist are left alone, so e.g. @mentions
+// pass through unchanged.
+func (a *Agent) expandAttachments(input string, vision bool) (string, []string) {
+	inlineMax := a.config.Attachments.InlineMaxBytes
+	if inlineMax == 0 {
+		inlineMax = defaultInlineMaxBytes
+	}
+
+	var inlined []string
+	var images []string
+	seen := make(map[string]bool)
+	for _, match := range attachmentPattern.FindAllStringSubmatch(input, -1) {
+		path, info, ok := attachmentPath(match[2])
+		if !ok || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		switch {
+		case info.IsDir():
+			if err := a.LiveContext.AddDirectory(path, true); err != nil {
+				fmt.Println(theme.ErrorText(fmt.Sprintf("Failed to attach %s: %v", path, err)))
+				continue
+			}
+			fmt.Println(theme.InfoText("📎 added directory " + path + " to live context"))
+
+		case tools.IsImagePath(path):
+			if !vision {
+				fmt.Println(theme.WarningText("📎 not attaching " + path + ": the current model doesn't accept images"))
+				continue
+			}
+			image, err := tools.ImageDataURL(path)
+			if err != nil {
+				fmt.Println(theme.ErrorText(fmt.Sprintf("Failed to attach %s: %v", path, err)))
+				continue
+			}
+			images = append(images, image)
+			fmt.Println(theme.InfoText("📎 attached image " + path))
+
+		case inlineMax > 0 && info.Size() <= int64(inlineMax):
+			content, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Println(theme.ErrorText(fmt.Sprintf("Failed to attach %s: %v", path, err)))
+				continue
+			}
+			inlined = append(inlined, fmt.Sprintf("--- FILE: %s ---\n%s", path, strings.TrimRight(string(content), "\n")))
+			fmt.Println(theme.InfoText("📎 inlined " + path))
+
+		default:
+			if err := a.LiveContext.AddFile(path, 1, nil); err != nil {
+				fmt.Println(theme.ErrorText(fmt.Sprintf("Failed to attach %s: %v", path, err)))
+				continue
+			}
+			fmt.Println(theme.InfoText("📎 added " + path + " to live context"))
+		}
+	}
+
+	if len(inlined) > 0 {
+		input += "\n\n" + strings.Join(inlined, "\n\n")
+	}
+	return input, images
+}
+
+// attachmentPath finds the file a reference names, dropping punctuation that ends a sentence
+func attachmentPath(reference string) (string, os.FileInfo, bool) {
+	for _, path := range []string{reference, strings.TrimRight(reference, `.,;:!?)"'`)} {
+		if info, err := os.Stat(path); err == nil {
+			return filepath.Clean(path), info, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandAttachments(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.go")
+	large := filepath.Join(dir, "large.go")
+	image := filepath.Join(dir, "shot.png")
+	assert.NoError(t, os.WriteFile(small, []byte("package small\n"), 0644))
+	assert.NoError(t, os.WriteFile(large, []byte(strings.Repeat("// filler\n", 1000)), 0644))
+	assert.NoError(t, os.WriteFile(image, []byte("\x89PNG"), 0644))
+
+	agent := &Agent{LiveContext: NewLiveContext(), config: &Config{}}
+	input := "look at @" + small + ", @" + large + " and @" + image + " for @someone"
+	message, images := agent.expandAttachments(input, true)
+
+	assert.True(t, strings.HasPrefix(message, input+"\n\n"))
+	assert.Contains(t, message, "--- FILE: "+small+" ---\npackage small")
+	assert.NotContains(t, message, "filler")
+	assert.Contains(t, agent.LiveContext.ListFiles(), large)
+	assert.Equal(t, []string{"data:image/png;base64,iVBORw=="}, images)
+
+	// Models without vision don't get images
+	_, images = agent.expandAttachments("@"+image, false)
+	assert.Empty(t, images)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"agent/logging"
+	"agent/miniagents"
+	"agent/theme"
+	"agent/tools"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MiniagentConfig controls background miniagents and the limits they share with the main loop
+type MiniagentConfig struct {
+	TokenBudget       int     `json:"token_budget"`        // estimated tokens miniagents may use per session (0 = unlimited)
+	CostBudget        float64 `json:"cost_budget"`         // estimated dollars miniagents may spend per session, from the models' pricing (0 = unlimited)
+	RequestsPerMinute int     `json:"requests_per_minute"` // per provider, shared with the main loop (0 = unlimited)
+	Title             bool    `json:"title"`               // title the session after the first turn
+	Review            bool    `json:"review"`              // review each turn's file changes for bugs
+}
+
+// logsDir returns the directory holding per-miniagent logs
+func logsDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	return filepath.Join(homeDir, ".agent", "logs")
+}
+
+// Title returns the session's title, or "" before the titler has run
+func (a *Agent) Title() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.title
+}
+
+// startBackgroundMiniagents starts the titler and reviewer after a turn, as configured
+func (a *Agent) startBackgroundMiniagents(turn int, request string) {
+	cfg := a.config.Miniagents
+	if cfg.Title && a.Title() == "" {
+		response := a.latestResponse()
+		err := a.miniagents.Start("titler", a.currentModel, func(ctx context.Context, env *miniagents.Env) error {
+			title, err := miniagents.TitleSession(ctx, env, request, response)
+			if err != nil {
+				return err
+			}
+			a.mu.Lock()
+			a.title = title
+			a.mu.Unlock()
+			a.sessionLogger.LogRecord(struct {
+				Type      string    `json:"type"`
+				Title     string    `json:"title"`
+				Timestamp time.Time `json:"timestamp"`
+			}{"title", title, time.Now()})
+			return nil
+		}, nil)
+		if err != nil {
+			logging.Debugf("Titler not started: %v", err)
+		}
+	}
+
+	if cfg.Review {
+		diff := a.turnDiff(turn)
+		if diff == "" {
+			return
+		}
+		err := a.miniagents.Start("reviewer", a.currentModel, func(ctx context.Context, env *miniagents.Env) error {
+			findings, err := miniagents.ReviewChanges(ctx, env, diff)
+			if err != nil {
+				return err
+			}
+			if findings != "" {
+				fmt.Println(theme.WarningText(fmt.Sprintf("\n🔎 Review of turn %d:\n%s", turn, findings)))
+			}
+			return nil
+		}, func(err error) {
+			if err != nil && err != context.Canceled {
+				logging.Warnf("Review of turn %d failed: %v", turn, err)
+			}
+		})
+		if err != nil {
+			logging.Debugf("Reviewer not started: %v", err)
+		}
+	}
+}
+
+// summarizeOutput has a miniagent condense long shell output before it's returned to the model
+func (a *Agent) summarizeOutput(ctx context.Context, command, output string) (string, error) {
+	var summary string
+	err := a.miniagents.Run(ctx, "summarizer", a.currentModel, func(ctx context.Context, env *miniagents.Env) error {
+		var err error
+		summary, err = miniagents.SummarizeOutput(ctx, env, command, output)
+		return err
+	})
+	return summary, err
+}
+
+// turnDiff returns a plain diff of every file changed by tools during turn
+func (a *Agent) turnDiff(turn int) string {
+	return a.journalDiff(func(entry tools.JournalEntry) bool { return entry.Turn == turn })
+}
+
+// journalDiff returns a plain diff of every file changed by the journal entries match selects,
+// from before the first of them to now
+func (a *Agent) journalDiff(match func(tools.JournalEntry) bool) string {
+	originals := make(map[string]string)
+	var paths []string
+	for _, entry := range a.journal.Entries() {
+		if !match(entry) {
+			continue
+		}
+		if _, seen := originals[entry.Path]; seen {
+			continue // the first entry holds the content from before the changes
+		}
+		original := ""
+		if entry.Existed && entry.Backup != "" {
+			original = readFileIfExists(entry.Backup)
+		}
+		originals[entry.Path] = original
+		paths = append(paths, entry.Path)
+	}
+
+	var diffs []string
+	for _, path := range paths {
+		current := readFileIfExists(path)
+		if current != originals[path] {
+			diffs = append(diffs, tools.PlainDiff(path, originals[path], current))
+		}
+	}
+	return strings.Join(diffs, "\n")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionBranch is a conversation kept open in this process: the session the agent started with,
+// or one made with /fork. Only the branch in use is loaded into the agent; the others wait here.
+type sessionBranch struct {
+	Name     string
+	Logger   *SessionLogger
+	Messages []models.Message
+	Title    string
+	Parent   string // ID of the session it was forked from
+}
+
+// ForkRecord opens the log of a forked session, which then repeats the messages it was forked with
+// so the log stands on its own
+type ForkRecord struct {
+	Type     string    `json:"type"` // "fork"
+	Parent   string    `json:"parent"`
+	Name     string    `json:"name,omitempty"`
+	Messages int       `json:"messages"`
+	Time     time.Time `json:"time"`
+}
+
+// ensureBranches records the agent's own session as the first branch
+func (a *Agent) ensureBranches() {
+	if len(a.branches) == 0 {
+		a.branches = []*sessionBranch{{Name: "main", Logger: a.sessionLogger}}
+	}
+}
+
+// saveBranch stores the conversation in use in its branch. The caller holds a.mu.
+func (a *Agent) saveBranch() {
+	current := a.branches[a.branch]
+	current.Messages = a.Messages
+	current.Title = a.title
+	current.Logger = a.sessionLogger
+}
+
+// loadBranch makes branch the conversation in use. The caller holds a.mu.
+func (a *Agent) loadBranch(index int) {
+	branch := a.branches[index]
+	a.branch = index
+	a.Messages = branch.Messages
+	a.title = branch.Title
+	a.sessionLogger = branch.Logger
+}
+
+// fork copies the conversation into a new session with its own log and switches to it. Files on
+// disk, checkpoints, and tasks are shared by every branch.
+func (a *Agent) fork(name string) (*sessionBranch, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ensureBranches()
+
+	id := fmt.Sprintf("%s-%d", a.branches[0].Logger.ID, len(a.branches))
+	logger, err := openSessionLogger(id)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = fmt.Sprintf("fork %d", len(a.branches))
+	}
+	messages := make([]models.Message, len(a.Messages))
+	copy(messages, a.Messages)
+	logger.LogRecord(ForkRecord{Type: "fork", Parent: a.sessionLogger.ID, Name: name, Messages: len(messages), Time: time.Now()})
+	for _, message := range messages {
+		logger.LogMessage(message)
+	}
+
+	a.saveBranch()
+	branch := &sessionBranch{Name: name, Logger: logger, Messages: messages, Title: a.title, Parent: a.sessionLogger.ID}
+	a.branches = append(a.branches, branch)
+	a.loadBranch(len(a.branches) - 1)
+	return branch, nil
+}
+
+// findBranch looks a branch up by its number in /sessions, its name, or its session ID
+func (a *Agent) findBranch(key string) (int, bool) {
+	if number, err := strconv.Atoi(key); err == nil && number >= 1 && number <= len(a.branches) {
+		return number - 1, true
+	}
+	for i, branch := range a.branches {
+		if branch.Name == key || branch.Logger.ID == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func handleFork(a *Agent, args []string) string {
+	branch, err := a.fork(strings.Join(args, " "))
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to fork the session: %v", err))
+	}
+	return theme.SuccessText(fmt.Sprintf("Forked %d messages into %q (session %s), now in use. /sessions lists the branches and switches back.",
+		len(branch.Messages), branch.Name, branch.Logger.ID))
+}
+
+func handleSessions(a *Agent, args []string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ensureBranches()
+
+	if len(args) == 0 {
+		a.saveBranch()
+		var result strings.Builder
+		result.WriteString(theme.InfoText("Sessions open in this process:") + "\n")
+		for i, branch := range a.branches {
+			marker := " "
+			if i == a.branch {
+				marker = "*"
+			}
+			line := fmt.Sprintf(" %s %d. %s (session %s, %d messages", marker, i+1, branch.Name, branch.Logger.ID, len(branch.Messages))
+			if branch.Parent != "" {
+				line += ", forked from " + branch.Parent
+			}
+			line += ")"
+			if branch.Title != "" {
+				line += " " + branch.Title
+			}
+			result.WriteString(theme.InfoText(line) + "\n")
+		}
+		result.WriteString(theme.InfoText("Switch with /sessions <number|name>; /fork [name] starts another branch"))
+		return result.String()
+	}
+
+	index, ok := a.findBranch(strings.Join(args, " "))
+	if !ok {
+		return theme.ErrorText(fmt.Sprintf("No session named %s; /sessions lists them", strings.Join(args, " ")))
+	}
+	if index == a.branch {
+		return theme.InfoText(fmt.Sprintf("Already in %s", a.branches[index].Name))
+	}
+	a.saveBranch()
+	a.loadBranch(index)
+	return theme.SuccessText(fmt.Sprintf("Switched to %s (session %s, %d messages)", a.branches[index].Name, a.sessionLogger.ID, len(a.Messages)))
+}
+
+// closeBranches closes the logs of the branches not in use
+func (a *Agent) closeBranches() {
+	for i, branch := range a.branches {
+		if i != a.branch {
+			branch.Logger.Close()
+		}
+	}
+}
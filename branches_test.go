@@ -0,0 +1,47 @@
+package main
+
+import (
+	"agent/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkAndSwitchSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	logger, err := openSessionLogger("20250101120000")
+	require.NoError(t, err)
+	a := &Agent{sessionLogger: logger, Messages: []models.Message{{Role: "user", Content: "first"}}}
+	defer func() {
+		a.closeBranches()
+		a.sessionLogger.Close()
+	}()
+
+	assert.Contains(t, handleFork(a, []string{"retry"}), `"retry"`)
+	assert.Equal(t, "20250101120000-1", a.sessionLogger.ID)
+	a.AddUserMessage("only in the fork")
+	assert.Len(t, a.Messages, 2)
+
+	assert.Contains(t, handleSessions(a, nil), "* 2. retry (session 20250101120000-1, 2 messages, forked from 20250101120000)")
+	assert.Contains(t, handleSessions(a, []string{"main"}), "Switched to main")
+	assert.Len(t, a.Messages, 1)
+	assert.Equal(t, "20250101120000", a.sessionLogger.ID)
+	assert.Contains(t, handleSessions(a, []string{"9"}), "No session named 9")
+
+	handleSessions(a, []string{"2"})
+	assert.Equal(t, "only in the fork", a.Messages[1].Content)
+
+	entries, err := readSessionLog(filepath.Join(home, ".agent", "sessions", "20250101120000-1.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "the fork's log repeats the forked messages")
+	assert.Equal(t, "first", entries[0].Message.Content)
+
+	data, err := os.ReadFile(filepath.Join(home, ".agent", "sessions", "20250101120000.jsonl"))
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "only in the fork"))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"agent/theme"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Checkpoint is a shadow commit of the working tree taken before a turn modified files
+type Checkpoint struct {
+	Turn    int
+	Commit  string
+	Created time.Time
+	Prompt  string
+}
+
+// mutatingTools are the tools that trigger a checkpoint before their first use in a turn
+var mutatingTools = map[string]bool{
+	"create_file":    true,
+	"edit_file":      true,
+	"delete_file":    true,
+	"multi_edit":     true,
+	"insert_lines":   true,
+	"append_to_file": true,
+	"apply_patch":    true,
+	"undo_edit":      true,
+	"shell":          true,
+}
+
+// runGit runs a git command in the working directory, optionally with a private index file
+func runGit(indexFile string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Env = os.Environ()
+	if indexFile != "" {
+		cmd.Env = append(cmd.Env, "GIT_INDEX_FILE="+indexFile)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// snapshotWorkingTree records every non-ignored file (tracked or not) in a commit without touching
+// the user's index, branch, or stash, and returns the commit hash
+func snapshotWorkingTree(message string) (string, error) {
+	gitDir, err := runGit("", "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+
+	indexFile := filepath.Join(gitDir, "agent-checkpoint-index")
+	defer os.Remove(indexFile)
+
+	if _, err := runGit(indexFile, "add", "-A", ":/"); err != nil {
+		return "", err
+	}
+	tree, err := runGit(indexFile, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"-c", "user.name=agent", "-c", "user.email=agent@localhost", "commit-tree", tree, "-m", message}
+	if head, err := runGit("", "rev-parse", "--verify", "-q", "HEAD"); err == nil && head != "" {
+		args = append(args, "-p", head)
+	}
+	return runGit("", args...)
+}
+
+// createCheckpoint snapshots the working tree once per turn
+func (a *Agent) createCheckpoint() {
+	a.checkpointTurn = a.turn
+
+	prompt := ""
+	history := a.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			prompt = history[i].Content
+			break
+		}
+	}
+
+	commit, err := snapshotWorkingTree(fmt.Sprintf("agent checkpoint: turn %d", a.turn))
+	if err != nil {
+		fmt.Println(theme.WarningText(fmt.Sprintf("Checkpoint skipped: %v", err)))
+		return
+	}
+
+	// Keep the commit reachable so git gc doesn't collect it
+	ref := fmt.Sprintf("refs/agent/checkpoints/%s/%d", a.sessionLogger.ID, len(a.checkpoints)+1)
+	if _, err := runGit("", "update-ref", ref, commit); err != nil {
+		fmt.Println(theme.WarningText(fmt.Sprintf("Failed to save checkpoint ref: %v", err)))
+	}
+
+	a.checkpoints = append(a.checkpoints, Checkpoint{
+		Turn:    a.turn,
+		Commit:  commit,
+		Created: time.Now(),
+		Prompt:  prompt,
+	})
+	a.linkCheckpoint(commit)
+}
+
+// restoreCheckpoint rolls the working tree back to the state captured by checkpoint n (1-based).
+// The current state is checkpointed first so the restore itself can be undone.
+func (a *Agent) restoreCheckpoint(n int) error {
+	if n < 1 || n > len(a.checkpoints) {
+		return fmt.Errorf("checkpoint %d does not exist (have %d)", n, len(a.checkpoints))
+	}
+	target := a.checkpoints[n-1]
+
+	current, err := snapshotWorkingTree(fmt.Sprintf("agent checkpoint: before restoring %d", n))
+	if err != nil {
+		return err
+	}
+	ref := fmt.Sprintf("refs/agent/checkpoints/%s/%d", a.sessionLogger.ID, len(a.checkpoints)+1)
+	if _, err := runGit("", "update-ref", ref, current); err != nil {
+		return err
+	}
+	a.checkpoints = append(a.checkpoints, Checkpoint{
+		Turn:    a.turn,
+		Commit:  current,
+		Created: time.Now(),
+		Prompt:  fmt.Sprintf("(state before /checkpoint restore %d)", n),
+	})
+
+	topLevel, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	// Files that exist now but did not exist at the checkpoint must be removed
+	added, err := runGit("", "diff", "--name-only", "--diff-filter=A", target.Commit, current)
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(added, "\n") {
+		if path != "" {
+			if err := os.Remove(filepath.Join(topLevel, path)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+	}
+
+	gitDir, err := runGit("", "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return err
+	}
+	indexFile := filepath.Join(gitDir, "agent-checkpoint-index")
+	defer os.Remove(indexFile)
+
+	if _, err := runGit(indexFile, "read-tree", target.Commit); err != nil {
+		return err
+	}
+	if _, err := runGit(indexFile, "checkout-index", "-a", "-f", "--prefix="+topLevel+"/"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tempDir))
+	defer os.Chdir(originalDir)
+
+	git := func(args ...string) string {
+		output, err := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@localhost"}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(output))
+		return string(output)
+	}
+	write := func(path, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, path), []byte(content), 0644))
+	}
+	read := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(tempDir, path))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	git("init", "-q", "-b", "main")
+	write("tracked.txt", "committed\n")
+	git("add", "tracked.txt")
+	git("commit", "-q", "-m", "initial")
+	head := git("rev-parse", "HEAD")
+
+	// A dirty working tree: an uncommitted edit, a staged file, and an untracked file
+	write("tracked.txt", "edited\n")
+	write("staged.txt", "staged\n")
+	git("add", "staged.txt")
+	write("untracked.txt", "untracked\n")
+
+	a := &Agent{sessionLogger: &SessionLogger{ID: "test"}, turn: 1}
+	a.createCheckpoint()
+	require.Len(t, a.checkpoints, 1)
+	assert.Equal(t, 1, a.checkpoints[0].Turn)
+
+	write("tracked.txt", "edited again\n")
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "untracked.txt")))
+	write("new.txt", "new\n")
+
+	require.NoError(t, a.restoreCheckpoint(1))
+	assert.Equal(t, "edited\n", read("tracked.txt"))
+	assert.Equal(t, "staged\n", read("staged.txt"))
+	assert.Equal(t, "untracked\n", read("untracked.txt"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "new.txt"), "files created after the checkpoint are removed")
+
+	// The user's branch, index, and stash are left alone
+	assert.Equal(t, head, git("rev-parse", "HEAD"))
+	assert.Equal(t, "staged.txt\n", git("diff", "--cached", "--name-only"))
+	assert.Empty(t, git("stash", "list"))
+
+	// The state before the restore was checkpointed, so the restore can be undone
+	require.Len(t, a.checkpoints, 2)
+	require.NoError(t, a.restoreCheckpoint(2))
+	assert.Equal(t, "edited again\n", read("tracked.txt"))
+	assert.Equal(t, "new\n", read("new.txt"))
+
+	assert.ErrorContains(t, a.restoreCheckpoint(5), "checkpoint 5 does not exist")
+}
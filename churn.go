@@ -0,0 +1,129 @@
+package main
+
+import (
+	"agent/theme"
+	"agent/tools"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ChurnConfig caps how many lines the agent may change in a single turn
+type ChurnConfig struct {
+	MaxLines int    `json:"max_lines"` // 0 disables the cap
+	Action   string `json:"action"`    // "warn" (default) or "approve" to ask before keeping changes past the cap
+}
+
+// churnTools are the file tools whose changes count toward the per-turn churn
+var churnTools = map[string]bool{
+	"create_file":    true,
+	"edit_file":      true,
+	"delete_file":    true,
+	"multi_edit":     true,
+	"insert_lines":   true,
+	"append_to_file": true,
+	"apply_patch":    true,
+}
+
+// turnChurn tracks the lines changed by file tools during the current turn
+type turnChurn struct {
+	added    int
+	removed  int
+	files    map[string]bool
+	warned   bool
+	approved bool
+}
+
+func (c turnChurn) total() int {
+	return c.added + c.removed
+}
+
+// readFileIfExists returns a file's content, or an empty string if it doesn't exist
+func readFileIfExists(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// churnPaths returns the files a call to a churn tool changes: every file a patch touches, or the
+// tool's path
+func (a *Agent) churnPaths(tool string, params map[string]interface{}) []string {
+	if !churnTools[tool] {
+		return nil
+	}
+	if patch, ok := params["patch"].(string); ok && tool == "apply_patch" {
+		return a.patchPaths(patch)
+	}
+	if path, _ := params["path"].(string); path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// recordChurn adds the changes made to paths since before (their contents prior to the tool call)
+// to the turn's churn and enforces the cap. When the user declines to go past the cap, the journal
+// is undone back to its first journaled entries, reverting the call, and an error is returned.
+func (a *Agent) recordChurn(paths []string, before map[string]string, journaled int) error {
+	added, removed := 0, 0
+	for _, path := range paths {
+		pathAdded, pathRemoved := tools.CountLineChanges(before[path], readFileIfExists(path))
+		if pathAdded == 0 && pathRemoved == 0 {
+			continue
+		}
+		if a.churn.files == nil {
+			a.churn.files = make(map[string]bool)
+		}
+		a.churn.files[path] = true
+		added += pathAdded
+		removed += pathRemoved
+	}
+	if added == 0 && removed == 0 {
+		return nil
+	}
+	a.churn.added += added
+	a.churn.removed += removed
+
+	limit := a.config.Churn.MaxLines
+	fmt.Println(theme.DebugText(churnBar(a.churn, limit)))
+
+	if limit <= 0 || a.churn.total() <= limit || a.churn.approved {
+		return nil
+	}
+
+	if a.config.Churn.Action != "approve" {
+		if !a.churn.warned {
+			a.churn.warned = true
+			fmt.Println(theme.WarningText(fmt.Sprintf("⚠ This turn has changed %d lines, over the limit of %d. Press Ctrl+C to stop.", a.churn.total(), limit)))
+		}
+		return nil
+	}
+
+	if a.Confirm(fmt.Sprintf("This turn has changed %d lines, over the limit of %d. Keep this change and continue?", a.churn.total(), limit)) {
+		a.churn.approved = true
+		return nil
+	}
+
+	target := strings.Join(paths, ", ")
+	for len(a.journal.Entries()) > journaled {
+		if _, err := a.journal.UndoLast(); err != nil {
+			return fmt.Errorf("failed to revert change to %s: %w", target, err)
+		}
+	}
+	a.churn.added -= added
+	a.churn.removed -= removed
+	return fmt.Errorf("the user declined a change to %s that took this turn past %d changed lines, so it was reverted. Stop editing and ask the user how to proceed", target, limit)
+}
+
+// churnBar renders the turn's churn, with a bar showing progress toward the limit when one is set
+func churnBar(churn turnChurn, limit int) string {
+	summary := fmt.Sprintf("Δ turn: +%d -%d lines in %d files", churn.added, churn.removed, len(churn.files))
+	if limit <= 0 {
+		return summary
+	}
+
+	const width = 20
+	filled := min(churn.total()*width/limit, width)
+	return fmt.Sprintf("%s [%s%s] %d/%d", summary, strings.Repeat("█", filled), strings.Repeat("░", width-filled), churn.total(), limit)
+}
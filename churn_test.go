@@ -0,0 +1,175 @@
+package main
+
+import (
+	"agent/models"
+	"agent/permissions"
+	"agent/tools"
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChurnAgent returns an agent that runs file tools in root and answers confirmations with answers
+func newChurnAgent(t *testing.T, root string, churn ChurnConfig, answers string) *Agent {
+	journal := tools.NewChangeJournal(filepath.Join(t.TempDir(), "journal"))
+	a := &Agent{
+		config:   &Config{Churn: churn},
+		journal:  journal,
+		workDir:  tools.NewWorkingDirectory(root),
+		watchdog: newWatchdog(),
+		input:    bufio.NewScanner(strings.NewReader(answers)),
+	}
+	a.permissions, _ = permissions.New(root, nil)
+	a.tools = map[string]models.ToolDefinition{
+		"create_file": tools.NewCreateFileTool(journal, nil, nil),
+		"edit_file":   tools.NewEditFileTool(journal, nil),
+		"delete_file": tools.NewDeleteFileTool(journal),
+		"apply_patch": tools.NewApplyPatchTool(journal, nil, a.workDir),
+	}
+	return a
+}
+
+func callTool(t *testing.T, a *Agent, name string, params map[string]interface{}) error {
+	arguments, err := json.Marshal(params)
+	require.NoError(t, err)
+	_, err = a.ExecuteToolCall(context.Background(), models.ToolCall{ID: "1", Type: "function", Function: models.FunctionCall{Name: name, Arguments: string(arguments)}})
+	return err
+}
+
+func TestPatchChurnCap(t *testing.T) {
+	root := t.TempDir()
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+	defer os.Chdir(originalDir)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("one\ntwo\nthree\n"), 0644))
+	}
+	a := newChurnAgent(t, root, ChurnConfig{MaxLines: 6, Action: "approve"}, "n\n")
+
+	// Each file alone stays under the cap, but the patch as a whole doesn't
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n" +
+		"--- a/b.txt\n+++ b/b.txt\n@@ -1,3 +1,3 @@\n-one\n-two\n-three\n+1\n+2\n+3\n"
+	err = callTool(t, a, "apply_patch", map[string]interface{}{"patch": patch})
+	require.ErrorContains(t, err, "took this turn past 6 changed lines, so it was reverted")
+	assert.Contains(t, err.Error(), "a.txt, b.txt")
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		assert.Equal(t, "one\ntwo\nthree\n", string(data), "every patched file is reverted")
+	}
+	assert.Equal(t, 0, a.churn.total())
+	assert.Empty(t, a.journal.Entries())
+}
+
+func TestChurnCounting(t *testing.T) {
+	root := t.TempDir()
+	a := newChurnAgent(t, root, ChurnConfig{}, "")
+	path := filepath.Join(root, "a.txt")
+
+	steps := []struct {
+		name           string
+		tool           string
+		params         map[string]interface{}
+		added, removed int
+	}{
+		{"create", "create_file", map[string]interface{}{"path": path, "content": "one\ntwo\nthree\n"}, 3, 0},
+		{"edit", "edit_file", map[string]interface{}{"path": path, "old_str": "two", "new_str": "2\n2.5"}, 5, 1},
+		{"unchanged edit", "edit_file", map[string]interface{}{"path": path, "old_str": "one", "new_str": "one"}, 5, 1},
+		{"delete", "delete_file", map[string]interface{}{"path": path}, 5, 5},
+	}
+	for _, step := range steps {
+		require.NoError(t, callTool(t, a, step.tool, step.params), step.name)
+		assert.Equal(t, step.added, a.churn.added, step.name)
+		assert.Equal(t, step.removed, a.churn.removed, step.name)
+	}
+	assert.Len(t, a.churn.files, 1)
+	assert.Equal(t, "Δ turn: +5 -5 lines in 1 files", churnBar(a.churn, 0))
+}
+
+func TestChurnCap(t *testing.T) {
+	cases := []struct {
+		name    string
+		action  string
+		answers string
+		kept    bool
+		err     string
+	}{
+		{"warn", "warn", "", true, ""},
+		{"approved", "approve", "y\n", true, ""},
+		{"declined", "approve", "n\n", false, "took this turn past 4 changed lines, so it was reverted"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			a := newChurnAgent(t, root, ChurnConfig{MaxLines: 4, Action: c.action}, c.answers)
+
+			// At the cap isn't over it
+			require.NoError(t, callTool(t, a, "create_file", map[string]interface{}{"path": filepath.Join(root, "a.txt"), "content": "1\n2\n3\n4\n"}))
+			err := callTool(t, a, "create_file", map[string]interface{}{"path": filepath.Join(root, "b.txt"), "content": "5\n"})
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if c.kept {
+				assert.FileExists(t, filepath.Join(root, "b.txt"))
+				assert.Equal(t, 5, a.churn.total())
+			} else {
+				assert.NoFileExists(t, filepath.Join(root, "b.txt"))
+				assert.Equal(t, 4, a.churn.total(), "the reverted change isn't counted")
+			}
+			assert.FileExists(t, filepath.Join(root, "a.txt"), "changes before the cap are kept")
+		})
+	}
+}
+
+func TestChurnBar(t *testing.T) {
+	churn := turnChurn{added: 3, removed: 2, files: map[string]bool{"a.txt": true, "b.txt": true}}
+	cases := []struct {
+		name     string
+		churn    turnChurn
+		limit    int
+		expected string
+	}{
+		{"no limit", churn, 0, "Δ turn: +3 -2 lines in 2 files"},
+		{"under the limit", churn, 20, "Δ turn: +3 -2 lines in 2 files [█████░░░░░░░░░░░░░░░] 5/20"},
+		{"over the limit", churn, 4, "Δ turn: +3 -2 lines in 2 files [████████████████████] 5/4"},
+		{"nothing changed", turnChurn{}, 10, "Δ turn: +0 -0 lines in 0 files [░░░░░░░░░░░░░░░░░░░░] 0/10"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, churnBar(c.churn, c.limit))
+		})
+	}
+}
+
+func TestChurnResetsEachTurn(t *testing.T) {
+	root := t.TempDir()
+	a := newChurnAgent(t, root, ChurnConfig{MaxLines: 2, Action: "approve"}, "y\nn\n")
+	create := func(name string) error {
+		return callTool(t, a, "create_file", map[string]interface{}{"path": filepath.Join(root, name), "content": "1\n2\n3\n"})
+	}
+
+	a.startTurn()
+	require.NoError(t, create("a.txt"), "approved")
+	require.NoError(t, create("b.txt"), "already approved this turn")
+	assert.Equal(t, 6, a.churn.total())
+
+	// A new turn starts counting from zero and asks again past the cap
+	a.startTurn()
+	assert.Equal(t, 0, a.churn.total())
+	assert.Empty(t, a.churn.files)
+	assert.ErrorContains(t, create("c.txt"), "so it was reverted")
+	assert.NoFileExists(t, filepath.Join(root, "c.txt"))
+	assert.FileExists(t, filepath.Join(root, "b.txt"), "only this turn's change is reverted")
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"agent/api"
+	"agent/logging"
 	"agent/miniagents"
 	"agent/theme"
+	"agent/tools"
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -48,12 +54,42 @@ type Command struct {
 }
 
 var builtinCommands = map[string]Command{
-	"help":    {handleHelp, "Show available commands and their descriptions"},
-	"model":   {handleModel, "Show or change the AI model and provider"},
-	"context": {handleContext, "Show live context summary (use 'full' to see complete content)"},
-	"prune":   {handlePrune, "Prune context to reduce size (usage: /prune [target_reduction_chars])"},
-	"clear":   {handleClear, "Clear conversation history"},
-	"quit":    {handleQuit, "Quit to the terminal"},
+	"help":        {handleHelp, "Show available commands and their descriptions"},
+	"model":       {handleModel, "Show or change the AI model and provider"},
+	"models":      {handleModels, "List the configured models, or ask the providers for theirs and add them (usage: /models [provider] | /models refresh [provider...])"},
+	"context":     {handleContext, "Show live context summary (use 'full' to see complete content, 'placement system|message' to choose where it is sent, 'save|load <name>' to switch working sets)"},
+	"prompt":      {handlePrompt, "Show which system prompt template is used and the variables templates can use, or the rendered prompt (usage: /prompt [show])"},
+	"why":         {handleWhy, "Explain what the last model request included and what was trimmed to fit"},
+	"prune":       {handlePrune, "Prune history and live context toward their budgets (usage: /prune [target_reduction_chars])"},
+	"clear":       {handleClear, "Clear conversation history"},
+	"artifacts":   {handleArtifacts, "List this session's artifacts, or show one (usage: /artifacts [n])"},
+	"anchors":     {handleAnchors, "List, set, or remove named code locations (usage: /anchors [set <name> <path:line> [note]|remove <name>])"},
+	"pin":         {handlePin, "Pin live-context entries so pruning never removes them (usage: /pin [path|off <path>])"},
+	"priority":    {handlePriority, "Show or set live-context priorities; low-priority entries are cut first when over budget (usage: /priority [<path> low|normal|high])"},
+	"undo":        {handleUndo, "Revert file changes (usage: /undo [turn <n>|list])"},
+	"tasks":       {handleTasks, "Track multi-session tasks and show the model's task board for this session (usage: /tasks [new <prompt>|issue <n>|show [n]|start <n>|verify [n]|done [n] [note]|note <text>|board [clear]])"},
+	"checkpoint":  {handleCheckpoint, "List or restore working tree checkpoints (usage: /checkpoint list|restore <n>)"},
+	"index":       {handleIndex, "Build or refresh the semantic search index for this workspace (usage: /index [status])"},
+	"persona":     {handlePersona, "Act as a configured persona with its own instructions, tools, and model, or list them (usage: /persona [<name>|off])"},
+	"plan":        {handlePlan, "Plan with read-only tools before changing anything (usage: /plan [task]|off)"},
+	"execute":     {handleExecute, "Leave plan mode and carry out the latest plan (usage: /execute [extra instructions])"},
+	"permissions": {handlePermissions, "Show or change tool permission rules for this session (usage: /permissions [add <rule>|remove <n>])"},
+	"deps":        {handleDeps, "List outdated Go modules, upgrade the selected ones, and fix what breaks (usage: /deps)"},
+	"config":      {handleConfig, "Show the config and any problems in it, or change a value (usage: /config [get <path>|set <path> <value>])"},
+	"reload":      {handleReload, "Reload the global and project config (.agent/config.json) without restarting"},
+	"trust":       {handleTrust, "Apply all of this directory's .agent/config.json, including hooks, providers, and security settings"},
+	"usage":       {handleUsage, "Show the tokens each model used this session and what they cost"},
+	"theme":       {handleTheme, "Show or switch the color theme for this session (usage: /theme [name])"},
+	"set":         {handleSet, "Override temperature, top_p, or max_tokens for this session without editing the config (usage: /set [tool_calls.]<name> <value|default> | /set reset)"},
+	"stop":        {handleStop, "Stop the running turn; type it while the agent works (Esc in --tui)"},
+	"export":      {handleExport, "Save the conversation, tool calls, and file changes to a file (usage: /export [markdown|html|json] [path])"},
+	"fork":        {handleFork, "Copy the conversation into a new session to try another approach (usage: /fork [name])"},
+	"sessions":    {handleSessions, "List the sessions forked in this process, or switch to one (usage: /sessions [number|name])"},
+	"stats":       {handleStats, "Show how many times each tool ran this session, how long it took, how often it failed, and how much it returned"},
+	"debug":       {handleDebug, "Write debug messages and raw streamed response chunks to ~/.agent/agent.log (usage: /debug on|off)"},
+	"dryrun":      {handleDryRun, "Toggle dry run, where file tools show their diffs without writing to disk (usage: /dryrun [on|off])"},
+	"share":       {handleShare, "Preview a sanitized transcript, then upload it and print its URL (usage: /share [anonymize])"},
+	"quit":        {handleQuit, "Quit to the terminal"},
 }
 
 // registerBuiltinCommands sets up all the built-in commands
@@ -94,6 +130,9 @@ func handleModel(a *Agent, args []string) string {
 
 	if len(args) == 0 {
 		result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Current model: %s:%s", a.currentModel.Provider.Name, a.currentModel.Name))))
+		for _, line := range a.modelSettings() {
+			result.WriteString(theme.InfoText(line) + "\n")
+		}
 		result.WriteString("\n")
 
 		result.WriteString(fmt.Sprintf("%s\n", theme.InfoText("Available models:")))
@@ -132,21 +171,81 @@ func handleModel(a *Agent, args []string) string {
 				errorMsg.WriteString(theme.InfoText("2. Set: export OPENROUTER_API_KEY=\"your-key\"") + "\n")
 			}
 			return errorMsg.String()
-		} else {
-			return theme.SuccessText(fmt.Sprintf("Switched to %s:%s", provider, modelID))
 		}
+		if err := saveSelectedModel(provider, modelID); err != nil {
+			return theme.WarningText(fmt.Sprintf("Switched to %s:%s, but failed to save it as the default: %v", provider, modelID, err))
+		}
+		message := theme.SuccessText(fmt.Sprintf("Switched to %s:%s", provider, modelID))
+		for _, note := range capabilityNotes(a.currentModel) {
+			message += "\n" + theme.WarningText("Note: "+note)
+		}
+		return message
 	}
 
 	return theme.ErrorText("Invalid arguments. Use /model for usage information.")
 }
 
+func handleTheme(a *Agent, args []string) string {
+	if len(args) == 0 {
+		var result strings.Builder
+		result.WriteString(theme.InfoText(fmt.Sprintf("Current theme: %s", theme.Current())) + "\n")
+		result.WriteString(theme.InfoText("Available themes: "+strings.Join(theme.Presets(), ", ")) + "\n")
+		result.WriteString(theme.InfoText("Set \"preset\" in ~/.agent/theme.json to change the default, and \"styles\" to override colors."))
+		return result.String()
+	}
+	if err := theme.SetTheme(args[0]); err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to switch theme: %v", err))
+	}
+	return theme.SuccessText(fmt.Sprintf("Switched to the %s theme", args[0]))
+}
+
+func handleDebug(a *Agent, args []string) string {
+	path, _ := logging.Path()
+	if len(args) == 0 {
+		state := "off"
+		if logging.Debugging() {
+			state = "on"
+		}
+		return theme.InfoText(fmt.Sprintf("Debug logging is %s; the log is %s. Usage: /debug on|off", state, path))
+	}
+	switch args[0] {
+	case "on":
+		logging.SetDebug(true)
+		return theme.SuccessText(fmt.Sprintf("Writing debug messages and every streamed response chunk to %s", path))
+	case "off":
+		logging.SetDebug(false)
+		return theme.SuccessText("Debug logging is off")
+	}
+	return theme.ErrorText("Usage: /debug on|off")
+}
+
 func handleClear(a *Agent, args []string) string {
 	a.ClearHistory()
-	a.InitializeDefaultContext()
-	return theme.SuccessText("Conversation context and history cleared")
+	a.board.Clear()
+	a.workDir.Reset()
+	seeded := a.InitializeDefaultContext()
+	result := theme.SuccessText("Conversation context and history cleared")
+	if len(seeded) > 0 {
+		result += "\n" + theme.InfoText(fmt.Sprintf("Added %d files with uncommitted changes to live context: %s", len(seeded), strings.Join(seeded, ", ")))
+	}
+	return result
 }
 
 func handleContext(a *Agent, args []string) string {
+	if len(args) > 0 && args[0] == "placement" {
+		if len(args) == 1 {
+			return theme.InfoText(fmt.Sprintf("Live context is sent in the %s (switch with /context placement system|message)", placementLabel(a.ContextPlacement())))
+		}
+		if err := a.setContextPlacement(args[1]); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return theme.SuccessText(fmt.Sprintf("Live context will be sent in the %s for the rest of this session", placementLabel(args[1])))
+	}
+
+	if len(args) > 0 && (args[0] == "save" || args[0] == "load") {
+		return handleContextSet(a, args[0], args[1:])
+	}
+
 	liveContext := a.LiveContext
 	showFull := len(args) > 0 && args[0] == "full"
 
@@ -154,6 +253,10 @@ func handleContext(a *Agent, args []string) string {
 
 	currentSize, maxSize, usagePercent := liveContext.GetContextUsage()
 	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Context Usage: %d/%d bytes (%.1f%%)", currentSize, maxSize, usagePercent))))
+	if usage := api.PromptCacheUsage(); usage.PromptTokens > 0 {
+		result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Prompt Cache: %d/%d prompt tokens read from cache (%.1f%%) over %d requests",
+			usage.CachedTokens, usage.PromptTokens, 100*float64(usage.CachedTokens)/float64(usage.PromptTokens), usage.Requests))))
+	}
 	result.WriteString("\n")
 
 	if showFull {
@@ -170,14 +273,14 @@ func handleContext(a *Agent, args []string) string {
 		if len(files) > 0 {
 			result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Files (%d):", len(files)))))
 			for _, file := range files {
-				result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("- %s", file))))
+				result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("- %s%s", file, priorityLabel(liveContext, file)))))
 			}
 		}
 
 		if len(dirs) > 0 {
 			result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Directories (%d):", len(dirs)))))
 			for _, dir := range dirs {
-				result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("- %s", dir))))
+				result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("- %s%s", dir, priorityLabel(liveContext, dir)))))
 			}
 		}
 
@@ -188,21 +291,154 @@ func handleContext(a *Agent, args []string) string {
 	return result.String()
 }
 
+// handleContextSet saves the live-context working set under a name or switches to a saved one
+func handleContextSet(a *Agent, action string, args []string) string {
+	if len(args) == 0 {
+		names, err := ListContextSets()
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to list saved contexts: %v", err))
+		}
+		if len(names) == 0 {
+			return theme.InfoText(fmt.Sprintf("No saved contexts. Usage: /context %s <name>", action))
+		}
+		return theme.InfoText(fmt.Sprintf("Saved contexts: %s\nUsage: /context %s <name>", strings.Join(names, ", "), action))
+	}
+
+	if action == "save" {
+		set, err := SaveContextSet(a.LiveContext, args[0])
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to save context: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("Saved %d files and %d directories as %q (switch back with /context load %s)", len(set.Files), len(set.Directories), set.Name, set.Name))
+	}
+
+	set, missing, err := LoadContextSet(a.LiveContext, args[0])
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to load context: %v", err))
+	}
+	var result strings.Builder
+	result.WriteString(theme.SuccessText(fmt.Sprintf("Loaded context %q: %d files and %d directories", set.Name, len(a.LiveContext.ListFiles()), len(a.LiveContext.ListDirectories()))))
+	for _, path := range missing {
+		result.WriteString("\n" + theme.WarningText(fmt.Sprintf("Skipped %s: it no longer exists", path)))
+	}
+	return result.String()
+}
+
+// priorityLabel describes non-default pinning and priority for display
+func priorityLabel(liveContext *LiveContext, path string) string {
+	pinned, priority := liveContext.GetPriority(path)
+	if pinned {
+		return " [pinned]"
+	}
+	if priority != PriorityNormal {
+		return fmt.Sprintf(" [%s]", priority)
+	}
+	return ""
+}
+
+func handlePin(a *Agent, args []string) string {
+	if len(args) == 0 {
+		var pinned []string
+		for _, path := range append(a.LiveContext.ListFiles(), a.LiveContext.ListDirectories()...) {
+			if isPinned, _ := a.LiveContext.GetPriority(path); isPinned {
+				pinned = append(pinned, "- "+path)
+			}
+		}
+		if len(pinned) == 0 {
+			return theme.InfoText("No pinned entries. Usage: /pin <path>")
+		}
+		sort.Strings(pinned)
+		return theme.InfoText("Pinned entries:\n" + strings.Join(pinned, "\n"))
+	}
+
+	if args[0] == "off" && len(args) == 2 {
+		_, priority := a.LiveContext.GetPriority(args[1])
+		if err := a.LiveContext.SetPriority(args[1], false, priority); err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to unpin: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("Unpinned %s", args[1]))
+	}
+
+	// Entries not yet in live context are added so they can be pinned
+	path := args[0]
+	if !slices.Contains(a.LiveContext.ListFiles(), path) && !slices.Contains(a.LiveContext.ListDirectories(), path) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if err := a.LiveContext.AddDirectory(path, false); err != nil {
+				return theme.ErrorText(fmt.Sprintf("Failed to add directory: %v", err))
+			}
+		} else if err := a.LiveContext.AddFile(path, 1, nil); err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to add file: %v", err))
+		}
+	}
+
+	_, priority := a.LiveContext.GetPriority(path)
+	if err := a.LiveContext.SetPriority(path, true, priority); err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to pin: %v", err))
+	}
+	return theme.SuccessText(fmt.Sprintf("Pinned %s", path))
+}
+
+func handlePriority(a *Agent, args []string) string {
+	if len(args) == 0 {
+		var prioritized []string
+		for _, path := range append(a.LiveContext.ListFiles(), a.LiveContext.ListDirectories()...) {
+			if label := priorityLabel(a.LiveContext, path); label != "" {
+				prioritized = append(prioritized, "- "+path+label)
+			}
+		}
+		if len(prioritized) == 0 {
+			return theme.InfoText("Every live-context entry has normal priority. Usage: /priority <path> low|normal|high")
+		}
+		sort.Strings(prioritized)
+		return theme.InfoText("Live-context priorities:\n" + strings.Join(prioritized, "\n"))
+	}
+	if len(args) != 2 {
+		return theme.ErrorText("Usage: /priority [<path> low|normal|high]")
+	}
+
+	path, priority := args[0], args[1]
+	pinned, _ := a.LiveContext.GetPriority(path)
+	if err := a.LiveContext.SetPriority(path, pinned, priority); err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to set priority: %v", err))
+	}
+	return theme.SuccessText(fmt.Sprintf("Set %s to %s priority", path, priority))
+}
+
 func handlePrune(a *Agent, args []string) string {
 	currentSize := a.GetContextCharacterCount()
 
-	targetReduction := currentSize / 4
+	historySize := 0
+	for _, msg := range a.GetHistory() {
+		if msg.Status != "deleted" {
+			historySize += messageSize(msg)
+		}
+	}
+	liveContextSize := currentSize - historySize
+
+	// Each subsystem is pruned against its own budget when one is configured
+	historyReduction := historySize / 4
+	liveContextReduction := liveContextSize / 4
+	if _, liveContextBudget, historyBudget := a.config.Budget.Limits(); historyBudget > 0 {
+		historyReduction = max(0, historySize-historyBudget)
+		liveContextReduction = max(0, liveContextSize-liveContextBudget)
+	}
 
 	if len(args) > 0 {
-		if parsed, err := strconv.Atoi(args[0]); err == nil && parsed > 0 {
-			targetReduction = parsed
+		if parsed, err := strconv.Atoi(args[0]); err == nil && parsed > 0 && currentSize > 0 {
+			historyReduction = parsed * historySize / currentSize
+			liveContextReduction = parsed - historyReduction
 		}
 	}
 
 	var result strings.Builder
 	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText("Starting context pruning...")))
-	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Current context size: %d characters", currentSize))))
-	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Target reduction: %d characters", targetReduction))))
+	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Current context size: %d characters (history %d, live context %d)", currentSize, historySize, liveContextSize))))
+	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText(fmt.Sprintf("Target reduction: history %d, live context %d characters", historyReduction, liveContextReduction))))
+
+	if historyReduction == 0 && liveContextReduction == 0 {
+		result.WriteString(theme.SuccessText("Context is within budget, nothing to prune") + "\n")
+		return result.String()
+	}
 
 	if a.currentModel == nil {
 		return theme.ErrorText("No model configured. Use /model to set one.")
@@ -210,9 +446,10 @@ func handlePrune(a *Agent, args []string) string {
 
 	messages := a.GetHistory()
 
-	go func() {
-		ctx := context.Background()
-		if err := miniagents.PruneContext(ctx, a.currentModel, &messages, a.LiveContext, a.tools); err != nil {
+	err := a.miniagents.Start("pruner", a.currentModel, func(ctx context.Context, env *miniagents.Env) error {
+		return miniagents.PruneContext(ctx, env, &messages, a.LiveContext, a.tools, historyReduction, liveContextReduction)
+	}, func(err error) {
+		if err != nil {
 			fmt.Printf("%s\n", theme.ErrorText(fmt.Sprintf("Context pruning failed: %v", err)))
 		} else {
 			newSize := a.GetContextCharacterCount()
@@ -221,8 +458,196 @@ func handlePrune(a *Agent, args []string) string {
 			fmt.Printf("%s\n", theme.InfoText(fmt.Sprintf("New context size: %d characters", newSize)))
 			fmt.Printf("%s\n", theme.InfoText(fmt.Sprintf("Actual reduction: %d characters", actualReduction)))
 		}
-	}()
+	})
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Context pruning not started: %v", err))
+	}
 
 	result.WriteString(fmt.Sprintf("%s\n", theme.InfoText("Context pruning started in background...")))
 	return result.String()
 }
+
+func handleShare(a *Agent, args []string) string {
+	anonymize := a.config.Share.AnonymizePaths || (len(args) > 0 && args[0] == "anonymize")
+
+	transcript := a.sanitizeTranscript(renderTranscript(a.GetHistory()), anonymize)
+
+	// Uploads can't be taken back, so the user sees exactly what would be published first
+	fmt.Println(transcript)
+	service := a.config.Share.Service
+	if service == "" {
+		service = "gist"
+	}
+	if !a.Confirm(fmt.Sprintf("Upload the transcript above as a %s?", service)) {
+		return theme.InfoText("Transcript not shared")
+	}
+
+	url, err := uploadTranscript(a.config.Share, transcript)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to share transcript: %v", err))
+	}
+
+	return theme.SuccessText(fmt.Sprintf("Shared transcript: %s", url))
+}
+
+func handleIndex(a *Agent, args []string) string {
+	if a.searchIndex == nil {
+		return theme.ErrorText("Semantic search index is unavailable")
+	}
+
+	if len(args) > 0 && args[0] == "status" {
+		files, chunks, updated := a.searchIndex.Stats()
+		if updated.IsZero() {
+			return theme.InfoText("Index not built yet. Run /index to build it.")
+		}
+		return theme.InfoText(fmt.Sprintf("Index: %d files, %d chunks, model %s, updated %s", files, chunks, a.config.Index.embeddingModel(), updated.Format("2006-01-02 15:04")))
+	} else if len(args) > 0 {
+		return theme.ErrorText("Invalid arguments. Usage: /index [status]")
+	}
+
+	stats, err := a.searchIndex.Refresh(context.Background(), func(done, total int) {
+		fmt.Printf("\r%s", theme.InfoText(fmt.Sprintf("Embedding files: %d/%d", done, total)))
+	})
+	fmt.Println()
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Indexing failed after %d files: %v", stats.Embedded, err))
+	}
+
+	return theme.SuccessText(fmt.Sprintf("Indexed %d files (%d chunks); embedded %d changed files, removed %d", stats.Files, stats.Chunks, stats.Embedded, stats.Removed))
+}
+
+func handleUndo(a *Agent, args []string) string {
+	if len(args) > 0 && args[0] == "list" {
+		entries := a.journal.Entries()
+		if len(entries) == 0 {
+			return theme.InfoText("No file changes recorded this session")
+		}
+		var result strings.Builder
+		result.WriteString(theme.InfoText("Recorded file changes:") + "\n")
+		for _, e := range entries {
+			result.WriteString(theme.InfoText(fmt.Sprintf("  turn %d: %s %s", e.Turn, e.Tool, e.Path)) + "\n")
+		}
+		return result.String()
+	}
+
+	var reverted []tools.JournalEntry
+	var err error
+	if len(args) == 2 && args[0] == "turn" {
+		turn, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return theme.ErrorText("Invalid turn number. Usage: /undo turn <n>")
+		}
+		reverted, err = a.journal.UndoTurn(turn)
+	} else if len(args) == 0 {
+		reverted, err = a.journal.UndoLast()
+	} else {
+		return theme.ErrorText("Invalid arguments. Usage: /undo [turn <n>|list]")
+	}
+
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Undo failed: %v", err))
+	}
+	return theme.SuccessText(tools.FormatRevertedEntries(reverted))
+}
+
+func handleCheckpoint(a *Agent, args []string) string {
+	if len(args) == 0 || args[0] == "list" {
+		if !a.config.Checkpoints {
+			return theme.InfoText("Checkpoints are disabled. Set \"checkpoints\": true in ~/.agent/config.json to enable them.")
+		}
+		if len(a.checkpoints) == 0 {
+			return theme.InfoText("No checkpoints yet. One is taken before each turn that modifies files.")
+		}
+
+		var result strings.Builder
+		result.WriteString(theme.InfoText("Checkpoints:") + "\n")
+		for i, cp := range a.checkpoints {
+			prompt := strings.ReplaceAll(cp.Prompt, "\n", " ")
+			if len(prompt) > 60 {
+				prompt = prompt[:60] + "..."
+			}
+			result.WriteString(theme.InfoText(fmt.Sprintf("  %d. turn %d, %s, %s - %s", i+1, cp.Turn, cp.Created.Format("15:04:05"), cp.Commit[:8], prompt)) + "\n")
+		}
+		return result.String()
+	}
+
+	if args[0] == "restore" && len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return theme.ErrorText("Invalid checkpoint number. Usage: /checkpoint restore <n>")
+		}
+		if err := a.restoreCheckpoint(n); err != nil {
+			return theme.ErrorText(fmt.Sprintf("Restore failed: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("Restored working tree to checkpoint %d (previous state saved as checkpoint %d)", n, len(a.checkpoints)))
+	}
+
+	return theme.ErrorText("Invalid arguments. Usage: /checkpoint list|restore <n>")
+}
+
+func handleArtifacts(a *Agent, args []string) string {
+	if len(args) == 0 {
+		list := a.artifacts.List()
+		if len(list) == 0 {
+			return theme.InfoText("No artifacts saved this session")
+		}
+		var result strings.Builder
+		result.WriteString(theme.InfoText(fmt.Sprintf("Artifacts in %s:", a.artifacts.Dir())) + "\n")
+		for _, artifact := range list {
+			result.WriteString(tools.FormatArtifact(artifact) + "\n")
+		}
+		return strings.TrimRight(result.String(), "\n")
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil || len(args) > 1 {
+		return theme.ErrorText("Usage: /artifacts [n]")
+	}
+	artifact, ok := a.artifacts.Get(id)
+	if !ok {
+		return theme.ErrorText(fmt.Sprintf("There is no artifact #%d", id))
+	}
+	content, err := os.ReadFile(artifact.Path)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to read artifact #%d: %v", id, err))
+	}
+	if !utf8.Valid(content) {
+		return tools.FormatArtifact(artifact) + "\n" + artifact.Path
+	}
+	return tools.FormatArtifact(artifact) + "\n" + artifact.Path + "\n\n" + previewText(strings.TrimRight(string(content), "\n"), a.config.Preview)
+}
+
+func handleAnchors(a *Agent, args []string) string {
+	switch {
+	case len(args) == 0:
+		anchors := a.anchors.List()
+		if len(anchors) == 0 {
+			return theme.InfoText("No anchors. Set one with /anchors set <name> <path:line> [note]")
+		}
+		var result strings.Builder
+		for _, anchor := range anchors {
+			line := fmt.Sprintf("⚓ %s → %s", anchor.Name, anchor.Location())
+			if anchor.Note != "" {
+				line += " — " + anchor.Note
+			}
+			if anchor.Stale {
+				result.WriteString(theme.WarningText(line+" (stale)") + "\n")
+				continue
+			}
+			result.WriteString(theme.InfoText(line) + "\n")
+		}
+		return strings.TrimRight(result.String(), "\n")
+	case args[0] == "set" && len(args) >= 3:
+		anchor, err := a.anchors.Set(args[1], args[2], strings.Join(args[3:], " "))
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to set anchor: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("⚓ %s → %s", anchor.Name, anchor.Location()))
+	case args[0] == "remove" && len(args) == 2:
+		if err := a.anchors.Remove(args[1]); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return theme.SuccessText("Removed anchor " + args[1])
+	}
+	return theme.ErrorText("Usage: /anchors [set <name> <path:line> [note]|remove <name>]")
+}
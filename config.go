@@ -1,8 +1,10 @@
 package main
 
 import (
+	"agent/hooks"
 	"agent/models"
-	"agent/theme"
+	"agent/tools"
+	"agent/tracing"
 	_ "embed"
 	"encoding/json"
 	"fmt"
@@ -15,9 +17,95 @@ var defaultConfigJSON []byte
 
 // Config represents the persistent agent configuration
 type Config struct {
-	Providers     []*models.Provider `json:"providers"`
-	Model         *SelectedModel     `json:"model"`
-	MaxIterations int                `json:"max_iterations"`
+	Providers            []*models.Provider           `json:"providers"`
+	Model                *SelectedModel               `json:"model"`
+	MaxIterations        int                          `json:"max_iterations"` // model requests in a turn before asking whether to continue (0 = unlimited)
+	TurnBudget           TurnBudgetConfig             `json:"turn_budget"`
+	Share                ShareConfig                  `json:"share"`
+	Checkpoints          bool                         `json:"checkpoints"` // snapshot the git working tree before turns that modify files
+	Budget               BudgetConfig                 `json:"budget"`
+	Preview              PreviewConfig                `json:"preview"`
+	HeartbeatSeconds     int                          `json:"heartbeat_seconds"` // how often progress events are emitted during a turn (default 10)
+	LSP                  map[string][]string          `json:"lsp"`               // language server commands by language (go, python, typescript)
+	Churn                ChurnConfig                  `json:"churn"`
+	Index                IndexConfig                  `json:"index"`
+	Diagrams             DiagramConfig                `json:"diagrams"`
+	Watchdog             WatchdogConfig               `json:"watchdog"`
+	Docs                 tools.DocsProvider           `json:"docs"`
+	Deps                 DepsConfig                   `json:"deps"`
+	Sandbox              tools.SandboxConfig          `json:"sandbox"`
+	Security             SecurityConfig               `json:"security"`
+	Miniagents           MiniagentConfig              `json:"miniagents"`
+	Permissions          []string                     `json:"permissions"`     // rules like "edit_file:./src/**", "shell:git *", "deny shell:rm *"
+	Hooks                []hooks.Rule                 `json:"hooks"`           // commands or Go hooks run before and after matching tool calls
+	ShellHistory         int                          `json:"shell_history"`   // shell commands summarized in the system prompt (default 10, -1 disables)
+	PromptSections       map[string]bool              `json:"prompt_sections"` // system prompt sections to turn off, e.g. {"git": false}; all are on by default
+	Personas             map[string]PersonaConfig     `json:"personas"`        // roles to switch to with /persona
+	ShellOutput          tools.ShellOutputConfig      `json:"shell_output"`
+	ShellEnv             tools.ShellEnvConfig         `json:"shell_env"`
+	ShellInteractive     tools.ShellInteractiveConfig `json:"shell_interactive"`
+	ToolResults          ResultBudgetConfig           `json:"tool_results"`
+	Highlight            HighlightConfig              `json:"highlight"`
+	Format               tools.FormatConfig           `json:"format"`
+	Attachments          AttachmentsConfig            `json:"attachments"`
+	Filters              FilterConfig                 `json:"filters"`
+	Redaction            RedactionConfig              `json:"redaction"`
+	Sessions             SessionsConfig               `json:"sessions"`
+	LiveContextPlacement string                       `json:"live_context_placement"` // "system" (default) or "message" to send live context after the history
+	Startup              StartupConfig                `json:"startup"`
+	IgnorePatterns       []string                     `json:"ignore_patterns"`  // names skipped in live context directory structures, e.g. "dist"
+	DefaultIgnores       []string                     `json:"default_ignores"`  // replace DefaultIgnorePatterns when set; [] skips nothing by default
+	IncludeHidden        *bool                        `json:"include_hidden"`   // list dot-prefixed entries in directory structures (default true)
+	MaxFileSize          int                          `json:"max_file_size"`    // bytes of one live context file sent in full (default 256KB, -1 for no limit)
+	SeedGitChanges       bool                         `json:"seed_git_changes"` // add files with uncommitted changes to live context at startup and after /clear
+	Tracing              tracing.Config               `json:"tracing"`
+}
+
+// PreviewConfig controls how much of large tool arguments and results is echoed to the terminal.
+// Zero values use the defaults (30 lines before previewing, showing the first 10 and last 5).
+type PreviewConfig struct {
+	MaxLines  int `json:"max_lines"`
+	HeadLines int `json:"head_lines"`
+	TailLines int `json:"tail_lines"`
+}
+
+// HighlightConfig controls syntax highlighting of code blocks and diffs. Highlighting is always off
+// when the terminal can't show colors.
+type HighlightConfig struct {
+	Disabled bool   `json:"disabled"`
+	Style    string `json:"style"` // chroma style name, e.g. "dracula"; defaults to one matching the theme
+}
+
+// BudgetConfig splits the request size between the system instructions, live context, and history.
+// Ratios default to 10% system, 40% live context, and 50% history when left at zero.
+type BudgetConfig struct {
+	TotalChars  int     `json:"total_chars"` // 0 disables budget enforcement
+	System      float64 `json:"system"`
+	LiveContext float64 `json:"live_context"`
+	History     float64 `json:"history"`
+}
+
+// Limits returns the character budgets for system instructions, live context, and history.
+// All limits are zero when budgeting is disabled.
+func (b BudgetConfig) Limits() (int, int, int) {
+	if b.TotalChars <= 0 {
+		return 0, 0, 0
+	}
+	system, liveContext, history := b.Ratios()
+	total := float64(b.TotalChars)
+	return int(total * system), int(total * liveContext), int(total * history)
+}
+
+// Ratios returns the fractions of the budget for system instructions, live context, and history,
+// with each one that isn't set taking its default of 0.1, 0.4, or 0.5
+func (b BudgetConfig) Ratios() (float64, float64, float64) {
+	ratio := func(configured, fallback float64) float64 {
+		if configured == 0 {
+			return fallback
+		}
+		return configured
+	}
+	return ratio(b.System, 0.1), ratio(b.LiveContext, 0.4), ratio(b.History, 0.5)
 }
 
 // SelectedModel represents the currently selected model
@@ -45,11 +133,12 @@ func getConfigPath() (string, error) {
 	return filepath.Join(agentDir, configFileName), nil
 }
 
-// LoadConfig loads the configuration from file, creating defaults if it doesn't exist or is corrupted
-func LoadConfig() *Config {
+// LoadConfig loads the configuration from file, creating defaults if it doesn't exist or is corrupted.
+// Problems with the file are returned as warnings to print at startup.
+func LoadConfig() (*Config, []string) {
 	configPath, err := getConfigPath()
 	if err != nil {
-		return createDefaultConfig()
+		return createDefaultConfig(), nil
 	}
 
 	// Check if config file exists
@@ -57,22 +146,21 @@ func LoadConfig() *Config {
 		// Create default config
 		config := createDefaultConfig()
 		SaveConfig(config)
-		return config
+		return config, nil
 	}
 
 	// Read existing config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return createDefaultConfig()
+		return createDefaultConfig(), nil
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		fmt.Println(theme.WarningText("Warning: Config file is corrupted"))
-		return createDefaultConfig()
+	config, problems, err := ParseConfig(data)
+	if err != nil {
+		return createDefaultConfig(), []string{fmt.Sprintf("Warning: %s is not valid (%v); using the default config until it is fixed", configPath, err)}
 	}
 
-	return &config
+	return config, configWarnings(problems)
 }
 
 // SaveConfig saves the configuration to file
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetLimits(t *testing.T) {
+	cases := []struct {
+		name                         string
+		budget                       BudgetConfig
+		system, liveContext, history int
+	}{
+		{"disabled", BudgetConfig{System: 0.5, LiveContext: 0.5}, 0, 0, 0},
+		{"default split", BudgetConfig{TotalChars: 10000}, 1000, 4000, 5000},
+		{"configured split", BudgetConfig{TotalChars: 10000, System: 0.2, LiveContext: 0.3, History: 0.5}, 2000, 3000, 5000},
+		{"system set", BudgetConfig{TotalChars: 10000, System: 0.05}, 500, 4000, 5000},
+		{"live context set", BudgetConfig{TotalChars: 10000, LiveContext: 0.2}, 1000, 2000, 5000},
+		{"history set", BudgetConfig{TotalChars: 10000, History: 0.3}, 1000, 4000, 3000},
+		{"two set", BudgetConfig{TotalChars: 10000, System: 0.2, History: 0.4}, 2000, 4000, 4000},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			system, liveContext, history := c.budget.Limits()
+			assert.Equal(t, c.system, system)
+			assert.Equal(t, c.liveContext, liveContext)
+			assert.Equal(t, c.history, history)
+		})
+	}
+}
+
+func TestBudgetValidation(t *testing.T) {
+	cases := []struct {
+		name    string
+		budget  BudgetConfig
+		problem string
+	}{
+		{"defaults", BudgetConfig{TotalChars: 10000}, ""},
+		{"configured split", BudgetConfig{System: 0.2, LiveContext: 0.3, History: 0.5}, ""},
+		{"under 1", BudgetConfig{System: 0.05, LiveContext: 0.2}, ""},
+		{"over 1", BudgetConfig{System: 0.3, LiveContext: 0.4, History: 0.5}, "budget: system, live_context, and history add up to 1.2"},
+		{"over 1 with defaults", BudgetConfig{History: 0.7}, "budget: system, live_context, and history add up to 1.2"},
+		{"negative", BudgetConfig{System: -0.1}, "budget.system: must be a fraction between 0 and 1, got -0.1"},
+		{"over 1 alone", BudgetConfig{History: 1.5}, "budget.history: must be a fraction between 0 and 1, got 1.5"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var messages []string
+			for _, problem := range validateConfig(&Config{Budget: c.budget}) {
+				if strings.HasPrefix(problem.Path, "budget") {
+					messages = append(messages, problem.String())
+				}
+			}
+			if c.problem == "" {
+				assert.Empty(t, messages)
+				return
+			}
+			require.NotEmpty(t, messages)
+			assert.True(t, strings.HasPrefix(messages[0], c.problem), messages[0])
+		})
+	}
+}
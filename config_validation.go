@@ -0,0 +1,573 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"agent/theme"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxStopSequences is the most stop sequences OpenAI-compatible APIs accept
+const maxStopSequences = 4
+
+// ConfigProblem is a config value that is invalid or not recognized
+type ConfigProblem struct {
+	Path    string // e.g. providers[0].models[1].config.temperature
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ParseConfig decodes and validates config JSON. A JSON syntax error is returned as an error with its
+// line and column; everything else is reported as problems alongside the decoded config.
+func ParseConfig(data []byte) (*Config, []ConfigProblem, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := position(data, syntaxErr.Offset)
+			return nil, nil, fmt.Errorf("line %d, column %d: %v", line, column, err)
+		}
+		return nil, nil, err
+	}
+	if _, ok := raw.(map[string]interface{}); !ok {
+		return nil, nil, fmt.Errorf("the config must be a JSON object")
+	}
+
+	problems := unknownFields(raw, reflect.TypeOf(Config{}), "")
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, err
+		}
+		// Decoding carries on past a type error, so the rest of the config is still usable
+		problems = append(problems, ConfigProblem{typeErr.Field, fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)})
+	}
+
+	return &config, append(problems, validateConfig(&config)...), nil
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	before := data[:min(int(offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// unknownFields reports keys in raw that don't match a field of t, suggesting a close match
+func unknownFields(raw interface{}, t reflect.Type, path string) []ConfigProblem {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var problems []ConfigProblem
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldPath := joinPath(path, key)
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				message := "unknown field"
+				if suggestion := closestField(key, fields); suggestion != "" {
+					message += fmt.Sprintf("; did you mean %q?", suggestion)
+				}
+				problems = append(problems, ConfigProblem{fieldPath, message})
+				continue
+			}
+			problems = append(problems, unknownFields(object[key], field.Type, fieldPath)...)
+		}
+	case reflect.Slice:
+		if list, ok := raw.([]interface{}); ok {
+			for i, item := range list {
+				problems = append(problems, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case reflect.Map:
+		if object, ok := raw.(map[string]interface{}); ok {
+			for key, value := range object {
+				problems = append(problems, unknownFields(value, t.Elem(), joinPath(path, key))...)
+			}
+		}
+	}
+	return problems
+}
+
+// jsonFields maps the lowercased JSON names of a struct's fields, as encoding/json matches them
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field
+	}
+	return fields
+}
+
+// closestField returns the field name within two edits of key, if any
+func closestField(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for name, field := range fields {
+		if distance := editDistance(strings.ToLower(key), name); distance < bestDistance {
+			best, bestDistance = name, distance
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" {
+				best = tag
+			}
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validateConfig checks values that decode fine but can't work
+func validateConfig(config *Config) []ConfigProblem {
+	var problems []ConfigProblem
+	add := func(path, format string, args ...interface{}) {
+		problems = append(problems, ConfigProblem{path, fmt.Sprintf(format, args...)})
+	}
+
+	if config.MaxIterations < 0 {
+		add("max_iterations", "must be 0 or more, got %d", config.MaxIterations)
+	}
+	if config.TurnBudget.MaxTokens < 0 {
+		add("turn_budget.max_tokens", "must be 0 (unlimited) or more, got %d", config.TurnBudget.MaxTokens)
+	}
+	if config.TurnBudget.MaxCost < 0 {
+		add("turn_budget.max_cost", "must be 0 (unlimited) or more, got %g", config.TurnBudget.MaxCost)
+	}
+	if config.Budget.TotalChars < 0 {
+		add("budget.total_chars", "must be 0 (disabled) or more, got %d", config.Budget.TotalChars)
+	}
+	ratios := map[string]float64{"system": config.Budget.System, "live_context": config.Budget.LiveContext, "history": config.Budget.History}
+	for _, name := range []string{"system", "live_context", "history"} {
+		if ratios[name] < 0 || ratios[name] > 1 {
+			add("budget."+name, "must be a fraction between 0 and 1, got %g", ratios[name])
+		}
+	}
+	// Unset fractions take their defaults, so they count toward the sum too
+	if system, liveContext, history := config.Budget.Ratios(); system+liveContext+history > 1.0001 {
+		add("budget", "system, live_context, and history add up to %g (unset ones default to 0.1, 0.4, and 0.5); they must not exceed 1", system+liveContext+history)
+	}
+	for name, patterns := range map[string][]string{"ignore_patterns": config.IgnorePatterns, "default_ignores": config.DefaultIgnores, "shell_env.allow": config.ShellEnv.Allow, "shell_env.deny": config.ShellEnv.Deny} {
+		for i, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				add(fmt.Sprintf("%s[%d]", name, i), "%q is not a valid glob pattern", pattern)
+			}
+		}
+	}
+	for name, value := range map[string]int{"head_lines": config.ShellOutput.HeadLines, "tail_lines": config.ShellOutput.TailLines, "summarize_over": config.ShellOutput.SummarizeOver} {
+		if value < 0 {
+			add("shell_output."+name, "must be 0 (default) or more, got %d", value)
+		}
+	}
+	for name, value := range map[string]int{"max_chars": config.ToolResults.MaxChars, "max_turn_chars": config.ToolResults.MaxTurnChars} {
+		if value < 0 {
+			add("tool_results."+name, "must be 0 (default) or more, got %d", value)
+		}
+	}
+	for tool, value := range config.ToolResults.PerTool {
+		if value <= 0 {
+			add("tool_results.per_tool."+tool, "must be more than 0, got %d", value)
+		}
+	}
+	if config.ShellInteractive.PromptTimeoutSeconds < 0 {
+		add("shell_interactive.prompt_timeout_seconds", "must be 0 (default) or more, got %d", config.ShellInteractive.PromptTimeoutSeconds)
+	}
+	if config.ShellInteractive.TimeoutSeconds < 0 {
+		add("shell_interactive.timeout_seconds", "must be 0 (default) or more, got %d", config.ShellInteractive.TimeoutSeconds)
+	}
+	for i, root := range config.Security.AllowedRoots {
+		if strings.TrimSpace(root) == "" {
+			add(fmt.Sprintf("security.allowed_roots[%d]", i), "is empty; name a directory")
+		}
+	}
+	for name, value := range map[string]int{"max_sessions": config.Sessions.MaxSessions, "max_age_days": config.Sessions.MaxAgeDays} {
+		if value < 0 {
+			add("sessions."+name, "must be 0 (keep all) or more, got %d", value)
+		}
+	}
+	for i, pattern := range config.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add(fmt.Sprintf("redaction.patterns[%d]", i), "is not a valid regex: %v", err)
+		}
+	}
+	for name := range config.PromptSections {
+		if !slices.ContainsFunc(promptProviders, func(provider promptProvider) bool { return provider.Name == name }) {
+			add("prompt_sections."+name, "is not a system prompt section; /prompt lists them")
+		}
+	}
+	for i, rule := range config.Hooks {
+		if err := rule.Validate(); err != nil {
+			add(fmt.Sprintf("hooks[%d]", i), "%v", err)
+		}
+	}
+	if placement := config.LiveContextPlacement; placement != "" && placement != PlacementSystem && placement != PlacementMessage {
+		add("live_context_placement", "must be %q or %q, got %q", PlacementSystem, PlacementMessage, placement)
+	}
+
+	providerIDs := make(map[string]bool)
+	for i, provider := range config.Providers {
+		path := fmt.Sprintf("providers[%d]", i)
+		if provider == nil {
+			add(path, "must be an object")
+			continue
+		}
+		if provider.ID == "" {
+			add(path+".id", "missing; requests and /model refer to providers by id")
+		} else if providerIDs[provider.ID] {
+			add(path+".id", "duplicate provider id %q", provider.ID)
+		}
+		providerIDs[provider.ID] = true
+		if provider.BaseURL == "" {
+			add(path+".base_url", "missing; set the provider's OpenAI-compatible endpoint, e.g. https://api.openai.com/v1")
+		} else if u, err := url.Parse(provider.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(path+".base_url", "%q is not an http(s) URL", provider.BaseURL)
+		}
+
+		modelIDs := make(map[string]bool)
+		for j, model := range provider.Models {
+			modelPath := fmt.Sprintf("%s.models[%d]", path, j)
+			if model == nil {
+				add(modelPath, "must be an object")
+				continue
+			}
+			if model.ID == "" {
+				add(modelPath+".id", "missing; set the model name the provider expects")
+			} else if modelIDs[model.ID] {
+				add(modelPath+".id", "duplicate model id %q", model.ID)
+			}
+			modelIDs[model.ID] = true
+			if t := model.Config.Temperature; t < 0 || t > 2 {
+				add(modelPath+".config.temperature", "must be between 0 and 2, got %g", t)
+			}
+			if p := model.Config.TopP; p < 0 || p > 1 {
+				add(modelPath+".config.top_p", "must be between 0 and 1, got %g", p)
+			}
+			if len(model.Config.Stop) > maxStopSequences {
+				add(modelPath+".config.stop", "has %d sequences; providers accept at most %d", len(model.Config.Stop), maxStopSequences)
+			}
+			for k, stop := range model.Config.Stop {
+				if stop == "" {
+					add(fmt.Sprintf("%s.config.stop[%d]", modelPath, k), "is empty")
+				}
+			}
+			for name, penalty := range map[string]*float64{"frequency_penalty": model.Config.FrequencyPenalty, "presence_penalty": model.Config.PresencePenalty} {
+				if penalty != nil && (*penalty < -2 || *penalty > 2) {
+					add(modelPath+".config."+name, "must be between -2 and 2, got %g", *penalty)
+				}
+			}
+			if model.Config.MaxTokens <= 0 {
+				add(modelPath+".config.max_tokens", "must be more than 0, got %d", model.Config.MaxTokens)
+			}
+			if window := model.Config.ContextWindow; window < 0 {
+				add(modelPath+".config.context_window", "must be 0 (unknown) or more, got %d", window)
+			} else if window > 0 && model.Config.MaxTokens >= window {
+				add(modelPath+".config.max_tokens", "%d leaves no room for the prompt in the %d token context window", model.Config.MaxTokens, window)
+			}
+			if pricing := model.Config.Pricing; pricing != nil && (pricing.Input < 0 || pricing.CachedInput < 0 || pricing.Output < 0) {
+				add(modelPath+".config.pricing", "prices can't be negative")
+			}
+			switch model.PromptCache {
+			case api.CacheAuto, api.CacheControl, api.CacheKey, api.CacheOff:
+			default:
+				add(modelPath+".prompt_cache", "must be %q, %q, or %q, got %q", api.CacheControl, api.CacheKey, api.CacheOff, model.PromptCache)
+			}
+		}
+	}
+
+	for name, persona := range config.Personas {
+		if name == "off" || strings.ContainsAny(name, " \t") {
+			add("personas."+name, "is not a usable persona name; /persona %s can't select it", name)
+		}
+		if persona.Model != nil && !hasModel(config, persona.Model.Provider, persona.Model.Model) {
+			add("personas."+name+".model", "%s:%s is not one of the configured providers' models", persona.Model.Provider, persona.Model.Model)
+		}
+	}
+
+	if config.Model != nil {
+		if !hasModel(config, config.Model.Provider, config.Model.Model) {
+			add("model", "%s:%s is not one of the configured providers' models", config.Model.Provider, config.Model.Model)
+		}
+		for i, provider := range config.Providers {
+			if provider == nil || provider.ID != config.Model.Provider || !strings.HasPrefix(provider.APIKey, api.EnvKeyPrefix) {
+				continue
+			}
+			if envVar := strings.TrimPrefix(provider.APIKey, api.EnvKeyPrefix); os.Getenv(envVar) == "" {
+				add(fmt.Sprintf("providers[%d].api_key", i), "the environment variable %s is not set", envVar)
+			}
+		}
+	}
+	return problems
+}
+
+func findProvider(config *Config, id string) *models.Provider {
+	for _, provider := range config.Providers {
+		if provider != nil && provider.ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+func hasModel(config *Config, providerID, modelID string) bool {
+	provider := findProvider(config, providerID)
+	if provider == nil {
+		return false
+	}
+	for _, model := range provider.Models {
+		if model != nil && model.ID == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// setConfigValue sets the value at a dotted path in raw config JSON. Array elements are addressed by
+// index or, for objects with an id, by that id (providers.openai.base_url). value is parsed as JSON
+// when it is valid JSON and used as a string otherwise.
+func setConfigValue(data []byte, path, value string) ([]byte, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+
+	keys := strings.Split(path, ".")
+	var node interface{} = root
+	for i, key := range keys {
+		last := i == len(keys)-1
+		switch current := node.(type) {
+		case map[string]interface{}:
+			if last {
+				current[key] = parsed
+				break
+			}
+			next, ok := current[key]
+			if !ok || next == nil {
+				next = make(map[string]interface{})
+				current[key] = next
+			}
+			node = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil {
+				index = indexByID(current, key)
+			}
+			if index < 0 || index >= len(current) {
+				return nil, fmt.Errorf("%s: no element %q", strings.Join(keys[:i], "."), key)
+			}
+			if last {
+				current[index] = parsed
+				break
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("%s is not an object or list", strings.Join(keys[:i], "."))
+		}
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func indexByID(list []interface{}, id string) int {
+	for i, item := range list {
+		if object, ok := item.(map[string]interface{}); ok && object["id"] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupConfigValue returns the value at a dotted path in decoded config JSON, addressed like setConfigValue
+func lookupConfigValue(root interface{}, path string) (interface{}, bool) {
+	node := root
+	for _, key := range strings.Split(path, ".") {
+		switch current := node.(type) {
+		case map[string]interface{}:
+			next, ok := current[key]
+			if !ok {
+				return nil, false
+			}
+			node = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil {
+				index = indexByID(current, key)
+			}
+			if index < 0 || index >= len(current) {
+				return nil, false
+			}
+			node = current[index]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// maskAPIKeys hides API keys written into the config, leaving env:, keychain:, and cmd: references readable
+func maskAPIKeys(node interface{}) {
+	switch current := node.(type) {
+	case map[string]interface{}:
+		for key, value := range current {
+			if key == "api_key" {
+				if apiKey, ok := value.(string); ok && apiKey != "" && !api.IsKeyReference(apiKey) {
+					current[key] = "****" + apiKey[max(0, len(apiKey)-4):]
+				}
+				continue
+			}
+			maskAPIKeys(value)
+		}
+	case []interface{}:
+		for _, item := range current {
+			maskAPIKeys(item)
+		}
+	}
+}
+
+func handleConfig(a *Agent, args []string) string {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to read %s: %v", configPath, err))
+	}
+
+	if len(args) == 0 || args[0] == "get" {
+		var root interface{}
+		if err := json.Unmarshal(data, &root); err != nil {
+			_, _, err := ParseConfig(data)
+			return theme.ErrorText(fmt.Sprintf("%s is not valid JSON: %v", configPath, err))
+		}
+		maskAPIKeys(root)
+		if len(args) > 1 {
+			value, ok := lookupConfigValue(root, args[1])
+			if !ok {
+				return theme.ErrorText(fmt.Sprintf("%s is not set", args[1]))
+			}
+			root = value
+		}
+		shown, _ := json.MarshalIndent(root, "", "  ")
+		if len(args) > 1 {
+			return theme.InfoText(string(shown))
+		}
+
+		var result strings.Builder
+		result.WriteString(theme.InfoText(configPath) + "\n")
+		if _, err := os.Stat(projectConfigPath); err == nil {
+			result.WriteString(theme.InfoText(fmt.Sprintf("Overlaid by %s in this project; /config set changes the global config", projectConfigPath)) + "\n")
+		}
+		result.WriteString(theme.InfoText(string(shown)) + "\n\n")
+		_, problems, _ := ParseConfig(data)
+		if len(problems) == 0 {
+			result.WriteString(theme.SuccessText("No problems found"))
+		}
+		for _, problem := range problems {
+			result.WriteString(theme.WarningText("⚠ "+problem.String()) + "\n")
+		}
+		return strings.TrimRight(result.String(), "\n")
+	}
+
+	if args[0] != "set" || len(args) < 3 {
+		return theme.ErrorText("Usage: /config [get <path>|set <path> <value>]")
+	}
+	path, value := args[1], strings.Join(args[2:], " ")
+	updated, err := setConfigValue(data, path, value)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to set %s: %v", path, err))
+	}
+	config, problems, err := ParseConfig(updated)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to set %s: %v", path, err))
+	}
+	// Problems the config already had don't block the change, only ones it introduces
+	existing := make(map[string]bool)
+	if _, before, err := ParseConfig(data); err == nil {
+		for _, problem := range before {
+			existing[problem.String()] = true
+		}
+	}
+	for _, problem := range problems {
+		if !existing[problem.String()] {
+			return theme.ErrorText(fmt.Sprintf("Not saved: %s", problem))
+		}
+	}
+
+	if err := SaveConfig(config); err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	config, _ = applyProjectConfig(config)
+	*a.config = *config
+	a.applyConfig()
+	if config.Model != nil {
+		if err := a.switchProvider(config.Model.Provider, config.Model.Model); err != nil {
+			return theme.ErrorText(fmt.Sprintf("Saved %s, but the selected model is unavailable: %v", path, err))
+		}
+	}
+	return theme.SuccessText(fmt.Sprintf("Set %s to %s. Settings read at startup, such as language servers and the sandbox, apply from the next session.", path, value))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test")
+	config, problems, err := ParseConfig(defaultConfigJSON)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.NotEmpty(t, config.Providers)
+}
+
+func TestParseConfigProblems(t *testing.T) {
+	_, _, err := ParseConfig([]byte("{\n  \"max_iterations\": 10,\n  \"budget\": {\n}"))
+	assert.ErrorContains(t, err, "line 4, column 2")
+
+	config, problems, err := ParseConfig([]byte(`{
+		"max_iteration": 20,
+		"budget": {"total_chars": "lots"},
+		"prompt_sections": {"gti": false},
+		"providers": [
+			{"id": "local", "base_url": "localhost:8080", "models": [
+				{"id": "qwen", "config": {"max_tokens": 1024, "temprature": 0.2, "temperature": 3, "stop": ["###", ""], "presence_penalty": 2.5}}
+			]},
+			{"id": "openai", "models": []}
+		],
+		"model": {"provider": "local", "model": "llama"}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "qwen", config.Providers[0].Models[0].ID)
+
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.String())
+	}
+	assert.Contains(t, messages, `max_iteration: unknown field; did you mean "max_iterations"?`)
+	assert.Contains(t, messages, `providers[0].models[0].config.temprature: unknown field; did you mean "temperature"?`)
+	assert.Contains(t, messages, "budget.total_chars: expected int, got string")
+	assert.Contains(t, messages, `providers[0].base_url: "localhost:8080" is not an http(s) URL`)
+	assert.Contains(t, messages, "providers[0].models[0].config.temperature: must be between 0 and 2, got 3")
+	assert.Contains(t, messages, "providers[1].base_url: missing; set the provider's OpenAI-compatible endpoint, e.g. https://api.openai.com/v1")
+	assert.Contains(t, messages, "model: local:llama is not one of the configured providers' models")
+	assert.Contains(t, messages, "prompt_sections.gti: is not a system prompt section; /prompt lists them")
+	assert.Contains(t, messages, "providers[0].models[0].config.stop[1]: is empty")
+	assert.Contains(t, messages, "providers[0].models[0].config.presence_penalty: must be between -2 and 2, got 2.5")
+}
+
+func TestSetConfigValue(t *testing.T) {
+	data := []byte(`{"max_iterations": 10, "providers": [{"id": "openai", "base_url": "https://api.openai.com/v1"}]}`)
+
+	updated, err := setConfigValue(data, "max_iterations", "20")
+	assert.NoError(t, err)
+	updated, err = setConfigValue(updated, "providers.openai.base_url", "https://proxy.example.com/v1")
+	assert.NoError(t, err)
+	updated, err = setConfigValue(updated, "budget.total_chars", "400000")
+	assert.NoError(t, err)
+
+	var config Config
+	assert.NoError(t, json.Unmarshal(updated, &config))
+	assert.Equal(t, 20, config.MaxIterations)
+	assert.Equal(t, "https://proxy.example.com/v1", config.Providers[0].BaseURL)
+	assert.Equal(t, 400000, config.Budget.TotalChars)
+
+	_, err = setConfigValue(data, "providers.anthropic.base_url", "https://api.anthropic.com")
+	assert.ErrorContains(t, err, `no element "anthropic"`)
+	_, err = setConfigValue(data, "max_iterations.limit", "5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"agent/api"
+	"agent/filters"
+	"agent/logging"
+	"agent/models"
+	"fmt"
+	"time"
+)
+
+// FilterConfig chooses the content filters every request passes through before it is sent
+type FilterConfig struct {
+	Builtin []string       `json:"builtin"` // registered filters: "pii", "paths", or custom Go hooks
+	Rules   []filters.Rule `json:"rules"`   // regex filters that replace or block matches
+}
+
+// FilterAuditRecord logs what a content filter changed in a request, without the removed content
+type FilterAuditRecord struct {
+	Type string    `json:"type"` // always "filter"
+	Time time.Time `json:"time"`
+	filters.Record
+}
+
+// newFilterChain builds the configured filters, skipping unknown or invalid ones, after the secrets
+// redactor when there is one
+func newFilterChain(cfg FilterConfig, secrets *filters.Secrets) *filters.Chain {
+	var chain []filters.Filter
+	if secrets != nil {
+		chain = append(chain, secrets)
+	}
+	for _, name := range cfg.Builtin {
+		filter, ok := filters.Lookup(name)
+		if !ok {
+			logging.Warnf("Ignoring unknown content filter %q (available: %v)", name, filters.Registered())
+			continue
+		}
+		chain = append(chain, filter)
+	}
+	for _, rule := range cfg.Rules {
+		filter, err := filters.NewRegexFilter(rule)
+		if err != nil {
+			logging.Warnf("Ignoring content filter: %v", err)
+			continue
+		}
+		chain = append(chain, filter)
+	}
+	return filters.NewChain(chain...)
+}
+
+// installContentFilters routes every outbound request through the secrets redactor and the configured
+// filters and records their changes in the session log
+func (a *Agent) installContentFilters() {
+	chain := newFilterChain(a.config.Filters, a.redactor)
+	if chain.Len() == 0 {
+		api.SetOutboundFilter(nil)
+		return
+	}
+	api.SetOutboundFilter(a.outboundFilter(chain))
+}
+
+// outboundFilter runs the system prompt, message contents, and tool call arguments through chain
+func (a *Agent) outboundFilter(chain *filters.Chain) api.OutboundFilter {
+	return func(systemPrompt string, messages []models.Message) (string, []models.Message, error) {
+		filtered := make([]models.Message, len(messages))
+		copy(filtered, messages)
+		parts := []filters.Part{{Source: "system prompt", Content: &systemPrompt}}
+		for i := range filtered {
+			source := fmt.Sprintf("message %d (%s)", i+1, filtered[i].Role)
+			parts = append(parts, filters.Part{Source: source, Content: &filtered[i].Content})
+			if len(filtered[i].ToolCalls) == 0 {
+				continue
+			}
+			// The history's tool calls are shared, so filter copies
+			filtered[i].ToolCalls = append([]models.ToolCall(nil), filtered[i].ToolCalls...)
+			for j := range filtered[i].ToolCalls {
+				call := &filtered[i].ToolCalls[j].Function
+				parts = append(parts, filters.Part{Source: fmt.Sprintf("%s %s call arguments", source, call.Name), Content: &call.Arguments})
+			}
+		}
+
+		records, err := chain.Apply(parts)
+		for _, record := range records {
+			a.sessionLogger.LogRecord(FilterAuditRecord{Type: "filter", Time: time.Now(), Record: record})
+		}
+		return systemPrompt, filtered, err
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"agent/tools"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxSkippedLabels caps how many omitted symbols are listed in one note
+const maxSkippedLabels = 20
+
+// fileChunk is a run of lines holding one symbol, or the code between symbols when label is empty
+type fileChunk struct {
+	start, end int // 1-based, inclusive
+	label      string
+	children   []fileChunk // chunks of the symbols nested inside, used when the whole symbol doesn't fit
+}
+
+// readFileChunks renders as much of a live-context file as fits in budget characters, split along
+// the functions, types, and classes tree-sitter finds rather than at an arbitrary line. Included
+// chunks are labeled with their enclosing symbol and skipped ones are listed by signature, so the
+// model still sees the file's structure. ok is false when the language isn't supported or no
+// chunk fits.
+func readFileChunks(fileInfo FileInfo, budget int) (string, bool) {
+	content, err := os.ReadFile(fileInfo.Path)
+	if err != nil || budget <= 0 {
+		return "", false
+	}
+	symbols, err := tools.Outline(context.Background(), fileInfo.Path, content)
+	if err != nil {
+		return "", false
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start, end, err := lineRange(fileInfo, len(lines))
+	if err != nil {
+		return "", false
+	}
+
+	var sections []string
+	var skipped []fileChunk
+	used, included := 0, 0
+	flushSkipped := func() {
+		if len(skipped) == 0 {
+			return
+		}
+		note := skippedNote(skipped)
+		sections = append(sections, note)
+		used += len(note) + 1
+		skipped = nil
+	}
+
+	var fit func(chunks []fileChunk)
+	fit = func(chunks []fileChunk) {
+		for _, chunk := range chunks {
+			chunk.start, chunk.end = max(chunk.start, start), min(chunk.end, end)
+			if chunk.start > chunk.end {
+				continue
+			}
+			text := renderChunk(chunk, lines)
+			// Leave room for a note about the chunks after this one
+			if used+len(text)+1 <= budget-len(skippedNote(skipped))-80 {
+				flushSkipped()
+				sections = append(sections, text)
+				used += len(text) + 1
+				included++
+			} else if len(chunk.children) > 0 {
+				fit(chunk.children)
+			} else {
+				skipped = append(skipped, chunk)
+			}
+		}
+	}
+	fit(syntaxChunks(symbols, lines, 0, 1, len(lines), ""))
+	flushSkipped()
+
+	if included == 0 || used > budget {
+		return "", false
+	}
+	return strings.Join(sections, "\n"), true
+}
+
+// syntaxChunks splits lines from..to into one chunk per symbol at depth, with the code between
+// symbols in unlabeled chunks, skipping blank runs. Comments and decorators directly above a symbol stay with it.
+func syntaxChunks(symbols []tools.OutlineSymbol, lines []string, depth, from, to int, parent string) []fileChunk {
+	var chunks []fileChunk
+	cursor := from
+	for i, symbol := range symbols {
+		if symbol.Depth != depth || symbol.StartLine < from || symbol.EndLine > to {
+			continue
+		}
+		start := symbol.StartLine
+		for start > cursor && strings.TrimSpace(lines[start-2]) != "" {
+			start--
+		}
+		if start > cursor && !blankLines(lines, cursor, start-1) {
+			chunks = append(chunks, fileChunk{start: cursor, end: start - 1, label: parent})
+		}
+
+		label := symbol.Signature
+		if parent != "" {
+			label = parent + " > " + label
+		}
+		chunk := fileChunk{start: start, end: symbol.EndLine, label: label}
+		if i+1 < len(symbols) && symbols[i+1].Depth > depth {
+			chunk.children = syntaxChunks(symbols[i+1:], lines, depth+1, start, symbol.EndLine, label)
+		}
+		chunks = append(chunks, chunk)
+		cursor = symbol.EndLine + 1
+	}
+	if cursor <= to && !blankLines(lines, cursor, to) {
+		chunks = append(chunks, fileChunk{start: cursor, end: to, label: parent})
+	}
+	return chunks
+}
+
+// blankLines reports whether lines start..end hold only whitespace
+func blankLines(lines []string, start, end int) bool {
+	return strings.TrimSpace(strings.Join(lines[start-1:end], "")) == ""
+}
+
+// renderChunk formats a chunk with a header naming its symbol and numbered lines
+func renderChunk(chunk fileChunk, lines []string) string {
+	header := fmt.Sprintf("@@ lines %d-%d @@", chunk.start, chunk.end)
+	if chunk.label != "" {
+		header = fmt.Sprintf("@@ lines %d-%d: %s @@", chunk.start, chunk.end, chunk.label)
+	}
+	numbered := []string{header}
+	for i := chunk.start; i <= chunk.end; i++ {
+		line := lines[i-1]
+		if len(line) > 2000 {
+			line = line[:2000] + "..."
+		}
+		numbered = append(numbered, fmt.Sprintf("%d: %s", i, line))
+	}
+	return strings.Join(numbered, "\n")
+}
+
+// skippedNote summarizes a run of chunks left out of the budget
+func skippedNote(chunks []fileChunk) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+	var labels []string
+	for _, chunk := range chunks {
+		if chunk.label != "" {
+			labels = append(labels, chunk.label)
+		}
+	}
+	if len(labels) > maxSkippedLabels {
+		labels = append(labels[:maxSkippedLabels], fmt.Sprintf("%d more", len(labels)-maxSkippedLabels))
+	}
+	note := fmt.Sprintf("@@ lines %d-%d omitted", chunks[0].start, chunks[len(chunks)-1].end)
+	if len(labels) > 0 {
+		note += ": " + strings.Join(labels, "; ")
+	}
+	return note + " @@"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const chunkFixture = `package store
+
+import "strings"
+
+// Normalize lowercases a key
+func Normalize(key string) string {
+	return strings.ToLower(key)
+}
+
+type Store struct {
+	items map[string]int
+}
+
+func (s *Store) Load(path string) error {
+FILLER	return nil
+}
+`
+
+func TestReadFileChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.go")
+	content := strings.Replace(chunkFixture, "FILLER", strings.Repeat("\t_ = path // filler to push Load over budget\n", 20), 1)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	chunked, ok := readFileChunks(FileInfo{Path: path, StartLine: 1}, 400)
+	assert.True(t, ok)
+	assert.Contains(t, chunked, "@@ lines 1-4 @@\n1: package store")
+	assert.Contains(t, chunked, "@@ lines 5-8: func Normalize(key string) string @@\n5: // Normalize lowercases a key")
+	assert.Contains(t, chunked, "@@ lines 10-12: type Store struct @@")
+	assert.Contains(t, chunked, "omitted: func (s *Store) Load(path string) error @@")
+	assert.NotContains(t, chunked, "filler")
+	assert.LessOrEqual(t, len(chunked), 400)
+
+	// A range limits the chunks to the lines the entry covers
+	end := 12
+	chunked, ok = readFileChunks(FileInfo{Path: path, StartLine: 9, EndLine: &end}, 400)
+	assert.True(t, ok)
+	assert.NotContains(t, chunked, "Normalize")
+	assert.Contains(t, chunked, "@@ lines 10-12: type Store struct @@")
+
+	// Unsupported languages fall back to omitting the file
+	notes := filepath.Join(t.TempDir(), "notes.txt")
+	assert.NoError(t, os.WriteFile(notes, []byte(content), 0644))
+	_, ok = readFileChunks(FileInfo{Path: notes, StartLine: 1}, 400)
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Where live context is sent, chosen with live_context_placement in the config or /context placement
+const (
+	PlacementSystem  = "system"  // in the reference data at the end of the system prompt (default)
+	PlacementMessage = "message" // in a context message after the history, rebuilt for every request
+)
+
+// referenceDataMarker starts the live context and per-turn data in the system prompt template
+const referenceDataMarker = "====\n\nREFERENCE DATA"
+
+// turnDataMarker starts the per-turn data (context usage, date, git status, running jobs, ...), which
+// is always sent in the context message so the system prompt and history stay a cacheable prefix
+const turnDataMarker = "====\n\nCURRENT STATE"
+
+const contextMessagePreamble = "Current state, refreshed before every request. It replaces any earlier version and is not something the user wrote."
+
+// ContextPlacement reports where live context is sent
+func (a *Agent) ContextPlacement() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.contextPlacement == "" {
+		return PlacementSystem
+	}
+	return a.contextPlacement
+}
+
+func (a *Agent) setContextPlacement(placement string) error {
+	if placement != PlacementSystem && placement != PlacementMessage {
+		return fmt.Errorf("unknown placement %q; use %s or %s", placement, PlacementSystem, PlacementMessage)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.contextPlacement = placement
+	return nil
+}
+
+func placementLabel(placement string) string {
+	if placement == PlacementMessage {
+		return "context message after the history"
+	}
+	return "system prompt"
+}
+
+// splitReferenceData cuts the part of the system prompt from marker on off the prompt: the per-turn
+// data with turnDataMarker, or the live context as well with referenceDataMarker
+func splitReferenceData(prompt, marker string) (string, string) {
+	index := strings.Index(prompt, marker)
+	if index == -1 {
+		return prompt, ""
+	}
+	reference := strings.ReplaceAll(prompt[index+len("====\n\n"):], api.CacheBreakpoint, "\n")
+	return strings.TrimRight(prompt[:index], "\n"), strings.TrimSpace(reference)
+}
+
+// withContextMessage returns the messages to send with the per-turn data, and the live context when
+// it's placed in the message, appended as a user message. It is never stored in the history, so each
+// request carries only the latest version.
+func withContextMessage(messages []models.Message, reference string) []models.Message {
+	contextMessage := models.Message{
+		ID:        models.ContextMessageID,
+		Role:      "user",
+		Content:   contextMessagePreamble + "\n\n<context>\n" + reference + "\n</context>",
+		Timestamp: time.Now(),
+	}
+	return append(append([]models.Message(nil), messages...), contextMessage)
+}
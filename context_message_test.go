@@ -0,0 +1,47 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextMessagePlacement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	assert.NoError(t, os.WriteFile(path, []byte("remember the milk"), 0644))
+
+	agent := &Agent{LiveContext: NewLiveContext(), config: &Config{}}
+	defer agent.LiveContext.Close()
+	assert.NoError(t, agent.LiveContext.AddFile(path, 1, nil))
+
+	// Per-turn data always goes after the history, so the system prompt stays a cacheable prefix
+	prompt, reference, _ := agent.buildSystemPrompt()
+	assert.Contains(t, prompt, "remember the milk")
+	assert.NotContains(t, prompt, "Context Usage:")
+	assert.True(t, strings.HasPrefix(reference, "CURRENT STATE"))
+	assert.Contains(t, reference, "Context Usage:")
+	assert.NotContains(t, reference, "remember the milk")
+
+	assert.Error(t, agent.setContextPlacement("sidecar"))
+	assert.NoError(t, agent.setContextPlacement(PlacementMessage))
+	prompt, reference, _ = agent.buildSystemPrompt()
+	assert.NotContains(t, prompt, "Files you're currently reading")
+	assert.NotContains(t, prompt, "remember the milk")
+	assert.True(t, strings.HasPrefix(reference, "REFERENCE DATA"))
+	assert.Contains(t, reference, "remember the milk")
+	assert.Contains(t, reference, "Context Usage:")
+	assert.NotContains(t, reference, api.CacheBreakpoint)
+
+	history := []models.Message{{Role: "user", Content: "what should I buy?"}}
+	messages := withContextMessage(history, reference)
+	assert.Len(t, history, 1)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Equal(t, models.ContextMessageID, messages[1].ID)
+	assert.Contains(t, messages[1].Content, "<context>\nREFERENCE DATA")
+}
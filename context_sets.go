@@ -0,0 +1,181 @@
+package main
+
+import (
+	"agent/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var contextSetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ContextSet is a saved working set of live-context files and directories, so users can switch
+// between areas of a repository without reading each file again. Paths are absolute.
+type ContextSet struct {
+	Name        string          `json:"name"`
+	Workspace   string          `json:"workspace"`
+	Saved       time.Time       `json:"saved"`
+	Files       []FileInfo      `json:"files"`
+	Directories []DirectoryInfo `json:"directories"`
+}
+
+// contextSetsDir returns the directory holding saved context sets
+func contextSetsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".agent", "contexts"), nil
+}
+
+// contextSetPath returns where the context set with name is saved
+func contextSetPath(name string) (string, error) {
+	if !contextSetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("context names use letters, digits, '.', '_', and '-', got %q", name)
+	}
+	dir, err := contextSetsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveContextSet writes the files and directories in live context to ~/.agent/contexts/<name>.json
+func SaveContextSet(lc *LiveContext, name string) (*ContextSet, error) {
+	path, err := contextSetPath(name)
+	if err != nil {
+		return nil, err
+	}
+	workspace, _ := os.Getwd()
+	set := &ContextSet{Name: name, Workspace: workspace, Saved: time.Now()}
+	for _, fileInfo := range lc.Files() {
+		if absPath, err := filepath.Abs(fileInfo.Path); err == nil {
+			fileInfo.Path = absPath
+		}
+		set.Files = append(set.Files, fileInfo)
+	}
+	for _, dirInfo := range lc.Directories() {
+		if absPath, err := filepath.Abs(dirInfo.Path); err == nil {
+			dirInfo.Path = absPath
+		}
+		// The copy shares its slices with live context
+		dirInfo.Expanded = slices.Clone(dirInfo.Expanded)
+		for i, expanded := range dirInfo.Expanded {
+			if absPath, err := filepath.Abs(expanded); err == nil {
+				dirInfo.Expanded[i] = absPath
+			}
+		}
+		set.Directories = append(set.Directories, dirInfo)
+	}
+	sort.Slice(set.Files, func(i, j int) bool { return set.Files[i].Path < set.Files[j].Path })
+	sort.Slice(set.Directories, func(i, j int) bool { return set.Directories[i].Path < set.Directories[j].Path })
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// LoadContextSet replaces the files and directories in live context with a saved set. Entries that
+// no longer exist on disk are left out and returned.
+func LoadContextSet(lc *LiveContext, name string) (*ContextSet, []string, error) {
+	path, err := contextSetPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("no saved context named %q", name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var set ContextSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, nil, fmt.Errorf("%s is not valid: %w", path, err)
+	}
+
+	// Paths inside the working directory are shown relative to it, as the model usually reads them
+	workspace, _ := os.Getwd()
+	display := func(path string) string {
+		if rel, err := filepath.Rel(workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+		return path
+	}
+
+	var missing []string
+	files := make(map[string]FileInfo)
+	for _, fileInfo := range set.Files {
+		if _, err := os.Stat(fileInfo.Path); err != nil {
+			missing = append(missing, fileInfo.Path)
+			continue
+		}
+		fileInfo.Path = display(fileInfo.Path)
+		files[fileInfo.Path] = fileInfo
+	}
+	directories := make(map[string]DirectoryInfo)
+	for _, dirInfo := range set.Directories {
+		if _, err := os.Stat(dirInfo.Path); err != nil {
+			missing = append(missing, dirInfo.Path)
+			continue
+		}
+		dirInfo.Path = display(dirInfo.Path)
+		for i, expanded := range dirInfo.Expanded {
+			dirInfo.Expanded[i] = display(expanded)
+		}
+		directories[dirInfo.Path] = dirInfo
+	}
+	lc.Restore(files, directories)
+	return &set, missing, nil
+}
+
+// ListContextSets returns the names of the saved context sets
+func ListContextSets() ([]string, error) {
+	dir, err := contextSetsDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Restore replaces the files and directories in live context, watching the new files for changes
+func (lc *LiveContext) Restore(files map[string]FileInfo, directories map[string]DirectoryInfo) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.watcher != nil {
+		for filePath := range lc.files {
+			_ = lc.watcher.Remove(filepath.Dir(filePath))
+		}
+		for filePath := range files {
+			if err := lc.watcher.Add(filepath.Dir(filePath)); err != nil {
+				logging.Warnf("Failed to watch %s: %v", filePath, err)
+			}
+		}
+	}
+	lc.files = files
+	lc.directories = directories
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextSetSaveAndLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	wd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	require.NoError(t, os.MkdirAll("api", 0755))
+	for _, name := range []string{"api/server.go", "main.go", "gone.go"} {
+		require.NoError(t, os.WriteFile(name, []byte("package main\n"), 0644))
+	}
+
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}
+	require.NoError(t, lc.AddFile("api/server.go", 1, nil))
+	require.NoError(t, lc.SetPriority("api/server.go", true, PriorityNormal))
+	require.NoError(t, lc.AddFile("gone.go", 1, nil))
+	require.NoError(t, lc.AddDirectory("api", false))
+	set, err := SaveContextSet(lc, "api-work")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "api", "server.go"), set.Files[0].Path, "saved paths are absolute")
+
+	// Loading replaces the current working set and skips what was deleted since
+	require.NoError(t, os.Remove("gone.go"))
+	require.NoError(t, lc.RemoveFile("api/server.go"))
+	require.NoError(t, lc.AddFile("main.go", 1, nil))
+	_, missing, err := LoadContextSet(lc, "api-work")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "gone.go")}, missing)
+	assert.Equal(t, []string{filepath.Join("api", "server.go")}, lc.ListFiles())
+	assert.Equal(t, []string{"api"}, lc.ListDirectories())
+	pinned, _ := lc.GetPriority(filepath.Join("api", "server.go"))
+	assert.True(t, pinned, "priorities are restored with the files")
+
+	names, err := ListContextSets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api-work"}, names)
+	_, err = SaveContextSet(lc, "../escape")
+	assert.Error(t, err)
+}
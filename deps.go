@@ -0,0 +1,205 @@
+package main
+
+import (
+	"agent/theme"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultVerifyCommand = "go build ./... && go vet ./... && go test ./..."
+	maxVerifyOutput      = 8000
+)
+
+// DepsConfig configures the /deps upgrade workflow
+type DepsConfig struct {
+	Verify string `json:"verify"` // shell command that must pass after upgrading (default: go build, vet, and test)
+}
+
+func (c DepsConfig) verifyCommand() string {
+	if c.Verify == "" {
+		return defaultVerifyCommand
+	}
+	return c.Verify
+}
+
+// outdatedModule is a direct dependency with a newer version available
+type outdatedModule struct {
+	Path    string
+	Version string
+	Update  string
+}
+
+// listOutdatedModules returns the direct dependencies of the current module that have updates
+func listOutdatedModules() ([]outdatedModule, error) {
+	output, err := exec.Command("go", "list", "-m", "-u", "-json", "all").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("go list failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	var outdated []outdatedModule
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var module struct {
+			Path     string
+			Version  string
+			Main     bool
+			Indirect bool
+			Update   *struct{ Version string }
+		}
+		if err := decoder.Decode(&module); err != nil {
+			return nil, fmt.Errorf("unexpected go list output: %w", err)
+		}
+		if module.Main || module.Indirect || module.Update == nil {
+			continue
+		}
+		outdated = append(outdated, outdatedModule{Path: module.Path, Version: module.Version, Update: module.Update.Version})
+	}
+	return outdated, nil
+}
+
+// parseSelection turns "1,3", "2 4", or "all" into indexes into a list of n items, each listed once
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input == "all" {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+
+	var indexes []int
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 1 || number > n {
+			return nil, fmt.Errorf("invalid selection %q", field)
+		}
+		if !slices.Contains(indexes, number-1) {
+			indexes = append(indexes, number-1)
+		}
+	}
+	return indexes, nil
+}
+
+// snapshotFiles saves the contents of files so a failed change can be undone. The returned function
+// restores them, removing those that didn't exist.
+func snapshotFiles(paths ...string) (func() error, error) {
+	saved := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		saved[path] = data
+	}
+	return func() error {
+		for path, data := range saved {
+			var err error
+			if data == nil {
+				err = os.Remove(path)
+				if os.IsNotExist(err) {
+					err = nil
+				}
+			} else {
+				err = os.WriteFile(path, data, 0644)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", path, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+func handleDeps(a *Agent, args []string) string {
+	if len(args) > 0 {
+		return theme.ErrorText("Invalid arguments. Usage: /deps")
+	}
+
+	fmt.Println(theme.InfoText("Checking for module updates..."))
+	outdated, err := listOutdatedModules()
+	if err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	if len(outdated) == 0 {
+		return theme.SuccessText("All direct dependencies are up to date")
+	}
+
+	fmt.Println(theme.InfoText("Outdated direct dependencies:"))
+	for i, module := range outdated {
+		fmt.Println(theme.InfoText(fmt.Sprintf("  %d. %s %s → %s", i+1, module.Path, module.Version, module.Update)))
+	}
+
+	answer, ok := a.Ask("Upgrade which modules? (e.g. 1,3 or all; empty to cancel)")
+	if !ok || strings.TrimSpace(answer) == "" {
+		return theme.InfoText("No modules upgraded")
+	}
+	indexes, err := parseSelection(answer, len(outdated))
+	if err != nil {
+		return theme.ErrorText(err.Error())
+	}
+
+	// A failed go get can leave some modules upgraded, so go.mod and go.sum are put back as they were
+	restore, err := snapshotFiles("go.mod", "go.sum")
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to save go.mod and go.sum: %v", err))
+	}
+	failed := func(format string, args ...interface{}) string {
+		message := fmt.Sprintf(format, args...)
+		if err := restore(); err != nil {
+			return theme.ErrorText(fmt.Sprintf("%s, and %v", message, err))
+		}
+		return theme.ErrorText(message + "; go.mod and go.sum were restored")
+	}
+
+	var upgraded []string
+	for _, i := range indexes {
+		module := outdated[i]
+		fmt.Println(theme.InfoText(fmt.Sprintf("Upgrading %s to %s...", module.Path, module.Update)))
+		if output, err := exec.Command("go", "get", module.Path+"@"+module.Update).CombinedOutput(); err != nil {
+			return failed("go get %s@%s failed: %s", module.Path, module.Update, strings.TrimSpace(string(output)))
+		}
+		upgraded = append(upgraded, fmt.Sprintf("%s %s → %s", module.Path, module.Version, module.Update))
+	}
+	if output, err := exec.Command("go", "mod", "tidy").CombinedOutput(); err != nil {
+		return failed("go mod tidy failed: %s", strings.TrimSpace(string(output)))
+	}
+
+	verify := a.config.Deps.verifyCommand()
+	fmt.Println(theme.InfoText("Running " + verify))
+	output, err := exec.Command("sh", "-c", verify).CombinedOutput()
+	if err == nil {
+		return theme.SuccessText(fmt.Sprintf("Upgraded %d modules and `%s` passed:\n  %s", len(upgraded), verify, strings.Join(upgraded, "\n  ")))
+	}
+
+	// Hand the breakage to the agent to fix as a normal turn
+	fmt.Println(theme.WarningText("Verification failed; asking the agent to fix the breaking changes"))
+	a.ProcessMessage(depsFixPrompt(upgraded, verify, string(output)))
+	return ""
+}
+
+// depsFixPrompt asks the agent to fix what the upgrades broke and summarize the breaking changes
+func depsFixPrompt(upgraded []string, verify, output string) string {
+	if len(output) > maxVerifyOutput {
+		output = "...\n" + output[len(output)-maxVerifyOutput:]
+	}
+	return fmt.Sprintf(`I upgraded these Go modules:
+- %s
+
+The verify command now fails:
+$ %s
+%s
+
+Fix the code for the new versions (use lookup_docs to check the upgraded APIs) and re-run the verify command until it passes. Don't downgrade the modules. Then summarize each breaking change you had to fix: the module, what changed in its API or behavior, and how the code was updated.`,
+		strings.Join(upgraded, "\n- "), verify, strings.TrimSpace(output))
+}
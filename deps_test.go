@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelection(t *testing.T) {
+	cases := []struct {
+		input   string
+		indexes []int
+		err     string
+	}{
+		{"1,3", []int{0, 2}, ""},
+		{"2 4", []int{1, 3}, ""},
+		{" 3, 1 ", []int{2, 0}, ""},
+		{"ALL", []int{0, 1, 2, 3}, ""},
+		{"2,2 1,2", []int{1, 0}, ""},
+		{"0", nil, `invalid selection "0"`},
+		{"5", nil, `invalid selection "5"`},
+		{"1,two", nil, `invalid selection "two"`},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			indexes, err := parseSelection(c.input, 4)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.indexes, indexes)
+		})
+	}
+}
+
+func TestDepsFixPrompt(t *testing.T) {
+	prompt := depsFixPrompt([]string{"example.com/a v1.0.0 → v2.0.0", "example.com/b v0.1.0 → v0.2.0"}, "go test ./...", "  FAIL: undefined: a.Old\n")
+	assert.Contains(t, prompt, "- example.com/a v1.0.0 → v2.0.0\n- example.com/b v0.1.0 → v0.2.0")
+	assert.Contains(t, prompt, "$ go test ./...\nFAIL: undefined: a.Old\n")
+	assert.Contains(t, prompt, "Don't downgrade")
+
+	// Long output keeps its end, where the failures are
+	long := strings.Repeat("ok\n", maxVerifyOutput) + "FAIL: the last error"
+	prompt = depsFixPrompt([]string{"example.com/a"}, "make", long)
+	assert.Contains(t, prompt, "...\n")
+	assert.Contains(t, prompt, "FAIL: the last error")
+	assert.Less(t, len(prompt), maxVerifyOutput+1000)
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	goMod, goSum := filepath.Join(dir, "go.mod"), filepath.Join(dir, "go.sum")
+	require.NoError(t, os.WriteFile(goMod, []byte("module example\n"), 0644))
+
+	restore, err := snapshotFiles(goMod, goSum)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(goMod, []byte("module example\n\nrequire example.com/a v2.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(goSum, []byte("example.com/a v2.0.0 h1:abc=\n"), 0644))
+
+	require.NoError(t, restore())
+	data, err := os.ReadFile(goMod)
+	require.NoError(t, err)
+	assert.Equal(t, "module example\n", string(data))
+	assert.NoFileExists(t, goSum, "a file that didn't exist is removed")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"agent/theme"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DiagramConfig controls rendering of mermaid and graphviz blocks in responses to image artifacts
+type DiagramConfig struct {
+	Render bool   `json:"render"`
+	Format string `json:"format"` // svg (default) or png
+}
+
+// diagramBlockPattern matches fenced mermaid, dot, and graphviz code blocks
+var diagramBlockPattern = regexp.MustCompile("(?s)```(mermaid|dot|graphviz)[ \t]*\n(.*?)\n```")
+
+// rendererInstallHints explain how to get the binary for each diagram language
+var rendererInstallHints = map[string]string{
+	"mermaid": "install the mermaid CLI with `npm install -g @mermaid-js/mermaid-cli`",
+	"dot":     "install graphviz (e.g. `brew install graphviz` or `apt install graphviz`)",
+}
+
+// diagramBlock is a diagram's language and source extracted from a response
+type diagramBlock struct {
+	Language string
+	Source   string
+}
+
+// extractDiagrams returns the diagram blocks in text in order
+func extractDiagrams(text string) []diagramBlock {
+	var blocks []diagramBlock
+	for _, match := range diagramBlockPattern.FindAllStringSubmatch(text, -1) {
+		language := match[1]
+		if language == "graphviz" {
+			language = "dot"
+		}
+		blocks = append(blocks, diagramBlock{Language: language, Source: match[2]})
+	}
+	return blocks
+}
+
+// renderDiagram renders a diagram with the local mermaid CLI (mmdc) or graphviz (dot)
+func renderDiagram(block diagramBlock, format string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "agent-diagram")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "diagram."+block.Language)
+	output := filepath.Join(dir, "diagram."+format)
+	if err := os.WriteFile(input, []byte(block.Source), 0644); err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	switch block.Language {
+	case "mermaid":
+		cmd = exec.Command("mmdc", "-i", input, "-o", output)
+	default:
+		cmd = exec.Command("dot", "-T"+format, "-o", output, input)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s not found; %s", cmd.Args[0], rendererInstallHints[block.Language])
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %s", cmd.Args[0], message)
+		}
+		return nil, fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return os.ReadFile(output)
+}
+
+// renderDiagrams saves every diagram in a response as an image artifact and prints where it went
+func (a *Agent) renderDiagrams(content string) {
+	if !a.config.Diagrams.Render {
+		return
+	}
+	format := a.config.Diagrams.Format
+	if format != "png" {
+		format = "svg"
+	}
+
+	for _, block := range extractDiagrams(content) {
+		image, err := renderDiagram(block, format)
+		if err != nil {
+			fmt.Println(theme.WarningText(fmt.Sprintf("Could not render %s diagram: %v", block.Language, err)))
+			continue
+		}
+
+		description, _, _ := strings.Cut(strings.TrimSpace(block.Source), "\n")
+		artifact, err := a.artifacts.Save(block.Language+"-diagram."+format, "diagram", description, image)
+		if err != nil {
+			fmt.Println(theme.WarningText(fmt.Sprintf("Could not save diagram: %v", err)))
+			continue
+		}
+		fmt.Println(theme.InfoText("🖼  Saved diagram: " + artifact.Path))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"agent/artifacts"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractDiagrams(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected []diagramBlock
+	}{
+		{"none", "Some prose.\n```go\nfunc main() {}\n```", nil},
+		{"mermaid", "Here:\n```mermaid\ngraph TD\n  A --> B\n```\nDone.", []diagramBlock{{"mermaid", "graph TD\n  A --> B"}}},
+		{"graphviz is dot", "```graphviz\ndigraph { a -> b }\n```", []diagramBlock{{"dot", "digraph { a -> b }"}}},
+		{"several in order", "```dot\ndigraph { a }\n```\n\n```mermaid \ngraph LR\n```", []diagramBlock{{"dot", "digraph { a }"}, {"mermaid", "graph LR"}}},
+		{"unterminated", "```mermaid\ngraph TD\n", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, extractDiagrams(c.text))
+		})
+	}
+}
+
+// fakeRenderer leaves only a script named name, which runs body, on PATH
+func fakeRenderer(t *testing.T, name, body string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake renderers are shell scripts")
+	}
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nPATH=%q\n%s\n", os.Getenv("PATH"), body)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0755))
+	t.Setenv("PATH", dir)
+}
+
+func TestRenderDiagram(t *testing.T) {
+	// dot -T<format> -o <output> <input>
+	fakeRenderer(t, "dot", `cat "$4" > "$3"; echo " as $1" >> "$3"`)
+	image, err := renderDiagram(diagramBlock{"dot", "digraph { a }"}, "png")
+	require.NoError(t, err)
+	assert.Equal(t, "digraph { a } as -Tpng\n", string(image))
+
+	// mmdc -i <input> -o <output>
+	fakeRenderer(t, "mmdc", `echo "Parse error on line 1" >&2; exit 1`)
+	_, err = renderDiagram(diagramBlock{"mermaid", "graph"}, "svg")
+	assert.EqualError(t, err, "mmdc: Parse error on line 1")
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = renderDiagram(diagramBlock{"mermaid", "graph"}, "svg")
+	assert.ErrorContains(t, err, "mmdc not found; install the mermaid CLI")
+}
+
+func TestRenderDiagrams(t *testing.T) {
+	fakeRenderer(t, "dot", `cat "$4" > "$3"`)
+	response := "```dot\ndigraph { a -> b }\n```\n```mermaid\ngraph TD\n```"
+
+	a := &Agent{config: &Config{}, artifacts: artifacts.NewStore(t.TempDir())}
+	a.renderDiagrams(response)
+	assert.Empty(t, a.artifacts.List(), "rendering is off by default")
+
+	// Diagrams that fail to render, here for lack of mmdc, are skipped
+	a.config.Diagrams = DiagramConfig{Render: true, Format: "png"}
+	a.renderDiagrams(response)
+	saved := a.artifacts.List()
+	require.Len(t, saved, 1)
+	assert.Equal(t, "diagram", saved[0].Kind)
+	assert.Equal(t, "digraph { a -> b }", saved[0].Description)
+	assert.Equal(t, ".png", filepath.Ext(saved[0].Path))
+	content, err := os.ReadFile(saved[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "digraph { a -> b }", string(content))
+}
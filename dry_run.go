@@ -0,0 +1,42 @@
+package main
+
+import "agent/theme"
+
+// dryRunTools are the tools that only show their changes in a dry run
+var dryRunTools = map[string]bool{
+	"create_file":    true,
+	"edit_file":      true,
+	"delete_file":    true,
+	"multi_edit":     true,
+	"insert_lines":   true,
+	"append_to_file": true,
+	"apply_patch":    true,
+}
+
+// InDryRun reports whether file tools only show the changes they would make
+func (a *Agent) InDryRun() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dryRun
+}
+
+func (a *Agent) setDryRun(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dryRun = enabled
+}
+
+func handleDryRun(a *Agent, args []string) string {
+	enabled := !a.InDryRun()
+	if len(args) == 1 && (args[0] == "on" || args[0] == "off") {
+		enabled = args[0] == "on"
+	} else if len(args) > 0 {
+		return theme.ErrorText("Usage: /dryrun [on|off]")
+	}
+
+	a.setDryRun(enabled)
+	if !enabled {
+		return theme.InfoText("Dry run off; file tools write to disk again")
+	}
+	return theme.InfoText("Dry run on: file tools show their diffs without writing anything. Shell commands still run. /dryrun again to turn it off.")
+}
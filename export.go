@@ -0,0 +1,126 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"agent/tools"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// exportFormats maps each /export format to the extension of the files it writes
+var exportFormats = map[string]string{"markdown": ".md", "html": ".html", "json": ".json"}
+
+// TranscriptExport is the document /export json writes
+type TranscriptExport struct {
+	Session  string           `json:"session"`
+	Exported time.Time        `json:"exported"`
+	Model    string           `json:"model,omitempty"`
+	Messages []models.Message `json:"messages"`
+	Changes  string           `json:"changes,omitempty"` // unified diff of the files tools changed this session
+}
+
+func handleExport(a *Agent, args []string) string {
+	format, path := "", ""
+	for _, arg := range args {
+		if _, ok := exportFormats[arg]; ok && format == "" && path == "" {
+			format = arg
+		} else if path == "" {
+			path = arg
+		} else {
+			return theme.ErrorText("Usage: /export [markdown|html|json] [path]")
+		}
+	}
+	if format == "" {
+		format = "markdown"
+		for name, extension := range exportFormats {
+			if strings.EqualFold(filepath.Ext(path), extension) {
+				format = name
+			}
+		}
+	}
+	if path == "" {
+		path = fmt.Sprintf("transcript-%s%s", a.sessionLogger.ID, exportFormats[format])
+	}
+
+	data, err := a.exportTranscript(format)
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to export the transcript: %v", err))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to write %s: %v", path, err))
+	}
+	return theme.SuccessText(fmt.Sprintf("Exported the conversation to %s", path))
+}
+
+// exportTranscript renders the conversation and the session's file changes in format, with
+// credentials masked as for /share
+func (a *Agent) exportTranscript(format string) ([]byte, error) {
+	anonymize := a.config.Share.AnonymizePaths
+	messages := a.GetHistory()
+	changes := a.journalDiff(func(tools.JournalEntry) bool { return true })
+
+	switch format {
+	case "json":
+		export := TranscriptExport{Session: a.sessionLogger.ID, Exported: time.Now(), Changes: a.sanitizeTranscript(changes, anonymize)}
+		if a.currentModel != nil && a.currentModel.Provider != nil {
+			export.Model = a.currentModel.Provider.ID + ":" + a.currentModel.ID
+		}
+		for _, message := range messages {
+			if message.Status == "deleted" {
+				continue
+			}
+			message.Content = a.sanitizeTranscript(message.Content, anonymize)
+			message.ToolCalls = append([]models.ToolCall(nil), message.ToolCalls...)
+			for i := range message.ToolCalls {
+				message.ToolCalls[i].Function.Arguments = a.sanitizeTranscript(message.ToolCalls[i].Function.Arguments, anonymize)
+			}
+			export.Messages = append(export.Messages, message)
+		}
+		return json.MarshalIndent(export, "", "  ")
+	case "markdown", "html":
+		transcript := renderTranscript(messages)
+		if changes != "" {
+			transcript += fmt.Sprintf("\n## File changes\n\n```diff\n%s\n```\n", strings.TrimRight(changes, "\n"))
+		}
+		transcript = a.sanitizeTranscript(transcript, anonymize)
+		if format == "markdown" {
+			return []byte(transcript), nil
+		}
+		return renderTranscriptHTML(transcript)
+	}
+	return nil, fmt.Errorf("unknown format %q (use markdown, html, or json)", format)
+}
+
+// renderTranscriptHTML renders a markdown transcript as a standalone page. Raw HTML in the
+// conversation is escaped rather than rendered.
+func renderTranscriptHTML(transcript string) ([]byte, error) {
+	var body bytes.Buffer
+	converter := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	if err := converter.Convert([]byte(transcript), &body); err != nil {
+		return nil, err
+	}
+
+	title := "Agent transcript"
+	if heading, _, found := strings.Cut(transcript, "\n"); found {
+		title = strings.TrimPrefix(heading, "# ")
+	}
+	var page bytes.Buffer
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	page.WriteString("<style>body{max-width:60em;margin:2em auto;padding:0 1em;font-family:sans-serif;line-height:1.5}" +
+		"pre{background:#f6f8fa;padding:1em;overflow-x:auto}h3{border-top:1px solid #ddd;padding-top:1em}</style>\n")
+	page.WriteString("</head>\n<body>\n")
+	page.Write(body.Bytes())
+	page.WriteString("</body>\n</html>\n")
+	return page.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"agent/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestAgent() *Agent {
+	return &Agent{
+		config:        &Config{},
+		sessionLogger: &SessionLogger{ID: "20250101120000"},
+		Messages: []models.Message{
+			{Role: "user", Content: "fix the <b>build</b>, token=\"f3Kq9-xT2vLw8Zp1\""},
+			{Role: "assistant", ToolCalls: []models.ToolCall{{Function: models.FunctionCall{Name: "edit_file", Arguments: `{"path":"main.go"}`}}}},
+			{Role: "tool", ToolName: "edit_file", Content: "Updated"},
+			{Role: "assistant", Content: "removed", Status: "deleted"},
+		},
+	}
+}
+
+func TestExportTranscriptFormats(t *testing.T) {
+	a := exportTestAgent()
+
+	markdown, err := a.exportTranscript("markdown")
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "**tool call:** `edit_file`")
+	assert.Contains(t, string(markdown), `token="[REDACTED]"`)
+	assert.NotContains(t, string(markdown), "removed")
+
+	page, err := a.exportTranscript("html")
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "<!DOCTYPE html>")
+	assert.Contains(t, string(page), "<code>edit_file</code>")
+	assert.NotContains(t, string(page), "<b>build</b>", "HTML in messages is not rendered")
+
+	data, err := a.exportTranscript("json")
+	require.NoError(t, err)
+	var export TranscriptExport
+	require.NoError(t, json.Unmarshal(data, &export))
+	assert.Equal(t, "20250101120000", export.Session)
+	assert.Len(t, export.Messages, 3)
+	assert.Equal(t, "edit_file", export.Messages[1].ToolCalls[0].Function.Name)
+}
+
+func TestHandleExportPaths(t *testing.T) {
+	dir := t.TempDir()
+	a := exportTestAgent()
+
+	path := filepath.Join(dir, "review.html")
+	assert.Contains(t, handleExport(a, []string{path}), "Exported")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<html>", "the format follows the extension")
+
+	path = filepath.Join(dir, "transcript.txt")
+	assert.Contains(t, handleExport(a, []string{"json", path}), "Exported")
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, json.Valid(data))
+
+	assert.Contains(t, handleExport(a, []string{"json", "a", "b"}), "Usage")
+}
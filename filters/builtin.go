@@ -0,0 +1,82 @@
+package filters
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register(NewHook("pii", scrubPII))
+	Register(NewHook("paths", anonymizePaths))
+}
+
+var (
+	emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]\d{3}[ .-]\d{4}\b`)
+	ssnPattern   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// scrubPII replaces email addresses, phone numbers, US social security numbers, and payment card
+// numbers with placeholders. Card numbers must pass the Luhn check, so IDs and timestamps survive.
+func scrubPII(content string) (string, int, error) {
+	changes := 0
+	replace := func(pattern *regexp.Regexp, placeholder string, valid func(string) bool) {
+		content = pattern.ReplaceAllStringFunc(content, func(match string) string {
+			if valid != nil && !valid(match) {
+				return match
+			}
+			changes++
+			return placeholder
+		})
+	}
+	replace(emailPattern, "[EMAIL]", nil)
+	replace(ssnPattern, "[SSN]", nil)
+	replace(cardPattern, "[CARD]", luhnValid)
+	replace(phonePattern, "[PHONE]", nil)
+	return content, changes, nil
+}
+
+// luhnValid reports whether the digits of number pass the Luhn checksum used by payment cards
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// anonymizePaths replaces the workspace path with <workspace> and the home directory with ~, so
+// requests don't reveal usernames or directory layouts
+func anonymizePaths(content string) (string, int, error) {
+	changes := 0
+	replace := func(path, placeholder string) {
+		if path == "" || path == "/" {
+			return
+		}
+		if count := strings.Count(content, path); count > 0 {
+			content = strings.ReplaceAll(content, path, placeholder)
+			changes += count
+		}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		replace(cwd, "<workspace>")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		replace(home, "~")
+	}
+	return content, changes, nil
+}
@@ -0,0 +1,167 @@
+// Package filters runs outbound prompt content through a chain of filters before it is sent to a
+// model. Filters can transform content (scrubbing PII, anonymizing paths) or block the request
+// entirely, and every change they make is reported so it can be audited.
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Filter transforms or blocks outbound content. Apply returns the content to send and how many
+// changes were made; returning an error blocks the request.
+type Filter interface {
+	Name() string
+	Apply(content string) (string, int, error)
+}
+
+// Hook adapts a function to a Filter
+type Hook struct {
+	name  string
+	apply func(content string) (string, int, error)
+}
+
+// NewHook wraps apply as a filter called name
+func NewHook(name string, apply func(content string) (string, int, error)) Hook {
+	return Hook{name: name, apply: apply}
+}
+
+func (h Hook) Name() string { return h.name }
+
+func (h Hook) Apply(content string) (string, int, error) { return h.apply(content) }
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Filter)
+)
+
+// Register makes a filter available by name to the "builtin" list in the filter config. Custom Go
+// hooks register themselves from an init function in a file compiled into the agent.
+func Register(filter Filter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[filter.Name()] = filter
+}
+
+// Lookup returns a registered filter
+func Lookup(name string) (Filter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	filter, ok := registry[name]
+	return filter, ok
+}
+
+// Registered lists the names of registered filters
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rule is a regex filter from the config. Matches are replaced with Replace (Go regexp expansion,
+// e.g. "$1" works), or block the request when Block is set.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+	Block   bool   `json:"block"`
+}
+
+// NewRegexFilter compiles a rule into a filter
+func NewRegexFilter(rule Rule) (Filter, error) {
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("filter %s: %w", rule.Name, err)
+	}
+	name := rule.Name
+	if name == "" {
+		name = rule.Pattern
+	}
+	return NewHook(name, func(content string) (string, int, error) {
+		matches := pattern.FindAllStringIndex(content, -1)
+		if len(matches) == 0 {
+			return content, 0, nil
+		}
+		if rule.Block {
+			// The error is logged and shown, so it says where the match is, not what it is
+			return content, 0, fmt.Errorf("matched at byte %d", matches[0][0])
+		}
+		return pattern.ReplaceAllString(content, rule.Replace), len(matches), nil
+	}), nil
+}
+
+// Record summarizes what one filter did to a request. Only counts and locations are kept, never the
+// content that was removed or matched.
+type Record struct {
+	Filter  string   `json:"filter"`
+	Changes int      `json:"changes"`
+	Sources []string `json:"sources"`           // which parts of the request were changed, e.g. "system prompt", "message 3"
+	Blocked bool     `json:"blocked,omitempty"` // the filter blocked the request at the last source
+}
+
+// BlockedError is returned when a filter blocks a request
+type BlockedError struct {
+	Filter string
+	Source string
+	Err    error
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by content filter %s in %s: %v", e.Filter, e.Source, e.Err)
+}
+
+func (e *BlockedError) Unwrap() error { return e.Err }
+
+// Chain runs filters in order over every part of a request
+type Chain struct {
+	filters []Filter
+}
+
+// NewChain creates a chain of filters
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{filters: filters}
+}
+
+// Len is the number of filters in the chain
+func (c *Chain) Len() int {
+	return len(c.filters)
+}
+
+// Part is one piece of outbound content, labeled by where it came from
+type Part struct {
+	Source  string
+	Content *string
+}
+
+// Apply filters each part in place and returns one record per filter that changed or blocked
+// something. When a filter blocks, the parts may be partly filtered and must not be sent.
+func (c *Chain) Apply(parts []Part) ([]Record, error) {
+	var records []Record
+	for _, filter := range c.filters {
+		record := Record{Filter: filter.Name()}
+		for _, part := range parts {
+			filtered, changes, err := filter.Apply(*part.Content)
+			if err != nil {
+				record.Blocked = true
+				record.Sources = append(record.Sources, part.Source)
+				return append(records, record), &BlockedError{Filter: filter.Name(), Source: part.Source, Err: err}
+			}
+			if changes > 0 {
+				*part.Content = filtered
+				record.Changes += changes
+				record.Sources = append(record.Sources, part.Source)
+			}
+		}
+		if record.Changes > 0 {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,136 @@
+package filters
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Redacted replaces the credentials Secrets finds
+const Redacted = "[REDACTED]"
+
+// DefaultSecretPatterns match common credentials. When a pattern has a capture group, only the group
+// is masked, so a header like "Authorization: Bearer ..." keeps its name.
+var DefaultSecretPatterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,                                         // AWS access key IDs
+	`(?i)\baws_secret_access_key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`,   // AWS secret keys
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                                        // GitHub tokens
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,                                      // GitHub fine-grained tokens
+	`\bglpat-[A-Za-z0-9_\-]{20,}\b`,                                         // GitLab tokens
+	`\bsk-[A-Za-z0-9_\-]{20,}\b`,                                            // OpenAI and Anthropic keys
+	`\bxox[abprs]-[A-Za-z0-9\-]{10,}\b`,                                     // Slack tokens
+	`\bAIza[0-9A-Za-z_\-]{35}\b`,                                            // Google API keys
+	`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`, // JWTs
+	`(?i)\bbearer\s+([A-Za-z0-9_\-\.=]{16,})`,
+}
+
+// assignedSecret matches a quoted value assigned to a credential-like name, like password: "...".
+// Only values that look random are masked, so placeholders and code such as
+// token = strings.TrimSpace(raw) are left alone.
+var assignedSecret = regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|password|passwd)["']?\s*[:=]\s*["']([^\s"']{8,})["']`)
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which an assigned value looks
+// random rather than like a word or placeholder
+const minSecretEntropy = 3.0
+
+// Secrets masks credentials: matches of its patterns and literal values such as configured API keys.
+// It is a Filter, so it can run in the outbound chain.
+type Secrets struct {
+	patterns []*regexp.Regexp
+	literals []string
+}
+
+// NewSecrets compiles the default patterns plus extra ones and masks the literals wherever they appear
+func NewSecrets(extra []string, literals []string) (*Secrets, error) {
+	secrets := &Secrets{}
+	for _, pattern := range append(append([]string(nil), DefaultSecretPatterns...), extra...) {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret pattern %q: %w", pattern, err)
+		}
+		secrets.patterns = append(secrets.patterns, compiled)
+	}
+	for _, literal := range literals {
+		// Short values would mask ordinary words
+		if len(literal) >= 8 {
+			secrets.literals = append(secrets.literals, literal)
+		}
+	}
+	return secrets, nil
+}
+
+func (s *Secrets) Name() string { return "secrets" }
+
+func (s *Secrets) Apply(content string) (string, int, error) {
+	content, changes := s.Redact(content)
+	return content, changes, nil
+}
+
+// Redact returns text with its credentials masked and how many were masked
+func (s *Secrets) Redact(text string) (string, int) {
+	changes := 0
+	for _, literal := range s.literals {
+		if count := strings.Count(text, literal); count > 0 {
+			text = strings.ReplaceAll(text, literal, Redacted)
+			changes += count
+		}
+	}
+	for _, pattern := range s.patterns {
+		var n int
+		text, n = mask(text, pattern, nil)
+		changes += n
+	}
+	text, n := mask(text, assignedSecret, looksRandom)
+	return text, changes + n
+}
+
+// mask replaces the matches of pattern, or their first group, that keep accepts (all of them when
+// keep is nil), and returns the text and how many it replaced
+func mask(text string, pattern *regexp.Regexp, keep func(string) bool) (string, int) {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+	var out strings.Builder
+	last, changes := 0, 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) >= 4 && match[2] >= 0 {
+			start, end = match[2], match[3]
+		}
+		if text[start:end] == Redacted || (keep != nil && !keep(text[start:end])) {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(Redacted)
+		last = end
+		changes++
+	}
+	out.WriteString(text[last:])
+	return out.String(), changes
+}
+
+// looksRandom reports whether value mixes letters and digits and has the entropy of a generated
+// credential
+func looksRandom(value string) bool {
+	counts := make(map[rune]int)
+	letters, digits := false, false
+	for _, r := range value {
+		counts[r]++
+		letters = letters || unicode.IsLetter(r)
+		digits = digits || unicode.IsDigit(r)
+	}
+	if !letters || !digits {
+		return false
+	}
+	entropy := 0.0
+	total := float64(len([]rune(value)))
+	for _, count := range counts {
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minSecretEntropy
+}
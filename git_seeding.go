@@ -0,0 +1,82 @@
+package main
+
+import (
+	"agent/logging"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxSeededFiles caps how many changed files seeding adds, so a large uncommitted change doesn't
+// fill live context on its own
+const maxSeededFiles = 20
+
+// gitChangedFiles returns the files with uncommitted changes, including untracked ones, relative to
+// the working directory. Deleted files are left out.
+func gitChangedFiles() ([]string, error) {
+	root, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+
+	// runGit trims its output, which would eat the status of the first entry
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	workDir, _ := os.Getwd()
+	var files []string
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		// Renames and copies are followed by the original path
+		if status[0] == 'R' || status[0] == 'C' {
+			i++
+		}
+		if strings.Contains(status, "D") {
+			continue
+		}
+
+		// Porcelain paths are relative to the repository root
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		if info, err := os.Stat(absPath); err != nil || info.IsDir() {
+			continue
+		}
+		if rel, err := filepath.Rel(workDir, absPath); err == nil {
+			absPath = rel
+		}
+		files = append(files, absPath)
+	}
+	return files, nil
+}
+
+// seedContextFromGit adds files with uncommitted changes to live context, since they are usually
+// what the user wants to discuss, and returns the files added
+func (a *Agent) seedContextFromGit() []string {
+	files, err := gitChangedFiles()
+	if err != nil {
+		logging.Debugf("Not seeding live context from git: %v", err)
+		return nil
+	}
+	if len(files) > maxSeededFiles {
+		logging.Infof("%d files have uncommitted changes; adding the first %d to live context", len(files), maxSeededFiles)
+		files = files[:maxSeededFiles]
+	}
+	for _, file := range files {
+		if err := a.LiveContext.AddFile(file, 1, nil); err != nil {
+			logging.Warnf("Failed to add %s to live context: %v", file, err)
+		}
+	}
+	return files
+}
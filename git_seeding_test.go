@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedContextFromGit(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tempDir))
+	defer os.Chdir(originalDir)
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	require.NoError(t, exec.Command("git", "init", "-q", "-b", "main").Run())
+	require.NoError(t, os.MkdirAll(filepath.Join("src", "new"), 0755))
+	for _, name := range []string{"README.md", "src/kept.go", "src/edited.go", "src/deleted.go", "old.go"} {
+		require.NoError(t, os.WriteFile(name, []byte("one\n"), 0644))
+	}
+	require.NoError(t, exec.Command("git", "add", ".").Run())
+	require.NoError(t, exec.Command("git", "commit", "-q", "-m", "initial").Run())
+
+	// Modified, untracked, renamed, and deleted files
+	require.NoError(t, os.WriteFile(filepath.Join("src", "edited.go"), []byte("two\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join("src", "new", "file.go"), []byte("new\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join("src", "deleted.go")))
+	require.NoError(t, exec.Command("git", "mv", "old.go", "renamed.go").Run())
+
+	// Paths are relative to the working directory, even in a subdirectory of the repository
+	require.NoError(t, os.Chdir("src"))
+	a := &Agent{LiveContext: &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}, config: &Config{}}
+	assert.Empty(t, a.InitializeDefaultContext(), "seeding is off by default")
+
+	a.config.SeedGitChanges = true
+	seeded := a.InitializeDefaultContext()
+	assert.ElementsMatch(t, []string{"edited.go", filepath.Join("new", "file.go"), filepath.Join("..", "renamed.go")}, seeded)
+	assert.ElementsMatch(t, seeded, a.LiveContext.ListFiles())
+}
@@ -0,0 +1,326 @@
+// Package hooks runs configured actions before and after tool calls, such as formatting a file after
+// it is edited or blocking edits to protected paths. Hooks are shell commands or Go functions
+// registered by name, and their output can be passed back to the model with the tool result.
+package hooks
+
+import (
+	"agent/permissions"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events a rule can run on
+const (
+	Pre  = "pre"  // before the tool runs; a failing hook blocks the call
+	Post = "post" // after the tool succeeds
+)
+
+// DefaultTimeout bounds a hook command whose rule sets no timeout
+const DefaultTimeout = 30 * time.Second
+
+// maxOutput caps the hook output kept for the terminal and the model
+const maxOutput = 2000
+
+// Call describes the tool call a hook runs for
+type Call struct {
+	Event  string
+	Tool   string
+	Params map[string]interface{}
+	Path   string // the file the call touches that matched the rule, if any
+	Result string // the tool's output; empty before the tool runs
+}
+
+// Hook is a Go function run for tool calls. The returned text is its output; for pre hooks,
+// returning an error blocks the call.
+type Hook interface {
+	Name() string
+	Run(ctx context.Context, call Call) (string, error)
+}
+
+// Func adapts a function to a Hook
+type Func struct {
+	name string
+	run  func(ctx context.Context, call Call) (string, error)
+}
+
+// NewHook wraps run as a hook called name
+func NewHook(name string, run func(ctx context.Context, call Call) (string, error)) Func {
+	return Func{name: name, run: run}
+}
+
+func (f Func) Name() string { return f.name }
+
+func (f Func) Run(ctx context.Context, call Call) (string, error) { return f.run(ctx, call) }
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Hook)
+)
+
+// Register makes a hook available by name to the "builtin" field of hook rules. Custom Go hooks
+// register themselves from an init function in a file compiled into the agent.
+func Register(hook Hook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[hook.Name()] = hook
+}
+
+// Lookup returns a registered hook
+func Lookup(name string) (Hook, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	hook, ok := registry[name]
+	return hook, ok
+}
+
+// Registered lists the names of registered hooks
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rule runs a shell command or a registered hook on one event. Tools and Paths narrow which calls
+// it runs for; a path pattern without "/" matches file names, e.g. "*.go".
+type Rule struct {
+	Event          string   `json:"event"`           // "pre" or "post"
+	Tools          []string `json:"tools"`           // tool names; empty means every tool
+	Paths          []string `json:"paths"`           // path globs, where ** crosses directories; empty means any call
+	Command        string   `json:"command"`         // run with sh -c and the AGENT_* variables set
+	Builtin        string   `json:"builtin"`         // a registered Go hook, instead of a command
+	Feedback       bool     `json:"feedback"`        // pass the output to the model in the result's notes
+	TimeoutSeconds int      `json:"timeout_seconds"` // default 30
+}
+
+// Validate reports whether the rule can run
+func (r Rule) Validate() error {
+	if r.Event != Pre && r.Event != Post {
+		return fmt.Errorf("event must be %q or %q, got %q", Pre, Post, r.Event)
+	}
+	if (r.Command == "") == (r.Builtin == "") {
+		return errors.New("set exactly one of command and builtin")
+	}
+	if r.Builtin != "" {
+		if _, ok := Lookup(r.Builtin); !ok {
+			return fmt.Errorf("unknown builtin hook %q (available: %v)", r.Builtin, Registered())
+		}
+	}
+	if r.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must be 0 (default) or more, got %d", r.TimeoutSeconds)
+	}
+	return nil
+}
+
+// Name identifies the rule in output and errors
+func (r Rule) Name() string {
+	if r.Builtin != "" {
+		return r.Builtin
+	}
+	return r.Command
+}
+
+// Outcome is what one hook printed for a call
+type Outcome struct {
+	Rule   Rule
+	Path   string
+	Output string
+	Err    error // the hook failed or its command exited with an error
+}
+
+// Note renders the outcome for the tool result's notes
+func (o Outcome) Note() string {
+	note := fmt.Sprintf("%s hook %s", o.Rule.Event, o.Rule.Name())
+	if o.Path != "" {
+		note += " on " + o.Path
+	}
+	if o.Err != nil {
+		note += fmt.Sprintf(" failed (%v)", o.Err)
+	}
+	if o.Output != "" {
+		note += ": " + o.Output
+	}
+	return note
+}
+
+// BlockedError is returned when a pre hook blocks a call
+type BlockedError struct {
+	Outcome Outcome
+}
+
+func (e *BlockedError) Error() string {
+	reason := e.Outcome.Output
+	if reason == "" {
+		reason = e.Outcome.Err.Error()
+	}
+	return fmt.Sprintf("blocked by hook %s: %s", e.Outcome.Rule.Name(), reason)
+}
+
+func (e *BlockedError) Unwrap() error { return e.Outcome.Err }
+
+// Runner runs the rules that match each tool call, in order
+type Runner struct {
+	mu    sync.RWMutex
+	root  string
+	rules []Rule
+}
+
+// New creates a runner with rules; relative path patterns are resolved against root
+func New(root string, rules []Rule) (*Runner, error) {
+	runner := &Runner{root: root}
+	for _, rule := range rules {
+		if err := runner.Add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return runner, nil
+}
+
+// Add appends a rule after validating it
+func (r *Runner) Add(rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+// Len is the number of rules
+func (r *Runner) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.rules)
+}
+
+// Pre runs the pre hooks matching a call to tool on paths. The first hook that fails stops the rest
+// and blocks the call with a *BlockedError.
+func (r *Runner) Pre(ctx context.Context, tool string, params map[string]interface{}, paths []string) ([]Outcome, error) {
+	call := Call{Event: Pre, Tool: tool, Params: params}
+	var outcomes []Outcome
+	for _, outcome := range r.run(ctx, call, paths) {
+		outcomes = append(outcomes, outcome)
+		if outcome.Err != nil {
+			return outcomes, &BlockedError{Outcome: outcome}
+		}
+	}
+	return outcomes, nil
+}
+
+// Post runs the post hooks matching a call that returned result. Failing hooks are reported in their
+// outcomes; the call has already happened.
+func (r *Runner) Post(ctx context.Context, tool string, params map[string]interface{}, paths []string, result string) []Outcome {
+	return r.run(ctx, Call{Event: Post, Tool: tool, Params: params, Result: result}, paths)
+}
+
+// run runs each matching rule once per matching path, or once when the rule has no path patterns.
+// Pre hooks stop at the first failure.
+func (r *Runner) run(ctx context.Context, call Call, paths []string) []Outcome {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	rules := slices.Clone(r.rules)
+	r.mu.RUnlock()
+
+	var outcomes []Outcome
+	for _, rule := range rules {
+		if rule.Event != call.Event || (len(rule.Tools) > 0 && !slices.Contains(rule.Tools, call.Tool)) {
+			continue
+		}
+		for _, path := range r.matchingPaths(rule, paths) {
+			call.Path = path
+			output, err := runRule(ctx, rule, call)
+			outcome := Outcome{Rule: rule, Path: path, Output: output, Err: err}
+			outcomes = append(outcomes, outcome)
+			if err != nil && call.Event == Pre {
+				return outcomes
+			}
+		}
+	}
+	return outcomes
+}
+
+// matchingPaths returns the paths a rule runs for. Without path patterns it runs once, for the first
+// path if there is one.
+func (r *Runner) matchingPaths(rule Rule, paths []string) []string {
+	if len(rule.Paths) == 0 {
+		if len(paths) == 0 {
+			return []string{""}
+		}
+		return paths[:1]
+	}
+	var matched []string
+	for _, path := range paths {
+		for _, pattern := range rule.Paths {
+			if strings.Contains(pattern, "/") {
+				if permissions.MatchPath(r.root, pattern, path) {
+					matched = append(matched, path)
+					break
+				}
+			} else if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+				matched = append(matched, path)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func runRule(ctx context.Context, rule Rule, call Call) (string, error) {
+	timeout := DefaultTimeout
+	if rule.TimeoutSeconds > 0 {
+		timeout = time.Duration(rule.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if rule.Builtin != "" {
+		hook, ok := Lookup(rule.Builtin)
+		if !ok {
+			return "", fmt.Errorf("unknown builtin hook %q", rule.Builtin)
+		}
+		output, err := hook.Run(ctx, call)
+		return truncate(strings.TrimSpace(output)), err
+	}
+
+	args, _ := json.Marshal(call.Params)
+	cmd := exec.CommandContext(ctx, "sh", "-c", rule.Command)
+	cmd.Env = append(os.Environ(),
+		"AGENT_HOOK_EVENT="+call.Event,
+		"AGENT_TOOL="+call.Tool,
+		"AGENT_TOOL_PATH="+call.Path,
+		"AGENT_TOOL_ARGS="+string(args),
+		"AGENT_TOOL_RESULT="+call.Result,
+	)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return truncate(strings.TrimSpace(string(output))), err
+}
+
+func truncate(output string) string {
+	if len(output) <= maxOutput {
+		return output
+	}
+	return output[:maxOutput] + fmt.Sprintf("... (%d more bytes)", len(output)-maxOutput)
+}
@@ -0,0 +1,64 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreHookBlocks(t *testing.T) {
+	runner, err := New("/repo", []Rule{
+		{Event: Pre, Tools: []string{"edit_file"}, Paths: []string{"./vendor/**"}, Command: `echo "$AGENT_TOOL_PATH is vendored" && exit 1`},
+		{Event: Post, Command: "echo never"},
+	})
+	assert.NoError(t, err)
+
+	params := map[string]interface{}{"path": "vendor/lib/a.go"}
+	outcomes, err := runner.Pre(context.Background(), "edit_file", params, []string{"vendor/lib/a.go"})
+	var blocked *BlockedError
+	assert.True(t, errors.As(err, &blocked))
+	assert.Equal(t, "blocked by hook echo \"$AGENT_TOOL_PATH is vendored\" && exit 1: vendor/lib/a.go is vendored", err.Error())
+	assert.Len(t, outcomes, 1)
+
+	// Other paths and tools don't match the rule
+	outcomes, err = runner.Pre(context.Background(), "edit_file", params, []string{"src/a.go"})
+	assert.NoError(t, err)
+	assert.Empty(t, outcomes)
+	_, err = runner.Pre(context.Background(), "read_file", params, []string{"vendor/lib/a.go"})
+	assert.NoError(t, err)
+}
+
+func TestPostHooks(t *testing.T) {
+	Register(NewHook("count", func(ctx context.Context, call Call) (string, error) {
+		return call.Tool + " returned " + call.Result, nil
+	}))
+	runner, err := New("/repo", []Rule{
+		{Event: Post, Tools: []string{"edit_file", "apply_patch"}, Paths: []string{"*.go"}, Command: `echo "$AGENT_TOOL: $AGENT_TOOL_PATH"`, Feedback: true},
+		{Event: Post, Builtin: "count"},
+		{Event: Post, Command: "echo failed >&2; exit 3"},
+	})
+	assert.NoError(t, err)
+
+	// The command runs once for each Go file the patch touched
+	outcomes := runner.Post(context.Background(), "apply_patch", nil, []string{"a.go", "README.md", "pkg/b.go"}, "ok")
+	assert.Len(t, outcomes, 4)
+	assert.Equal(t, "apply_patch: a.go", outcomes[0].Output)
+	assert.Equal(t, "post hook echo \"$AGENT_TOOL: $AGENT_TOOL_PATH\" on pkg/b.go: apply_patch: pkg/b.go", outcomes[1].Note())
+	assert.Equal(t, "apply_patch returned ok", outcomes[2].Output)
+	assert.Error(t, outcomes[3].Err)
+	assert.Equal(t, "failed", outcomes[3].Output)
+}
+
+func TestRuleValidation(t *testing.T) {
+	for _, rule := range []Rule{
+		{Event: "after", Command: "true"},
+		{Event: Pre},
+		{Event: Pre, Command: "true", Builtin: "count"},
+		{Event: Post, Builtin: "missing"},
+	} {
+		_, err := New("/repo", []Rule{rule})
+		assert.Error(t, err, "%+v", rule)
+	}
+}
@@ -0,0 +1,367 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chunkLines    = 50 // lines per chunk
+	chunkOverlap  = 10 // lines shared by consecutive chunks so matches near a boundary aren't split
+	maxChunkChars = 6000
+	maxFileBytes  = 256 * 1024
+	batchSize     = 64
+)
+
+// skipDirs are never indexed when the workspace isn't a git repository
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, ".venv": true, "dist": true, "build": true}
+
+// Embedder returns one vector per text
+type Embedder func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Chunk is an embedded range of lines in a file
+type Chunk struct {
+	StartLine int
+	EndLine   int
+	Vector    []float32
+}
+
+type fileEntry struct {
+	ModTime time.Time
+	Size    int64
+	Chunks  []Chunk
+}
+
+// Result is a chunk matching a search query
+type Result struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Score     float64
+}
+
+// Stats summarizes a refresh
+type Stats struct {
+	Files    int // files in the index
+	Chunks   int // chunks in the index
+	Embedded int // files embedded during this refresh
+	Removed  int // files dropped because they no longer exist
+}
+
+// Index holds embeddings for a workspace's files and persists them to disk
+type Index struct {
+	mu      sync.RWMutex
+	root    string
+	path    string
+	embed   Embedder
+	Model   string
+	Files   map[string]*fileEntry
+	Updated time.Time
+}
+
+// Open loads the index for the workspace at root from dir, or starts an empty one. An index built
+// with a different embedding model is discarded because its vectors aren't comparable.
+func Open(root, dir, model string, embed Embedder) (*Index, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(absRoot))
+	ix := &Index{
+		root:  absRoot,
+		path:  filepath.Join(dir, hex.EncodeToString(sum[:8])+".gob"),
+		embed: embed,
+		Model: model,
+		Files: make(map[string]*fileEntry),
+	}
+
+	data, err := os.ReadFile(ix.path)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored Index
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return ix, nil // rebuild a corrupt index rather than failing
+	}
+	if stored.Model == model {
+		ix.Files = stored.Files
+		ix.Updated = stored.Updated
+	}
+	return ix, nil
+}
+
+// Empty reports whether nothing has been indexed yet
+func (ix *Index) Empty() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.Files) == 0
+}
+
+// Stats returns the number of indexed files and chunks and when the index was last refreshed
+func (ix *Index) Stats() (int, int, time.Time) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	chunks := 0
+	for _, entry := range ix.Files {
+		chunks += len(entry.Chunks)
+	}
+	return len(ix.Files), chunks, ix.Updated
+}
+
+// Refresh embeds new and modified files, drops deleted ones, and saves the index. progress is called
+// after each file is embedded.
+func (ix *Index) Refresh(ctx context.Context, progress func(done, total int)) (Stats, error) {
+	files, err := listFiles(ix.root)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	ix.mu.RLock()
+	var stale []string
+	present := make(map[string]bool, len(files))
+	for _, path := range files {
+		info, err := os.Stat(filepath.Join(ix.root, path))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		present[path] = true
+		entry, ok := ix.Files[path]
+		if !ok || !entry.ModTime.Equal(info.ModTime()) || entry.Size != info.Size() {
+			stale = append(stale, path)
+		}
+	}
+	var removed []string
+	for path := range ix.Files {
+		if !present[path] {
+			removed = append(removed, path)
+		}
+	}
+	ix.mu.RUnlock()
+
+	stats := Stats{Removed: len(removed)}
+	ix.mu.Lock()
+	for _, path := range removed {
+		delete(ix.Files, path)
+	}
+	ix.mu.Unlock()
+
+	for i, path := range stale {
+		if err := ctx.Err(); err != nil {
+			// Keep the progress made so far
+			_ = ix.save()
+			return stats, err
+		}
+		if err := ix.indexFile(ctx, path); err != nil {
+			_ = ix.save()
+			return stats, fmt.Errorf("failed to index %s: %w", path, err)
+		}
+		stats.Embedded++
+		if progress != nil {
+			progress(i+1, len(stale))
+		}
+	}
+
+	ix.mu.Lock()
+	ix.Updated = time.Now()
+	ix.mu.Unlock()
+
+	stats.Files, stats.Chunks, _ = ix.Stats()
+	return stats, ix.save()
+}
+
+// indexFile chunks and embeds a single file, replacing its previous entry
+func (ix *Index) indexFile(ctx context.Context, path string) error {
+	fullPath := filepath.Join(ix.root, path)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	entry := &fileEntry{ModTime: info.ModTime(), Size: info.Size()}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return err
+	}
+
+	// Binary and very large files are recorded without chunks so they aren't retried every refresh
+	if len(content) <= maxFileBytes && !bytes.Contains(content, []byte{0}) {
+		ranges, texts := chunkFile(path, string(content))
+		for start := 0; start < len(texts); start += batchSize {
+			end := min(start+batchSize, len(texts))
+			vectors, err := ix.embed(ctx, texts[start:end])
+			if err != nil {
+				return err
+			}
+			for i, vector := range vectors {
+				entry.Chunks = append(entry.Chunks, Chunk{
+					StartLine: ranges[start+i][0],
+					EndLine:   ranges[start+i][1],
+					Vector:    normalize(vector),
+				})
+			}
+		}
+	}
+
+	ix.mu.Lock()
+	ix.Files[path] = entry
+	ix.mu.Unlock()
+	return nil
+}
+
+// chunkFile splits content into overlapping line windows. Each chunk's text starts with the file
+// path so the embedding captures where the code lives.
+func chunkFile(path, content string) ([][2]int, []string) {
+	lines := strings.Split(content, "\n")
+	var ranges [][2]int
+	var texts []string
+
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		if text != "" {
+			if len(text) > maxChunkChars {
+				text = text[:maxChunkChars]
+			}
+			ranges = append(ranges, [2]int{start + 1, end})
+			texts = append(texts, path+"\n"+text)
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return ranges, texts
+}
+
+// Search returns the chunks most similar to query, best first
+func (ix *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	if ix.Empty() {
+		return nil, fmt.Errorf("the semantic index is empty; ask the user to run /index to build it")
+	}
+
+	vectors, err := ix.embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	queryVector := normalize(vectors[0])
+
+	ix.mu.RLock()
+	var results []Result
+	for path, entry := range ix.Files {
+		for _, chunk := range entry.Chunks {
+			results = append(results, Result{
+				Path:      path,
+				StartLine: chunk.StartLine,
+				EndLine:   chunk.EndLine,
+				Score:     dot(queryVector, chunk.Vector),
+			})
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// save writes the index to disk atomically
+func (ix *Index) save() error {
+	ix.mu.RLock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(ix)
+	ix.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0755); err != nil {
+		return err
+	}
+	tmp := ix.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ix.path)
+}
+
+// listFiles returns workspace-relative paths of files to index, honoring .gitignore when the
+// workspace is a git repository
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	if output, err := cmd.Output(); err == nil {
+		var files []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if line != "" {
+				files = append(files, filepath.FromSlash(line))
+			}
+		}
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (skipDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	norm := math.Sqrt(sum)
+	if norm == 0 {
+		return vector
+	}
+	result := make([]float32, len(vector))
+	for i, v := range vector {
+		result[i] = float32(float64(v) / norm)
+	}
+	return result
+}
+
+// dot returns the dot product of two vectors, which is the cosine similarity for normalized vectors
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range min(len(a), len(b)) {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
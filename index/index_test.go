@@ -0,0 +1,82 @@
+package index
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wordEmbedder is a deterministic bag-of-words embedder for tests
+func wordEmbedder(calls *int) Embedder {
+	return func(ctx context.Context, texts []string) ([][]float32, error) {
+		*calls += len(texts)
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vector := make([]float32, 64)
+			for _, word := range strings.Fields(strings.ToLower(text)) {
+				h := fnv.New32a()
+				h.Write([]byte(word))
+				vector[h.Sum32()%64]++
+			}
+			vectors[i] = vector
+		}
+		return vectors, nil
+	}
+}
+
+func TestIndexRefreshAndSearch(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	storeDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "retry.go"), []byte("retry with exponential backoff\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "config.go"), []byte("parse json config file\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "dep.js"), []byte("retry backoff\n"), 0644))
+
+	calls := 0
+	ix, err := Open(root, storeDir, "test-model", wordEmbedder(&calls))
+	assert.NoError(t, err)
+
+	_, err = ix.Search(ctx, "retry", 5)
+	assert.Error(t, err, "searching an empty index should fail")
+
+	stats, err := ix.Refresh(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Files: 2, Chunks: 2, Embedded: 2}, stats)
+
+	results, err := ix.Search(ctx, "exponential backoff retry", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "retry.go", results[0].Path)
+	assert.Equal(t, 1, results[0].StartLine)
+
+	// Unchanged files are not re-embedded; deleted files are dropped
+	calls = 0
+	assert.NoError(t, os.Remove(filepath.Join(root, "config.go")))
+	stats, err = ix.Refresh(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Files: 1, Chunks: 1, Removed: 1}, stats)
+	assert.Equal(t, 0, calls)
+
+	// The index persists across opens, but only for the same embedding model
+	reopened, err := Open(root, storeDir, "test-model", wordEmbedder(&calls))
+	assert.NoError(t, err)
+	assert.False(t, reopened.Empty())
+	other, err := Open(root, storeDir, "other-model", wordEmbedder(&calls))
+	assert.NoError(t, err)
+	assert.True(t, other.Empty())
+}
+
+func TestChunkFile(t *testing.T) {
+	var lines []string
+	for i := 0; i < 120; i++ {
+		lines = append(lines, "line")
+	}
+	ranges, texts := chunkFile("a.go", strings.Join(lines, "\n"))
+	assert.Equal(t, [][2]int{{1, 50}, {41, 90}, {81, 120}}, ranges)
+	assert.True(t, strings.HasPrefix(texts[0], "a.go\n"))
+}
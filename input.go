@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Terminals with bracketed paste enabled wrap pasted text in these markers, so newlines inside a
+// paste can be told apart from the user pressing Enter
+const (
+	pasteStart            = "\x1b[200~"
+	pasteEnd              = "\x1b[201~"
+	enableBracketedPaste  = "\x1b[?2004h"
+	disableBracketedPaste = "\x1b[?2004l"
+)
+
+// readMessage reads one message from scanner, which may span several lines: a line ending in \
+// continues on the next line, a line starting with """ opens a block that runs until a line ending
+// in """, and pasted text is kept together however many lines it has. continuePrompt is called
+// before each further line is read. It returns the message and how many lines it took; ok is false
+// when input has ended.
+func readMessage(scanner lineScanner, continuePrompt func()) (message string, lines int, ok bool) {
+	if !scanner.Scan() {
+		return "", 0, false
+	}
+	lines = 1
+	next := func() bool {
+		continuePrompt()
+		if !scanner.Scan() {
+			return false
+		}
+		lines++
+		return true
+	}
+
+	if rest, isBlock := strings.CutPrefix(strings.TrimSpace(stripPasteMarkers(scanner.Text())), `"""`); isBlock {
+		var block []string
+		for {
+			if body, closed := strings.CutSuffix(strings.TrimRight(rest, " \t"), `"""`); closed {
+				block = append(block, body)
+				break
+			}
+			block = append(block, rest)
+			if !next() {
+				break
+			}
+			rest = stripPasteMarkers(scanner.Text())
+		}
+		return strings.Trim(strings.Join(block, "\n"), "\n"), lines, true
+	}
+
+	var text strings.Builder
+	for {
+		line := scanner.Text()
+		for {
+			before, pasted, found := strings.Cut(line, pasteStart)
+			if !found {
+				break
+			}
+			// Pasted newlines arrive as separate lines; keep reading until the paste ends
+			text.WriteString(before)
+			for {
+				chunk, typed, ended := strings.Cut(pasted, pasteEnd)
+				text.WriteString(chunk)
+				if ended {
+					line = typed
+					break
+				}
+				text.WriteString("\n")
+				if !scanner.Scan() {
+					return text.String(), lines, true
+				}
+				lines++
+				pasted = scanner.Text()
+			}
+		}
+
+		if continued, isContinued := strings.CutSuffix(line, `\`); isContinued {
+			text.WriteString(continued + "\n")
+			if !next() {
+				break
+			}
+			continue
+		}
+		text.WriteString(line)
+		break
+	}
+	return text.String(), lines, true
+}
+
+func stripPasteMarkers(line string) string {
+	return strings.NewReplacer(pasteStart, "", pasteEnd, "").Replace(line)
+}
+
+// lineScanner is the part of bufio.Scanner that input is read through
+type lineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// lineReader reads lines in the background, so a line typed while a turn runs is seen right away and
+// intercept can act on it (e.g. /stop cancelling the turn, or holding a message until the turn
+// ends). Lines intercept reports as handled are not returned by Scan. Reading starts with the first
+// Scan so nothing is read from a reader another part of the program ends up using instead.
+type lineReader struct {
+	reader    io.Reader
+	intercept func(line string) bool
+	start     sync.Once
+	mu        sync.Mutex
+	ready     *sync.Cond
+	pending   []string // lines Scan returns next
+	ended     bool
+	text      string
+	err       error
+}
+
+func newLineReader(reader io.Reader, intercept func(line string) bool) *lineReader {
+	r := &lineReader{reader: reader, intercept: intercept}
+	r.ready = sync.NewCond(&r.mu)
+	return r
+}
+
+func (r *lineReader) Scan() bool {
+	r.start.Do(func() { go r.read() })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.pending) == 0 && !r.ended {
+		r.ready.Wait()
+	}
+	if len(r.pending) == 0 {
+		return false
+	}
+	r.text, r.pending = r.pending[0], r.pending[1:]
+	return true
+}
+
+func (r *lineReader) Text() string {
+	return r.text
+}
+
+// Err reports the error that ended input, once Scan has returned false
+func (r *lineReader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Append adds lines for Scan to return after the ones already read, such as lines intercept held
+func (r *lineReader) Append(lines ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, lines...)
+	r.ready.Broadcast()
+}
+
+func (r *lineReader) read() {
+	scanner := bufio.NewScanner(r.reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // pasted lines can be long, like minified code or logs
+	for scanner.Scan() {
+		if r.intercept != nil && r.intercept(scanner.Text()) {
+			continue
+		}
+		r.Append(scanner.Text())
+	}
+	r.mu.Lock()
+	r.ended, r.err = true, scanner.Err()
+	r.ready.Broadcast()
+	r.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMessage(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		message string
+		lines   int
+	}{
+		{"single line", "hello\nnext", "hello", 1},
+		{"backslash continuation", "first \\\nsecond \\\nthird\nnext", "first \nsecond \nthird", 3},
+		{"triple quote block", "\"\"\"\npanic: oops\n\tmain.go:12\n\"\"\"\nnext", "panic: oops\n\tmain.go:12", 4},
+		{"triple quote on one line", "\"\"\"inline\"\"\"\nnext", "inline", 1},
+		{"text after opening quotes", "\"\"\"look at this\ncode\"\"\"\nnext", "look at this\ncode", 2},
+		{"bracketed paste", pasteStart + "line one\nline two\\\nline three" + pasteEnd + "\nnext", "line one\nline two\\\nline three", 3},
+		{"typing around a paste", "see " + pasteStart + "a\nb" + pasteEnd + " please\nnext", "see a\nb please", 2},
+		{"paste ending in a newline", pasteStart + "a\nb\n" + pasteEnd + "\nnext", "a\nb\n", 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(c.input))
+			prompts := 0
+			message, lines, ok := readMessage(scanner, func() { prompts++ })
+			assert.True(t, ok)
+			assert.Equal(t, c.message, message)
+			assert.Equal(t, c.lines, lines)
+
+			// The next message starts after everything that was consumed
+			next, _, ok := readMessage(scanner, func() {})
+			assert.True(t, ok)
+			assert.Equal(t, "next", next)
+		})
+	}
+}
+
+func TestReadMessageEndOfInput(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("\"\"\"\nunterminated"))
+	message, _, ok := readMessage(scanner, func() {})
+	assert.True(t, ok)
+	assert.Equal(t, "unterminated", message)
+
+	_, _, ok = readMessage(scanner, func() {})
+	assert.False(t, ok)
+}
+
+func TestLineReaderIntercept(t *testing.T) {
+	var intercepted []string
+	reader := newLineReader(strings.NewReader("first\n/stop\nsecond\n"), func(line string) bool {
+		if line == "/stop" {
+			intercepted = append(intercepted, line)
+			return true
+		}
+		return false
+	})
+
+	var lines []string
+	for reader.Scan() {
+		lines = append(lines, reader.Text())
+	}
+	assert.NoError(t, reader.Err())
+	assert.Equal(t, []string{"first", "second"}, lines)
+	assert.Equal(t, []string{"/stop"}, intercepted)
+}
+
+func TestLineReaderLongLines(t *testing.T) {
+	long := strings.Repeat("x", 1024*1024)
+	reader := newLineReader(strings.NewReader(long+"\nnext\n"), nil)
+
+	var lines []string
+	for reader.Scan() {
+		lines = append(lines, reader.Text())
+	}
+	assert.NoError(t, reader.Err())
+	assert.Equal(t, []string{long, "next"}, lines)
+}
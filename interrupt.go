@@ -0,0 +1,81 @@
+package main
+
+import (
+	"agent/theme"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exitWindow is how soon a second Ctrl+C has to follow the first to exit
+const exitWindow = 2 * time.Second
+
+// interruptedMarker ends a response the user interrupted when it's sent back to the model
+const interruptedMarker = "\n\n[response interrupted by the user]"
+
+// interruptAction is what a Ctrl+C did
+type interruptAction int
+
+const (
+	interruptCancelled interruptAction = iota // stopped the running turn
+	interruptArmed                            // nothing was running; another Ctrl+C exits
+	interruptExit                             // second Ctrl+C within exitWindow
+)
+
+// interruptTracker decides what each Ctrl+C does. A single Ctrl+C never exits, even when the turn it
+// was meant to stop has just finished; only two within exitWindow do.
+type interruptTracker struct {
+	mu   sync.Mutex
+	last time.Time
+	now  func() time.Time // for tests; time.Now when nil
+}
+
+func (t *interruptTracker) interrupt(cancelTurn func() bool) interruptAction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	if !t.last.IsZero() && now.Sub(t.last) < exitWindow {
+		t.last = time.Time{}
+		return interruptExit
+	}
+	t.last = now
+	if cancelTurn() {
+		return interruptCancelled
+	}
+	return interruptArmed
+}
+
+// Interrupt handles a Ctrl+C: it stops the running turn, or exits when it follows another Ctrl+C
+// within exitWindow
+func (a *Agent) Interrupt() interruptAction {
+	return a.interrupts.interrupt(a.CancelTurn)
+}
+
+// interruptNotice is shown after a Ctrl+C that didn't exit
+func interruptNotice(action interruptAction) string {
+	if action == interruptArmed {
+		return theme.InfoText("Press Ctrl+C again to exit, or type /quit")
+	}
+	return theme.InfoText("Stopping… press Ctrl+C again to exit")
+}
+
+// interceptStop cancels the running turn when /stop is typed during it. A confirmation waiting for
+// an answer still gets the line, which declines it.
+func (a *Agent) interceptStop(line string) bool {
+	if strings.TrimSpace(line) != "/stop" {
+		return false
+	}
+	asking := a.isAsking()
+	return a.CancelTurn() && !asking
+}
+
+// handleStop runs when /stop is typed between turns, since a /stop typed during one is intercepted
+func handleStop(a *Agent, args []string) string {
+	if a.CancelTurn() {
+		return theme.WarningText("Stopping the running turn")
+	}
+	return theme.InfoText("Nothing is running")
+}
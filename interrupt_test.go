@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterruptTracker(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := interruptTracker{now: func() time.Time { return now }}
+	running := true
+	cancel := func() bool {
+		wasRunning := running
+		running = false
+		return wasRunning
+	}
+
+	assert.Equal(t, interruptCancelled, tracker.interrupt(cancel))
+	now = now.Add(3 * time.Second)
+	assert.Equal(t, interruptArmed, tracker.interrupt(cancel), "a single Ctrl+C when idle doesn't exit")
+	now = now.Add(time.Second)
+	assert.Equal(t, interruptExit, tracker.interrupt(cancel))
+
+	running = true
+	assert.Equal(t, interruptCancelled, tracker.interrupt(cancel))
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, interruptExit, tracker.interrupt(cancel), "a second Ctrl+C exits even while stopping")
+}
+
+func TestInterceptStop(t *testing.T) {
+	a := &Agent{}
+	assert.False(t, a.interceptStop("/stop"), "between turns /stop goes to the command")
+
+	a.inProgress, a.cancelFunc = true, func() {}
+	assert.False(t, a.interceptStop("hello"))
+	assert.True(t, a.interceptStop(" /stop "))
+
+	a.asking = true
+	assert.False(t, a.interceptStop("/stop"), "a waiting confirmation still gets the line")
+}
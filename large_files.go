@@ -0,0 +1,124 @@
+package main
+
+import (
+	"agent/tools"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxFileSize is the largest file live context includes in full unless it was read with force
+const DefaultMaxFileSize = 256 * 1024
+
+const (
+	sniffBytes        = 8000             // how much of a file is checked for binary content
+	maxSummarizedSize = 16 * 1024 * 1024 // larger files are described by their size only
+	summarySymbols    = 200              // symbols listed in the outline of a large file
+	summaryHeadLines  = 20               // lines shown from large files that can't be outlined
+)
+
+// SetMaxFileSize sets the largest file included in full; 0 restores the default and a negative size
+// removes the limit
+func (lc *LiveContext) SetMaxFileSize(size int) {
+	if size == 0 {
+		size = DefaultMaxFileSize
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.maxFileSize = size
+}
+
+// SetForce includes a file in live context in full even when it is over the size limit
+func (lc *LiveContext) SetForce(path string, force bool) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	fileInfo, exists := lc.files[path]
+	if !exists {
+		return fmt.Errorf("%s not found in live context", path)
+	}
+	fileInfo.Force = force
+	lc.files[path] = fileInfo
+	return nil
+}
+
+// readSample returns the start of a file, which is enough to tell text from binary content
+func readSample(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sample := make([]byte, sniffBytes)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return sample[:n], nil
+}
+
+// isBinary reports whether a sample from the start of a file looks like binary content: it has NUL
+// bytes or much of it isn't valid UTF-8
+func isBinary(sample []byte) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	invalid := 0
+	// The sample may end partway through a character
+	for rest := sample; len(rest) > utf8.UTFMax; {
+		r, size := utf8.DecodeRune(rest)
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		rest = rest[size:]
+	}
+	return invalid*10 > len(sample)
+}
+
+// describeBinary stands in for the contents of a binary file
+func describeBinary(size int64, sample []byte) string {
+	return fmt.Sprintf("[Binary file: %s, %s. Its contents aren't shown.]", http.DetectContentType(sample), formatBytes(int(size)))
+}
+
+// summarizeLargeFile stands in for the contents of a file over the size limit, with its outline when
+// the language is supported or its first lines otherwise
+func summarizeLargeFile(path string, size int64, limit int) string {
+	hint := "Read a section with read_file start_line and end_line, or read_file with force: true to include the whole file."
+	header := fmt.Sprintf("[Large file: %s, over the %s limit for one file in live context.", formatBytes(int(size)), formatBytes(limit))
+	if size > maxSummarizedSize {
+		return header + "]\n" + hint
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return header + "]\n" + hint
+	}
+	lines := strings.Split(string(content), "\n")
+	header = strings.TrimSuffix(header, ".") + fmt.Sprintf(", %d lines.]", len(lines))
+
+	var summary strings.Builder
+	summary.WriteString(header + "\n")
+	if symbols, err := tools.Outline(context.Background(), path, content); err == nil && len(symbols) > 0 {
+		summary.WriteString("Outline:\n")
+		for i, symbol := range symbols {
+			if i == summarySymbols {
+				summary.WriteString(fmt.Sprintf("  ... %d more symbols\n", len(symbols)-i))
+				break
+			}
+			summary.WriteString(fmt.Sprintf("%s%s [%d-%d]\n", strings.Repeat("  ", symbol.Depth+1), symbol.Signature, symbol.StartLine, symbol.EndLine))
+		}
+	} else {
+		summary.WriteString(fmt.Sprintf("First %d lines:\n", min(summaryHeadLines, len(lines))))
+		for i, line := range lines[:min(summaryHeadLines, len(lines))] {
+			if len(line) > 200 {
+				line = line[:200] + "..."
+			}
+			summary.WriteString(fmt.Sprintf("%d: %s\n", i+1, line))
+		}
+	}
+	summary.WriteString(hint)
+	return summary.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFileWithOptionsBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	require.NoError(t, os.WriteFile(path, append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), make([]byte, 100)...), 0644))
+
+	lc := &LiveContext{files: make(map[string]FileInfo), maxFileSize: DefaultMaxFileSize}
+	content, err := lc.readFileWithOptions(FileInfo{Path: path, StartLine: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "[Binary file: image/png, 116B. Its contents aren't shown.]", content)
+}
+
+func TestReadFileWithOptionsLargeFile(t *testing.T) {
+	dir := t.TempDir()
+	var source strings.Builder
+	source.WriteString("package big\n")
+	for i := 0; i < 50; i++ {
+		source.WriteString(fmt.Sprintf("\nfunc F%d() int {\n\treturn %d\n}\n", i, i))
+	}
+	goFile := filepath.Join(dir, "big.go")
+	require.NoError(t, os.WriteFile(goFile, []byte(source.String()), 0644))
+	logFile := filepath.Join(dir, "big.log")
+	require.NoError(t, os.WriteFile(logFile, []byte(strings.Repeat("a log line\n", 200)), 0644))
+
+	lc := NewLiveContext()
+	defer lc.Close()
+	lc.SetMaxFileSize(1000)
+	require.NoError(t, lc.AddFile(goFile, 1, nil))
+	require.NoError(t, lc.AddFile(logFile, 1, nil))
+
+	// Over the limit, code is outlined and other files show their first lines
+	content, err := lc.readFileWithOptions(lc.files[goFile])
+	require.NoError(t, err)
+	assert.Contains(t, content, "[Large file:")
+	assert.Contains(t, content, "func F49() int [")
+	assert.NotContains(t, content, "return 49")
+	content, err = lc.readFileWithOptions(lc.files[logFile])
+	require.NoError(t, err)
+	assert.Contains(t, content, "First 20 lines:\n1: a log line")
+	assert.Contains(t, content, "force: true")
+
+	// A line range or force reads the file itself
+	end := 3
+	require.NoError(t, lc.AddFile(goFile, 2, &end))
+	content, err = lc.readFileWithOptions(lc.files[goFile])
+	require.NoError(t, err)
+	assert.Equal(t, "2: \n3: func F0() int {", content)
+	require.NoError(t, lc.AddFile(goFile, 1, nil))
+	require.NoError(t, lc.SetForce(goFile, true))
+	content, err = lc.readFileWithOptions(lc.files[goFile])
+	require.NoError(t, err)
+	assert.Contains(t, content, "return 49")
+}
@@ -1,20 +1,60 @@
 package main
 
 import (
+	"agent/logging"
+	"agent/tools"
+	"cmp"
 	"fmt"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
 )
 
-// MaxContextSize is the maximum allowed context size in bytes
+// MaxContextSize is the default context size in bytes used when no budget is configured
 const MaxContextSize = 100 * 1024 // 100kB
 
+// Priority levels for live-context entries. Low-priority entries are the first to be cut to symbols
+// or omitted when live context is over budget, and the first the pruner removes; pinned entries
+// claim the budget before all others and are never removed by the pruner.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
 // FileInfo holds information about a file in live context
 type FileInfo struct {
 	Path      string
 	StartLine int
 	EndLine   *int // nil means read to end
+	Pinned    bool
+	Priority  string
+	Force     bool // include the whole file even when it is over the size limit
+}
+
+// Statuses of a live-context entry in a serialization
+const (
+	EntryIncluded = "included"
+	EntryPartial  = "partial" // cut to the whole symbols that fit the budget
+	EntryOmitted  = "omitted" // over the live context budget
+	EntryError    = "error"   // couldn't be read; the error was sent instead
+)
+
+// ContextEntry records how a live-context file or directory was serialized
+type ContextEntry struct {
+	Path      string
+	Directory bool
+	Status    string
+	Chars     int
+	Untrusted bool   // wrapped as untrusted content after a prompt-injection finding
+	Priority  string // pinned, high, normal, or low; entries claim the budget in that order
 }
 
 // DirectoryInfo holds information about a directory in live context
@@ -22,19 +62,174 @@ type DirectoryInfo struct {
 	Path            string
 	IgnoreGitignore bool
 	IgnorePatterns  []string
+	Pinned          bool
+	Priority        string
+	MaxItems        int      // entries listed before the structure is cut short; 0 for DefaultTreeItems
+	MaxDepth        int      // levels listed below the directory; 0 for DefaultTreeDepth
+	Expanded        []string // subdirectories listed in full in their own sections
+	IncludeHidden   *bool    // nil follows the live context's setting
+	SkipDefaults    bool     // list entries the default ignore patterns would skip
 }
 
-// LiveContext manages files and directories for the agent
+// LiveContext manages files and directories for the agent. It is safe for concurrent use: tools
+// change it while the pruner and serialization read it from other goroutines.
 type LiveContext struct {
+	// mu guards files, directories, and the settings below them. Serialization works on copies
+	// taken under the read lock, so reading files from disk doesn't block changes.
+	mu          sync.RWMutex
 	files       map[string]FileInfo
 	directories map[string]DirectoryInfo
+	maxSize     int
+	maxFileSize int // larger files are summarized unless read with force; negative for no limit
+
+	// ignorePatterns are names skipped in every directory structure, from ignore_patterns in the config
+	ignorePatterns []string
+	// defaultIgnores replace DefaultIgnorePatterns when set, from default_ignores in the config
+	defaultIgnores []string
+	hideDotfiles   bool
+
+	// watcher marks live-context files as changed when they are modified outside the agent
+	watcher   *fsnotify.Watcher
+	changesMu sync.Mutex
+	changed   map[string]bool
+
+	// injections holds the prompt-injection findings for files in the last serialization
+	scanInjection bool
+	injectionsMu  sync.Mutex
+	injections    map[string][]string
+
+	// entries records what the last budgeted serialization included
+	entriesMu sync.Mutex
+	entries   []ContextEntry
 }
 
 // NewLiveContext creates a new LiveContext instance
 func NewLiveContext() *LiveContext {
-	return &LiveContext{
-		files:       make(map[string]FileInfo),
-		directories: make(map[string]DirectoryInfo),
+	lc := &LiveContext{
+		files:         make(map[string]FileInfo),
+		directories:   make(map[string]DirectoryInfo),
+		maxSize:       MaxContextSize,
+		maxFileSize:   DefaultMaxFileSize,
+		changed:       make(map[string]bool),
+		scanInjection: true,
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logging.Warnf("File watching disabled: %v", err)
+		return lc
+	}
+	lc.watcher = watcher
+	go lc.watchFiles()
+
+	return lc
+}
+
+// watchFiles records changes to watched files until the watcher is closed
+func (lc *LiveContext) watchFiles() {
+	for {
+		select {
+		case event, ok := <-lc.watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			absPath, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+			lc.changesMu.Lock()
+			lc.changed[absPath] = true
+			lc.changesMu.Unlock()
+		case _, ok := <-lc.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// ResetChanges forgets changes observed so far, e.g. those made by the agent during its own turn
+func (lc *LiveContext) ResetChanges() {
+	lc.changesMu.Lock()
+	defer lc.changesMu.Unlock()
+	lc.changed = make(map[string]bool)
+}
+
+// ChangedFiles returns the live-context files modified since the last ResetChanges
+func (lc *LiveContext) ChangedFiles() []string {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	lc.changesMu.Lock()
+	defer lc.changesMu.Unlock()
+
+	var changed []string
+	for filePath := range lc.files {
+		if absPath, err := filepath.Abs(filePath); err == nil && lc.changed[absPath] {
+			changed = append(changed, filePath)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Close stops watching files
+func (lc *LiveContext) Close() error {
+	if lc.watcher == nil {
+		return nil
+	}
+	return lc.watcher.Close()
+}
+
+// SetInjectionScan turns scanning files for prompt injection on or off
+func (lc *LiveContext) SetInjectionScan(enabled bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.scanInjection = enabled
+}
+
+// SetIgnorePatterns sets names skipped in every directory structure, on top of each directory's own
+func (lc *LiveContext) SetIgnorePatterns(patterns []string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.ignorePatterns = patterns
+}
+
+// SetDefaultIgnores replaces the patterns directory structures skip unless a directory was read
+// without them; nil restores DefaultIgnorePatterns
+func (lc *LiveContext) SetDefaultIgnores(patterns []string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.defaultIgnores = patterns
+}
+
+// SetIncludeHidden sets whether directory structures list dot-prefixed entries that aren't ignored
+func (lc *LiveContext) SetIncludeHidden(include bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.hideDotfiles = !include
+}
+
+// Injections returns the files that contained possible prompt injection when the live context was
+// last serialized, with the kinds of patterns found
+func (lc *LiveContext) Injections() map[string][]string {
+	lc.injectionsMu.Lock()
+	defer lc.injectionsMu.Unlock()
+	injections := make(map[string][]string, len(lc.injections))
+	for path, findings := range lc.injections {
+		injections[path] = findings
+	}
+	return injections
+}
+
+// SetMaxSize sets the size that context usage is measured against
+func (lc *LiveContext) SetMaxSize(maxSize int) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if maxSize > 0 {
+		lc.maxSize = maxSize
 	}
 }
 
@@ -48,25 +243,58 @@ func (lc *LiveContext) AddFile(filePath string, startLine int, endLine *int) err
 		startLine = 1
 	}
 
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	// Re-reading a file with a new line range keeps its pin and priority
+	existing := lc.files[filePath]
 	lc.files[filePath] = FileInfo{
 		Path:      filePath,
 		StartLine: startLine,
 		EndLine:   endLine,
+		Pinned:    existing.Pinned,
+		Priority:  existing.Priority,
+		Force:     existing.Force,
+	}
+
+	// Watch the parent directory since editors often replace files rather than writing in place
+	if lc.watcher != nil {
+		if err := lc.watcher.Add(filepath.Dir(filePath)); err != nil {
+			logging.Warnf("Failed to watch %s: %v", filePath, err)
+		}
 	}
 	return nil
 }
 
 // RemoveFile removes a file from live context
 func (lc *LiveContext) RemoveFile(filePath string) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
 	if _, exists := lc.files[filePath]; !exists {
 		return fmt.Errorf("file %s not found in live context", filePath)
 	}
 	delete(lc.files, filePath)
+
+	if lc.watcher != nil {
+		dir := filepath.Dir(filePath)
+		stillWatched := false
+		for other := range lc.files {
+			if filepath.Dir(other) == dir {
+				stillWatched = true
+				break
+			}
+		}
+		if !stillWatched {
+			_ = lc.watcher.Remove(dir)
+		}
+	}
 	return nil
 }
 
 // ListFiles returns all files in live context
 func (lc *LiveContext) ListFiles() []string {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
 	files := make([]string, 0, len(lc.files))
 	for filePath := range lc.files {
 		files = append(files, filePath)
@@ -80,25 +308,173 @@ func (lc *LiveContext) AddDirectory(dirPath string, ignoreGitignore bool, ignore
 		return fmt.Errorf("directory path cannot be empty")
 	}
 
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	existing := lc.directories[dirPath]
 	lc.directories[dirPath] = DirectoryInfo{
 		Path:            dirPath,
 		IgnoreGitignore: ignoreGitignore,
 		IgnorePatterns:  ignorePatterns,
+		Pinned:          existing.Pinned,
+		Priority:        existing.Priority,
+		MaxItems:        existing.MaxItems,
+		MaxDepth:        existing.MaxDepth,
+		Expanded:        existing.Expanded,
+		IncludeHidden:   existing.IncludeHidden,
+		SkipDefaults:    existing.SkipDefaults,
+	}
+	return nil
+}
+
+// SetDirectoryVisibility sets whether a directory in live context lists hidden entries and whether it
+// skips the default ignore patterns; nil leaves a setting unchanged
+func (lc *LiveContext) SetDirectoryVisibility(path string, includeHidden, defaultIgnores *bool) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	dirInfo, exists := lc.directories[path]
+	if !exists {
+		return fmt.Errorf("directory %s not found in live context", path)
+	}
+	if includeHidden != nil {
+		dirInfo.IncludeHidden = includeHidden
+	}
+	if defaultIgnores != nil {
+		dirInfo.SkipDefaults = !*defaultIgnores
+	}
+	lc.directories[path] = dirInfo
+	return nil
+}
+
+// SetDirectoryLimits sets how many entries and levels of a directory in live context are listed;
+// zero keeps the default
+func (lc *LiveContext) SetDirectoryLimits(path string, maxItems, maxDepth int) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	dirInfo, exists := lc.directories[path]
+	if !exists {
+		return fmt.Errorf("directory %s not found in live context", path)
+	}
+	if maxItems < 0 || maxDepth < 0 {
+		return fmt.Errorf("limits can't be negative")
 	}
+	dirInfo.MaxItems, dirInfo.MaxDepth = maxItems, maxDepth
+	lc.directories[path] = dirInfo
 	return nil
 }
 
+// ExpandDirectory lists a subdirectory of a directory in live context in its own section, for
+// subtrees the directory's structure cut short. It returns the directory it was expanded in, or ""
+// when no directory in live context contains it and it was added as a directory of its own.
+func (lc *LiveContext) ExpandDirectory(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(absPath); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	// The innermost directory in live context that contains path gets the expansion
+	parent, parentLength := "", -1
+	for dirPath := range lc.directories {
+		absDir, err := filepath.Abs(dirPath)
+		if err != nil || absDir == absPath {
+			continue
+		}
+		if rel, err := filepath.Rel(absDir, absPath); err == nil && !strings.HasPrefix(rel, "..") && len(absDir) > parentLength {
+			parent, parentLength = dirPath, len(absDir)
+		}
+	}
+	if parent == "" {
+		if _, exists := lc.directories[path]; !exists {
+			lc.directories[path] = DirectoryInfo{Path: path}
+		}
+		return "", nil
+	}
+
+	dirInfo := lc.directories[parent]
+	for _, expanded := range dirInfo.Expanded {
+		if expanded == path {
+			return parent, nil
+		}
+	}
+	// Copies handed out by Directories share the old slice, so it is replaced rather than changed
+	dirInfo.Expanded = append(slices.Clone(dirInfo.Expanded), path)
+	sort.Strings(dirInfo.Expanded)
+	lc.directories[parent] = dirInfo
+	return parent, nil
+}
+
+// SetPriority updates the pin flag and priority of a file or directory already in live context
+func (lc *LiveContext) SetPriority(path string, pinned bool, priority string) error {
+	switch priority {
+	case "":
+		priority = PriorityNormal
+	case PriorityLow, PriorityNormal, PriorityHigh:
+	default:
+		return fmt.Errorf("priority must be one of: low, normal, high")
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if fileInfo, exists := lc.files[path]; exists {
+		fileInfo.Pinned = pinned
+		fileInfo.Priority = priority
+		lc.files[path] = fileInfo
+		return nil
+	}
+	if dirInfo, exists := lc.directories[path]; exists {
+		dirInfo.Pinned = pinned
+		dirInfo.Priority = priority
+		lc.directories[path] = dirInfo
+		return nil
+	}
+	return fmt.Errorf("%s not found in live context", path)
+}
+
+// GetPriority returns the pin flag and priority of a file or directory in live context
+func (lc *LiveContext) GetPriority(path string) (bool, string) {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	if fileInfo, exists := lc.files[path]; exists {
+		return fileInfo.Pinned, cmp.Or(fileInfo.Priority, PriorityNormal)
+	}
+	if dirInfo, exists := lc.directories[path]; exists {
+		return dirInfo.Pinned, cmp.Or(dirInfo.Priority, PriorityNormal)
+	}
+	return false, PriorityNormal
+}
+
 // RemoveDirectory removes a directory from live context
 func (lc *LiveContext) RemoveDirectory(dirPath string) error {
-	if _, exists := lc.directories[dirPath]; !exists {
-		return fmt.Errorf("directory %s not found in live context", dirPath)
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if _, exists := lc.directories[dirPath]; exists {
+		delete(lc.directories, dirPath)
+		return nil
 	}
-	delete(lc.directories, dirPath)
-	return nil
+	// An expanded subdirectory is collapsed back into its directory's structure
+	for parent, dirInfo := range lc.directories {
+		for i, expanded := range dirInfo.Expanded {
+			if expanded == dirPath {
+				dirInfo.Expanded = append(dirInfo.Expanded[:i:i], dirInfo.Expanded[i+1:]...)
+				lc.directories[parent] = dirInfo
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("directory %s not found in live context", dirPath)
 }
 
 // ListDirectories returns all directories in live context
 func (lc *LiveContext) ListDirectories() []string {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
 	dirs := make([]string, 0, len(lc.directories))
 	for dirPath := range lc.directories {
 		dirs = append(dirs, dirPath)
@@ -106,95 +482,311 @@ func (lc *LiveContext) ListDirectories() []string {
 	return dirs
 }
 
+// Files returns a copy of the files in live context, which doesn't change as live context does
+func (lc *LiveContext) Files() map[string]FileInfo {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return maps.Clone(lc.files)
+}
+
+// Directories returns a copy of the directories in live context, which doesn't change as live
+// context does
+func (lc *LiveContext) Directories() map[string]DirectoryInfo {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return maps.Clone(lc.directories)
+}
+
 // SerializeFiles generates the files section of live context
 func (lc *LiveContext) SerializeFiles() string {
-	var sections []string
+	files, _, _ := lc.serialize(math.MaxInt)
+	return files
+}
 
-	sections = append(sections, "\n--- FILES ---")
-	for filePath, fileInfo := range lc.files {
-		endLineString := "end"
-		if fileInfo.EndLine != nil {
-			endLineString = fmt.Sprintf("%d", *fileInfo.EndLine)
-		}
-		sections = append(sections, fmt.Sprintf("\n--- FILE: %s [Lines %d:%s]---", filePath, fileInfo.StartLine, endLineString))
+// SerializeDirectories generates the directories section of live context
+func (lc *LiveContext) SerializeDirectories() string {
+	_, dirs, _ := lc.serialize(math.MaxInt)
+	return dirs
+}
 
-		content, err := lc.readFileWithOptions(fileInfo)
-		if err != nil {
-			sections = append(sections, fmt.Sprintf("Error reading file: %v", err))
-		} else {
-			sections = append(sections, content)
+// SerializeWithinBudget serializes directories and files, omitting entries that would push the total
+// past budget characters. Pinned entries are always sent whole; the other entries share what they
+// leave. A budget of zero or less means unlimited. Omitted paths are returned.
+func (lc *LiveContext) SerializeWithinBudget(budget int) (string, string, []string) {
+	if budget <= 0 {
+		budget = math.MaxInt
+	}
+	files, dirs, entries := lc.serialize(budget)
+
+	lc.entriesMu.Lock()
+	lc.entries = entries
+	lc.entriesMu.Unlock()
+
+	var omitted []string
+	for _, entry := range entries {
+		if entry.Status == EntryOmitted {
+			omitted = append(omitted, entry.Path)
 		}
 	}
+	return files, dirs, omitted
+}
+
+// LastSerialization reports how each entry was serialized by the last SerializeWithinBudget call
+func (lc *LiveContext) LastSerialization() []ContextEntry {
+	lc.entriesMu.Lock()
+	defer lc.entriesMu.Unlock()
+	return append([]ContextEntry(nil), lc.entries...)
+}
 
-	if len(lc.files) == 0 {
-		sections = append(sections, "No files in live context")
+const (
+	filesHeader       = "\n--- FILES ---"
+	directoriesHeader = "\n--- DIRECTORY STRUCTURES ---"
+)
+
+// contextBudget tracks the characters of live context serialized so far against the budget
+type contextBudget struct {
+	limit int
+	used  int
+}
+
+func (b *contextBudget) fits(size int) bool {
+	return b.used+size <= b.limit
+}
+
+// claim charges a section and the newline joining it to the next
+func (b *contextBudget) claim(section string) {
+	b.used += len(section) + 1
+}
+
+// serialize renders the files and directories sections. Pinned entries are rendered first and
+// always whole, then the others claim what's left in priority order, directories before files, so
+// low-priority entries are the first to be cut to symbols or omitted. Each section is sent in path
+// order.
+func (lc *LiveContext) serialize(limit int) (string, string, []ContextEntry) {
+	files := lc.Files()
+	directories := lc.Directories()
+	budget := &contextBudget{limit: limit, used: len(filesHeader) + len(directoriesHeader)}
+
+	lc.mu.RLock()
+	scanInjection := lc.scanInjection
+	lc.mu.RUnlock()
+	injections := make(map[string][]string)
+
+	renderedFiles, renderedDirs := make(map[string]string), make(map[string]string)
+	var fileEntries, dirEntries []ContextEntry
+	for _, pinned := range []bool{true, false} {
+		var dirPaths []string
+		for path, info := range directories {
+			if info.Pinned == pinned {
+				dirPaths = append(dirPaths, path)
+			}
+		}
+		for _, dirPath := range budgetOrder(dirPaths, func(path string) string {
+			return priorityName(directories[path].Pinned, directories[path].Priority)
+		}) {
+			section, entry := lc.serializeDirectory(dirPath, directories[dirPath], budget)
+			renderedDirs[dirPath] = section
+			dirEntries = append(dirEntries, entry)
+		}
+
+		var filePaths []string
+		for path, info := range files {
+			if info.Pinned == pinned {
+				filePaths = append(filePaths, path)
+			}
+		}
+		for _, filePath := range budgetOrder(filePaths, func(path string) string {
+			return priorityName(files[path].Pinned, files[path].Priority)
+		}) {
+			section, entry := lc.serializeFile(filePath, files[filePath], budget, scanInjection, injections)
+			renderedFiles[filePath] = section
+			fileEntries = append(fileEntries, entry)
+		}
 	}
 
-	return strings.Join(sections, "\n")
+	lc.injectionsMu.Lock()
+	lc.injections = injections
+	lc.injectionsMu.Unlock()
+
+	fileSections, fileEntries := inPathOrder([]string{filesHeader}, renderedFiles, fileEntries)
+	if len(files) == 0 {
+		fileSections = append(fileSections, "No files in live context")
+	}
+	dirSections, dirEntries := inPathOrder([]string{directoriesHeader}, renderedDirs, dirEntries)
+	if len(directories) == 0 {
+		dirSections = append(dirSections, "No directories in live context")
+	}
+	return strings.Join(fileSections, "\n"), strings.Join(dirSections, "\n"), append(dirEntries, fileEntries...)
 }
 
-// SerializeDirectories generates the directories section of live context
-func (lc *LiveContext) SerializeDirectories() string {
-	var sections []string
+// serializeFile renders a file in live context, cut to whole symbols or omitted when it doesn't
+// fit the budget unless it's pinned
+func (lc *LiveContext) serializeFile(filePath string, fileInfo FileInfo, budget *contextBudget, scanInjection bool, injections map[string][]string) (string, ContextEntry) {
+	endLineString := "end"
+	if fileInfo.EndLine != nil {
+		endLineString = fmt.Sprintf("%d", *fileInfo.EndLine)
+	}
+	section := fmt.Sprintf("\n--- FILE: %s [Lines %d:%s]---", filePath, fileInfo.StartLine, endLineString)
+	entry := ContextEntry{Path: filePath, Status: EntryIncluded, Priority: priorityName(fileInfo.Pinned, fileInfo.Priority)}
+
+	content, err := lc.readFileWithOptions(fileInfo)
+	if err != nil {
+		section += "\n" + fmt.Sprintf("Error reading file: %v", err)
+		entry.Status = EntryError
+	} else {
+		if !fileInfo.Pinned && !budget.fits(len(section)+1+len(content)) {
+			// Keep the whole functions and types that fit rather than dropping the file
+			partial := fmt.Sprintf("\n--- FILE: %s [Lines %d:%s, partial: whole symbols within the live context budget]---", filePath, fileInfo.StartLine, endLineString)
+			if chunked, ok := readFileChunks(fileInfo, budget.limit-budget.used-len(partial)-1); ok {
+				section, content = partial, chunked
+				entry.Status = EntryPartial
+			}
+		}
+		if scanInjection {
+			if findings := tools.DetectInjection(content); len(findings) > 0 {
+				injections[filePath] = findings
+				content = tools.WrapUntrusted("file "+filePath, content, findings)
+				entry.Untrusted = true
+			}
+		}
+		section += "\n" + content
+	}
 
-	sections = append(sections, "\n--- DIRECTORY STRUCTURES ---")
-	for dirPath, dirInfo := range lc.directories {
-		sections = append(sections, fmt.Sprintf("\n--- DIRECTORY: %s ---", dirPath))
+	if !fileInfo.Pinned && !budget.fits(len(section)) {
+		entry.Status = EntryOmitted
+		section = fmt.Sprintf("\n--- FILE: %s (omitted: over live context budget) ---", filePath)
+	}
+	entry.Chars = len(section)
+	budget.claim(section)
+	return section, entry
+}
 
-		structure, err := generateDirectoryTree(
-			dirInfo.Path,
-			dirInfo.IgnoreGitignore,
-			dirInfo.IgnorePatterns,
-		)
+// serializeDirectory renders a directory tree in live context, omitted when it doesn't fit the
+// budget unless it's pinned
+func (lc *LiveContext) serializeDirectory(dirPath string, dirInfo DirectoryInfo, budget *contextBudget) (string, ContextEntry) {
+	lc.mu.RLock()
+	ignorePatterns, defaultIgnores, hideDotfiles := lc.ignorePatterns, lc.defaultIgnores, lc.hideDotfiles
+	lc.mu.RUnlock()
+
+	section := fmt.Sprintf("\n--- DIRECTORY: %s ---", dirPath)
+	entry := ContextEntry{Path: dirPath, Directory: true, Status: EntryIncluded, Priority: priorityName(dirInfo.Pinned, dirInfo.Priority)}
+
+	options := treeOptions{
+		ignorePatterns: append(append([]string(nil), ignorePatterns...), dirInfo.IgnorePatterns...),
+		hideDotfiles:   hideDotfiles,
+		maxItems:       dirInfo.MaxItems,
+		maxDepth:       dirInfo.MaxDepth,
+		expanded:       make(map[string]bool),
+	}
+	if !dirInfo.SkipDefaults {
+		defaults := defaultIgnores
+		if defaults == nil {
+			defaults = DefaultIgnorePatterns
+		}
+		options.ignorePatterns = append(options.ignorePatterns, defaults...)
+	}
+	if dirInfo.IncludeHidden != nil {
+		options.hideDotfiles = !*dirInfo.IncludeHidden
+	}
+	for _, expanded := range dirInfo.Expanded {
+		if absPath, err := filepath.Abs(expanded); err == nil {
+			options.expanded[absPath] = true
+		}
+	}
+	structure, err := generateDirectoryTree(dirInfo.Path, options)
+	for _, expanded := range dirInfo.Expanded {
 		if err != nil {
-			sections = append(sections, fmt.Sprintf("Error reading directory: %v", err))
-			// TODO how to handle warnings LogWarning("live_context", "directory_read", err)
-		} else {
-			sections = append(sections, structure)
+			break
+		}
+		subtree, subErr := generateDirectoryTree(expanded, options)
+		if subErr != nil {
+			subtree = fmt.Sprintf("Error reading directory: %v", subErr)
 		}
+		structure += fmt.Sprintf("\n--- EXPANDED: %s ---\n%s", expanded, subtree)
+	}
+	if err != nil {
+		section += "\n" + fmt.Sprintf("Error reading directory: %v", err)
+		entry.Status = EntryError
+		// TODO how to handle warnings LogWarning("live_context", "directory_read", err)
+	} else {
+		section += "\n" + structure
 	}
 
-	if len(lc.directories) == 0 {
-		sections = append(sections, "No directories in live context")
+	if !dirInfo.Pinned && !budget.fits(len(section)) {
+		entry.Status = EntryOmitted
+		section = fmt.Sprintf("\n--- DIRECTORY: %s (omitted: over live context budget) ---", dirPath)
 	}
+	entry.Chars = len(section)
+	budget.claim(section)
+	return section, entry
+}
+
+// priorityRanks orders entries for the live context budget; pinned entries come before high
+var priorityRanks = map[string]int{"pinned": 0, PriorityHigh: 1, PriorityNormal: 2, PriorityLow: 3}
 
-	return strings.Join(sections, "\n")
+// priorityName is the priority an entry is budgeted with, "pinned" for pinned entries
+func priorityName(pinned bool, priority string) string {
+	if pinned {
+		return "pinned"
+	}
+	return cmp.Or(priority, PriorityNormal)
+}
+
+// budgetOrder sorts paths by priority, then by path
+func budgetOrder(paths []string, priority func(path string) string) []string {
+	rank := func(path string) int {
+		return priorityRanks[priority(path)]
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if rank(paths[i]) != rank(paths[j]) {
+			return rank(paths[i]) < rank(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+// inPathOrder appends the rendered sections to sections and sorts entries, both by path, so the
+// serialization doesn't change when priorities do
+func inPathOrder(sections []string, rendered map[string]string, entries []ContextEntry) ([]string, []ContextEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	for _, entry := range entries {
+		sections = append(sections, rendered[entry.Path])
+	}
+	return sections, entries
 }
 
-// readFileWithOptions reads a file with the specified options
+// readFileWithOptions reads a file with the specified options. Binary files are described rather
+// than sent, and whole files over the size limit are summarized by their structure.
 func (lc *LiveContext) readFileWithOptions(fileInfo FileInfo) (string, error) {
-	content, err := os.ReadFile(fileInfo.Path)
+	info, err := os.Stat(fileInfo.Path)
 	if err != nil {
 		return "", err
 	}
-
-	lines := strings.Split(string(content), "\n")
-	totalLines := len(lines)
-
-	// Handle start and end line bounds
-	startLine := fileInfo.StartLine
-	if startLine < 1 {
-		startLine = 1
+	sample, err := readSample(fileInfo.Path)
+	if err != nil {
+		return "", err
 	}
-	if startLine > totalLines {
-		return "", fmt.Errorf("start line %d exceeds file length %d", startLine, totalLines)
+	if isBinary(sample) {
+		return describeBinary(info.Size(), sample), nil
 	}
-
-	endLine := totalLines
-	if fileInfo.EndLine != nil {
-		if *fileInfo.EndLine < 0 {
-			// Negative end line means count from end
-			endLine = totalLines + *fileInfo.EndLine + 1
-		} else {
-			endLine = *fileInfo.EndLine
-		}
+	lc.mu.RLock()
+	maxFileSize := lc.maxFileSize
+	lc.mu.RUnlock()
+	whole := fileInfo.StartLine <= 1 && fileInfo.EndLine == nil
+	if whole && !fileInfo.Force && maxFileSize > 0 && info.Size() > int64(maxFileSize) {
+		return summarizeLargeFile(fileInfo.Path, info.Size(), maxFileSize), nil
 	}
 
-	if endLine > totalLines {
-		endLine = totalLines
+	content, err := os.ReadFile(fileInfo.Path)
+	if err != nil {
+		return "", err
 	}
-	if endLine < startLine {
-		return "", fmt.Errorf("end line %d is before start line %d", endLine, startLine)
+
+	lines := strings.Split(string(content), "\n")
+	startLine, endLine, err := lineRange(fileInfo, len(lines))
+	if err != nil {
+		return "", err
 	}
 
 	// Extract the specified range (convert to 0-based indexing)
@@ -223,18 +815,59 @@ func (lc *LiveContext) readFileWithOptions(fileInfo FileInfo) (string, error) {
 	return strings.Join(processedLines, "\n"), nil
 }
 
-// generateDirectoryTree creates a flat list representation of a directory using breadth-first traversal
-func generateDirectoryTree(dirPath string, ignoreGitignore bool, ignorePatterns []string) (string, error) {
-	const maxItems = 100
-	const maxDepth = 10 // Fixed reasonable depth limit
+// lineRange resolves a file entry's 1-based, inclusive line range against the file's length
+func lineRange(fileInfo FileInfo, totalLines int) (int, int, error) {
+	startLine := fileInfo.StartLine
+	if startLine < 1 {
+		startLine = 1
+	}
+	if startLine > totalLines {
+		return 0, 0, fmt.Errorf("start line %d exceeds file length %d", startLine, totalLines)
+	}
 
-	// Set up exclusions
-	defaultIgnores := []string{".git", "node_modules", ".vscode", ".idea", ".DS_Store"}
-	ignoreMap := make(map[string]bool)
-	for _, pattern := range append(defaultIgnores, ignorePatterns...) {
-		ignoreMap[pattern] = true
+	endLine := totalLines
+	if fileInfo.EndLine != nil {
+		if *fileInfo.EndLine < 0 {
+			// Negative end line means count from end
+			endLine = totalLines + *fileInfo.EndLine + 1
+		} else {
+			endLine = *fileInfo.EndLine
+		}
 	}
 
+	if endLine > totalLines {
+		endLine = totalLines
+	}
+	if endLine < startLine {
+		return 0, 0, fmt.Errorf("end line %d is before start line %d", endLine, startLine)
+	}
+	return startLine, endLine, nil
+}
+
+// Default limits of a directory structure in live context
+const (
+	DefaultTreeItems = 100
+	DefaultTreeDepth = 10
+)
+
+// DefaultIgnorePatterns are the names directory structures skip unless the config replaces them
+var DefaultIgnorePatterns = []string{".git", "node_modules", ".venv", "__pycache__", ".vscode", ".idea", ".DS_Store", "*.log"}
+
+// treeOptions controls how much of a directory structure is listed
+type treeOptions struct {
+	ignorePatterns []string // names or glob patterns matched against entry names
+	hideDotfiles   bool
+	maxItems       int
+	maxDepth       int
+	expanded       map[string]bool // absolute paths of subdirectories listed in their own sections
+}
+
+// generateDirectoryTree creates a flat list representation of a directory using breadth-first
+// traversal. Directories that weren't listed in full are marked with how many entries were left out.
+func generateDirectoryTree(dirPath string, options treeOptions) (string, error) {
+	maxItems := cmp.Or(options.maxItems, DefaultTreeItems)
+	maxDepth := cmp.Or(options.maxDepth, DefaultTreeDepth)
+
 	// Breadth-first traversal
 	type queueItem struct {
 		path  string
@@ -243,68 +876,59 @@ func generateDirectoryTree(dirPath string, ignoreGitignore bool, ignorePatterns
 
 	queue := []queueItem{{path: dirPath, depth: 0}}
 	var results []string
-	truncatedDirs := make(map[string]bool)
+	truncated := false
+	display := func(path string) string {
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return path
+		}
+		return "./" + filepath.ToSlash(relPath)
+	}
 
-	for len(queue) > 0 && len(results) < maxItems {
+	for len(queue) > 0 {
 		current := queue[0]
 		queue = queue[1:]
 
-		// Skip if we've exceeded max depth
-		if current.depth > maxDepth {
-			continue
-		}
-
-		entries, err := os.ReadDir(current.path)
+		entries, err := listTreeEntries(current.path, options)
 		if err != nil {
+			if current.depth == 0 {
+				return "", err
+			}
 			continue
 		}
 
-		var dirEntries []os.DirEntry
-		var fileEntries []os.DirEntry
-
-		// Separate directories and files, apply filters
-		for _, entry := range entries {
-			name := entry.Name()
-
-			// Skip ignored patterns
-			if ignoreMap[name] || strings.HasPrefix(name, ".") {
-				continue
-			}
-
-			// Skip .log files
-			if strings.HasSuffix(name, ".log") {
-				continue
-			}
-
-			if entry.IsDir() {
-				dirEntries = append(dirEntries, entry)
-			} else {
-				fileEntries = append(fileEntries, entry)
+		// Once the list is full, directories still waiting are summarized by what they hold
+		if len(results) >= maxItems {
+			if len(entries) > 0 {
+				results = append(results, fmt.Sprintf("%s/... (%s not listed)", display(current.path), describeEntries(entries)))
+				truncated = true
 			}
+			continue
 		}
 
-		// Add directories first, then files
-		allEntries := append(dirEntries, fileEntries...)
-
-		itemsAdded := 0
-		for _, entry := range allEntries {
+		for i, entry := range entries {
 			if len(results) >= maxItems {
-				// Mark this directory as truncated
-				truncatedDirs[current.path] = true
+				results = append(results, fmt.Sprintf("%s/... (%s more not listed)", display(current.path), describeEntries(entries[i:])))
+				truncated = true
 				break
 			}
 
 			fullPath := filepath.Join(current.path, entry.Name())
-			relPath, err := filepath.Rel(dirPath, fullPath)
-			if err != nil {
-				continue
-			}
-
-			displayPath := "./" + relPath
+			displayPath := display(fullPath)
 			if entry.IsDir() {
 				displayPath += "/"
-				// Add to queue for next level
-				queue = append(queue, queueItem{path: fullPath, depth: current.depth + 1})
+				absPath, _ := filepath.Abs(fullPath)
+				switch children, err := listTreeEntries(fullPath, options); {
+				case options.expanded[absPath]:
+					displayPath += " (expanded below)"
+				case err != nil || len(children) == 0:
+				case current.depth+1 > maxDepth:
+					displayPath += fmt.Sprintf(" (%s, past the depth limit)", describeEntries(children))
+					truncated = true
+				default:
+					// Add to queue for next level
+					queue = append(queue, queueItem{path: fullPath, depth: current.depth + 1})
+				}
 			} else {
 				// Always include file sizes for better LLM context
 				if info, err := entry.Info(); err == nil {
@@ -320,29 +944,59 @@ func generateDirectoryTree(dirPath string, ignoreGitignore bool, ignorePatterns
 			}
 
 			results = append(results, displayPath)
-			itemsAdded++
 		}
+	}
 
-		// If we couldn't add all items from this directory, mark as truncated
-		if itemsAdded < len(allEntries) {
-			truncatedDirs[current.path] = true
-		}
+	if truncated {
+		results = append(results, fmt.Sprintf("(Listing limited to %d entries and %d levels. Use expand_directory on a subdirectory to see more of it.)", maxItems, maxDepth))
 	}
+	return strings.Join(results, "\n"), nil
+}
 
-	// Add truncation indicators for directories that weren't fully explored
-	if len(truncatedDirs) > 0 {
-		for dirPath := range truncatedDirs {
-			relPath, err := filepath.Rel(dirPath, dirPath)
-			if err == nil && relPath != "." {
-				displayPath := "./" + relPath + "/..."
-				results = append(results, displayPath)
-			} else {
-				results = append(results, "./...")
-			}
+// listTreeEntries reads the entries of a directory that a structure lists, directories first
+func listTreeEntries(path string, options treeOptions) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirEntries []os.DirEntry
+	var fileEntries []os.DirEntry
+
+	// Separate directories and files, apply filters
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if options.hideDotfiles && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if slices.ContainsFunc(options.ignorePatterns, func(pattern string) bool {
+			matched, _ := filepath.Match(pattern, name)
+			return matched || pattern == name
+		}) {
+			continue
+		}
+
+		if entry.IsDir() {
+			dirEntries = append(dirEntries, entry)
+		} else {
+			fileEntries = append(fileEntries, entry)
 		}
 	}
 
-	return strings.Join(results, "\n"), nil
+	// Add directories first, then files
+	return append(dirEntries, fileEntries...), nil
+}
+
+// describeEntries counts directory entries, e.g. "12 entries: 3 directories, 9 files"
+func describeEntries(entries []os.DirEntry) string {
+	dirs := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs++
+		}
+	}
+	return fmt.Sprintf("%d entries: %d directories, %d files", len(entries), dirs, len(entries)-dirs)
 }
 
 // GetContextUsage returns current size, max size, and usage percentage
@@ -352,6 +1006,9 @@ func (lc *LiveContext) GetContextUsage() (int, int, float64) {
 	dirsContent := lc.SerializeDirectories()
 	currentSize := len(filesContent) + len(dirsContent)
 
-	usagePercent := float64(currentSize) / float64(MaxContextSize) * 100
-	return currentSize, MaxContextSize, usagePercent
+	lc.mu.RLock()
+	maxSize := lc.maxSize
+	lc.mu.RUnlock()
+	usagePercent := float64(currentSize) / float64(maxSize) * 100
+	return currentSize, maxSize, usagePercent
 }
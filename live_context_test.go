@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeWithinBudgetPriority(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("some notes that take up space\n", 10)
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		lc.files[path] = FileInfo{Path: path, StartLine: 1}
+	}
+	a, b, c := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")
+	assert.NoError(t, lc.SetPriority(a, false, PriorityLow))
+	assert.NoError(t, lc.SetPriority(c, true, PriorityNormal))
+
+	// Room for two of the three files: the low-priority one is omitted even though it sorts first
+	files, _, omitted := lc.SerializeWithinBudget(1000)
+	assert.Equal(t, []string{a}, omitted)
+	assert.Less(t, strings.Index(files, a), strings.Index(files, b), "files are still sent in path order")
+
+	entries := lc.LastSerialization()
+	priorities := make(map[string]string)
+	for _, entry := range entries {
+		priorities[entry.Path] = entry.Priority
+	}
+	assert.Equal(t, map[string]string{a: PriorityLow, b: PriorityNormal, c: "pinned"}, priorities)
+	assert.Contains(t, describeEntry(entries[0]), "omitted")
+	assert.Contains(t, describeEntry(entries[0]), "[low]")
+
+	// With less room, the pinned file keeps its place ahead of the normal one
+	_, _, omitted = lc.SerializeWithinBudget(500)
+	assert.ElementsMatch(t, []string{a, b}, omitted)
+}
+
+func TestSerializeWithinBudgetPinned(t *testing.T) {
+	dir := t.TempDir()
+	pinned := filepath.Join(dir, "pinned.txt")
+	other := filepath.Join(dir, "other.txt")
+	assert.NoError(t, os.WriteFile(pinned, []byte(strings.Repeat("pinned notes\n", 100)), 0644))
+	assert.NoError(t, os.WriteFile(other, []byte("small\n"), 0644))
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}
+	lc.files[pinned] = FileInfo{Path: pinned, StartLine: 1, Pinned: true}
+	lc.files[other] = FileInfo{Path: other, StartLine: 1}
+	lc.directories[dir] = DirectoryInfo{Path: dir, MaxItems: 100}
+
+	// A pinned file over the whole budget is still sent whole, and the rest share what's left: here
+	// nothing, even the directory serialized before files
+	files, dirs, omitted := lc.SerializeWithinBudget(300)
+	assert.Contains(t, files, strings.Repeat("pinned notes\n", 100))
+	assert.ElementsMatch(t, []string{dir, other}, omitted)
+	assert.Contains(t, dirs, "omitted: over live context budget")
+
+	// Unpinned, it's cut or omitted like any other file
+	lc.files[pinned] = FileInfo{Path: pinned, StartLine: 1}
+	_, _, omitted = lc.SerializeWithinBudget(300)
+	assert.Contains(t, omitted, pinned)
+}
+
+func TestDirectoryTreeLimitsAndExpansion(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"a/b/c/deep.txt", "a/one.txt", "z/1.txt", "z/2.txt", "z/3.txt", "top.txt"} {
+		full := filepath.Join(dir, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte("x"), 0644))
+	}
+
+	// Past the depth limit, a directory is marked with what it holds instead of being listed
+	tree, err := generateDirectoryTree(dir, treeOptions{maxDepth: 1})
+	assert.NoError(t, err)
+	assert.Contains(t, tree, "./a/b/ (1 entries: 1 directories, 0 files, past the depth limit)")
+	assert.NotContains(t, tree, "deep.txt")
+	assert.Contains(t, tree, "Listing limited to 100 entries and 1 levels")
+
+	// Once the item limit is reached, the rest of each directory is counted
+	tree, err = generateDirectoryTree(dir, treeOptions{maxItems: 4})
+	assert.NoError(t, err)
+	assert.Contains(t, tree, "./z/... (3 entries: 0 directories, 3 files not listed)")
+
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}
+	assert.NoError(t, lc.AddDirectory(dir, false))
+	assert.NoError(t, lc.SetDirectoryLimits(dir, 4, 0))
+	z := filepath.Join(dir, "z")
+	parent, err := lc.ExpandDirectory(z)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, parent)
+
+	structure := lc.SerializeDirectories()
+	assert.Contains(t, structure, "./z/ (expanded below)")
+	assert.Contains(t, structure, "--- EXPANDED: "+z+" ---\n./1.txt")
+
+	// Removing the expanded subdirectory collapses it and keeps the directory
+	assert.NoError(t, lc.RemoveDirectory(z))
+	assert.Equal(t, []string{dir}, lc.ListDirectories())
+	assert.NotContains(t, lc.SerializeDirectories(), "EXPANDED")
+}
+
+func TestDirectoryTreeHiddenAndIgnored(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{".github/workflows/ci.yml", ".env.example", ".git/HEAD", "node_modules/x.js", "build.log", "main.go"} {
+		full := filepath.Join(dir, path)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		assert.NoError(t, os.WriteFile(full, []byte("x"), 0644))
+	}
+
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo)}
+	assert.NoError(t, lc.AddDirectory(dir, false))
+	structure := lc.SerializeDirectories()
+	assert.Contains(t, structure, "./.github/workflows/ci.yml")
+	assert.Contains(t, structure, "./.env.example")
+	for _, ignored := range []string{".git/", "node_modules", "build.log"} {
+		assert.NotContains(t, structure, ignored)
+	}
+
+	// The config can hide dotfiles and replace the defaults
+	lc.SetIncludeHidden(false)
+	lc.SetDefaultIgnores([]string{"*.go"})
+	structure = lc.SerializeDirectories()
+	assert.NotContains(t, structure, ".github")
+	assert.NotContains(t, structure, "main.go")
+	assert.Contains(t, structure, "./node_modules/x.js")
+
+	// A directory's own settings win over the config
+	includeHidden, defaultIgnores := true, false
+	assert.NoError(t, lc.SetDirectoryVisibility(dir, &includeHidden, &defaultIgnores))
+	structure = lc.SerializeDirectories()
+	assert.Contains(t, structure, "./.git/HEAD")
+	assert.Contains(t, structure, "./main.go")
+}
+
+func TestLiveContextConcurrentUse(t *testing.T) {
+	dir := t.TempDir()
+	lc := &LiveContext{files: make(map[string]FileInfo), directories: make(map[string]DirectoryInfo), maxSize: MaxContextSize}
+	assert.NoError(t, lc.AddDirectory(dir, false))
+
+	// Tools change live context while the pruner serializes it from another goroutine
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			path := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+			assert.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+			for range 50 {
+				assert.NoError(t, lc.AddFile(path, 1, nil))
+				assert.NoError(t, lc.SetPriority(path, false, PriorityHigh))
+				assert.NoError(t, lc.RemoveFile(path))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				lc.SerializeWithinBudget(1000)
+				lc.GetContextUsage()
+				_, _ = lc.ExpandDirectory(dir)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, lc.Files())
+}
+
+func TestLiveContextChangedFiles(t *testing.T) {
+	dir, other := t.TempDir(), t.TempDir()
+	watched := filepath.Join(dir, "watched.txt")
+	for _, path := range []string{watched, filepath.Join(dir, "sibling.txt"), filepath.Join(other, "other.txt")} {
+		assert.NoError(t, os.WriteFile(path, []byte("before"), 0644))
+	}
+	lc := NewLiveContext()
+	defer lc.Close()
+	if lc.watcher == nil {
+		t.Skip("file watching is unavailable")
+	}
+	assert.NoError(t, lc.AddFile(watched, 1, nil))
+
+	// Files outside live context aren't reported, even in a watched directory
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sibling.txt"), []byte("after"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(other, "other.txt"), []byte("after"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644))
+	assert.Never(t, func() bool { return len(lc.ChangedFiles()) > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(watched, []byte("after"), 0644))
+	assert.Eventually(t, func() bool { return slices.Equal(lc.ChangedFiles(), []string{watched}) }, time.Second, 10*time.Millisecond)
+
+	lc.ResetChanges()
+	assert.Empty(t, lc.ChangedFiles())
+}
+
+func TestLiveContextChangesConcurrentUse(t *testing.T) {
+	dir := t.TempDir()
+	lc := NewLiveContext()
+	defer lc.Close()
+
+	// The watcher records changes while the agent reads and resets them between turns
+	var wg sync.WaitGroup
+	for i := range 4 {
+		path := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		assert.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+		assert.NoError(t, lc.AddFile(path, 1, nil))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprint(j)), 0644))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				for _, changed := range lc.ChangedFiles() {
+					assert.Equal(t, dir, filepath.Dir(changed))
+				}
+				lc.ResetChanges()
+			}
+		}()
+	}
+	wg.Wait()
+}
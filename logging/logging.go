@@ -0,0 +1,131 @@
+// Package logging writes leveled diagnostics to the agent's log file (~/.agent/agent.log) and shows
+// warnings and errors on the terminal. --verbose shows every level on the terminal, and debug mode
+// adds debug messages and the raw chunks of streamed responses to the log file.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level orders messages by importance
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	return [...]string{"DEBUG", "INFO", "WARN", "ERROR"}[l]
+}
+
+var (
+	mu       sync.Mutex
+	file     *os.File
+	verbose  bool // show every level on the terminal
+	debug    bool // write debug messages and stream chunks to the log file
+	terminal = log.Default()
+)
+
+// Path returns where the log file is kept
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".agent", "agent.log"), nil
+}
+
+// Open starts appending messages to the log file at path
+func Open(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	opened, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+	}
+	file = opened
+	return nil
+}
+
+// Close stops writing to the log file
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// SetVerbose shows info and debug messages on the terminal too
+func SetVerbose(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	verbose = on
+}
+
+// SetDebug writes debug messages and streamed response chunks to the log file
+func SetDebug(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	debug = on
+}
+
+// Debugging reports whether debug mode is on
+func Debugging() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return debug
+}
+
+func Debugf(format string, args ...interface{}) { logf(LevelDebug, format, args...) }
+func Infof(format string, args ...interface{})  { logf(LevelInfo, format, args...) }
+func Warnf(format string, args ...interface{})  { logf(LevelWarn, format, args...) }
+func Errorf(format string, args ...interface{}) { logf(LevelError, format, args...) }
+
+// Chunk records one raw chunk of a streamed response, in debug mode only and never on the terminal
+func Chunk(source, raw string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if debug {
+		write(LevelDebug, fmt.Sprintf("%s chunk: %s", source, strings.TrimSpace(raw)))
+	}
+}
+
+func logf(level Level, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	mu.Lock()
+	defer mu.Unlock()
+	if level >= LevelInfo || debug || verbose {
+		write(level, message)
+	}
+	// The terminal goes through the standard logger, so interfaces that capture it (--tui) still do
+	if level >= LevelWarn || verbose {
+		terminal.Print(message)
+	}
+}
+
+// write appends a line to the log file. The caller holds mu.
+func write(level Level, message string) {
+	if file == nil {
+		return
+	}
+	fmt.Fprintf(file, "%s %-5s %s\n", time.Now().Format(time.RFC3339), level, message)
+}
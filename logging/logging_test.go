@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".agent", "agent.log")
+	require.NoError(t, Open(path))
+	var shown bytes.Buffer
+	log.SetOutput(&shown)
+	defer log.SetOutput(os.Stderr)
+
+	Debugf("hidden %d", 1)
+	Infof("started")
+	Warnf("careful")
+	Chunk("openai/gpt-4o", `{"id":"1"}`)
+	assert.NotContains(t, shown.String(), "started", "info stays in the log file")
+	assert.Contains(t, shown.String(), "careful")
+
+	SetDebug(true)
+	Debugf("detail")
+	Chunk("openai/gpt-4o", `{"id":"2"}`)
+	SetDebug(false)
+	SetVerbose(true)
+	Infof("now shown")
+	SetVerbose(false)
+	require.NoError(t, Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	logged := string(data)
+	assert.NotContains(t, logged, "hidden")
+	assert.Contains(t, logged, "INFO  started")
+	assert.Contains(t, logged, "WARN  careful")
+	assert.Contains(t, logged, "DEBUG detail")
+	assert.NotContains(t, logged, `{"id":"1"}`)
+	assert.Contains(t, logged, `DEBUG openai/gpt-4o chunk: {"id":"2"}`)
+	assert.NotContains(t, shown.String(), "chunk", "chunks never reach the terminal")
+	assert.Contains(t, shown.String(), "now shown")
+}
@@ -0,0 +1,571 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// DefaultServers maps language IDs to the language server command used when none is configured
+var DefaultServers = map[string][]string{
+	"go":         {"gopls"},
+	"python":     {"pyright-langserver", "--stdio"},
+	"typescript": {"typescript-language-server", "--stdio"},
+}
+
+// languageIDs maps file extensions to the LSP language ID and the server that handles them
+var languageIDs = map[string][2]string{
+	".go":  {"go", "go"},
+	".py":  {"python", "python"},
+	".ts":  {"typescript", "typescript"},
+	".tsx": {"typescriptreact", "typescript"},
+	".js":  {"javascript", "typescript"},
+	".jsx": {"javascriptreact", "typescript"},
+}
+
+// Position is a zero-based line and UTF-16 character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span between two positions
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic is a compiler or linter message reported by the server
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// SeverityName returns the LSP severity as a word
+func (d Diagnostic) SeverityName() string {
+	switch d.Severity {
+	case 1:
+		return "error"
+	case 2:
+		return "warning"
+	case 3:
+		return "info"
+	default:
+		return "hint"
+	}
+}
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Client is a connection to a single language server process
+type Client struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan rpcMessage
+	done    chan struct{} // closed once the connection to the server is lost
+	err     error         // why the connection was lost, set before done is closed
+
+	openFiles   map[string]int // URI -> document version
+	diagnostics map[string][]Diagnostic
+	diagUpdated map[string]time.Time
+}
+
+// StartClient launches a language server rooted at dir and performs the initialize handshake
+func StartClient(ctx context.Context, command []string, dir string) (*Client, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+
+	c := newClient(cmd, stdin)
+	go c.readLoop(bufio.NewReader(stdout))
+
+	rootURI := pathToURI(dir)
+	initParams := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(dir)},
+		},
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"publishDiagnostics": map[string]interface{}{},
+				"definition":         map[string]interface{}{"linkSupport": true},
+				"references":         map[string]interface{}{},
+			},
+			"workspace": map[string]interface{}{
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}
+	if err := c.call(ctx, "initialize", initParams, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	if err := c.notify("initialized", map[string]interface{}{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newClient(cmd *exec.Cmd, stdin io.WriteCloser) *Client {
+	return &Client{
+		cmd:         cmd,
+		stdin:       stdin,
+		pending:     make(map[int]chan rpcMessage),
+		done:        make(chan struct{}),
+		openFiles:   make(map[string]int),
+		diagnostics: make(map[string][]Diagnostic),
+		diagUpdated: make(map[string]time.Time),
+	}
+}
+
+// Alive reports whether the server is still connected. A client whose server exited or stopped
+// answering with valid messages fails every call and should be replaced.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Client) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(body)
+	return err
+}
+
+func (c *Client) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan rpcMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.err
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	}
+}
+
+// readLoop dispatches the server's messages until the connection is lost, then fails the pending
+// calls and every later one
+func (c *Client) readLoop(reader *bufio.Reader) {
+	err := c.readMessages(reader)
+	c.mu.Lock()
+	c.err = fmt.Errorf("language server exited: %w", err)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// readMessages dispatches responses, stores diagnostics, and answers server-initiated requests. It
+// returns why it stopped reading.
+func (c *Client) readMessages(reader *bufio.Reader) error {
+	for {
+		length := 0
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return err
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params struct {
+				URI         string       `json:"uri"`
+				Diagnostics []Diagnostic `json:"diagnostics"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil {
+				c.mu.Lock()
+				c.diagnostics[params.URI] = params.Diagnostics
+				c.diagUpdated[params.URI] = time.Now()
+				c.mu.Unlock()
+			}
+		case msg.Method != "" && msg.ID != nil:
+			// Requests from the server (configuration, progress, registrations) get empty answers
+			var result interface{}
+			if msg.Method == "workspace/configuration" {
+				var params struct {
+					Items []interface{} `json:"items"`
+				}
+				_ = json.Unmarshal(msg.Params, &params)
+				result = make([]interface{}, len(params.Items))
+			}
+			_ = c.write(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		case msg.ID != nil:
+			id, _ := strconv.Atoi(strings.Trim(string(*msg.ID), `"`))
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// SyncFile sends the current contents of path to the server, opening it on first use
+func (c *Client) SyncFile(path, languageID string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	uri := pathToURI(path)
+
+	c.mu.Lock()
+	version, open := c.openFiles[uri]
+	c.openFiles[uri] = version + 1
+	c.mu.Unlock()
+
+	if !open {
+		return c.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": languageID,
+				"version":    1,
+				"text":       string(content),
+			},
+		})
+	}
+	return c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": version + 1},
+		"contentChanges": []map[string]string{{"text": string(content)}},
+	})
+}
+
+// Diagnostics waits for the server to publish diagnostics for path after the latest sync
+func (c *Client) Diagnostics(ctx context.Context, path string, timeout time.Duration) ([]Diagnostic, error) {
+	uri := pathToURI(path)
+	requested := time.Now()
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.diagnostics[uri], nil
+		case <-ticker.C:
+			c.mu.Lock()
+			updated := c.diagUpdated[uri]
+			diagnostics := c.diagnostics[uri]
+			c.mu.Unlock()
+			// Servers often publish several times in a row; wait for them to settle
+			if updated.After(requested) && time.Since(updated) > 500*time.Millisecond {
+				return diagnostics, nil
+			}
+		}
+	}
+}
+
+// Definition returns the locations where the symbol at pos is defined
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	err := c.call(ctx, "textDocument/definition", map[string]interface{}{
+		"textDocument": map[string]string{"uri": pathToURI(path)},
+		"position":     pos,
+	}, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocations(raw), nil
+}
+
+// References returns every location that references the symbol at pos
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	err := c.call(ctx, "textDocument/references", map[string]interface{}{
+		"textDocument": map[string]string{"uri": pathToURI(path)},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": false},
+	}, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocations(raw), nil
+}
+
+// parseLocations accepts Location, []Location, or []LocationLink results
+func parseLocations(raw json.RawMessage) []Location {
+	var single Location
+	if json.Unmarshal(raw, &single) == nil && single.URI != "" {
+		return []Location{single}
+	}
+
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+
+	var locations []Location
+	for _, item := range items {
+		if item.TargetURI != "" {
+			locations = append(locations, Location{URI: item.TargetURI, Range: item.TargetSelectionRange})
+		} else {
+			locations = append(locations, item.Location)
+		}
+	}
+	return locations
+}
+
+// Close shuts the server down, killing it if it doesn't exit promptly
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = c.call(ctx, "shutdown", nil, nil)
+	_ = c.notify("exit", nil)
+	_ = c.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		return c.cmd.Process.Kill()
+	}
+}
+
+// Manager starts one language server per language on demand for a workspace
+type Manager struct {
+	mu      sync.Mutex
+	root    string
+	servers map[string][]string
+	clients map[string]*Client
+}
+
+// NewManager creates a manager for the workspace at root. Configured servers override the defaults.
+func NewManager(root string, servers map[string][]string) *Manager {
+	merged := make(map[string][]string)
+	for lang, command := range DefaultServers {
+		merged[lang] = command
+	}
+	for lang, command := range servers {
+		if len(command) > 0 {
+			merged[lang] = command
+		}
+	}
+	return &Manager{root: root, servers: merged, clients: make(map[string]*Client)}
+}
+
+// ClientFor returns a running client for path's language, starting the server and syncing the file
+func (m *Manager) ClientFor(ctx context.Context, path string) (*Client, error) {
+	ids, ok := languageIDs[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("no language server configured for %s files", filepath.Ext(path))
+	}
+	languageID, server := ids[0], ids[1]
+
+	m.mu.Lock()
+	client, running := m.clients[server]
+	if running && !client.Alive() {
+		// The server crashed; reap it and start a new one
+		go client.Close()
+		delete(m.clients, server)
+		running = false
+	}
+	if !running {
+		var err error
+		client, err = StartClient(ctx, m.servers[server], m.root)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		m.clients[server] = client
+	}
+	m.mu.Unlock()
+
+	if err := client.SyncFile(path, languageID); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Close stops every running language server
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, client := range m.clients {
+		_ = client.Close()
+		delete(m.clients, name)
+	}
+}
+
+// PositionOf converts a 1-based line and either a symbol on that line or a 1-based byte column into
+// an LSP position
+func PositionOf(path string, line int, symbol string, column int) (Position, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Position{}, err
+	}
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return Position{}, fmt.Errorf("line %d is outside the file (1-%d)", line, len(lines))
+	}
+	text := lines[line-1]
+
+	offset := column - 1
+	if symbol != "" {
+		offset = strings.Index(text, symbol)
+		if offset == -1 {
+			return Position{}, fmt.Errorf("symbol %q not found on line %d", symbol, line)
+		}
+	}
+	if offset < 0 || offset > len(text) {
+		return Position{}, fmt.Errorf("column %d is outside line %d", column, line)
+	}
+
+	return Position{Line: line - 1, Character: len(utf16.Encode([]rune(text[:offset])))}, nil
+}
+
+// FormatLocation renders a location as path:line:column followed by the source line
+func FormatLocation(loc Location, root string) string {
+	path := URIToPath(loc.URI)
+	display := path
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		display = rel
+	}
+
+	line := loc.Range.Start.Line
+	result := fmt.Sprintf("%s:%d:%d", display, line+1, loc.Range.Start.Character+1)
+	if content, err := os.ReadFile(path); err == nil {
+		lines := strings.Split(string(content), "\n")
+		if line < len(lines) {
+			result += ": " + strings.TrimSpace(lines[line])
+		}
+	}
+	return result
+}
+
+func pathToURI(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String()
+}
+
+// URIToPath converts a file:// URI to a local path
+func URIToPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(parsed.Path)
+}
@@ -0,0 +1,124 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	assert.NoError(t, os.WriteFile(path, []byte("package main\n\n// héllo\nvar s = \"é\" + name\n"), 0644))
+
+	pos, err := PositionOf(path, 4, "name", 0)
+	assert.NoError(t, err)
+	// "é" is two bytes but one UTF-16 unit
+	assert.Equal(t, Position{Line: 3, Character: 14}, pos)
+
+	pos, err = PositionOf(path, 1, "", 9)
+	assert.NoError(t, err)
+	assert.Equal(t, Position{Line: 0, Character: 8}, pos)
+
+	_, err = PositionOf(path, 4, "missing", 0)
+	assert.Error(t, err)
+	_, err = PositionOf(path, 99, "name", 0)
+	assert.Error(t, err)
+}
+
+func TestParseLocations(t *testing.T) {
+	single := json.RawMessage(`{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}`)
+	assert.Equal(t, []Location{{URI: "file:///a.go", Range: Range{Start: Position{1, 2}, End: Position{1, 3}}}}, parseLocations(single))
+
+	links := json.RawMessage(`[{"targetUri":"file:///b.go","targetSelectionRange":{"start":{"line":4,"character":0},"end":{"line":4,"character":1}}}]`)
+	locations := parseLocations(links)
+	assert.Len(t, locations, 1)
+	assert.Equal(t, "file:///b.go", locations[0].URI)
+	assert.Equal(t, 4, locations[0].Range.Start.Line)
+
+	assert.Empty(t, parseLocations(json.RawMessage(`null`)))
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir with space", "a.go")
+	assert.Equal(t, path, URIToPath(pathToURI(path)))
+}
+
+// TestHelperLanguageServer is a language server for TestManagerRestartsDeadServer. It answers every
+// request with a null result, and crashes when asked for a definition.
+func TestHelperLanguageServer(t *testing.T) {
+	if os.Getenv("AGENT_TEST_LANGUAGE_SERVER") != "1" {
+		t.Skip("run as a language server by TestManagerRestartsDeadServer")
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		length := 0
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				os.Exit(0)
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			os.Exit(0)
+		}
+		var msg rpcMessage
+		_ = json.Unmarshal(body, &msg)
+		switch {
+		case msg.Method == "textDocument/definition":
+			os.Exit(1)
+		case msg.Method == "exit":
+			os.Exit(0)
+		case msg.ID != nil:
+			reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":null}`, *msg.ID)
+			fmt.Printf("Content-Length: %d\r\n\r\n%s", len(reply), reply)
+		}
+	}
+}
+
+func TestManagerRestartsDeadServer(t *testing.T) {
+	t.Setenv("AGENT_TEST_LANGUAGE_SERVER", "1")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	assert.NoError(t, os.WriteFile(path, []byte("package main\n"), 0644))
+	manager := NewManager(dir, map[string][]string{"go": {os.Args[0], "-test.run=^TestHelperLanguageServer$"}})
+	defer manager.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := manager.ClientFor(ctx, path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, client.Alive())
+
+	// The server crashes during the call; the call fails rather than waiting for ctx
+	_, err = client.Definition(ctx, path, Position{})
+	assert.ErrorContains(t, err, "language server exited")
+	assert.NoError(t, ctx.Err())
+	<-client.done
+	assert.False(t, client.Alive())
+	_, err = client.References(ctx, path, Position{})
+	assert.ErrorContains(t, err, "language server exited", "later calls fail at once")
+
+	restarted, err := manager.ClientFor(ctx, path)
+	assert.NoError(t, err)
+	assert.NotSame(t, client, restarted)
+	assert.True(t, restarted.Alive())
+}
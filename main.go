@@ -1,8 +1,9 @@
 package main
 
 import (
+	"agent/logging"
 	"agent/theme"
-	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -11,45 +12,156 @@ import (
 )
 
 func main() {
+	reproduce := flag.String("reproduce", "", "re-send a recorded request with identical settings: <session>:<turn>[:<iteration>]")
+	tui := flag.Bool("tui", false, "full-screen interface with a scrollable conversation, status bar, and tool output pane")
+	quiet := flag.Bool("quiet", false, "print only the conversation: no banner, config warnings, prompts, input echo, or heartbeats")
+	dryRun := flag.Bool("dry-run", false, "file tools show their diffs without writing to disk (toggle with /dryrun)")
+	verbose := flag.Bool("verbose", false, "show info and debug messages on the terminal as well as in ~/.agent/agent.log")
+	flag.Parse()
+
+	logging.SetVerbose(*verbose)
+	if path, err := logging.Path(); err == nil {
+		if err := logging.Open(path); err != nil {
+			log.Printf("Not writing a log file: %v", err)
+		}
+	}
+	defer logging.Close()
+
 	theme.InitializeTheme()
+
+	if flag.Arg(0) == "replay" {
+		if err := runReplay(flag.Args()[1:]); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "profile" {
+		if err := runProfile(flag.Args()[1:]); err != nil {
+			log.Fatalf("Profile failed: %v", err)
+		}
+		return
+	}
+
 	agent := NewAgent()
+	agent.quiet = *quiet
+	agent.dryRun = *dryRun
 
-	// Set up signal handling for request cancellation on Ctrl+C
+	if *reproduce != "" {
+		err := agent.Reproduce(*reproduce)
+		if closeErr := agent.Close(); closeErr != nil {
+			logging.Errorf("Failed to close chatbot: %v", closeErr)
+		}
+		if err != nil {
+			log.Fatalf("Reproduce failed: %v", err)
+		}
+		return
+	}
+
+	if *tui {
+		if err := runTUI(agent); err != nil {
+			logging.Errorf("TUI failed: %v", err)
+		}
+		if err := agent.Close(); err != nil {
+			log.Fatalf("Failed to close chatbot: %v", err)
+		}
+		return
+	}
+
+	// Ctrl+C stops the running turn; two within exitWindow exit
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt)
-
-	// Handle signals in a goroutine
 	go func() {
-		for {
-			<-sigChan
-			agent.inProgressMutex.Lock()
-			if agent.inProgress && agent.cancelFunc != nil {
-				agent.cancelFunc()
-				agent.inProgressMutex.Unlock()
-			} else {
-				agent.inProgressMutex.Unlock()
-				fmt.Printf("\n%s\n", theme.InfoText("Exiting..."))
-				os.Exit(0)
+		for range sigChan {
+			action := agent.Interrupt()
+			if action != interruptExit {
+				fmt.Printf("\n%s\n", interruptNotice(action))
+				continue
+			}
+			if theme.IsTerminal() {
+				fmt.Print(disableBracketedPaste)
 			}
+			fmt.Printf("\n%s\n", theme.InfoText("Exiting..."))
+			os.Exit(0)
 		}
 	}()
 
-	fmt.Println(theme.AgentText("🦜 welcome, friend\n   " + agent.GetAvailableCommands()))
-	scanner := bufio.NewScanner(os.Stdin)
+	// Streaming text already shows progress while the model responds, so heartbeats are only
+	// printed while a tool is running, along with the statuses it reports
+	agent.OnProgress(func(event ProgressEvent) {
+		if event.Tool == "" || agent.quiet {
+			return
+		}
+		if event.Update {
+			fmt.Println(theme.DebugText("   ↳ " + event.Status))
+			return
+		}
+		fmt.Println(theme.DebugText(fmt.Sprintf("⏱  turn %d · iteration %d · running %s · %s", event.Turn, event.Iteration, event.Tool, event.Elapsed)))
+	})
+
+	if theme.IsTerminal() {
+		fmt.Print(enableBracketedPaste)
+	}
+
+	if welcome := agent.welcomeMessage(); welcome != "" {
+		fmt.Println(welcome)
+	}
+	chatLoop(agent, !agent.quiet)
+
+	if theme.IsTerminal() {
+		fmt.Print(disableBracketedPaste)
+	}
+
+	if err := agent.Close(); err != nil {
+		log.Fatalf("Failed to close chatbot: %v", err)
+	}
+}
 
+// chatLoop reads messages and commands from the agent's input until it ends or /quit. The prompt
+// is printed unless the interface draws its own input line, and input is echoed unless --quiet.
+func chatLoop(agent *Agent, showPrompt bool) {
+	scanner := agent.input
+	queuedLines := 0 // lines typed during a turn that are still to be read
+	continuePrompt := func() {
+		if showPrompt && queuedLines == 0 {
+			fmt.Print(theme.PromptText("… "))
+		}
+	}
 	for {
-		fmt.Print(theme.PromptText("> "))
+		queuedLines += agent.takeReleased()
+		queued := queuedLines > 0
+		if showPrompt && !queued {
+			prompt := "> "
+			if agent.InPlanMode() {
+				prompt = "plan> "
+			} else if agent.InDryRun() {
+				prompt = "dry-run> "
+			}
+			fmt.Print(theme.PromptText(prompt))
+		}
 
-		if !scanner.Scan() {
+		message, lines, ok := readMessage(scanner, continuePrompt)
+		if !ok {
 			if err := scanner.Err(); err != nil {
 				fmt.Printf("Error reading input: %v\n", err)
 			}
 			break
 		}
 
-		input := strings.TrimSpace(scanner.Text())
-		fmt.Printf("\033[1A\033[K") // Moves cursor up one line and clears the line
-		fmt.Println(theme.UserText("👤 " + input))
+		input := strings.TrimSpace(message)
+		if queued {
+			queuedLines = max(queuedLines-lines, 0)
+			if !agent.quiet {
+				fmt.Println(theme.UserText("👤 (queued) " + input))
+			}
+		} else {
+			if showPrompt && theme.IsTerminal() {
+				fmt.Print(strings.Repeat("\033[1A\033[K", lines)) // Moves cursor up and clears each line that was typed
+			}
+			if !agent.quiet {
+				fmt.Println(theme.UserText("👤 " + input))
+			}
+		}
 		if input == "" {
 			continue
 		}
@@ -68,8 +180,4 @@ func main() {
 		fmt.Println()
 		fmt.Println()
 	}
-
-	if err := agent.Close(); err != nil {
-		log.Fatalf("Failed to close chatbot: %v", err)
-	}
 }
@@ -1,14 +1,11 @@
 package miniagents
 
 import (
-	"agent/api"
 	"agent/models"
 	"agent/tools"
 	"context"
 	_ "embed"
-	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/google/uuid"
@@ -17,25 +14,46 @@ import (
 //go:embed context_pruner_prompt.md
 var systemPromptTemplate string
 
-// PruneContext runs the context pruning process
-func PruneContext(ctx context.Context, model *models.Model, messages *[]models.Message, liveContext tools.LiveContextManager, allTools map[string]models.ToolDefinition) error {
+// PruneContext runs the context pruning process. History and live context each have their own
+// reduction target; the pruner is only given the tools for parts that need to shrink.
+func PruneContext(ctx context.Context, env *Env, messages *[]models.Message, liveContext tools.LiveContextManager, allTools map[string]models.ToolDefinition, historyReduction, liveContextReduction int) error {
 
-	log.Printf("Starting context pruning")
+	env.Log.Printf("Starting context pruning")
 
 	prunerTools := make(map[string]models.ToolDefinition)
-	prunerTools["remove_message"] = allTools["remove_message"]
-	prunerTools["stop_reading_file"] = allTools["stop_reading_file"]
-	prunerTools["stop_reading_directory"] = allTools["stop_reading_directory"]
+	if historyReduction > 0 {
+		prunerTools["remove_message"] = allTools["remove_message"]
+	}
+	if liveContextReduction > 0 {
+		// Pinned entries must survive pruning no matter what the pruner decides
+		for _, name := range []string{"stop_reading_file", "stop_reading_directory"} {
+			tool := allTools[name]
+			stopReading := tool.Func
+			tool.Func = func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+				if path, ok := params["path"].(string); ok {
+					if pinned, _ := liveContext.GetPriority(path); pinned {
+						return "", "", fmt.Errorf("%s is pinned and cannot be removed", path)
+					}
+				}
+				return stopReading(ctx, params)
+			}
+			prunerTools[name] = tool
+		}
+	}
+	if len(prunerTools) == 0 {
+		env.Log.Printf("Context is within budget, nothing to prune")
+		return nil
+	}
 
 	iteration := 0
 	maxIterations := 1
 
 	for iteration < maxIterations {
 		iteration++
-		log.Printf("Context pruning iteration %d/%d", iteration, maxIterations)
+		env.Log.Printf("Context pruning iteration %d/%d", iteration, maxIterations)
 
 		// Build system prompt with current metrics for this iteration
-		systemPrompt := buildSystemPrompt(*messages, liveContext)
+		systemPrompt := buildSystemPrompt(*messages, liveContext, historyReduction, liveContextReduction)
 
 		userPrompt := models.Message{
 			ID:      uuid.New().String(),
@@ -45,23 +63,21 @@ func PruneContext(ctx context.Context, model *models.Model, messages *[]models.M
 		}
 
 		// Make LLM request
-		content, toolCalls, err := api.Invoke(
+		content, toolCalls, err := env.Invoke(
 			ctx,
-			model,
 			[]models.Message{userPrompt},
 			systemPrompt,
 			prunerTools, // Use tools directly
-			nil,         // onReceiveContent - not needed
 		)
 
 		if err != nil {
-			log.Printf("Context pruning LLM request failed: %v", err)
+			env.Log.Printf("Context pruning LLM request failed: %v", err)
 			return fmt.Errorf("LLM request failed: %w", err)
 		}
 
 		// If no tool calls, we're done
 		if len(toolCalls) == 0 {
-			log.Printf("Context pruning completed after %d iterations. Final response: %s", iteration, content)
+			env.Log.Printf("Context pruning completed after %d iterations. Final response: %s", iteration, content)
 			break
 		}
 
@@ -69,41 +85,69 @@ func PruneContext(ctx context.Context, model *models.Model, messages *[]models.M
 		for _, toolCall := range toolCalls {
 			tool, exists := prunerTools[toolCall.Function.Name]
 			if !exists {
-				log.Printf("Tool call skipped: %s not a valid tool", toolCall.Function.Name)
+				env.Log.Printf("Tool call skipped: %s not a valid tool", toolCall.Function.Name)
 				continue
 			}
-			var params map[string]interface{}
-			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
-				log.Printf("Tool call failed: %s - %v", toolCall.Function.Name, err)
+			params, _, err := tools.ParseArguments(toolCall.Function.Arguments)
+			if err != nil {
+				env.Log.Printf("Tool call failed: %s - %v", toolCall.Function.Name, err)
 				continue // Skip to next tool call
 			}
 			_, agentMessage, err := tool.Func(ctx, params)
 			if err != nil {
-				log.Printf("Tool call failed: %s - %v", toolCall.Function.Name, err)
+				env.Log.Printf("Tool call failed: %s - %v", toolCall.Function.Name, err)
 				continue // Skip to next tool call
 			}
-			log.Printf("Tool call succeeded: %s - %s", toolCall.Function.Name, agentMessage)
+			env.Log.Printf("Tool call succeeded: %s - %s", toolCall.Function.Name, agentMessage)
 		}
 	}
 
 	if iteration >= maxIterations {
-		log.Printf("Context pruning stopped after reaching max iterations (%d)", maxIterations)
+		env.Log.Printf("Context pruning stopped after reaching max iterations (%d)", maxIterations)
 	}
 
 	return nil
 }
 
 // buildSystemPrompt creates the system prompt with current context metrics
-func buildSystemPrompt(messages []models.Message, liveContext tools.LiveContextManager) string {
+func buildSystemPrompt(messages []models.Message, liveContext tools.LiveContextManager, historyReduction, liveContextReduction int) string {
 	var sb strings.Builder
 	for _, msg := range messages {
-		sb.WriteString(fmt.Sprintf("- ID: %s, Role: %s, Size: %d chars, Content: %s\n", msg.ID, msg.Role, len(msg.Content), msg.Content))
+		if msg.Status == "deleted" {
+			continue
+		}
+		var calls []string
+		for _, tc := range msg.ToolCalls {
+			calls = append(calls, fmt.Sprintf("%s %s", tc.Function.Name, tc.ID))
+		}
+		switch {
+		case len(calls) > 0:
+			sb.WriteString(fmt.Sprintf("- ID: %s, Role: %s, Tool calls: %s, Size: %d chars, Content: %s\n", msg.ID, msg.Role, strings.Join(calls, ", "), len(msg.Content), msg.Content))
+		case msg.Role == "tool":
+			sb.WriteString(fmt.Sprintf("- ID: %s, Role: %s, Result of: %s %s, Size: %d chars, Content: %s\n", msg.ID, msg.Role, msg.ToolName, msg.ToolCallID, len(msg.Content), msg.Content))
+		default:
+			sb.WriteString(fmt.Sprintf("- ID: %s, Role: %s, Size: %d chars, Content: %s\n", msg.ID, msg.Role, len(msg.Content), msg.Content))
+		}
 	}
 
 	prompt := systemPromptTemplate
+	prompt = strings.ReplaceAll(prompt, "{HISTORY_REDUCTION}", fmt.Sprintf("%d", historyReduction))
+	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_REDUCTION}", fmt.Sprintf("%d", liveContextReduction))
 	prompt = strings.ReplaceAll(prompt, "{MESSAGES}", sb.String())
-	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_FILE_LIST}", strings.Join(liveContext.ListFiles(), "\n"))
-	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORY_LIST}", strings.Join(liveContext.ListDirectories(), "\n"))
+	describe := func(paths []string) string {
+		var lines []string
+		for _, path := range paths {
+			pinned, priority := liveContext.GetPriority(path)
+			if pinned {
+				lines = append(lines, fmt.Sprintf("- %s [pinned, do not remove]", path))
+			} else {
+				lines = append(lines, fmt.Sprintf("- %s [priority: %s]", path, priority))
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_FILE_LIST}", describe(liveContext.ListFiles()))
+	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORY_LIST}", describe(liveContext.ListDirectories()))
 	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_FILES}", liveContext.SerializeFiles())
 	prompt = strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORIES}", liveContext.SerializeDirectories())
 	return prompt
@@ -0,0 +1,34 @@
+package miniagents
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const outputSummarizerPrompt = `You summarize the output of shell commands for a coding agent that can't read all of it. Keep every error, failure, and warning with its file, line, and exact message, and the final result (e.g. test counts or the last status line). Leave out progress, download, and repeated lines. Reply with only the summary, in at most 40 lines.`
+
+// maxSummarizedOutput keeps the request small; the end of the output, where results and errors
+// usually are, is kept over the start
+const maxSummarizedOutput = 60000
+
+// SummarizeOutput condenses the output of command, keeping the errors and the final result
+func SummarizeOutput(ctx context.Context, env *Env, command, output string) (string, error) {
+	if len(output) > maxSummarizedOutput {
+		head := maxSummarizedOutput / 3
+		output = output[:head] + "\n... (output truncated) ...\n" + output[len(output)-(maxSummarizedOutput-head):]
+	}
+	prompt := fmt.Sprintf("Command: %s\n\nOutput:\n%s", command, output)
+
+	content, _, err := env.Invoke(ctx, []models.Message{newMessage("user", prompt)}, outputSummarizerPrompt, nil)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(content)
+	if summary == "" {
+		return "", fmt.Errorf("the model returned an empty summary")
+	}
+	env.Log.Printf("summarized %d bytes of output from %q into %d", len(output), command, len(summary))
+	return summary, nil
+}
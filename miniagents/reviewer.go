@@ -0,0 +1,33 @@
+package miniagents
+
+import (
+	"agent/models"
+	"context"
+	_ "embed"
+	"strings"
+)
+
+//go:embed reviewer_prompt.md
+var reviewerPromptTemplate string
+
+// maxReviewDiff keeps very large turns from using the whole budget on one review
+const maxReviewDiff = 40000
+
+// ReviewChanges asks the model to look for problems in diff. It returns "" when nothing was found.
+func ReviewChanges(ctx context.Context, env *Env, diff string) (string, error) {
+	if len(diff) > maxReviewDiff {
+		diff = diff[:maxReviewDiff] + "\n... (diff truncated)"
+	}
+	prompt := strings.ReplaceAll(reviewerPromptTemplate, "{DIFF}", diff)
+
+	content, _, err := env.Invoke(ctx, []models.Message{newMessage("user", "Review the changes.")}, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+	content = strings.TrimSpace(content)
+	env.Log.Printf("review: %s", content)
+	if strings.EqualFold(strings.Trim(content, "`. "), "LGTM") {
+		return "", nil
+	}
+	return content, nil
+}
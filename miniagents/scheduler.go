@@ -0,0 +1,295 @@
+package miniagents
+
+import (
+	"agent/api"
+	"agent/models"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Budget caps the tokens and the cost miniagents may spend in a session. Token counts are estimated
+// from characters because streamed responses don't report usage, and costs from the token counts and
+// the models' pricing.
+type Budget struct {
+	mu        sync.Mutex
+	limit     int     // 0 means unlimited
+	costLimit float64 // in dollars; 0 means unlimited
+	used      map[string]int
+	cost      float64
+}
+
+// NewBudget creates a budget of limit tokens and costLimit dollars shared by all miniagents; 0 means
+// unlimited
+func NewBudget(limit int, costLimit float64) *Budget {
+	return &Budget{limit: limit, costLimit: costLimit, used: make(map[string]int)}
+}
+
+// Reserve returns an error if spending estimate more tokens, costing cost more dollars, would exceed
+// the budget
+func (b *Budget) Reserve(estimate int, cost float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.total()+estimate > b.limit {
+		return fmt.Errorf("miniagent token budget exhausted (%d of %d tokens used)", b.total(), b.limit)
+	}
+	if b.costLimit > 0 && b.cost+cost > b.costLimit {
+		return fmt.Errorf("miniagent cost budget exhausted ($%.2f of $%.2f used)", b.cost, b.costLimit)
+	}
+	return nil
+}
+
+// Spend records tokens used by the named miniagent and what they cost
+func (b *Budget) Spend(name string, tokens int, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used[name] += tokens
+	b.cost += cost
+}
+
+// Cost returns the dollars spent so far and the cost limit
+func (b *Budget) Cost() (float64, float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cost, b.costLimit
+}
+
+// Usage returns the tokens used so far, the limit, and the tokens used by each miniagent
+func (b *Budget) Usage() (int, int, map[string]int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byAgent := make(map[string]int, len(b.used))
+	for name, tokens := range b.used {
+		byAgent[name] = tokens
+	}
+	return b.total(), b.limit, byAgent
+}
+
+func (b *Budget) total() int {
+	total := 0
+	for _, tokens := range b.used {
+		total += tokens
+	}
+	return total
+}
+
+// RateLimiter spaces out requests to each provider. It is shared by the main loop and miniagents
+// so background work can't push the session over a provider's rate limit.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// NewRateLimiter allows perMinute requests per provider; 0 means unlimited
+func NewRateLimiter(perMinute int) *RateLimiter {
+	limiter := &RateLimiter{next: make(map[string]time.Time)}
+	if perMinute > 0 {
+		limiter.interval = time.Minute / time.Duration(perMinute)
+	}
+	return limiter
+}
+
+// Wait blocks until a request to provider may be sent
+func (r *RateLimiter) Wait(ctx context.Context, provider string) error {
+	if r == nil || r.interval == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next[provider]
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next[provider] = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Env is a running miniagent's access to the model and its log
+type Env struct {
+	Name  string
+	Model *models.Model
+	Log   *log.Logger
+
+	scheduler *Scheduler
+}
+
+// Invoke sends a request through the scheduler's rate limiter and budget
+func (e *Env) Invoke(ctx context.Context, messages []models.Message, systemPrompt string, agentTools map[string]models.ToolDefinition) (string, []models.ToolCall, error) {
+	promptChars := len(systemPrompt)
+	for _, msg := range messages {
+		promptChars += len(msg.Content)
+	}
+	promptTokens := estimateTokens(promptChars)
+	if err := e.scheduler.budget.Reserve(promptTokens, e.cost(promptTokens, 0)); err != nil {
+		return "", nil, err
+	}
+	if err := e.scheduler.limiter.Wait(ctx, e.Model.Provider.ID); err != nil {
+		return "", nil, err
+	}
+
+	content, toolCalls, err := api.Invoke(ctx, e.Model, messages, systemPrompt, agentTools, nil)
+
+	responseChars := len(content)
+	for _, toolCall := range toolCalls {
+		responseChars += len(toolCall.Function.Arguments)
+	}
+	completionTokens := estimateTokens(responseChars)
+	cost := e.cost(promptTokens, completionTokens)
+	e.scheduler.budget.Spend(e.Name, promptTokens+completionTokens, cost)
+	e.Log.Printf("request: ~%d tokens, ~$%.4f, %d tool calls, err=%v", promptTokens+completionTokens, cost, len(toolCalls), err)
+	return content, toolCalls, err
+}
+
+// cost estimates what the tokens cost with the model's pricing, or 0 when it has none
+func (e *Env) cost(promptTokens, completionTokens int) float64 {
+	if e.Model.Config.Pricing == nil {
+		return 0
+	}
+	return e.Model.Config.Pricing.Cost(promptTokens, 0, completionTokens)
+}
+
+// estimateTokens approximates a token count from a character count
+func estimateTokens(chars int) int {
+	return (chars + 3) / 4
+}
+
+// Scheduler runs miniagents in the background, at most one of each name at a time, under a shared
+// budget and rate limiter. Each miniagent logs to <logDir>/<name>.log.
+type Scheduler struct {
+	budget  *Budget
+	limiter *RateLimiter
+	logDir  string
+	session string
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler; session prefixes log lines so runs can be told apart
+func NewScheduler(budget *Budget, limiter *RateLimiter, logDir, session string) *Scheduler {
+	return &Scheduler{
+		budget:  budget,
+		limiter: limiter,
+		logDir:  logDir,
+		session: session,
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// Budget returns the budget shared by the scheduler's miniagents
+func (s *Scheduler) Budget() *Budget {
+	return s.budget
+}
+
+// Start runs job in the background as the named miniagent and calls done with its result. It fails
+// if a miniagent with that name is already running or the budget is exhausted.
+func (s *Scheduler) Start(name string, model *models.Model, job func(ctx context.Context, env *Env) error, done func(error)) error {
+	if model == nil {
+		return fmt.Errorf("no model configured")
+	}
+	if err := s.budget.Reserve(0, 0); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if _, ok := s.running[name]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%s is already running", name)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running[name] = cancel
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		logger, closeLog := s.openLog(name)
+		defer closeLog()
+
+		logger.Printf("started")
+		err := job(ctx, &Env{Name: name, Model: model, Log: logger, scheduler: s})
+		logger.Printf("finished: err=%v", err)
+
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+		cancel()
+
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+// Run runs job as the named miniagent and waits for it, for work whose result the caller needs right
+// away. Unlike Start, runs with the same name may overlap.
+func (s *Scheduler) Run(ctx context.Context, name string, model *models.Model, job func(ctx context.Context, env *Env) error) error {
+	if model == nil {
+		return fmt.Errorf("no model configured")
+	}
+	if err := s.budget.Reserve(0, 0); err != nil {
+		return err
+	}
+	logger, closeLog := s.openLog(name)
+	defer closeLog()
+
+	logger.Printf("started")
+	err := job(ctx, &Env{Name: name, Model: model, Log: logger, scheduler: s})
+	logger.Printf("finished: err=%v", err)
+	return err
+}
+
+// Running returns the names of the miniagents currently running
+func (s *Scheduler) Running() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.running))
+	for name := range s.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close cancels running miniagents and waits for them to stop
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	for _, cancel := range s.running {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) openLog(name string) (*log.Logger, func()) {
+	if err := os.MkdirAll(s.logDir, 0755); err == nil {
+		file, err := os.OpenFile(filepath.Join(s.logDir, name+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			return log.New(file, fmt.Sprintf("[%s] ", s.session), log.LstdFlags), func() { file.Close() }
+		}
+	}
+	return log.New(io.Discard, "", 0), func() {}
+}
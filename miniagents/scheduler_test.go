@@ -0,0 +1,105 @@
+package miniagents
+
+import (
+	"agent/models"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	budget := NewBudget(100, 0)
+	assert.NoError(t, budget.Reserve(60, 0))
+	budget.Spend("pruner", 60, 0)
+	budget.Spend("titler", 30, 0)
+	assert.NoError(t, budget.Reserve(10, 0))
+	assert.Error(t, budget.Reserve(11, 0))
+
+	used, limit, byAgent := budget.Usage()
+	assert.Equal(t, 90, used)
+	assert.Equal(t, 100, limit)
+	assert.Equal(t, map[string]int{"pruner": 60, "titler": 30}, byAgent)
+
+	assert.NoError(t, NewBudget(0, 0).Reserve(1<<30, 1000))
+
+	// The cost limit applies on its own, whatever the tokens
+	budget = NewBudget(0, 0.50)
+	budget.Spend("reviewer", 1000, 0.40)
+	assert.NoError(t, budget.Reserve(1<<20, 0.10))
+	assert.ErrorContains(t, budget.Reserve(1, 0.11), "cost budget exhausted ($0.40 of $0.50 used)")
+	spent, costLimit := budget.Cost()
+	assert.Equal(t, 0.40, spent)
+	assert.Equal(t, 0.50, costLimit)
+}
+
+func TestEnvCost(t *testing.T) {
+	env := &Env{Model: &models.Model{ID: "m"}}
+	assert.Zero(t, env.cost(1000, 1000), "a model without pricing is free")
+	env.Model.Config.Pricing = &models.Pricing{Input: 3, Output: 15}
+	assert.InDelta(t, 0.018, env.cost(1000, 1000), 1e-9)
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(600) // one request every 100ms per provider
+	ctx := context.Background()
+
+	start := time.Now()
+	assert.NoError(t, limiter.Wait(ctx, "a"))
+	assert.NoError(t, limiter.Wait(ctx, "b"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.NoError(t, limiter.Wait(ctx, "a"))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NoError(t, limiter.Wait(cancelled, "c")) // first slot is immediate
+	assert.ErrorIs(t, limiter.Wait(cancelled, "c"), context.Canceled)
+}
+
+func TestSchedulerRunsOneOfEachName(t *testing.T) {
+	logDir := t.TempDir()
+	scheduler := NewScheduler(NewBudget(0, 0), NewRateLimiter(0), logDir, "session")
+	model := &models.Model{ID: "m", Provider: &models.Provider{ID: "p"}}
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	err := scheduler.Start("titler", model, func(ctx context.Context, env *Env) error {
+		env.Log.Printf("working")
+		<-release
+		return nil
+	}, func(err error) { done <- err })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"titler"}, scheduler.Running())
+
+	assert.Error(t, scheduler.Start("titler", model, func(ctx context.Context, env *Env) error { return nil }, nil))
+
+	close(release)
+	assert.NoError(t, <-done)
+	scheduler.Close()
+	assert.Empty(t, scheduler.Running())
+
+	logged, err := os.ReadFile(filepath.Join(logDir, "titler.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(logged), "[session] ")
+	assert.Contains(t, string(logged), "working")
+}
+
+func TestSchedulerRunWaits(t *testing.T) {
+	scheduler := NewScheduler(NewBudget(10, 0), NewRateLimiter(0), t.TempDir(), "session")
+	model := &models.Model{ID: "m", Provider: &models.Provider{ID: "p"}}
+
+	ran := false
+	assert.NoError(t, scheduler.Run(context.Background(), "summarizer", model, func(ctx context.Context, env *Env) error {
+		ran = true
+		return nil
+	}))
+	assert.True(t, ran)
+
+	scheduler.Budget().Spend("summarizer", 11, 0)
+	assert.Error(t, scheduler.Run(context.Background(), "summarizer", model, func(ctx context.Context, env *Env) error { return nil }))
+	assert.Error(t, scheduler.Run(context.Background(), "summarizer", nil, func(ctx context.Context, env *Env) error { return nil }))
+}
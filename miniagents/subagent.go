@@ -0,0 +1,99 @@
+package miniagents
+
+import (
+	"agent/api"
+	"agent/models"
+	"agent/tools"
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:embed subagent_prompt.md
+var subAgentPromptTemplate string
+
+// RunSubAgent works on task with its own message history and live context until the model answers
+// without tool calls, and returns that answer as the report. onToolCall, if set, is called before
+// each tool runs so callers can show progress.
+func RunSubAgent(ctx context.Context, model *models.Model, task string, liveContext tools.LiveContextManager, agentTools map[string]models.ToolDefinition, maxIterations int, onToolCall func(name string)) (string, error) {
+	messages := []models.Message{newMessage("user", task)}
+
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		content, toolCalls, err := api.Invoke(ctx, model, messages, buildSubAgentPrompt(liveContext), agentTools, nil)
+		if err != nil {
+			return "", fmt.Errorf("sub-agent request failed: %w", err)
+		}
+
+		if len(toolCalls) == 0 {
+			return content, nil
+		}
+
+		assistant := newMessage("assistant", content)
+		assistant.ToolCalls = toolCalls
+		messages = append(messages, assistant)
+
+		for _, toolCall := range toolCalls {
+			if onToolCall != nil {
+				onToolCall(toolCall.Function.Name)
+			}
+			result := newMessage("tool", runSubAgentTool(ctx, agentTools, toolCall).JSON())
+			result.ToolName = toolCall.Function.Name
+			result.ToolCallID = toolCall.ID
+			messages = append(messages, result)
+		}
+	}
+
+	// Out of iterations: ask for a report with what has been learned so far
+	messages = append(messages, newMessage("user", "You have run out of steps. Stop exploring and write your report now with what you have found."))
+	content, _, err := api.Invoke(ctx, model, messages, buildSubAgentPrompt(liveContext), nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("sub-agent request failed: %w", err)
+	}
+	return content, nil
+}
+
+// runSubAgentTool executes one tool call and wraps its outcome in a result envelope
+func runSubAgentTool(ctx context.Context, agentTools map[string]models.ToolDefinition, toolCall models.ToolCall) models.ToolResultEnvelope {
+	tool, exists := agentTools[toolCall.Function.Name]
+	if !exists {
+		return models.NewToolResultEnvelope("error", fmt.Sprintf("tool '%s' is not available to this sub-agent", toolCall.Function.Name), nil)
+	}
+
+	params, repairs, err := tools.ParseArguments(toolCall.Function.Arguments)
+	if err != nil {
+		return models.NewToolResultEnvelope("error", fmt.Sprintf("The tool was not run: %v. Call it again with the arguments as a single JSON object matching its schema.", err), nil)
+	}
+
+	coercions, err := tools.CoerceParams(tool.Schema, params)
+	if err != nil {
+		return models.NewToolResultEnvelope("error", err.Error(), nil)
+	}
+	coercions = append(repairs, coercions...)
+
+	_, agentMessage, err := tool.Func(ctx, params)
+	if err != nil {
+		return models.NewToolResultEnvelope("error", fmt.Sprintf("Tool execution failed: %v", err), nil)
+	}
+	envelope := models.NewToolResultEnvelope("success", agentMessage, nil)
+	envelope.Notes = coercions
+	return envelope
+}
+
+func buildSubAgentPrompt(liveContext tools.LiveContextManager) string {
+	prompt := strings.ReplaceAll(subAgentPromptTemplate, "{LIVE_CONTEXT_FILES}", liveContext.SerializeFiles())
+	return strings.ReplaceAll(prompt, "{LIVE_CONTEXT_DIRECTORIES}", liveContext.SerializeDirectories())
+}
+
+func newMessage(role, content string) models.Message {
+	return models.Message{
+		ID:        uuid.New().String(),
+		Role:      role,
+		Content:   content,
+		Timestamp: time.Now(),
+		Status:    "active",
+	}
+}
@@ -0,0 +1,29 @@
+package miniagents
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const titlerPrompt = `You write titles for coding sessions. Reply with only a title of at most 6 words describing the user's task, without quotes or trailing punctuation.`
+
+// TitleSession returns a short title for a session from its first request and the response to it
+func TitleSession(ctx context.Context, env *Env, request, response string) (string, error) {
+	if len(response) > 2000 {
+		response = response[:2000]
+	}
+	prompt := fmt.Sprintf("User request:\n%s\n\nAssistant response:\n%s", request, response)
+
+	content, _, err := env.Invoke(ctx, []models.Message{newMessage("user", prompt)}, titlerPrompt, nil)
+	if err != nil {
+		return "", err
+	}
+	title := strings.Trim(strings.TrimSpace(content), "\"'.")
+	if title == "" {
+		return "", fmt.Errorf("the model returned an empty title")
+	}
+	env.Log.Printf("title: %s", title)
+	return title, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"agent/theme"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const modelListTimeout = 30 * time.Second
+
+// Settings given to models found by /models refresh, matching the models in the default config
+var discoveredModelConfig = models.ModelConfig{MaxTokens: 4096, Temperature: 0.7, TopP: 0.9}
+
+func handleModels(a *Agent, args []string) string {
+	if len(args) > 0 && args[0] == "refresh" {
+		return a.refreshModels(args[1:])
+	}
+	if len(args) > 1 {
+		return theme.ErrorText("Usage: /models [provider] | /models refresh [provider...]")
+	}
+
+	var result strings.Builder
+	for _, provider := range a.config.Providers {
+		if len(args) == 1 && provider.ID != args[0] {
+			continue
+		}
+		result.WriteString(theme.InfoText(fmt.Sprintf("%s (%d models):", provider.ID, len(provider.Models))) + "\n")
+		for _, model := range provider.Models {
+			marker := " "
+			if a.currentModel != nil && a.currentModel.ID == model.ID && a.currentModel.Provider != nil && a.currentModel.Provider.ID == provider.ID {
+				marker = "*"
+			}
+			result.WriteString(theme.InfoText(fmt.Sprintf(" %s %s:%s%s", marker, provider.ID, model.ID, describeCapabilities(model))) + "\n")
+		}
+	}
+	if result.Len() == 0 {
+		return theme.ErrorText(fmt.Sprintf("No provider named %s", args[0]))
+	}
+	result.WriteString(theme.InfoText("Switch with /model <provider>:<model>; /models refresh asks the providers for their current models"))
+	return result.String()
+}
+
+// describeCapabilities summarizes the metadata of a model that has any
+func describeCapabilities(model *models.Model) string {
+	var details []string
+	if window := model.Config.ContextWindow; window > 0 {
+		details = append(details, fmt.Sprintf("%dk context", window/1000))
+	}
+	if !model.AcceptsTools() {
+		details = append(details, "no tools")
+	}
+	if model.AcceptsImages() {
+		details = append(details, "images")
+	}
+	if pricing := model.Config.Pricing; pricing != nil {
+		details = append(details, fmt.Sprintf("$%g/$%g per M", pricing.Input, pricing.Output))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// refreshModels asks each provider, or the named ones, for its models and merges them into the
+// session's config and the global config
+func (a *Agent) refreshModels(providerIDs []string) string {
+	global, err := loadGlobalConfig()
+	if err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to read the global config: %v", err))
+	}
+	var result strings.Builder
+	refreshed := 0
+	for _, provider := range a.config.Providers {
+		if len(providerIDs) > 0 && !slices.Contains(providerIDs, provider.ID) {
+			continue
+		}
+		refreshed++
+		ctx, cancel := context.WithTimeout(context.Background(), modelListTimeout)
+		listed, err := api.ListModels(ctx, provider)
+		cancel()
+		if err != nil {
+			result.WriteString(theme.ErrorText(fmt.Sprintf("%s: %v", provider.ID, err)) + "\n")
+			continue
+		}
+
+		added, updated := mergeDiscoveredModels(provider, listed)
+		// Providers that only the project config has aren't saved
+		if saved := findProvider(global, provider.ID); saved != nil {
+			mergeDiscoveredModels(saved, listed)
+		}
+		result.WriteString(theme.SuccessText(fmt.Sprintf("%s: %d models listed, %d added, %d updated", provider.ID, len(listed), added, updated)) + "\n")
+	}
+	if refreshed == 0 {
+		return theme.ErrorText(fmt.Sprintf("No provider named %s", strings.Join(providerIDs, ", ")))
+	}
+
+	if err := SaveConfig(global); err != nil {
+		result.WriteString(theme.ErrorText(fmt.Sprintf("Failed to save the models: %v", err)) + "\n")
+	}
+	return strings.TrimRight(result.String(), "\n")
+}
+
+// mergeDiscoveredModels adds the listed models the provider doesn't have yet and fills in metadata
+// its existing models leave unset. Models the provider no longer lists are kept, since they may have
+// been added by hand. It returns how many models were added and updated.
+func mergeDiscoveredModels(provider *models.Provider, listed []*models.Model) (int, int) {
+	existing := make(map[string]*models.Model)
+	for _, model := range provider.Models {
+		existing[model.ID] = model
+	}
+
+	added, updated := 0, 0
+	for _, found := range listed {
+		model, ok := existing[found.ID]
+		if !ok {
+			model = &models.Model{ID: found.ID, Name: found.Name, Config: discoveredModelConfig}
+			if model.Name == "" {
+				model.Name = found.ID
+			}
+			model.Config.ContextWindow = found.Config.ContextWindow
+			model.Config.SupportsTools = found.Config.SupportsTools
+			model.Config.SupportsVision = found.Config.SupportsVision
+			model.Config.Pricing = found.Config.Pricing
+			if window := model.Config.ContextWindow; window > 0 && model.Config.MaxTokens >= window {
+				model.Config.MaxTokens = window / 2
+			}
+			provider.Models = append(provider.Models, model)
+			existing[found.ID] = model
+			added++
+			continue
+		}
+
+		changed := false
+		if model.Config.ContextWindow == 0 && found.Config.ContextWindow > 0 {
+			model.Config.ContextWindow = found.Config.ContextWindow
+			changed = true
+		}
+		if model.Config.SupportsTools == nil && found.Config.SupportsTools != nil {
+			model.Config.SupportsTools = found.Config.SupportsTools
+			changed = true
+		}
+		if !model.AcceptsImages() && found.Config.SupportsVision {
+			model.Config.SupportsVision = true
+			changed = true
+		}
+		if model.Config.Pricing == nil && found.Config.Pricing != nil {
+			model.Config.Pricing = found.Config.Pricing
+			changed = true
+		}
+		if changed {
+			updated++
+		}
+	}
+	return added, updated
+}
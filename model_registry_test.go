@@ -0,0 +1,41 @@
+package main
+
+import (
+	"agent/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDiscoveredModels(t *testing.T) {
+	noTools := false
+	provider := &models.Provider{ID: "openrouter", Models: []*models.Model{
+		{ID: "hand-tuned", Name: "Mine", Config: models.ModelConfig{MaxTokens: 8000, Temperature: 0.2}},
+		{ID: "unlisted", Config: models.ModelConfig{MaxTokens: 100}},
+	}}
+	listed := []*models.Model{
+		{ID: "hand-tuned", Config: models.ModelConfig{ContextWindow: 200000, Pricing: &models.Pricing{Input: 3, Output: 15}}},
+		{ID: "new/model", Name: "New Model", Config: models.ModelConfig{ContextWindow: 2048, SupportsTools: &noTools}},
+	}
+
+	added, updated := mergeDiscoveredModels(provider, listed)
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, updated)
+	assert.Len(t, provider.Models, 3, "models the provider no longer lists are kept")
+
+	tuned := provider.Models[0]
+	assert.Equal(t, "Mine", tuned.Name)
+	assert.Equal(t, 8000, tuned.Config.MaxTokens, "settings made by hand are kept")
+	assert.Equal(t, 200000, tuned.Config.ContextWindow)
+	assert.Equal(t, 15.0, tuned.Config.Pricing.Output)
+
+	discovered := provider.Models[2]
+	assert.Equal(t, "New Model", discovered.Name)
+	assert.Equal(t, 1024, discovered.Config.MaxTokens, "max_tokens leaves room for the prompt in a small window")
+	assert.Equal(t, 0.7, discovered.Config.Temperature)
+	assert.False(t, discovered.AcceptsTools())
+
+	added, updated = mergeDiscoveredModels(provider, listed)
+	assert.Zero(t, added)
+	assert.Zero(t, updated)
+}
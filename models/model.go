@@ -2,6 +2,9 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,16 +13,19 @@ type Provider struct {
 	ID      string   `json:"id"`
 	Name    string   `json:"name"`
 	BaseURL string   `json:"base_url"`
-	APIKey  string   `json:"api_key,omitempty"` // Can be env:VAR_NAME or direct key
+	APIKey  string   `json:"api_key,omitempty"` // Can be env:VAR_NAME, keychain:<name>, cmd:<command>, or the key itself
 	Models  []*Model `json:"models"`
 }
 
 // Model represents a static model configuration
 type Model struct {
-	ID       string      `json:"id"`
-	Name     string      `json:"name"`
-	Config   ModelConfig `json:"config"`
-	Provider *Provider   `json:"-"` // Back-reference, not serialized
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Config      ModelConfig `json:"config"`
+	Vision      bool        `json:"vision,omitempty"`       // accepts images attached to messages; the same as config.supports_vision
+	Family      string      `json:"family,omitempty"`       // models of the same family on other providers can take its requests
+	PromptCache string      `json:"prompt_cache,omitempty"` // "cache_control", "key", or "off"; picked from the model ID and provider when unset
+	Provider    *Provider   `json:"-"`                      // Back-reference, not serialized
 }
 
 // ModelConfig holds model-specific configuration
@@ -27,8 +33,48 @@ type ModelConfig struct {
 	MaxTokens   int     `json:"max_tokens"`
 	Temperature float64 `json:"temperature"`
 	TopP        float64 `json:"top_p"`
+	Seed        *int64  `json:"seed,omitempty"` // fixed sampling seed; when unset each turn gets a random seed
+
+	Stop             []string `json:"stop,omitempty"`              // sequences that end the response, at most 4
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"` // -2 to 2; positive values discourage repeating tokens. Not sent when unset
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`  // -2 to 2; positive values encourage new topics. Not sent when unset
+
+	ContextWindow  int      `json:"context_window,omitempty"`  // tokens the model takes, prompt and response together; 0 when unknown
+	SupportsTools  *bool    `json:"supports_tools,omitempty"`  // false for models that can't call tools; assumed true when unset
+	SupportsVision bool     `json:"supports_vision,omitempty"` // accepts images attached to messages
+	Pricing        *Pricing `json:"pricing,omitempty"`
+}
+
+// Pricing is what a model costs in dollars per million tokens
+type Pricing struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input,omitempty"` // prompt tokens read from the provider's cache; the input price when unset
+	Output      float64 `json:"output"`
+}
+
+// Cost returns the price of the tokens, where cached tokens are part of the prompt tokens
+func (p Pricing) Cost(promptTokens, cachedTokens, completionTokens int) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	return (float64(promptTokens-cachedTokens)*p.Input + float64(cachedTokens)*cachedPrice + float64(completionTokens)*p.Output) / 1e6
+}
+
+// AcceptsTools reports whether tools can be sent to the model
+func (m *Model) AcceptsTools() bool {
+	return m.Config.SupportsTools == nil || *m.Config.SupportsTools
+}
+
+// AcceptsImages reports whether images can be attached to messages sent to the model
+func (m *Model) AcceptsImages() bool {
+	return m.Vision || m.Config.SupportsVision
 }
 
+// ContextMessageID identifies the context message sent after the history. It is rebuilt for every
+// request and never stored, so providers cache the history up to the message before it.
+const ContextMessageID = "live-context"
+
 // Message represents a conversation message
 type Message struct {
 	ID         string     `json:"id"` // Unique ID for the message across its lifecycle
@@ -38,7 +84,8 @@ type Message struct {
 	ToolName   string     `json:"tool_name,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
-	Status     string     `json:"status,omitempty"` // e.g., "active", "edited", "deleted"
+	Status     string     `json:"status,omitempty"` // e.g., "active", "cancelled" (by the user before any content), "interrupted" (by the user mid-response), "edited", "deleted"
+	Images     []string   `json:"images,omitempty"` // image URLs, or data: URLs holding the image itself
 }
 
 // ToolCall represents a tool call in a message
@@ -54,11 +101,47 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// ToolResultEnvelope is the JSON document stored as the content of every tool message, so the model
+// and external consumers can parse results the same way for every tool
+type ToolResultEnvelope struct {
+	Status    string   `json:"status"`              // "success", "error", "cancelled", or "denied"
+	Summary   string   `json:"summary"`             // one line describing the outcome
+	Data      string   `json:"data,omitempty"`      // full tool output when it doesn't fit in the summary
+	Artifacts []string `json:"artifacts,omitempty"` // files created, modified, or deleted by the tool
+	Notes     []string `json:"notes,omitempty"`     // arguments converted to their schema types, and hook output
+}
+
+// NewToolResultEnvelope builds an envelope whose summary is the first line of output
+func NewToolResultEnvelope(status, output string, artifacts []string) ToolResultEnvelope {
+	output = strings.TrimSpace(output)
+	summary, _, _ := strings.Cut(output, "\n")
+	if len(summary) > 200 {
+		summary = summary[:200] + "..."
+	}
+
+	envelope := ToolResultEnvelope{Status: status, Summary: summary, Artifacts: artifacts}
+	if output != summary {
+		envelope.Data = output
+	}
+	return envelope
+}
+
+// JSON serializes the envelope for use as tool message content
+func (e ToolResultEnvelope) JSON() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf(`{"status":"error","summary":"failed to encode tool result: %v"}`, err)
+	}
+	return string(data)
+}
+
 type ToolResult struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	IsError bool   `json:"is_error"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Content     string   `json:"content"`
+	IsError     bool     `json:"is_error"`
+	IsCancelled bool     `json:"is_cancelled"`     // the user cancelled the turn before the tool finished
+	Images      []string `json:"images,omitempty"` // images the tool attached, as URLs or data: URLs
 }
 
 // ToolFunc defines the signature for tool functions
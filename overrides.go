@@ -0,0 +1,199 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// toolCallsPrefix scopes a /set override to the requests that continue a turn after tool calls
+const toolCallsPrefix = "tool_calls."
+
+// samplingOverrides replace the selected model's sampling settings for the rest of the session
+type samplingOverrides struct {
+	Temperature      *float64
+	TopP             *float64
+	MaxTokens        *int
+	FrequencyPenalty *float64
+	PresencePenalty  *float64
+}
+
+// sessionOverrides are the settings changed with /set. ToolCalls applies on top of All to the
+// requests after the first in a turn, which carry tool results back to the model.
+type sessionOverrides struct {
+	All       samplingOverrides
+	ToolCalls samplingOverrides
+}
+
+func (o samplingOverrides) apply(config models.ModelConfig) models.ModelConfig {
+	if o.Temperature != nil {
+		config.Temperature = *o.Temperature
+	}
+	if o.TopP != nil {
+		config.TopP = *o.TopP
+	}
+	if o.MaxTokens != nil {
+		config.MaxTokens = *o.MaxTokens
+	}
+	if o.FrequencyPenalty != nil {
+		config.FrequencyPenalty = o.FrequencyPenalty
+	}
+	if o.PresencePenalty != nil {
+		config.PresencePenalty = o.PresencePenalty
+	}
+	return config
+}
+
+func (o samplingOverrides) empty() bool {
+	return o.Temperature == nil && o.TopP == nil && o.MaxTokens == nil && o.FrequencyPenalty == nil && o.PresencePenalty == nil
+}
+
+// withOverrides returns a copy of model with the session's /set overrides applied
+func (a *Agent) withOverrides(model *models.Model, afterToolCalls bool) *models.Model {
+	a.mu.RLock()
+	overrides := a.overrides
+	a.mu.RUnlock()
+
+	overridden := *model
+	overridden.Config = overrides.All.apply(overridden.Config)
+	if afterToolCalls {
+		overridden.Config = overrides.ToolCalls.apply(overridden.Config)
+	}
+	return &overridden
+}
+
+// set parses and stores one override, or clears it when value is "default"
+func (o *samplingOverrides) set(name, value string) error {
+	clear := value == "default"
+	switch name {
+	case "temperature", "top_p", "frequency_penalty", "presence_penalty":
+		target, low, high := &o.Temperature, 0.0, 2.0
+		switch name {
+		case "top_p":
+			target, high = &o.TopP, 1.0
+		case "frequency_penalty":
+			target, low = &o.FrequencyPenalty, -2.0
+		case "presence_penalty":
+			target, low = &o.PresencePenalty, -2.0
+		}
+		if clear {
+			*target = nil
+			return nil
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < low || number > high {
+			return fmt.Errorf("%s must be a number from %g to %g, got %q", name, low, high, value)
+		}
+		*target = &number
+	case "max_tokens":
+		if clear {
+			o.MaxTokens = nil
+			return nil
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return fmt.Errorf("max_tokens must be a whole number above 0, got %q", value)
+		}
+		o.MaxTokens = &number
+	default:
+		return fmt.Errorf("unknown setting %q; use temperature, top_p, max_tokens, frequency_penalty, or presence_penalty, optionally prefixed with %s", name, toolCallsPrefix)
+	}
+	return nil
+}
+
+// describeOverrides lists the overrides that are set, e.g. "temperature 0.2, max_tokens 8000"
+func describeOverrides(o samplingOverrides) string {
+	var parts []string
+	if o.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("temperature %g", *o.Temperature))
+	}
+	if o.TopP != nil {
+		parts = append(parts, fmt.Sprintf("top_p %g", *o.TopP))
+	}
+	if o.MaxTokens != nil {
+		parts = append(parts, fmt.Sprintf("max_tokens %d", *o.MaxTokens))
+	}
+	if o.FrequencyPenalty != nil {
+		parts = append(parts, fmt.Sprintf("frequency_penalty %g", *o.FrequencyPenalty))
+	}
+	if o.PresencePenalty != nil {
+		parts = append(parts, fmt.Sprintf("presence_penalty %g", *o.PresencePenalty))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// modelSettings describes the sampling settings requests use, marking the ones set with /set
+func (a *Agent) modelSettings() []string {
+	if a.currentModel == nil {
+		return nil
+	}
+	a.mu.RLock()
+	overrides := a.overrides
+	a.mu.RUnlock()
+
+	config := overrides.All.apply(a.currentModel.Config)
+	settings := fmt.Sprintf("Settings: temperature %g, top_p %g, max_tokens %d", config.Temperature, config.TopP, config.MaxTokens)
+	if config.FrequencyPenalty != nil {
+		settings += fmt.Sprintf(", frequency_penalty %g", *config.FrequencyPenalty)
+	}
+	if config.PresencePenalty != nil {
+		settings += fmt.Sprintf(", presence_penalty %g", *config.PresencePenalty)
+	}
+	if len(config.Stop) > 0 {
+		settings += fmt.Sprintf(", stop %q", config.Stop)
+	}
+	lines := []string{settings}
+	if !overrides.All.empty() {
+		lines = append(lines, "  set for this session: "+describeOverrides(overrides.All))
+	}
+	if !overrides.ToolCalls.empty() {
+		lines = append(lines, "  after tool calls: "+describeOverrides(overrides.ToolCalls))
+	}
+	return lines
+}
+
+func handleSet(a *Agent, args []string) string {
+	if len(args) == 0 {
+		lines := a.modelSettings()
+		if len(lines) == 0 {
+			return theme.ErrorText("No model configured. Use /model to set one.")
+		}
+		lines = append(lines, "Usage: /set [tool_calls.]<temperature|top_p|max_tokens|frequency_penalty|presence_penalty> <value|default>, or /set reset")
+		return theme.InfoText(strings.Join(lines, "\n"))
+	}
+
+	if len(args) == 1 && args[0] == "reset" {
+		a.mu.Lock()
+		a.overrides = sessionOverrides{}
+		a.mu.Unlock()
+		return theme.SuccessText("Cleared the session's overrides; requests use the config's settings again")
+	}
+	if len(args) != 2 {
+		return theme.ErrorText("Usage: /set [tool_calls.]<temperature|top_p|max_tokens|frequency_penalty|presence_penalty> <value|default>, or /set reset")
+	}
+
+	name, value := args[0], args[1]
+	a.mu.Lock()
+	target, scope := &a.overrides.All, "for this session"
+	if trimmed, ok := strings.CutPrefix(name, toolCallsPrefix); ok {
+		name, target, scope = trimmed, &a.overrides.ToolCalls, "for requests after tool calls"
+	}
+	err := target.set(name, value)
+	a.mu.Unlock()
+	if err != nil {
+		return theme.ErrorText(err.Error())
+	}
+
+	if value == "default" {
+		return theme.SuccessText(fmt.Sprintf("%s is back to the config's setting %s", name, scope))
+	}
+	message := theme.SuccessText(fmt.Sprintf("Set %s to %s %s; config.json is unchanged", name, value, scope))
+	if model := a.currentModel; name == "max_tokens" && model != nil && model.Config.ContextWindow > 0 {
+		if tokens, _ := strconv.Atoi(value); tokens >= model.Config.ContextWindow {
+			message += "\n" + theme.WarningText(fmt.Sprintf("Note: that leaves no room for the prompt in the %d token context window", model.Config.ContextWindow))
+		}
+	}
+	return message
+}
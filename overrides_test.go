@@ -0,0 +1,47 @@
+package main
+
+import (
+	"agent/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplingOverridesSet(t *testing.T) {
+	var overrides samplingOverrides
+	require.NoError(t, overrides.set("temperature", "0.2"))
+	require.NoError(t, overrides.set("max_tokens", "8000"))
+	assert.Error(t, overrides.set("temperature", "3"))
+	assert.Error(t, overrides.set("top_p", "1.5"))
+	assert.Error(t, overrides.set("max_tokens", "0"))
+	assert.Error(t, overrides.set("seed", "1"))
+	assert.Equal(t, "temperature 0.2, max_tokens 8000", describeOverrides(overrides))
+
+	require.NoError(t, overrides.set("temperature", "default"))
+	assert.Equal(t, "max_tokens 8000", describeOverrides(overrides))
+
+	require.NoError(t, overrides.set("frequency_penalty", "-0.5"))
+	assert.Error(t, overrides.set("presence_penalty", "2.5"))
+	config := overrides.apply(models.ModelConfig{Stop: []string{"###"}})
+	require.NotNil(t, config.FrequencyPenalty)
+	assert.Equal(t, -0.5, *config.FrequencyPenalty)
+	assert.Nil(t, config.PresencePenalty)
+	assert.Equal(t, []string{"###"}, config.Stop)
+}
+
+func TestWithOverrides(t *testing.T) {
+	model := &models.Model{ID: "gpt", Config: models.ModelConfig{MaxTokens: 4096, Temperature: 0.7, TopP: 0.9}}
+	a := &Agent{currentModel: model}
+	assert.Contains(t, handleSet(a, []string{"temperature", "0.2"}), "Set temperature")
+	assert.Contains(t, handleSet(a, []string{"tool_calls.temperature", "0"}), "after tool calls")
+
+	first := a.withOverrides(model, false)
+	assert.Equal(t, 0.2, first.Config.Temperature)
+	assert.Equal(t, 0.9, first.Config.TopP)
+	assert.Equal(t, 0.0, a.withOverrides(model, true).Config.Temperature)
+	assert.Equal(t, 0.7, model.Config.Temperature, "the configured model is unchanged")
+
+	handleSet(a, []string{"reset"})
+	assert.Equal(t, 0.7, a.withOverrides(model, true).Config.Temperature)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"agent/logging"
+	"agent/models"
+	"agent/permissions"
+	"agent/theme"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// newPermissionPolicy builds the policy from the configured rules, skipping invalid ones, and confines
+// tool paths to root and the allowed roots unless security.allow_outside_workspace is set
+func newPermissionPolicy(root string, rules []string, security SecurityConfig) *permissions.Policy {
+	policy, _ := permissions.New(root, nil)
+	for _, rule := range rules {
+		if err := policy.Add(rule); err != nil {
+			logging.Warnf("Ignoring %v", err)
+		}
+	}
+	if !security.AllowOutsideWorkspace {
+		policy.ConfineTo(append([]string{root}, security.AllowedRoots...))
+	}
+	return policy
+}
+
+// guardTool wraps a tool so calls to it made outside ExecuteToolCall, such as by sub-agents, go
+// through the same checks as the model's calls. A call that isn't run fails with the reason.
+func (a *Agent) guardTool(tool models.ToolDefinition) models.ToolDefinition {
+	guarded := tool
+	guarded.Func = func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+		arguments, err := json.Marshal(params)
+		if err != nil {
+			return "", "", err
+		}
+		toolCall := models.ToolCall{Type: "function", Function: models.FunctionCall{Name: tool.Name, Arguments: string(arguments)}}
+		envelope, err := a.runTool(ctx, tool, toolCall, params, nil, false)
+		if err != nil {
+			return "", "", err
+		}
+		result := envelope.Summary
+		if envelope.Data != "" {
+			result = envelope.Data
+		}
+		if envelope.Status != "success" {
+			return "", "", errors.New(result)
+		}
+		if len(envelope.Notes) > 0 {
+			result += "\n\nNotes:\n" + strings.Join(envelope.Notes, "\n")
+		}
+		return "", result, nil
+	}
+	return guarded
+}
+
+func handlePermissions(a *Agent, args []string) string {
+	if len(args) == 0 {
+		var result strings.Builder
+		if workspace := a.permissions.Workspace(); len(workspace) > 0 {
+			result.WriteString(theme.InfoText("Tool paths are confined to: "+strings.Join(workspace, ", ")) + "\n")
+		} else {
+			result.WriteString(theme.WarningText("Tool paths are not confined to the workspace (security.allow_outside_workspace)") + "\n")
+		}
+		rules := a.permissions.Rules()
+		if len(rules) == 0 {
+			return result.String() + theme.InfoText("No permission rules; every tool call is allowed. Add one with /permissions add <rule>.")
+		}
+		result.WriteString(theme.InfoText("Permission rules:") + "\n")
+		for i, rule := range rules {
+			result.WriteString(theme.InfoText(fmt.Sprintf("  %d. %s", i+1, rule)) + "\n")
+		}
+		return result.String()
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return theme.ErrorText("Usage: /permissions add [deny] tool[:pattern]")
+		}
+		rule := strings.Join(args[1:], " ")
+		if err := a.permissions.Add(rule); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return theme.SuccessText("Added permission rule for this session: " + rule)
+	case "remove":
+		if len(args) != 2 {
+			return theme.ErrorText("Usage: /permissions remove <n>")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return theme.ErrorText("Invalid rule number. Usage: /permissions remove <n>")
+		}
+		rule, err := a.permissions.Remove(n)
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return theme.SuccessText("Removed permission rule for this session: " + rule.String())
+	}
+	return theme.ErrorText("Invalid arguments. Usage: /permissions [add <rule>|remove <n>]")
+}
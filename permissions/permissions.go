@@ -0,0 +1,408 @@
+// Package permissions decides whether a tool call is allowed by matching it against allow and deny
+// rules such as "edit_file:./src/**", "shell:git *", and "deny shell:rm *".
+package permissions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// commandSubstitution matches $(...), backticks, and process substitution, which run commands inside
+// another command where they can't be checked on their own
+var commandSubstitution = regexp.MustCompile("\\$\\(|`|[<>]\\(")
+
+// Rule allows or denies a tool, optionally only for paths or commands matching Pattern
+type Rule struct {
+	Deny    bool
+	Tool    string // tool name, or * for every tool
+	Pattern string // glob matched against the call's path or shell command; empty matches everything
+}
+
+// ParseRule parses "[allow|deny] tool[:pattern]"
+func ParseRule(text string) (Rule, error) {
+	var rule Rule
+	text = strings.TrimSpace(text)
+	if rest, ok := strings.CutPrefix(text, "deny "); ok {
+		rule.Deny = true
+		text = strings.TrimSpace(rest)
+	} else if rest, ok := strings.CutPrefix(text, "allow "); ok {
+		text = strings.TrimSpace(rest)
+	}
+
+	rule.Tool, rule.Pattern, _ = strings.Cut(text, ":")
+	rule.Tool = strings.TrimSpace(rule.Tool)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Tool == "" || strings.ContainsAny(rule.Tool, " \t") {
+		return Rule{}, fmt.Errorf("invalid permission rule %q: expected [allow|deny] tool[:pattern]", text)
+	}
+	if _, err := GlobRegexp(rule.Pattern, rule.Tool != "shell"); err != nil {
+		return Rule{}, fmt.Errorf("invalid permission rule %q: %w", text, err)
+	}
+	return rule, nil
+}
+
+// String formats the rule in the syntax ParseRule accepts
+func (r Rule) String() string {
+	text := r.Tool
+	if r.Pattern != "" {
+		text += ":" + r.Pattern
+	}
+	if r.Deny {
+		return "deny " + text
+	}
+	return text
+}
+
+// Policy is an ordered, mutable set of rules. A call is denied when any deny rule matches it. When a
+// tool has allow rules, calls matching none of them are denied; tools without rules are allowed.
+type Policy struct {
+	mu        sync.RWMutex
+	root      string
+	rules     []Rule
+	workspace []string // directories paths must be inside, with symlinks resolved; nil allows any path
+}
+
+// New parses rules into a policy; relative path patterns are resolved against root
+func New(root string, rules []string) (*Policy, error) {
+	policy := &Policy{root: root}
+	for _, text := range rules {
+		if err := policy.Add(text); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// Rules returns a copy of the policy's rules in order
+func (p *Policy) Rules() []Rule {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Rule(nil), p.rules...)
+}
+
+// Add parses and appends a rule
+func (p *Policy) Add(text string) error {
+	rule, err := ParseRule(text)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, rule)
+	return nil
+}
+
+// Remove deletes the rule at the 1-based position n
+func (p *Policy) Remove(n int) (Rule, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n < 1 || n > len(p.rules) {
+		return Rule{}, fmt.Errorf("no rule %d", n)
+	}
+	rule := p.rules[n-1]
+	p.rules = append(p.rules[:n-1], p.rules[n:]...)
+	return rule, nil
+}
+
+// ConfineTo refuses calls whose path is outside every one of roots, after resolving symlinks so a link
+// inside a root can't reach outside it. Relative roots are resolved against the policy's root. No
+// roots lifts the confinement.
+func (p *Policy) ConfineTo(roots []string) {
+	var workspace []string
+	for _, root := range roots {
+		workspace = append(workspace, resolveSymlinks(p.absolute(expandHome(root))))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workspace = workspace
+}
+
+// Workspace returns the directories paths are confined to, or nil when they aren't confined
+func (p *Policy) Workspace() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.workspace...)
+}
+
+// Check returns an error describing why the call is denied, or nil if it's allowed. The call's
+// subject is its command for shell and its path for other tools. A shell call's cwd must also be
+// inside the workspace and not denied by a rule for every tool, such as "deny *:~/.ssh/**".
+func (p *Policy) Check(tool string, params map[string]interface{}) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if command, ok := params["command"].(string); ok && tool == "shell" {
+		if cwd, _ := params["cwd"].(string); cwd != "" {
+			cwd = p.absolute(cwd)
+			if err := p.checkWorkspace(cwd); err != nil {
+				return err
+			}
+			for _, rule := range p.rules {
+				if !rule.Deny || rule.Tool != "*" {
+					continue
+				}
+				if matches, err := p.matches(rule.Pattern, cwd, true); err != nil || matches {
+					return ruleDenial(rule, err)
+				}
+			}
+		}
+		if commandSubstitution.MatchString(command) && p.hasRules(tool) {
+			return fmt.Errorf("%q runs a command substitution, which can't be checked against the permission rules; run the inner command on its own", command)
+		}
+		// Every part of a compound command must be allowed on its own
+		for _, part := range splitCommand(command) {
+			if part = strings.TrimSpace(part); part != "" {
+				if err := p.check(tool, part, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	path, _ := params["path"].(string)
+	if path != "" {
+		path = p.absolute(path)
+		if err := p.checkWorkspace(path); err != nil {
+			return err
+		}
+	}
+	return p.check(tool, path, true)
+}
+
+// splitCommand splits a compound shell command at &&, ||, ;, |, &, and newlines. The & of a
+// redirection such as 2>&1 or &>file doesn't split it.
+func splitCommand(command string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ';' || c == '\n' || c == '|':
+		case c == '&' && !(i > 0 && (command[i-1] == '>' || command[i-1] == '<')) && !(i+1 < len(command) && command[i+1] == '>'):
+		default:
+			continue
+		}
+		parts = append(parts, command[start:i])
+		if (c == '&' || c == '|') && i+1 < len(command) && command[i+1] == c {
+			i++
+		}
+		start = i + 1
+	}
+	return append(parts, command[start:])
+}
+
+// hasRules reports whether any rule applies to tool
+func (p *Policy) hasRules(tool string) bool {
+	for _, rule := range p.rules {
+		if rule.Tool == tool || rule.Tool == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWorkspace returns an error if path resolves to a location outside the workspace
+func (p *Policy) checkWorkspace(path string) error {
+	if p.workspace == nil {
+		return nil
+	}
+	path = expandHome(path)
+	resolved := resolveSymlinks(path)
+	for _, root := range p.workspace {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	if resolved != path {
+		return fmt.Errorf("%q resolves to %q, which is outside the workspace (%s)", path, resolved, strings.Join(p.workspace, ", "))
+	}
+	return fmt.Errorf("%q is outside the workspace (%s)", path, strings.Join(p.workspace, ", "))
+}
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// resolveSymlinks resolves the symlinks in path. For a path that doesn't exist yet, the symlinks in
+// its existing parent directories are resolved.
+func resolveSymlinks(path string) string {
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// ruleDenial explains a call denied by rule. A rule whose pattern doesn't compile denies every call
+// it applies to, so a broken deny rule can't let calls through.
+func ruleDenial(rule Rule, err error) error {
+	if err != nil {
+		return fmt.Errorf("rule %q can't be checked: %v", rule.String(), err)
+	}
+	return fmt.Errorf("denied by rule %q", rule.String())
+}
+
+func (p *Policy) check(tool, subject string, isPath bool) error {
+	hasAllow, allowed := false, false
+	for _, rule := range p.rules {
+		if rule.Tool != tool && rule.Tool != "*" {
+			continue
+		}
+		matches, err := p.matches(rule.Pattern, subject, isPath)
+		if err != nil {
+			return ruleDenial(rule, err)
+		}
+		if rule.Deny && matches {
+			return ruleDenial(rule, nil)
+		}
+		if !rule.Deny {
+			hasAllow = true
+			allowed = allowed || matches
+		}
+	}
+	if hasAllow && !allowed {
+		if subject == "" {
+			return fmt.Errorf("%s is not allowed by any permission rule", tool)
+		}
+		return fmt.Errorf("%s on %q is not allowed by any permission rule", tool, subject)
+	}
+	return nil
+}
+
+func (p *Policy) matches(pattern, subject string, isPath bool) (bool, error) {
+	if pattern == "" || pattern == "*" || pattern == "**" {
+		return true, nil
+	}
+	if subject == "" {
+		return false, nil
+	}
+	if !isPath {
+		matcher, err := GlobRegexp(pattern, false)
+		if err != nil {
+			return false, err
+		}
+		return matcher.MatchString(subject), nil
+	}
+
+	// The root or home directory a relative pattern is resolved against is matched literally, so
+	// glob characters in its name aren't read as part of the pattern
+	base := ""
+	if strings.HasPrefix(pattern, "~/") {
+		base, _ = os.UserHomeDir()
+	} else if !filepath.IsAbs(pattern) {
+		base = p.root
+	}
+	resolved := p.absolute(pattern)
+	prefix, glob := "", resolved
+	if base != "" {
+		base = strings.TrimSuffix(filepath.Clean(base), string(filepath.Separator)) + string(filepath.Separator)
+		if rest, ok := strings.CutPrefix(resolved, base); ok {
+			prefix, glob = base, rest
+		}
+	}
+	expr, err := globExpr(glob, true)
+	if err != nil {
+		return false, err
+	}
+	matcher, err := regexp.Compile("^" + regexp.QuoteMeta(prefix) + expr + "$")
+	if err != nil {
+		return false, err
+	}
+	return matcher.MatchString(subject), nil
+}
+
+func (p *Policy) absolute(path string) string {
+	path = expandHome(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.root, path)
+	}
+	return filepath.Clean(path)
+}
+
+// MatchPath reports whether path matches a path glob, where * stays within one directory and **
+// crosses directories. Relative patterns and paths are resolved against root.
+func MatchPath(root, pattern, path string) bool {
+	p := &Policy{root: root}
+	matches, _ := p.matches(pattern, p.absolute(path), true)
+	return matches
+}
+
+// GlobRegexp compiles a glob into an anchored regexp. In paths * and ? stay within one directory, **
+// crosses directories, **/ also matches no directory at all, {a,b} matches either alternative, and
+// [...] is a character class, negated by [!...]. In commands * and ? match anything and the rest is
+// literal.
+func GlobRegexp(pattern string, isPath bool) (*regexp.Regexp, error) {
+	expr, err := globExpr(pattern, isPath)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^" + expr + "$")
+}
+
+// globExpr converts a glob into an unanchored regular expression, as described for GlobRegexp
+func globExpr(pattern string, isPath bool) (string, error) {
+	var expr strings.Builder
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case isPath && strings.HasPrefix(pattern[i:], "**/"):
+			// Zero or more directories, so **/*.go also matches files at the top
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*' && isPath:
+			expr.WriteString("[^/]*")
+		case c == '*':
+			expr.WriteString(".*")
+		case c == '?' && isPath:
+			expr.WriteString("[^/]")
+		case c == '?':
+			expr.WriteString(".")
+		case c == '{' && isPath:
+			expr.WriteString("(?:")
+			braces++
+		case c == '}' && braces > 0:
+			expr.WriteString(")")
+			braces--
+		case c == ',' && braces > 0:
+			expr.WriteString("|")
+		case c == '[' && isPath:
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed [ in pattern %q", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if braces > 0 {
+		return "", fmt.Errorf("unclosed { in pattern %q", pattern)
+	}
+	return expr.String(), nil
+}
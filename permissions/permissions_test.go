@@ -0,0 +1,177 @@
+package permissions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("deny shell:rm *")
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Deny: true, Tool: "shell", Pattern: "rm *"}, rule)
+	assert.Equal(t, "deny shell:rm *", rule.String())
+
+	rule, err = ParseRule("allow git_commit")
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Tool: "git_commit"}, rule)
+
+	_, err = ParseRule(":rm *")
+	assert.Error(t, err)
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := New("/work", []string{"edit_file:./src/**", "shell:git *", "shell:go test*", "deny shell:git push*", "deny *:**/.env"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		tool    string
+		params  map[string]interface{}
+		allowed bool
+	}{
+		{"edit_file", map[string]interface{}{"path": "/work/src/pkg/a.go"}, true},
+		{"edit_file", map[string]interface{}{"path": "src/a.go"}, true},
+		{"edit_file", map[string]interface{}{"path": "/work/main.go"}, false},
+		{"edit_file", map[string]interface{}{"path": "/work/src/../main.go"}, false},
+		{"create_file", map[string]interface{}{"path": "/work/main.go"}, true},
+		{"create_file", map[string]interface{}{"path": "/work/config/.env"}, false},
+		{"shell", map[string]interface{}{"command": "git status"}, true},
+		{"shell", map[string]interface{}{"command": "git push origin main"}, false},
+		{"shell", map[string]interface{}{"command": "git status && rm -rf /"}, false},
+		{"shell", map[string]interface{}{"command": "go test ./... | tail"}, false},
+		{"shell", map[string]interface{}{"command": "ls"}, false},
+		{"shell", map[string]interface{}{"command": "git status & rm -rf /"}, false},
+		{"shell", map[string]interface{}{"command": "go test ./... 2>&1"}, true},
+		{"shell", map[string]interface{}{"command": "go test ./... &> test.log"}, true},
+		{"shell", map[string]interface{}{"command": "git log $(rm -rf /)"}, false},
+		{"shell", map[string]interface{}{"command": "git log `rm -rf /`"}, false},
+		{"shell", map[string]interface{}{"command": "git diff <(rm -rf /)"}, false},
+		{"git_status", map[string]interface{}{}, true},
+		{"shell", map[string]interface{}{"command": "git status", "cwd": "/work/src"}, true},
+		{"shell", map[string]interface{}{"command": "git status", "cwd": "/work/config/.env"}, false},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.tool, tt.params)
+		assert.Equal(t, tt.allowed, err == nil, "%s %v: %v", tt.tool, tt.params, err)
+	}
+}
+
+func TestPolicyHomePatterns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	policy, err := New("/work", []string{"deny *:~/.ssh/**"})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, policy.Check("read_file", map[string]interface{}{"path": filepath.Join(home, ".ssh", "id_ed25519")}), "denied by rule")
+	assert.ErrorContains(t, policy.Check("read_file", map[string]interface{}{"path": "~/.ssh/config"}), "denied by rule")
+	assert.NoError(t, policy.Check("read_file", map[string]interface{}{"path": filepath.Join(home, "notes.md")}))
+
+	// Without rules for shell, command substitution is left alone
+	assert.NoError(t, policy.Check("git_status", map[string]interface{}{}))
+	unrestricted, _ := New("/work", []string{"edit_file:./src/**"})
+	assert.NoError(t, unrestricted.Check("shell", map[string]interface{}{"command": "echo $(date)"}))
+}
+
+func TestPolicyWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	notes := filepath.Join(dir, "notes")
+	outside := filepath.Join(dir, "outside")
+	for _, path := range []string{workspace, notes, outside} {
+		assert.NoError(t, os.Mkdir(path, 0755))
+	}
+	assert.NoError(t, os.Symlink(outside, filepath.Join(workspace, "escape")))
+
+	policy, err := New(workspace, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, policy.Check("edit_file", map[string]interface{}{"path": "/etc/hosts"}), "unconfined by default")
+
+	policy.ConfineTo([]string{".", notes})
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"main.go", true},
+		{filepath.Join(workspace, "new", "dir", "file.go"), true},
+		{filepath.Join(notes, "todo.md"), true},
+		{"/etc/hosts", false},
+		{"../outside/file.go", false},
+		{filepath.Join(workspace, "escape", "file.go"), false},
+		{filepath.Join(dir, "workspace-other", "file.go"), false},
+	}
+	for _, tt := range tests {
+		err := policy.Check("edit_file", map[string]interface{}{"path": tt.path})
+		assert.Equal(t, tt.allowed, err == nil, "%s: %v", tt.path, err)
+	}
+	assert.ErrorContains(t, policy.Check("read_file", map[string]interface{}{"path": filepath.Join(workspace, "escape")}), "resolves to")
+	assert.NoError(t, policy.Check("shell", map[string]interface{}{"command": "cat /etc/hosts"}), "shell commands are left to the sandbox")
+	assert.NoError(t, policy.Check("shell", map[string]interface{}{"command": "ls", "cwd": workspace}))
+	assert.ErrorContains(t, policy.Check("shell", map[string]interface{}{"command": "ls", "cwd": outside}), "outside the workspace")
+	assert.ErrorContains(t, policy.Check("shell", map[string]interface{}{"command": "ls", "cwd": "escape"}), "resolves to")
+
+	policy.ConfineTo(nil)
+	assert.NoError(t, policy.Check("edit_file", map[string]interface{}{"path": "/etc/hosts"}))
+}
+
+func TestPolicyAddRemove(t *testing.T) {
+	policy, err := New("/work", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, policy.Add("deny shell"))
+	assert.Error(t, policy.Check("shell", map[string]interface{}{"command": "ls"}))
+
+	rule, err := policy.Remove(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "deny shell", rule.String())
+	assert.NoError(t, policy.Check("shell", map[string]interface{}{"command": "ls"}))
+
+	_, err = policy.Remove(1)
+	assert.Error(t, err)
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		isPath  bool
+		matches []string
+		misses  []string
+	}{
+		{"**/*_test.go", true, []string{"a_test.go", "pkg/sub/b_test.go"}, []string{"a.go", "a_test.go.orig"}},
+		{"*.go", true, []string{"main.go"}, []string{"pkg/main.go"}},
+		{"src/**/*.{ts,tsx}", true, []string{"src/a.ts", "src/ui/b.tsx"}, []string{"src/a.js", "lib/a.ts"}},
+		{"file?.[!a-c]x", true, []string{"file1.dx"}, []string{"file1.ax", "file12.dx"}},
+		{"git *", false, []string{"git status", "git log -- a/b"}, []string{"gitk", "go test"}},
+		{"echo {a,b} [x]", false, []string{"echo {a,b} [x]"}, []string{"echo a [x]", "echo {a,b} x"}},
+	}
+	for _, tt := range tests {
+		matcher, err := GlobRegexp(tt.pattern, tt.isPath)
+		assert.NoError(t, err)
+		for _, path := range tt.matches {
+			assert.True(t, matcher.MatchString(path), "%s should match %s", tt.pattern, path)
+		}
+		for _, path := range tt.misses {
+			assert.False(t, matcher.MatchString(path), "%s shouldn't match %s", tt.pattern, path)
+		}
+	}
+
+	_, err := GlobRegexp("src/{a,b", true)
+	assert.Error(t, err)
+	_, err = GlobRegexp("src/[ab", true)
+	assert.Error(t, err)
+}
+
+func TestPolicyGlobs(t *testing.T) {
+	policy, err := New("/work/[draft]", []string{"deny *:**/.env", "edit_file:src/*.{go,mod}"})
+	assert.NoError(t, err)
+
+	assert.ErrorContains(t, policy.Check("read_file", map[string]interface{}{"path": "/work/[draft]/.env"}), "denied by rule", "**/ matches no directory too")
+	assert.ErrorContains(t, policy.Check("read_file", map[string]interface{}{"path": "/work/[draft]/config/.env"}), "denied by rule")
+	assert.NoError(t, policy.Check("edit_file", map[string]interface{}{"path": "src/main.go"}), "the root's name isn't read as a glob")
+	assert.NoError(t, policy.Check("edit_file", map[string]interface{}{"path": "src/go.mod"}))
+	assert.Error(t, policy.Check("edit_file", map[string]interface{}{"path": "src/main.js"}))
+
+	_, err = ParseRule("deny read_file:src/{a,b")
+	assert.ErrorContains(t, err, "unclosed { in pattern")
+	_, err = ParseRule("shell:echo {")
+	assert.NoError(t, err, "braces in commands are literal")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PersonaConfig is a role the agent can switch to with /persona, such as a code reviewer or a docs
+// author, with its own instructions, tools, and model
+type PersonaConfig struct {
+	Description  string         `json:"description"`  // shown by /persona
+	Instructions string         `json:"instructions"` // added to the system prompt while the persona is active
+	Tools        []string       `json:"tools"`        // the only tools it may use; empty allows all
+	Model        *SelectedModel `json:"model"`        // the model it uses; unset keeps the current one
+}
+
+// Persona returns the active persona's name and config, if one is active
+func (a *Agent) Persona() (string, PersonaConfig, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.persona == "" {
+		return "", PersonaConfig{}, false
+	}
+	persona, ok := a.config.Personas[a.persona]
+	return a.persona, persona, ok
+}
+
+// setPersona switches to the named persona, or back to no persona when name is empty. The model
+// selected before the first persona is restored when leaving it or switching to one without a model.
+func (a *Agent) setPersona(name string) error {
+	var persona PersonaConfig
+	if name != "" {
+		var ok bool
+		if persona, ok = a.config.Personas[name]; !ok {
+			return fmt.Errorf("no persona named %q", name)
+		}
+	}
+
+	a.mu.RLock()
+	previous := a.personaModel
+	a.mu.RUnlock()
+	if previous == nil && a.config.Model != nil {
+		selected := *a.config.Model
+		previous = &selected
+	}
+
+	switch {
+	case persona.Model != nil:
+		if err := a.switchProvider(persona.Model.Provider, persona.Model.Model); err != nil {
+			return fmt.Errorf("persona %s: %w", name, err)
+		}
+	case previous != nil:
+		if err := a.switchProvider(previous.Provider, previous.Model); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.persona = name
+	a.personaModel = previous
+	if name == "" {
+		a.personaModel = nil
+	}
+	return nil
+}
+
+// personaInstructions adds the active persona's instructions to the system prompt
+func (a *Agent) personaInstructions() string {
+	name, persona, ok := a.Persona()
+	if !ok || persona.Instructions == "" {
+		return ""
+	}
+	return fmt.Sprintf("# PERSONA: %s\n%s", strings.ToUpper(name), persona.Instructions)
+}
+
+// personaTools filters tools down to the persona's allowlist
+func personaTools(all map[string]models.ToolDefinition, allowed []string) map[string]models.ToolDefinition {
+	if len(allowed) == 0 {
+		return all
+	}
+	filtered := make(map[string]models.ToolDefinition)
+	for _, name := range allowed {
+		if tool, ok := all[name]; ok {
+			filtered[name] = tool
+		}
+	}
+	return filtered
+}
+
+func handlePersona(a *Agent, args []string) string {
+	if len(args) == 0 {
+		if len(a.config.Personas) == 0 {
+			return theme.InfoText("No personas configured. Add them under \"personas\" in the config, e.g. {\"reviewer\": {\"instructions\": \"Review the changes; don't edit files.\", \"tools\": [\"read_file\", \"git_diff\"]}}.")
+		}
+		active, _, _ := a.Persona()
+		names := make([]string, 0, len(a.config.Personas))
+		for name := range a.config.Personas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var result strings.Builder
+		result.WriteString(theme.InfoText("Personas (usage: /persona <name>|off):") + "\n")
+		for _, name := range names {
+			persona := a.config.Personas[name]
+			marker := "  "
+			if name == active {
+				marker = "▶ "
+			}
+			line := marker + name
+			if persona.Description != "" {
+				line += " - " + persona.Description
+			}
+			if persona.Model != nil {
+				line += fmt.Sprintf(" [%s:%s]", persona.Model.Provider, persona.Model.Model)
+			}
+			if len(persona.Tools) > 0 {
+				line += fmt.Sprintf(" (%d tools)", len(persona.Tools))
+			}
+			result.WriteString(line + "\n")
+		}
+		return strings.TrimRight(result.String(), "\n")
+	}
+	if len(args) != 1 {
+		return theme.ErrorText("Usage: /persona [<name>|off]")
+	}
+
+	if args[0] == "off" {
+		if err := a.setPersona(""); err != nil {
+			return theme.ErrorText(fmt.Sprintf("Left the persona, but the previous model is unavailable: %v", err))
+		}
+		return theme.InfoText(fmt.Sprintf("Persona off; all tools are available and the model is %s:%s", a.currentModel.Provider.ID, a.currentModel.ID))
+	}
+
+	if err := a.setPersona(args[0]); err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	_, persona, _ := a.Persona()
+	var result strings.Builder
+	result.WriteString(theme.SuccessText(fmt.Sprintf("Now acting as %s with %s:%s", args[0], a.currentModel.Provider.ID, a.currentModel.ID)))
+	if len(persona.Tools) > 0 {
+		var unknown []string
+		for _, name := range persona.Tools {
+			if _, ok := a.tools[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		result.WriteString("\n" + theme.InfoText("Tools: "+strings.Join(persona.Tools, ", ")))
+		if len(unknown) > 0 {
+			result.WriteString("\n" + theme.WarningText("Not available in this session: "+strings.Join(unknown, ", ")))
+		}
+	}
+	return result.String()
+}
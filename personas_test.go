@@ -0,0 +1,39 @@
+package main
+
+import (
+	"agent/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersona(t *testing.T) {
+	config := &Config{
+		Providers: []*models.Provider{{ID: "p", Models: []*models.Model{{ID: "fast"}, {ID: "smart"}}}},
+		Personas: map[string]PersonaConfig{
+			"reviewer": {Instructions: "Review the diff; don't edit files.", Tools: []string{"read_file", "git_diff"}, Model: &SelectedModel{Provider: "p", Model: "smart"}},
+			"writer":   {Instructions: "Write docs."},
+		},
+	}
+	agent := &Agent{LiveContext: NewLiveContext(), config: config}
+	defer agent.LiveContext.Close()
+	agent.tools = map[string]models.ToolDefinition{"read_file": {}, "git_diff": {}, "edit_file": {}}
+	assert.NoError(t, agent.switchProvider("p", "fast"))
+
+	assert.Contains(t, handlePersona(agent, []string{"reviewer"}), "Now acting as reviewer with p:smart")
+	assert.Len(t, agent.GetTools(), 2)
+	assert.NotContains(t, agent.GetTools(), "edit_file")
+	assert.Contains(t, agent.BuildSystemPrompt(), "# PERSONA: REVIEWER\nReview the diff; don't edit files.")
+
+	// A persona without a model goes back to the one selected before the first persona
+	assert.Contains(t, handlePersona(agent, []string{"writer"}), "Now acting as writer with p:fast")
+	assert.Len(t, agent.GetTools(), 3)
+	assert.NotContains(t, agent.BuildSystemPrompt(), "REVIEWER")
+
+	assert.NoError(t, agent.setPersona("reviewer"))
+	assert.Contains(t, handlePersona(agent, []string{"off"}), "the model is p:fast")
+	assert.NotContains(t, agent.BuildSystemPrompt(), "# PERSONA")
+
+	assert.Contains(t, handlePersona(agent, []string{"tester"}), `no persona named "tester"`)
+	assert.Contains(t, handlePersona(agent, nil), "reviewer [p:smart] (2 tools)")
+}
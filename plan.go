@@ -0,0 +1,112 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+// readOnlyTools can't modify the workspace, so they're available in plan mode and to sub-agents by default
+var readOnlyTools = []string{
+	"read_file",
+	"stop_reading_file",
+	"read_directory",
+	"expand_directory",
+	"stop_reading_directory",
+	"code_outline",
+	"glob",
+	"semantic_search",
+	"find_definition",
+	"find_references",
+	"get_diagnostics",
+	"lookup_docs",
+	"git_status",
+	"git_diff",
+	"git_log",
+	"remove_message",
+	"view_image",
+	"set_anchor",
+	"task_add",
+	"task_update",
+	"task_list",
+	"set_working_directory",
+	"read_artifact",
+	"list_artifacts",
+}
+
+const planModeInstructions = `# PLAN MODE
+You are in plan mode. Only read-only tools are available: explore the code but do not modify anything.
+Finish with a numbered plan the user can approve: each step names the files to change and what changes, followed by how the result will be verified. Keep steps small and concrete. The user will run /execute to carry out your latest plan with full tool access.`
+
+// InPlanMode reports whether the agent is restricted to read-only tools
+func (a *Agent) InPlanMode() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.planMode
+}
+
+func (a *Agent) setPlanMode(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.planMode = enabled
+}
+
+// planModeTools filters tools down to the read-only ones
+func planModeTools(all map[string]models.ToolDefinition) map[string]models.ToolDefinition {
+	filtered := make(map[string]models.ToolDefinition)
+	for _, name := range readOnlyTools {
+		if tool, ok := all[name]; ok {
+			filtered[name] = tool
+		}
+	}
+	return filtered
+}
+
+// latestResponse returns the agent's most recent reply without tool calls, which in plan mode is its plan
+func (a *Agent) latestResponse() string {
+	history := a.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role == "assistant" && msg.Status == "active" && len(msg.ToolCalls) == 0 && strings.TrimSpace(msg.Content) != "" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
+func handlePlan(a *Agent, args []string) string {
+	if len(args) == 1 && args[0] == "off" {
+		a.setPlanMode(false)
+		return theme.InfoText("Plan mode off; all tools are available")
+	}
+
+	a.setPlanMode(true)
+	if len(args) == 0 {
+		return theme.InfoText("Plan mode on: the agent can only read and will answer with a numbered plan. Run /execute to carry it out or /plan off to leave.")
+	}
+
+	fmt.Println(theme.InfoText("Plan mode on"))
+	a.ProcessMessage(strings.Join(args, " "))
+	return ""
+}
+
+func handleExecute(a *Agent, args []string) string {
+	if !a.InPlanMode() {
+		return theme.ErrorText("Not in plan mode. Use /plan to make a plan first.")
+	}
+	plan := a.latestResponse()
+	if plan == "" {
+		return theme.ErrorText("No plan yet. Describe the task so the agent can make one.")
+	}
+
+	a.setPlanMode(false)
+	fmt.Println(theme.InfoText("Plan approved; all tools are available"))
+
+	prompt := "The plan is approved. Carry it out step by step, verifying as described:\n\n" + plan
+	if len(args) > 0 {
+		prompt += "\n\nAdditional instructions: " + strings.Join(args, " ")
+	}
+	a.ProcessMessage(prompt)
+	return ""
+}
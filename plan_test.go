@@ -0,0 +1,63 @@
+package main
+
+import (
+	"agent/models"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanModeTools(t *testing.T) {
+	agentTools := make(map[string]models.ToolDefinition)
+	for _, name := range []string{"read_file", "git_status", "task_add", "write_file", "edit_file", "run_shell"} {
+		agentTools[name] = models.ToolDefinition{Name: name}
+	}
+	a := &Agent{LiveContext: NewLiveContext(), config: &Config{}, tools: agentTools}
+	defer a.LiveContext.Close()
+
+	a.setPlanMode(true)
+	var names []string
+	for name := range a.GetTools() {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{"read_file", "git_status", "task_add"}, names)
+	assert.Contains(t, a.BuildSystemPrompt(), "# PLAN MODE")
+
+	// A call to a tool that isn't read-only is refused, even if the model names it anyway
+	_, err := a.ExecuteToolCall(context.Background(), models.ToolCall{Function: models.FunctionCall{Name: "write_file", Arguments: "{}"}})
+	assert.ErrorContains(t, err, "tool 'write_file' not found")
+
+	a.setPlanMode(false)
+	assert.Len(t, a.GetTools(), len(agentTools))
+	assert.NotContains(t, a.BuildSystemPrompt(), "# PLAN MODE")
+}
+
+func TestPlanModeEntryAndExit(t *testing.T) {
+	a := &Agent{}
+
+	assert.Contains(t, handleExecute(a, nil), "Not in plan mode")
+	assert.Contains(t, handlePlan(a, nil), "Plan mode on")
+	assert.True(t, a.InPlanMode())
+
+	// /execute needs a plan to carry out, and stays in plan mode without one
+	assert.Contains(t, handleExecute(a, nil), "No plan yet")
+	assert.True(t, a.InPlanMode())
+
+	assert.Contains(t, handlePlan(a, []string{"off"}), "Plan mode off")
+	assert.False(t, a.InPlanMode())
+}
+
+func TestLatestResponse(t *testing.T) {
+	a := &Agent{Messages: []models.Message{
+		{Role: "user", Content: "plan the refactor", Status: "active"},
+		{Role: "assistant", Content: "1. Rename the type\n2. Run the tests", Status: "active"},
+		{Role: "user", Content: "also update the docs", Status: "active"},
+		{Role: "assistant", Content: "Let me look.", Status: "active", ToolCalls: []models.ToolCall{{ID: "1"}}},
+		{Role: "assistant", Content: "1. Rename", Status: "cancelled"},
+		{Role: "assistant", Content: "  ", Status: "active"},
+	}}
+	assert.Equal(t, "1. Rename the type\n2. Run the tests", a.latestResponse(), "tool calls, cancelled, and empty replies aren't plans")
+
+	assert.Empty(t, (&Agent{}).latestResponse())
+}
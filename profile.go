@@ -0,0 +1,295 @@
+package main
+
+import (
+	"agent/api"
+	"agent/theme"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A profile bundles the global config (without API keys), theme, and project file templates into a
+// zip archive so a team can share one agent setup. Tool policies (permissions, sandbox, and security
+// settings) travel with the config.
+const (
+	profileManifest  = "profile.json"
+	profileConfig    = "config.json"
+	profileTheme     = "theme.json"
+	profileTemplates = "templates/"
+	profileVersion   = 1
+)
+
+// ProfileManifest describes a profile archive
+type ProfileManifest struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Contents []string  `json:"contents"`
+}
+
+// runProfile implements `agent profile export|import`
+func runProfile(args []string) error {
+	usage := "Usage: agent profile export <profile.zip> | agent profile import <profile.zip>"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+	flags := flag.NewFlagSet("profile "+args[0], flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one profile archive")
+	}
+
+	var written []string
+	var err error
+	switch args[0] {
+	case "export":
+		written, err = exportProfile(flags.Arg(0))
+	case "import":
+		written, err = importProfile(flags.Arg(0))
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown profile command %q", args[0])
+	}
+	for _, line := range written {
+		fmt.Println(theme.InfoText(line))
+	}
+	return err
+}
+
+// profileHome returns ~/.agent, where the global config and theme live
+func profileHome() (string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(configPath), nil
+}
+
+// exportProfile writes the profile archive to archivePath and describes what it contains
+func exportProfile(archivePath string) ([]string, error) {
+	home, err := profileHome()
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[string][]byte)
+
+	global, err := os.ReadFile(filepath.Join(home, configFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config: %w", err)
+	}
+	var config interface{}
+	if err := json.Unmarshal(global, &config); err != nil {
+		return nil, fmt.Errorf("%s is not valid JSON; fix it before exporting: %w", configFileName, err)
+	}
+	removed := stripAPIKeys(config)
+	if contents[profileConfig], err = json.MarshalIndent(config, "", "  "); err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(filepath.Join(home, profileTheme)); err == nil {
+		contents[profileTheme] = data
+	}
+	templates, _ := os.ReadDir(filepath.Join(".agent", "templates"))
+	for _, template := range templates {
+		if template.IsDir() {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(".agent", "templates", template.Name())); err == nil {
+			contents[profileTemplates+template.Name()] = data
+		}
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	manifest, err := json.MarshalIndent(ProfileManifest{Version: profileVersion, Created: time.Now(), Contents: names}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for _, name := range append([]string{profileManifest}, names...) {
+		data := contents[name]
+		if name == profileManifest {
+			data = manifest
+		}
+		file, err := writer.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(archivePath, archive.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	summary := []string{fmt.Sprintf("Exported %s:", archivePath)}
+	for _, name := range names {
+		summary = append(summary, "  "+name)
+	}
+	if removed > 0 {
+		summary = append(summary, fmt.Sprintf("Left out %d API keys, encryption keys, and tracing headers written into the config; env:, keychain:, and cmd: references are kept", removed))
+	}
+	return summary, nil
+}
+
+// secretConfigKeys name the config values that are credentials unless they reference one kept
+// elsewhere: provider API keys and the session encryption key
+var secretConfigKeys = map[string]bool{"api_key": true, "encryption_key": true}
+
+// stripAPIKeys removes the credentials written into a decoded config, keeping references to keys
+// kept elsewhere, and returns how many were removed. Tracing headers are removed whole, since they
+// usually carry the backend's API key.
+func stripAPIKeys(node interface{}) int {
+	removed := 0
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if secret, ok := value.(string); ok && secretConfigKeys[key] && secret != "" && !api.IsKeyReference(secret) {
+				delete(node, key)
+				removed++
+				continue
+			}
+			if headers, ok := value.(map[string]interface{}); ok && key == "headers" {
+				delete(node, key)
+				removed += len(headers)
+				continue
+			}
+			removed += stripAPIKeys(value)
+		}
+	case []interface{}:
+		for _, item := range node {
+			removed += stripAPIKeys(item)
+		}
+	}
+	return removed
+}
+
+// importProfile applies a profile archive. The profile's config is merged over the global config
+// like a project config, so API keys and providers the profile doesn't mention are kept. Files that
+// are replaced are backed up with a .bak suffix.
+func importProfile(archivePath string) ([]string, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile: %w", err)
+	}
+	defer reader.Close()
+
+	contents := make(map[string][]byte)
+	for _, file := range reader.File {
+		name := file.Name
+		if name != profileManifest && name != profileConfig && name != profileTheme &&
+			!(strings.HasPrefix(name, profileTemplates) && path.Base(name) == strings.TrimPrefix(name, profileTemplates) && !strings.HasPrefix(path.Base(name), ".")) {
+			return nil, fmt.Errorf("unexpected file %q in profile", name)
+		}
+		opened, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(opened)
+		opened.Close()
+		if err != nil {
+			return nil, err
+		}
+		contents[name] = data
+	}
+
+	var manifest ProfileManifest
+	if err := json.Unmarshal(contents[profileManifest], &manifest); err != nil {
+		return nil, fmt.Errorf("%s is not an agent profile: %w", archivePath, err)
+	}
+	if manifest.Version > profileVersion {
+		return nil, fmt.Errorf("the profile is version %d; this agent reads up to version %d", manifest.Version, profileVersion)
+	}
+
+	home, err := profileHome()
+	if err != nil {
+		return nil, err
+	}
+	var summary []string
+	if data, ok := contents[profileConfig]; ok {
+		global, err := loadGlobalConfig()
+		if err != nil {
+			return nil, err
+		}
+		merged, _, err := mergeConfig(global, data, true) // importing a profile is the user's choice
+		if err != nil {
+			return nil, fmt.Errorf("the profile's config is not valid: %w", err)
+		}
+		config, problems, err := ParseConfig(merged)
+		if err != nil {
+			return nil, fmt.Errorf("the profile's config is not valid: %w", err)
+		}
+		formatted, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := replaceProfileFile(filepath.Join(home, configFileName), formatted); err != nil {
+			return nil, err
+		}
+		summary = append(summary, "Merged the profile's config into "+filepath.Join(home, configFileName))
+		for _, problem := range problems {
+			summary = append(summary, "  ⚠ "+problem.String())
+		}
+	}
+	if data, ok := contents[profileTheme]; ok {
+		if err := replaceProfileFile(filepath.Join(home, profileTheme), data); err != nil {
+			return nil, err
+		}
+		summary = append(summary, "Installed the theme in "+filepath.Join(home, profileTheme))
+	}
+
+	var templates []string
+	for name := range contents {
+		if strings.HasPrefix(name, profileTemplates) {
+			templates = append(templates, strings.TrimPrefix(name, profileTemplates))
+		}
+	}
+	sort.Strings(templates)
+	for _, name := range templates {
+		target := filepath.Join(".agent", "templates", name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := replaceProfileFile(target, contents[profileTemplates+name]); err != nil {
+			return nil, err
+		}
+		summary = append(summary, "Installed the file template "+target)
+	}
+	return summary, nil
+}
+
+// replaceProfileFile writes data to target, backing up a different existing file to target.bak
+func replaceProfileFile(target string, data []byte) error {
+	if existing, err := os.ReadFile(target); err == nil {
+		if bytes.Equal(existing, data) {
+			return nil
+		}
+		if err := os.WriteFile(target+".bak", existing, 0644); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", target, err)
+		}
+	}
+	return os.WriteFile(target, data, 0644)
+}
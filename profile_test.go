@@ -0,0 +1,95 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileExportImport(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OPENROUTER_API_KEY", "test")
+	workspace := t.TempDir()
+	wd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(workspace))
+	defer os.Chdir(wd)
+
+	config := createDefaultConfig()
+	config.Providers[0].APIKey = "sk-secret-key"
+	config.Permissions = []string{"deny shell:rm *"}
+	config.Sessions.EncryptionKey = "correct horse battery staple"
+	config.Tracing.Headers = map[string]string{"x-honeycomb-team": "hc-secret"}
+	require.NoError(t, SaveConfig(config))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".agent", "theme.json"), []byte(`{"preset": "light"}`), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(".agent", "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(".agent", "templates", "go.tmpl"), []byte("// Copyright {{.Year}}\n"), 0644))
+
+	archive := filepath.Join(t.TempDir(), "team.zip")
+	summary, err := exportProfile(archive)
+	require.NoError(t, err)
+	assert.Contains(t, summary, "  templates/go.tmpl")
+	assert.Contains(t, summary[len(summary)-1], "Left out 3 API keys, encryption keys, and tracing headers")
+
+	reader, err := zip.OpenReader(archive)
+	require.NoError(t, err)
+	for _, file := range reader.File {
+		if file.Name == profileConfig {
+			opened, _ := file.Open()
+			var exported Config
+			require.NoError(t, json.NewDecoder(opened).Decode(&exported))
+			opened.Close()
+			assert.Empty(t, exported.Providers[0].APIKey)
+			assert.Equal(t, "env:OPENROUTER_API_KEY", exported.Providers[1].APIKey, "key references are kept")
+			assert.Empty(t, exported.Sessions.EncryptionKey)
+			assert.Empty(t, exported.Tracing.Headers)
+		}
+	}
+	reader.Close()
+
+	// A teammate with their own key and settings imports the profile
+	require.NoError(t, os.RemoveAll(filepath.Join(home, ".agent")))
+	require.NoError(t, os.RemoveAll(".agent"))
+	teammate := createDefaultConfig()
+	teammate.Providers[0].APIKey = "sk-teammate-key"
+	teammate.Permissions = []string{"shell:git *"}
+	teammate.MaxIterations = 3
+	require.NoError(t, SaveConfig(teammate))
+
+	_, err = importProfile(archive)
+	require.NoError(t, err)
+	imported, warnings := LoadConfig()
+	assert.Empty(t, warnings)
+	assert.Equal(t, "sk-teammate-key", imported.Providers[0].APIKey)
+	assert.Equal(t, []string{"shell:git *", "deny shell:rm *"}, imported.Permissions)
+	assert.Equal(t, config.MaxIterations, imported.MaxIterations)
+	assert.FileExists(t, filepath.Join(home, ".agent", "config.json.bak"))
+	assert.FileExists(t, filepath.Join(home, ".agent", "theme.json"))
+	assert.FileExists(t, filepath.Join(".agent", "templates", "go.tmpl"))
+
+	// Importing again doesn't duplicate permissions
+	_, err = importProfile(archive)
+	require.NoError(t, err)
+	imported, _ = LoadConfig()
+	assert.Equal(t, []string{"shell:git *", "deny shell:rm *"}, imported.Permissions)
+}
+
+func TestImportProfileRejectsUnexpectedFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	archive := filepath.Join(t.TempDir(), "bad.zip")
+	file, err := os.Create(archive)
+	require.NoError(t, err)
+	writer := zip.NewWriter(file)
+	entry, _ := writer.Create("templates/../../escape.tmpl")
+	entry.Write([]byte("x"))
+	require.NoError(t, writer.Close())
+	file.Close()
+
+	_, err = importProfile(archive)
+	assert.ErrorContains(t, err, "unexpected file")
+}
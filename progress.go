@@ -0,0 +1,97 @@
+package main
+
+import (
+	"agent/tools"
+	"time"
+)
+
+// ProgressEvent describes the state of a running turn
+type ProgressEvent struct {
+	Turn      int           `json:"turn"`
+	Iteration int           `json:"iteration"`
+	Tool      string        `json:"tool,omitempty"`   // tool currently executing, empty while waiting on the model
+	Status    string        `json:"status,omitempty"` // the latest status the running tool reported
+	Elapsed   time.Duration `json:"elapsed"`
+	Update    bool          `json:"update,omitempty"` // sent because the tool reported a status, not a heartbeat
+}
+
+// OnProgress registers a listener that receives heartbeat events while a turn is running, and status
+// updates from running tools
+func (a *Agent) OnProgress(listener func(ProgressEvent)) {
+	a.progressMu.Lock()
+	defer a.progressMu.Unlock()
+	a.progressListeners = append(a.progressListeners, listener)
+}
+
+// setProgress records the current iteration and tool of the running turn
+func (a *Agent) setProgress(iteration int, tool string) {
+	a.progressMu.Lock()
+	defer a.progressMu.Unlock()
+	a.progress.Iteration = iteration
+	a.progress.Tool = tool
+	a.progress.Status = ""
+}
+
+// toolProgress returns the function the running tool reports its status to. Statuses go to listeners
+// as they come, at most once a second so chatty tools don't flood the terminal; the latest one is
+// also included in heartbeats.
+func (a *Agent) toolProgress() tools.ProgressFunc {
+	var last time.Time
+	return func(status string) {
+		a.progressMu.Lock()
+		a.progress.Status = status
+		if time.Since(last) < time.Second {
+			a.progressMu.Unlock()
+			return
+		}
+		last = time.Now()
+		event := a.progress
+		event.Elapsed = time.Since(a.progressStart).Round(time.Second)
+		event.Update = true
+		listeners := append([]func(ProgressEvent){}, a.progressListeners...)
+		a.progressMu.Unlock()
+
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// startHeartbeat emits progress events periodically until the returned stop function is called
+func (a *Agent) startHeartbeat() func() {
+	interval := time.Duration(a.config.HeartbeatSeconds) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	start := time.Now()
+	a.progressMu.Lock()
+	a.progress = ProgressEvent{Turn: a.turn}
+	a.progressStart = start
+	a.progressMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.progressMu.Lock()
+				event := a.progress
+				event.Elapsed = time.Since(start).Round(time.Second)
+				listeners := append([]func(ProgressEvent){}, a.progressListeners...)
+				a.progressMu.Unlock()
+
+				for _, listener := range listeners {
+					listener(event)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
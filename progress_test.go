@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	a := &Agent{config: &Config{HeartbeatSeconds: 1}, turn: 2}
+	events := make(chan ProgressEvent, 10)
+	a.OnProgress(func(event ProgressEvent) { events <- event })
+
+	stop := a.startHeartbeat()
+	a.setProgress(3, "run_shell")
+
+	select {
+	case event := <-events:
+		assert.Equal(t, 2, event.Turn)
+		assert.Equal(t, 3, event.Iteration)
+		assert.Equal(t, "run_shell", event.Tool)
+		assert.False(t, event.Update)
+		assert.GreaterOrEqual(t, event.Elapsed, time.Second)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no heartbeat")
+	}
+
+	stop()
+	// A tick may have raced the stop; nothing comes after it
+	time.Sleep(50 * time.Millisecond)
+	for len(events) > 0 {
+		<-events
+	}
+	assert.Never(t, func() bool { return len(events) > 0 }, 1500*time.Millisecond, 50*time.Millisecond, "no heartbeats after the turn ends")
+}
+
+func TestToolProgress(t *testing.T) {
+	a := &Agent{config: &Config{}, turn: 1}
+	var mu sync.Mutex
+	var events []ProgressEvent
+	a.OnProgress(func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	a.setProgress(1, "run_shell")
+
+	report := a.toolProgress()
+	report("compiling")
+	report("testing")
+
+	// Statuses are sent at most once a second, but heartbeats carry the latest one
+	mu.Lock()
+	require.Len(t, events, 1)
+	assert.Equal(t, "compiling", events[0].Status)
+	assert.Equal(t, "run_shell", events[0].Tool)
+	assert.True(t, events[0].Update)
+	mu.Unlock()
+	assert.Equal(t, "testing", a.progress.Status)
+
+	// The next tool starts without the last one's status
+	a.setProgress(2, "read_file")
+	assert.Empty(t, a.progress.Status)
+}
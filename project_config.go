@@ -0,0 +1,260 @@
+package main
+
+import (
+	"agent/theme"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// projectConfigPath is the per-project config, relative to the working directory. It overlays the
+// global config: objects are merged field by field, lists of objects with an "id" (providers and
+// their models) are merged by id, and the lists below are appended to. Other values replace the
+// global ones. Until the user runs /trust, only untrustedConfigFields are applied, since a cloned
+// repository could otherwise run commands or send API keys elsewhere.
+var projectConfigPath = filepath.Join(".agent", configFileName)
+
+// appendedConfigFields are the lists a project config adds its new elements to instead of replacing
+var appendedConfigFields = map[string]bool{
+	"permissions":     true,
+	"ignore_patterns": true,
+	"hooks":           true,
+}
+
+// applyProjectConfig returns global with the project config in the working directory merged over
+// it, and warnings for problems the project config introduces. global is returned unchanged when
+// there is no project config or it can't be used.
+func applyProjectConfig(global *Config) (*Config, []string) {
+	data, err := os.ReadFile(projectConfigPath)
+	if os.IsNotExist(err) {
+		return global, nil
+	}
+	if err != nil {
+		return global, []string{fmt.Sprintf("Warning: failed to read %s: %v", projectConfigPath, err)}
+	}
+
+	merged, dropped, err := mergeConfig(global, data, projectTrusted(data))
+	if err != nil {
+		return global, []string{fmt.Sprintf("Warning: %s is not valid (%v); using the global config until it is fixed", projectConfigPath, err)}
+	}
+	var warnings []string
+	if len(dropped) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Warning: %s is not trusted, so its %s are ignored; read it and run /trust to apply them", projectConfigPath, strings.Join(dropped, ", ")))
+	}
+	config, problems, err := ParseConfig(merged)
+	if err != nil {
+		return global, []string{fmt.Sprintf("Warning: %s is not valid (%v); using the global config until it is fixed", projectConfigPath, err)}
+	}
+
+	// Problems the global config already has were reported for it
+	existing := make(map[string]bool)
+	if globalData, err := json.Marshal(global); err == nil {
+		if _, before, err := ParseConfig(globalData); err == nil {
+			for _, problem := range before {
+				existing[problem.String()] = true
+			}
+		}
+	}
+	for _, problem := range problems {
+		if !existing[problem.String()] {
+			warnings = append(warnings, fmt.Sprintf("Warning: %s: %s", projectConfigPath, problem))
+		}
+	}
+	return config, warnings
+}
+
+// mergeConfig overlays the project config JSON on global, returning the merged JSON and, for an
+// untrusted project, what was left out
+func mergeConfig(global *Config, project []byte, trusted bool) ([]byte, []string, error) {
+	var overlay interface{}
+	if err := json.Unmarshal(project, &overlay); err != nil {
+		_, _, err := ParseConfig(project)
+		return nil, nil, err
+	}
+	fields, ok := overlay.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected an object")
+	}
+	var dropped []string
+	if !trusted {
+		dropped = restrictUntrusted(fields, global)
+	}
+	globalData, err := json.Marshal(global)
+	if err != nil {
+		return nil, nil, err
+	}
+	var base interface{}
+	if err := json.Unmarshal(globalData, &base); err != nil {
+		return nil, nil, err
+	}
+	merged, err := json.Marshal(mergeJSON(base, overlay, ""))
+	return merged, dropped, err
+}
+
+// mergeJSON overlays one decoded JSON value on another
+func mergeJSON(base, overlay interface{}, key string) interface{} {
+	switch overlay := overlay.(type) {
+	case map[string]interface{}:
+		baseObject, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay
+		}
+		merged := make(map[string]interface{}, len(baseObject))
+		for field, value := range baseObject {
+			merged[field] = value
+		}
+		for field, value := range overlay {
+			merged[field] = mergeJSON(baseObject[field], value, field)
+		}
+		return merged
+	case []interface{}:
+		baseList, ok := base.([]interface{})
+		if !ok {
+			return overlay
+		}
+		if appendedConfigFields[key] {
+			merged := append([]interface{}(nil), baseList...)
+			for _, element := range overlay {
+				if !slices.ContainsFunc(merged, func(existing interface{}) bool { return reflect.DeepEqual(existing, element) }) {
+					merged = append(merged, element)
+				}
+			}
+			return merged
+		}
+		if hasIDs(baseList) && hasIDs(overlay) {
+			return mergeByID(baseList, overlay)
+		}
+		return overlay
+	default:
+		return overlay
+	}
+}
+
+// mergeByID merges list elements that share an id and appends the rest
+func mergeByID(base, overlay []interface{}) []interface{} {
+	merged := append([]interface{}(nil), base...)
+	for _, element := range overlay {
+		id := element.(map[string]interface{})["id"]
+		found := false
+		for i, existing := range merged {
+			if existing.(map[string]interface{})["id"] == id {
+				merged[i] = mergeJSON(existing, element, "")
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, element)
+		}
+	}
+	return merged
+}
+
+func hasIDs(list []interface{}) bool {
+	for _, element := range list {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := object["id"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// loadEffectiveConfig loads the global config with the project config merged over it, along with
+// warnings about either
+func loadEffectiveConfig() (*Config, []string) {
+	global, warnings := LoadConfig()
+	config, projectWarnings := applyProjectConfig(global)
+	return config, append(warnings, projectWarnings...)
+}
+
+// loadGlobalConfig reads the global config to change part of it and save it. Unlike LoadConfig, it
+// fails when the file isn't valid JSON rather than returning defaults that would overwrite it.
+func loadGlobalConfig() (*Config, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return createDefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid (%v); fix it first", configPath, err)
+	}
+	return config, nil
+}
+
+// saveSelectedModel records the model in the global config, leaving the rest of the file as it is
+// rather than writing project settings or resolved API keys into it
+func saveSelectedModel(providerId, modelId string) error {
+	global, err := loadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	global.Model = &SelectedModel{Provider: providerId, Model: modelId}
+	return SaveConfig(global)
+}
+
+// applyConfig applies the settings that can change during a session, returning warnings about
+// values it ignored. Language servers, the semantic index, formatters, and the sandbox are set up once
+// at startup.
+func (a *Agent) applyConfig() []string {
+	var warnings []string
+	workDir, _ := os.Getwd()
+	theme.ConfigureHighlighting(a.config.Highlight.Disabled, a.config.Highlight.Style)
+	_, liveContextBudget, _ := a.config.Budget.Limits()
+	a.LiveContext.SetMaxSize(liveContextBudget)
+	a.LiveContext.SetInjectionScan(!a.config.Security.DisableInjectionScan)
+	a.LiveContext.SetIgnorePatterns(a.config.IgnorePatterns)
+	a.LiveContext.SetDefaultIgnores(a.config.DefaultIgnores)
+	a.LiveContext.SetIncludeHidden(a.config.IncludeHidden == nil || *a.config.IncludeHidden)
+	a.LiveContext.SetMaxFileSize(a.config.MaxFileSize)
+	if placement := a.config.LiveContextPlacement; placement != "" {
+		if err := a.setContextPlacement(placement); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Warning: ignoring live_context_placement: %v", err))
+		}
+	}
+	a.permissions = newPermissionPolicy(workDir, a.config.Permissions, a.config.Security)
+	a.hooks = newHookRunner(workDir, a.config.Hooks)
+	a.installRedaction()
+	warnings = append(warnings, a.configureSessions()...)
+	a.installContentFilters()
+	template, templateFiles, templateWarnings := loadPromptTemplate(promptTemplatePaths())
+	a.promptTemplate, a.promptTemplateFiles = template, templateFiles
+	warnings = append(warnings, templateWarnings...)
+	return warnings
+}
+
+func handleReload(a *Agent, args []string) string {
+	config, warnings := loadEffectiveConfig()
+	*a.config = *config
+	warnings = append(warnings, a.applyConfig()...)
+
+	var result strings.Builder
+	for _, warning := range warnings {
+		result.WriteString(theme.WarningText(warning) + "\n")
+	}
+	if config.Model != nil {
+		if err := a.switchProvider(config.Model.Provider, config.Model.Model); err != nil {
+			result.WriteString(theme.ErrorText(fmt.Sprintf("The selected model is unavailable: %v", err)) + "\n")
+		}
+	}
+	source := "the global config"
+	if _, err := os.Stat(projectConfigPath); err == nil {
+		source += " and " + projectConfigPath
+	}
+	result.WriteString(theme.SuccessText("Reloaded " + source + ". Language servers, the semantic index, formatters, and the sandbox apply from the next session; rules added with /permissions were replaced by the config's."))
+	return result.String()
+}
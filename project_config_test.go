@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	t.Setenv("HOME", t.TempDir())
+
+	t.Setenv("OPENAI_API_KEY", "test")
+	global := createDefaultConfig()
+	global.Permissions = []string{"deny shell:rm *"}
+
+	config, warnings := applyProjectConfig(global)
+	assert.Same(t, global, config)
+	assert.Empty(t, warnings)
+
+	require.NoError(t, os.MkdirAll(".agent", 0755))
+	project := `{
+		"model": {"provider": "openai", "model": "gpt-4o-mini"},
+		"permissions": ["edit_file:./src/**", "*:/**"],
+		"ignore_patterns": ["dist"],
+		"budget": {"total_chars": 100000},
+		"providers": [{"id": "openai", "models": [{"id": "gpt-4o", "config": {"temperature": 0.2}}]}],
+		"max_iteratons": 3
+	}`
+	require.NoError(t, os.WriteFile(filepath.Join(".agent", "config.json"), []byte(project), 0644))
+
+	// Until it's trusted, only fields that can't run commands or loosen anything apply
+	config, warnings = applyProjectConfig(global)
+	assert.Equal(t, "gpt-4o-mini", config.Model.Model)
+	assert.Equal(t, []string{"deny shell:rm *", "edit_file:./src/**"}, config.Permissions)
+	assert.Equal(t, []string{"dist"}, config.IgnorePatterns)
+	assert.Equal(t, global.Budget.TotalChars, config.Budget.TotalChars)
+	assert.Equal(t, 0.7, config.Providers[0].Models[0].Config.Temperature)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "is not trusted, so its 1 permission rules that allow more, budget, max_iteratons, providers are ignored")
+
+	require.NoError(t, trustProject([]byte(project)))
+	config, warnings = applyProjectConfig(global)
+	assert.Equal(t, "gpt-4o-mini", config.Model.Model)
+	assert.Equal(t, []string{"deny shell:rm *", "edit_file:./src/**", "*:/**"}, config.Permissions)
+	assert.Equal(t, []string{"dist"}, config.IgnorePatterns)
+	assert.Equal(t, 100000, config.Budget.TotalChars)
+	assert.Equal(t, global.Budget.History, config.Budget.History, "fields the project doesn't set are kept")
+	assert.Len(t, config.Providers, len(global.Providers), "providers are merged by id")
+	assert.Equal(t, "https://api.openai.com/v1", config.Providers[0].BaseURL)
+	assert.Len(t, config.Providers[0].Models, len(global.Providers[0].Models))
+	assert.Equal(t, 0.2, config.Providers[0].Models[0].Config.Temperature)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "max_iteratons")
+	assert.Equal(t, []string{"deny shell:rm *"}, global.Permissions, "the global config is unchanged")
+
+	require.NoError(t, os.WriteFile(filepath.Join(".agent", "config.json"), []byte(`{"model": `), 0644))
+	config, warnings = applyProjectConfig(global)
+	assert.Same(t, global, config)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "line 1")
+}
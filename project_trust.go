@@ -0,0 +1,155 @@
+package main
+
+import (
+	"agent/permissions"
+	"agent/theme"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// trustedProjectsFile records the project configs the user trusted with /trust, by directory and the
+// hash of the config they saw
+const trustedProjectsFile = "trusted_projects.json"
+
+// untrustedConfigFields are the project config fields applied before the project is trusted: they
+// can't run commands, reach other hosts, or loosen the sandbox, security, or permissions
+var untrustedConfigFields = map[string]bool{
+	"model":           true,
+	"ignore_patterns": true,
+	"permissions":     true, // only rules that narrow what's allowed, see narrowingRules
+}
+
+func trustedProjectsPath() (string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), trustedProjectsFile), nil
+}
+
+// projectConfigHash identifies the contents of a project config, so editing it asks for trust again
+func projectConfigHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadTrustedProjects() map[string]string {
+	trusted := make(map[string]string)
+	path, err := trustedProjectsPath()
+	if err != nil {
+		return trusted
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &trusted)
+	}
+	return trusted
+}
+
+// projectTrusted reports whether the user trusted this project config in the working directory
+func projectTrusted(data []byte) bool {
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		return false
+	}
+	return loadTrustedProjects()[dir] == projectConfigHash(data)
+}
+
+// trustProject records the project config in the working directory as trusted
+func trustProject(data []byte) error {
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+	path, err := trustedProjectsPath()
+	if err != nil {
+		return err
+	}
+	trusted := loadTrustedProjects()
+	trusted[dir] = projectConfigHash(data)
+	out, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0600)
+}
+
+// restrictUntrusted drops the fields of an untrusted project config that aren't in
+// untrustedConfigFields, and the permission rules that would allow more than global does. It
+// returns the names of what it dropped.
+func restrictUntrusted(overlay map[string]interface{}, global *Config) []string {
+	var dropped []string
+	for field, value := range overlay {
+		if !untrustedConfigFields[field] {
+			dropped = append(dropped, field)
+			delete(overlay, field)
+			continue
+		}
+		if field != "permissions" {
+			continue
+		}
+		rules, ok := value.([]interface{})
+		if !ok {
+			continue // ParseConfig reports the type
+		}
+		kept, removed := narrowingRules(rules, global.Permissions)
+		overlay[field] = kept
+		if removed > 0 {
+			dropped = append(dropped, fmt.Sprintf("%d permission rules that allow more", removed))
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// narrowingRules keeps deny rules, and allow rules for tools global has no allow rules for, since
+// those restrict a tool that was unrestricted. It returns the kept rules and how many it dropped.
+func narrowingRules(rules []interface{}, global []string) ([]interface{}, int) {
+	allowed := make(map[string]bool)
+	for _, text := range global {
+		if rule, err := permissions.ParseRule(text); err == nil && !rule.Deny {
+			allowed[rule.Tool] = true
+		}
+	}
+	var kept []interface{}
+	dropped := 0
+	for _, value := range rules {
+		text, ok := value.(string)
+		if !ok {
+			kept = append(kept, value)
+			continue
+		}
+		rule, err := permissions.ParseRule(text)
+		if err == nil && !rule.Deny && (rule.Tool == "*" || allowed[rule.Tool] || allowed["*"]) {
+			dropped++
+			continue
+		}
+		kept = append(kept, value)
+	}
+	return kept, dropped
+}
+
+func handleTrust(a *Agent, args []string) string {
+	data, err := os.ReadFile(projectConfigPath)
+	if os.IsNotExist(err) {
+		return theme.InfoText(fmt.Sprintf("There is no %s to trust in this directory", projectConfigPath))
+	}
+	if err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	if projectTrusted(data) {
+		return theme.InfoText(fmt.Sprintf("%s is already trusted", projectConfigPath))
+	}
+	if len(args) == 0 || args[0] != "yes" {
+		return theme.WarningText(fmt.Sprintf("%s can run commands (hooks, language servers, cmd: keys), send your API keys to other hosts (providers), and loosen the sandbox, security, and permissions. Read it, then run /trust yes to apply all of it. Editing it asks again.", projectConfigPath))
+	}
+	if err := trustProject(data); err != nil {
+		return theme.ErrorText(fmt.Sprintf("Failed to record trust: %v", err))
+	}
+	return strings.TrimSpace(theme.SuccessText("Trusted "+projectConfigPath) + "\n" + handleReload(a, nil))
+}
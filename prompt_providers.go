@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// maxGitStatusLines caps the uncommitted changes the git section lists
+const maxGitStatusLines = 20
+
+// promptProvider fills one placeholder of the system prompt template. Add a provider here, and its
+// placeholder to system_prompt_template.md, to give the model a new dynamic section.
+type promptProvider struct {
+	Name        string // the section's name in /why and in prompt_sections
+	Variable    string // the placeholder it replaces
+	Description string // shown by /prompt
+	Static      bool   // the value doesn't change during a session, so it's part of the cached instructions
+	Render      func(a *Agent) string
+}
+
+// promptProviders are the sections of the system prompt besides the instructions and live context,
+// in the order /why lists them. Each can be turned off in the prompt_sections config.
+var promptProviders = []promptProvider{
+	{Name: "os", Variable: "{ENV_OS}", Description: "the operating system", Static: true, Render: func(a *Agent) string {
+		return runtime.GOOS
+	}},
+	{Name: "cwd", Variable: "{ENV_CWD}", Description: "the directory the agent was started in", Static: true, Render: func(a *Agent) string {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "unknown"
+		}
+		return cwd
+	}},
+	{Name: "anchors", Variable: "{ANCHORS}", Description: "named code locations", Render: func(a *Agent) string {
+		if summary := a.anchors.Summary(); summary != "" {
+			return "Named anchors (code locations by name; go straight to them instead of searching again):\n" + summary + "\n"
+		}
+		return ""
+	}},
+	{Name: "context usage", Variable: "{CONTEXT_USAGE}", Description: "how much of the live context budget is used", Render: func(a *Agent) string {
+		currentSize, maxSize, usagePercent := a.LiveContext.GetContextUsage()
+		return fmt.Sprintf("Context Usage: %d/%d bytes (%.1f%%)", currentSize, maxSize, usagePercent)
+	}},
+	{Name: "date", Variable: "{DATE}", Description: "the current date and time", Render: func(a *Agent) string {
+		return "Current time: " + time.Now().Format("Monday, 2006-01-02 15:04 MST")
+	}},
+	{Name: "working directory", Variable: "{WORKING_DIRECTORY}", Description: "the shell's working directory, when it isn't the start directory", Render: func(a *Agent) string {
+		if dir := a.workDir.Relative(); dir != "" {
+			return fmt.Sprintf("Working directory: %s (shell commands run here and relative paths resolve against it; change it with set_working_directory)\n", dir)
+		}
+		return ""
+	}},
+	{Name: "git", Variable: "{GIT_STATUS}", Description: "the git branch and uncommitted changes", Render: func(a *Agent) string {
+		return gitStatusSection()
+	}},
+	{Name: "task board", Variable: "{TASK_BOARD}", Description: "the model's task board", Render: func(a *Agent) string {
+		if summary := a.board.Summary(); summary != "" {
+			return "Task board (mark steps with task_update as you go):\n" + summary
+		}
+		return ""
+	}},
+	{Name: "running jobs", Variable: "{RUNNING_JOBS}", Description: "background miniagents that are running", Render: func(a *Agent) string {
+		if a.miniagents == nil {
+			return ""
+		}
+		if running := a.miniagents.Running(); len(running) > 0 {
+			return fmt.Sprintf("Running in the background: %s\n", strings.Join(running, ", "))
+		}
+		return ""
+	}},
+	{Name: "changed files", Variable: "{CHANGED_FILES}", Description: "files changed outside the agent since the last turn", Render: func(a *Agent) string {
+		if len(a.changedFiles) > 0 {
+			return fmt.Sprintf("Files changed since last turn (re-examine them before relying on earlier conclusions): %s\n", strings.Join(a.changedFiles, ", "))
+		}
+		return ""
+	}},
+	{Name: "shell history", Variable: "{SHELL_HISTORY}", Description: "the last shell commands and their exit codes", Render: func(a *Agent) string {
+		if summary := a.shellHistory.Summary(); summary != "" {
+			return "Recent shell commands (oldest first; run them again if you need their output):\n" + summary
+		}
+		return ""
+	}},
+}
+
+// promptSectionEnabled reports whether the prompt_sections config leaves the section on
+func (a *Agent) promptSectionEnabled(name string) bool {
+	enabled, ok := a.config.PromptSections[name]
+	return !ok || enabled
+}
+
+// renderPromptProviders replaces the placeholders of the providers with static or dynamic values in
+// prompt, returning the prompt and the dynamic sections
+func (a *Agent) renderPromptProviders(prompt string, static bool) (string, []promptSection) {
+	var sections []promptSection
+	var replacements []string
+	for _, provider := range promptProviders {
+		if provider.Static != static {
+			continue
+		}
+		text := ""
+		if a.promptSectionEnabled(provider.Name) {
+			text = provider.Render(a)
+		}
+		replacements = append(replacements, provider.Variable, text)
+		if !static {
+			sections = append(sections, promptSection{provider.Name, text})
+		}
+	}
+	// One pass, so a section whose text looks like a placeholder isn't replaced in turn
+	return strings.NewReplacer(replacements...).Replace(prompt), sections
+}
+
+// gitStatusSection describes the branch and uncommitted changes, or is empty outside a repository
+func gitStatusSection() string {
+	status, err := runGit("", "status", "--short", "--branch")
+	if err != nil || status == "" {
+		return ""
+	}
+	lines := strings.Split(status, "\n")
+	branch := strings.TrimPrefix(lines[0], "## ")
+	changes := lines[1:]
+	if len(changes) == 0 {
+		return fmt.Sprintf("Git branch: %s (no uncommitted changes)\n", branch)
+	}
+	more := ""
+	if len(changes) > maxGitStatusLines {
+		more = fmt.Sprintf("\n... and %d more", len(changes)-maxGitStatusLines)
+		changes = changes[:maxGitStatusLines]
+	}
+	return fmt.Sprintf("Git branch: %s, uncommitted changes:\n%s%s\n", branch, strings.Join(changes, "\n"), more)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"agent/tools"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptProviders(t *testing.T) {
+	agent := &Agent{LiveContext: NewLiveContext(), config: &Config{PromptSections: map[string]bool{"git": false}}}
+	defer agent.LiveContext.Close()
+	agent.shellHistory = tools.NewShellHistory(10)
+	agent.shellHistory.Record("echo {DATE}", 0, 0)
+
+	_, reference, sections := agent.buildSystemPrompt()
+	assert.Contains(t, reference, "Current time: ")
+	assert.Contains(t, reference, "echo {DATE}", "section text isn't treated as a placeholder")
+	assert.NotContains(t, reference, "{GIT_STATUS}")
+
+	texts := make(map[string]string)
+	for _, section := range sections {
+		texts[section.Name] = section.Text
+	}
+	assert.Contains(t, texts, "git")
+	assert.Empty(t, texts["git"])
+	assert.Contains(t, texts["date"], "Current time: ")
+}
+
+func TestGitStatusSection(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tempDir))
+	defer os.Chdir(originalDir)
+
+	assert.Empty(t, gitStatusSection(), "empty outside a repository")
+
+	require.NoError(t, exec.Command("git", "init", "-q", "-b", "main").Run())
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "new.go"), []byte("package main\n"), 0644))
+	section := gitStatusSection()
+	assert.Contains(t, section, "main")
+	assert.Contains(t, section, "uncommitted changes:\n?? new.go\n")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"agent/tools"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// TestSystemPromptSnapshot renders the system prompt for a fixture workspace and compares it to a
+// checked-in golden file. Run `make prompt-snapshot-update` after intentional template or
+// serialization changes and review the golden diff.
+func TestSystemPromptSnapshot(t *testing.T) {
+	goldenPath, err := filepath.Abs(filepath.Join("testdata", "prompt", "system_prompt.golden"))
+	assert.NoError(t, err)
+
+	originalDir, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(filepath.Join("testdata", "prompt", "workspace")))
+	defer os.Chdir(originalDir)
+
+	// The date and the repository's git status change from run to run
+	agent := &Agent{LiveContext: NewLiveContext(), config: &Config{PromptSections: map[string]bool{"date": false, "git": false}}}
+	defer agent.LiveContext.Close()
+
+	agent.InitializeDefaultContext()
+	end := 4
+	assert.NoError(t, agent.LiveContext.AddFile("main.go", 3, &end))
+	assert.NoError(t, agent.LiveContext.AddFile("pkg/util.go", 1, nil))
+	assert.NoError(t, agent.LiveContext.SetPriority("pkg/util.go", true, PriorityHigh))
+	agent.changedFiles = []string{"pkg/util.go"}
+	agent.shellHistory = tools.NewShellHistory(10)
+	agent.shellHistory.Record("go test ./...", 1, 2*time.Second)
+
+	// Replace machine-specific values so the snapshot is stable
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	prompt, reference, _ := agent.buildSystemPrompt()
+	prompt += "\n\n--- context message ---\n\n" + withContextMessage(nil, reference)[0].Content
+	prompt = strings.ReplaceAll(prompt, cwd, "<WORKSPACE>")
+	prompt = strings.ReplaceAll(prompt, "OS: "+runtime.GOOS, "OS: <OS>")
+
+	if *updateGolden {
+		assert.NoError(t, os.WriteFile(goldenPath, []byte(prompt), 0644))
+		return
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	assert.NoError(t, err, "golden file missing; run `make prompt-snapshot-update`")
+	assert.Equal(t, string(golden), prompt, "system prompt changed; if intentional, run `make prompt-snapshot-update` and review the diff")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+// inputQueue holds the lines typed while a turn runs, so they are sent as the next messages once it
+// finishes instead of being mixed into its output. Guarded by the agent's inProgressMutex.
+type inputQueue struct {
+	lines     []string
+	messages  int // messages among the lines, for status displays
+	inPaste   bool
+	continues bool // the last line held continues onto the next (an open paste or a trailing \)
+	released  int  // lines handed back to the input when the last turn finished
+}
+
+// interceptInput handles each line as it is typed: /stop during a turn cancels the turn, and other
+// lines typed during a turn are held until it finishes. Confirmations still get their answers.
+func (a *Agent) interceptInput(line string) bool {
+	if a.interceptStop(line) {
+		return true
+	}
+	a.inProgressMutex.Lock()
+	if !a.inProgress || a.asking {
+		a.inProgressMutex.Unlock()
+		return false
+	}
+	startsMessage := a.queue.add(line)
+	a.inProgressMutex.Unlock()
+
+	// Printed after unlocking, since the TUI reads status under the same lock while drawing output
+	if startsMessage && !a.quiet {
+		preview := []rune(strings.TrimSpace(stripPasteMarkers(line)))
+		if len(preview) > 60 {
+			preview = append(preview[:57], []rune("...")...)
+		}
+		fmt.Println(theme.InfoText("⏳ Queued until this turn finishes: " + string(preview)))
+	}
+	return true
+}
+
+// add holds a line and reports whether it starts a new message
+func (q *inputQueue) add(line string) bool {
+	startsMessage := !q.continues
+	if startsMessage {
+		q.messages++
+	}
+	if start, end := strings.LastIndex(line, pasteStart), strings.LastIndex(line, pasteEnd); start > end {
+		q.inPaste = true
+	} else if end >= 0 {
+		q.inPaste = false
+	}
+	q.continues = q.inPaste || strings.HasSuffix(line, `\`)
+	q.lines = append(q.lines, line)
+	return startsMessage
+}
+
+// releaseQueue hands the held lines back to the input, after the ones already typed. The caller
+// holds inProgressMutex, so no line can be held after the release.
+func (a *Agent) releaseQueue() {
+	reader, ok := a.input.(*lineReader)
+	if !ok || len(a.queue.lines) == 0 {
+		return
+	}
+	reader.Append(a.queue.lines...)
+	a.queue = inputQueue{released: a.queue.released + len(a.queue.lines)}
+}
+
+// takeReleased reports how many lines the finished turns released, so the prompt loop can mark
+// the messages they make as queued
+func (a *Agent) takeReleased() int {
+	a.inProgressMutex.Lock()
+	defer a.inProgressMutex.Unlock()
+	released := a.queue.released
+	a.queue.released = 0
+	return released
+}
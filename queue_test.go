@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputQueueMessages(t *testing.T) {
+	var queue inputQueue
+	assert.True(t, queue.add("first"))
+	assert.True(t, queue.add(pasteStart+"pasted"))
+	assert.False(t, queue.add("still pasted"+pasteEnd))
+	assert.True(t, queue.add(`continued \`))
+	assert.False(t, queue.add("end"))
+	assert.Equal(t, 3, queue.messages)
+}
+
+func TestQueuedInputIsReleasedAfterTheTurn(t *testing.T) {
+	a := &Agent{quiet: true}
+	reader := newLineReader(strings.NewReader(""), nil)
+	a.input = reader
+
+	assert.False(t, a.interceptInput("between turns"), "lines typed between turns are read normally")
+
+	a.inProgress = true
+	assert.True(t, a.interceptInput("typed during the turn"))
+	assert.Equal(t, 1, a.queue.messages)
+
+	a.asking = true
+	assert.False(t, a.interceptInput("y"), "confirmations still get their answers")
+	a.asking = false
+
+	a.inProgress = false
+	a.releaseQueue()
+	assert.Equal(t, 1, a.takeReleased())
+	assert.Zero(t, a.queue.messages)
+	assert.True(t, reader.Scan())
+	assert.Equal(t, "typed during the turn", reader.Text())
+	assert.False(t, reader.Scan())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"agent/api"
+	"agent/filters"
+	"agent/logging"
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+)
+
+// RedactionConfig controls the masking of credentials in requests, tool results, and session logs
+type RedactionConfig struct {
+	Disabled bool     `json:"disabled"` // send and log content unmasked
+	Patterns []string `json:"patterns"` // regexes to mask besides filters.DefaultSecretPatterns; a capture group masks only the group
+}
+
+// sessionRedactor masks credentials in the session log and request snapshots. It's nil when redaction
+// is disabled.
+var sessionRedactor atomic.Pointer[filters.Secrets]
+
+// newSecretsFilter builds the redactor from the default and configured patterns and the API keys
+// written in the config. With resolved, keys that api_key settings refer to are masked too once
+// they're known.
+func (a *Agent) newSecretsFilter(resolved bool) *filters.Secrets {
+	var keys []string
+	for _, provider := range a.config.Providers {
+		if provider == nil || provider.APIKey == "" {
+			continue
+		}
+		if !api.IsKeyReference(provider.APIKey) {
+			keys = append(keys, provider.APIKey)
+		} else if key, ok := api.ResolvedAPIKey(provider.APIKey); ok && resolved {
+			keys = append(keys, key)
+		}
+	}
+	secrets, err := filters.NewSecrets(a.config.Redaction.Patterns, keys)
+	if err != nil {
+		logging.Warnf("Ignoring redaction patterns: %v", err)
+		secrets, _ = filters.NewSecrets(nil, keys)
+	}
+	return secrets
+}
+
+// installRedaction sets up the redactor for tool results and the session log; installContentFilters
+// adds it to the outbound filters
+func (a *Agent) installRedaction() {
+	if a.config.Redaction.Disabled {
+		a.redactor = nil
+		sessionRedactor.Store(nil)
+		return
+	}
+	a.redactor = a.newSecretsFilter(false)
+	sessionRedactor.Store(a.redactor)
+}
+
+// redact masks credentials in text unless redaction is disabled
+func (a *Agent) redact(text string) string {
+	if a.redactor == nil {
+		return text
+	}
+	text, _ = a.redactor.Redact(text)
+	return text
+}
+
+// redactJSON marshals v with its credentials masked. Strings are masked after decoding so a
+// replacement can't break the JSON around it.
+func redactJSON(v any, indent bool) ([]byte, error) {
+	marshal := func(v any) ([]byte, error) {
+		if indent {
+			return json.MarshalIndent(v, "", "  ")
+		}
+		return json.Marshal(v)
+	}
+	data, err := marshal(v)
+	secrets := sessionRedactor.Load()
+	if err != nil || secrets == nil {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	tree, changes := redactTree(secrets, tree)
+	if changes == 0 {
+		// Keep the field order of the original
+		return data, nil
+	}
+	return marshal(tree)
+}
+
+// redactTree masks the strings in a decoded JSON value, returning it and how many were masked
+func redactTree(secrets *filters.Secrets, value any) (any, int) {
+	changes := 0
+	switch value := value.(type) {
+	case string:
+		return secrets.Redact(value)
+	case []any:
+		for i := range value {
+			var n int
+			value[i], n = redactTree(secrets, value[i])
+			changes += n
+		}
+	case map[string]any:
+		for key := range value {
+			var n int
+			value[key], n = redactTree(secrets, value[key])
+			changes += n
+		}
+	}
+	return value, changes
+}
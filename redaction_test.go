@@ -0,0 +1,29 @@
+package main
+
+import (
+	"agent/filters"
+	"agent/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactJSON(t *testing.T) {
+	secrets, err := filters.NewSecrets(nil, nil)
+	assert.NoError(t, err)
+	sessionRedactor.Store(secrets)
+	defer sessionRedactor.Store(nil)
+
+	data, err := redactJSON(models.Message{Role: "tool", Content: `{"password": "hunter2-Xk9qPz7w"}`}, false)
+	assert.NoError(t, err)
+	var message models.Message
+	assert.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, `{"password": "[REDACTED]"}`, message.Content)
+
+	clean := models.Message{Role: "user", Content: "nothing to hide"}
+	data, err = redactJSON(clean, false)
+	assert.NoError(t, err)
+	expected, _ := json.Marshal(clean)
+	assert.Equal(t, expected, data)
+}
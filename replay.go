@@ -0,0 +1,216 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionEntry is one line of a session log: a message, or a record with a type such as "request"
+type sessionEntry struct {
+	Type    string // empty for messages
+	Message models.Message
+	Request RequestRecord
+	Task    TaskRecord
+}
+
+// readSessionLog parses every line of a session log
+func readSessionLog(path string) ([]sessionEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []sessionEntry
+	var decoder sessionDecoder
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // messages can hold whole files
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		data, err := decoder.decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if data == nil {
+			continue
+		}
+		var header struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+
+		entry := sessionEntry{Type: header.Type}
+		switch header.Type {
+		case "":
+			err = json.Unmarshal(data, &entry.Message)
+		case "request":
+			err = json.Unmarshal(data, &entry.Request)
+		case "task":
+			err = json.Unmarshal(data, &entry.Task)
+		default:
+			continue // records added later are skipped by older viewers
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// resolveSessionLog accepts a path to a session log or the ID of a session in ~/.agent/sessions
+func resolveSessionLog(arg string) (string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(sessionsDir(homeDir), strings.TrimSuffix(arg, ".jsonl")+".jsonl")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("no session log at %s or %s", arg, path)
+	}
+	return path, nil
+}
+
+// runReplay implements `agent replay [--step] <session.jsonl|session ID>`
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	step := flags.Bool("step", false, "wait for Enter before showing each message")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: agent replay [--step] <session.jsonl|session ID>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one session log")
+	}
+
+	path, err := resolveSessionLog(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	config, _ := loadEffectiveConfig()
+	if _, err := setSessionKey(config.Sessions.EncryptionKey); err != nil {
+		return fmt.Errorf("sessions.encryption_key: %w", err)
+	}
+	entries, err := readSessionLog(path)
+	if err != nil {
+		return err
+	}
+
+	var advance func() bool
+	if *step {
+		keys := bufio.NewScanner(os.Stdin)
+		advance = func() bool {
+			fmt.Print(theme.DebugText("⏎ next · q quits "))
+			if !keys.Scan() {
+				return false
+			}
+			return strings.TrimSpace(keys.Text()) != "q"
+		}
+	}
+	replaySession(entries, config.Preview, advance)
+	return nil
+}
+
+// replaySession prints a session the way it looked when it ran. Tool results show what was sent to
+// the model, since what the tool showed the user isn't logged. When advance is set, it is called
+// before each message and the replay stops when it returns false.
+func replaySession(entries []sessionEntry, preview PreviewConfig, advance func() bool) {
+	for _, entry := range entries {
+		switch entry.Type {
+		case "request":
+			// A turn's first request marks where it started
+			if entry.Request.Iteration == 1 {
+				fmt.Println(theme.DebugText(fmt.Sprintf("── turn %d · %s/%s · %s", entry.Request.Turn, entry.Request.Provider, entry.Request.Model, entry.Request.Timestamp.Format("2006-01-02 15:04:05"))))
+			}
+			continue
+		case "task":
+			task := entry.Task
+			line := fmt.Sprintf("📋 task #%d %s", task.Task, task.State)
+			if task.Note != "" {
+				line += ": " + task.Note
+			}
+			fmt.Println(theme.InfoText(line))
+			continue
+		}
+
+		message := entry.Message
+		if message.Status == "deleted" {
+			// A tombstone: the message with this ID was removed from the context here
+			fmt.Println(theme.DebugText(fmt.Sprintf("🗑 removed %s message %s from the context", message.Role, message.ID)))
+			continue
+		}
+		if advance != nil && !advance() {
+			return
+		}
+		switch message.Role {
+		case "user":
+			fmt.Println(theme.UserText("👤 " + message.Content))
+			if len(message.Images) > 0 {
+				fmt.Println(theme.InfoText(fmt.Sprintf("📎 %d image(s) attached", len(message.Images))))
+			}
+		case "assistant":
+			if message.Status == "cancelled" {
+				fmt.Println(theme.WarningText("Cancelled request"))
+				continue
+			}
+			if message.Content != "" {
+				fmt.Print("🦜 ")
+				renderer := theme.NewMarkdownRenderer()
+				renderer.Write([]byte(message.Content))
+				renderer.Flush()
+				fmt.Println()
+			}
+			if message.Status == "interrupted" {
+				fmt.Println(theme.WarningText("Interrupted response"))
+			}
+			for _, toolCall := range message.ToolCalls {
+				var params map[string]interface{}
+				if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+					fmt.Println(theme.ToolText("🔧 " + toolCall.Function.Name + " " + toolCall.Function.Arguments))
+					continue
+				}
+				fmt.Println(theme.ToolText(formatToolCall(toolCall.Function.Name, params, preview)))
+			}
+		case "tool":
+			fmt.Println(formatToolOutput(replayToolResult(message), preview))
+			if len(message.Images) > 0 {
+				fmt.Println(theme.InfoText(fmt.Sprintf("📎 %d image(s) attached", len(message.Images))))
+			}
+		}
+	}
+}
+
+// replayToolResult extracts the output from a tool message's result envelope
+func replayToolResult(message models.Message) string {
+	var envelope models.ToolResultEnvelope
+	if err := json.Unmarshal([]byte(message.Content), &envelope); err != nil || envelope.Status == "" {
+		return message.Content // logged before results were envelopes
+	}
+	output := envelope.Summary
+	if envelope.Data != "" {
+		output = envelope.Data
+	}
+	if envelope.Status != "success" {
+		output = envelope.Status + ": " + output
+	}
+	return output
+}
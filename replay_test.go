@@ -0,0 +1,38 @@
+package main
+
+import (
+	"agent/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSessionLog(t *testing.T) {
+	log := strings.Join([]string{
+		`{"type":"request","turn":1,"iteration":1,"provider":"openai","model":"gpt-4o"}`,
+		`{"id":"1","role":"user","content":"list files","status":"active"}`,
+		`{"id":"2","role":"assistant","content":"","tool_calls":[{"id":"c1","type":"function","function":{"name":"shell","arguments":"{\"command\":\"ls\"}"}}],"status":"active"}`,
+		`{"type":"task","task":3,"state":"done"}`,
+		`{"type":"something_new"}`,
+		``,
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	assert.NoError(t, os.WriteFile(path, []byte(log), 0644))
+
+	entries, err := readSessionLog(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "gpt-4o", entries[0].Request.Model)
+	assert.Equal(t, "list files", entries[1].Message.Content)
+	assert.Equal(t, "shell", entries[2].Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, 3, entries[3].Task.Task)
+}
+
+func TestReplayToolResult(t *testing.T) {
+	envelope := models.NewToolResultEnvelope("error", "command failed\nexit status 1", nil)
+	assert.Equal(t, "error: command failed\nexit status 1", replayToolResult(models.Message{Content: envelope.JSON()}))
+	assert.Equal(t, "plain output", replayToolResult(models.Message{Content: "plain output"}))
+}
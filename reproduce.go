@@ -0,0 +1,269 @@
+package main
+
+import (
+	"agent/api"
+	"agent/logging"
+	"agent/models"
+	"agent/theme"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestRecord describes one model request. It is written to the session log before the request
+// is sent so model-dependent behavior can be traced back to the exact settings that produced it.
+type RequestRecord struct {
+	Type       string             `json:"type"` // always "request", to tell records apart from messages
+	Turn       int                `json:"turn"`
+	Iteration  int                `json:"iteration"`
+	Timestamp  time.Time          `json:"timestamp"`
+	Provider   string             `json:"provider"`
+	Model      string             `json:"model"`
+	Config     models.ModelConfig `json:"config"` // includes the seed sent with the request
+	PromptHash string             `json:"prompt_hash"`
+	Placement  string             `json:"live_context_placement"` // where live context was sent, for comparing the two
+	Snapshot   string             `json:"snapshot"`               // file holding the full request, used by --reproduce
+}
+
+// requestSnapshot is the full request and the response it got
+type requestSnapshot struct {
+	RequestRecord
+	SystemPrompt string            `json:"system_prompt"`
+	Messages     []models.Message  `json:"messages"`
+	Tools        []string          `json:"tools"`
+	Response     string            `json:"response"`
+	ToolCalls    []models.ToolCall `json:"tool_calls,omitempty"`
+}
+
+// turnSeed returns the model's fixed seed, or a new random one for the turn
+func turnSeed(model *models.Model) int64 {
+	if model.Config.Seed != nil {
+		return *model.Config.Seed
+	}
+	return rand.Int64N(1 << 31) // some providers only accept 32-bit seeds
+}
+
+// withSeed returns a copy of model that sends seed with its requests
+func withSeed(model *models.Model, seed int64) *models.Model {
+	seeded := *model
+	seeded.Config.Seed = &seed
+	return &seeded
+}
+
+// promptHash identifies the content of a request, ignoring message IDs and timestamps
+func promptHash(systemPrompt string, messages []models.Message, toolNames []string) string {
+	type sentMessage struct {
+		Role       string            `json:"role"`
+		Content    string            `json:"content"`
+		ToolCalls  []models.ToolCall `json:"tool_calls,omitempty"`
+		ToolCallID string            `json:"tool_call_id,omitempty"`
+	}
+	sent := make([]sentMessage, len(messages))
+	for i, msg := range messages {
+		sent[i] = sentMessage{msg.Role, msg.Content, msg.ToolCalls, msg.ToolCallID}
+	}
+	data, _ := json.Marshal(struct {
+		System   string        `json:"system"`
+		Messages []sentMessage `json:"messages"`
+		Tools    []string      `json:"tools"`
+	}{systemPrompt, sent, toolNames})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedToolNames(agentTools map[string]models.ToolDefinition) []string {
+	names := make([]string, 0, len(agentTools))
+	for name := range agentTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordRequest logs the request's metadata and saves its snapshot
+func (a *Agent) recordRequest(iteration int, model *models.Model, systemPrompt string, messages []models.Message, agentTools map[string]models.ToolDefinition) *requestSnapshot {
+	toolNames := sortedToolNames(agentTools)
+	snapshot := &requestSnapshot{
+		RequestRecord: RequestRecord{
+			Type:       "request",
+			Turn:       a.turn,
+			Iteration:  iteration,
+			Timestamp:  time.Now(),
+			Provider:   model.Provider.ID,
+			Model:      model.ID,
+			Config:     model.Config,
+			PromptHash: promptHash(systemPrompt, messages, toolNames),
+			Placement:  a.ContextPlacement(),
+			Snapshot:   a.sessionLogger.SnapshotPath(a.turn, iteration),
+		},
+		SystemPrompt: systemPrompt,
+		Messages:     messages,
+		Tools:        toolNames,
+	}
+	a.sessionLogger.LogRecord(snapshot.RequestRecord)
+	a.sessionLogger.saveSnapshot(snapshot)
+	return snapshot
+}
+
+// recordResponse adds the model's response to a request snapshot
+func (a *Agent) recordResponse(snapshot *requestSnapshot, content string, toolCalls []models.ToolCall) {
+	snapshot.Response = content
+	snapshot.ToolCalls = toolCalls
+	a.sessionLogger.saveSnapshot(snapshot)
+}
+
+// saveSnapshot writes a snapshot as indented JSON, or as an encryption header and one sealed line
+// when the session log is encrypted
+func (sl *SessionLogger) saveSnapshot(snapshot *requestSnapshot) {
+	sl.mu.Lock()
+	cipher, writable := sl.cipher, sl.writable()
+	sl.mu.Unlock()
+	if !writable {
+		return
+	}
+
+	indent := cipher == nil
+	data, err := redactJSON(snapshot, indent)
+	if err == nil && cipher != nil {
+		data, err = sealSnapshot(cipher, data)
+	}
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(snapshot.Snapshot), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(snapshot.Snapshot, data, 0600)
+	}
+	if err != nil {
+		logging.Errorf("Error saving request snapshot: %v", err)
+	}
+}
+
+// parseReproduceSpec parses "<session>:<turn>[:<iteration>]"; the iteration defaults to 1
+func parseReproduceSpec(spec string) (string, int, int, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return "", 0, 0, fmt.Errorf("invalid --reproduce value %q: expected <session>:<turn>[:<iteration>]", spec)
+	}
+	turn, err := strconv.Atoi(parts[1])
+	if err != nil || turn < 1 {
+		return "", 0, 0, fmt.Errorf("invalid turn %q", parts[1])
+	}
+	iteration := 1
+	if len(parts) == 3 {
+		if iteration, err = strconv.Atoi(parts[2]); err != nil || iteration < 1 {
+			return "", 0, 0, fmt.Errorf("invalid iteration %q", parts[2])
+		}
+	}
+	return parts[0], turn, iteration, nil
+}
+
+// Reproduce re-sends a recorded request with the same model, parameters, seed, prompt, and tools,
+// prints the new response, and compares it with the original. Tool calls are shown but not run.
+func (a *Agent) Reproduce(spec string) error {
+	session, turn, iteration, err := parseReproduceSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	path := a.sessionLogger.sessionSnapshotPath(session, turn, iteration)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("no recorded request for turn %d iteration %d of session %s: %w", turn, iteration, session, err)
+	}
+	if data, err = openSnapshot(data); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var snapshot requestSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if hash := promptHash(snapshot.SystemPrompt, snapshot.Messages, snapshot.Tools); hash != snapshot.PromptHash {
+		return fmt.Errorf("snapshot %s doesn't match its recorded prompt hash", path)
+	}
+
+	model, err := a.resolveModel(snapshot.Provider, snapshot.Model)
+	if err != nil {
+		return err
+	}
+	model.Config = snapshot.Config
+
+	agentTools := make(map[string]models.ToolDefinition)
+	for _, name := range snapshot.Tools {
+		tool, ok := a.tools[name]
+		if !ok {
+			return fmt.Errorf("tool %s from the recorded request no longer exists", name)
+		}
+		agentTools[name] = tool
+	}
+
+	seed := "none"
+	if snapshot.Config.Seed != nil {
+		seed = strconv.FormatInt(*snapshot.Config.Seed, 10)
+	}
+	fmt.Println(theme.InfoText(fmt.Sprintf("Reproducing session %s turn %d iteration %d: %s/%s, temperature %g, top_p %g, seed %s, prompt %s",
+		session, turn, iteration, snapshot.Provider, snapshot.Model, snapshot.Config.Temperature, snapshot.Config.TopP, seed, snapshot.PromptHash[:12])))
+
+	renderer := theme.NewMarkdownRenderer()
+	fmt.Print("🦜 ")
+	content, toolCalls, err := api.Invoke(context.Background(), model, snapshot.Messages, snapshot.SystemPrompt, agentTools, func(token string) {
+		renderer.Write([]byte(token))
+	})
+	renderer.Flush()
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	for _, toolCall := range toolCalls {
+		fmt.Println(theme.ToolText(fmt.Sprintf("🔧 %s %s (not run)", toolCall.Function.Name, toolCall.Function.Arguments)))
+	}
+
+	if content == snapshot.Response && sameToolCalls(toolCalls, snapshot.ToolCalls) {
+		fmt.Println(theme.SuccessText("Response matches the original"))
+	} else {
+		fmt.Println(theme.WarningText("Response differs from the original:"))
+		fmt.Println(snapshot.Response)
+		for _, toolCall := range snapshot.ToolCalls {
+			fmt.Println(theme.ToolText(fmt.Sprintf("🔧 %s %s", toolCall.Function.Name, toolCall.Function.Arguments)))
+		}
+	}
+	return nil
+}
+
+func sameToolCalls(a, b []models.ToolCall) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Function != b[i].Function {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveModel finds a configured model and returns a copy linked to its provider. API keys are
+// resolved by the api package when a request is sent.
+func (a *Agent) resolveModel(providerID, modelID string) (*models.Model, error) {
+	for _, provider := range a.config.Providers {
+		if provider.ID != providerID {
+			continue
+		}
+		for _, model := range provider.Models {
+			if model.ID == modelID {
+				resolved := *model
+				resolved.Provider = provider
+				return &resolved, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("model %s/%s is not configured", providerID, modelID)
+}
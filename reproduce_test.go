@@ -0,0 +1,94 @@
+package main
+
+import (
+	"agent/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReproduceSpec(t *testing.T) {
+	cases := []struct {
+		spec      string
+		session   string
+		turn      int
+		iteration int
+		err       string
+	}{
+		{"20250101120000:3", "20250101120000", 3, 1, ""},
+		{"20250101120000:3:2", "20250101120000", 3, 2, ""},
+		{"20250101120000-1:1", "20250101120000-1", 1, 1, ""},
+		{"20250101120000", "", 0, 0, "expected <session>:<turn>[:<iteration>]"},
+		{":3", "", 0, 0, "expected <session>:<turn>[:<iteration>]"},
+		{"20250101120000:3:2:1", "", 0, 0, "expected <session>:<turn>[:<iteration>]"},
+		{"20250101120000:0", "", 0, 0, `invalid turn "0"`},
+		{"20250101120000:three", "", 0, 0, `invalid turn "three"`},
+		{"20250101120000:3:0", "", 0, 0, `invalid iteration "0"`},
+		{"20250101120000:3:", "", 0, 0, `invalid iteration ""`},
+	}
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			session, turn, iteration, err := parseReproduceSpec(c.spec)
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.session, session)
+			assert.Equal(t, c.turn, turn)
+			assert.Equal(t, c.iteration, iteration)
+		})
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	messages := []models.Message{
+		{ID: "1", Role: "user", Content: "fix the test", Timestamp: time.Unix(1, 0)},
+		{ID: "2", Role: "assistant", ToolCalls: []models.ToolCall{{ID: "call", Function: models.FunctionCall{Name: "read_file", Arguments: `{"path":"a.go"}`}}}},
+		{ID: "3", Role: "tool", Content: "package a", ToolCallID: "call"},
+	}
+	tools := []string{"edit_file", "read_file"}
+	base := promptHash("system", messages, tools)
+
+	with := func(change func(messages []models.Message)) []models.Message {
+		changed := make([]models.Message, len(messages))
+		copy(changed, messages)
+		change(changed)
+		return changed
+	}
+	cases := []struct {
+		name     string
+		system   string
+		messages []models.Message
+		tools    []string
+		same     bool
+	}{
+		{"identical", "system", messages, tools, true},
+		{"message IDs", "system", with(func(m []models.Message) { m[0].ID = "other" }), tools, true},
+		{"timestamps", "system", with(func(m []models.Message) { m[0].Timestamp = time.Now() }), tools, true},
+		{"status", "system", with(func(m []models.Message) { m[0].Status = "active" }), tools, true},
+		{"system prompt", "system!", messages, tools, false},
+		{"content", "system", with(func(m []models.Message) { m[0].Content = "fix the tests" }), tools, false},
+		{"role", "system", with(func(m []models.Message) { m[0].Role = "system" }), tools, false},
+		{"tool call", "system", with(func(m []models.Message) {
+			m[1].ToolCalls = []models.ToolCall{{ID: "call", Function: models.FunctionCall{Name: "read_file", Arguments: `{"path":"b.go"}`}}}
+		}), tools, false},
+		{"tool call ID", "system", with(func(m []models.Message) { m[2].ToolCallID = "other" }), tools, false},
+		{"missing message", "system", messages[:2], tools, false},
+		{"tools", "system", messages, []string{"read_file"}, false},
+		{"tool order", "system", messages, []string{"read_file", "edit_file"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hash := promptHash(c.system, c.messages, c.tools)
+			assert.Len(t, hash, 64)
+			if c.same {
+				assert.Equal(t, base, hash)
+			} else {
+				assert.NotEqual(t, base, hash)
+			}
+		})
+	}
+}
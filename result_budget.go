@@ -0,0 +1,111 @@
+package main
+
+import (
+	"agent/logging"
+	"agent/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults for ResultBudgetConfig
+const (
+	defaultResultChars     = 20000
+	defaultTurnResultChars = 100000
+	minResultChars         = 2000 // what a result keeps once the turn's budget is spent
+)
+
+// ResultBudgetConfig caps how much tool output enters the conversation, so one huge result can't
+// crowd out the rest of the context. Output over the budget is trimmed and saved as an artifact the
+// model can read ranges of. Zero values use the defaults.
+type ResultBudgetConfig struct {
+	MaxChars     int            `json:"max_chars"`      // characters of one tool result (default 20000)
+	PerTool      map[string]int `json:"per_tool"`       // max_chars for specific tools, e.g. {"shell": 40000}
+	MaxTurnChars int            `json:"max_turn_chars"` // characters of all tool results in a turn (default 100000)
+}
+
+func (c ResultBudgetConfig) limits(tool string) (int, int) {
+	limit, turnLimit := c.MaxChars, c.MaxTurnChars
+	if perTool, ok := c.PerTool[tool]; ok && perTool > 0 {
+		limit = perTool
+	}
+	if limit <= 0 {
+		limit = defaultResultChars
+	}
+	if turnLimit <= 0 {
+		turnLimit = defaultTurnResultChars
+	}
+	return limit, turnLimit
+}
+
+// budgetToolResults trims results over their tool's budget, or over what's left of the turn's, before
+// they're added to the history
+func (a *Agent) budgetToolResults(results []models.ToolResult) {
+	for i := range results {
+		limit, turnLimit := a.config.ToolResults.limits(results[i].Name)
+		if remaining := turnLimit - a.turnResultChars; remaining < limit {
+			limit = max(remaining, minResultChars)
+		}
+		results[i].Content = a.trimToolResult(results[i], limit)
+		a.turnResultChars += len(results[i].Content)
+	}
+}
+
+// trimToolResult returns the result's content with its data cut to about limit characters. The
+// full data is saved as an artifact, except for read_artifact, whose output already is one.
+func (a *Agent) trimToolResult(result models.ToolResult, limit int) string {
+	if len(result.Content) <= limit {
+		return result.Content
+	}
+	var envelope models.ToolResultEnvelope
+	if err := json.Unmarshal([]byte(result.Content), &envelope); err != nil || len(envelope.Data) <= limit {
+		return result.Content
+	}
+
+	pointer := "the full output could not be saved"
+	if result.Name == "read_artifact" {
+		pointer = "read a smaller range"
+	} else if a.artifacts != nil {
+		artifact, err := a.artifacts.Save(result.Name+"-output.txt", "data", fmt.Sprintf("Full output of %s call %s", result.Name, result.ID), []byte(envelope.Data))
+		if err != nil {
+			logging.Warnf("Failed to save the output of %s as an artifact: %v", result.Name, err)
+		} else {
+			pointer = fmt.Sprintf("full output stored as artifact #%d, use read_artifact to fetch ranges", artifact.ID)
+		}
+	}
+
+	total := len(envelope.Data)
+	envelope.Data = trimMiddle(envelope.Data, limit, pointer)
+	envelope.Notes = append(envelope.Notes, fmt.Sprintf("Output trimmed from %d to %d characters: %s", total, len(envelope.Data), pointer))
+	return envelope.JSON()
+}
+
+// trimMiddle keeps about two thirds of limit from the start of text and a third from the end, cut at
+// line boundaries where possible, with a marker naming the lines left out
+func trimMiddle(text string, limit int, pointer string) string {
+	headEnd := cutBefore(text, limit*2/3)
+	if newline := strings.LastIndex(text[:headEnd], "\n"); newline > headEnd/2 {
+		headEnd = newline
+	}
+	tailStart := cutBefore(text, len(text)-limit/3)
+	if newline := strings.Index(text[tailStart:], "\n"); newline >= 0 && newline < limit/6 {
+		tailStart += newline + 1
+	}
+
+	firstOmitted := strings.Count(text[:headEnd], "\n") + 2
+	lastOmitted := strings.Count(text[:tailStart], "\n")
+	if !strings.HasSuffix(text[:tailStart], "\n") {
+		lastOmitted++
+	}
+	marker := fmt.Sprintf("... (lines %d-%d omitted: %s) ...", firstOmitted, lastOmitted, pointer)
+	return text[:headEnd] + "\n" + marker + "\n" + text[tailStart:]
+}
+
+// cutBefore moves index back to the start of a UTF-8 character so slicing doesn't split one
+func cutBefore(text string, index int) int {
+	for index > 0 && index < len(text) && !utf8.RuneStart(text[index]) {
+		index--
+	}
+	return index
+}
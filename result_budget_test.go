@@ -0,0 +1,59 @@
+package main
+
+import (
+	"agent/artifacts"
+	"agent/models"
+	"agent/tools"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetToolResults(t *testing.T) {
+	store := artifacts.NewStore(t.TempDir())
+	a := &Agent{config: &Config{ToolResults: ResultBudgetConfig{MaxChars: 3000, MaxTurnChars: 6000, PerTool: map[string]int{"git_diff": 5000}}}, artifacts: store}
+
+	var lines []string
+	for i := 1; i <= 500; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	output := strings.Join(lines, "\n")
+	result := func(name string) models.ToolResult {
+		return models.ToolResult{ID: "call", Name: name, Content: models.NewToolResultEnvelope("success", output, nil).JSON()}
+	}
+	small := models.ToolResult{Name: "shell", Content: models.NewToolResultEnvelope("success", "ok", nil).JSON()}
+
+	results := []models.ToolResult{small, result("shell"), result("git_diff")}
+	a.budgetToolResults(results)
+	assert.Equal(t, small.Content, results[0].Content)
+
+	var envelope models.ToolResultEnvelope
+	assert.NoError(t, json.Unmarshal([]byte(results[1].Content), &envelope))
+	assert.LessOrEqual(t, len(envelope.Data), 3200)
+	assert.True(t, strings.HasPrefix(envelope.Data, "line 1\n"))
+	assert.True(t, strings.HasSuffix(envelope.Data, "\nline 500"))
+	assert.Contains(t, envelope.Data, "omitted: full output stored as artifact #1, use read_artifact to fetch ranges")
+	assert.Len(t, envelope.Notes, 1)
+
+	// The full output can be read back in ranges
+	readArtifact := tools.NewReadArtifactTool(store)
+	_, read, err := readArtifact.Func(context.Background(), map[string]interface{}{"id": float64(1), "start_line": float64(250), "end_line": float64(251)})
+	assert.NoError(t, err)
+	assert.Equal(t, "Artifact #1 (shell-output.txt), lines 250-251 of 500:\nline 250\nline 251", read)
+
+	// git_diff has a larger budget, but only what's left of the turn's
+	assert.NoError(t, json.Unmarshal([]byte(results[2].Content), &envelope))
+	assert.Less(t, len(results[2].Content), 4000)
+	assert.Contains(t, envelope.Data, "artifact #2")
+
+	// Once the turn's budget is spent, results still keep a minimum
+	results = []models.ToolResult{result("shell")}
+	a.budgetToolResults(results)
+	assert.NoError(t, json.Unmarshal([]byte(results[0].Content), &envelope))
+	assert.Greater(t, len(envelope.Data), minResultChars/2)
+	assert.LessOrEqual(t, len(envelope.Data), minResultChars+200)
+}
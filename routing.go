@@ -0,0 +1,64 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"agent/theme"
+	"fmt"
+)
+
+// routingCandidates returns the configured models of model's family whose providers have an API
+// key. Keys aren't resolved until a request is routed to the provider.
+func (a *Agent) routingCandidates(model *models.Model) []*models.Model {
+	if model.Family == "" {
+		return nil
+	}
+	var candidates []*models.Model
+	for _, provider := range a.config.Providers {
+		for _, candidate := range provider.Models {
+			if candidate.Family != model.Family {
+				continue
+			}
+			resolved, err := a.resolveModel(provider.ID, candidate.ID)
+			if err != nil || !api.HasAPIKey(resolved.Provider.APIKey) {
+				continue
+			}
+			candidates = append(candidates, resolved)
+		}
+	}
+	return candidates
+}
+
+// routeRequest picks the provider for a request. When the model's family is served by several
+// providers, a request too large for the current provider's rate-limit headroom goes to the one with
+// the most room left.
+func (a *Agent) routeRequest(model *models.Model, systemPrompt string, messages []models.Message) *models.Model {
+	tokens := estimateRequestTokens(model, systemPrompt, messages)
+
+	routed := model
+	if candidates := a.routingCandidates(model); len(candidates) > 1 {
+		routed = api.Route(model, candidates, tokens)
+	}
+	if routed.Provider.ID != model.Provider.ID {
+		reason := "rate limited"
+		if quota, ok := api.ProviderQuota(model.Provider.ID); ok && quota.RemainingTokens >= 0 {
+			reason = fmt.Sprintf("%d tokens left, request needs ~%d", quota.RemainingTokens, tokens)
+		}
+		fmt.Println(theme.InfoText(fmt.Sprintf("↪ sending to %s/%s (%s: %s)", routed.Provider.ID, routed.ID, model.Provider.ID, reason)))
+	}
+	api.ReserveQuota(routed.Provider.ID, tokens)
+	return routed
+}
+
+// estimateRequestTokens roughly counts the tokens a request takes from the model's context window and
+// rate limits: its prompt at about four characters a token, plus the most the response can use
+func estimateRequestTokens(model *models.Model, systemPrompt string, messages []models.Message) int {
+	chars := len(systemPrompt)
+	for _, message := range messages {
+		chars += len(message.Content)
+		for _, call := range message.ToolCalls {
+			chars += len(call.Function.Arguments)
+		}
+	}
+	return (chars+3)/4 + model.Config.MaxTokens
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// IndexConfig selects the embedding model used by semantic search
+type IndexConfig struct {
+	Provider string `json:"provider"` // provider ID; defaults to the current model's provider
+	Model    string `json:"model"`    // embedding model (default text-embedding-3-small)
+}
+
+// embeddingModel returns the configured embedding model name
+func (c IndexConfig) embeddingModel() string {
+	if c.Model == "" {
+		return "text-embedding-3-small"
+	}
+	return c.Model
+}
+
+// indexDir returns the directory holding persisted semantic search indexes
+func indexDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	return filepath.Join(homeDir, ".agent", "index")
+}
+
+// embeddingProvider returns the provider that serves embeddings
+func (a *Agent) embeddingProvider() (*models.Provider, error) {
+	if a.config.Index.Provider == "" {
+		if a.currentModel == nil || a.currentModel.Provider == nil {
+			return nil, fmt.Errorf("no model selected; choose one with /model or set index.provider in the config")
+		}
+		return a.currentModel.Provider, nil
+	}
+
+	for _, provider := range a.config.Providers {
+		if provider.ID == a.config.Index.Provider {
+			return provider, nil
+		}
+	}
+	return nil, fmt.Errorf("embedding provider %q not found in config", a.config.Index.Provider)
+}
+
+// embed is the index.Embedder backed by the configured provider
+func (a *Agent) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	provider, err := a.embeddingProvider()
+	if err != nil {
+		return nil, err
+	}
+	return api.Embed(ctx, provider, a.config.Index.embeddingModel(), texts)
+}
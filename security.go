@@ -0,0 +1,60 @@
+package main
+
+import (
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+// SecurityConfig controls defenses against untrusted content and risky commands
+type SecurityConfig struct {
+	DisableInjectionScan bool   `json:"disable_injection_scan"` // don't scan live context and tool results for prompt injection
+	ConfirmOnInjection   bool   `json:"confirm_on_injection"`   // once injection is suspected in a turn, ask before tools that change anything
+	AuditCommands        bool   `json:"audit_commands"`         // have the model check shell commands against Policy before they run
+	Policy               string `json:"policy"`                 // security policy for the auditor (default tools.DefaultSecurityPolicy)
+
+	AllowedRoots          []string `json:"allowed_roots"`           // directories outside the workspace that tools may use, e.g. "~/notes"
+	AllowOutsideWorkspace bool     `json:"allow_outside_workspace"` // don't confine tool paths to the workspace and allowed_roots
+}
+
+// injectionGatedTools need the user's confirmation after possible prompt injection when
+// confirm_on_injection is set
+var injectionGatedTools = map[string]bool{
+	"create_file":    true,
+	"edit_file":      true,
+	"delete_file":    true,
+	"multi_edit":     true,
+	"insert_lines":   true,
+	"append_to_file": true,
+	"apply_patch":    true,
+	"undo_edit":      true,
+	"shell":          true,
+	"run_tests":      true,
+	"git_commit":     true,
+	"git_branch":     true,
+	"spawn_agent":    true,
+}
+
+// noteInjection warns the user the first time source is flagged in a turn and marks the turn as
+// suspect
+func (a *Agent) noteInjection(source string, findings []string) {
+	if a.flaggedSources == nil {
+		a.flaggedSources = make(map[string]bool)
+	}
+	if !a.flaggedSources[source] {
+		a.flaggedSources[source] = true
+		fmt.Println(theme.WarningText(fmt.Sprintf("⚠ Possible prompt injection in %s (%s); it is marked as untrusted for the model", source, strings.Join(findings, ", "))))
+	}
+}
+
+// confirmAfterInjection asks before running a tool that changes things once the turn has seen
+// possible prompt injection. It returns an error when the user declines.
+func (a *Agent) confirmAfterInjection(toolName string) error {
+	if !a.config.Security.ConfirmOnInjection || len(a.flaggedSources) == 0 || !injectionGatedTools[toolName] {
+		return nil
+	}
+	if a.Confirm(fmt.Sprintf("Content in this turn looked like prompt injection. Run %s anyway?", toolName)) {
+		return nil
+	}
+	return fmt.Errorf("the user declined to run %s after possible prompt injection was detected", toolName)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"agent/api"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SessionsConfig controls how session logs and request snapshots are stored and how long they're kept
+type SessionsConfig struct {
+	EncryptionKey string `json:"encryption_key"` // passphrase to encrypt them with, or an env:, keychain:, or cmd: reference to one
+	MaxSessions   int    `json:"max_sessions"`   // keep only the newest sessions; 0 keeps all
+	MaxAgeDays    int    `json:"max_age_days"`   // delete sessions last written more than this many days ago; 0 keeps them
+}
+
+const (
+	sessionCipherName = "nacl-secretbox"
+	sessionKDFName    = "scrypt"
+	// The scrypt parameters recommended for interactive logins in 2017
+	sessionKDFCost        = 32768
+	sessionKDFBlockSize   = 8
+	sessionKDFParallelism = 1
+)
+
+// encryptionHeader starts the encrypted part of a session log or snapshot. The lines after it are
+// sealedLines encrypted with the key derived from the passphrase and the salt.
+type encryptionHeader struct {
+	Type        string `json:"type"` // always "encryption"
+	Cipher      string `json:"cipher"`
+	KDF         string `json:"kdf"`
+	Cost        int    `json:"cost"`        // scrypt N
+	BlockSize   int    `json:"block_size"`  // scrypt r
+	Parallelism int    `json:"parallelism"` // scrypt p
+	Salt        []byte `json:"salt"`
+}
+
+// sealedLine is an encrypted line of a session log: a nonce followed by the ciphertext
+type sealedLine struct {
+	Sealed []byte `json:"sealed"`
+}
+
+// sessionPassphrase is the resolved sessions.encryption_key; empty when logs are written in plaintext
+var sessionPassphrase atomic.Pointer[string]
+
+// sessionLogsStopped is set while sessions.encryption_key is configured but can't be resolved. Nothing
+// is written then, rather than writing what should be encrypted in plaintext.
+var sessionLogsStopped atomic.Bool
+
+var (
+	derivedKeysMu sync.Mutex
+	derivedKeys   = make(map[string][]byte) // by passphrase and salt, so reading a session derives each key once
+)
+
+// sessionCipher encrypts and decrypts the lines of one session log
+type sessionCipher struct {
+	header encryptionHeader
+	key    [32]byte
+}
+
+// newSessionCipher creates a cipher with a new salt for the passphrase
+func newSessionCipher(passphrase string) (*sessionCipher, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return openSessionCipher(encryptionHeader{
+		Type:        "encryption",
+		Cipher:      sessionCipherName,
+		KDF:         sessionKDFName,
+		Cost:        sessionKDFCost,
+		BlockSize:   sessionKDFBlockSize,
+		Parallelism: sessionKDFParallelism,
+		Salt:        salt,
+	}, passphrase)
+}
+
+// openSessionCipher recreates the cipher a header was written with
+func openSessionCipher(header encryptionHeader, passphrase string) (*sessionCipher, error) {
+	if header.Cipher != sessionCipherName || header.KDF != sessionKDFName {
+		return nil, fmt.Errorf("unsupported session encryption %s with %s", header.Cipher, header.KDF)
+	}
+
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	cacheKey := fmt.Sprintf("%s\x00%x\x00%d\x00%d\x00%d", passphrase, header.Salt, header.Cost, header.BlockSize, header.Parallelism)
+	key, ok := derivedKeys[cacheKey]
+	if !ok {
+		var err error
+		key, err = scrypt.Key([]byte(passphrase), header.Salt, header.Cost, header.BlockSize, header.Parallelism, 32)
+		if err != nil {
+			return nil, fmt.Errorf("session encryption parameters: %w", err)
+		}
+		derivedKeys[cacheKey] = key
+	}
+
+	c := &sessionCipher{header: header}
+	copy(c.key[:], key)
+	return c, nil
+}
+
+// seal encrypts one line of JSON into a sealedLine
+func (c *sessionCipher) seal(plaintext []byte) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedLine{Sealed: secretbox.Seal(nonce[:], plaintext, &nonce, &c.key)})
+}
+
+// open decrypts a sealedLine's contents
+func (c *sessionCipher) open(sealed []byte) ([]byte, error) {
+	var nonce [24]byte
+	if len(sealed) < len(nonce)+secretbox.Overhead {
+		return nil, errors.New("sealed line is too short")
+	}
+	copy(nonce[:], sealed)
+	plaintext, ok := secretbox.Open(nil, sealed[len(nonce):], &nonce, &c.key)
+	if !ok {
+		return nil, errors.New("can't decrypt the session; sessions.encryption_key may have changed since it was written")
+	}
+	return plaintext, nil
+}
+
+// sessionDecoder turns the lines of a session log or snapshot back into JSON, decrypting the lines
+// after an encryption header with sessions.encryption_key
+type sessionDecoder struct {
+	cipher *sessionCipher
+}
+
+// decode returns the JSON of a line, or nil for an encryption header
+func (d *sessionDecoder) decode(line []byte) ([]byte, error) {
+	var probe struct {
+		Type   string `json:"type"`
+		Sealed []byte `json:"sealed"`
+	}
+	if err := json.Unmarshal(line, &probe); err != nil {
+		return nil, err
+	}
+	switch {
+	case probe.Type == "encryption":
+		var header encryptionHeader
+		if err := json.Unmarshal(line, &header); err != nil {
+			return nil, err
+		}
+		passphrase := sessionPassphrase.Load()
+		if passphrase == nil || *passphrase == "" {
+			return nil, errors.New("the session is encrypted; set sessions.encryption_key to the key it was written with")
+		}
+		cipher, err := openSessionCipher(header, *passphrase)
+		if err != nil {
+			return nil, err
+		}
+		d.cipher = cipher
+		return nil, nil
+	case probe.Sealed != nil:
+		if d.cipher == nil {
+			return nil, errors.New("encrypted line without an encryption header")
+		}
+		return d.cipher.open(probe.Sealed)
+	}
+	return line, nil
+}
+
+// setSessionKey resolves sessions.encryption_key, which new session logs are encrypted with and
+// encrypted ones are read with, and returns it. While a configured key can't be resolved, session
+// logs and snapshots aren't written at all.
+func setSessionKey(setting string) (string, error) {
+	passphrase := ""
+	var err error
+	if setting != "" {
+		passphrase, err = api.ResolveAPIKey(setting)
+		if err == nil && passphrase == "" {
+			err = errors.New("the key is empty")
+		}
+		if err != nil {
+			passphrase = ""
+		}
+	}
+	sessionPassphrase.Store(&passphrase)
+	sessionLogsStopped.Store(err != nil)
+	return passphrase, err
+}
+
+// configureSessions resolves the encryption key and encrypts the rest of the open session logs with
+// it. Logs already encrypted keep their key; a log that can't be encrypted stops being written.
+func (a *Agent) configureSessions() []string {
+	passphrase, err := setSessionKey(a.config.Sessions.EncryptionKey)
+	if err != nil {
+		return []string{fmt.Sprintf("Warning: the session log is not being written, since sessions.encryption_key can't be resolved: %v", err)}
+	}
+	if passphrase == "" {
+		return nil
+	}
+
+	loggers := []*SessionLogger{a.sessionLogger}
+	for _, branch := range a.branches {
+		if branch.Logger != a.sessionLogger {
+			loggers = append(loggers, branch.Logger)
+		}
+	}
+	var warnings []string
+	for _, logger := range loggers {
+		if err := logger.encrypt(passphrase); err != nil {
+			logger.stop()
+			warnings = append(warnings, fmt.Sprintf("Warning: session %s is no longer being written, since it can't be encrypted: %v", logger.ID, err))
+		}
+	}
+	return warnings
+}
+
+// pruneSessions deletes the logs, request snapshots, checkpoints, and artifacts of sessions beyond
+// max_sessions or older than max_age_days. The current session and its forks are kept.
+func pruneSessions(cfg SessionsConfig, dir, current string) ([]string, error) {
+	if cfg.MaxSessions <= 0 && cfg.MaxAgeDays <= 0 {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type session struct {
+		id      string
+		modTime time.Time
+	}
+	var sessions []session
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() || id == current || strings.HasPrefix(id, current+"-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session{id, info.ModTime()})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].modTime.After(sessions[j].modTime) })
+
+	cutoff := time.Now().AddDate(0, 0, -cfg.MaxAgeDays)
+	var removed []string
+	for i, s := range sessions {
+		// The current session counts toward max_sessions
+		tooMany := cfg.MaxSessions > 0 && i+1 >= cfg.MaxSessions
+		tooOld := cfg.MaxAgeDays > 0 && s.modTime.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		for _, path := range []string{filepath.Join(dir, s.id+".jsonl"), filepath.Join(dir, s.id), checkpointDir(s.id), artifactsDir(s.id)} {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, s.id)
+	}
+	return removed, nil
+}
+
+// sealSnapshot encrypts a request snapshot as a header line followed by one sealed line
+func sealSnapshot(cipher *sessionCipher, data []byte) ([]byte, error) {
+	header, err := json.Marshal(cipher.header)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := cipher.seal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(append(header, '\n'), sealed...), '\n'), nil
+}
+
+// openSnapshot returns the JSON of a request snapshot, decrypting it if it's sealed
+func openSnapshot(data []byte) ([]byte, error) {
+	first, rest, found := bytes.Cut(data, []byte("\n"))
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if !found || json.Unmarshal(first, &probe) != nil || probe.Type != "encryption" {
+		return data, nil
+	}
+	var decoder sessionDecoder
+	if _, err := decoder.decode(first); err != nil {
+		return nil, err
+	}
+	return decoder.decode(bytes.TrimSpace(rest))
+}
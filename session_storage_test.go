@@ -0,0 +1,121 @@
+package main
+
+import (
+	"agent/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedSessionLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AGENT_TEST_SESSION_KEY", "correct horse battery staple")
+	defer setSessionKey("")
+
+	_, err := setSessionKey("env:AGENT_TEST_SESSION_KEY")
+	require.NoError(t, err)
+	logger, err := openSessionLogger("20250101120000")
+	require.NoError(t, err)
+	logger.LogMessage(models.Message{Role: "user", Content: "the launch code is 0000"})
+	snapshot := &requestSnapshot{RequestRecord: RequestRecord{Type: "request", Snapshot: logger.SnapshotPath(1, 1)}, SystemPrompt: "secret prompt"}
+	logger.saveSnapshot(snapshot)
+	require.NoError(t, logger.Close())
+
+	path := filepath.Join(home, ".agent", "sessions", "20250101120000.jsonl")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "launch code")
+	saved, err := os.ReadFile(snapshot.Snapshot)
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), "secret prompt")
+
+	entries, err := readSessionLog(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "the launch code is 0000", entries[0].Message.Content)
+	opened, err := openSnapshot(saved)
+	require.NoError(t, err)
+	assert.Contains(t, string(opened), "secret prompt")
+
+	t.Setenv("AGENT_TEST_SESSION_KEY", "wrong")
+	_, err = setSessionKey("env:AGENT_TEST_SESSION_KEY")
+	require.NoError(t, err)
+	_, err = readSessionLog(path)
+	assert.ErrorContains(t, err, "can't decrypt the session")
+
+	setSessionKey("")
+	_, err = readSessionLog(path)
+	assert.ErrorContains(t, err, "the session is encrypted")
+}
+
+func TestUnresolvableSessionKey(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AGENT_TEST_SESSION_KEY", "")
+	defer setSessionKey("")
+
+	a := &Agent{config: &Config{Sessions: SessionsConfig{EncryptionKey: "env:AGENT_TEST_SESSION_KEY"}}}
+	logger, err := openSessionLogger("20250101120000")
+	require.NoError(t, err)
+	a.sessionLogger = logger
+	warnings := a.configureSessions()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "not being written")
+
+	// Nothing is written rather than writing it in plaintext
+	logger.LogMessage(models.Message{Role: "user", Content: "the launch code is 0000"})
+	snapshot := &requestSnapshot{RequestRecord: RequestRecord{Type: "request", Snapshot: logger.SnapshotPath(1, 1)}, SystemPrompt: "secret prompt"}
+	logger.saveSnapshot(snapshot)
+	path := filepath.Join(home, ".agent", "sessions", "20250101120000.jsonl")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	assert.NoFileExists(t, snapshot.Snapshot)
+
+	// Once the key resolves, the log is written encrypted
+	t.Setenv("AGENT_TEST_SESSION_KEY", "correct horse battery staple")
+	assert.Empty(t, a.configureSessions())
+	logger.LogMessage(models.Message{Role: "user", Content: "the launch code is 0000"})
+	require.NoError(t, logger.Close())
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"kdf":"scrypt"`)
+	assert.NotContains(t, string(data), "launch code")
+}
+
+func TestPruneSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := sessionsDir(home)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	now := time.Now()
+	for i, id := range []string{"current", "current-1", "new", "old", "ancient"} {
+		path := filepath.Join(dir, id+".jsonl")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+		modTime := now.Add(-time.Duration(i*24) * time.Hour * 10)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "old", "requests"), 0755))
+
+	removed, err := pruneSessions(SessionsConfig{MaxAgeDays: 35}, dir, "current")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ancient"}, removed)
+
+	removed, err = pruneSessions(SessionsConfig{MaxSessions: 2}, dir, "current")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, removed)
+	assert.NoDirExists(t, filepath.Join(dir, "old"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"current-1.jsonl", "current.jsonl", "new.jsonl"}, names)
+}
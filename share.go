@@ -0,0 +1,148 @@
+package main
+
+import (
+	"agent/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ShareConfig controls where /share uploads sanitized transcripts
+type ShareConfig struct {
+	Service        string `json:"service"`         // "gist" (default) or "paste"
+	PasteURL       string `json:"paste_url"`       // endpoint that accepts a text/plain POST and responds with the paste URL
+	GistPublic     bool   `json:"gist_public"`     // create public gists instead of secret ones
+	AnonymizePaths bool   `json:"anonymize_paths"` // replace home and workspace paths by default
+}
+
+// sanitizeTranscript masks credentials and optionally replaces local paths with placeholders. Shared
+// transcripts are always masked, even with redaction disabled, and so are the keys behind env:,
+// keychain:, and cmd: api_key settings.
+func (a *Agent) sanitizeTranscript(text string, anonymizePaths bool) string {
+	text, _ = a.newSecretsFilter(true).Redact(text)
+
+	if anonymizePaths {
+		if cwd, err := os.Getwd(); err == nil && cwd != "/" {
+			text = strings.ReplaceAll(text, cwd, "<workspace>")
+		}
+		if home, err := os.UserHomeDir(); err == nil && home != "/" {
+			text = strings.ReplaceAll(text, home, "~")
+		}
+	}
+
+	return text
+}
+
+// renderTranscript renders the active conversation history as markdown
+func renderTranscript(messages []models.Message) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Agent transcript (%s)\n", time.Now().Format("2006-01-02 15:04")))
+
+	for _, msg := range messages {
+		if msg.Status == "deleted" {
+			continue
+		}
+
+		switch msg.Role {
+		case "tool":
+			sb.WriteString(fmt.Sprintf("\n### tool result: %s%s\n\n```\n%s\n```\n", msg.ToolName, cancelledLabel(msg), msg.Content))
+		default:
+			sb.WriteString(fmt.Sprintf("\n### %s%s\n\n", msg.Role, cancelledLabel(msg)))
+			if msg.Content != "" {
+				sb.WriteString(msg.Content + "\n")
+			}
+			for _, tc := range msg.ToolCalls {
+				sb.WriteString(fmt.Sprintf("\n**tool call:** `%s`\n\n```json\n%s\n```\n", tc.Function.Name, tc.Function.Arguments))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// cancelledLabel marks messages the user cancelled or interrupted
+func cancelledLabel(msg models.Message) string {
+	switch msg.Status {
+	case "cancelled":
+		return " (cancelled)"
+	case "interrupted":
+		return " (interrupted)"
+	}
+	return ""
+}
+
+// uploadTranscript publishes a transcript to the configured service and returns its URL
+func uploadTranscript(cfg ShareConfig, transcript string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var req *http.Request
+	var err error
+	switch cfg.Service {
+	case "", "gist":
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return "", fmt.Errorf("GITHUB_TOKEN is not set (required to create gists)")
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"description": "Agent conversation transcript",
+			"public":      cfg.GistPublic,
+			"files": map[string]interface{}{
+				"transcript.md": map[string]string{"content": transcript},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode gist: %w", err)
+		}
+
+		req, err = http.NewRequest("POST", "https://api.github.com/gists", bytes.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Content-Type", "application/json")
+	case "paste":
+		if cfg.PasteURL == "" {
+			return "", fmt.Errorf("share.paste_url must be set to use the paste service")
+		}
+		req, err = http.NewRequest("POST", cfg.PasteURL, strings.NewReader(transcript))
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	default:
+		return "", fmt.Errorf("unknown share service %q (use gist or paste)", cfg.Service)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("upload failed with status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if cfg.Service == "paste" {
+		return strings.TrimSpace(string(respBody)), nil
+	}
+
+	var gist struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(respBody, &gist); err != nil {
+		return "", fmt.Errorf("failed to parse gist response: %w", err)
+	}
+	return gist.HTMLURL, nil
+}
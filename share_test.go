@@ -0,0 +1,107 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeTranscript(t *testing.T) {
+	t.Setenv("AGENT_TEST_SHARE_KEY", "env-key-Zq81xLm2")
+	cmdKey := "cmd:echo cmd-key-Hw72pQs9"
+	_, err := api.ResolveAPIKey(cmdKey)
+	require.NoError(t, err)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workspace := filepath.Join(home, "project")
+	require.NoError(t, os.Mkdir(workspace, 0755))
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workspace))
+	defer os.Chdir(originalDir)
+	workspace, err = os.Getwd()
+	require.NoError(t, err)
+
+	a := &Agent{config: &Config{Providers: []*models.Provider{
+		{ID: "plain", APIKey: "plain-key-Rt44vNc0"},
+		{ID: "env", APIKey: "env:AGENT_TEST_SHARE_KEY"},
+		{ID: "cmd", APIKey: cmdKey},
+		{ID: "keychain", APIKey: "keychain:unresolved"},
+	}}}
+
+	cases := []struct {
+		name      string
+		text      string
+		anonymize bool
+		expected  string
+	}{
+		{"plain key", "key is plain-key-Rt44vNc0", false, "key is [REDACTED]"},
+		{"env key", "key is env-key-Zq81xLm2", false, "key is [REDACTED]"},
+		{"cmd key", "key is cmd-key-Hw72pQs9", false, "key is [REDACTED]"},
+		{"pattern", `password = "hunter2-Xk9qPz7w"`, false, `password = "[REDACTED]"`},
+		{"paths kept", "edited " + workspace + "/main.go", false, "edited " + workspace + "/main.go"},
+		{"workspace path", "edited " + workspace + "/main.go", true, "edited <workspace>/main.go"},
+		{"home path", "read " + home + "/.bashrc", true, "read ~/.bashrc"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, a.sanitizeTranscript(c.text, c.anonymize))
+		})
+	}
+}
+
+func TestRenderTranscript(t *testing.T) {
+	transcript := renderTranscript([]models.Message{
+		{Role: "user", Content: "fix the test"},
+		{Role: "assistant", Content: "Reading it.", ToolCalls: []models.ToolCall{{ID: "1", Function: models.FunctionCall{Name: "read_file", Arguments: `{"path":"a_test.go"}`}}}},
+		{Role: "tool", ToolName: "read_file", Content: "package a", ToolCallID: "1"},
+		{Role: "assistant", Content: "pruned away", Status: "deleted"},
+		{Role: "assistant", Content: "Half an answer", Status: "interrupted"},
+		{Role: "user", Content: "never mind", Status: "cancelled"},
+	})
+
+	assert.True(t, strings.HasPrefix(transcript, "# Agent transcript ("))
+	assert.Contains(t, transcript, "\n### user\n\nfix the test\n")
+	assert.Contains(t, transcript, "\n### assistant\n\nReading it.\n\n**tool call:** `read_file`\n\n```json\n{\"path\":\"a_test.go\"}\n```\n")
+	assert.Contains(t, transcript, "\n### tool result: read_file\n\n```\npackage a\n```\n")
+	assert.Contains(t, transcript, "\n### assistant (interrupted)\n\nHalf an answer\n")
+	assert.Contains(t, transcript, "\n### user (cancelled)\n\nnever mind\n")
+	assert.NotContains(t, transcript, "pruned away", "deleted messages are left out")
+}
+
+func TestShareAsksBeforeUploading(t *testing.T) {
+	var uploads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploads = append(uploads, string(body))
+		io.WriteString(w, "https://paste.example.com/1\n")
+	}))
+	defer server.Close()
+
+	share := func(answer string) string {
+		a := &Agent{
+			config:   &Config{Share: ShareConfig{Service: "paste", PasteURL: server.URL}},
+			Messages: []models.Message{{Role: "user", Content: `password = "hunter2-Xk9qPz7w"`, Status: "active"}},
+			input:    bufio.NewScanner(strings.NewReader(answer)),
+		}
+		return handleShare(a, nil)
+	}
+
+	assert.Contains(t, share("n\n"), "Transcript not shared")
+	assert.Empty(t, uploads)
+
+	assert.Contains(t, share("y\n"), "Shared transcript: https://paste.example.com/1")
+	require.Len(t, uploads, 1)
+	assert.Contains(t, uploads[0], `password = "[REDACTED]"`)
+}
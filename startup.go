@@ -0,0 +1,53 @@
+package main
+
+import (
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+const defaultGreeting = "🦜 welcome, friend"
+
+// StartupConfig controls what is printed before the first prompt
+type StartupConfig struct {
+	Greeting     string `json:"greeting"`      // replaces the default greeting
+	HideBanner   bool   `json:"hide_banner"`   // skip the greeting and the command list
+	HideCommands bool   `json:"hide_commands"` // greet without listing the commands
+	HideWarnings bool   `json:"hide_warnings"` // don't print config problems at startup; /config still shows them
+}
+
+// welcomeMessage returns the config warnings and banner shown at startup, or an empty string when
+// they are turned off in the config or with --quiet
+func (a *Agent) welcomeMessage() string {
+	if a.quiet {
+		return ""
+	}
+	startup := a.config.Startup
+
+	var lines []string
+	if !startup.HideWarnings {
+		for _, warning := range a.startupWarnings {
+			lines = append(lines, theme.WarningText(warning))
+		}
+	}
+	if !startup.HideBanner {
+		greeting := startup.Greeting
+		if greeting == "" {
+			greeting = defaultGreeting
+		}
+		if !startup.HideCommands {
+			greeting += "\n   " + a.GetAvailableCommands()
+		}
+		lines = append(lines, theme.AgentText(greeting))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configWarnings formats config problems as they are printed at startup
+func configWarnings(problems []ConfigProblem) []string {
+	warnings := make([]string, len(problems))
+	for i, problem := range problems {
+		warnings[i] = fmt.Sprintf("Warning: %s: %s (see /config)", configFileName, problem)
+	}
+	return warnings
+}
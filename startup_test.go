@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWelcomeMessage(t *testing.T) {
+	agent := &Agent{config: &Config{}, startupWarnings: []string{"Warning: config.json: max_iterations: must be positive"}}
+	agent.registerBuiltinCommands()
+
+	welcome := agent.welcomeMessage()
+	assert.Contains(t, welcome, "max_iterations")
+	assert.Contains(t, welcome, defaultGreeting)
+	assert.Contains(t, welcome, "Commands:")
+
+	agent.config.Startup = StartupConfig{Greeting: "hello", HideCommands: true, HideWarnings: true}
+	welcome = agent.welcomeMessage()
+	assert.Contains(t, welcome, "hello")
+	assert.NotContains(t, welcome, defaultGreeting)
+	assert.NotContains(t, welcome, "Commands:")
+	assert.NotContains(t, welcome, "max_iterations")
+
+	agent.config.Startup = StartupConfig{HideBanner: true}
+	assert.NotContains(t, agent.welcomeMessage(), "hello")
+	assert.Contains(t, agent.welcomeMessage(), "max_iterations")
+
+	agent.config.Startup = StartupConfig{}
+	agent.quiet = true
+	assert.Empty(t, agent.welcomeMessage())
+}
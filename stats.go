@@ -0,0 +1,150 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolCallRecord is the session log record of one tool call
+type ToolCallRecord struct {
+	Type       string    `json:"type"` // always "tool"
+	Turn       int       `json:"turn"`
+	Iteration  int       `json:"iteration"`
+	Tool       string    `json:"tool"`
+	Status     string    `json:"status"` // "ok", "error", or "cancelled"
+	DurationMS int64     `json:"duration_ms"`
+	Bytes      int       `json:"bytes"` // size of the result sent to the model
+	Time       time.Time `json:"time"`
+}
+
+// toolStats totals the calls of one tool this session
+type toolStats struct {
+	Calls     int
+	Failures  int
+	Cancelled int
+	Duration  time.Duration
+	Slowest   time.Duration
+	Bytes     int
+}
+
+// toolTelemetry collects toolStats for /stats
+type toolTelemetry struct {
+	mu    sync.Mutex
+	tools map[string]*toolStats
+}
+
+// recordToolCall adds a finished tool call to the session's statistics and log
+func (a *Agent) recordToolCall(iteration int, result models.ToolResult, duration time.Duration) {
+	status := "ok"
+	if result.IsError {
+		status = "error"
+	} else if result.IsCancelled {
+		status = "cancelled"
+	}
+
+	a.telemetry.mu.Lock()
+	if a.telemetry.tools == nil {
+		a.telemetry.tools = make(map[string]*toolStats)
+	}
+	stats, ok := a.telemetry.tools[result.Name]
+	if !ok {
+		stats = &toolStats{}
+		a.telemetry.tools[result.Name] = stats
+	}
+	stats.Calls++
+	stats.Duration += duration
+	stats.Slowest = max(stats.Slowest, duration)
+	stats.Bytes += len(result.Content)
+	switch status {
+	case "error":
+		stats.Failures++
+	case "cancelled":
+		stats.Cancelled++
+	}
+	a.telemetry.mu.Unlock()
+
+	a.sessionLogger.LogRecord(ToolCallRecord{
+		Type:       "tool",
+		Turn:       a.turn,
+		Iteration:  iteration,
+		Tool:       result.Name,
+		Status:     status,
+		DurationMS: duration.Milliseconds(),
+		Bytes:      len(result.Content),
+		Time:       time.Now(),
+	})
+}
+
+func handleStats(a *Agent, args []string) string {
+	a.telemetry.mu.Lock()
+	names := make([]string, 0, len(a.telemetry.tools))
+	stats := make(map[string]toolStats)
+	for name, tool := range a.telemetry.tools {
+		names = append(names, name)
+		stats[name] = *tool
+	}
+	a.telemetry.mu.Unlock()
+	if len(names) == 0 {
+		return theme.InfoText("No tool calls yet this session")
+	}
+	// The tools that took the most time come first
+	sort.Slice(names, func(i, j int) bool {
+		if stats[names[i]].Duration != stats[names[j]].Duration {
+			return stats[names[i]].Duration > stats[names[j]].Duration
+		}
+		return names[i] < names[j]
+	})
+
+	width := len("tool")
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	row := fmt.Sprintf("%%-%ds %%6s %%8s %%10s %%10s %%10s %%10s", width)
+
+	var total toolStats
+	var result strings.Builder
+	result.WriteString(theme.InfoText("Tool calls this session:") + "\n")
+	result.WriteString(theme.InfoText(fmt.Sprintf(row, "tool", "calls", "failed", "total", "average", "slowest", "returned")) + "\n")
+	for _, name := range names {
+		tool := stats[name]
+		result.WriteString(theme.InfoText(fmt.Sprintf(row, name, fmt.Sprint(tool.Calls), failureRate(tool),
+			formatDuration(tool.Duration), formatDuration(tool.Duration/time.Duration(tool.Calls)), formatDuration(tool.Slowest), formatBytes(tool.Bytes))) + "\n")
+		total.Calls += tool.Calls
+		total.Failures += tool.Failures
+		total.Duration += tool.Duration
+		total.Bytes += tool.Bytes
+	}
+	result.WriteString(theme.InfoText(fmt.Sprintf("%d calls took %s and returned %s; cancelled calls aren't counted as failures",
+		total.Calls, formatDuration(total.Duration), formatBytes(total.Bytes))))
+	return result.String()
+}
+
+// failureRate formats how many of a tool's calls failed, e.g. "2 (25%)"
+func failureRate(tool toolStats) string {
+	if tool.Failures == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d (%d%%)", tool.Failures, tool.Failures*100/tool.Calls)
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return fmt.Sprintf("%dms", duration.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", duration.Seconds())
+}
+
+func formatBytes(bytes int) string {
+	switch {
+	case bytes < 1024:
+		return fmt.Sprintf("%dB", bytes)
+	case bytes < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(bytes)/1024)
+	}
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+}
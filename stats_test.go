@@ -0,0 +1,44 @@
+package main
+
+import (
+	"agent/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolStats(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	logger, err := openSessionLogger("20250101120000")
+	require.NoError(t, err)
+	a := &Agent{sessionLogger: logger, turn: 2}
+
+	assert.Contains(t, handleStats(a, nil), "No tool calls")
+
+	a.recordToolCall(1, models.ToolResult{Name: "run_shell", Content: strings.Repeat("x", 2048)}, 3*time.Second)
+	a.recordToolCall(1, models.ToolResult{Name: "run_shell", Content: "failed", IsError: true}, time.Second)
+	a.recordToolCall(2, models.ToolResult{Name: "read_file", Content: "ok"}, 20*time.Millisecond)
+	a.recordToolCall(2, models.ToolResult{Name: "read_file", Content: "stopped", IsCancelled: true}, 0)
+	require.NoError(t, logger.Close())
+
+	stats := strings.Split(handleStats(a, nil), "\n")
+	require.Len(t, stats, 5)
+	assert.Regexp(t, `run_shell\s+2\s+1 \(50%\)\s+4\.0s\s+2\.0s\s+3\.0s\s+2\.0KB`, stats[2], "the slowest tool comes first")
+	assert.Regexp(t, `read_file\s+2\s+0\s+20ms\s+10ms\s+20ms\s+9B`, stats[3])
+	assert.Contains(t, stats[4], "4 calls took 4.0s")
+
+	data, err := os.ReadFile(filepath.Join(home, ".agent", "sessions", "20250101120000.jsonl"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	var record ToolCallRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, ToolCallRecord{Type: "tool", Turn: 2, Iteration: 1, Tool: "run_shell", Status: "error", DurationMS: 1000, Bytes: 6, Time: record.Time}, record)
+}
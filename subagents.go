@@ -0,0 +1,82 @@
+package main
+
+import (
+	"agent/logging"
+	"agent/miniagents"
+	"agent/models"
+	"agent/theme"
+	"agent/tools"
+	"context"
+	"fmt"
+)
+
+// subAgentMaxIterations bounds how many tool rounds a sub-agent may take before it must report
+const subAgentMaxIterations = 30
+
+// subAgentExcludedTools can't be granted to sub-agents: they act on the parent's conversation, task
+// board, or working directory, or would let sub-agents spawn more sub-agents
+var subAgentExcludedTools = map[string]bool{
+	"spawn_agent":           true,
+	"remove_message":        true,
+	"task_add":              true,
+	"task_update":           true,
+	"task_list":             true,
+	"set_working_directory": true,
+}
+
+// spawnSubAgent runs one sub-agent with its own history and live context and returns its report
+func (a *Agent) spawnSubAgent(ctx context.Context, id int, task string, toolNames []string) (string, error) {
+	liveContext := NewLiveContext()
+	liveContext.SetInjectionScan(!a.config.Security.DisableInjectionScan)
+	defer func() {
+		if err := liveContext.Close(); err != nil {
+			logging.Warnf("Failed to stop sub-agent file watcher: %v", err)
+		}
+	}()
+
+	agentTools, err := a.subAgentTools(liveContext, toolNames)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println(theme.DebugText(fmt.Sprintf("🤖 Sub-agent %d started: %s", id, task)))
+	report, err := miniagents.RunSubAgent(ctx, a.currentModel, task, liveContext, agentTools, subAgentMaxIterations, func(name string) {
+		fmt.Println(theme.DebugText(fmt.Sprintf("🤖 Sub-agent %d: %s", id, name)))
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Println(theme.DebugText(fmt.Sprintf("🤖 Sub-agent %d finished", id)))
+	return report, nil
+}
+
+// subAgentTools builds a sub-agent's tool set: context tools bound to its own live context, the
+// read-only tools, and any extra parent tools that were granted
+func (a *Agent) subAgentTools(liveContext tools.LiveContextManager, extra []string) (map[string]models.ToolDefinition, error) {
+	agentTools := tools.NewContextTools(liveContext)
+
+	for _, name := range readOnlyTools {
+		if _, ok := agentTools[name]; ok {
+			continue
+		}
+		if tool, ok := a.tools[name]; ok && !subAgentExcludedTools[name] {
+			agentTools[name] = tool
+		}
+	}
+
+	for _, name := range extra {
+		if _, ok := agentTools[name]; ok {
+			continue
+		}
+		tool, ok := a.tools[name]
+		if !ok || subAgentExcludedTools[name] {
+			return nil, fmt.Errorf("tool '%s' can't be granted to a sub-agent", name)
+		}
+		agentTools[name] = tool
+	}
+
+	for name, tool := range agentTools {
+		agentTools[name] = a.guardTool(tool)
+	}
+	return agentTools, nil
+}
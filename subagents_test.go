@@ -0,0 +1,132 @@
+package main
+
+import (
+	"agent/filters"
+	"agent/models"
+	"agent/permissions"
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubAgentTools(t *testing.T) {
+	parentTools := make(map[string]models.ToolDefinition)
+	for _, name := range []string{"read_file", "git_status", "git_diff", "remove_message", "task_add", "task_list", "write_file", "run_shell", "spawn_agent", "set_working_directory"} {
+		parentTools[name] = models.ToolDefinition{Name: name, Func: func(context.Context, map[string]interface{}) (string, string, error) {
+			return "", "parent " + name, nil
+		}}
+	}
+	a := &Agent{config: &Config{}, tools: parentTools, watchdog: newWatchdog()}
+	a.permissions, _ = permissions.New(t.TempDir(), []string{"deny git_diff"})
+	liveContext := NewLiveContext()
+	defer liveContext.Close()
+
+	agentTools, err := a.subAgentTools(liveContext, []string{"write_file"})
+	require.NoError(t, err)
+	var names []string
+	for name := range agentTools {
+		names = append(names, name)
+	}
+	assert.Contains(t, names, "git_status", "read-only tools are included")
+	assert.Contains(t, names, "write_file", "granted tools are included")
+	assert.NotContains(t, names, "run_shell", "other tools need to be granted")
+	for name := range subAgentExcludedTools {
+		assert.NotContains(t, names, name)
+	}
+
+	// Read-only tools are the parent's, but context tools are the sub-agent's own, bound to its live
+	// context
+	_, result, err := agentTools["git_status"].Func(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "parent git_status", result)
+	assert.NotEqual(t, "parent read_file", callResult(agentTools["read_file"]))
+
+	// The parent's permission rules apply to the sub-agent's calls
+	_, _, err = agentTools["git_diff"].Func(context.Background(), map[string]interface{}{})
+	assert.ErrorContains(t, err, "Permission denied")
+
+	for _, name := range []string{"spawn_agent", "task_add", "set_working_directory", "no_such_tool"} {
+		_, err := a.subAgentTools(liveContext, []string{name})
+		assert.ErrorContains(t, err, "can't be granted to a sub-agent", name)
+	}
+}
+
+func TestSubAgentToolsRunChecks(t *testing.T) {
+	root := t.TempDir()
+	a := newChurnAgent(t, root, ChurnConfig{MaxLines: 2, Action: "approve"}, "n\n")
+	a.redactor, _ = filters.NewSecrets(nil, []string{"hunter2-token"})
+	a.tools["git_status"] = models.ToolDefinition{Name: "git_status", Func: func(context.Context, map[string]interface{}) (string, string, error) {
+		return "", "remote uses hunter2-token", nil
+	}}
+	liveContext := NewLiveContext()
+	defer liveContext.Close()
+	agentTools, err := a.subAgentTools(liveContext, []string{"create_file"})
+	require.NoError(t, err)
+
+	// A sub-agent's writes count toward the turn's churn cap like the agent's own
+	_, _, err = agentTools["create_file"].Func(context.Background(), map[string]interface{}{"path": "a.txt", "content": "one\ntwo\nthree\n"})
+	assert.ErrorContains(t, err, "took this turn past 2 changed lines, so it was reverted")
+	assert.NoFileExists(t, filepath.Join(root, "a.txt"))
+
+	// and its results are redacted
+	_, result, err := agentTools["git_status"].Func(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.NotContains(t, result, "hunter2-token")
+}
+
+func callResult(tool models.ToolDefinition) string {
+	_, result, _ := tool.Func(context.Background(), map[string]interface{}{"path": "missing.txt"})
+	return result
+}
+
+// blockingScanner returns each line sent on lines, counting the reads it was asked for
+type blockingScanner struct {
+	lines chan string
+	mu    sync.Mutex
+	reads int
+	text  string
+}
+
+func (s *blockingScanner) Scan() bool {
+	s.mu.Lock()
+	s.reads++
+	s.mu.Unlock()
+	s.text = <-s.lines
+	return true
+}
+
+func (s *blockingScanner) Text() string { return s.text }
+func (s *blockingScanner) Err() error   { return nil }
+
+func (s *blockingScanner) readCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+func TestAskOneQuestionAtATime(t *testing.T) {
+	input := &blockingScanner{lines: make(chan string)}
+	a := &Agent{input: input}
+
+	answers := make(chan string, 2)
+	ask := func(question string) {
+		answer, _ := a.Ask(question)
+		answers <- question + ": " + answer
+	}
+	go ask("first")
+	require.Eventually(t, func() bool { return input.readCount() == 1 }, time.Second, time.Millisecond)
+	go ask("second")
+	assert.Never(t, func() bool { return input.readCount() > 1 }, 50*time.Millisecond, time.Millisecond, "the second question waits for the first answer")
+
+	input.lines <- "yes"
+	input.lines <- "no"
+	got := []string{<-answers, <-answers}
+	slices.Sort(got)
+	assert.Equal(t, []string{"first: yes", "second: no"}, got)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"agent/theme"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// promptTemplateFile is the name of the system prompt templates in ~/.agent and the project's
+// .agent directory
+const promptTemplateFile = "system_prompt.md"
+
+// promptVariable is a placeholder a system prompt template can use
+type promptVariable struct {
+	Name        string
+	Description string
+	Section     string // the prompt_sections name of the provider filling it, if any
+}
+
+// templateVariables are the placeholders filled by the prompt builder itself rather than a provider
+var templateVariables = []promptVariable{
+	{"{BASE_PROMPT}", "the template this one overrides: the built-in one, or ~/.agent/system_prompt.md for a project template. Use it to extend the base instead of replacing it", ""},
+	{"{MODE_INSTRUCTIONS}", "plan mode and task instructions, when active", ""},
+	{"{LIVE_CONTEXT_DIRECTORIES}", "the directories in live context", ""},
+	{"{LIVE_CONTEXT_FILES}", "the files in live context", ""},
+	{"{CACHE_BREAKPOINT}", "where providers may cache the prompt up to", ""},
+}
+
+// promptTemplateVariables returns every placeholder a template can use, in the order /prompt lists
+// them
+func promptTemplateVariables() []promptVariable {
+	variables := append([]promptVariable(nil), templateVariables...)
+	for _, provider := range promptProviders {
+		variables = append(variables, promptVariable{provider.Variable, provider.Description, provider.Name})
+	}
+	return variables
+}
+
+// requiredPromptVariables carry the live context; a template without them leaves the model blind to
+// the files it reads
+var requiredPromptVariables = []string{"{LIVE_CONTEXT_FILES}", "{LIVE_CONTEXT_DIRECTORIES}"}
+
+var promptVariablePattern = regexp.MustCompile(`\{[A-Z][A-Z0-9_]*\}`)
+
+// promptTemplatePaths returns the user and project system prompt templates, in the order they apply
+func promptTemplatePaths() []string {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".agent", promptTemplateFile))
+	}
+	return append(paths, filepath.Join(".agent", promptTemplateFile))
+}
+
+// loadPromptTemplate applies the templates at paths over the built-in system prompt template, each
+// replacing the one before it or extending it through {BASE_PROMPT}. It returns the template, the
+// paths that were applied, and warnings. A template with unknown variables is skipped.
+func loadPromptTemplate(paths []string) (string, []string, []string) {
+	template := systemPromptTemplate
+	var applied, warnings []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Warning: failed to read %s: %v", path, err))
+			continue
+		}
+		text := string(data)
+		if unknown := unknownPromptVariables(text); len(unknown) > 0 {
+			warnings = append(warnings, fmt.Sprintf("Warning: %s uses unknown variables %s and is ignored until they are fixed; /prompt lists the variables", path, strings.Join(unknown, ", ")))
+			continue
+		}
+		text = strings.ReplaceAll(text, "{BASE_PROMPT}", template)
+		for _, variable := range requiredPromptVariables {
+			if !strings.Contains(text, variable) {
+				warnings = append(warnings, fmt.Sprintf("Warning: %s has no %s, so the model won't see that part of the live context", path, variable))
+			}
+		}
+		template = text
+		applied = append(applied, path)
+	}
+	return template, applied, warnings
+}
+
+// unknownPromptVariables returns the placeholders in text that aren't template variables
+func unknownPromptVariables(text string) []string {
+	known := make(map[string]bool)
+	for _, variable := range promptTemplateVariables() {
+		known[variable.Name] = true
+	}
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, match := range promptVariablePattern.FindAllString(text, -1) {
+		if !known[match] && !seen[match] {
+			unknown = append(unknown, match)
+			seen[match] = true
+		}
+	}
+	return unknown
+}
+
+// systemPromptTemplate returns the template the system prompt is rendered from
+func (a *Agent) systemPromptTemplate() string {
+	if a.promptTemplate != "" {
+		return a.promptTemplate
+	}
+	return systemPromptTemplate
+}
+
+func handlePrompt(a *Agent, args []string) string {
+	if len(args) > 0 && args[0] == "show" {
+		prompt, reference, _ := a.buildSystemPrompt()
+		if reference != "" {
+			prompt += "\n\n" + theme.InfoText("Sent as a message after the history:") + "\n\n" + reference
+		}
+		return prompt
+	}
+	if len(args) > 0 {
+		return theme.ErrorText("Usage: /prompt [show]")
+	}
+
+	var result strings.Builder
+	if len(a.promptTemplateFiles) == 0 {
+		result.WriteString(theme.InfoText("Using the built-in system prompt template.") + "\n")
+	} else {
+		result.WriteString(theme.InfoText(fmt.Sprintf("Using the built-in system prompt template overridden by %s.", strings.Join(a.promptTemplateFiles, ", "))) + "\n")
+	}
+	result.WriteString(theme.InfoText(fmt.Sprintf("Put a template in ~/.agent/%s or .agent/%s to replace it; /reload applies changes. Templates can use:", promptTemplateFile, promptTemplateFile)) + "\n")
+	for _, variable := range promptTemplateVariables() {
+		line := fmt.Sprintf("  %-28s %s", variable.Name, variable.Description)
+		if variable.Section != "" {
+			state := "on"
+			if !a.promptSectionEnabled(variable.Section) {
+				state = "off"
+			}
+			line += fmt.Sprintf(" [%q, %s]", variable.Section, state)
+		}
+		result.WriteString(line + "\n")
+	}
+	result.WriteString(theme.InfoText("Turn sections off with \"prompt_sections\": {\"git\": false}. Use /prompt show to see the prompt the next request will send."))
+	return result.String()
+}
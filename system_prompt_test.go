@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPromptTemplate(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.md")
+	project := filepath.Join(dir, "project.md")
+	missing := filepath.Join(dir, "missing.md")
+
+	// No overrides keep the built-in template
+	template, applied, warnings := loadPromptTemplate([]string{missing})
+	assert.Equal(t, systemPromptTemplate, template)
+	assert.Empty(t, applied)
+	assert.Empty(t, warnings)
+
+	// The user template replaces the built-in one and the project template extends it
+	assert.NoError(t, os.WriteFile(user, []byte("Be terse.\n{LIVE_CONTEXT_DIRECTORIES}\n{LIVE_CONTEXT_FILES}"), 0644))
+	assert.NoError(t, os.WriteFile(project, []byte("{BASE_PROMPT}\nUse tabs."), 0644))
+	template, applied, warnings = loadPromptTemplate([]string{user, project})
+	assert.Equal(t, "Be terse.\n{LIVE_CONTEXT_DIRECTORIES}\n{LIVE_CONTEXT_FILES}\nUse tabs.", template)
+	assert.Equal(t, []string{user, project}, applied)
+	assert.Empty(t, warnings)
+
+	// Unknown variables skip the template; missing live context is a warning
+	assert.NoError(t, os.WriteFile(project, []byte("{BASE_PROMPT}\n{GIT_BRANCH}"), 0644))
+	assert.NoError(t, os.WriteFile(user, []byte("Be terse."), 0644))
+	template, applied, warnings = loadPromptTemplate([]string{user, project})
+	assert.Equal(t, "Be terse.", template)
+	assert.Equal(t, []string{user}, applied)
+	assert.Len(t, warnings, 3)
+	assert.Contains(t, warnings[0], "no {LIVE_CONTEXT_FILES}")
+	assert.Contains(t, warnings[2], "unknown variables {GIT_BRANCH}")
+}
+
+func TestBuiltinPromptTemplateVariables(t *testing.T) {
+	assert.Empty(t, unknownPromptVariables(systemPromptTemplate))
+}
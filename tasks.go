@@ -0,0 +1,338 @@
+package main
+
+import (
+	"agent/tasks"
+	"agent/theme"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TaskRecord logs a task lifecycle event in the session log
+type TaskRecord struct {
+	Type  string      `json:"type"` // always "task"
+	Task  int         `json:"task"`
+	State tasks.State `json:"state"`
+	Note  string      `json:"note,omitempty"`
+	Time  time.Time   `json:"time"`
+}
+
+// ActiveTask returns the task being worked on in this session, if any
+func (a *Agent) ActiveTask() (tasks.Task, bool) {
+	a.mu.RLock()
+	id := a.activeTask
+	a.mu.RUnlock()
+	if id == 0 {
+		return tasks.Task{}, false
+	}
+	return a.tasks.Get(id)
+}
+
+func (a *Agent) setActiveTask(id int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.activeTask = id
+}
+
+// transitionTask moves a task through its lifecycle and reports the event
+func (a *Agent) transitionTask(id int, state tasks.State, note string) (tasks.Task, error) {
+	task, err := a.tasks.Transition(id, state, note, a.sessionLogger.ID)
+	if err != nil {
+		return task, err
+	}
+	a.sessionLogger.LogRecord(TaskRecord{Type: "task", Task: id, State: state, Note: note, Time: time.Now()})
+	fmt.Println(theme.InfoText(fmt.Sprintf("Task %d is now %s", id, state)))
+	return task, nil
+}
+
+// linkCheckpoint records a checkpoint commit on the active task
+func (a *Agent) linkCheckpoint(commit string) {
+	task, ok := a.ActiveTask()
+	if !ok {
+		return
+	}
+	if _, err := a.tasks.AddCheckpoint(task.ID, commit); err != nil {
+		fmt.Println(theme.WarningText(fmt.Sprintf("Failed to link checkpoint to task %d: %v", task.ID, err)))
+	}
+}
+
+// taskInstructions tells the model which task it's working on
+func (a *Agent) taskInstructions() string {
+	task, ok := a.ActiveTask()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("# CURRENT TASK\nYou are working on task %d (%s): %s. Keep your work focused on it.", task.ID, task.State, task.Title)
+}
+
+// taskTitle shortens a prompt to its first line
+func taskTitle(prompt string) string {
+	title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(prompt), "\n", 2)[0])
+	if runes := []rune(title); len(runes) > 60 {
+		title = string(runes[:57]) + "..."
+	}
+	return title
+}
+
+// fetchIssue loads a GitHub issue with the gh CLI
+func fetchIssue(ref string) (title, body, url string, err error) {
+	output, err := exec.Command("gh", "issue", "view", ref, "--json", "title,body,url").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", "", "", fmt.Errorf("gh issue view %s: %s", ref, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", "", "", fmt.Errorf("gh issue view %s: %w", ref, err)
+	}
+	var issue struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		URL   string `json:"url"`
+	}
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return "", "", "", fmt.Errorf("unexpected output from gh: %w", err)
+	}
+	return issue.Title, issue.Body, issue.URL, nil
+}
+
+// resumePrompt restates a task for the model, including what happened in earlier sessions
+func resumePrompt(task tasks.Task) string {
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Work on task %d: %s\n\n%s", task.ID, task.Title, task.Prompt))
+	if task.Issue != "" {
+		prompt.WriteString("\n\nIssue: " + task.Issue)
+	}
+
+	var notes []string
+	for _, event := range task.Events {
+		if event.Note != "" && event.Note != "created" {
+			notes = append(notes, fmt.Sprintf("- %s (%s): %s", event.Time.Format("2006-01-02 15:04"), event.State, event.Note))
+		}
+	}
+	if len(task.Sessions) > 1 || len(notes) > 0 {
+		prompt.WriteString("\n\nThis task was started in an earlier session. Check the current state of the code before continuing.")
+		if len(notes) > 0 {
+			prompt.WriteString("\nHistory:\n" + strings.Join(notes, "\n"))
+		}
+	}
+	return prompt.String()
+}
+
+// taskID parses the task argument, defaulting to the active task
+func (a *Agent) taskID(args []string) (int, error) {
+	if len(args) == 0 {
+		if task, ok := a.ActiveTask(); ok {
+			return task.ID, nil
+		}
+		return 0, fmt.Errorf("no active task; pass a task number")
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid task number %q", args[0])
+	}
+	if _, ok := a.tasks.Get(id); !ok {
+		return 0, fmt.Errorf("task %d does not exist", id)
+	}
+	return id, nil
+}
+
+func handleTasks(a *Agent, args []string) string {
+	if len(args) == 0 {
+		return listTasks(a)
+	}
+
+	switch args[0] {
+	case "new":
+		prompt := strings.Join(args[1:], " ")
+		if strings.TrimSpace(prompt) == "" {
+			return theme.ErrorText("Usage: /tasks new <what to do>")
+		}
+		task, err := a.tasks.Create(taskTitle(prompt), prompt, "", a.sessionLogger.ID)
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to create task: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("Created task %d: %s. Run /tasks start %d to work on it.", task.ID, task.Title, task.ID))
+
+	case "issue":
+		if len(args) != 2 {
+			return theme.ErrorText("Usage: /tasks issue <number|url>")
+		}
+		title, body, url, err := fetchIssue(args[1])
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to fetch issue: %v", err))
+		}
+		task, err := a.tasks.Create(taskTitle(title), title+"\n\n"+body, url, a.sessionLogger.ID)
+		if err != nil {
+			return theme.ErrorText(fmt.Sprintf("Failed to create task: %v", err))
+		}
+		return theme.SuccessText(fmt.Sprintf("Created task %d from %s: %s", task.ID, url, task.Title))
+
+	case "show":
+		id, err := a.taskID(args[1:])
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		task, _ := a.tasks.Get(id)
+		return showTask(task)
+
+	case "start":
+		id, err := a.taskID(args[1:])
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return startTask(a, id)
+
+	case "verify":
+		id, err := a.taskID(args[1:])
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		task, err := a.transitionTask(id, tasks.Verifying, "")
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		a.setActiveTask(id)
+		a.ProcessMessage(fmt.Sprintf("Verify that task %d is complete: %s\n\n%s\n\nRun the project's build and tests and review the changes against the task. Report what passes and anything still missing.", task.ID, task.Title, task.Prompt))
+		return ""
+
+	case "done":
+		id, err := a.taskID(args[1:])
+		if err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		if _, err := a.transitionTask(id, tasks.Done, strings.Join(args[min(2, len(args)):], " ")); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		if task, ok := a.ActiveTask(); ok && task.ID == id {
+			a.setActiveTask(0)
+		}
+		return ""
+
+	case "board":
+		if len(args) > 1 && args[1] == "clear" {
+			a.board.Clear()
+			return theme.SuccessText("Cleared the task board")
+		}
+		return showBoard(a)
+
+	case "note":
+		task, ok := a.ActiveTask()
+		if !ok || len(args) < 2 {
+			return theme.ErrorText("Usage: /tasks note <text> (with an active task)")
+		}
+		if _, err := a.tasks.Note(task.ID, strings.Join(args[1:], " "), a.sessionLogger.ID); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+		return theme.SuccessText(fmt.Sprintf("Noted on task %d", task.ID))
+	}
+
+	return theme.ErrorText("Invalid arguments. Usage: /tasks [new <prompt>|issue <n>|show [n]|start <n>|verify [n]|done [n] [note]|note <text>|board [clear]]")
+}
+
+// startTask makes a task active, links it to the current branch, and hands its prompt to the agent
+func startTask(a *Agent, id int) string {
+	task, _ := a.tasks.Get(id)
+	if task.State != tasks.InProgress {
+		var err error
+		if task, err = a.transitionTask(id, tasks.InProgress, ""); err != nil {
+			return theme.ErrorText(err.Error())
+		}
+	} else if _, err := a.tasks.Note(id, "resumed", a.sessionLogger.ID); err != nil {
+		return theme.ErrorText(err.Error())
+	}
+	a.setActiveTask(id)
+
+	if branch, err := runGit("", "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		if task.Branch == "" {
+			if task, err = a.tasks.SetBranch(id, branch); err != nil {
+				fmt.Println(theme.WarningText(fmt.Sprintf("Failed to link branch: %v", err)))
+			}
+		} else if task.Branch != branch {
+			fmt.Println(theme.WarningText(fmt.Sprintf("Task %d was started on branch %s but you're on %s", id, task.Branch, branch)))
+		}
+	}
+
+	task, _ = a.tasks.Get(id)
+	a.ProcessMessage(resumePrompt(task))
+	return ""
+}
+
+// showBoard renders the model's task board as a checklist, dimming finished steps
+func showBoard(a *Agent) string {
+	items := a.board.Items()
+	if len(items) == 0 {
+		return theme.InfoText("The task board is empty. The model adds steps to it with task_add while it works through a plan.")
+	}
+	var result strings.Builder
+	result.WriteString(theme.InfoText("Task board:") + "\n")
+	for _, line := range strings.Split(strings.TrimSuffix(a.board.Checklist(), "\n"), "\n") {
+		if strings.HasPrefix(line, "[x]") || strings.HasPrefix(line, "[-]") {
+			result.WriteString(theme.DebugText(" "+line) + "\n")
+		} else {
+			result.WriteString(theme.InfoText(" "+line) + "\n")
+		}
+	}
+	return result.String()
+}
+
+func listTasks(a *Agent) string {
+	var result strings.Builder
+	if len(a.board.Items()) > 0 {
+		result.WriteString(showBoard(a) + "\n")
+	}
+
+	list := a.tasks.List()
+	if len(list) == 0 {
+		result.WriteString(theme.InfoText("No tasks yet. Create one with /tasks new <prompt> or /tasks issue <number>."))
+		return result.String()
+	}
+
+	active, _ := a.ActiveTask()
+	result.WriteString(theme.InfoText("Tasks:") + "\n")
+	for _, task := range list {
+		marker := " "
+		if task.ID == active.ID {
+			marker = "*"
+		}
+		line := fmt.Sprintf(" %s %d. [%s] %s", marker, task.ID, task.State, task.Title)
+		if task.Branch != "" {
+			line += " (" + task.Branch + ")"
+		}
+		if task.State == tasks.Done {
+			result.WriteString(theme.DebugText(line) + "\n")
+		} else {
+			result.WriteString(theme.InfoText(line) + "\n")
+		}
+	}
+	return result.String()
+}
+
+func showTask(task tasks.Task) string {
+	var result strings.Builder
+	result.WriteString(theme.InfoText(fmt.Sprintf("Task %d: %s [%s]", task.ID, task.Title, task.State)) + "\n")
+	if task.Issue != "" {
+		result.WriteString(theme.InfoText("Issue: "+task.Issue) + "\n")
+	}
+	if task.Branch != "" {
+		result.WriteString(theme.InfoText("Branch: "+task.Branch) + "\n")
+	}
+	if len(task.Checkpoints) > 0 {
+		short := make([]string, len(task.Checkpoints))
+		for i, commit := range task.Checkpoints {
+			short[i] = commit[:min(8, len(commit))]
+		}
+		result.WriteString(theme.InfoText("Checkpoints: "+strings.Join(short, ", ")) + "\n")
+	}
+	result.WriteString(theme.InfoText(fmt.Sprintf("Sessions: %d", len(task.Sessions))) + "\n")
+	result.WriteString(theme.InfoText("Events:") + "\n")
+	for _, event := range task.Events {
+		line := fmt.Sprintf("  %s %s", event.Time.Format("2006-01-02 15:04"), event.State)
+		if event.Note != "" {
+			line += " - " + event.Note
+		}
+		result.WriteString(theme.InfoText(line) + "\n")
+	}
+	return result.String()
+}
@@ -0,0 +1,252 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// State is where a task is in its lifecycle
+type State string
+
+const (
+	Planned    State = "planned"
+	InProgress State = "in-progress"
+	Verifying  State = "verifying"
+	Done       State = "done"
+)
+
+// States lists the lifecycle in order
+var States = []State{Planned, InProgress, Verifying, Done}
+
+// transitions are the states each state may move to. Verifying can fall back to in-progress when
+// checks fail, and done tasks can be reopened.
+var transitions = map[State][]State{
+	Planned:    {InProgress, Done},
+	InProgress: {Verifying, Done, Planned},
+	Verifying:  {InProgress, Done},
+	Done:       {InProgress},
+}
+
+// ParseState accepts a state name
+func ParseState(name string) (State, error) {
+	for _, state := range States {
+		if string(state) == name {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("unknown task state %q (want one of %v)", name, States)
+}
+
+// Event is a lifecycle change, or a note, recorded on a task
+type Event struct {
+	Time    time.Time `json:"time"`
+	State   State     `json:"state"`
+	Note    string    `json:"note,omitempty"`
+	Session string    `json:"session,omitempty"`
+}
+
+// Task is a unit of work that can span several sessions
+type Task struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Prompt      string    `json:"prompt"`
+	Issue       string    `json:"issue,omitempty"` // URL of the issue the task was created from
+	State       State     `json:"state"`
+	Branch      string    `json:"branch,omitempty"`
+	Checkpoints []string  `json:"checkpoints,omitempty"` // checkpoint commits taken while the task was active
+	Sessions    []string  `json:"sessions,omitempty"`
+	Events      []Event   `json:"events"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Store persists tasks to a JSON file so they survive across sessions
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	tasks []*Task
+}
+
+// NewStore opens the task store at path, loading it if it exists. The file is created on the
+// first change. When the file can't be read, the store is returned empty along with the error, and
+// changes fail until the file is fixed rather than overwriting it.
+func NewStore(path string) (*Store, error) {
+	store := &Store{path: path}
+	return store, store.load()
+}
+
+// load rereads the file, so changes made by other sessions aren't lost when this one saves. The
+// tasks loaded last are kept when it fails. Callers hold mu.
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read tasks: %w", err)
+	}
+	var tasks []*Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("%s is not a valid task list; fix or remove it: %w", s.path, err)
+	}
+	s.tasks = tasks
+	return nil
+}
+
+// Create adds a planned task
+func (s *Store) Create(title, prompt, issue, session string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return Task{}, err
+	}
+
+	id := 1
+	if len(s.tasks) > 0 {
+		id = s.tasks[len(s.tasks)-1].ID + 1
+	}
+	now := time.Now()
+	task := &Task{
+		ID:      id,
+		Title:   title,
+		Prompt:  prompt,
+		Issue:   issue,
+		State:   Planned,
+		Events:  []Event{{Time: now, State: Planned, Note: "created", Session: session}},
+		Created: now,
+		Updated: now,
+	}
+	s.tasks = append(s.tasks, task)
+	return *task, s.save()
+}
+
+// Get returns the task with the given ID
+func (s *Store) Get(id int) (Task, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.load() // NewStore and changes report a bad file
+	if task := s.find(id); task != nil {
+		return clone(task), true
+	}
+	return Task{}, false
+}
+
+// List returns every task in the order they were created
+func (s *Store) List() []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.load() // NewStore and changes report a bad file
+	list := make([]Task, len(s.tasks))
+	for i, task := range s.tasks {
+		list[i] = clone(task)
+	}
+	return list
+}
+
+// Transition moves a task to state and records the event. Moving to the current state is an error
+// so callers notice no-op commands.
+func (s *Store) Transition(id int, state State, note, session string) (Task, error) {
+	return s.update(id, func(task *Task) error {
+		if !slices.Contains(transitions[task.State], state) {
+			return fmt.Errorf("task %d is %s and can't move to %s", id, task.State, state)
+		}
+		task.State = state
+		task.Events = append(task.Events, Event{Time: time.Now(), State: state, Note: note, Session: session})
+		if session != "" && !slices.Contains(task.Sessions, session) {
+			task.Sessions = append(task.Sessions, session)
+		}
+		return nil
+	})
+}
+
+// Note records an event without changing the task's state
+func (s *Store) Note(id int, note, session string) (Task, error) {
+	return s.update(id, func(task *Task) error {
+		task.Events = append(task.Events, Event{Time: time.Now(), State: task.State, Note: note, Session: session})
+		return nil
+	})
+}
+
+// SetBranch links the task to a git branch
+func (s *Store) SetBranch(id int, branch string) (Task, error) {
+	return s.update(id, func(task *Task) error {
+		task.Branch = branch
+		return nil
+	})
+}
+
+// AddCheckpoint links a checkpoint commit to the task
+func (s *Store) AddCheckpoint(id int, commit string) (Task, error) {
+	return s.update(id, func(task *Task) error {
+		task.Checkpoints = append(task.Checkpoints, commit)
+		return nil
+	})
+}
+
+func (s *Store) update(id int, change func(task *Task) error) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return Task{}, err
+	}
+
+	task := s.find(id)
+	if task == nil {
+		return Task{}, fmt.Errorf("task %d does not exist", id)
+	}
+	if err := change(task); err != nil {
+		return Task{}, err
+	}
+	task.Updated = time.Now()
+	return clone(task), s.save()
+}
+
+func (s *Store) find(id int) *Task {
+	for _, task := range s.tasks {
+		if task.ID == id {
+			return task
+		}
+	}
+	return nil
+}
+
+// save writes the store atomically, through a temporary file of its own so sessions saving at the
+// same time don't write into each other's; callers hold mu
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create tasks directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save tasks: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save tasks: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func clone(task *Task) Task {
+	copied := *task
+	copied.Checkpoints = slices.Clone(task.Checkpoints)
+	copied.Sessions = slices.Clone(task.Sessions)
+	copied.Events = slices.Clone(task.Events)
+	return copied
+}
@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".agent", "tasks.json")
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	task, err := store.Create("Add retries", "Retry failed uploads", "", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, task.ID)
+	assert.Equal(t, Planned, task.State)
+
+	_, err = store.Transition(1, Verifying, "", "s1")
+	assert.ErrorContains(t, err, "can't move to verifying")
+
+	_, err = store.Transition(1, InProgress, "", "s1")
+	require.NoError(t, err)
+	_, err = store.Transition(1, Verifying, "tests added", "s2")
+	require.NoError(t, err)
+	_, err = store.Transition(1, InProgress, "tests fail", "s2")
+	require.NoError(t, err)
+	_, err = store.AddCheckpoint(1, "abc123")
+	require.NoError(t, err)
+	_, err = store.SetBranch(1, "retries")
+	require.NoError(t, err)
+
+	_, err = store.Transition(2, Done, "", "s2")
+	assert.ErrorContains(t, err, "does not exist")
+
+	// Everything survives a reload
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+	task, ok := reopened.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, InProgress, task.State)
+	assert.Equal(t, []string{"s1", "s2"}, task.Sessions)
+	assert.Equal(t, []string{"abc123"}, task.Checkpoints)
+	assert.Equal(t, "retries", task.Branch)
+	assert.Len(t, task.Events, 4)
+	assert.Equal(t, "tests added", task.Events[2].Note)
+
+	second, err := reopened.Create("Docs", "Document retries", "https://example.com/issues/2", "s2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+}
+
+func TestCorruptStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id": 1,`), 0644))
+
+	store, err := NewStore(path)
+	assert.ErrorContains(t, err, "not a valid task list")
+	_, err = store.Create("Docs", "Document retries", "", "s1")
+	assert.ErrorContains(t, err, "not a valid task list")
+
+	// The file is left for the user to fix
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id": 1,`, string(data))
+}
+
+func TestConcurrentStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	first, err := NewStore(path)
+	require.NoError(t, err)
+	second, err := NewStore(path)
+	require.NoError(t, err)
+
+	_, err = first.Create("Add retries", "Retry failed uploads", "", "s1")
+	require.NoError(t, err)
+	task, err := second.Create("Docs", "Document retries", "", "s2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, task.ID)
+	_, err = first.Transition(2, InProgress, "", "s1")
+	require.NoError(t, err)
+
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+	tasks := reopened.List()
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Add retries", tasks[0].Title)
+	assert.Equal(t, InProgress, tasks[1].State)
+}
+
+func TestParseState(t *testing.T) {
+	state, err := ParseState("in-progress")
+	assert.NoError(t, err)
+	assert.Equal(t, InProgress, state)
+
+	_, err = ParseState("blocked")
+	assert.Error(t, err)
+}
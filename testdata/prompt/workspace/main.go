@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+
+	"fixture/pkg"
+)
+
+func main() {
+	fmt.Println(pkg.Greeting("world"))
+}
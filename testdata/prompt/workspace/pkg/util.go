@@ -0,0 +1,6 @@
+package pkg
+
+// Greeting returns a friendly greeting
+func Greeting(name string) string {
+	return "hello, " + name
+}
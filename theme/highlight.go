@@ -0,0 +1,130 @@
+package theme
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// presetHighlightStyles pairs each theme preset with a chroma style that suits its background
+var presetHighlightStyles = map[string]string{
+	"dark":      "monokai",
+	"light":     "github",
+	"solarized": "solarized-dark",
+}
+
+var highlightConfig = struct {
+	sync.RWMutex
+	disabled bool
+	style    string // chroma style name; empty follows the theme preset
+}{}
+
+// ConfigureHighlighting turns syntax highlighting off or picks the chroma style
+// (https://xyproto.github.io/splash/docs/) used instead of the theme's default
+func ConfigureHighlighting(disabled bool, style string) {
+	highlightConfig.Lock()
+	defer highlightConfig.Unlock()
+	highlightConfig.disabled = disabled
+	highlightConfig.style = style
+}
+
+// highlightingEnabled reports whether highlighting is on and the terminal can show it
+func highlightingEnabled() bool {
+	highlightConfig.RLock()
+	disabled := highlightConfig.disabled
+	highlightConfig.RUnlock()
+	return !disabled && ColorEnabled()
+}
+
+// Highlight colors code in language (a fenced code block's tag, e.g. "go" or "python"). It returns
+// false when highlighting is off or the language isn't recognized.
+func Highlight(code, language string) (string, bool) {
+	if language == "" || !highlightingEnabled() {
+		return "", false
+	}
+	return highlight(code, lexers.Get(language))
+}
+
+// HighlightFile colors code using the language implied by path's file name
+func HighlightFile(code, path string) (string, bool) {
+	if path == "" || !highlightingEnabled() {
+		return "", false
+	}
+	return highlight(code, lexers.Match(filepath.Base(path)))
+}
+
+// HighlightFileLines colors a whole file at once and returns it line by line, each line carrying its
+// own colors, so lines can be shown apart from the rest, as diffs do. Tokens spanning lines, like
+// block comments, are colored as they are in the file.
+func HighlightFileLines(code, path string) ([]string, bool) {
+	if path == "" || !highlightingEnabled() {
+		return nil, false
+	}
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		return nil, false
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return nil, false
+	}
+
+	formatter, style := highlightFormatter(), highlightStyle()
+	var lines []string
+	for _, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		if last := len(tokens) - 1; last >= 0 {
+			tokens[last].Value = strings.TrimSuffix(tokens[last].Value, "\n")
+		}
+		var line strings.Builder
+		if err := formatter.Format(&line, style, chroma.Literator(tokens...)); err != nil {
+			return nil, false
+		}
+		lines = append(lines, line.String())
+	}
+	return lines, true
+}
+
+func highlight(code string, lexer chroma.Lexer) (string, bool) {
+	if lexer == nil {
+		return "", false
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", false
+	}
+
+	var result strings.Builder
+	if err := highlightFormatter().Format(&result, highlightStyle(), iterator); err != nil {
+		return "", false
+	}
+	return result.String(), true
+}
+
+func highlightStyle() *chroma.Style {
+	highlightConfig.RLock()
+	name := highlightConfig.style
+	highlightConfig.RUnlock()
+	if name == "" {
+		name = presetHighlightStyles[Current()]
+	}
+	return styles.Get(name)
+}
+
+// highlightFormatter matches the terminal's color support
+func highlightFormatter() chroma.Formatter {
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		return formatters.Get("terminal16m")
+	case termenv.ANSI256:
+		return formatters.Get("terminal256")
+	default:
+		return formatters.Get("terminal16")
+	}
+}
@@ -0,0 +1,44 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlight(t *testing.T) {
+	// Tests don't run in a terminal, so highlighting starts off
+	_, ok := Highlight("package main", "go")
+	assert.False(t, ok)
+
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	highlighted, ok := Highlight("package main", "go")
+	assert.True(t, ok)
+	assert.Contains(t, highlighted, "\x1b[")
+
+	_, ok = HighlightFile("x = 1", "script.py")
+	assert.True(t, ok)
+	_, ok = Highlight("???", "not-a-language")
+	assert.False(t, ok)
+
+	// Each line carries its own colors, even inside a comment spanning lines
+	lines, ok := HighlightFileLines("/* one\ntwo */\nx := 1\n", "main.go")
+	assert.True(t, ok)
+	assert.Len(t, lines, 3)
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "\x1b["), "line %q starts with its color", line)
+		assert.NotContains(t, line, "\n")
+	}
+	assert.Contains(t, lines[1], "two */")
+
+	ConfigureHighlighting(true, "")
+	t.Cleanup(func() { ConfigureHighlighting(false, "") })
+	_, ok = Highlight("package main", "go")
+	assert.False(t, ok)
+}
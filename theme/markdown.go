@@ -0,0 +1,399 @@
+package theme
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.TaskList))
+
+var (
+	listItemPattern = regexp.MustCompile(`^\s*([-+*]|\d{1,9}[.)])(\s|$)`)
+	fencePattern    = regexp.MustCompile("^\\s*(`{3,}|~{3,})")
+)
+
+// bullets are used for unordered list items by nesting depth
+var bullets = []string{"•", "◦", "▪"}
+
+// MarkdownRenderer renders streamed CommonMark (plus GFM tables, strikethrough, and task lists) to
+// the terminal. Text is buffered until a block is complete, then the block is parsed and printed,
+// so emphasis, lists, and tables that span tokens render correctly.
+type MarkdownRenderer struct {
+	out     io.Writer
+	line    strings.Builder // the current incomplete line
+	pending strings.Builder // complete lines of the current block
+	fence   string          // opening fence of the code block being buffered, if any
+	blank   bool            // the pending block ended with a blank line
+	wrote   bool            // a block has been printed, so the next one needs a separator
+}
+
+// NewMarkdownRenderer creates a new streaming markdown renderer that prints to stdout
+func NewMarkdownRenderer() *MarkdownRenderer {
+	return newMarkdownRenderer(os.Stdout)
+}
+
+func newMarkdownRenderer(out io.Writer) *MarkdownRenderer {
+	return &MarkdownRenderer{out: out}
+}
+
+// Write buffers incoming markdown tokens and prints each block once it's complete
+func (mr *MarkdownRenderer) Write(data []byte) {
+	for _, char := range string(data) {
+		if char == '\n' {
+			mr.processLine(mr.line.String())
+			mr.line.Reset()
+		} else {
+			mr.line.WriteRune(char)
+		}
+	}
+}
+
+// Flush prints everything buffered, including an unfinished block
+func (mr *MarkdownRenderer) Flush() {
+	if mr.line.Len() > 0 {
+		mr.pending.WriteString(mr.line.String() + "\n")
+		mr.line.Reset()
+	}
+	mr.fence = ""
+	mr.renderPending()
+}
+
+// processLine adds a complete line to the pending block, printing the block when the line ends it
+func (mr *MarkdownRenderer) processLine(line string) {
+	if mr.fence != "" {
+		mr.pending.WriteString(line + "\n")
+		if closesFence(line, mr.fence) {
+			mr.fence = ""
+			mr.renderPending()
+		}
+		return
+	}
+
+	if strings.TrimSpace(line) == "" {
+		if mr.pending.Len() > 0 {
+			mr.blank = true
+			mr.pending.WriteString("\n")
+		}
+		return
+	}
+
+	// A blank line ends a block unless the next line continues it: an indented line belongs to the
+	// previous list item, and another list item continues a loose list
+	if mr.blank && !mr.continuesBlock(line) {
+		mr.renderPending()
+	}
+	mr.blank = false
+
+	if match := fencePattern.FindStringSubmatch(line); match != nil {
+		mr.fence = match[1]
+	}
+	mr.pending.WriteString(line + "\n")
+}
+
+func (mr *MarkdownRenderer) continuesBlock(line string) bool {
+	if line[0] == ' ' || line[0] == '\t' {
+		return true
+	}
+	return listItemPattern.MatchString(mr.pending.String()) && listItemPattern.MatchString(line)
+}
+
+// closesFence reports whether line closes a code block opened with fence
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+func (mr *MarkdownRenderer) renderPending() {
+	source := mr.pending.String()
+	mr.pending.Reset()
+	mr.blank = false
+	if strings.TrimSpace(source) == "" {
+		return
+	}
+
+	if mr.wrote {
+		fmt.Fprint(mr.out, "\n\n")
+	}
+	fmt.Fprint(mr.out, RenderMarkdown(source))
+	mr.wrote = true
+}
+
+// RenderMarkdown renders a complete markdown document for the terminal
+func RenderMarkdown(source string) string {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+	r := &blockRenderer{source: src}
+	return r.children(doc, "\n\n")
+}
+
+// blockRenderer walks a parsed document and renders each block to styled text
+type blockRenderer struct {
+	source []byte
+	depth  int // list nesting depth
+}
+
+func (r *blockRenderer) children(node ast.Node, separator string) string {
+	var blocks []string
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if block := r.block(child); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, separator)
+}
+
+func (r *blockRenderer) block(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		return r.inlines(n, inlineStyle{})
+	case *ast.Heading:
+		return r.inlines(n, inlineStyle{heading: true, bold: true, prefix: strings.Repeat("#", n.Level) + " "})
+	case *ast.ThematicBreak:
+		return DebugText(strings.Repeat("─", 40))
+	case *ast.FencedCodeBlock:
+		return highlightCode(string(n.Lines().Value(r.source)), string(n.Language(r.source)))
+	case *ast.CodeBlock:
+		return highlightCode(string(n.Lines().Value(r.source)), "")
+	case *ast.HTMLBlock:
+		return strings.TrimRight(string(n.Lines().Value(r.source)), "\n")
+	case *ast.Blockquote:
+		return prefixLines(r.children(n, "\n\n"), DebugText("│ "), DebugText("│ "))
+	case *ast.List:
+		return r.list(n)
+	case *east.Table:
+		return r.table(n)
+	}
+	return r.children(node, "\n\n")
+}
+
+func (r *blockRenderer) list(list *ast.List) string {
+	separator := "\n\n"
+	if list.IsTight {
+		separator = "\n"
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+
+	var items []string
+	number := list.Start
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := bullets[min(r.depth-1, len(bullets)-1)]
+		if list.IsOrdered() {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		body := r.children(item, separator)
+		items = append(items, prefixLines(body, marker+" ", strings.Repeat(" ", lipgloss.Width(marker)+1)))
+	}
+	return strings.Join(items, separator)
+}
+
+func (r *blockRenderer) table(table *east.Table) string {
+	var rows [][]string
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			style := inlineStyle{}
+			if _, header := row.(*east.TableHeader); header {
+				style.bold = true
+			}
+			cells = append(cells, r.inlines(cell, style))
+		}
+		rows = append(rows, cells)
+	}
+
+	columns := len(table.Alignments)
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < columns {
+				widths[i] = max(widths[i], lipgloss.Width(cell))
+			}
+		}
+	}
+
+	border := func(left, middle, right string) string {
+		parts := make([]string, columns)
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width+2)
+		}
+		return DebugText(left + strings.Join(parts, middle) + right)
+	}
+
+	var lines []string
+	lines = append(lines, border("┌", "┬", "┐"))
+	for i, row := range rows {
+		line := DebugText("│")
+		for column := 0; column < columns; column++ {
+			cell := ""
+			if column < len(row) {
+				cell = row[column]
+			}
+			line += " " + alignCell(cell, widths[column], table.Alignments[column]) + " " + DebugText("│")
+		}
+		lines = append(lines, line)
+		if i == 0 && len(rows) > 1 {
+			lines = append(lines, border("├", "┼", "┤"))
+		}
+	}
+	lines = append(lines, border("└", "┴", "┘"))
+	return strings.Join(lines, "\n")
+}
+
+func alignCell(cell string, width int, alignment east.Alignment) string {
+	padding := width - lipgloss.Width(cell)
+	switch alignment {
+	case east.AlignRight:
+		return strings.Repeat(" ", padding) + cell
+	case east.AlignCenter:
+		return strings.Repeat(" ", padding/2) + cell + strings.Repeat(" ", padding-padding/2)
+	}
+	return cell + strings.Repeat(" ", padding)
+}
+
+// inlineStyle accumulates the emphasis that applies to nested inline content
+type inlineStyle struct {
+	heading   bool
+	bold      bool
+	italic    bool
+	strike    bool
+	underline bool
+	prefix    string // text rendered before the first child, e.g. a heading's #s
+}
+
+// render applies the accumulated style to a run of plain text. Each run is styled on its own so
+// nested emphasis doesn't reset the styles around it.
+func (s inlineStyle) render(text string) string {
+	if !s.bold && !s.italic && !s.strike && !s.underline {
+		return text
+	}
+	style := lipgloss.NewStyle()
+	if s.bold {
+		style = themeStyle(StyleInfo).Bold(true)
+	}
+	return style.Italic(s.italic).Strikethrough(s.strike).Underline(s.underline).Render(text)
+}
+
+func (r *blockRenderer) inlines(node ast.Node, style inlineStyle) string {
+	var result strings.Builder
+	if style.prefix != "" {
+		result.WriteString(style.render(style.prefix))
+		style.prefix = ""
+	}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		result.WriteString(r.inline(child, style))
+	}
+	return result.String()
+}
+
+func (r *blockRenderer) inline(node ast.Node, style inlineStyle) string {
+	switch n := node.(type) {
+	case *ast.Text:
+		rendered := style.render(string(n.Value(r.source)))
+		if n.SoftLineBreak() || n.HardLineBreak() {
+			rendered += "\n"
+		}
+		return rendered
+	case *ast.String:
+		return style.render(string(n.Value))
+	case *ast.CodeSpan:
+		var code strings.Builder
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			if t, ok := child.(*ast.Text); ok {
+				code.Write(t.Value(r.source))
+			} else if s, ok := child.(*ast.String); ok {
+				code.Write(s.Value)
+			}
+		}
+		return CodeText(code.String())
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			style.bold = true
+		} else {
+			style.italic = true
+		}
+		return r.inlines(n, style)
+	case *east.Strikethrough:
+		style.strike = true
+		return r.inlines(n, style)
+	case *ast.Link:
+		style.underline = true
+		label := r.inlines(n, style)
+		if destination := string(n.Destination); destination != "" && destination != plainText(n, r.source) {
+			label += DebugText(" (" + destination + ")")
+		}
+		return label
+	case *ast.AutoLink:
+		style.underline = true
+		return style.render(string(n.URL(r.source)))
+	case *ast.Image:
+		return DebugText(fmt.Sprintf("[image: %s] (%s)", plainText(n, r.source), n.Destination))
+	case *ast.RawHTML:
+		var html strings.Builder
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			html.Write(segment.Value(r.source))
+		}
+		return html.String()
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "☑ "
+		}
+		return "☐ "
+	}
+	return r.inlines(node, style)
+}
+
+// plainText returns the unstyled text of an inline node's children
+func plainText(node ast.Node, source []byte) string {
+	var result strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			result.Write(n.Value(source))
+		case *ast.String:
+			result.Write(n.Value)
+		default:
+			result.WriteString(plainText(child, source))
+		}
+	}
+	return result.String()
+}
+
+// highlightCode renders a fenced code block, syntax highlighted and labeled with its language when
+// it has one
+func highlightCode(code, language string) string {
+	code = strings.TrimRight(code, "\n")
+	rendered, ok := Highlight(code, language)
+	if !ok {
+		rendered = StyledText(code, StyleCodeBlock)
+	}
+	if language != "" {
+		rendered = DebugText(language) + "\n" + rendered
+	}
+	return rendered
+}
+
+// prefixLines prefixes the first line of text with first and the remaining lines with rest
+func prefixLines(text, first, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else if line != "" {
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
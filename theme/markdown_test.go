@@ -0,0 +1,56 @@
+package theme
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// render streams source through a renderer in small chunks, as tokens arrive from a model
+func render(source string, chunkSize int) string {
+	var out bytes.Buffer
+	renderer := newMarkdownRenderer(&out)
+	for start := 0; start < len(source); start += chunkSize {
+		renderer.Write([]byte(source[start:min(start+chunkSize, len(source))]))
+	}
+	renderer.Flush()
+	return out.String()
+}
+
+func TestMarkdownRendererBlocks(t *testing.T) {
+	source := "# Title\n\nSome **bold *and italic*** text with `code` and [a link](https://example.com).\n\n" +
+		"- one\n- two\n  - nested\n\n" +
+		"3. third\n4. fourth\n\n" +
+		"> quoted\n\n" +
+		"```go\nfunc main() {\n\n}\n```\n\n" +
+		"| Name | Size |\n|:-----|-----:|\n| a | 1 |\n| bb | 22 |\n"
+
+	expected := "# Title\n\n" +
+		"Some bold and italic text with code and a link (https://example.com).\n\n" +
+		"• one\n• two\n  ◦ nested\n\n" +
+		"3. third\n4. fourth\n\n" +
+		"│ quoted\n\n" +
+		"go\nfunc main() {\n\n}\n\n" +
+		"┌──────┬──────┐\n" +
+		"│ Name │ Size │\n" +
+		"├──────┼──────┤\n" +
+		"│ a    │    1 │\n" +
+		"│ bb   │   22 │\n" +
+		"└──────┴──────┘"
+
+	for _, chunkSize := range []int{1, 3, 7, len(source)} {
+		assert.Equal(t, expected, render(source, chunkSize), "chunk size %d", chunkSize)
+	}
+}
+
+func TestMarkdownRendererLooseLists(t *testing.T) {
+	source := "1. first\n\n   more about first\n\n2. second\n\nAfter the list."
+	assert.Equal(t, "1. first\n\n   more about first\n\n2. second\n\nAfter the list.", render(source, 2))
+}
+
+func TestMarkdownRendererUnfinished(t *testing.T) {
+	// A stream cut off mid-block still prints what arrived
+	assert.Equal(t, "Partial **bold", render("Partial **bold", 4))
+	assert.Equal(t, "code", render("```\ncode\n", 4))
+}
@@ -0,0 +1,186 @@
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StyleSpec describes a style in the theme file. Colors are ANSI numbers ("6") or hex ("#2aa198");
+// padding takes 1, 2, or 4 values like CSS.
+type StyleSpec struct {
+	Foreground string `json:"foreground,omitempty"`
+	Background string `json:"background,omitempty"`
+	Padding    []int  `json:"padding,omitempty"`
+}
+
+// ThemeFile is the format of ~/.agent/theme.json
+type ThemeFile struct {
+	Preset string               `json:"preset"` // dark (default), light, or solarized
+	Styles map[string]StyleSpec `json:"styles"` // overrides applied on top of every preset, by style name
+}
+
+// styleNames are the names used for styles in the theme file
+var styleNames = map[string]StyleType{
+	"prompt":     StylePrompt,
+	"success":    StyleSuccess,
+	"error":      StyleError,
+	"warning":    StyleWarning,
+	"info":       StyleInfo,
+	"tool":       StyleTool,
+	"command":    StyleCommand,
+	"debug":      StyleDebug,
+	"agent":      StyleAgent,
+	"user":       StyleUser,
+	"code":       StyleCode,
+	"code_block": StyleCodeBlock,
+}
+
+var presets = map[string]map[StyleType]StyleSpec{
+	"dark": {
+		StylePrompt:    {Foreground: "13"},
+		StyleSuccess:   {Foreground: "2"},
+		StyleError:     {Foreground: "1"},
+		StyleWarning:   {Foreground: "3"},
+		StyleInfo:      {Foreground: "6"},
+		StyleTool:      {Foreground: "12"},
+		StyleCommand:   {Foreground: "#ffffff", Background: "#111111", Padding: []int{2, 4}},
+		StyleDebug:     {Foreground: "8"},
+		StyleAgent:     {Background: "#232e23", Padding: []int{1, 2}},
+		StyleUser:      {Background: "#3d2d35", Padding: []int{1, 2}},
+		StyleCode:      {Foreground: "2", Background: "0"},
+		StyleCodeBlock: {Foreground: "2", Background: "8"},
+	},
+	"light": {
+		StylePrompt:    {Foreground: "#8e24aa"},
+		StyleSuccess:   {Foreground: "#2e7d32"},
+		StyleError:     {Foreground: "#c62828"},
+		StyleWarning:   {Foreground: "#e65100"},
+		StyleInfo:      {Foreground: "#00838f"},
+		StyleTool:      {Foreground: "#1565c0"},
+		StyleCommand:   {Foreground: "#111111", Background: "#eeeeee", Padding: []int{2, 4}},
+		StyleDebug:     {Foreground: "#757575"},
+		StyleAgent:     {Background: "#e8f5e9", Padding: []int{1, 2}},
+		StyleUser:      {Background: "#fce4ec", Padding: []int{1, 2}},
+		StyleCode:      {Foreground: "#2e7d32", Background: "#f5f5f5"},
+		StyleCodeBlock: {Foreground: "#1b5e20", Background: "#eeeeee"},
+	},
+	"solarized": {
+		StylePrompt:    {Foreground: "#d33682"},
+		StyleSuccess:   {Foreground: "#859900"},
+		StyleError:     {Foreground: "#dc322f"},
+		StyleWarning:   {Foreground: "#b58900"},
+		StyleInfo:      {Foreground: "#2aa198"},
+		StyleTool:      {Foreground: "#268bd2"},
+		StyleCommand:   {Foreground: "#93a1a1", Background: "#002b36", Padding: []int{2, 4}},
+		StyleDebug:     {Foreground: "#586e75"},
+		StyleAgent:     {Background: "#073642", Padding: []int{1, 2}},
+		StyleUser:      {Background: "#002b36", Padding: []int{1, 2}},
+		StyleCode:      {Foreground: "#859900", Background: "#073642"},
+		StyleCodeBlock: {Foreground: "#859900", Background: "#073642"},
+	},
+}
+
+// Presets returns the names of the built-in themes
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Current returns the name of the active preset
+func Current() string {
+	if current := theme.Load(); current != nil {
+		return current.name
+	}
+	return ""
+}
+
+// SetTheme switches to the named preset with the theme file's overrides applied
+func SetTheme(name string) error {
+	preset, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %v)", name, Presets())
+	}
+	file, err := loadThemeFile()
+	if err != nil {
+		return err
+	}
+	next, err := buildTheme(name, preset, file.Styles)
+	if err != nil {
+		return err
+	}
+	theme.Store(next)
+	return nil
+}
+
+func buildTheme(name string, preset map[StyleType]StyleSpec, overrides map[string]StyleSpec) (*Theme, error) {
+	specs := make(map[StyleType]StyleSpec, len(preset))
+	for styleType, spec := range preset {
+		specs[styleType] = spec
+	}
+	for styleName, override := range overrides {
+		styleType, ok := styleNames[styleName]
+		if !ok {
+			return nil, fmt.Errorf("unknown style %q in theme file", styleName)
+		}
+		spec := specs[styleType]
+		if override.Foreground != "" {
+			spec.Foreground = override.Foreground
+		}
+		if override.Background != "" {
+			spec.Background = override.Background
+		}
+		if override.Padding != nil {
+			spec.Padding = override.Padding
+		}
+		specs[styleType] = spec
+	}
+
+	t := &Theme{name: name, styles: make(map[StyleType]lipgloss.Style, len(specs))}
+	for styleType, spec := range specs {
+		style := lipgloss.NewStyle()
+		if spec.Foreground != "" {
+			style = style.Foreground(lipgloss.Color(spec.Foreground))
+		}
+		if spec.Background != "" {
+			style = style.Background(lipgloss.Color(spec.Background))
+		}
+		switch len(spec.Padding) {
+		case 0:
+		case 1, 2, 4:
+			style = style.Padding(spec.Padding...)
+		default:
+			return nil, fmt.Errorf("padding takes 1, 2, or 4 values, got %v", spec.Padding)
+		}
+		t.styles[styleType] = style
+	}
+	return t, nil
+}
+
+// loadThemeFile reads ~/.agent/theme.json; a missing file is an empty theme file
+func loadThemeFile() (ThemeFile, error) {
+	var file ThemeFile
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return file, nil
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".agent", "theme.json"))
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ThemeFile{}, fmt.Errorf("invalid theme.json: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,71 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresetsDefineEveryStyle(t *testing.T) {
+	for _, name := range Presets() {
+		for styleName, styleType := range styleNames {
+			_, ok := presets[name][styleType]
+			assert.True(t, ok, "preset %s is missing style %s", name, styleName)
+		}
+	}
+}
+
+func TestBuildThemeOverrides(t *testing.T) {
+	built, err := buildTheme("dark", presets["dark"], map[string]StyleSpec{
+		"info":  {Foreground: "#123456"},
+		"agent": {Padding: []int{0}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dark", built.name)
+
+	info := built.styles[StyleInfo]
+	assert.Equal(t, lipgloss.Color("#123456"), info.GetForeground())
+
+	// Fields without an override keep the preset's value
+	agent := built.styles[StyleAgent]
+	assert.Equal(t, 0, agent.GetPaddingLeft())
+	assert.Equal(t, lipgloss.Color("#232e23"), agent.GetBackground())
+}
+
+func TestBuildThemeRejectsInvalidOverrides(t *testing.T) {
+	_, err := buildTheme("dark", presets["dark"], map[string]StyleSpec{"heading": {Foreground: "1"}})
+	assert.ErrorContains(t, err, "unknown style")
+
+	_, err = buildTheme("dark", presets["dark"], map[string]StyleSpec{"user": {Padding: []int{1, 2, 3}}})
+	assert.ErrorContains(t, err, "padding")
+}
+
+func TestSetThemeUnknown(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	assert.Error(t, SetTheme("neon"))
+	require.NoError(t, SetTheme("solarized"))
+	assert.Equal(t, "solarized", Current())
+}
+
+func TestInitializeThemeWithInvalidFile(t *testing.T) {
+	for name, contents := range map[string]string{
+		"not json":      `{"preset": `,
+		"unknown style": `{"preset": "solarized", "styles": {"heading": {"foreground": "1"}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			require.NoError(t, os.MkdirAll(filepath.Join(home, ".agent"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(home, ".agent", "theme.json"), []byte(contents), 0644))
+			theme.Store(nil)
+
+			InitializeTheme()
+			assert.Equal(t, "dark", Current())
+		})
+	}
+}
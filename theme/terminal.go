@@ -0,0 +1,43 @@
+package theme
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// DetectColorProfile picks the richest palette the terminal supports. Output that isn't a terminal,
+// NO_COLOR, and TERM=dumb get plain text; CLICOLOR_FORCE keeps colors when piping.
+func DetectColorProfile(getenv func(string) string, terminal bool) termenv.Profile {
+	term := strings.ToLower(getenv("TERM"))
+	if getenv("NO_COLOR") != "" || term == "dumb" {
+		return termenv.Ascii
+	}
+	if !terminal {
+		if forced := getenv("CLICOLOR_FORCE"); forced == "" || forced == "0" {
+			return termenv.Ascii
+		}
+	}
+
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return termenv.TrueColor
+	}
+	if strings.Contains(term, "256color") || strings.Contains(term, "kitty") || strings.Contains(term, "alacritty") {
+		return termenv.ANSI256
+	}
+	return termenv.ANSI
+}
+
+// IsTerminal reports whether stdout is a terminal, so cursor movement is safe to print
+func IsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether output is styled; when false, styled text is printed as-is
+func ColorEnabled() bool {
+	return lipgloss.ColorProfile() != termenv.Ascii
+}
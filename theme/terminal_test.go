@@ -0,0 +1,39 @@
+package theme
+
+import (
+	"testing"
+
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectColorProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		terminal bool
+		expected termenv.Profile
+	}{
+		{"truecolor terminal", map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, true, termenv.TrueColor},
+		{"256 color terminal", map[string]string{"TERM": "xterm-256color"}, true, termenv.ANSI256},
+		{"basic terminal", map[string]string{"TERM": "xterm"}, true, termenv.ANSI},
+		{"NO_COLOR", map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, true, termenv.Ascii},
+		{"dumb terminal", map[string]string{"TERM": "dumb", "COLORTERM": "truecolor"}, true, termenv.Ascii},
+		{"piped", map[string]string{"TERM": "xterm-256color"}, false, termenv.Ascii},
+		{"piped with CLICOLOR_FORCE", map[string]string{"TERM": "xterm-256color", "CLICOLOR_FORCE": "1"}, false, termenv.ANSI256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.expected, DetectColorProfile(getenv, tt.terminal))
+		})
+	}
+}
+
+func TestStyledTextPlainWithoutColor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// Tests don't run in a terminal, so padded styles print their text unchanged
+	assert.NoError(t, SetTheme("dark"))
+	assert.Equal(t, "hello", AgentText("hello"))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"agent/hooks"
+	"agent/logging"
+	"agent/theme"
+	"context"
+	"fmt"
+)
+
+// newHookRunner builds the runner from the configured hook rules, skipping invalid ones
+func newHookRunner(root string, rules []hooks.Rule) *hooks.Runner {
+	runner, _ := hooks.New(root, nil)
+	for i, rule := range rules {
+		if err := runner.Add(rule); err != nil {
+			logging.Warnf("Ignoring hooks[%d]: %v", i, err)
+		}
+	}
+	return runner
+}
+
+// toolCallPaths returns the files a tool call touches: its path argument, or each file in a patch
+func (a *Agent) toolCallPaths(tool string, params map[string]interface{}) []string {
+	if patch, ok := params["patch"].(string); ok && tool == "apply_patch" {
+		return a.patchPaths(patch)
+	}
+	if path, ok := params["path"].(string); ok && path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// runPreHooks runs the pre hooks for a call, showing their output. It returns the notes to pass to
+// the model and an error when a hook blocks the call.
+func (a *Agent) runPreHooks(ctx context.Context, tool string, params map[string]interface{}) ([]string, error) {
+	outcomes, err := a.hooks.Pre(ctx, tool, params, a.toolCallPaths(tool, params))
+	return showHookOutcomes(outcomes), err
+}
+
+// runPostHooks runs the post hooks for a call that returned result, showing their output, and
+// returns the notes to pass to the model
+func (a *Agent) runPostHooks(ctx context.Context, tool string, params map[string]interface{}, result string) []string {
+	return showHookOutcomes(a.hooks.Post(ctx, tool, params, a.toolCallPaths(tool, params), result))
+}
+
+// showHookOutcomes prints each hook's output and returns the notes of hooks with feedback enabled
+func showHookOutcomes(outcomes []hooks.Outcome) []string {
+	var notes []string
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			fmt.Println(theme.WarningText("🪝 " + outcome.Note()))
+		} else if outcome.Output != "" {
+			fmt.Println(theme.DebugText("🪝 " + outcome.Note()))
+		}
+		if outcome.Rule.Feedback {
+			notes = append(notes, outcome.Note())
+		}
+	}
+	return notes
+}
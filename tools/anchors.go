@@ -0,0 +1,256 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var anchorNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Anchor names a code location so the model and the user can refer to it without searching again
+type Anchor struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Text  string `json:"text"` // the line when the anchor was set, used to follow it as the file changes
+	Note  string `json:"note,omitempty"`
+	Stale bool   `json:"-"` // the line can no longer be found in the file
+}
+
+// Location formats the anchor as path:line
+func (a Anchor) Location() string {
+	return fmt.Sprintf("%s:%d", a.Path, a.Line)
+}
+
+// Anchors is the set of named locations in a workspace, saved to a JSON file so they outlast
+// pruning and sessions
+type Anchors struct {
+	mu      sync.Mutex
+	path    string
+	anchors map[string]Anchor
+}
+
+// NewAnchors loads the anchors saved at path, if any. An empty path keeps them in memory only.
+func NewAnchors(path string) *Anchors {
+	anchors := &Anchors{path: path, anchors: make(map[string]Anchor)}
+	if path == "" {
+		return anchors
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		var saved []Anchor
+		if json.Unmarshal(data, &saved) == nil {
+			for _, anchor := range saved {
+				anchors.anchors[anchor.Name] = anchor
+			}
+		}
+	}
+	return anchors
+}
+
+// Set creates or moves an anchor to location, given as path:line
+func (a *Anchors) Set(name, location, note string) (Anchor, error) {
+	if !anchorNamePattern.MatchString(name) {
+		return Anchor{}, fmt.Errorf("anchor names use letters, digits, '.', '_', and '-', got %q", name)
+	}
+	separator := strings.LastIndex(location, ":")
+	if separator == -1 {
+		return Anchor{}, fmt.Errorf("location must be path:line, got %q", location)
+	}
+	line, err := strconv.Atoi(location[separator+1:])
+	if err != nil || line < 1 {
+		return Anchor{}, fmt.Errorf("location must end in a line number, got %q", location)
+	}
+	path, err := filepath.Abs(location[:separator])
+	if err != nil {
+		return Anchor{}, err
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return Anchor{}, err
+	}
+	if line > len(lines) {
+		return Anchor{}, fmt.Errorf("%s has %d lines", path, len(lines))
+	}
+
+	anchor := Anchor{Name: name, Path: path, Line: line, Text: strings.TrimSpace(lines[line-1]), Note: note}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.anchors[name] = anchor
+	return anchor, a.save()
+}
+
+// Remove deletes an anchor
+func (a *Anchors) Remove(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.anchors[name]; !ok {
+		return fmt.Errorf("no anchor named %q", name)
+	}
+	delete(a.anchors, name)
+	return a.save()
+}
+
+// List returns the anchors sorted by name. Anchors whose line moved are updated to where the line
+// is now; ones whose line is gone are marked stale. A nil Anchors has none.
+func (a *Anchors) List() []Anchor {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	moved := false
+	names := make([]string, 0, len(a.anchors))
+	for name, anchor := range a.anchors {
+		names = append(names, name)
+		if resolved := resolveAnchor(anchor); resolved.Line != anchor.Line || resolved.Stale != anchor.Stale {
+			moved = moved || resolved.Line != anchor.Line
+			a.anchors[name] = resolved
+		}
+	}
+	if moved {
+		a.save()
+	}
+
+	sort.Strings(names)
+	anchors := make([]Anchor, len(names))
+	for i, name := range names {
+		anchors[i] = a.anchors[name]
+	}
+	return anchors
+}
+
+// Summary renders the anchors as one line each for the system prompt
+func (a *Anchors) Summary() string {
+	var summary strings.Builder
+	for _, anchor := range a.List() {
+		summary.WriteString(fmt.Sprintf("- %s → %s", anchor.Name, anchor.Location()))
+		if anchor.Note != "" {
+			summary.WriteString(" — " + anchor.Note)
+		}
+		if anchor.Stale {
+			summary.WriteString(" (stale: the line has changed; re-anchor it if you still need it)")
+		}
+		summary.WriteString("\n")
+	}
+	return summary.String()
+}
+
+// resolveAnchor finds the anchored line, searching outward from its last position when the file changed
+func resolveAnchor(anchor Anchor) Anchor {
+	lines, err := readLines(anchor.Path)
+	if err != nil {
+		anchor.Stale = true
+		return anchor
+	}
+	matches := func(line int) bool {
+		return line >= 1 && line <= len(lines) && strings.TrimSpace(lines[line-1]) == anchor.Text
+	}
+	anchor.Stale = false
+	for offset := 0; offset < len(lines)+anchor.Line; offset++ {
+		if matches(anchor.Line - offset) {
+			anchor.Line -= offset
+			return anchor
+		}
+		if matches(anchor.Line + offset) {
+			anchor.Line += offset
+			return anchor
+		}
+	}
+	anchor.Stale = true
+	return anchor
+}
+
+func readLines(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(content), "\n"), nil
+}
+
+// save writes the anchors to disk; the caller holds the lock
+func (a *Anchors) save() error {
+	if a.path == "" {
+		return nil
+	}
+	saved := make([]Anchor, 0, len(a.anchors))
+	for _, anchor := range a.anchors {
+		saved = append(saved, anchor)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Name < saved[j].Name })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(a.path, data, 0644)
+}
+
+// NewSetAnchorTool creates the set_anchor tool
+func NewSetAnchorTool(anchors *Anchors) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "set_anchor",
+		Description: "Name a code location you or the user will come back to (an entry point, a tricky function, where a bug happens) so it can be referred to by name. Anchors are listed in the reference data of every request, follow their line as the file changes, and are kept when history and live context are pruned. Setting an existing name moves it; pass remove to delete one.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Short name, e.g. request-loop",
+				},
+				"location": map[string]interface{}{
+					"type":        "string",
+					"description": "path:line, e.g. /repo/agent.go:712",
+				},
+				"note": map[string]interface{}{
+					"type":        "string",
+					"description": "What is there, in a few words",
+				},
+				"remove": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Delete the anchor instead of setting it",
+				},
+			},
+			"required": []interface{}{"name"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return setAnchor(anchors, params)
+		},
+	}
+}
+
+func setAnchor(anchors *Anchors, params map[string]interface{}) (string, string, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("name must be a non-empty string")
+	}
+	if remove, _ := params["remove"].(bool); remove {
+		if err := anchors.Remove(name); err != nil {
+			return "", "", err
+		}
+		return "⚓ removed " + name + "\n", "Removed anchor " + name, nil
+	}
+
+	location, ok := params["location"].(string)
+	if !ok || location == "" {
+		return "", "", fmt.Errorf("location must be a path:line string")
+	}
+	note, _ := params["note"].(string)
+	anchor, err := anchors.Set(name, location, note)
+	if err != nil {
+		return "", "", WrapToolError("set_anchor", err)
+	}
+	return fmt.Sprintf("⚓ %s → %s\n", anchor.Name, anchor.Location()), fmt.Sprintf("Anchored %s at %s: %s", anchor.Name, anchor.Location(), anchor.Text), nil
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnchors(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	assert.NoError(t, os.WriteFile(source, []byte("package main\n\nfunc main() {\n\trun()\n}\n"), 0644))
+	store := filepath.Join(dir, ".agent", "anchors.json")
+
+	anchors := NewAnchors(store)
+	anchor, err := anchors.Set("entry", source+":3", "program start")
+	assert.NoError(t, err)
+	assert.Equal(t, "func main() {", anchor.Text)
+	_, err = anchors.Set("bad name", source+":3", "")
+	assert.Error(t, err)
+	_, err = anchors.Set("past-end", source+":40", "")
+	assert.ErrorContains(t, err, "has 6 lines")
+
+	// The anchor follows its line when code is inserted above it
+	assert.NoError(t, os.WriteFile(source, []byte("package main\n\nimport \"os\"\n\nfunc main() {\n\trun()\n}\n"), 0644))
+	assert.Equal(t, 5, NewAnchors(store).List()[0].Line)
+	assert.Contains(t, anchors.Summary(), "- entry → "+source+":5 — program start\n")
+
+	assert.NoError(t, os.WriteFile(source, []byte("package main\n"), 0644))
+	assert.True(t, anchors.List()[0].Stale)
+
+	_, _, err = setAnchor(anchors, map[string]interface{}{"name": "entry", "remove": true})
+	assert.NoError(t, err)
+	assert.Empty(t, NewAnchors(store).List())
+	assert.Error(t, anchors.Remove("entry"))
+}
@@ -16,6 +16,10 @@ func NewApprovalTool() models.ToolDefinition {
 					"type":        "boolean",
 					"description": "true if the command is approved, false otherwise.",
 				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "Why the command complies with or violates the policy.",
+				},
 			},
 			"required": []interface{}{"approved"},
 		},
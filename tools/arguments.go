@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseArguments decodes a tool call's JSON arguments. Models sometimes send almost-JSON: wrapped in
+// a code fence, with trailing commas, cut off before the closing braces, or followed by commentary.
+// Those are repaired, and each repair is described in the returned notes so the model can learn from
+// it. Arguments that still aren't a JSON object are an error describing where parsing failed.
+func ParseArguments(arguments string) (map[string]interface{}, []string, error) {
+	var params map[string]interface{}
+	err := json.Unmarshal([]byte(arguments), &params)
+	if err == nil && params != nil {
+		return params, nil, nil
+	}
+	if strings.TrimSpace(arguments) == "" {
+		return map[string]interface{}{}, []string{"arguments were empty and were treated as {}"}, nil
+	}
+
+	repaired, notes := repairJSON(arguments)
+	decoder := json.NewDecoder(strings.NewReader(repaired))
+	var repairedParams map[string]interface{}
+	if repairErr := decoder.Decode(&repairedParams); repairErr == nil && repairedParams != nil {
+		if rest := strings.TrimSpace(repaired[decoder.InputOffset():]); rest != "" {
+			notes = append(notes, fmt.Sprintf("ignored %d characters after the arguments object", len(rest)))
+		}
+		return repairedParams, []string{fmt.Sprintf("arguments were not valid JSON (%s) and were repaired: %s", describeJSONError(arguments, err), strings.Join(notes, "; "))}, nil
+	}
+	return nil, nil, fmt.Errorf("arguments are not a valid JSON object: %s", describeJSONError(arguments, err))
+}
+
+// describeJSONError says where arguments failed to parse, quoting the text around the failure
+func describeJSONError(arguments string, err error) string {
+	if err == nil {
+		return "expected an object"
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset := int(syntaxErr.Offset)
+		start := max(0, offset-20)
+		end := min(len(arguments), offset+20)
+		return fmt.Sprintf("%v at character %d, near %q", err, offset, arguments[start:end])
+	}
+	return err.Error()
+}
+
+// repairJSON fixes the mistakes models make most often in JSON arguments. It returns the repaired
+// text and what was changed.
+func repairJSON(text string) (string, []string) {
+	var notes []string
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 && !strings.Contains(text[:newline], "{") {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+		notes = append(notes, "removed a markdown code fence")
+	}
+	if start := strings.IndexByte(text, '{'); start > 0 {
+		text = text[start:]
+		notes = append(notes, "ignored text before the arguments object")
+	}
+
+	var out strings.Builder
+	var open []byte // unclosed { and [, innermost last
+	inString, escaped := false, false
+	trailingCommas := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			out.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			open = append(open, c)
+		case '}', ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case ',':
+			next := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if next == "" || next[0] == '}' || next[0] == ']' {
+				trailingCommas++
+				continue
+			}
+		}
+		out.WriteByte(c)
+	}
+	switch {
+	case trailingCommas == 1:
+		notes = append(notes, "removed a trailing comma")
+	case trailingCommas > 1:
+		notes = append(notes, fmt.Sprintf("removed %d trailing commas", trailingCommas))
+	}
+
+	if inString || len(open) > 0 {
+		if inString {
+			out.WriteByte('"')
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == '{' {
+				out.WriteByte('}')
+			} else {
+				out.WriteByte(']')
+			}
+		}
+		notes = append(notes, "closed the arguments, which were cut off; check that no values are missing")
+	}
+
+	return out.String(), notes
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArguments(t *testing.T) {
+	params, notes, err := ParseArguments(`{"path": "a.go"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"path": "a.go"}, params)
+	assert.Empty(t, notes)
+
+	params, notes, err = ParseArguments("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, params)
+	assert.Len(t, notes, 1)
+
+	tests := []struct {
+		name      string
+		arguments string
+		want      map[string]interface{}
+		note      string
+	}{
+		{"trailing commas", `{"paths": ["a.go", "b.go",], "force": true,}`, map[string]interface{}{"paths": []interface{}{"a.go", "b.go"}, "force": true}, "removed 2 trailing commas"},
+		{"commas in strings are kept", `{"text": "a,}", "n": 1,}`, map[string]interface{}{"text": "a,}", "n": float64(1)}, "removed a trailing comma"},
+		{"code fence", "```json\n{\"path\": \"a.go\"}\n```", map[string]interface{}{"path": "a.go"}, "removed a markdown code fence"},
+		{"cut off", `{"path": "a.go", "content": "package ma`, map[string]interface{}{"path": "a.go", "content": "package ma"}, "cut off"},
+		{"commentary", `{"path": "a.go"} I'll read this file next.`, map[string]interface{}{"path": "a.go"}, "ignored 25 characters after the arguments object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, notes, err := ParseArguments(tt.arguments)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, params)
+			if assert.Len(t, notes, 1) {
+				assert.Contains(t, notes[0], "arguments were not valid JSON")
+				assert.Contains(t, notes[0], tt.note)
+			}
+		})
+	}
+
+	_, _, err = ParseArguments(`{"path": a.go}`)
+	assert.ErrorContains(t, err, `arguments are not a valid JSON object: invalid character 'a' looking for beginning of value at character 10, near "{\"path\": a.go}"`)
+	_, _, err = ParseArguments(`["a.go"]`)
+	assert.ErrorContains(t, err, "arguments are not a valid JSON object")
+}
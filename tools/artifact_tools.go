@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"agent/artifacts"
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NewSaveArtifactTool creates the save_artifact tool
+func NewSaveArtifactTool(store *artifacts.Store) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "save_artifact",
+		Description: "Save a standalone generated output (report, one-off script, diagram source, data export) to the session's artifacts directory instead of the source tree. Use create_file for changes that belong in the project. The user sees where the artifact was saved.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "File name including extension, e.g. perf-report.md or migrate.sh",
+				},
+				"content": map[string]interface{}{
+					"type":        "string",
+					"description": "Content of the artifact",
+				},
+				"kind": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: What the artifact is (default: other). Scripts are saved as executable.",
+					"enum":        []interface{}{"report", "script", "diagram", "data", "other"},
+				},
+				"description": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: One-line description recorded in the artifact index",
+				},
+			},
+			"required": []interface{}{"name", "content"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return saveArtifact(params, store)
+		},
+	}
+}
+
+func saveArtifact(params map[string]interface{}, store *artifacts.Store) (string, string, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("name must be a non-empty string")
+	}
+	content, ok := params["content"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("content must be a string")
+	}
+	kind, _ := params["kind"].(string)
+	description, _ := params["description"].(string)
+
+	artifact, err := store.Save(name, kind, description, []byte(content))
+	if err != nil {
+		return "", "", WrapToolError("save_artifact", err)
+	}
+
+	result := fmt.Sprintf("Saved artifact #%d (%s, %d bytes) to %s", artifact.ID, artifact.Kind, artifact.Size, artifact.Path)
+	return result + "\n", result, nil
+}
+
+// NewListArtifactsTool creates the list_artifacts tool
+func NewListArtifactsTool(store *artifacts.Store) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "list_artifacts",
+		Description: "List this session's artifacts: saved deliverables, rendered diagrams, and the full output of tool calls whose results were trimmed. Read one with read_artifact.",
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			list := store.List()
+			if len(list) == 0 {
+				return "", "No artifacts saved this session", nil
+			}
+			var result strings.Builder
+			result.WriteString(fmt.Sprintf("%d artifacts:\n", len(list)))
+			for _, artifact := range list {
+				result.WriteString(FormatArtifact(artifact) + "\n")
+			}
+			return "", strings.TrimRight(result.String(), "\n"), nil
+		},
+	}
+}
+
+// FormatArtifact describes an artifact on one line
+func FormatArtifact(artifact artifacts.Artifact) string {
+	line := fmt.Sprintf("#%d %s (%s, %d bytes)", artifact.ID, artifact.Name, artifact.Kind, artifact.Size)
+	if artifact.Description != "" {
+		line += " — " + artifact.Description
+	}
+	return line
+}
+
+// maxArtifactLines is the most lines read_artifact returns in one call
+const maxArtifactLines = 500
+
+// NewReadArtifactTool creates the read_artifact tool
+func NewReadArtifactTool(store *artifacts.Store) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "read_artifact",
+		Description: fmt.Sprintf("Read a range of lines from a saved artifact, such as the full output of a tool call whose result was trimmed. Returns at most %d lines per call.", maxArtifactLines),
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "Artifact number, as in \"artifact #3\"",
+				},
+				"start_line": map[string]interface{}{
+					"type":        "integer",
+					"description": "Optional: First line to read, starting at 1 (default: 1)",
+				},
+				"end_line": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Optional: Last line to read (default: start_line + %d)", maxArtifactLines-1),
+				},
+			},
+			"required": []interface{}{"id"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return readArtifact(params, store)
+		},
+	}
+}
+
+func readArtifact(params map[string]interface{}, store *artifacts.Store) (string, string, error) {
+	id, ok := params["id"].(float64)
+	if !ok {
+		return "", "", fmt.Errorf("id must be an integer")
+	}
+	artifact, ok := store.Get(int(id))
+	if !ok {
+		return "", "", WrapToolError("read_artifact", fmt.Errorf("there is no artifact #%d", int(id)))
+	}
+	content, err := os.ReadFile(artifact.Path)
+	if err != nil {
+		return "", "", WrapToolError("read_artifact", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	start := 1
+	if value, ok := params["start_line"].(float64); ok && value > 1 {
+		start = int(value)
+	}
+	if start > len(lines) {
+		return "", "", WrapToolError("read_artifact", fmt.Errorf("artifact #%d has %d lines; start_line %d is past the end", artifact.ID, len(lines), start))
+	}
+	end := start + maxArtifactLines - 1
+	if value, ok := params["end_line"].(float64); ok && int(value) >= start && int(value) < end {
+		end = int(value)
+	}
+	end = min(end, len(lines))
+
+	header := fmt.Sprintf("Artifact #%d (%s), lines %d-%d of %d", artifact.ID, artifact.Name, start, end, len(lines))
+	return header + "\n", header + ":\n" + strings.Join(lines[start-1:end], "\n"), nil
+}
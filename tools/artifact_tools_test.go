@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"agent/artifacts"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactTools(t *testing.T) {
+	ctx := context.Background()
+	store := artifacts.NewStore(t.TempDir())
+	list := NewListArtifactsTool(store)
+
+	_, result, err := list.Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, "No artifacts saved this session", result)
+
+	_, _, err = NewSaveArtifactTool(store).Func(ctx, map[string]interface{}{"name": "notes.md", "content": "one\ntwo\nthree\n", "kind": "report", "description": "release notes"})
+	assert.NoError(t, err)
+
+	_, result, err = list.Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1 artifacts:\n#1 notes.md (report, 14 bytes) — release notes", result)
+
+	read := NewReadArtifactTool(store)
+	_, result, err = read.Func(ctx, map[string]interface{}{"id": float64(1), "start_line": float64(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, "Artifact #1 (notes.md), lines 2-3 of 3:\ntwo\nthree", result)
+
+	_, _, err = read.Func(ctx, map[string]interface{}{"id": float64(1), "start_line": float64(4)})
+	assert.ErrorContains(t, err, "past the end")
+	_, _, err = read.Func(ctx, map[string]interface{}{"id": float64(2)})
+	assert.ErrorContains(t, err, "no artifact #2")
+}
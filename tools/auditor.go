@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultSecurityPolicy is used by the command auditor when no policy is configured
+const DefaultSecurityPolicy = `Approve ordinary development commands: building, testing, linting, formatting, searching, reading files, and local version control.
+Deny commands that:
+- delete or overwrite files outside the current workspace, or delete the workspace itself
+- change system configuration, users, permissions outside the workspace, or install software system-wide
+- send files, environment variables, credentials, or other secrets over the network
+- rewrite shared version control history (e.g. force pushes) or push without being asked
+- download and execute remote scripts`
+
+// CommandAuditor asks a model to check shell commands against a security policy before they run.
+// Approved commands are cached so repeating a command doesn't cost another request. When the
+// auditor model can't be reached, the user is asked instead.
+type CommandAuditor struct {
+	getModel func() *models.Model
+	policy   string
+	confirm  func(question string) bool
+
+	mu       sync.Mutex
+	approved map[string]bool
+}
+
+// NewCommandAuditor creates an auditor for policy (DefaultSecurityPolicy when empty). confirm asks
+// the user a yes/no question.
+func NewCommandAuditor(getModel func() *models.Model, policy string, confirm func(question string) bool) *CommandAuditor {
+	if policy == "" {
+		policy = DefaultSecurityPolicy
+	}
+	return &CommandAuditor{
+		getModel: getModel,
+		policy:   policy,
+		confirm:  confirm,
+		approved: make(map[string]bool),
+	}
+}
+
+// Check returns nil if command may run, or an error explaining why it was rejected
+func (a *CommandAuditor) Check(ctx context.Context, command string) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	cached := a.approved[command]
+	a.mu.Unlock()
+	if cached {
+		return nil
+	}
+
+	model := a.getModel()
+	var approved bool
+	var reason string
+	var err error
+	if model == nil {
+		err = fmt.Errorf("no model selected")
+	} else {
+		approved, reason, err = auditCommand(ctx, model, command, a.policy)
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Fail closed unless the user approves
+		if !a.confirm(fmt.Sprintf("The command auditor is unavailable (%v). Run `%s`?", err, command)) {
+			return fmt.Errorf("command rejected: the auditor was unavailable and the user declined to run it")
+		}
+		approved = true
+	}
+	if !approved {
+		return fmt.Errorf("command rejected by security policy: %s", reason)
+	}
+
+	a.mu.Lock()
+	a.approved[command] = true
+	a.mu.Unlock()
+	return nil
+}
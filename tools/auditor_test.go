@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// auditorServer streams a make_approval_decision call with the given arguments
+func auditorServer(requests *int, arguments string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"make_approval_decision\",\"arguments\":%q}}]}}]}\n\n", arguments)
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func auditorModel(url string) func() *models.Model {
+	return func() *models.Model {
+		return &models.Model{ID: "m", Provider: &models.Provider{Name: "test", BaseURL: url, APIKey: "k"}}
+	}
+}
+
+func TestCommandAuditorCachesApprovals(t *testing.T) {
+	requests := 0
+	server := auditorServer(&requests, `{"approved":true,"reason":"read-only"}`)
+	defer server.Close()
+
+	auditor := NewCommandAuditor(auditorModel(server.URL), "", func(string) bool {
+		t.Fatal("the user shouldn't be asked")
+		return false
+	})
+	assert.NoError(t, auditor.Check(context.Background(), "ls"))
+	assert.NoError(t, auditor.Check(context.Background(), "ls"))
+	assert.Equal(t, 1, requests)
+}
+
+func TestCommandAuditorRejects(t *testing.T) {
+	requests := 0
+	server := auditorServer(&requests, `{"approved":false,"reason":"deletes files"}`)
+	defer server.Close()
+
+	auditor := NewCommandAuditor(auditorModel(server.URL), "", nil)
+	err := auditor.Check(context.Background(), "rm -rf /")
+	assert.ErrorContains(t, err, "deletes files")
+
+	// Rejections aren't cached
+	assert.Error(t, auditor.Check(context.Background(), "rm -rf /"))
+	assert.Equal(t, 2, requests)
+}
+
+func TestCommandAuditorFallsBackToUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var asked []string
+	answer := false
+	auditor := NewCommandAuditor(auditorModel(server.URL), "", func(question string) bool {
+		asked = append(asked, question)
+		return answer
+	})
+	assert.ErrorContains(t, auditor.Check(context.Background(), "make"), "user declined")
+
+	answer = true
+	assert.NoError(t, auditor.Check(context.Background(), "make"))
+	assert.NoError(t, auditor.Check(context.Background(), "make"))
+	assert.Len(t, asked, 2)
+}
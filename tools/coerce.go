@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CoerceParams converts tool arguments to the types declared in the tool's JSON schema. Weaker
+// models often send numbers and booleans as strings ("5", "true"), arrays as JSON-encoded strings,
+// or a single value where an array is expected. Each conversion is described in the returned notes
+// so the model can learn from it. Values that can't be converted are an error, so the tool never
+// runs with arguments of the wrong type.
+func CoerceParams(schema map[string]interface{}, params map[string]interface{}) ([]string, error) {
+	var notes []string
+	var problems []string
+	coerceObject(schema, params, "", &notes, &problems)
+	if len(problems) > 0 {
+		return notes, fmt.Errorf("invalid arguments: %s", strings.Join(problems, "; "))
+	}
+	return notes, nil
+}
+
+func coerceObject(schema map[string]interface{}, object map[string]interface{}, prefix string, notes, problems *[]string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Sort keys so notes and errors are reported in a stable order
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertySchema, ok := properties[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Null is how many models spell "not provided"
+		if object[key] == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = coerceValue(propertySchema, object[key], prefix+key, notes, problems)
+	}
+}
+
+func coerceValue(schema map[string]interface{}, value interface{}, name string, notes, problems *[]string) interface{} {
+	schemaType, _ := schema["type"].(string)
+	coerced, err := coerceType(schemaType, value)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s %v", name, err))
+		return value
+	}
+	if describeValue(coerced) != describeValue(value) {
+		*notes = append(*notes, fmt.Sprintf("%s: converted %s to %s", name, describeValue(value), describeValue(coerced)))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		coerced = coerceEnum(enum, coerced, name, notes, problems)
+	}
+
+	switch typed := coerced.(type) {
+	case map[string]interface{}:
+		coerceObject(schema, typed, name+".", notes, problems)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range typed {
+				typed[i] = coerceValue(items, item, fmt.Sprintf("%s[%d]", name, i), notes, problems)
+			}
+		}
+	}
+	return coerced
+}
+
+// coerceType converts value to a JSON schema type; untyped schemas accept anything
+func coerceType(schemaType string, value interface{}) (interface{}, error) {
+	switch schemaType {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case "integer", "number":
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a %s, got %s", schemaType, describeValue(value))
+			}
+			number = parsed
+		default:
+			return nil, fmt.Errorf("must be a %s, got %s", schemaType, describeValue(value))
+		}
+		if schemaType == "integer" && number != math.Trunc(number) {
+			return nil, fmt.Errorf("must be an integer, got %s", describeValue(value))
+		}
+		return number, nil
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "1":
+				return true, nil
+			case "false", "no", "0":
+				return false, nil
+			}
+		case float64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a boolean, got %s", describeValue(value))
+	case "array":
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case string:
+			var decoded []interface{}
+			if strings.HasPrefix(strings.TrimSpace(v), "[") && json.Unmarshal([]byte(v), &decoded) == nil {
+				return decoded, nil
+			}
+		}
+		if _, isObject := value.(map[string]interface{}); !isObject {
+			return []interface{}{value}, nil
+		}
+		return nil, fmt.Errorf("must be an array, got %s", describeValue(value))
+	case "object":
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return v, nil
+		case string:
+			var decoded map[string]interface{}
+			if json.Unmarshal([]byte(v), &decoded) == nil {
+				return decoded, nil
+			}
+		}
+		return nil, fmt.Errorf("must be an object, got %s", describeValue(value))
+	default:
+		return value, nil
+	}
+	return nil, fmt.Errorf("must be a %s, got %s", schemaType, describeValue(value))
+}
+
+// coerceEnum matches enum values case-insensitively
+func coerceEnum(enum []interface{}, value interface{}, name string, notes, problems *[]string) interface{} {
+	var allowed []string
+	for _, option := range enum {
+		if option == value {
+			return value
+		}
+		allowed = append(allowed, fmt.Sprint(option))
+	}
+	if text, ok := value.(string); ok {
+		for _, option := range enum {
+			if optionText, ok := option.(string); ok && strings.EqualFold(optionText, strings.TrimSpace(text)) {
+				*notes = append(*notes, fmt.Sprintf("%s: converted %s to %s", name, describeValue(value), describeValue(option)))
+				return option
+			}
+		}
+	}
+	*problems = append(*problems, fmt.Sprintf("%s must be one of %s, got %s", name, strings.Join(allowed, ", "), describeValue(value)))
+	return value
+}
+
+// describeValue renders a value with its JSON type, e.g. string "5" or number 5
+func describeValue(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	text := string(encoded)
+	if len(text) > 60 {
+		text = text[:57] + "..."
+	}
+	switch value.(type) {
+	case string:
+		return "string " + text
+	case float64:
+		return "number " + text
+	case bool:
+		return "boolean " + text
+	case []interface{}:
+		return "array " + text
+	case map[string]interface{}:
+		return "object " + text
+	}
+	return text
+}
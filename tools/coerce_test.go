@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var coerceSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":       map[string]interface{}{"type": "string"},
+		"start_line": map[string]interface{}{"type": "integer"},
+		"pin":        map[string]interface{}{"type": "boolean"},
+		"patterns":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"role":       map[string]interface{}{"type": "string", "enum": []interface{}{"user", "assistant"}},
+		"options": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"depth": map[string]interface{}{"type": "integer"}},
+		},
+	},
+}
+
+func TestCoerceParams(t *testing.T) {
+	params := map[string]interface{}{
+		"path":       "main.go",
+		"start_line": "12",
+		"pin":        "True",
+		"patterns":   `["*.go", 3]`,
+		"role":       "User",
+		"options":    `{"depth": "2"}`,
+		"extra":      "left alone",
+	}
+
+	notes, err := CoerceParams(coerceSchema, params)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"path":       "main.go",
+		"start_line": float64(12),
+		"pin":        true,
+		"patterns":   []interface{}{"*.go", "3"},
+		"role":       "user",
+		"options":    map[string]interface{}{"depth": float64(2)},
+		"extra":      "left alone",
+	}, params)
+	assert.Equal(t, []string{
+		`options: converted string "{\"depth\": \"2\"}" to object {"depth":"2"}`,
+		`options.depth: converted string "2" to number 2`,
+		`patterns: converted string "[\"*.go\", 3]" to array ["*.go",3]`,
+		`patterns[1]: converted number 3 to string "3"`,
+		`pin: converted string "True" to boolean true`,
+		`role: converted string "User" to string "user"`,
+		`start_line: converted string "12" to number 12`,
+	}, notes)
+}
+
+func TestCoerceParamsWellTyped(t *testing.T) {
+	params := map[string]interface{}{"start_line": float64(3), "patterns": "*.go", "pin": nil}
+	notes, err := CoerceParams(coerceSchema, params)
+	assert.NoError(t, err)
+	// A lone value becomes a one-element array and nulls are treated as omitted
+	assert.Equal(t, map[string]interface{}{"start_line": float64(3), "patterns": []interface{}{"*.go"}}, params)
+	assert.Len(t, notes, 1)
+}
+
+func TestCoerceParamsErrors(t *testing.T) {
+	_, err := CoerceParams(coerceSchema, map[string]interface{}{
+		"start_line": "3.5",
+		"pin":        "maybe",
+		"role":       "system",
+	})
+	assert.EqualError(t, err, `invalid arguments: pin must be a boolean, got string "maybe"; role must be one of user, assistant, got string "system"; start_line must be an integer, got string "3.5"`)
+}
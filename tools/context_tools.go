@@ -14,8 +14,14 @@ type LiveContextManager interface {
 	AddDirectory(path string, ignoreGitignore bool, ignorePatterns ...string) error
 	RemoveDirectory(path string) error
 	ListDirectories() []string
+	SetDirectoryLimits(path string, maxItems, maxDepth int) error
+	SetDirectoryVisibility(path string, includeHidden, defaultIgnores *bool) error
+	ExpandDirectory(path string) (parent string, err error)
 	SerializeFiles() string
 	SerializeDirectories() string
+	SetPriority(path string, pinned bool, priority string) error
+	GetPriority(path string) (pinned bool, priority string)
+	SetForce(path string, force bool) error
 }
 
 // NewReadFileTool creates the read_file tool
@@ -37,13 +43,26 @@ func NewReadFileTool(liveContext LiveContextManager) models.ToolDefinition {
 				"description": "Optional: Ending line number (1-based)",
 				"minimum":     1,
 			},
+			"pin": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Pin the file so context pruning never removes it. Use for files you are actively editing.",
+			},
+			"priority": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: How important the file is. Low-priority files are dropped first when context is compacted (default: normal)",
+				"enum":        []interface{}{"low", "normal", "high"},
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Include the whole file even if it's over the size limit; large files are otherwise summarized by their structure. Prefer reading a line range.",
+			},
 		},
 		"required": []string{"path"},
 	}
 
 	return models.ToolDefinition{
 		Name:        "read_file",
-		Description: "Read a file's contents. The file will be automatically included with current data in every request. Use this instead of shell commands like 'cat' to read files.",
+		Description: "Read a file's contents. The file will be automatically included with current data in every request. Use this instead of shell commands like 'cat' to read files. Binary files are described instead of shown.",
 		Schema:      schema,
 		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
 			return readFile(ctx, params, liveContext)
@@ -95,13 +114,31 @@ func NewReadDirectoryTool(liveContext LiveContextManager) models.ToolDefinition
 				},
 				"description": "Optional: Additional patterns to ignore (glob format)",
 			},
+			"include_hidden": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Whether to list dot-prefixed entries such as .github and .env.example (default: from the config, normally true)",
+			},
+			"default_ignores": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Whether to skip the default ignores such as .git, node_modules, and *.log (default: true)",
+			},
+			"max_items": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: Maximum number of entries to list (default: 100)",
+				"minimum":     1,
+			},
+			"max_depth": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: Maximum number of levels to list below the directory (default: 10)",
+				"minimum":     1,
+			},
 		},
 		"required": []string{"path"},
 	}
 
 	return models.ToolDefinition{
 		Name:        "read_directory",
-		Description: "Read a directory's nested file structure as a flat list. Use this instead of shell commands like 'ls' or 'find' to explore directories.",
+		Description: "Read a directory's nested file structure as a flat list. Use this instead of shell commands like 'ls' or 'find' to explore directories. Subdirectories cut short by the limits are marked with how many entries they hold.",
 		Schema:      schema,
 		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
 			return readDirectory(ctx, params, liveContext)
@@ -109,6 +146,29 @@ func NewReadDirectoryTool(liveContext LiveContextManager) models.ToolDefinition
 	}
 }
 
+// NewExpandDirectoryTool creates the expand_directory tool
+func NewExpandDirectoryTool(liveContext LiveContextManager) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the subdirectory to list in full",
+			},
+		},
+		"required": []string{"path"},
+	}
+
+	return models.ToolDefinition{
+		Name:        "expand_directory",
+		Description: "List a subdirectory that a directory structure in context cut short, in its own section. Use stop_reading_directory on the same path to collapse it again.",
+		Schema:      schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return expandDirectory(ctx, params, liveContext)
+		},
+	}
+}
+
 // NewStopReadingDirectoryTool creates the stop_reading_directory tool
 func NewStopReadingDirectoryTool(liveContext LiveContextManager) models.ToolDefinition {
 	schema := map[string]interface{}{
@@ -153,6 +213,27 @@ func readFile(ctx context.Context, params map[string]interface{}, liveContext Li
 		return "", "", WrapToolError("read_file", err)
 	}
 
+	pin, hasPin := params["pin"].(bool)
+	priority, hasPriority := params["priority"].(string)
+	if hasPin || hasPriority {
+		currentPin, currentPriority := liveContext.GetPriority(path)
+		if !hasPin {
+			pin = currentPin
+		}
+		if !hasPriority {
+			priority = currentPriority
+		}
+		if err := liveContext.SetPriority(path, pin, priority); err != nil {
+			return "", "", WrapToolError("read_file", err)
+		}
+	}
+
+	if force, ok := params["force"].(bool); ok {
+		if err := liveContext.SetForce(path, force); err != nil {
+			return "", "", WrapToolError("read_file", err)
+		}
+	}
+
 	if startLine > 0 || endLine != nil {
 		endLineStr := "end"
 		if endLine != nil {
@@ -202,9 +283,47 @@ func readDirectory(ctx context.Context, params map[string]interface{}, liveConte
 		return "", "", WrapToolError("read_directory", err)
 	}
 
+	var includeHidden, defaultIgnores *bool
+	if value, ok := params["include_hidden"].(bool); ok {
+		includeHidden = &value
+	}
+	if value, ok := params["default_ignores"].(bool); ok {
+		defaultIgnores = &value
+	}
+	if includeHidden != nil || defaultIgnores != nil {
+		if err := liveContext.SetDirectoryVisibility(path, includeHidden, defaultIgnores); err != nil {
+			return "", "", WrapToolError("read_directory", err)
+		}
+	}
+
+	maxItems, hasMaxItems := params["max_items"].(float64)
+	maxDepth, hasMaxDepth := params["max_depth"].(float64)
+	if hasMaxItems || hasMaxDepth {
+		if err := liveContext.SetDirectoryLimits(path, int(maxItems), int(maxDepth)); err != nil {
+			return "", "", WrapToolError("read_directory", err)
+		}
+	}
+
 	return fmt.Sprintf("Reading directory %s\n", path), "Reading", nil
 }
 
+// expandDirectory implements the expand directory functionality
+func expandDirectory(ctx context.Context, params map[string]interface{}, liveContext LiveContextManager) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("path must be a string")
+	}
+
+	parent, err := liveContext.ExpandDirectory(path)
+	if err != nil {
+		return "", "", WrapToolError("expand_directory", err)
+	}
+	if parent == "" {
+		return fmt.Sprintf("Reading directory %s\n", path), "Reading", nil
+	}
+	return fmt.Sprintf("Expanded %s in directory %s\n", path, parent), "Expanded", nil
+}
+
 // stopReadingDirectory implements the stop reading directory functionality
 func stopReadingDirectory(ctx context.Context, params map[string]interface{}, liveContext LiveContextManager) (string, string, error) {
 	path, ok := params["path"].(string)
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"agent/api"
+	"agent/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const maxDocsChars = 20000
+
+// DocsProvider is a Context7-compatible documentation service used for non-Go libraries
+type DocsProvider struct {
+	URL    string `json:"url"`               // API base URL (default https://context7.com/api/v1)
+	APIKey string `json:"api_key,omitempty"` // optional; can be env:VAR_NAME, keychain:<name>, or cmd:<command>
+}
+
+// NewLookupDocsTool creates the lookup_docs tool
+func NewLookupDocsTool(provider DocsProvider) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "lookup_docs",
+		Description: "Fetch documentation for a library at the version this project uses. Go packages are documented with `go doc` against the versions in go.mod; other libraries use the configured docs provider. Use this before calling unfamiliar dependency APIs instead of guessing. The user doesn't see the result.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"library": map[string]interface{}{
+					"type":        "string",
+					"description": "Go import path (e.g. github.com/openai/openai-go) or library name (e.g. react, django)",
+				},
+				"symbol": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Type, function, or method to document (e.g. Client or Client.Do for Go)",
+				},
+				"topic": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Focus area for non-Go libraries (e.g. routing, hooks)",
+				},
+				"version": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Library version for non-Go libraries (Go versions come from go.mod)",
+				},
+			},
+			"required": []interface{}{"library"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return lookupDocs(ctx, params, provider)
+		},
+	}
+}
+
+func lookupDocs(ctx context.Context, params map[string]interface{}, provider DocsProvider) (string, string, error) {
+	library, ok := params["library"].(string)
+	if !ok || strings.TrimSpace(library) == "" {
+		return "", "", fmt.Errorf("library must be a non-empty string")
+	}
+	symbol, _ := params["symbol"].(string)
+	topic, _ := params["topic"].(string)
+	version, _ := params["version"].(string)
+
+	// Go packages resolve against the current module, so go doc shows the version in go.mod
+	if docs, err := goDoc(ctx, library, symbol); err == nil {
+		return fmt.Sprintf("Fetched Go docs for %s\n", library), truncateDocs(docs), nil
+	}
+
+	if topic == "" {
+		topic = symbol
+	}
+	docs, err := fetchProviderDocs(ctx, provider, library, version, topic)
+	if err != nil {
+		return "", "", WrapToolError("lookup_docs", err)
+	}
+	return fmt.Sprintf("Fetched docs for %s\n", library), truncateDocs(docs), nil
+}
+
+// goDoc documents a Go package or symbol, noting the module version it resolved to
+func goDoc(ctx context.Context, pkg, symbol string) (string, error) {
+	if strings.ContainsAny(pkg, " \t") {
+		return "", fmt.Errorf("not a Go import path")
+	}
+
+	args := []string{"doc"}
+	if symbol != "" {
+		args = append(args, pkg, symbol)
+	} else {
+		args = append(args, "-short", pkg)
+	}
+	docs, err := runCommand(ctx, "go", args...)
+	if err != nil {
+		return "", err
+	}
+
+	header := "Package " + pkg
+	if module, err := runCommand(ctx, "go", "list", "-f", "{{with .Module}}{{.Path}} {{.Version}}{{end}}", pkg); err == nil && strings.TrimSpace(module) != "" {
+		header += " (module " + strings.TrimSpace(module) + ")"
+	} else {
+		header += " (standard library or current module)"
+	}
+	return header + "\n\n" + docs, nil
+}
+
+// fetchProviderDocs searches a Context7-compatible provider for the library and fetches its docs
+func fetchProviderDocs(ctx context.Context, provider DocsProvider, library, version, topic string) (string, error) {
+	baseURL := strings.TrimRight(provider.URL, "/")
+	if baseURL == "" {
+		baseURL = "https://context7.com/api/v1"
+	}
+
+	var search struct {
+		Results []struct {
+			ID       string   `json:"id"`
+			Title    string   `json:"title"`
+			Versions []string `json:"versions"`
+		} `json:"results"`
+	}
+	body, err := docsRequest(ctx, provider, baseURL+"/search?query="+url.QueryEscape(library))
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(body, &search); err != nil {
+		return "", fmt.Errorf("unexpected search response from docs provider: %w", err)
+	}
+	if len(search.Results) == 0 {
+		return "", fmt.Errorf("no documentation found for %s", library)
+	}
+
+	libraryID := search.Results[0].ID
+	if version != "" {
+		libraryID += "/" + version
+	}
+
+	query := url.Values{"type": {"txt"}, "tokens": {"5000"}}
+	if topic != "" {
+		query.Set("topic", topic)
+	}
+	docs, err := docsRequest(ctx, provider, baseURL+"/"+strings.TrimPrefix(libraryID, "/")+"?"+query.Encode())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Library %s (%s)\n\n%s", search.Results[0].Title, libraryID, docs), nil
+}
+
+func docsRequest(ctx context.Context, provider DocsProvider, requestURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := api.ResolveAPIKey(provider.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("docs provider API key: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docs provider request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("docs provider returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func truncateDocs(docs string) string {
+	if len(docs) <= maxDocsChars {
+		return docs
+	}
+	return docs[:maxDocsChars] + fmt.Sprintf("\n... (truncated %d characters; narrow with symbol or topic)", len(docs)-maxDocsChars)
+}
@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupDocsGo(t *testing.T) {
+	_, agentMsg, err := NewLookupDocsTool(DocsProvider{}).Func(context.Background(), map[string]interface{}{
+		"library": "strings",
+		"symbol":  "Cut",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Package strings (standard library or current module)")
+	assert.Contains(t, agentMsg, "func Cut(s, sep string)")
+}
+
+func TestLookupDocsProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/search":
+			assert.Equal(t, "react", r.URL.Query().Get("query"))
+			w.Write([]byte(`{"results":[{"id":"/facebook/react","title":"React"}]}`))
+		case "/facebook/react/v18.2.0":
+			assert.Equal(t, "hooks", r.URL.Query().Get("topic"))
+			w.Write([]byte("useState returns a stateful value"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, agentMsg, err := NewLookupDocsTool(DocsProvider{URL: server.URL, APIKey: "secret"}).Func(context.Background(), map[string]interface{}{
+		"library": "react",
+		"topic":   "hooks",
+		"version": "v18.2.0",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Library React (/facebook/react/v18.2.0)")
+	assert.Contains(t, agentMsg, "useState returns a stateful value")
+}
@@ -0,0 +1,22 @@
+package tools
+
+import "context"
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which the file tools show the diff of their change and report
+// it to the model without writing anything
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether file tools run in ctx should leave the disk untouched
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// dryRunResult is the result file tools return to the model in a dry run
+func dryRunResult(action string) string {
+	return "DRY RUN: " + action + ", but nothing was written; the file is unchanged on disk"
+}
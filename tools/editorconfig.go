@@ -0,0 +1,410 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// EditorConfig holds the .editorconfig properties that apply to a file. Empty and nil fields are
+// unset, so the file is written as given.
+type EditorConfig struct {
+	IndentStyle            string // "tab" or "space"
+	IndentSize             int
+	TabWidth               int
+	EndOfLine              string // "lf", "crlf", or "cr"
+	Charset                string // "utf-8", "utf-8-bom", "latin1", "utf-16be", or "utf-16le"
+	InsertFinalNewline     *bool
+	TrimTrailingWhitespace *bool
+}
+
+// LoadEditorConfig resolves the properties for path from the .editorconfig files in its directory
+// and its parents, stopping at one marked root = true. Closer files take precedence.
+func LoadEditorConfig(path string) EditorConfig {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return EditorConfig{}
+	}
+
+	var files []string
+	for dir := filepath.Dir(absPath); ; dir = filepath.Dir(dir) {
+		configPath := filepath.Join(dir, ".editorconfig")
+		if _, err := os.Stat(configPath); err == nil {
+			files = append(files, configPath)
+			if isRootEditorConfig(configPath) {
+				break
+			}
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	properties := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		applyEditorConfigFile(files[i], absPath, properties)
+	}
+	return editorConfigFromProperties(properties)
+}
+
+func isRootEditorConfig(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			return false
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(strings.ToLower(key)) == "root" {
+			return strings.TrimSpace(strings.ToLower(value)) == "true"
+		}
+	}
+	return false
+}
+
+// applyEditorConfigFile copies the properties of every section in configPath matching path
+func applyEditorConfigFile(configPath, path string, properties map[string]string) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	rel, err := filepath.Rel(filepath.Dir(configPath), path)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	matches := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			matches = editorConfigGlobMatches(line[1:len(line)-1], rel)
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && matches {
+			properties[strings.ToLower(strings.TrimSpace(key))] = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+}
+
+// editorConfigGlobMatches matches a section glob against a slash-separated path relative to the
+// .editorconfig file. Globs without a slash match the file name in any directory.
+func editorConfigGlobMatches(glob, rel string) bool {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+	pattern, err := regexp.Compile("^" + editorConfigGlobToRegexp(glob) + "$")
+	return err == nil && pattern.MatchString(rel)
+}
+
+func editorConfigGlobToRegexp(glob string) string {
+	var result strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// **/ matches zero or more directories
+					i++
+					result.WriteString("(?:.*/)?")
+				} else {
+					result.WriteString(".*")
+				}
+			} else {
+				result.WriteString("[^/]*")
+			}
+		case '?':
+			result.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				result.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			result.WriteString("[" + class + "]")
+			i += end
+		case '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 {
+				result.WriteString(`\{`)
+				continue
+			}
+			var alternatives []string
+			for _, alternative := range strings.Split(glob[i+1:i+end], ",") {
+				alternatives = append(alternatives, editorConfigGlobToRegexp(alternative))
+			}
+			result.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+			i += end
+		default:
+			result.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return result.String()
+}
+
+func editorConfigFromProperties(properties map[string]string) EditorConfig {
+	config := EditorConfig{
+		IndentStyle: properties["indent_style"],
+		EndOfLine:   properties["end_of_line"],
+		Charset:     properties["charset"],
+	}
+	if size, err := strconv.Atoi(properties["tab_width"]); err == nil {
+		config.TabWidth = size
+	}
+	if size, err := strconv.Atoi(properties["indent_size"]); err == nil {
+		config.IndentSize = size
+	} else if properties["indent_size"] == "tab" {
+		config.IndentSize = config.TabWidth
+	}
+	if config.TabWidth == 0 {
+		config.TabWidth = config.IndentSize
+	}
+	if config.IndentSize == 0 {
+		config.IndentSize = config.TabWidth
+	}
+	if value, ok := properties["insert_final_newline"]; ok && (value == "true" || value == "false") {
+		enabled := value == "true"
+		config.InsertFinalNewline = &enabled
+	}
+	if value, ok := properties["trim_trailing_whitespace"]; ok && (value == "true" || value == "false") {
+		enabled := value == "true"
+		config.TrimTrailingWhitespace = &enabled
+	}
+	return config
+}
+
+// Normalize rewrites a whole file to follow the config, including insert_final_newline
+func (c EditorConfig) Normalize(content string) string {
+	content = c.normalizeLines(content, "", false, false)
+	if c.InsertFinalNewline != nil && content != "" {
+		content = strings.TrimRight(content, "\n")
+		if *c.InsertFinalNewline {
+			content += "\n"
+		}
+	}
+	return c.lineEndings(content)
+}
+
+// NormalizeFragment rewrites text inserted into a file between before and after. Only the lines the
+// text adds whole are rewritten: a first or last line it shares with the file is left as written,
+// and so are lines inside a multi-line string literal. Its trailing newlines are kept.
+func (c EditorConfig) NormalizeFragment(text, before, after string) string {
+	startsMidLine := before != "" && !strings.HasSuffix(before, "\n") && !strings.HasSuffix(before, "\r")
+	endsMidLine := after != "" && !strings.HasPrefix(after, "\n") && !strings.HasPrefix(after, "\r")
+	quote := ""
+	for _, line := range strings.Split(before, "\n") {
+		quote = openQuote(quote, line)
+	}
+	return c.lineEndings(c.normalizeLines(text, quote, startsMidLine, endsMidLine))
+}
+
+// ReplaceFragments replaces the first n occurrences of old in content, or all of them when n < 0,
+// with text normalized for where each one lands
+func (c EditorConfig) ReplaceFragments(content, old, text string, n int) string {
+	if old == "" {
+		return content
+	}
+	var out strings.Builder
+	rest := content
+	for ; n != 0; n-- {
+		i := strings.Index(rest, old)
+		if i < 0 {
+			break
+		}
+		out.WriteString(rest[:i])
+		rest = rest[i+len(old):]
+		out.WriteString(c.NormalizeFragment(text, out.String(), rest))
+	}
+	out.WriteString(rest)
+	return out.String()
+}
+
+// normalizeLines trims and reindents the lines of content, joined with \n. quote is the multi-line
+// string open where content starts; a partial first or last line is left alone.
+func (c EditorConfig) normalizeLines(content, quote string, startsMidLine, endsMidLine bool) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		inString := quote != ""
+		quote = openQuote(quote, line)
+		if (i == 0 && startsMidLine) || (i == len(lines)-1 && endsMidLine) {
+			continue
+		}
+		if c.TrimTrailingWhitespace != nil && *c.TrimTrailingWhitespace && quote == "" {
+			line = strings.TrimRight(line, " \t")
+		}
+		if !inString {
+			line = c.reindent(line)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineEndings converts \n to the configured end_of_line
+func (c EditorConfig) lineEndings(content string) string {
+	switch c.EndOfLine {
+	case "crlf":
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	case "cr":
+		content = strings.ReplaceAll(content, "\n", "\r")
+	}
+	return content
+}
+
+// multilineQuotes open string literals that can span lines: raw strings and template literals, and
+// Python's triple-quoted strings
+var multilineQuotes = []string{"`", `"""`, "'''"}
+
+// openQuote returns the multi-line string literal still open after line, given the one open before
+// it. Ordinary double-quoted strings are skipped so quotes inside them don't count.
+func openQuote(quote, line string) string {
+	for {
+		if quote != "" {
+			end := strings.Index(line, quote)
+			if end < 0 {
+				return quote
+			}
+			line, quote = line[end+len(quote):], ""
+			continue
+		}
+
+		start, next := -1, ""
+		for _, delimiter := range multilineQuotes {
+			if i := strings.Index(line, delimiter); i >= 0 && (start < 0 || i < start) {
+				start, next = i, delimiter
+			}
+		}
+		if plain := strings.Index(line, `"`); plain >= 0 && (start < 0 || plain < start) {
+			line = skipString(line[plain+1:])
+			continue
+		}
+		if start < 0 {
+			return ""
+		}
+		line, quote = line[start+len(next):], next
+	}
+}
+
+// skipString returns what follows the closing quote of a double-quoted string that text starts in
+func skipString(text string) string {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return text[i+1:]
+		}
+	}
+	return ""
+}
+
+// reindent converts a line's leading whitespace to the configured indent style
+func (c EditorConfig) reindent(line string) string {
+	if c.IndentSize <= 0 || (c.IndentStyle != "tab" && c.IndentStyle != "space") {
+		return line
+	}
+	body := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(body)]
+	if indent == "" {
+		return line
+	}
+
+	columns := 0
+	for _, char := range indent {
+		if char == '\t' {
+			columns += c.TabWidth - columns%c.TabWidth
+		} else {
+			columns++
+		}
+	}
+
+	if c.IndentStyle == "space" {
+		return strings.Repeat(" ", columns) + body
+	}
+	return strings.Repeat("\t", columns/c.TabWidth) + strings.Repeat(" ", columns%c.TabWidth) + body
+}
+
+// Encode converts content to the configured charset
+func (c EditorConfig) Encode(content string) ([]byte, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	switch c.Charset {
+	case "utf-8-bom":
+		return []byte("\ufeff" + content), nil
+	case "latin1":
+		encoded := make([]byte, 0, len(content))
+		for _, char := range content {
+			if char > 0xFF {
+				return nil, fmt.Errorf("character %q can't be written in latin1 (set by .editorconfig)", char)
+			}
+			encoded = append(encoded, byte(char))
+		}
+		return encoded, nil
+	case "utf-16be", "utf-16le":
+		return encodeUTF16(content, c.Charset == "utf-16be"), nil
+	}
+	return []byte(content), nil
+}
+
+// Decode reads file bytes in the configured charset
+func (c EditorConfig) Decode(data []byte) string {
+	switch c.Charset {
+	case "utf-8-bom":
+		return strings.TrimPrefix(string(data), "\ufeff")
+	case "latin1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case "utf-16be", "utf-16le":
+		return decodeUTF16(data, c.Charset == "utf-16be")
+	}
+	return string(data)
+}
+
+func encodeUTF16(content string, bigEndian bool) []byte {
+	units := utf16.Encode([]rune(content))
+	encoded := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		if bigEndian {
+			encoded = append(encoded, byte(unit>>8), byte(unit))
+		} else {
+			encoded = append(encoded, byte(unit), byte(unit>>8))
+		}
+	}
+	return encoded
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEditorConfig(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".editorconfig"), []byte("root = true\n\n[*]\nindent_style = space\nindent_size = 4\ninsert_final_newline = true\n\n[*.{go,mk}]\nindent_style = tab\n\n[Makefile]\nindent_style = tab\n"), 0644))
+	sub := filepath.Join(root, "web")
+	assert.NoError(t, os.MkdirAll(sub, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, ".editorconfig"), []byte("[*.js]\nindent_size = 2\nend_of_line = crlf\n"), 0644))
+
+	goConfig := LoadEditorConfig(filepath.Join(root, "cmd", "main.go"))
+	assert.Equal(t, "tab", goConfig.IndentStyle)
+	assert.Equal(t, 4, goConfig.IndentSize)
+	assert.True(t, *goConfig.InsertFinalNewline)
+
+	assert.Equal(t, "tab", LoadEditorConfig(filepath.Join(root, "Makefile")).IndentStyle)
+
+	jsConfig := LoadEditorConfig(filepath.Join(sub, "app.js"))
+	assert.Equal(t, "space", jsConfig.IndentStyle)
+	assert.Equal(t, 2, jsConfig.IndentSize)
+	assert.Equal(t, "crlf", jsConfig.EndOfLine)
+
+	assert.Equal(t, EditorConfig{}, LoadEditorConfig(filepath.Join(t.TempDir(), "x.txt")))
+}
+
+func TestEditorConfigNormalize(t *testing.T) {
+	enabled := true
+	tabs := EditorConfig{IndentStyle: "tab", IndentSize: 4, TabWidth: 4, InsertFinalNewline: &enabled, TrimTrailingWhitespace: &enabled}
+	assert.Equal(t, "func f() {\n\tif x {\n\t\treturn\n\t}\n}\n", tabs.Normalize("func f() {  \n    if x {\n        return\n    }\n}"))
+
+	spaces := EditorConfig{IndentStyle: "space", IndentSize: 2, TabWidth: 2, EndOfLine: "crlf"}
+	assert.Equal(t, "a:\r\n  b: 1\r\n", spaces.NormalizeFragment("a:\n\tb: 1\n", "", ""))
+
+	// Fragments keep their trailing newlines
+	assert.Equal(t, "\tx\n\n", tabs.NormalizeFragment("    x\n\n", "", ""))
+
+	// Multi-line strings keep their indentation and trailing whitespace
+	assert.Equal(t, "var s = `\n    kept  \n`\n\tx\n", tabs.Normalize("var s = `\n    kept  \n`\n    x"))
+	assert.Equal(t, "q = \"\"\"\n    kept\n\"\"\"\n\tx\n", tabs.Normalize("q = \"\"\"\n    kept\n\"\"\"\n    x"))
+	assert.Equal(t, "s := \"`\"\n\tx\n", tabs.Normalize("s := \"`\"\n    x"), "a quote inside an ordinary string")
+	assert.Equal(t, "    kept\n`\n\tx\n", tabs.NormalizeFragment("    kept\n`\n    x\n", "var s = `\n", ""))
+}
+
+func TestEditorConfigNormalizeFragmentLines(t *testing.T) {
+	enabled := true
+	tabs := EditorConfig{IndentStyle: "tab", IndentSize: 4, TabWidth: 4, TrimTrailingWhitespace: &enabled}
+
+	// The first and last lines continue the file's lines, so they're left as written
+	assert.Equal(t, "b  \n\tc\n    d  ", tabs.NormalizeFragment("b  \n    c  \n    d  ", "a(", ")\n"))
+	assert.Equal(t, "    x  ", tabs.NormalizeFragment("    x  ", "f(", ")"))
+
+	// Replacements are normalized for where each one lands
+	content := "f(old)\nold\n"
+	assert.Equal(t, "f(    y\n    z)\n\ty\n\tz\n", tabs.ReplaceFragments(content, "old", "    y\n    z", -1))
+}
+
+func TestEditorConfigCharsets(t *testing.T) {
+	for _, charset := range []string{"utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le"} {
+		config := EditorConfig{Charset: charset}
+		encoded, err := config.Encode("café\n")
+		assert.NoError(t, err, charset)
+		assert.Equal(t, "café\n", config.Decode(encoded), charset)
+	}
+
+	bom, _ := EditorConfig{Charset: "utf-8-bom"}.Encode("x")
+	assert.Equal(t, []byte("\ufeffx"), bom)
+
+	_, err := EditorConfig{Charset: "latin1"}.Encode("日本")
+	assert.Error(t, err)
+}
+
+func TestFileToolsFollowEditorConfig(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".editorconfig"), []byte("root = true\n[*.py]\nindent_style = space\nindent_size = 4\ninsert_final_newline = true\n"), 0644))
+	formatter := NewFormatter(FormatConfig{})
+	ctx := context.Background()
+
+	path := filepath.Join(root, "app.py")
+	_, _, err := createFile(ctx, map[string]interface{}{"path": path, "content": "def f():\n\treturn 1"}, nil, nil, formatter)
+	assert.NoError(t, err)
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "def f():\n    return 1\n", string(content))
+
+	_, _, err = editFile(ctx, map[string]interface{}{"path": path, "old_str": "    return 1", "new_str": "\tx = 1\n\treturn x"}, nil, formatter)
+	assert.NoError(t, err)
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "def f():\n    x = 1\n    return x\n", string(content))
+
+	// Edits to CRLF files match and keep CRLF line endings
+	crlfPath := filepath.Join(root, "notes.txt")
+	assert.NoError(t, os.WriteFile(crlfPath, []byte("one\r\ntwo\r\n"), 0644))
+	_, _, err = editFile(ctx, map[string]interface{}{"path": crlfPath, "old_str": "one\ntwo", "new_str": "one\nand\ntwo"}, nil, formatter)
+	assert.NoError(t, err)
+	content, _ = os.ReadFile(crlfPath)
+	assert.Equal(t, "one\r\nand\r\ntwo\r\n", string(content))
+}
+
+func TestFormatterRunsConfiguredCommand(t *testing.T) {
+	root := t.TempDir()
+	script := filepath.Join(root, "upper.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ntr a-z A-Z < \"$1\" > \"$1.tmp\" && mv \"$1.tmp\" \"$1\"\n"), 0755))
+	formatter := NewFormatter(FormatConfig{OnWrite: true, Commands: map[string]string{".txt": script}})
+
+	path := filepath.Join(root, "a.txt")
+	_, agentMsg, err := createFile(context.Background(), map[string]interface{}{"path": path, "content": "hello\n"}, nil, nil, formatter)
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "formatted with "+script)
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "HELLO\n", string(content))
+
+	// Formatting is skipped for extensions without an installed formatter
+	name, err := formatter.Format(context.Background(), filepath.Join(root, "b.unknown"))
+	assert.NoError(t, err)
+	assert.Empty(t, name)
+
+	// Formatting is off by default
+	name, err = NewFormatter(FormatConfig{Commands: map[string]string{".txt": script}}).Format(context.Background(), path)
+	assert.NoError(t, err)
+	assert.Empty(t, name)
+}
+
+func TestFormatterReportsLintProblems(t *testing.T) {
+	root := t.TempDir()
+	script := filepath.Join(root, "lint.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ngrep -n TODO \"$1\" && exit 1 || exit 0\n"), 0755))
+	formatter := NewFormatter(FormatConfig{Lint: true, LintCommands: map[string]string{".txt": script}})
+
+	path := filepath.Join(root, "a.txt")
+	_, agentMsg, err := createFile(context.Background(), map[string]interface{}{"path": path, "content": "done\nTODO finish\n"}, nil, nil, formatter)
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "lint.sh reported problems; fix them before moving on:\n2:TODO finish")
+
+	_, agentMsg, err = editFile(context.Background(), map[string]interface{}{"path": path, "old_str": "TODO finish", "new_str": "finished"}, nil, formatter)
+	assert.NoError(t, err)
+	assert.NotContains(t, agentMsg, "reported problems")
+
+	// Go files are vetted as a package, but only inside a module
+	goPath := filepath.Join(root, "p", "p.go")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(goPath), 0755))
+	assert.NoError(t, os.WriteFile(goPath, []byte("package p\n\nimport \"fmt\"\n\nfunc F() { fmt.Printf(\"%d\", \"x\") }\n"), 0644))
+	name, problems := formatter.Lint(context.Background(), goPath)
+	assert.Empty(t, name)
+	assert.Empty(t, problems)
+	if _, err := exec.LookPath("go"); err == nil {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/lint\n\ngo 1.21\n"), 0644))
+		name, problems = formatter.Lint(context.Background(), goPath)
+		assert.Equal(t, "go vet", name)
+		assert.Contains(t, problems, "Printf format %d has arg")
+	}
+}
@@ -34,8 +34,29 @@ func generateDiff(oldContent, newContent, filePath string) string {
 	addCount := 0
 	delCount := 0
 
+	// Unchanged context is syntax highlighted so the changes stand out against real code. The new
+	// file is highlighted once and the context lines are taken from it; a line the diff only shows
+	// part of is left plain.
+	highlighted, _ := theme.HighlightFileLines(newContent, filePath)
+	newPos, newLine := 0, 0
+
 	for diffIndex, diff := range diffs {
 		lines := strings.Split(diff.Text, "\n")
+		if diff.Type == diffmatchpatch.DiffEqual && highlighted != nil {
+			startsMidLine := newPos > 0 && newContent[newPos-1] != '\n'
+			end := newPos + len(diff.Text)
+			endsMidLine := end < len(newContent) && newContent[end] != '\n'
+			for i := range lines {
+				whole := (i > 0 || !startsMidLine) && (i < len(lines)-1 || !endsMidLine)
+				if whole && newLine+i < len(highlighted) {
+					lines[i] = highlighted[newLine+i]
+				}
+			}
+		}
+		if diff.Type != diffmatchpatch.DiffDelete {
+			newPos += len(diff.Text)
+			newLine += strings.Count(diff.Text, "\n")
+		}
 
 		switch diff.Type {
 		case diffmatchpatch.DiffInsert:
@@ -83,8 +104,78 @@ func generateDiff(oldContent, newContent, filePath string) string {
 	return buff.String()
 }
 
-// NewCreateFileTool creates a create_file tool definition
-func NewCreateFileTool() models.ToolDefinition {
+// CountLineChanges returns the number of lines added and removed between two versions of a file
+func CountLineChanges(oldContent, newContent string) (int, int) {
+	dmp := diffmatchpatch.New()
+	oldChars, newChars, lines := dmp.DiffLinesToChars(oldContent, newContent)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(oldChars, newChars, false), lines)
+
+	added, removed := 0, 0
+	for _, diff := range diffs {
+		count := strings.Count(diff.Text, "\n")
+		if diff.Text != "" && !strings.HasSuffix(diff.Text, "\n") {
+			count++
+		}
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			added += count
+		case diffmatchpatch.DiffDelete:
+			removed += count
+		}
+	}
+	return added, removed
+}
+
+// PlainDiff returns an uncolored line diff of two versions of a file with three lines of context
+// around each change, for showing changes to a model
+func PlainDiff(path, oldContent, newContent string) string {
+	const context = 3
+	dmp := diffmatchpatch.New()
+	oldChars, newChars, lines := dmp.DiffLinesToChars(oldContent, newContent)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(oldChars, newChars, false), lines)
+
+	var buff strings.Builder
+	buff.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", path, path))
+	for i, diff := range diffs {
+		diffLines := strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n")
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			for _, line := range diffLines {
+				buff.WriteString("+" + line + "\n")
+			}
+		case diffmatchpatch.DiffDelete:
+			for _, line := range diffLines {
+				buff.WriteString("-" + line + "\n")
+			}
+		case diffmatchpatch.DiffEqual:
+			head, tail := context, context
+			if i == 0 {
+				head = 0
+			}
+			if i == len(diffs)-1 {
+				tail = 0
+			}
+			if len(diffLines) <= head+tail {
+				for _, line := range diffLines {
+					buff.WriteString(" " + line + "\n")
+				}
+				continue
+			}
+			for _, line := range diffLines[:head] {
+				buff.WriteString(" " + line + "\n")
+			}
+			buff.WriteString("@@\n")
+			for _, line := range diffLines[len(diffLines)-tail:] {
+				buff.WriteString(" " + line + "\n")
+			}
+		}
+	}
+	return buff.String()
+}
+
+// NewCreateFileTool creates a create_file tool definition. New files get the project's template for
+// their extension, if any, and all writes follow the project's .editorconfig.
+func NewCreateFileTool(journal *ChangeJournal, templates *FileTemplates, formatter *Formatter) models.ToolDefinition {
 	schema := map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
@@ -104,12 +195,14 @@ func NewCreateFileTool() models.ToolDefinition {
 		Name:        "create_file",
 		Description: "Create a new file with the specified content. If the file already exists, it will be overwritten.",
 		Schema:      schema,
-		Func:        createFile,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return createFile(ctx, params, journal, templates, formatter)
+		},
 	}
 }
 
 // NewEditFileTool creates an edit_file tool definition
-func NewEditFileTool() models.ToolDefinition {
+func NewEditFileTool(journal *ChangeJournal, formatter *Formatter) models.ToolDefinition {
 	schema := map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
@@ -133,12 +226,14 @@ func NewEditFileTool() models.ToolDefinition {
 		Name:        "edit_file",
 		Description: "Edit a file by replacing old_str with new_str. The old_str must match exactly including whitespace and newlines. If old_str appears multiple times, only the first occurrence will be replaced.",
 		Schema:      schema,
-		Func:        editFile,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return editFile(ctx, params, journal, formatter)
+		},
 	}
 }
 
 // NewDeleteFileTool creates a delete_file tool definition
-func NewDeleteFileTool() models.ToolDefinition {
+func NewDeleteFileTool(journal *ChangeJournal) models.ToolDefinition {
 	schema := map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
@@ -154,11 +249,13 @@ func NewDeleteFileTool() models.ToolDefinition {
 		Name:        "delete_file",
 		Description: "Delete a file from the filesystem",
 		Schema:      schema,
-		Func:        deleteFile,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return deleteFile(ctx, params, journal)
+		},
 	}
 }
 
-func createFile(ctx context.Context, params map[string]interface{}) (string, string, error) {
+func createFile(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, templates *FileTemplates, formatter *Formatter) (string, string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
 		return "", "", fmt.Errorf("path must be a string")
@@ -174,31 +271,59 @@ func createFile(ctx context.Context, params map[string]interface{}) (string, str
 		return "", "", WrapToolError("create_file", err)
 	}
 
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := ""
+	var existingContent []byte
+	isUpdate := false
+	if existingContent, err = os.ReadFile(absPath); err == nil {
+		oldContent = editorConfig.Decode(existingContent)
+		isUpdate = true
+	}
+
+	// Templates only apply to new files; overwriting keeps whatever header the file had
+	applied := ""
+	if !isUpdate {
+		content, applied, err = templates.Apply(absPath, content)
+		if err != nil {
+			return "", "", WrapToolError("create_file", err)
+		}
+	}
+
+	content = editorConfig.Normalize(content)
+
+	if IsDryRun(ctx) {
+		action := "would create the file"
+		if isUpdate {
+			action = "would overwrite the file"
+		}
+		return generateDiff(oldContent, content, absPath), dryRunResult(action), nil
+	}
+
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", "", WrapToolError("create_file", fmt.Errorf("failed to create directory %s: %w", dir, err))
 	}
 
-	oldContent := ""
-	isUpdate := false
-	if existingContent, err := os.ReadFile(absPath); err == nil {
-		oldContent = string(existingContent)
-		isUpdate = true
+	if err := journal.Record("create_file", absPath, existingContent, isUpdate); err != nil {
+		return "", "", WrapToolError("create_file", err)
 	}
 
-	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
-		return "", "", WrapToolError("create_file", fmt.Errorf("failed to write file: %w", err))
+	content, formatNote, err := writeFile(ctx, absPath, content, editorConfig, formatter)
+	if err != nil {
+		return "", "", WrapToolError("create_file", err)
 	}
 
 	agentMessage := "Created"
 	if isUpdate {
 		agentMessage = "Updated"
+	} else if applied != "" {
+		agentMessage = fmt.Sprintf("Created (applied project template %s; the file content differs from what you wrote)", filepath.Base(applied))
 	}
 
-	return generateDiff(oldContent, content, absPath), agentMessage, nil
+	return generateDiff(oldContent, content, absPath), agentMessage + formatNote, nil
 }
 
-func editFile(ctx context.Context, params map[string]interface{}) (string, string, error) {
+func editFile(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, formatter *Formatter) (string, string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
 		return "", "", fmt.Errorf("path must be a string")
@@ -224,22 +349,43 @@ func editFile(ctx context.Context, params map[string]interface{}) (string, strin
 		return "", "", WrapToolError("edit_file", fmt.Errorf("failed to read file: %w", err))
 	}
 
-	oldContent := string(content)
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := editorConfig.Decode(content)
 
+	// Models write \n; match and insert CRLF in files that already use it
+	crlf := strings.Contains(oldContent, "\r\n")
+	if crlf && !strings.Contains(oldContent, oldStr) {
+		oldStr = strings.ReplaceAll(oldStr, "\n", "\r\n")
+	}
 	if !strings.Contains(oldContent, oldStr) {
 		return "", "", WrapToolError("edit_file", fmt.Errorf("old_str not found in file"))
 	}
 
-	newContent := strings.Replace(oldContent, oldStr, newStr, 1)
+	if crlf && editorConfig.EndOfLine == "" {
+		editorConfig.EndOfLine = "crlf"
+	}
+	newContent := editorConfig.ReplaceFragments(oldContent, oldStr, newStr, 1)
+	if editorConfig.InsertFinalNewline != nil && *editorConfig.InsertFinalNewline && newContent != "" && !strings.HasSuffix(newContent, "\n") && !strings.HasSuffix(newContent, "\r") {
+		newContent += editorConfig.NormalizeFragment("\n", newContent, "")
+	}
 
-	if err := os.WriteFile(absPath, []byte(newContent), 0644); err != nil {
-		return "", "", WrapToolError("edit_file", fmt.Errorf("failed to write file: %w", err))
+	if IsDryRun(ctx) {
+		return generateDiff(oldContent, newContent, absPath), dryRunResult("would apply the edit"), nil
 	}
 
-	return generateDiff(oldContent, newContent, absPath), "Updated", nil
+	if err := journal.Record("edit_file", absPath, content, true); err != nil {
+		return "", "", WrapToolError("edit_file", err)
+	}
+
+	newContent, formatNote, err := writeFile(ctx, absPath, newContent, editorConfig, formatter)
+	if err != nil {
+		return "", "", WrapToolError("edit_file", err)
+	}
+
+	return generateDiff(oldContent, newContent, absPath), "Updated" + formatNote, nil
 }
 
-func deleteFile(ctx context.Context, params map[string]interface{}) (string, string, error) {
+func deleteFile(ctx context.Context, params map[string]interface{}, journal *ChangeJournal) (string, string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
 		return "", "", fmt.Errorf("path must be a string")
@@ -256,6 +402,14 @@ func deleteFile(ctx context.Context, params map[string]interface{}) (string, str
 	}
 	oldContent := string(content)
 
+	if IsDryRun(ctx) {
+		return generateDiff(oldContent, "", absPath), dryRunResult("would delete the file"), nil
+	}
+
+	if err := journal.Record("delete_file", absPath, content, true); err != nil {
+		return "", "", WrapToolError("delete_file", err)
+	}
+
 	if err := os.Remove(absPath); err != nil {
 		return "", "", WrapToolError("delete_file", fmt.Errorf("failed to delete file: %w", err))
 	}
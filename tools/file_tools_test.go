@@ -7,6 +7,11 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"agent/theme"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 func TestEditFile(t *testing.T) {
@@ -38,7 +43,7 @@ func TestEditFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, _, err := editFile(ctx, tt.params)
+			_, _, err := editFile(ctx, tt.params, nil, nil)
 			if err == nil {
 				t.Errorf("expected error containing %q, got nil", tt.wantErr)
 			} else if !strings.Contains(err.Error(), tt.wantErr) {
@@ -54,7 +59,7 @@ func TestEditFile(t *testing.T) {
 		"new_str": "modified line 2",
 	}
 
-	userMsg, agentMsg, err := editFile(ctx, params)
+	userMsg, agentMsg, err := editFile(ctx, params, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -105,7 +110,7 @@ func TestCreateFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, _, err := createFile(ctx, tt.params)
+			_, _, err := createFile(ctx, tt.params, nil, nil, nil)
 			if err == nil {
 				t.Errorf("expected error containing %q, got nil", tt.wantErr)
 			} else if !strings.Contains(err.Error(), tt.wantErr) {
@@ -120,7 +125,7 @@ func TestCreateFile(t *testing.T) {
 		"content": "hello world",
 	}
 
-	userMsg, agentMsg, err := createFile(ctx, params)
+	userMsg, agentMsg, err := createFile(ctx, params, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -150,7 +155,7 @@ func TestCreateFile(t *testing.T) {
 		"content": "new content",
 	}
 
-	userMsg, agentMsg, err = createFile(ctx, overwriteParams)
+	userMsg, agentMsg, err = createFile(ctx, overwriteParams, nil, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -198,7 +203,7 @@ func TestDeleteFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, _, err := deleteFile(ctx, tt.params)
+			_, _, err := deleteFile(ctx, tt.params, nil)
 			if err == nil {
 				t.Errorf("expected error containing %q, got nil", tt.wantErr)
 			} else if !strings.Contains(err.Error(), tt.wantErr) {
@@ -212,7 +217,7 @@ func TestDeleteFile(t *testing.T) {
 		"path": testFile,
 	}
 
-	userMsg, agentMsg, err := deleteFile(ctx, params)
+	userMsg, agentMsg, err := deleteFile(ctx, params, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -315,3 +320,98 @@ func TestGenerateDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateDiffHighlightsContext(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	oldContent := "package main\n\n/* one\ntwo */\nfunc a() {}\n"
+	newContent := "package main\n\n/* one\ntwo */\nfunc b() {}\n"
+	lines, ok := theme.HighlightFileLines(newContent, "main.go")
+	if !ok {
+		t.Fatal("expected main.go to be highlighted")
+	}
+
+	// Context inside a comment is colored as the comment it is, not as code on its own
+	diff := generateDiff(oldContent, newContent, "main.go")
+	for _, line := range lines[2:4] {
+		if !strings.Contains(diff, line) {
+			t.Errorf("expected diff to contain highlighted line %q. Diff:\n%q", line, diff)
+		}
+	}
+}
+
+func TestCountLineChanges(t *testing.T) {
+	tests := []struct {
+		name            string
+		oldContent      string
+		newContent      string
+		expectedAdded   int
+		expectedRemoved int
+	}{
+		{"modify and append", "a\nb\nc\n", "a\nB\nc\nd\n", 2, 1},
+		{"new file without trailing newline", "", "one\ntwo", 2, 0},
+		{"unchanged", "same\n", "same\n", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := CountLineChanges(tt.oldContent, tt.newContent)
+			if added != tt.expectedAdded || removed != tt.expectedRemoved {
+				t.Errorf("expected +%d -%d, got +%d -%d", tt.expectedAdded, tt.expectedRemoved, added, removed)
+			}
+		})
+	}
+}
+
+func TestPlainDiff(t *testing.T) {
+	oldContent := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newContent := "a\nB\nc\nd\ne\nf\ng\nh\ni\nJ\n"
+	want := "--- f.txt\n+++ f.txt\n a\n-b\n+B\n c\n d\n e\n@@\n g\n h\n i\n-j\n+J\n"
+	if got := PlainDiff("f.txt", oldContent, newContent); got != want {
+		t.Errorf("PlainDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestFileToolsDryRun(t *testing.T) {
+	ctx := WithDryRun(context.Background())
+	tempDir := t.TempDir()
+	existing := filepath.Join(tempDir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("line 1\nline 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal := NewChangeJournal(filepath.Join(tempDir, "checkpoints"))
+
+	created := filepath.Join(tempDir, "sub", "new.txt")
+	diff, result, err := createFile(ctx, map[string]interface{}{"path": created, "content": "hello"}, journal, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "hello") || !strings.HasPrefix(result, "DRY RUN: would create") {
+		t.Errorf("unexpected dry run result %q with diff %q", result, diff)
+	}
+	if _, err := os.Stat(filepath.Dir(created)); !os.IsNotExist(err) {
+		t.Errorf("dry run created the directory: %v", err)
+	}
+
+	if _, result, err = editFile(ctx, map[string]interface{}{"path": existing, "old_str": "line 2", "new_str": "changed"}, journal, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "DRY RUN: would apply the edit") {
+		t.Errorf("unexpected dry run result %q", result)
+	}
+	if _, result, err = deleteFile(ctx, map[string]interface{}{"path": existing}, journal); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "DRY RUN: would delete") {
+		t.Errorf("unexpected dry run result %q", result)
+	}
+
+	if data, err := os.ReadFile(existing); err != nil || string(data) != "line 1\nline 2\n" {
+		t.Errorf("dry run changed the file: %q, %v", data, err)
+	}
+	if entries := journal.Entries(); len(entries) != 0 {
+		t.Errorf("dry run recorded %d journal entries", len(entries))
+	}
+}
@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FormatConfig controls formatting and linting of files the agent writes
+type FormatConfig struct {
+	OnWrite      bool              `json:"on_write"`      // run the project formatter on each file the agent writes
+	Commands     map[string]string `json:"commands"`      // formatter command by extension, e.g. {".go": "gofumpt -w"}; the path is appended
+	Lint         bool              `json:"lint"`          // run the project linter on each file the agent writes and report its problems
+	LintCommands map[string]string `json:"lint_commands"` // linter command by extension, e.g. {".go": "golangci-lint run"}; the path is appended
+}
+
+// defaultFormatters are used for extensions without a configured command, when installed
+var defaultFormatters = map[string]string{
+	".go":   "gofmt -w",
+	".py":   "ruff format -q",
+	".rs":   "rustfmt",
+	".js":   "prettier --write --log-level warn",
+	".jsx":  "prettier --write --log-level warn",
+	".ts":   "prettier --write --log-level warn",
+	".tsx":  "prettier --write --log-level warn",
+	".css":  "prettier --write --log-level warn",
+	".json": "prettier --write --log-level warn",
+}
+
+// projectLinter is the linter used for a file type in projects marked by a file such as go.mod
+type projectLinter struct {
+	marker  string
+	command string
+	dir     bool // lint the file's directory (its Go package) instead of the file
+}
+
+// defaultLinters are used for extensions without a configured command, when the file is inside a
+// project with the marker file and the linter is installed
+var defaultLinters = map[string]projectLinter{
+	".go":  {marker: "go.mod", command: "go vet", dir: true},
+	".py":  {marker: "pyproject.toml", command: "ruff check -q"},
+	".js":  {marker: "package.json", command: "eslint"},
+	".jsx": {marker: "package.json", command: "eslint"},
+	".ts":  {marker: "package.json", command: "eslint"},
+	".tsx": {marker: "package.json", command: "eslint"},
+}
+
+// Formatter makes file writes follow the project's .editorconfig and, optionally, runs the
+// project's formatter on written files. A nil Formatter writes content unchanged.
+type Formatter struct {
+	config FormatConfig
+}
+
+// NewFormatter creates a formatter with the given settings
+func NewFormatter(config FormatConfig) *Formatter {
+	return &Formatter{config: config}
+}
+
+// EditorConfig returns the .editorconfig properties for path
+func (f *Formatter) EditorConfig(path string) EditorConfig {
+	if f == nil {
+		return EditorConfig{}
+	}
+	return LoadEditorConfig(path)
+}
+
+// Format runs the formatter for path's extension and returns its name, or "" when formatting on
+// write is off or there's no formatter installed for the file type
+func (f *Formatter) Format(ctx context.Context, path string) (string, error) {
+	if f == nil || !f.config.OnWrite {
+		return "", nil
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	command, ok := f.config.Commands[ext]
+	if !ok {
+		command = defaultFormatters[ext]
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", nil
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", nil
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], path)...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = os.Environ()
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(output.String())
+		if len(message) > 2000 {
+			message = message[:2000] + "..."
+		}
+		return args[0], fmt.Errorf("%s failed: %s", args[0], message)
+	}
+	return args[0], nil
+}
+
+// Lint runs the linter for path's extension and returns its name and, when it found problems, its
+// output. Both are empty when linting is off or there's no linter for the file.
+func (f *Formatter) Lint(ctx context.Context, path string) (string, string) {
+	if f == nil || !f.config.Lint {
+		return "", ""
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	target := path
+	args := strings.Fields(f.config.LintCommands[ext])
+	if _, ok := f.config.LintCommands[ext]; !ok {
+		linter, ok := defaultLinters[ext]
+		if !ok {
+			return "", ""
+		}
+		root := findProjectRoot(filepath.Dir(path), linter.marker)
+		if root == "" {
+			return "", ""
+		}
+		args = strings.Fields(linter.command)
+		// Prefer the project's own copy of node tools
+		if local := filepath.Join(root, "node_modules", ".bin", args[0]); fileExists(local) {
+			args[0] = local
+		}
+		if linter.dir {
+			target = "."
+		}
+	}
+	if len(args) == 0 {
+		return "", ""
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", ""
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], target)...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = os.Environ()
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	name := strings.Join(append([]string{filepath.Base(args[0])}, args[1:]...), " ")
+	if err := cmd.Run(); err == nil {
+		return name, ""
+	}
+	message := strings.TrimSpace(output.String())
+	if message == "" {
+		message = "it exited with an error and no output"
+	}
+	if len(message) > 2000 {
+		message = message[:2000] + "..."
+	}
+	return name, message
+}
+
+// findProjectRoot returns the nearest directory at or above dir that contains marker, or ""
+func findProjectRoot(dir, marker string) string {
+	for {
+		if fileExists(filepath.Join(dir, marker)) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeFile writes content in the charset set by config, runs the formatter and linter, and returns
+// the content as it ended up on disk along with a note telling the model about formatting and any
+// problems the linter found
+func writeFile(ctx context.Context, path, content string, config EditorConfig, formatter *Formatter) (string, string, error) {
+	encoded, err := config.Encode(content)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, encoded, 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	content, note := formatFile(ctx, path, content, config, formatter)
+	if linter, problems := formatter.Lint(ctx, path); problems != "" {
+		note += fmt.Sprintf("\n\n%s reported problems; fix them before moving on:\n%s", linter, problems)
+	}
+	return content, note, nil
+}
+
+// formatFile runs the formatter on a written file and returns its content with a note about what
+// the formatter did
+func formatFile(ctx context.Context, path, content string, config EditorConfig, formatter *Formatter) (string, string) {
+	name, err := formatter.Format(ctx, path)
+	if err != nil {
+		return content, fmt.Sprintf(" (the file was written but %v)", err)
+	}
+	if name == "" {
+		return content, ""
+	}
+	formatted, err := os.ReadFile(path)
+	if err != nil {
+		return content, ""
+	}
+	if formatted := config.Decode(formatted); formatted != content {
+		return formatted, fmt.Sprintf(" (formatted with %s; the file content differs from what you wrote)", name)
+	}
+	return content, ""
+}
@@ -0,0 +1,418 @@
+package tools
+
+import (
+	"agent/models"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs a program in the working directory and returns trimmed stdout, or an error carrying stderr
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("%s %s failed: %s", name, args[0], message)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// runGitCommand runs git in the working directory
+func runGitCommand(ctx context.Context, args ...string) (string, error) {
+	return runCommand(ctx, "git", args...)
+}
+
+// NewGitStatusTool creates the git_status tool
+func NewGitStatusTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "git_status",
+		Description: "Show the current branch, upstream tracking, and staged, unstaged, untracked, and conflicted files as a compact summary. Use this instead of running `git status` in the shell.",
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		Func: gitStatus,
+	}
+}
+
+// NewGitDiffTool creates the git_diff tool
+func NewGitDiffTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "git_diff",
+		Description: "Show changes in the working tree or index as a per-file summary followed by the unified diff. Use this instead of running `git diff` in the shell.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"staged": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Optional: Show staged changes instead of unstaged changes (default: false)",
+				},
+				"ref": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Compare the working tree against this commit, branch, or range (e.g. main, HEAD~3, main...HEAD)",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Limit the diff to this file or directory",
+				},
+				"stat_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Optional: Only return the per-file summary (default: false)",
+				},
+				"max_lines": map[string]interface{}{
+					"type":        "integer",
+					"description": "Optional: Maximum diff lines to return (default: 400)",
+					"minimum":     1,
+				},
+			},
+		},
+		Func: gitDiff,
+	}
+}
+
+// NewGitLogTool creates the git_log tool
+func NewGitLogTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "git_log",
+		Description: "List recent commits as one line each (hash, date, author, subject). Use this instead of running `git log` in the shell.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"count": map[string]interface{}{
+					"type":        "integer",
+					"description": "Optional: Number of commits to list (default: 10)",
+					"minimum":     1,
+				},
+				"ref": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Branch, commit, or range to list (default: HEAD)",
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Only list commits touching this file or directory",
+				},
+			},
+		},
+		Func: gitLog,
+	}
+}
+
+// NewGitCommitTool creates the git_commit tool
+func NewGitCommitTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "git_commit",
+		Description: "Stage files and create a commit. Only commit when the user asked for it.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Commit message",
+				},
+				"paths": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Optional: Files to stage before committing",
+				},
+				"all": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Optional: Stage all changes, including untracked files, before committing (default: false)",
+				},
+			},
+			"required": []interface{}{"message"},
+		},
+		Func: gitCommit,
+	}
+}
+
+// NewGitBranchTool creates the git_branch tool
+func NewGitBranchTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "git_branch",
+		Description: "List, create, or switch branches.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: What to do (default: list)",
+					"enum":        []interface{}{"list", "create", "switch"},
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Branch name (required for create and switch)",
+				},
+			},
+		},
+		Func: gitBranch,
+	}
+}
+
+func gitStatus(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	// -z keeps paths with spaces, quotes, or non-ASCII characters as they are
+	output, err := runGitCommand(ctx, "status", "--porcelain=v1", "-z", "--branch")
+	if err != nil {
+		return "", "", WrapToolError("git_status", err)
+	}
+	branch, tracking, staged, unstaged, untracked, conflicts := parseGitStatus(output)
+
+	var summary strings.Builder
+	summary.WriteString("Branch: " + branch)
+	if len(tracking) > 0 {
+		summary.WriteString(" (" + strings.Join(tracking, ", ") + ")")
+	}
+	summary.WriteString("\n")
+
+	sections := []struct {
+		name  string
+		files []string
+	}{
+		{"Conflicts", conflicts},
+		{"Staged", staged},
+		{"Unstaged", unstaged},
+		{"Untracked", untracked},
+	}
+	clean := true
+	for _, section := range sections {
+		if len(section.files) > 0 {
+			clean = false
+			summary.WriteString(fmt.Sprintf("%s (%d): %s\n", section.name, len(section.files), strings.Join(section.files, ", ")))
+		}
+	}
+	if clean {
+		summary.WriteString("Working tree clean\n")
+	}
+
+	result := strings.TrimRight(summary.String(), "\n")
+	return result + "\n", result, nil
+}
+
+// conflictStatuses are the porcelain XY codes of unmerged paths
+var conflictStatuses = map[string]bool{"DD": true, "AU": true, "UD": true, "UA": true, "DU": true, "AA": true, "UU": true}
+
+// parseGitStatus reads the NUL-separated records of `git status --porcelain=v1 -z --branch`. Renamed
+// and copied files are listed as "new <- old".
+func parseGitStatus(output string) (branch string, tracking, staged, unstaged, untracked, conflicts []string) {
+	branch = "(unknown)"
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if head, ok := strings.CutPrefix(record, "## "); ok {
+			branch, tracking = parseBranchHeader(head)
+			continue
+		}
+		if len(record) < 4 {
+			continue
+		}
+		xy, path := record[:2], record[3:]
+		// Renames and copies are followed by the original path
+		if (xy[0] == 'R' || xy[0] == 'C' || xy[1] == 'R' || xy[1] == 'C') && i+1 < len(records) {
+			i++
+			path += " <- " + records[i]
+		}
+		switch {
+		case xy == "??":
+			untracked = append(untracked, path)
+		case xy == "!!":
+		case conflictStatuses[xy]:
+			conflicts = append(conflicts, xy+" "+path)
+		default:
+			if xy[0] != ' ' {
+				staged = append(staged, fmt.Sprintf("%c %s", xy[0], path))
+			}
+			if xy[1] != ' ' {
+				unstaged = append(unstaged, fmt.Sprintf("%c %s", xy[1], path))
+			}
+		}
+	}
+	return branch, tracking, staged, unstaged, untracked, conflicts
+}
+
+// parseBranchHeader reads the "## " header of porcelain v1 status, such as
+// "main...origin/main [ahead 1, behind 2]"
+func parseBranchHeader(head string) (string, []string) {
+	if branch, ok := strings.CutPrefix(head, "No commits yet on "); ok {
+		return branch, nil
+	}
+	if strings.HasPrefix(head, "HEAD (no branch)") {
+		return "(detached)", nil
+	}
+	var tracking []string
+	head, counts, _ := strings.Cut(head, " [")
+	branch, upstream, found := strings.Cut(head, "...")
+	if found {
+		tracking = append(tracking, "upstream "+upstream)
+	}
+	if counts = strings.TrimSuffix(counts, "]"); counts != "" {
+		tracking = append(tracking, counts)
+	}
+	return branch, tracking
+}
+
+// checkRefArg rejects a ref or branch name that git would read as an option, such as
+// --output=<file>, which would let a read-only tool write files
+func checkRefArg(param, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("%s can't start with '-', got %q", param, value)
+	}
+	return nil
+}
+
+func gitDiff(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	args := []string{"diff"}
+	if staged, _ := params["staged"].(bool); staged {
+		args = append(args, "--cached")
+	}
+	if ref, ok := params["ref"].(string); ok && ref != "" {
+		if err := checkRefArg("ref", ref); err != nil {
+			return "", "", err
+		}
+		args = append(args, ref)
+	}
+
+	var pathArgs []string
+	if path, ok := params["path"].(string); ok && path != "" {
+		pathArgs = []string{"--", path}
+	}
+
+	maxLines := 400
+	if ml, ok := params["max_lines"].(float64); ok && ml > 0 {
+		maxLines = int(ml)
+	}
+
+	stat, err := runGitCommand(ctx, append(append(append([]string{}, args...), "--stat"), pathArgs...)...)
+	if err != nil {
+		return "", "", WrapToolError("git_diff", err)
+	}
+	if stat == "" {
+		return "No changes\n", "No changes", nil
+	}
+
+	if statOnly, _ := params["stat_only"].(bool); statOnly {
+		return stat + "\n", stat, nil
+	}
+
+	diff, err := runGitCommand(ctx, append(append(args, "--no-color", "--no-ext-diff"), pathArgs...)...)
+	if err != nil {
+		return "", "", WrapToolError("git_diff", err)
+	}
+
+	lines := strings.Split(diff, "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], fmt.Sprintf("... (%d more lines; narrow with path or use stat_only)", len(lines)-maxLines))
+	}
+
+	result := stat + "\n\n" + strings.Join(lines, "\n")
+	return stat + "\n", result, nil
+}
+
+func gitLog(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	count := 10
+	if c, ok := params["count"].(float64); ok && c > 0 {
+		count = int(c)
+	}
+
+	args := []string{"log", fmt.Sprintf("-n%d", count), "--date=short", "--pretty=format:%h %ad %an: %s"}
+	if ref, ok := params["ref"].(string); ok && ref != "" {
+		if err := checkRefArg("ref", ref); err != nil {
+			return "", "", err
+		}
+		args = append(args, ref)
+	}
+	if path, ok := params["path"].(string); ok && path != "" {
+		args = append(args, "--", path)
+	}
+
+	output, err := runGitCommand(ctx, args...)
+	if err != nil {
+		return "", "", WrapToolError("git_log", err)
+	}
+	if output == "" {
+		return "No commits\n", "No commits", nil
+	}
+
+	return output + "\n", output, nil
+}
+
+func gitCommit(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	message, ok := params["message"].(string)
+	if !ok || strings.TrimSpace(message) == "" {
+		return "", "", fmt.Errorf("message must be a non-empty string")
+	}
+
+	if all, _ := params["all"].(bool); all {
+		if _, err := runGitCommand(ctx, "add", "-A"); err != nil {
+			return "", "", WrapToolError("git_commit", err)
+		}
+	}
+	if paths, ok := params["paths"].([]interface{}); ok && len(paths) > 0 {
+		addArgs := []string{"add", "--"}
+		for _, p := range paths {
+			if str, ok := p.(string); ok {
+				addArgs = append(addArgs, str)
+			}
+		}
+		if _, err := runGitCommand(ctx, addArgs...); err != nil {
+			return "", "", WrapToolError("git_commit", err)
+		}
+	}
+
+	if _, err := runGitCommand(ctx, "commit", "-m", message); err != nil {
+		return "", "", WrapToolError("git_commit", err)
+	}
+
+	summary, err := runGitCommand(ctx, "show", "--stat", "--oneline", "--no-color", "HEAD")
+	if err != nil {
+		return "", "", WrapToolError("git_commit", err)
+	}
+
+	return summary + "\n", "Committed " + summary, nil
+}
+
+func gitBranch(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	action, _ := params["action"].(string)
+	name, _ := params["name"].(string)
+
+	switch action {
+	case "", "list":
+		output, err := runGitCommand(ctx, "branch", "--format=%(HEAD) %(refname:short) %(upstream:short) %(upstream:track)")
+		if err != nil {
+			return "", "", WrapToolError("git_branch", err)
+		}
+		var lines []string
+		for _, line := range strings.Split(output, "\n") {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+		result := strings.Join(lines, "\n")
+		return result + "\n", result, nil
+	case "create", "switch":
+		if name == "" {
+			return "", "", fmt.Errorf("name is required to %s a branch", action)
+		}
+		if err := checkRefArg("name", name); err != nil {
+			return "", "", err
+		}
+		args := []string{"switch", name}
+		if action == "create" {
+			args = []string{"switch", "-c", name}
+		}
+		if _, err := runGitCommand(ctx, args...); err != nil {
+			return "", "", WrapToolError("git_branch", err)
+		}
+		result := fmt.Sprintf("Switched to branch %s", name)
+		if action == "create" {
+			result = fmt.Sprintf("Created and switched to branch %s", name)
+		}
+		return result + "\n", result, nil
+	default:
+		return "", "", fmt.Errorf("action must be one of: list, create, switch")
+	}
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitTools(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	originalDir, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(tempDir))
+	defer os.Chdir(originalDir)
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	assert.NoError(t, exec.Command("git", "init", "-q", "-b", "main").Run())
+
+	// Commit an initial file
+	assert.NoError(t, os.WriteFile("a.txt", []byte("one\n"), 0644))
+	_, agentMsg, err := NewGitCommitTool().Func(ctx, map[string]interface{}{"message": "initial commit", "all": true})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "initial commit")
+
+	_, agentMsg, err = NewGitStatusTool().Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Branch: main\nWorking tree clean", agentMsg)
+
+	// Modify, stage, and add untracked files
+	assert.NoError(t, os.WriteFile("a.txt", []byte("two\n"), 0644))
+	assert.NoError(t, os.WriteFile("b.txt", []byte("new\n"), 0644))
+	assert.NoError(t, os.WriteFile("c.txt", []byte("untracked\n"), 0644))
+	assert.NoError(t, exec.Command("git", "add", "b.txt").Run())
+
+	_, agentMsg, err = NewGitStatusTool().Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Staged (1): A b.txt")
+	assert.Contains(t, agentMsg, "Unstaged (1): M a.txt")
+	assert.Contains(t, agentMsg, "Untracked (1): c.txt")
+
+	// Paths with spaces and renames come through -z as they are
+	assert.NoError(t, exec.Command("git", "mv", "b.txt", "b renamed.txt").Run())
+	assert.NoError(t, os.WriteFile("d \"quoted\".txt", []byte("x\n"), 0644))
+	_, agentMsg, err = NewGitStatusTool().Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Staged (1): A b renamed.txt")
+	assert.Contains(t, agentMsg, "Untracked (2): c.txt, d \"quoted\".txt")
+	assert.NoError(t, exec.Command("git", "mv", "b renamed.txt", "b.txt").Run())
+	assert.NoError(t, os.Remove("d \"quoted\".txt"))
+
+	_, agentMsg, err = NewGitDiffTool().Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "a.txt | 2 +-")
+	assert.Contains(t, agentMsg, "+two")
+
+	_, agentMsg, err = NewGitDiffTool().Func(ctx, map[string]interface{}{"staged": true, "stat_only": true})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "b.txt")
+	assert.NotContains(t, agentMsg, "+new")
+
+	// Branch and log
+	_, _, err = NewGitBranchTool().Func(ctx, map[string]interface{}{"action": "create", "name": "feature"})
+	assert.NoError(t, err)
+	_, agentMsg, err = NewGitBranchTool().Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "* feature")
+
+	_, agentMsg, err = NewGitLogTool().Func(ctx, map[string]interface{}{"count": float64(5)})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Test: initial commit")
+
+	_, _, err = NewGitCommitTool().Func(ctx, map[string]interface{}{"message": ""})
+	assert.Error(t, err)
+
+	// Refs and branch names that git would read as options are refused, so nothing is written
+	output := filepath.Join(tempDir, "written.txt")
+	for _, call := range []struct {
+		tool   func() models.ToolDefinition
+		params map[string]interface{}
+	}{
+		{NewGitDiffTool, map[string]interface{}{"ref": "--output=" + output}},
+		{NewGitLogTool, map[string]interface{}{"ref": "--output=" + output}},
+		{NewGitBranchTool, map[string]interface{}{"action": "create", "name": "--output=" + output}},
+		{NewGitBranchTool, map[string]interface{}{"action": "switch", "name": "-"}},
+	} {
+		_, _, err = call.tool().Func(ctx, call.params)
+		assert.ErrorContains(t, err, "can't start with '-'")
+	}
+	assert.NoFileExists(t, output)
+}
+
+func TestParseGitStatus(t *testing.T) {
+	output := "## main...origin/main [ahead 2, behind 1]\x00" +
+		"M  staged.go\x00" +
+		" M unstaged file.go\x00" +
+		"MM both.go\x00" +
+		"R  new name.go\x00old name.go\x00" +
+		"UU conflict.go\x00" +
+		"?? untracked.go\x00"
+	branch, tracking, staged, unstaged, untracked, conflicts := parseGitStatus(output)
+	assert.Equal(t, "main", branch)
+	assert.Equal(t, []string{"upstream origin/main", "ahead 2, behind 1"}, tracking)
+	assert.Equal(t, []string{"M staged.go", "M both.go", "R new name.go <- old name.go"}, staged)
+	assert.Equal(t, []string{"M unstaged file.go", "M both.go"}, unstaged)
+	assert.Equal(t, []string{"untracked.go"}, untracked)
+	assert.Equal(t, []string{"UU conflict.go"}, conflicts)
+
+	for header, want := range map[string]string{
+		"## No commits yet on trunk\x00": "trunk",
+		"## HEAD (no branch)\x00":        "(detached)",
+		"## feature\x00":                 "feature",
+	} {
+		branch, tracking, _, _, _, _ := parseGitStatus(header)
+		assert.Equal(t, want, branch)
+		assert.Empty(t, tracking)
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/models"
+	"agent/permissions"
+)
+
+const defaultGlobLimit = 100
+
+// globSkippedDirs are never searched unless the pattern names them
+var globSkippedDirs = []string{".git", "node_modules", ".venv", "__pycache__"}
+
+// NewGlobTool creates the glob tool
+func NewGlobTool() models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "Glob matched against paths relative to the search directory: * and ? stay within a directory, ** spans directories, {a,b} matches either, e.g. \"**/*_test.go\" or \"src/**/*.{ts,tsx}\"",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: Directory to search (default: the working directory)",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Optional: Maximum number of paths to return (default: %d)", defaultGlobLimit),
+				"minimum":     1,
+			},
+		},
+		"required": []interface{}{"pattern"},
+	}
+
+	return models.ToolDefinition{
+		Name:        "glob",
+		Description: "Find files by name pattern. Returns matching paths, most recently modified first, without adding anything to context. Use it to locate files before reading them.",
+		Schema:      schema,
+		Func:        globFiles,
+	}
+}
+
+func globFiles(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	pattern, ok := params["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", "", fmt.Errorf("pattern must be a non-empty string")
+	}
+	root, _ := params["path"].(string)
+	if root == "" {
+		root = "."
+	}
+	limit := defaultGlobLimit
+	if value, ok := params["limit"].(float64); ok && value >= 1 {
+		limit = int(value)
+	}
+
+	matcher, err := permissions.GlobRegexp(filepath.ToSlash(strings.TrimPrefix(pattern, "./")), true)
+	if err != nil {
+		return "", "", WrapToolError("glob", err)
+	}
+
+	type match struct {
+		path     string
+		modified time.Time
+	}
+	var matches []match
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the search
+			if entry != nil && entry.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			for _, skipped := range globSkippedDirs {
+				if entry.Name() == skipped && !strings.Contains(pattern, skipped) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if matcher.MatchString(rel) {
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			matches = append(matches, match{path: filepath.Join(root, rel), modified: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", WrapToolError("glob", err)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No files match %s\n", pattern), fmt.Sprintf("No files match %s in %s", pattern, root), nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].modified.Equal(matches[j].modified) {
+			return matches[i].modified.After(matches[j].modified)
+		}
+		return matches[i].path < matches[j].path
+	})
+
+	var result strings.Builder
+	for i, match := range matches {
+		if i == limit {
+			result.WriteString(fmt.Sprintf("... %d more; narrow the pattern or raise the limit\n", len(matches)-limit))
+			break
+		}
+		result.WriteString(match.path + "\n")
+	}
+	return fmt.Sprintf("Found %d files matching %s\n", len(matches), pattern), strings.TrimRight(result.String(), "\n"), nil
+}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, path := range []string{"old_test.go", "pkg/new_test.go", "pkg/code.go", "node_modules/dep/x_test.go"} {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte("package x\n"), 0644))
+		modified := now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(full, modified, modified))
+	}
+
+	_, result, err := globFiles(context.Background(), map[string]interface{}{"pattern": "**/*_test.go", "path": dir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "pkg/new_test.go"), filepath.Join(dir, "old_test.go")}, strings.Split(result, "\n"), "newest first, node_modules skipped")
+
+	_, result, err = globFiles(context.Background(), map[string]interface{}{"pattern": "**/*.go", "path": dir, "limit": float64(1)})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "pkg/code.go")+"\n... 2 more; narrow the pattern or raise the limit", result)
+}
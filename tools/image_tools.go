@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MaxImageBytes is the largest image providers accept
+const MaxImageBytes = 20 * 1024 * 1024
+
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+
+// IsImagePath reports whether path has an image extension models accept
+func IsImagePath(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// ImageDataURL reads an image file into a data: URL
+func ImageDataURL(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > MaxImageBytes {
+		return "", fmt.Errorf("image is %d MB, over the %d MB limit", info.Size()/(1024*1024), MaxImageBytes/(1024*1024))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ImageCollector gathers images that tools attach to their results. Tool results can only hold
+// text, so the images are sent to the model in a message after them.
+type ImageCollector struct {
+	mu     sync.Mutex
+	images []string
+}
+
+type imageCollectorKey struct{}
+
+// WithImageCollector returns a context in which tools can attach images to their result
+func WithImageCollector(ctx context.Context) (context.Context, *ImageCollector) {
+	collector := &ImageCollector{}
+	return context.WithValue(ctx, imageCollectorKey{}, collector), collector
+}
+
+// Images returns the attached images
+func (c *ImageCollector) Images() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.images...)
+}
+
+// AttachImage adds an image, given as a URL or data: URL, to the running tool's result
+func AttachImage(ctx context.Context, image string) error {
+	collector, ok := ctx.Value(imageCollectorKey{}).(*ImageCollector)
+	if !ok {
+		return fmt.Errorf("images can't be returned to this agent")
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.images = append(collector.images, image)
+	return nil
+}
+
+// NewViewImageTool creates the view_image tool. vision reports whether the current model accepts images.
+func NewViewImageTool(vision func() bool) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "view_image",
+		Description: "Look at an image, such as a screenshot, design mock, or rendered diagram. The image is shown to you after the tool result; the user only sees which image was viewed.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path to a local image (png, jpg, gif, or webp), or an http(s) URL",
+				},
+			},
+			"required": []interface{}{"path"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return viewImage(ctx, params, vision)
+		},
+	}
+}
+
+func viewImage(ctx context.Context, params map[string]interface{}, vision func() bool) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("path must be a non-empty string")
+	}
+	if !vision() {
+		return "", "", fmt.Errorf("the current model can't view images")
+	}
+
+	image := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		if !IsImagePath(path) {
+			return "", "", fmt.Errorf("%s is not a png, jpg, gif, or webp image", path)
+		}
+		var err error
+		if image, err = ImageDataURL(path); err != nil {
+			return "", "", WrapToolError("view_image", err)
+		}
+	}
+	if err := AttachImage(ctx, image); err != nil {
+		return "", "", err
+	}
+	return "🖼  " + path + "\n", "Viewing " + path + "; the image follows the tool results.", nil
+}
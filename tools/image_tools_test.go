@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mock.png")
+	assert.NoError(t, os.WriteFile(path, []byte("\x89PNG"), 0644))
+	tool := NewViewImageTool(func() bool { return true })
+
+	ctx, collector := WithImageCollector(context.Background())
+	userMessage, agentMessage, err := tool.Func(ctx, map[string]interface{}{"path": path})
+	assert.NoError(t, err)
+	assert.Contains(t, userMessage, path)
+	assert.Contains(t, agentMessage, "image follows")
+
+	_, _, err = tool.Func(ctx, map[string]interface{}{"path": "https://example.com/diagram.png"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data:image/png;base64,iVBORw==", "https://example.com/diagram.png"}, collector.Images())
+
+	_, _, err = tool.Func(ctx, map[string]interface{}{"path": "notes.txt"})
+	assert.Error(t, err)
+
+	// Without a collector, e.g. in a sub-agent, there is nowhere to send the image
+	_, _, err = tool.Func(context.Background(), map[string]interface{}{"path": path})
+	assert.Error(t, err)
+
+	_, _, err = NewViewImageTool(func() bool { return false }).Func(ctx, map[string]interface{}{"path": path})
+	assert.ErrorContains(t, err, "can't view images")
+}
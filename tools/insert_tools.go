@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/models"
+)
+
+// NewInsertLinesTool creates an insert_lines tool definition
+func NewInsertLinesTool(journal *ChangeJournal, formatter *Formatter) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the file to insert into",
+			},
+			"line": map[string]interface{}{
+				"type":        "integer",
+				"description": "Insert after this line (1-based); 0 inserts at the start of the file",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "The lines to insert",
+			},
+		},
+		"required": []interface{}{"path", "line", "content"},
+	}
+
+	return models.ToolDefinition{
+		Name:        "insert_lines",
+		Description: "Insert lines into a file after a given line number, without matching existing text. Use it to add new code near a known location; read the file first so the line number is current.",
+		Schema:      schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return insertLines(ctx, params, journal, formatter)
+		},
+	}
+}
+
+// NewAppendToFileTool creates an append_to_file tool definition
+func NewAppendToFileTool(journal *ChangeJournal, formatter *Formatter) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the file to append to. It is created if it doesn't exist.",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "The lines to add at the end of the file",
+			},
+		},
+		"required": []interface{}{"path", "content"},
+	}
+
+	return models.ToolDefinition{
+		Name:        "append_to_file",
+		Description: "Add lines to the end of a file, creating it if needed, without matching or rewriting existing text",
+		Schema:      schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return appendToFile(ctx, params, journal, formatter)
+		},
+	}
+}
+
+func insertLines(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, formatter *Formatter) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("path must be a string")
+	}
+	line, ok := params["line"].(float64)
+	if !ok {
+		return "", "", fmt.Errorf("line must be a number")
+	}
+	content, ok := params["content"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("content must be a string")
+	}
+
+	absPath, err := validateAndResolvePath(path)
+	if err != nil {
+		return "", "", WrapToolError("insert_lines", err)
+	}
+	existing, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", "", WrapToolError("insert_lines", fmt.Errorf("failed to read file: %w", err))
+	}
+
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := editorConfig.Decode(existing)
+	lines := strings.SplitAfter(oldContent, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	after := int(line)
+	if after < 0 || after > len(lines) {
+		return "", "", WrapToolError("insert_lines", fmt.Errorf("line %d is out of range; the file has %d lines", after, len(lines)))
+	}
+
+	before := strings.Join(lines[:after], "")
+	// Inserting after a last line without a newline needs one to keep the lines apart
+	if after == len(lines) && after > 0 && !strings.HasSuffix(lines[after-1], "\n") {
+		before += lineBreak(oldContent)
+	}
+	inserted := insertedLines(content, before, oldContent, &editorConfig)
+	newContent := before + inserted + strings.Join(lines[after:], "")
+
+	count := strings.Count(inserted, "\n")
+	done, planned := fmt.Sprintf("Inserted %d lines after line %d", count, after), fmt.Sprintf("would insert %d lines after line %d", count, after)
+	return writeFileChange(ctx, "insert_lines", absPath, existing, true, oldContent, newContent, done, planned, editorConfig, journal, formatter)
+}
+
+func appendToFile(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, formatter *Formatter) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("path must be a string")
+	}
+	content, ok := params["content"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("content must be a string")
+	}
+
+	absPath, err := validateAndResolvePath(path)
+	if err != nil {
+		return "", "", WrapToolError("append_to_file", err)
+	}
+	existing, err := os.ReadFile(absPath)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", WrapToolError("append_to_file", fmt.Errorf("failed to read file: %w", err))
+	}
+
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := editorConfig.Decode(existing)
+	before := oldContent
+	if before != "" && !strings.HasSuffix(before, "\n") {
+		before += lineBreak(oldContent)
+	}
+	appended := insertedLines(content, before, oldContent, &editorConfig)
+
+	count := strings.Count(appended, "\n")
+	done, planned := fmt.Sprintf("Appended %d lines", count), fmt.Sprintf("would append %d lines", count)
+	if !existed {
+		done, planned = fmt.Sprintf("Created with %d lines", count), fmt.Sprintf("would create the file with %d lines", count)
+		if !IsDryRun(ctx) {
+			if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+				return "", "", WrapToolError("append_to_file", fmt.Errorf("failed to create directory %s: %w", filepath.Dir(absPath), err))
+			}
+		}
+	}
+	return writeFileChange(ctx, "append_to_file", absPath, existing, existed, oldContent, before+appended, done, planned, editorConfig, journal, formatter)
+}
+
+// insertedLines prepares content to go between whole lines of a file, after before: it follows the
+// .editorconfig and the file's line endings and ends with a line break
+func insertedLines(content, before, fileContent string, editorConfig *EditorConfig) string {
+	if strings.Contains(fileContent, "\r\n") && editorConfig.EndOfLine == "" {
+		editorConfig.EndOfLine = "crlf"
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return editorConfig.NormalizeFragment(content, before, "")
+}
+
+// lineBreak is the line ending content already uses
+func lineBreak(content string) string {
+	if strings.Contains(content, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// writeFileChange journals and writes newContent to path and reports done, or in a dry run only
+// reports what was planned
+func writeFileChange(ctx context.Context, toolName, absPath string, original []byte, existed bool, oldContent, newContent, done, planned string, editorConfig EditorConfig, journal *ChangeJournal, formatter *Formatter) (string, string, error) {
+	if IsDryRun(ctx) {
+		return generateDiff(oldContent, newContent, absPath), dryRunResult(planned), nil
+	}
+	if err := journal.Record(toolName, absPath, original, existed); err != nil {
+		return "", "", WrapToolError(toolName, err)
+	}
+	newContent, formatNote, err := writeFile(ctx, absPath, newContent, editorConfig, formatter)
+	if err != nil {
+		return "", "", WrapToolError(toolName, err)
+	}
+	return generateDiff(oldContent, newContent, absPath), done + formatNote, nil
+}
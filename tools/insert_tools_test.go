@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInsertLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	tests := []struct {
+		name     string
+		original string
+		line     float64
+		content  string
+		want     string
+	}{
+		{"middle", "one\ntwo\nthree\n", 1, "one and a half", "one\none and a half\ntwo\nthree\n"},
+		{"start", "one\ntwo\n", 0, "zero\n", "zero\none\ntwo\n"},
+		{"end without final newline", "one\ntwo", 2, "three", "one\ntwo\nthree\n"},
+		{"crlf", "one\r\ntwo\r\n", 1, "a\nb", "one\r\na\r\nb\r\ntwo\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := insertLines(context.Background(), map[string]interface{}{"path": path, "line": tt.line, "content": tt.content}, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.want {
+				t.Errorf("file = %q, want %q", data, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := insertLines(context.Background(), map[string]interface{}{"path": path, "line": float64(10), "content": "x"}, nil, nil); err == nil {
+		t.Error("expected an error for a line past the end of the file")
+	}
+}
+
+func TestAppendToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "log.txt")
+	journal := NewChangeJournal(filepath.Join(t.TempDir(), "checkpoints"))
+
+	if _, result, err := appendToFile(context.Background(), map[string]interface{}{"path": path, "content": "first"}, journal, nil); err != nil || result != "Created with 1 lines" {
+		t.Fatalf("result %q, err %v", result, err)
+	}
+	if err := os.WriteFile(path, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := appendToFile(context.Background(), map[string]interface{}{"path": path, "content": "second\nthird\n"}, journal, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first\nsecond\nthird\n" {
+		t.Errorf("file = %q", data)
+	}
+	if entries := journal.Entries(); len(entries) != 2 || entries[0].Existed {
+		t.Errorf("unexpected journal entries %+v", entries)
+	}
+}
@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalEntry records the state of a file before a single mutation
+type JournalEntry struct {
+	ID        int       `json:"id"`
+	Turn      int       `json:"turn"`
+	Tool      string    `json:"tool"`
+	Path      string    `json:"path"`
+	Existed   bool      `json:"existed"`
+	Backup    string    `json:"backup,omitempty"` // file holding the original contents
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ChangeJournal tracks file mutations made during a session so they can be reverted.
+// A nil journal is valid and records nothing.
+type ChangeJournal struct {
+	mu      sync.Mutex
+	dir     string
+	turn    int
+	nextID  int
+	entries []JournalEntry
+}
+
+// NewChangeJournal creates a journal that stores original file contents under dir
+func NewChangeJournal(dir string) *ChangeJournal {
+	return &ChangeJournal{dir: dir, nextID: 1}
+}
+
+// SetTurn sets the turn number attached to subsequent entries
+func (j *ChangeJournal) SetTurn(turn int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.turn = turn
+}
+
+// Record stores the original contents of path before toolName modifies it
+func (j *ChangeJournal) Record(toolName, path string, original []byte, existed bool) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	entry := JournalEntry{
+		ID:        j.nextID,
+		Turn:      j.turn,
+		Tool:      toolName,
+		Path:      path,
+		Existed:   existed,
+		Timestamp: time.Now(),
+	}
+
+	if existed {
+		entry.Backup = filepath.Join(j.dir, fmt.Sprintf("%d.orig", entry.ID))
+		if err := os.WriteFile(entry.Backup, original, 0644); err != nil {
+			return fmt.Errorf("failed to save original contents: %w", err)
+		}
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(j.dir, "journal.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer logFile.Close()
+	if err := json.NewEncoder(logFile).Encode(entry); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	j.nextID++
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of the recorded entries, oldest first
+func (j *ChangeJournal) Entries() []JournalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]JournalEntry, len(j.entries))
+	copy(entries, j.entries)
+	return entries
+}
+
+// UndoLast reverts the most recent change
+func (j *ChangeJournal) UndoLast() ([]JournalEntry, error) {
+	if j == nil {
+		return nil, fmt.Errorf("change journal is not enabled")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) == 0 {
+		return nil, fmt.Errorf("no changes to undo")
+	}
+	entry, err := j.revert(len(j.entries) - 1)
+	if err != nil {
+		return nil, err
+	}
+	return []JournalEntry{entry}, nil
+}
+
+// UndoTurn reverts every change made during the given turn, newest first
+func (j *ChangeJournal) UndoTurn(turn int) ([]JournalEntry, error) {
+	if j == nil {
+		return nil, fmt.Errorf("change journal is not enabled")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var reverted []JournalEntry
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if j.entries[i].Turn != turn {
+			continue
+		}
+		entry, err := j.revert(i)
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, entry)
+	}
+
+	if len(reverted) == 0 {
+		return nil, fmt.Errorf("no changes recorded for turn %d", turn)
+	}
+	return reverted, nil
+}
+
+// revert restores the entry at index i and removes it from the journal. Caller must hold the lock.
+func (j *ChangeJournal) revert(i int) (JournalEntry, error) {
+	entry := j.entries[i]
+
+	if entry.Existed {
+		original, err := os.ReadFile(entry.Backup)
+		if err != nil {
+			return entry, fmt.Errorf("failed to read backup for %s: %w", entry.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return entry, fmt.Errorf("failed to recreate directory for %s: %w", entry.Path, err)
+		}
+		if err := os.WriteFile(entry.Path, original, 0644); err != nil {
+			return entry, fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+	} else if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+		return entry, fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+	}
+
+	j.entries = append(j.entries[:i], j.entries[i+1:]...)
+	return entry, nil
+}
+
+// FormatRevertedEntries describes reverted entries for display
+func FormatRevertedEntries(entries []JournalEntry) string {
+	var lines []string
+	for _, e := range entries {
+		action := "restored"
+		if !e.Existed {
+			action = "removed"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (undo %s from turn %d)", action, e.Path, e.Tool, e.Turn))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewUndoEditTool creates the undo_edit tool
+func NewUndoEditTool(journal *ChangeJournal) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"turn": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: Revert every file change made during this turn. Omit to revert only the most recent change.",
+				"minimum":     1,
+			},
+		},
+	}
+
+	return models.ToolDefinition{
+		Name:        "undo_edit",
+		Description: "Revert file changes made by create_file, edit_file, or delete_file. Without arguments, reverts the most recent change.",
+		Schema:      schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			var reverted []JournalEntry
+			var err error
+			if turn, ok := params["turn"].(float64); ok {
+				reverted, err = journal.UndoTurn(int(turn))
+			} else {
+				reverted, err = journal.UndoLast()
+			}
+			if err != nil {
+				return "", "", WrapToolError("undo_edit", err)
+			}
+
+			summary := FormatRevertedEntries(reverted)
+			return summary + "\n", summary, nil
+		},
+	}
+}
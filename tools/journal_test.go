@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeJournal(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	journal := NewChangeJournal(filepath.Join(tempDir, "checkpoints"))
+	existing := filepath.Join(tempDir, "existing.txt")
+	created := filepath.Join(tempDir, "created.txt")
+	assert.NoError(t, os.WriteFile(existing, []byte("original"), 0644))
+
+	// Turn 1: edit an existing file
+	journal.SetTurn(1)
+	_, _, err := editFile(ctx, map[string]interface{}{"path": existing, "old_str": "original", "new_str": "edited"}, journal, nil)
+	assert.NoError(t, err)
+
+	// Turn 2: create a new file and delete the existing one
+	journal.SetTurn(2)
+	_, _, err = createFile(ctx, map[string]interface{}{"path": created, "content": "new file"}, journal, nil, nil)
+	assert.NoError(t, err)
+	_, _, err = deleteFile(ctx, map[string]interface{}{"path": existing}, journal)
+	assert.NoError(t, err)
+	assert.Len(t, journal.Entries(), 3)
+
+	// Undoing the last change brings back the deleted file with its edited contents
+	reverted, err := journal.UndoLast()
+	assert.NoError(t, err)
+	assert.Equal(t, "delete_file", reverted[0].Tool)
+	content, err := os.ReadFile(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, "edited", string(content))
+
+	// Undoing turn 2 removes the created file
+	undoTool := NewUndoEditTool(journal)
+	_, agentMsg, err := undoTool.Func(ctx, map[string]interface{}{"turn": float64(2)})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "removed "+created)
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+
+	// Undoing turn 1 restores the original contents
+	_, err = journal.UndoTurn(1)
+	assert.NoError(t, err)
+	content, err = os.ReadFile(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	_, err = journal.UndoLast()
+	assert.Error(t, err)
+}
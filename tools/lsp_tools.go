@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"agent/lsp"
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// lspPositionSchema describes the parameters shared by the definition and reference tools
+func lspPositionSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "File containing the symbol",
+			},
+			"line": map[string]interface{}{
+				"type":        "integer",
+				"description": "Line number of the symbol (1-based)",
+				"minimum":     1,
+			},
+			"symbol": map[string]interface{}{
+				"type":        "string",
+				"description": "The identifier on that line to look up (its first occurrence is used)",
+			},
+			"column": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: 1-based column to use instead of symbol",
+				"minimum":     1,
+			},
+		},
+		"required": []interface{}{"path", "line"},
+	}
+}
+
+// NewGetDiagnosticsTool creates the get_diagnostics tool
+func NewGetDiagnosticsTool(manager *lsp.Manager) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "get_diagnostics",
+		Description: "Get compiler and linter errors and warnings for a file from its language server (gopls, pyright, typescript-language-server). Use after editing to check the code still compiles. The user doesn't see the result.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "File to check",
+				},
+			},
+			"required": []interface{}{"path"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return getDiagnostics(ctx, params, manager)
+		},
+	}
+}
+
+// NewFindDefinitionTool creates the find_definition tool
+func NewFindDefinitionTool(manager *lsp.Manager) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "find_definition",
+		Description: "Find where a symbol is defined using the file's language server. More precise than searching with grep. The user doesn't see the result.",
+		Schema:      lspPositionSchema(),
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return findLocations(ctx, params, manager, "find_definition")
+		},
+	}
+}
+
+// NewFindReferencesTool creates the find_references tool
+func NewFindReferencesTool(manager *lsp.Manager) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "find_references",
+		Description: "Find every usage of a symbol across the workspace using the file's language server. The user doesn't see the result.",
+		Schema:      lspPositionSchema(),
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return findLocations(ctx, params, manager, "find_references")
+		},
+	}
+}
+
+func getDiagnostics(ctx context.Context, params map[string]interface{}, manager *lsp.Manager) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("path must be a non-empty string")
+	}
+
+	client, err := manager.ClientFor(ctx, path)
+	if err != nil {
+		return "", "", WrapToolError("get_diagnostics", err)
+	}
+	diagnostics, err := client.Diagnostics(ctx, path, 10*time.Second)
+	if err != nil {
+		return "", "", WrapToolError("get_diagnostics", err)
+	}
+
+	if len(diagnostics) == 0 {
+		result := fmt.Sprintf("No diagnostics for %s", path)
+		return result + "\n", result, nil
+	}
+
+	var lines []string
+	for _, d := range diagnostics {
+		source := ""
+		if d.Source != "" {
+			source = " (" + d.Source + ")"
+		}
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s: %s%s", path, d.Range.Start.Line+1, d.Range.Start.Character+1, d.SeverityName(), d.Message, source))
+	}
+	result := strings.Join(lines, "\n")
+	return fmt.Sprintf("%d diagnostics for %s\n", len(diagnostics), path), result, nil
+}
+
+func findLocations(ctx context.Context, params map[string]interface{}, manager *lsp.Manager, toolName string) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("path must be a non-empty string")
+	}
+	line, ok := params["line"].(float64)
+	if !ok || line < 1 {
+		return "", "", fmt.Errorf("line must be a positive integer")
+	}
+	symbol, _ := params["symbol"].(string)
+	column, _ := params["column"].(float64)
+	if symbol == "" && column < 1 {
+		return "", "", fmt.Errorf("either symbol or column is required")
+	}
+
+	pos, err := lsp.PositionOf(path, int(line), symbol, int(column))
+	if err != nil {
+		return "", "", WrapToolError(toolName, err)
+	}
+
+	client, err := manager.ClientFor(ctx, path)
+	if err != nil {
+		return "", "", WrapToolError(toolName, err)
+	}
+
+	var locations []lsp.Location
+	if toolName == "find_definition" {
+		locations, err = client.Definition(ctx, path, pos)
+	} else {
+		locations, err = client.References(ctx, path, pos)
+	}
+	if err != nil {
+		return "", "", WrapToolError(toolName, err)
+	}
+
+	if len(locations) == 0 {
+		result := "No results"
+		return result + "\n", result, nil
+	}
+
+	root, _ := os.Getwd()
+	var lines []string
+	for _, loc := range locations {
+		lines = append(lines, lsp.FormatLocation(loc, root))
+	}
+	result := strings.Join(lines, "\n")
+	return fmt.Sprintf("Found %d locations\n", len(locations)), result, nil
+}
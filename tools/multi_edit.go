@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"agent/models"
+)
+
+// NewMultiEditTool creates a multi_edit tool definition
+func NewMultiEditTool(journal *ChangeJournal, formatter *Formatter) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the file to edit",
+			},
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Replacements to make, in order. Each applies to the result of the ones before it.",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"old_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The exact string to find. Must match exactly including whitespace and newlines.",
+						},
+						"new_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The string to replace it with",
+						},
+						"expected_replacements": map[string]interface{}{
+							"type":        "integer",
+							"description": "Optional: How many occurrences old_string has; all of them are replaced. Defaults to 1.",
+						},
+					},
+					"required": []interface{}{"old_string", "new_string"},
+				},
+			},
+		},
+		"required": []interface{}{"path", "edits"},
+	}
+
+	return models.ToolDefinition{
+		Name: "multi_edit",
+		Description: "Make several replacements in one file at once. The edits are all-or-nothing: if any old_string isn't found " +
+			"the expected number of times, the file is left unchanged. Prefer it to several edit_file calls on the same file.",
+		Schema: schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return multiEdit(ctx, params, journal, formatter)
+		},
+	}
+}
+
+// stringEdit is one replacement of a multi_edit call
+type stringEdit struct {
+	old      string
+	new      string
+	expected int
+}
+
+func multiEdit(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, formatter *Formatter) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("path must be a string")
+	}
+	edits, err := parseStringEdits(params["edits"])
+	if err != nil {
+		return "", "", WrapToolError("multi_edit", err)
+	}
+
+	absPath, err := validateAndResolvePath(path)
+	if err != nil {
+		return "", "", WrapToolError("multi_edit", err)
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", "", WrapToolError("multi_edit", fmt.Errorf("failed to read file: %w", err))
+	}
+
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := editorConfig.Decode(content)
+	crlf := strings.Contains(oldContent, "\r\n")
+	if crlf && editorConfig.EndOfLine == "" {
+		editorConfig.EndOfLine = "crlf"
+	}
+
+	// Every edit is checked against the content left by the ones before it before anything is written
+	newContent := oldContent
+	for i, edit := range edits {
+		oldStr := edit.old
+		// Models write \n; match and insert CRLF in files that already use it
+		if crlf && !strings.Contains(newContent, oldStr) {
+			oldStr = strings.ReplaceAll(oldStr, "\n", "\r\n")
+		}
+		if count := strings.Count(newContent, oldStr); count != edit.expected {
+			return "", "", WrapToolError("multi_edit", fmt.Errorf("edit %d: old_string found %d times, expected %d; no edits were applied", i+1, count, edit.expected))
+		}
+		newContent = editorConfig.ReplaceFragments(newContent, oldStr, edit.new, -1)
+	}
+	if editorConfig.InsertFinalNewline != nil && *editorConfig.InsertFinalNewline && newContent != "" && !strings.HasSuffix(newContent, "\n") && !strings.HasSuffix(newContent, "\r") {
+		newContent += editorConfig.NormalizeFragment("\n", newContent, "")
+	}
+
+	if IsDryRun(ctx) {
+		return generateDiff(oldContent, newContent, absPath), dryRunResult(fmt.Sprintf("would apply %d edits", len(edits))), nil
+	}
+
+	if err := journal.Record("multi_edit", absPath, content, true); err != nil {
+		return "", "", WrapToolError("multi_edit", err)
+	}
+
+	newContent, formatNote, err := writeFile(ctx, absPath, newContent, editorConfig, formatter)
+	if err != nil {
+		return "", "", WrapToolError("multi_edit", err)
+	}
+
+	return generateDiff(oldContent, newContent, absPath), fmt.Sprintf("Updated with %d edits%s", len(edits), formatNote), nil
+}
+
+// parseStringEdits reads the edits argument of multi_edit
+func parseStringEdits(value interface{}) ([]stringEdit, error) {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("edits must be a non-empty array")
+	}
+	edits := make([]stringEdit, 0, len(items))
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("edit %d must be an object", i+1)
+		}
+		oldStr, ok := fields["old_string"].(string)
+		if !ok || oldStr == "" {
+			return nil, fmt.Errorf("edit %d: old_string must be a non-empty string", i+1)
+		}
+		newStr, ok := fields["new_string"].(string)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: new_string must be a string", i+1)
+		}
+		expected := 1
+		if count, ok := fields["expected_replacements"].(float64); ok {
+			expected = int(count)
+		}
+		if expected < 1 {
+			return nil, fmt.Errorf("edit %d: expected_replacements must be at least 1", i+1)
+		}
+		if oldStr == newStr {
+			return nil, fmt.Errorf("edit %d: old_string and new_string are the same", i+1)
+		}
+		edits = append(edits, stringEdit{old: oldStr, new: newStr, expected: expected})
+	}
+	return edits, nil
+}
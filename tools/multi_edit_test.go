@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.go")
+	original := "const a = 1\nconst b = 1\nconst c = 2\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	edits := []interface{}{
+		map[string]interface{}{"old_string": "= 1", "new_string": "= 10", "expected_replacements": float64(2)},
+		map[string]interface{}{"old_string": "const c = 2", "new_string": "const c = 20"},
+		// Applies to the result of the first edit
+		map[string]interface{}{"old_string": "const a = 10", "new_string": "const a = 100"},
+	}
+	_, result, err := multiEdit(context.Background(), map[string]interface{}{"path": path, "edits": edits}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "const a = 100\nconst b = 10\nconst c = 20\n" {
+		t.Errorf("file = %q", data)
+	}
+	if !strings.Contains(result, "3 edits") {
+		t.Errorf("unexpected result %q", result)
+	}
+}
+
+func TestMultiEditIsAllOrNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	original := "alpha\nbeta\nbeta\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal := NewChangeJournal(filepath.Join(t.TempDir(), "checkpoints"))
+
+	for _, edits := range [][]interface{}{
+		{map[string]interface{}{"old_string": "alpha", "new_string": "ALPHA"}, map[string]interface{}{"old_string": "gamma", "new_string": "GAMMA"}},
+		{map[string]interface{}{"old_string": "alpha", "new_string": "ALPHA"}, map[string]interface{}{"old_string": "beta", "new_string": "BETA"}},
+	} {
+		if _, _, err := multiEdit(context.Background(), map[string]interface{}{"path": path, "edits": edits}, journal, nil); err == nil || !strings.Contains(err.Error(), "edit 2") {
+			t.Errorf("expected edit 2 to fail, got %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Errorf("file changed after a failed edit: %q", data)
+	}
+	if entries := journal.Entries(); len(entries) != 0 {
+		t.Errorf("failed edits recorded %d journal entries", len(entries))
+	}
+}
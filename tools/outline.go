@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// outlineLanguage is a tree-sitter grammar and the node types it reports as symbols
+type outlineLanguage struct {
+	language *sitter.Language
+	symbols  map[string]bool
+}
+
+var jsSymbols = map[string]bool{
+	"function_declaration":           true,
+	"generator_function_declaration": true,
+	"class_declaration":              true,
+	"abstract_class_declaration":     true,
+	"method_definition":              true,
+	"interface_declaration":          true,
+	"type_alias_declaration":         true,
+	"enum_declaration":               true,
+	"variable_declarator":            true, // only when assigned a function, see isOutlineSymbol
+}
+
+var outlineLanguages = map[string]outlineLanguage{
+	".go": {golang.GetLanguage(), map[string]bool{
+		"function_declaration": true,
+		"method_declaration":   true,
+		"type_spec":            true,
+	}},
+	".py": {python.GetLanguage(), map[string]bool{
+		"function_definition": true,
+		"class_definition":    true,
+	}},
+	".js":  {javascript.GetLanguage(), jsSymbols},
+	".jsx": {javascript.GetLanguage(), jsSymbols},
+	".mjs": {javascript.GetLanguage(), jsSymbols},
+	".ts":  {typescript.GetLanguage(), jsSymbols},
+	".tsx": {tsx.GetLanguage(), jsSymbols},
+}
+
+// OutlineSymbol is a function, type, or method found in a file
+type OutlineSymbol struct {
+	Signature string
+	StartLine int
+	EndLine   int
+	Depth     int // nesting level, e.g. 1 for methods inside a class
+}
+
+// NewCodeOutlineTool creates the code_outline tool
+func NewCodeOutlineTool() models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "code_outline",
+		Description: "List the functions, types, classes, and methods in a source file with their signatures and line ranges (Go, Python, JavaScript, TypeScript). Use this to understand a large file, then read_file only the line ranges you need. The user doesn't see the result.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path to the source file",
+				},
+			},
+			"required": []interface{}{"path"},
+		},
+		Func: codeOutline,
+	}
+}
+
+func codeOutline(ctx context.Context, params map[string]interface{}) (string, string, error) {
+	path, ok := params["path"].(string)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("path must be a non-empty string")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", WrapToolError("code_outline", err)
+	}
+
+	symbols, err := Outline(ctx, path, content)
+	if err != nil {
+		return "", "", WrapToolError("code_outline", err)
+	}
+
+	lineCount := strings.Count(string(content), "\n") + 1
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("%s (%d lines)\n", path, lineCount))
+	if len(symbols) == 0 {
+		result.WriteString("No symbols found\n")
+	}
+	for _, symbol := range symbols {
+		result.WriteString(fmt.Sprintf("%s%s [%d-%d]\n", strings.Repeat("  ", symbol.Depth+1), symbol.Signature, symbol.StartLine, symbol.EndLine))
+	}
+
+	return fmt.Sprintf("Outlined %s (%d symbols)\n", path, len(symbols)), strings.TrimRight(result.String(), "\n"), nil
+}
+
+// Outline parses content with the grammar for path's extension and returns its symbols in source order
+func Outline(ctx context.Context, path string, content []byte) ([]OutlineSymbol, error) {
+	lang, ok := outlineLanguages[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("code outlines are not supported for %s files", filepath.Ext(path))
+	}
+
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang.language)
+
+	tree, err := parser.ParseCtx(ctx, nil, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	defer tree.Close()
+
+	var symbols []OutlineSymbol
+	var walk func(node *sitter.Node, depth int)
+	walk = func(node *sitter.Node, depth int) {
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			child := node.NamedChild(i)
+			if lang.symbols[child.Type()] && isOutlineSymbol(child) {
+				symbols = append(symbols, OutlineSymbol{
+					Signature: symbolSignature(child, content),
+					StartLine: int(child.StartPoint().Row) + 1,
+					EndLine:   int(child.EndPoint().Row) + 1,
+					Depth:     depth,
+				})
+				walk(child, depth+1)
+			} else {
+				walk(child, depth)
+			}
+		}
+	}
+	walk(tree.RootNode(), 0)
+
+	return symbols, nil
+}
+
+// isOutlineSymbol filters out variable declarations that don't hold functions
+func isOutlineSymbol(node *sitter.Node) bool {
+	if node.Type() != "variable_declarator" {
+		return true
+	}
+	value := node.ChildByFieldName("value")
+	if value == nil {
+		return false
+	}
+	switch value.Type() {
+	case "arrow_function", "function", "function_expression", "generator_function":
+		return true
+	}
+	return false
+}
+
+// symbolSignature returns a symbol's declaration without its body, collapsed onto one line
+func symbolSignature(node *sitter.Node, content []byte) string {
+	var signature string
+	switch node.Type() {
+	case "type_spec":
+		// Go types: show the kind of type rather than its whole definition
+		signature = "type " + node.ChildByFieldName("name").Content(content)
+		if typeNode := node.ChildByFieldName("type"); typeNode != nil {
+			switch typeNode.Type() {
+			case "struct_type":
+				signature += " struct"
+			case "interface_type":
+				signature += " interface"
+			default:
+				signature += " " + typeNode.Content(content)
+			}
+		}
+	case "variable_declarator":
+		value := node.ChildByFieldName("value")
+		end := value.EndByte()
+		if body := value.ChildByFieldName("body"); body != nil {
+			end = body.StartByte()
+		}
+		signature = string(content[node.StartByte():end])
+	default:
+		end := node.EndByte()
+		if body := node.ChildByFieldName("body"); body != nil {
+			end = body.StartByte()
+		}
+		signature = string(content[node.StartByte():end])
+	}
+
+	signature = strings.Join(strings.Fields(signature), " ")
+	signature = strings.TrimSpace(strings.TrimSuffix(signature, "=>"))
+	signature = strings.TrimSuffix(signature, ":")
+	if runes := []rune(signature); len(runes) > 160 {
+		signature = string(runes[:157]) + "..."
+	}
+	return signature
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutline(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected []OutlineSymbol
+	}{
+		{
+			name: "go",
+			path: "example.go",
+			content: `package example
+
+type Store struct {
+	items map[string]int
+}
+
+func (s *Store) Get(key string) (int, bool) {
+	v, ok := s.items[key]
+	return v, ok
+}
+
+func NewStore() *Store { return &Store{} }
+`,
+			expected: []OutlineSymbol{
+				{Signature: "type Store struct", StartLine: 3, EndLine: 5},
+				{Signature: "func (s *Store) Get(key string) (int, bool)", StartLine: 7, EndLine: 10},
+				{Signature: "func NewStore() *Store", StartLine: 12, EndLine: 12},
+			},
+		},
+		{
+			name: "python",
+			path: "example.py",
+			content: `class Greeter(Base):
+    def greet(self, name: str) -> str:
+        return "hi " + name
+
+def main():
+    pass
+`,
+			expected: []OutlineSymbol{
+				{Signature: "class Greeter(Base)", StartLine: 1, EndLine: 3},
+				{Signature: "def greet(self, name: str) -> str", StartLine: 2, EndLine: 3, Depth: 1},
+				{Signature: "def main()", StartLine: 5, EndLine: 6},
+			},
+		},
+		{
+			name: "typescript",
+			path: "example.ts",
+			content: `export interface Shape { area(): number }
+
+export const double = (n: number): number => n * 2;
+const limit = 10;
+
+class Square implements Shape {
+  area(): Promise<number> { return Promise.resolve(1); }
+}
+`,
+			expected: []OutlineSymbol{
+				{Signature: "interface Shape", StartLine: 1, EndLine: 1},
+				{Signature: "double = (n: number): number", StartLine: 3, EndLine: 3},
+				{Signature: "class Square implements Shape", StartLine: 6, EndLine: 8},
+				{Signature: "area(): Promise<number>", StartLine: 7, EndLine: 7, Depth: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols, err := Outline(ctx, tt.path, []byte(tt.content))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, symbols)
+		})
+	}
+
+	_, err := Outline(ctx, "notes.txt", []byte("hello"))
+	assert.Error(t, err)
+}
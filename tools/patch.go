@@ -0,0 +1,439 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"agent/models"
+)
+
+// maxPatchFuzz is how many context lines at each end of a hunk may be ignored to place it, as with
+// patch's default fuzz factor
+const maxPatchFuzz = 2
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the part of a unified diff that changes one file
+type filePatch struct {
+	oldPath string // "/dev/null" when the patch creates the file
+	newPath string // "/dev/null" when the patch deletes the file
+	hunks   []patchHunk
+}
+
+// patchHunk is one @@ section of a file patch
+type patchHunk struct {
+	header   string
+	oldStart int
+	oldLines int
+	lines    []string // each starts with ' ', '-', or '+'
+}
+
+// NewApplyPatchTool creates an apply_patch tool definition. The patch's paths are relative to workDir.
+func NewApplyPatchTool(journal *ChangeJournal, formatter *Formatter, workDir *WorkingDirectory) models.ToolDefinition {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"patch": map[string]interface{}{
+				"type":        "string",
+				"description": "A unified diff, as produced by diff -u or git diff. It may change several files; use /dev/null as the old path to create a file and as the new path to delete one.",
+			},
+		},
+		"required": []interface{}{"patch"},
+	}
+
+	return models.ToolDefinition{
+		Name: "apply_patch",
+		Description: "Apply a unified diff to one or more files. Prefer it to repeated edit_file calls for changes with several hunks. " +
+			"Hunks are placed by their context lines, so line numbers may be off and small whitespace differences are tolerated; " +
+			"hunks that can't be placed are rejected and reported while the rest are applied.",
+		Schema: schema,
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return applyPatch(ctx, params, journal, formatter, workDir)
+		},
+	}
+}
+
+// PatchPaths lists the files a patch changes, so they can be checked like the paths of other file tools
+func PatchPaths(patch string) []string {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.path())
+	}
+	return paths
+}
+
+func applyPatch(ctx context.Context, params map[string]interface{}, journal *ChangeJournal, formatter *Formatter, workDir *WorkingDirectory) (string, string, error) {
+	patch, ok := params["patch"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("patch must be a string")
+	}
+
+	files, err := parsePatch(patch)
+	if err != nil {
+		return "", "", WrapToolError("apply_patch", err)
+	}
+
+	var diffs, report []string
+	changed, rejected := 0, false
+	for _, file := range files {
+		diff, summary, complete, err := applyFilePatch(ctx, file, journal, formatter, workDir)
+		if err != nil {
+			return strings.Join(diffs, "\n"), "", WrapToolError("apply_patch", err)
+		}
+		if diff != "" {
+			diffs = append(diffs, diff)
+			changed++
+		}
+		rejected = rejected || !complete
+		report = append(report, summary)
+	}
+
+	summary := strings.Join(report, "\n")
+	if changed == 0 {
+		return "", "", WrapToolError("apply_patch", fmt.Errorf("nothing was applied:\n%s", summary))
+	}
+	if rejected {
+		summary += "\nThe other hunks are in place; read the files and send the rejected hunks again against their current contents."
+	}
+	if IsDryRun(ctx) {
+		summary = dryRunResult("would apply the patch") + "\n" + summary
+	}
+	return strings.Join(diffs, "\n"), summary, nil
+}
+
+// applyFilePatch applies the hunks of one file that can be placed and writes the result. It returns
+// the diff of what changed, a summary for the model, and whether every hunk applied.
+func applyFilePatch(ctx context.Context, file filePatch, journal *ChangeJournal, formatter *Formatter, workDir *WorkingDirectory) (string, string, bool, error) {
+	path := workDir.Resolve(file.path())
+	absPath, err := validateAndResolvePath(path)
+	if err != nil {
+		return "", "", false, err
+	}
+	creating, deleting := file.oldPath == "/dev/null", file.newPath == "/dev/null"
+
+	existing, err := os.ReadFile(absPath)
+	switch {
+	case creating && err == nil:
+		return "", fmt.Sprintf("%s: rejected, the patch creates it but it already exists", path), false, nil
+	case !creating && err != nil:
+		return "", fmt.Sprintf("%s: rejected, failed to read file: %v", path, err), false, nil
+	case len(file.hunks) == 0:
+		return "", fmt.Sprintf("%s: rejected, the patch has no hunks for it", path), false, nil
+	}
+
+	editorConfig := formatter.EditorConfig(absPath)
+	oldContent := editorConfig.Decode(existing)
+	crlf := strings.Contains(oldContent, "\r\n")
+	text := strings.ReplaceAll(oldContent, "\r\n", "\n")
+	var lines []string
+	if text != "" {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+
+	patched, notes, rejects := applyHunks(lines, file.hunks)
+	if len(rejects) == len(file.hunks) {
+		return "", fmt.Sprintf("%s: rejected all %d hunks\n%s", path, len(file.hunks), strings.Join(rejects, "\n")), false, nil
+	}
+
+	newContent := strings.Join(patched, "\n")
+	if len(patched) > 0 && (creating || text == "" || strings.HasSuffix(text, "\n")) {
+		newContent += "\n"
+	}
+	if crlf {
+		newContent = strings.ReplaceAll(newContent, "\n", "\r\n")
+	}
+	if creating {
+		newContent = editorConfig.Normalize(newContent)
+	}
+	if deleting && strings.TrimSpace(newContent) != "" {
+		return "", fmt.Sprintf("%s: rejected, the patch deletes it but the file has lines the patch doesn't remove", path), false, nil
+	}
+
+	verb := "Patched"
+	switch {
+	case creating:
+		verb = "Created"
+	case deleting:
+		verb = "Deleted"
+	}
+	summary := fmt.Sprintf("%s %s (%d of %d hunks)", verb, path, len(file.hunks)-len(rejects), len(file.hunks))
+	if len(notes) > 0 {
+		summary += "\n" + strings.Join(notes, "\n")
+	}
+	if len(rejects) > 0 {
+		summary += "\n" + strings.Join(rejects, "\n")
+	}
+
+	if IsDryRun(ctx) {
+		return generateDiff(oldContent, newContent, absPath), summary, len(rejects) == 0, nil
+	}
+
+	if creating {
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			return "", "", false, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(absPath), err)
+		}
+	}
+	if err := journal.Record("apply_patch", absPath, existing, !creating); err != nil {
+		return "", "", false, err
+	}
+	if deleting {
+		if err := os.Remove(absPath); err != nil {
+			return "", "", false, fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+		return generateDiff(oldContent, "", absPath), summary, len(rejects) == 0, nil
+	}
+
+	newContent, formatNote, err := writeFile(ctx, absPath, newContent, editorConfig, formatter)
+	if err != nil {
+		return "", "", false, err
+	}
+	return generateDiff(oldContent, newContent, absPath), summary + formatNote, len(rejects) == 0, nil
+}
+
+// path is the file the patch changes
+func (f filePatch) path() string {
+	if f.newPath == "/dev/null" {
+		return f.oldPath
+	}
+	return f.newPath
+}
+
+// parsePatch splits a unified diff into file patches. Lines outside file headers and hunks, such as
+// git's "diff --git" and "index" lines, are ignored.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []filePatch
+	var hunk *patchHunk
+	finish := func() {
+		if hunk != nil {
+			current := &files[len(files)-1]
+			current.hunks = append(current.hunks, hunk.trimmed())
+			hunk = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			finish()
+			oldPath, newPath := patchPath(line[4:]), patchPath(lines[i+1][4:])
+			// git prefixes the old and new paths with a/ and b/
+			if (oldPath == "/dev/null" || strings.HasPrefix(oldPath, "a/")) && (newPath == "/dev/null" || strings.HasPrefix(newPath, "b/")) {
+				oldPath, newPath = strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+			}
+			if oldPath == "/dev/null" && newPath == "/dev/null" {
+				return nil, fmt.Errorf("file header at line %d has /dev/null for both paths", i+1)
+			}
+			files = append(files, filePatch{oldPath: oldPath, newPath: newPath})
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("hunk at line %d comes before any --- and +++ file header", i+1)
+			}
+			finish()
+			match := hunkHeader.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("malformed hunk header at line %d: %s", i+1, line)
+			}
+			start, _ := strconv.Atoi(match[1])
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			hunk = &patchHunk{header: line, oldStart: start, oldLines: count}
+		case hunk != nil && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			hunk.lines = append(hunk.lines, line)
+		case hunk != nil && line == "":
+			// Editors and models often strip the space from blank context lines
+			hunk.lines = append(hunk.lines, " ")
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"; the file's own final newline is kept
+		default:
+			finish()
+		}
+	}
+	finish()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found; the patch needs --- and +++ lines before its hunks")
+	}
+	return files, nil
+}
+
+// patchPath reads the path from a --- or +++ line, dropping any timestamp after a tab
+func patchPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	return strings.TrimSpace(path)
+}
+
+// trimmed drops blank lines read past the end of the hunk, such as the separator before the next file
+func (h patchHunk) trimmed() patchHunk {
+	for len(h.lines) > 0 && h.lines[len(h.lines)-1] == " " && len(h.old()) > h.oldLines {
+		h.lines = h.lines[:len(h.lines)-1]
+	}
+	return h
+}
+
+// old returns the lines the hunk expects to find in the file
+func (h patchHunk) old() []string {
+	var old []string
+	for _, line := range h.lines {
+		if line[0] != '+' {
+			old = append(old, line[1:])
+		}
+	}
+	return old
+}
+
+// fuzzed drops up to fuzz context lines from each end of the hunk, returning how many were dropped
+// from the start
+func (h patchHunk) fuzzed(fuzz int) (patchHunk, int) {
+	lead, trail := 0, 0
+	for lead < fuzz && lead < len(h.lines) && h.lines[lead][0] == ' ' {
+		lead++
+	}
+	for trail < fuzz && trail < len(h.lines)-lead && h.lines[len(h.lines)-1-trail][0] == ' ' {
+		trail++
+	}
+	h.lines = h.lines[lead : len(h.lines)-trail]
+	return h, lead
+}
+
+// lineMatchers compare a file line with a hunk line, from strictest to most forgiving
+var lineMatchers = []struct {
+	note  string
+	match func(file, hunk string) bool
+}{
+	{"", func(file, hunk string) bool { return file == hunk }},
+	{"ignoring trailing whitespace", func(file, hunk string) bool {
+		return strings.TrimRight(file, " \t") == strings.TrimRight(hunk, " \t")
+	}},
+	{"ignoring indentation", func(file, hunk string) bool { return strings.TrimSpace(file) == strings.TrimSpace(hunk) }},
+}
+
+// applyHunks applies hunks to lines in order. It returns the patched lines, notes on hunks that
+// needed an offset, fuzz, or whitespace tolerance to apply, and a description of each rejected hunk.
+func applyHunks(lines []string, hunks []patchHunk) ([]string, []string, []string) {
+	var notes, rejects []string
+	offset, floor := 0, 0 // floor keeps hunks from overlapping the ones already applied
+	for n, hunk := range hunks {
+		expected := max(hunk.oldStart-1, 0) + offset
+		placed := false
+		for fuzz := 0; fuzz <= maxPatchFuzz && !placed; fuzz++ {
+			candidate, lead := hunk.fuzzed(fuzz)
+			if fuzz > 0 && lead == 0 && len(candidate.lines) == len(hunk.lines) {
+				continue
+			}
+			old := candidate.old()
+			if fuzz > 0 && len(old) == 0 {
+				continue
+			}
+			for _, matcher := range lineMatchers {
+				at, ok := locateLines(lines, old, expected+lead, floor, matcher.match)
+				if !ok {
+					continue
+				}
+				replacement := replaceHunk(lines[at:at+len(old)], candidate.lines)
+				lines = append(lines[:at], append(replacement, lines[at+len(old):]...)...)
+				offset = at - lead - max(hunk.oldStart-1, 0) + len(replacement) - len(old)
+				floor = at + len(replacement)
+
+				var qualifiers []string
+				if at-lead != expected {
+					qualifiers = append(qualifiers, fmt.Sprintf("offset %+d lines", at-lead-expected))
+				}
+				if fuzz > 0 {
+					qualifiers = append(qualifiers, fmt.Sprintf("fuzz %d", fuzz))
+				}
+				if matcher.note != "" {
+					qualifiers = append(qualifiers, matcher.note)
+				}
+				if len(qualifiers) > 0 {
+					notes = append(notes, fmt.Sprintf("  hunk %d applied at line %d (%s)", n+1, at-lead+1, strings.Join(qualifiers, ", ")))
+				}
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			rejects = append(rejects, rejectHunk(lines, hunk, n+1))
+		}
+	}
+	return lines, notes, rejects
+}
+
+// locateLines finds old in lines at or after floor, taking the match nearest to expected. A hunk
+// that only adds lines goes at expected.
+func locateLines(lines, old []string, expected, floor int, match func(file, hunk string) bool) (int, bool) {
+	last := len(lines) - len(old)
+	if len(old) == 0 {
+		return min(max(expected, floor), len(lines)), true
+	}
+	matchesAt := func(at int) bool {
+		if at < floor || at > last {
+			return false
+		}
+		for i, line := range old {
+			if !match(lines[at+i], line) {
+				return false
+			}
+		}
+		return true
+	}
+	for distance := 0; expected-distance >= floor || expected+distance <= last; distance++ {
+		if matchesAt(expected - distance) {
+			return expected - distance, true
+		}
+		if matchesAt(expected + distance) {
+			return expected + distance, true
+		}
+	}
+	return 0, false
+}
+
+// replaceHunk builds the lines that replace matched, keeping the file's own version of context lines
+func replaceHunk(matched, hunkLines []string) []string {
+	replacement := make([]string, 0, len(hunkLines))
+	i := 0
+	for _, line := range hunkLines {
+		switch line[0] {
+		case ' ':
+			replacement = append(replacement, matched[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			replacement = append(replacement, line[1:])
+		}
+	}
+	return replacement
+}
+
+// rejectHunk describes a hunk that couldn't be placed, with its text so the model can correct it
+func rejectHunk(lines []string, hunk patchHunk, number int) string {
+	reason := "the lines it keeps and removes weren't found in the file"
+	var result []string
+	adds := false
+	for _, line := range hunk.lines {
+		if line[0] != '-' {
+			result = append(result, line[1:])
+		}
+		adds = adds || line[0] == '+'
+	}
+	if adds {
+		if _, ok := locateLines(lines, result, 0, 0, lineMatchers[1].match); ok {
+			reason = "its result is already in the file, so it may have been applied before"
+		}
+	}
+	return fmt.Sprintf("  hunk %d rejected, %s:\n    %s\n    %s", number, reason, hunk.header, strings.Join(hunk.lines, "\n    "))
+}
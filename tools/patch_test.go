@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	original := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n\nfunc helper() int {\n\treturn 1\n}\n"
+	if err := os.WriteFile(main, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	obsolete := filepath.Join(dir, "obsolete.txt")
+	if err := os.WriteFile(obsolete, []byte("gone\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "pkg", "new.go")
+
+	// The second hunk's line numbers are off by two and its context has trailing spaces
+	patch := "diff --git a/main.go b/main.go\n" +
+		"--- " + main + "\n+++ " + main + "\n" +
+		"@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hello, world\")\n }\n" +
+		"@@ -11,3 +11,3 @@\n func helper() int {  \n-\treturn 1\n+\treturn 2\n }\n" +
+		"--- /dev/null\n+++ " + created + "\n@@ -0,0 +1,2 @@\n+package pkg\n+\n" +
+		"--- " + obsolete + "\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n"
+
+	journal := NewChangeJournal(filepath.Join(dir, "checkpoints"))
+	diff, result, err := applyPatch(context.Background(), map[string]interface{}{"patch": patch}, journal, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(main)
+	want := strings.Replace(strings.Replace(original, "\"hello\"", "\"hello, world\"", 1), "return 1", "return 2", 1)
+	if string(data) != want {
+		t.Errorf("main.go = %q, want %q", data, want)
+	}
+	if data, err := os.ReadFile(created); err != nil || string(data) != "package pkg\n\n" {
+		t.Errorf("new.go = %q, %v", data, err)
+	}
+	if _, err := os.Stat(obsolete); !os.IsNotExist(err) {
+		t.Errorf("obsolete.txt wasn't deleted: %v", err)
+	}
+	for _, expected := range []string{"Patched " + main + " (2 of 2 hunks)", "hunk 2 applied at line 9 (offset -2 lines, ignoring trailing whitespace)", "Created " + created, "Deleted " + obsolete} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("result %q doesn't mention %q", result, expected)
+		}
+	}
+	for _, path := range []string{main, created, obsolete} {
+		if !strings.Contains(diff, path) {
+			t.Errorf("diff doesn't show %s: %q", path, diff)
+		}
+	}
+	if entries := journal.Entries(); len(entries) != 3 {
+		t.Errorf("expected 3 journal entries, got %d", len(entries))
+	}
+}
+
+func TestApplyPatchRejectsHunks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\nfour\nfive\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := "--- " + path + "\n+++ " + path + "\n" +
+		"@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n" +
+		"@@ -4,2 +4,2 @@\n-six\n+SIX\n five\n"
+	_, result, err := applyPatch(context.Background(), map[string]interface{}{"patch": patch}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\nTWO\nthree\nfour\nfive\n" {
+		t.Errorf("file = %q", data)
+	}
+	if !strings.Contains(result, "(1 of 2 hunks)") || !strings.Contains(result, "hunk 2 rejected") || !strings.Contains(result, "-six") {
+		t.Errorf("rejection not reported: %q", result)
+	}
+
+	// Sending the applied hunk again is rejected as already applied, and nothing changes
+	_, _, err = applyPatch(context.Background(), map[string]interface{}{"patch": "--- " + path + "\n+++ " + path + "\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n"}, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "already in the file") {
+		t.Errorf("expected an already-applied rejection, got %v", err)
+	}
+}
+
+func TestApplyPatchFuzz(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e"}
+	// The leading context line is wrong, but fuzz 1 drops it
+	hunk := patchHunk{header: "@@ -2,3 +2,3 @@", oldStart: 2, oldLines: 3, lines: []string{" x", "-c", "+C", " d"}}
+	patched, notes, rejects := applyHunks(lines, []patchHunk{hunk})
+	if len(rejects) != 0 {
+		t.Fatalf("unexpected rejects: %v", rejects)
+	}
+	if strings.Join(patched, ",") != "a,b,C,d,e" {
+		t.Errorf("patched = %v", patched)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "fuzz 1") {
+		t.Errorf("notes = %v", notes)
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	patch := "--- a/x.go\t2024-01-01\n+++ b/x.go\n@@ -1 +1 @@\n-old\n+new\n\n--- a/y.go\n+++ b/y.go\n@@ -3,2 +3,3 @@\n keep\n\n+added\n"
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].path() != "x.go" || files[1].path() != "y.go" {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	// The blank separator after x.go's hunk isn't part of it, but y.go's blank context line is
+	if len(files[0].hunks[0].lines) != 2 || len(files[1].hunks[0].lines) != 3 {
+		t.Errorf("unexpected hunk lines: %q, %q", files[0].hunks[0].lines, files[1].hunks[0].lines)
+	}
+	if _, err := parsePatch("@@ -1 +1 @@\n-a\n+b\n"); err == nil {
+		t.Error("expected an error for a hunk without a file header")
+	}
+}
+
+func TestApplyPatchInWorkingDirectory(t *testing.T) {
+	root := t.TempDir()
+	previous, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(previous)
+	if err := os.MkdirAll(filepath.Join(root, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "api", "routes.go"), []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workDir := NewWorkingDirectory(root)
+	if _, err := workDir.Set("api"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Paths are relative to the working directory, not the workspace root
+	patch := "--- a/routes.go\n+++ b/routes.go\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n"
+	_, result, err := applyPatch(context.Background(), map[string]interface{}{"patch": patch}, nil, nil, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "api", "routes.go")); string(data) != "a\nB\n" {
+		t.Errorf("routes.go = %q", data)
+	}
+	if !strings.Contains(result, "Patched "+filepath.Join("api", "routes.go")) {
+		t.Errorf("result %q doesn't name the file from the root", result)
+	}
+}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ProgressFunc receives a running tool's status, like "Receiving objects: 40%"
+type ProgressFunc func(status string)
+
+type progressKey struct{}
+
+// WithProgress returns a context in which tools report their status to progress while they run
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// ReportProgress reports the running tool's status, if anyone is listening. Only the first line of
+// the status is kept.
+func ReportProgress(ctx context.Context, format string, args ...interface{}) {
+	progress, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || progress == nil {
+		return
+	}
+	status := strings.TrimSpace(fmt.Sprintf(format, args...))
+	if line, _, found := strings.Cut(status, "\n"); found {
+		status = strings.TrimSpace(line)
+	}
+	if status != "" {
+		progress(status)
+	}
+}
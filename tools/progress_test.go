@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportProgress(t *testing.T) {
+	// Without a listener, reporting does nothing
+	ReportProgress(context.Background(), "ignored")
+
+	var statuses []string
+	ctx := WithProgress(context.Background(), func(status string) { statuses = append(statuses, status) })
+	ReportProgress(ctx, "cloning %d%%", 40)
+	ReportProgress(ctx, "  first line\nsecond line")
+	ReportProgress(ctx, "   ")
+	assert.Equal(t, []string{"cloning 40%", "first line"}, statuses)
+
+	// The shell reports the last line of output as the command writes it
+	statuses = nil
+	tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, ShellInteractiveConfig{}, nil)
+	_, _, err := tool.Func(ctx, map[string]interface{}{"command": "printf 'Receiving objects: 40%%\\rReceiving objects: 100%%\\n'; sleep 0.5"})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, statuses) {
+		assert.Equal(t, "Receiving objects: 100%", statuses[len(statuses)-1])
+	}
+}
@@ -1,28 +1,131 @@
 package tools
 
-import "agent/models"
+import (
+	"agent/artifacts"
+	"agent/index"
+	"agent/lsp"
+	"agent/models"
+)
+
+// Dependencies are the state the tools share with the agent. Optional tools are left out when what
+// they depend on is nil.
+type Dependencies struct {
+	LiveContext   LiveContextManager
+	DeleteMessage DeleteMessageFunc
+	Journal       *ChangeJournal
+	Templates     *FileTemplates
+	Formatter     *Formatter
+
+	Sandbox      SandboxConfig
+	Auditor      *CommandAuditor
+	ShellHistory *ShellHistory
+	WorkDir      *WorkingDirectory
+	ShellEnv     ShellEnvConfig
+	ShellOutput  ShellOutputConfig
+	Summarize    OutputSummarizer
+	Interactive  ShellInteractiveConfig
+	Ask          PromptFunc
+
+	LSP         *lsp.Manager
+	SearchIndex *index.Index
+	Artifacts   *artifacts.Store
+	Anchors     *Anchors
+	Board       *TaskBoard
+	Docs        DocsProvider
+	Spawn       SpawnFunc
+	Vision      func() bool // whether the current model accepts images
+}
 
 // NewToolRegistry creates a map of all available tools
-func NewToolRegistry(liveContext LiveContextManager, deleteMessageFunc DeleteMessageFunc, getModel func() *models.Model) map[string]models.ToolDefinition {
+func NewToolRegistry(deps Dependencies) map[string]models.ToolDefinition {
 	tools := make(map[string]models.ToolDefinition)
 
 	// File tools
-	tools["create_file"] = NewCreateFileTool()
-	tools["edit_file"] = NewEditFileTool()
-	tools["delete_file"] = NewDeleteFileTool()
+	tools["create_file"] = NewCreateFileTool(deps.Journal, deps.Templates, deps.Formatter)
+	tools["edit_file"] = NewEditFileTool(deps.Journal, deps.Formatter)
+	tools["delete_file"] = NewDeleteFileTool(deps.Journal)
+	tools["multi_edit"] = NewMultiEditTool(deps.Journal, deps.Formatter)
+	tools["insert_lines"] = NewInsertLinesTool(deps.Journal, deps.Formatter)
+	tools["append_to_file"] = NewAppendToFileTool(deps.Journal, deps.Formatter)
+	tools["apply_patch"] = NewApplyPatchTool(deps.Journal, deps.Formatter, deps.WorkDir)
+	tools["undo_edit"] = NewUndoEditTool(deps.Journal)
 
-	// Shell tool
-	tools["shell"] = NewShellTool(getModel)
+	// Shell tools
+	tools["shell"] = NewShellTool(deps.Sandbox, deps.Auditor, deps.ShellHistory, deps.WorkDir, deps.ShellEnv, deps.ShellOutput, deps.Summarize, deps.Interactive, deps.Ask)
+	tools["run_tests"] = NewRunTestsTool(deps.Sandbox, deps.WorkDir, deps.ShellEnv)
+	if deps.WorkDir != nil {
+		tools["set_working_directory"] = NewSetWorkingDirectoryTool(deps.WorkDir)
+	}
 
-	// Context tools (only add if dependencies are provided)
-	if liveContext != nil {
-		tools["read_file"] = NewReadFileTool(liveContext)
-		tools["stop_reading_file"] = NewStopReadingFileTool(liveContext)
-		tools["read_directory"] = NewReadDirectoryTool(liveContext)
-		tools["stop_reading_directory"] = NewStopReadingDirectoryTool(liveContext)
-		tools["remove_message"] = NewRemoveMessageTool(deleteMessageFunc)
+	// Git tools
+	tools["git_status"] = NewGitStatusTool()
+	tools["git_diff"] = NewGitDiffTool()
+	tools["git_log"] = NewGitLogTool()
+	tools["git_commit"] = NewGitCommitTool()
+	tools["git_branch"] = NewGitBranchTool()
+
+	// Artifact tools
+	if deps.Artifacts != nil {
+		tools["save_artifact"] = NewSaveArtifactTool(deps.Artifacts)
+		tools["read_artifact"] = NewReadArtifactTool(deps.Artifacts)
+		tools["list_artifacts"] = NewListArtifactsTool(deps.Artifacts)
+	}
+
+	// Code navigation tools
+	tools["code_outline"] = NewCodeOutlineTool()
+	tools["glob"] = NewGlobTool()
+	tools["lookup_docs"] = NewLookupDocsTool(deps.Docs)
+	if deps.SearchIndex != nil {
+		tools["semantic_search"] = NewSemanticSearchTool(deps.SearchIndex)
+	}
+
+	// Language server tools
+	if deps.LSP != nil {
+		tools["get_diagnostics"] = NewGetDiagnosticsTool(deps.LSP)
+		tools["find_definition"] = NewFindDefinitionTool(deps.LSP)
+		tools["find_references"] = NewFindReferencesTool(deps.LSP)
+	}
 
+	// Planning tools
+	if deps.Anchors != nil {
+		tools["set_anchor"] = NewSetAnchorTool(deps.Anchors)
+	}
+	if deps.Board != nil {
+		tools["task_add"] = NewTaskAddTool(deps.Board)
+		tools["task_update"] = NewTaskUpdateTool(deps.Board)
+		tools["task_list"] = NewTaskListTool(deps.Board)
+	}
+
+	// Sub-agent tool
+	if deps.Spawn != nil {
+		tools["spawn_agent"] = NewSpawnAgentTool(deps.Spawn)
+	}
+
+	// Image tool
+	if deps.Vision != nil {
+		tools["view_image"] = NewViewImageTool(deps.Vision)
+	}
+
+	// Context tools (only add if dependencies are provided)
+	if deps.LiveContext != nil {
+		for name, tool := range NewContextTools(deps.LiveContext) {
+			tools[name] = tool
+		}
+	}
+	if deps.DeleteMessage != nil {
+		tools["remove_message"] = NewRemoveMessageTool(deps.DeleteMessage)
 	}
 
 	return tools
 }
+
+// NewContextTools creates the tools that read files and directories into liveContext
+func NewContextTools(liveContext LiveContextManager) map[string]models.ToolDefinition {
+	return map[string]models.ToolDefinition{
+		"read_file":              NewReadFileTool(liveContext),
+		"stop_reading_file":      NewStopReadingFileTool(liveContext),
+		"read_directory":         NewReadDirectoryTool(liveContext),
+		"expand_directory":       NewExpandDirectoryTool(liveContext),
+		"stop_reading_directory": NewStopReadingDirectoryTool(liveContext),
+	}
+}
@@ -0,0 +1,26 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewToolRegistry(t *testing.T) {
+	registry := NewToolRegistry(Dependencies{})
+	for _, name := range []string{"create_file", "shell", "run_tests", "git_status", "glob"} {
+		assert.Contains(t, registry, name)
+	}
+	// Tools whose dependencies are missing are left out
+	for _, name := range []string{"read_file", "remove_message", "set_working_directory", "semantic_search", "task_add", "spawn_agent", "view_image"} {
+		assert.NotContains(t, registry, name)
+	}
+
+	registry = NewToolRegistry(Dependencies{WorkDir: NewWorkingDirectory(t.TempDir()), Board: NewTaskBoard(), Vision: func() bool { return true }})
+	for _, name := range []string{"set_working_directory", "task_add", "task_update", "task_list", "view_image"} {
+		assert.Contains(t, registry, name)
+	}
+	for name, tool := range registry {
+		assert.Equal(t, name, tool.Name)
+	}
+}
@@ -4,38 +4,30 @@ import (
 	"agent/models"
 	"context"
 	"fmt"
+	"strings"
 )
 
-// DeleteMessageFunc is the callback function type for deleting messages
-type DeleteMessageFunc func(role, contentContains string) (bool, error)
+// DeleteMessageFunc removes the message with the given ID from the conversation history. It returns
+// the removed messages, the requested one first followed by the tool calls or results removed with
+// it, or nil if no message has that ID.
+type DeleteMessageFunc func(messageID string) ([]models.Message, error)
 
-// NewDeleteMessageTool creates a delete_message tool definition
+// NewRemoveMessageTool creates a remove_message tool definition
 func NewRemoveMessageTool(deleteMessageFunc DeleteMessageFunc) models.ToolDefinition {
 	schema := map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
-			"role": map[string]interface{}{
-				"type":        "string",
-				"description": "Role of the message to delete",
-				"enum":        []interface{}{"user", "assistant", "tool"},
-			},
 			"message_id": map[string]interface{}{
 				"type":        "string",
 				"description": "ID of the message to delete",
 			},
 		},
-		"required": []interface{}{
-			"role", "message_id",
-		},
-		"anyOf": []interface{}{
-			map[string]interface{}{"required": []interface{}{"message_id"}},
-			map[string]interface{}{"required": []interface{}{"recent_count"}},
-		},
+		"required": []interface{}{"message_id"},
 	}
 
 	return models.ToolDefinition{
 		Name:        "remove_message",
-		Description: "Delete a message from the conversation history using role and message ID. Useful for clearing context when messages are no longer useful. Good examples of messages to delete: old build logs, search results that aren't needed, tool calls after their results are applied. Deleting larger messages is more helpful that deleting smaller messages. Bias towards not removing user messages unless they are definitely not needed and large.",
+		Description: "Delete a message from the conversation history by its ID. Useful for clearing context when messages are no longer useful. Good examples of messages to delete: old build logs, search results that aren't needed, tool calls after their results are applied. A tool call and its results are always deleted together. Deleting larger messages is more helpful that deleting smaller messages. Bias towards not removing user messages unless they are definitely not needed and large.",
 		Schema:      schema,
 		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
 			return removeMessage(ctx, params, deleteMessageFunc)
@@ -45,14 +37,8 @@ func NewRemoveMessageTool(deleteMessageFunc DeleteMessageFunc) models.ToolDefini
 
 // removeMessage implements the delete message functionality
 func removeMessage(ctx context.Context, params map[string]interface{}, deleteMessageFunc DeleteMessageFunc) (string, string, error) {
-	role, ok := params["role"].(string)
-	if !ok {
-		return "", "", fmt.Errorf("role must be one of: user, assistant, tool")
-	}
-
 	messageID, hasMessageID := params["message_id"].(string)
-
-	if !hasMessageID {
+	if !hasMessageID || messageID == "" {
 		return "", "", fmt.Errorf("must provide message_id")
 	}
 
@@ -60,13 +46,21 @@ func removeMessage(ctx context.Context, params map[string]interface{}, deleteMes
 		return "", "", fmt.Errorf("message deletion function not set")
 	}
 
-	deleted, err := deleteMessageFunc(role, messageID)
+	removed, err := deleteMessageFunc(messageID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to delete message: %w", err)
 	}
-	if deleted {
-		return fmt.Sprintf("Deleted %s message with ID: %s\n", role, messageID), "Deleted", nil
+	if len(removed) == 0 {
+		return "Message not found\n", "Not found", nil
 	}
 
-	return "Message not found\n", "Not found", nil
+	result := fmt.Sprintf("Deleted %s message with ID: %s\n", removed[0].Role, messageID)
+	if len(removed) > 1 {
+		var paired []string
+		for _, msg := range removed[1:] {
+			paired = append(paired, fmt.Sprintf("%s %s", msg.Role, msg.ID))
+		}
+		result += fmt.Sprintf("Also deleted the tool call messages paired with it: %s\n", strings.Join(paired, ", "))
+	}
+	return result, "Deleted", nil
 }
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"agent/models"
 	"context"
 	"testing"
 
@@ -9,33 +10,43 @@ import (
 
 func TestRemoveMessageTool(t *testing.T) {
 	// Mock DeleteMessageFunc
-	mockDeleteMessageFunc := func(role, messageID string) (bool, error) {
-		// In a real scenario, you might log or store these calls for assertion
-		return true, nil
+	messages := map[string]models.Message{
+		"123": {ID: "123", Role: "user"},
+		"456": {ID: "456", Role: "assistant"},
+	}
+	mockDeleteMessageFunc := func(messageID string) ([]models.Message, error) {
+		if msg, ok := messages[messageID]; ok {
+			return []models.Message{msg}, nil
+		}
+		if messageID == "789" {
+			return []models.Message{{ID: "789", Role: "tool"}, {ID: "788", Role: "assistant"}}, nil
+		}
+		return nil, nil
 	}
 
 	// Create the tool directly
 	tool := NewRemoveMessageTool(mockDeleteMessageFunc)
 
 	// Test case 1: Remove a user message
-	params := map[string]interface{}{"message_id": "123", "role": "user"}
+	params := map[string]interface{}{"message_id": "123"}
 	userMsg, agentMsg, err := tool.Func(context.Background(), params)
 	assert.NoError(t, err)
 	assert.Contains(t, userMsg, "Deleted user message with ID: 123")
 	assert.Equal(t, agentMsg, "Deleted")
 
 	// Test case 2: Remove an assistant message
-	params = map[string]interface{}{"message_id": "456", "role": "assistant"}
+	params = map[string]interface{}{"message_id": "456"}
 	userMsg, agentMsg, err = tool.Func(context.Background(), params)
 	assert.NoError(t, err)
 	assert.Contains(t, userMsg, "Deleted assistant message with ID: 456")
 	assert.Equal(t, agentMsg, "Deleted")
 
-	// Test case 3: Remove a tool message
-	params = map[string]interface{}{"message_id": "789", "role": "tool"}
+	// Test case 3: Remove a tool message, which takes its call with it
+	params = map[string]interface{}{"message_id": "789"}
 	userMsg, agentMsg, err = tool.Func(context.Background(), params)
 	assert.NoError(t, err)
 	assert.Contains(t, userMsg, "Deleted tool message with ID: 789")
+	assert.Contains(t, userMsg, "assistant 788")
 	assert.Equal(t, agentMsg, "Deleted")
 
 	// Test case 4: Missing message_id
@@ -46,13 +57,9 @@ func TestRemoveMessageTool(t *testing.T) {
 	assert.Empty(t, userMsg)
 	assert.Empty(t, agentMsg)
 
-	// Test case 5: DeleteMessageFunc returns false (message not found)
-	mockDeleteMessageFuncNotFound := func(role, messageID string) (bool, error) {
-		return false, nil
-	}
-	toolNotFound := NewRemoveMessageTool(mockDeleteMessageFuncNotFound)
-	params = map[string]interface{}{"message_id": "999", "role": "user"}
-	userMsg, agentMsg, err = toolNotFound.Func(context.Background(), params)
+	// Test case 5: message not found
+	params = map[string]interface{}{"message_id": "999"}
+	userMsg, agentMsg, err = tool.Func(context.Background(), params)
 	assert.NoError(t, err)
 	assert.Contains(t, userMsg, "Message not found")
 	assert.Equal(t, agentMsg, "Not found")
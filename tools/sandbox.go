@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const defaultSandboxImage = "debian:stable-slim"
+
+// SandboxConfig selects how shell commands are isolated from the host. Sandboxed commands can read
+// the filesystem (or the image, for docker) except the home directory, but can only write to the
+// workspace, and not to its .git directory.
+type SandboxConfig struct {
+	Backend  string   `json:"backend"`   // "none" (default), "firejail", "bubblewrap", or "docker"
+	Image    string   `json:"image"`     // docker image with the project's toolchain (default debian:stable-slim)
+	Network  bool     `json:"network"`   // allow network access inside the sandbox
+	ShowHome bool     `json:"show_home"` // let commands read the home directory, which holds ~/.ssh, ~/.aws, and the like
+	Args     []string `json:"args"`      // extra arguments passed to the backend
+}
+
+// Enabled reports whether commands run in a sandbox
+func (c SandboxConfig) Enabled() bool {
+	return c.Backend != "" && c.Backend != "none"
+}
+
+// Command builds the command that runs command in workDir inside the configured sandbox. The
+// workspace root is writable; a workDir outside it is refused, since the sandbox couldn't write there.
+func (c SandboxConfig) Command(ctx context.Context, command, root, workDir string) (*exec.Cmd, error) {
+	var name string
+	var args []string
+
+	if c.Enabled() && !withinDir(root, workDir) {
+		return nil, fmt.Errorf("%s is outside the workspace %s, which is all the sandbox can write to", workDir, root)
+	}
+	home := c.hiddenHome()
+	// Hooks and config in .git run outside the sandbox the next time the agent runs git, so the
+	// sandbox can read the repository but not change it
+	gitDir := ""
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		gitDir = filepath.Join(root, ".git")
+	}
+
+	switch c.Backend {
+	case "", "none":
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = workDir
+		return cmd, nil
+	case "firejail":
+		name = "firejail"
+		args = []string{"--quiet", "--noprofile", "--read-only=/", "--read-write=" + root, "--private-tmp", "--private-dev"}
+		if gitDir != "" {
+			args = append(args, "--read-only="+gitDir)
+		}
+		if home != "" {
+			// A whitelisted path under home hides the rest of it
+			if withinDir(home, root) && root != home {
+				args = append(args, "--whitelist="+root)
+			} else if !withinDir(root, home) {
+				args = append(args, "--blacklist="+home)
+			}
+		}
+		if !c.Network {
+			args = append(args, "--net=none")
+		}
+	case "bubblewrap", "bwrap":
+		name = "bwrap"
+		args = []string{"--ro-bind", "/", "/"}
+		if home != "" {
+			// The workspace is bound after, so it stays visible when it's under home
+			args = append(args, "--tmpfs", home)
+		}
+		args = append(args, "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp",
+			"--bind", root, root)
+		if gitDir != "" {
+			args = append(args, "--ro-bind", gitDir, gitDir)
+		}
+		args = append(args, "--chdir", workDir, "--unshare-all", "--die-with-parent")
+		if c.Network {
+			args = append(args, "--share-net")
+		}
+	case "docker":
+		name = "docker"
+		image := c.Image
+		if image == "" {
+			image = defaultSandboxImage
+		}
+		// Only the workspace is mounted, so home is never visible
+		args = []string{"run", "--rm", "-i", "--init", "-v", root + ":" + root}
+		if gitDir != "" {
+			args = append(args, "-v", gitDir+":"+gitDir+":ro")
+		}
+		args = append(args, "-w", workDir, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+		if !c.Network {
+			args = append(args, "--network", "none")
+		}
+		args = append(args, c.Args...)
+		args = append(args, image, "sh", "-c", command)
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q (use none, firejail, bubblewrap, or docker)", c.Backend)
+	}
+
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("sandbox backend %s is configured but `%s` isn't installed; install it or set sandbox.backend to none", c.Backend, name)
+	}
+	if c.Backend != "docker" {
+		args = append(args, c.Args...)
+		args = append(args, "--", "sh", "-c", command)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = workDir
+	return cmd, nil
+}
+
+// hiddenHome returns the home directory to hide from commands, or "" to leave it visible
+func (c SandboxConfig) hiddenHome() string {
+	if c.ShowHome {
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || !filepath.IsAbs(home) || filepath.Clean(home) == "/" {
+		return ""
+	}
+	return filepath.Clean(home)
+}
+
+// withinDir reports whether path is dir or inside it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
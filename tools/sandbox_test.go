@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	ctx := context.Background()
+	bin := t.TempDir()
+	for _, name := range []string{"firejail", "bwrap", "docker"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0755))
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("HOME", "/home/dev")
+
+	cmd, err := SandboxConfig{}.Command(ctx, "ls", "/work", "/work")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sh", "-c", "ls"}, cmd.Args)
+
+	cmd, err = SandboxConfig{Backend: "firejail"}.Command(ctx, "ls", "/work", "/work")
+	assert.NoError(t, err)
+	assert.Contains(t, cmd.Args, "--read-write=/work")
+	assert.Contains(t, cmd.Args, "--blacklist=/home/dev")
+	assert.Contains(t, cmd.Args, "--net=none")
+	assert.Equal(t, []string{"--", "sh", "-c", "ls"}, cmd.Args[len(cmd.Args)-4:])
+
+	cmd, err = SandboxConfig{Backend: "bubblewrap", Network: true}.Command(ctx, "ls", "/work", "/work")
+	assert.NoError(t, err)
+	assert.Contains(t, cmd.Args, "--share-net")
+	assert.Equal(t, []string{"--tmpfs", "/home/dev"}, cmd.Args[4:6])
+	assert.Equal(t, []string{"--", "sh", "-c", "ls"}, cmd.Args[len(cmd.Args)-4:])
+
+	// A workspace under home stays visible and writable, and commands can run in its subdirectories
+	cmd, err = SandboxConfig{Backend: "bwrap"}.Command(ctx, "ls", "/home/dev/project", "/home/dev/project/src")
+	assert.NoError(t, err)
+	args := strings.Join(cmd.Args, " ")
+	assert.Contains(t, args, "--tmpfs /home/dev --dev")
+	assert.Contains(t, args, "--bind /home/dev/project /home/dev/project --chdir /home/dev/project/src")
+	cmd, err = SandboxConfig{Backend: "firejail"}.Command(ctx, "ls", "/home/dev/project", "/home/dev/project")
+	assert.NoError(t, err)
+	assert.Contains(t, cmd.Args, "--whitelist=/home/dev/project")
+
+	cmd, err = SandboxConfig{Backend: "bwrap", ShowHome: true}.Command(ctx, "ls", "/work", "/work")
+	assert.NoError(t, err)
+	assert.NotContains(t, cmd.Args, "/home/dev")
+
+	for _, backend := range []string{"firejail", "bwrap", "docker"} {
+		_, err = SandboxConfig{Backend: backend}.Command(ctx, "ls", "/work", "/home/dev/.ssh")
+		assert.ErrorContains(t, err, "outside the workspace", backend)
+		_, err = SandboxConfig{Backend: backend}.Command(ctx, "ls", "/work", "/work-other")
+		assert.ErrorContains(t, err, "outside the workspace", backend)
+	}
+
+	cmd, err = SandboxConfig{Backend: "docker", Image: "golang:1.23", Args: []string{"--memory", "2g"}}.Command(ctx, "go test", "/work", "/work/cmd")
+	assert.NoError(t, err)
+	assert.Contains(t, cmd.Args, "/work:/work")
+	assert.Contains(t, cmd.Args, "/work/cmd")
+	assert.Contains(t, cmd.Args, "none")
+	assert.Equal(t, []string{"--memory", "2g", "golang:1.23", "sh", "-c", "go test"}, cmd.Args[len(cmd.Args)-6:])
+
+	// The workspace's .git is mounted read-only, so commands can't plant hooks or config that git
+	// would run outside the sandbox
+	repo := t.TempDir()
+	gitDir := filepath.Join(repo, ".git")
+	assert.NoError(t, os.Mkdir(gitDir, 0755))
+	cmd, err = SandboxConfig{Backend: "firejail"}.Command(ctx, "ls", repo, repo)
+	assert.NoError(t, err)
+	args = strings.Join(cmd.Args, " ")
+	assert.Contains(t, args, "--read-write="+repo+" ")
+	assert.Contains(t, args, "--read-only="+gitDir+" ")
+	assert.Less(t, strings.Index(args, "--read-write="+repo), strings.Index(args, "--read-only="+gitDir), "the later, narrower rule wins")
+	cmd, err = SandboxConfig{Backend: "bwrap"}.Command(ctx, "ls", repo, repo)
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), "--bind "+repo+" "+repo+" --ro-bind "+gitDir+" "+gitDir+" --chdir")
+	cmd, err = SandboxConfig{Backend: "docker"}.Command(ctx, "ls", repo, repo)
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), "-v "+repo+":"+repo+" -v "+gitDir+":"+gitDir+":ro -w")
+	cmd, err = SandboxConfig{Backend: "bwrap"}.Command(ctx, "ls", "/work", "/work")
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), "--bind /work /work --chdir", "nothing to protect without a .git")
+
+	_, err = SandboxConfig{Backend: "chroot"}.Command(ctx, "ls", "/work", "/work")
+	assert.ErrorContains(t, err, "unknown sandbox backend")
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = SandboxConfig{Backend: "docker"}.Command(ctx, "ls", "/work", "/work")
+	assert.ErrorContains(t, err, "isn't installed")
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"agent/index"
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NewSemanticSearchTool creates the semantic_search tool
+func NewSemanticSearchTool(ix *index.Index) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "semantic_search",
+		Description: "Search the workspace by meaning rather than exact text, e.g. \"where are API retries handled\". Returns the best-matching file line ranges with a short preview; read_file the ranges you need. Requires an index built with /index. The user doesn't see the result.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Natural-language description of the code you're looking for",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Optional: Maximum number of results (default: 8)",
+					"minimum":     1,
+				},
+			},
+			"required": []interface{}{"query"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return semanticSearch(ctx, params, ix)
+		},
+	}
+}
+
+func semanticSearch(ctx context.Context, params map[string]interface{}, ix *index.Index) (string, string, error) {
+	query, ok := params["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return "", "", fmt.Errorf("query must be a non-empty string")
+	}
+	limit := 8
+	if l, ok := params["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	results, err := ix.Search(ctx, query, limit)
+	if err != nil {
+		return "", "", WrapToolError("semantic_search", err)
+	}
+	if len(results) == 0 {
+		return "No matches\n", "No matches", nil
+	}
+
+	var output strings.Builder
+	for _, result := range results {
+		output.WriteString(fmt.Sprintf("%s:%d-%d (score %.2f)\n", result.Path, result.StartLine, result.EndLine, result.Score))
+		for _, line := range previewLines(result.Path, result.StartLine, 3) {
+			output.WriteString("    " + line + "\n")
+		}
+	}
+
+	return fmt.Sprintf("Found %d matches for %q\n", len(results), query), strings.TrimRight(output.String(), "\n"), nil
+}
+
+// previewLines returns up to count non-blank lines of a file starting at a 1-based line
+func previewLines(path string, start, count int) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	var preview []string
+	for i := start - 1; i < len(lines) && len(preview) < count; i++ {
+		if i >= 0 && strings.TrimSpace(lines[i]) != "" {
+			preview = append(preview, strings.TrimRight(lines[i], " \t"))
+		}
+	}
+	return preview
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ShellEnvConfig controls which environment variables commands the model writes can see, so
+// credentials in the user's environment don't reach them. Patterns are globs matched against
+// variable names, e.g. "AWS_*".
+type ShellEnvConfig struct {
+	Allow []string          `json:"allow"` // pass only matching variables; empty passes all but Deny
+	Deny  []string          `json:"deny"`  // never pass matching variables, even when allowed
+	Set   map[string]string `json:"set"`   // variables added or overridden, e.g. {"CI": "1"}
+}
+
+// Environ returns the process environment filtered and extended as configured
+func (c ShellEnvConfig) Environ() []string {
+	return c.filter(os.Environ())
+}
+
+func (c ShellEnvConfig) filter(environ []string) []string {
+	var env []string
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, overridden := c.Set[name]; overridden {
+			continue
+		}
+		if len(c.Allow) > 0 && !matchesEnvName(c.Allow, name) {
+			continue
+		}
+		if matchesEnvName(c.Deny, name) {
+			continue
+		}
+		env = append(env, entry)
+	}
+
+	names := make([]string, 0, len(c.Set))
+	for name := range c.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+c.Set[name])
+	}
+	return env
+}
+
+func matchesEnvName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
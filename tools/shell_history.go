@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxHistoryCommandChars = 120
+
+// ShellHistoryEntry is a shell command the agent ran and how it ended
+type ShellHistoryEntry struct {
+	Command  string
+	ExitCode int // -1 when the command couldn't be started
+	Duration time.Duration
+}
+
+// ShellHistory keeps the last few shell commands so the model remembers what it ran after the
+// outputs have been pruned from the conversation
+type ShellHistory struct {
+	mu      sync.Mutex
+	size    int
+	entries []ShellHistoryEntry
+}
+
+// NewShellHistory keeps the last size commands
+func NewShellHistory(size int) *ShellHistory {
+	return &ShellHistory{size: size}
+}
+
+// Record adds a command, dropping the oldest once the window is full. A nil history records nothing.
+func (h *ShellHistory) Record(command string, exitCode int, duration time.Duration) {
+	if h == nil || h.size <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, ShellHistoryEntry{Command: command, ExitCode: exitCode, Duration: duration})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+}
+
+// Entries returns the recorded commands, oldest first
+func (h *ShellHistory) Entries() []ShellHistoryEntry {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]ShellHistoryEntry(nil), h.entries...)
+}
+
+// Summary renders the history as one line per command for the system prompt
+func (h *ShellHistory) Summary() string {
+	var summary strings.Builder
+	for _, entry := range h.Entries() {
+		command := strings.Join(strings.Fields(entry.Command), " ")
+		if runes := []rune(command); len(runes) > maxHistoryCommandChars {
+			command = string(runes[:maxHistoryCommandChars-3]) + "..."
+		}
+		status := fmt.Sprintf("exit %d", entry.ExitCode)
+		if entry.ExitCode == -1 {
+			status = "failed to start"
+		}
+		summary.WriteString(fmt.Sprintf("- `%s` → %s (%s)\n", command, status, entry.Duration.Round(100*time.Millisecond)))
+	}
+	return summary.String()
+}
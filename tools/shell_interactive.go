@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"agent/logging"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// DefaultPromptTimeoutSeconds is how long a command may sit at a prompt before the shell tool
+// treats it as waiting for input
+const DefaultPromptTimeoutSeconds = 10
+
+// DefaultCommandTimeoutSeconds is how long a shell command may run before it's stopped
+const DefaultCommandTimeoutSeconds = 600
+
+// ShellInteractiveConfig controls how the shell tool handles commands that wait for terminal input,
+// like git commit without -m or npm init. Commands run under a pseudo-terminal so they behave as
+// they would for the user; once one stops at a prompt, it's either forwarded to the user or stopped.
+type ShellInteractiveConfig struct {
+	DisablePTY           bool `json:"disable_pty"`            // run commands with pipes instead of a pseudo-terminal
+	PromptTimeoutSeconds int  `json:"prompt_timeout_seconds"` // seconds without output at a prompt before acting (default 10)
+	ForwardPrompts       bool `json:"forward_prompts"`        // ask the user to answer prompts instead of stopping the command
+	TimeoutSeconds       int  `json:"timeout_seconds"`        // seconds a command may run in all before it's stopped (default 600)
+}
+
+// PromptFunc asks the user a question and returns their answer; ok is false when input has ended
+type PromptFunc func(question string) (answer string, ok bool)
+
+// InteractiveError is returned for a command stopped because it waited for input
+type InteractiveError struct {
+	Prompt  string
+	Timeout time.Duration
+}
+
+func (e *InteractiveError) Error() string {
+	return fmt.Sprintf("interactive command not supported: it waited for input for %v at %q and was stopped. Run it non-interactively instead, e.g. with flags like -m or --yes, or with its input piped to stdin", e.Timeout, e.Prompt)
+}
+
+// CommandTimeoutError is returned for a command stopped because it ran longer than timeout_seconds
+type CommandTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	return fmt.Sprintf("the command was stopped after running for %v. Run long jobs in the background with their output sent to a file, or split them up", e.Timeout)
+}
+
+func (c ShellInteractiveConfig) commandTimeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return DefaultCommandTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+func (c ShellInteractiveConfig) promptTimeout() time.Duration {
+	if c.PromptTimeoutSeconds <= 0 {
+		return DefaultPromptTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.PromptTimeoutSeconds) * time.Second
+}
+
+// run runs cmd and returns its combined output as the terminal would show it. A command that stops
+// at a prompt is forwarded to ask when prompts are forwarded, and otherwise killed with an
+// *InteractiveError. A command that goes quiet without a prompt is sent end of input, in case it's
+// reading stdin, and one that runs past the command timeout is killed with a *CommandTimeoutError.
+// Other errors are as from cmd.Wait.
+func (c ShellInteractiveConfig) run(ctx context.Context, cmd *exec.Cmd, command string, ask PromptFunc) (string, error) {
+	var output, child, pty, tty *os.File
+	var input io.Writer
+	var sendEOF func()
+	var err error
+	if !c.DisablePTY {
+		pty, tty, err = openPTY()
+		if err != nil {
+			logging.Debugf("Running %q without a pseudo-terminal: %v", command, err)
+		}
+	}
+	if pty != nil {
+		attachTTY(cmd, tty)
+		output, input, child = pty, pty, tty
+		// Ctrl-D ends a read from the terminal
+		sendEOF = func() { pty.Write([]byte{0x04}) }
+	} else {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return "", err
+		}
+		// Holding stdin open keeps a command that reads it waiting at its prompt so the user can
+		// answer; otherwise it gets end of input and fails right away
+		if c.ForwardPrompts && ask != nil {
+			stdin, err := cmd.StdinPipe()
+			if err != nil {
+				reader.Close()
+				writer.Close()
+				return "", err
+			}
+			input = stdin
+			sendEOF = func() {
+				stdin.Close()
+				sendEOF = nil
+			}
+		}
+		cmd.Stdout, cmd.Stderr = writer, writer
+		newProcessGroup(cmd)
+		output, child = reader, writer
+	}
+	defer output.Close()
+
+	err = cmd.Start()
+	child.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	activity := make(chan struct{}, 1)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		chunk := make([]byte, 4096)
+		for {
+			n, err := output.Read(chunk)
+			if n > 0 {
+				mu.Lock()
+				buf.Write(chunk[:n])
+				mu.Unlock()
+				select {
+				case activity <- struct{}{}:
+				default:
+				}
+			}
+			// A pseudo-terminal reports EIO rather than EOF once the command closes it
+			if err != nil {
+				return
+			}
+		}
+	}()
+	text := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return terminalText(buf.String())
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	timeout := c.promptTimeout()
+	idle := time.NewTimer(timeout)
+	defer idle.Stop()
+	deadline := time.NewTimer(c.commandTimeout())
+	defer deadline.Stop()
+	done := ctx.Done()
+	eofSent := false
+	for {
+		select {
+		case err := <-exited:
+			// Background processes the command started may hold the terminal open; don't wait on them
+			select {
+			case <-drained:
+			case <-time.After(200 * time.Millisecond):
+			}
+			return text(), err
+		case <-activity:
+			idle.Reset(timeout)
+			eofSent = false
+			mu.Lock()
+			tail := string(buf.Bytes()[max(0, buf.Len()-512):])
+			mu.Unlock()
+			ReportProgress(ctx, "%s", lastLine(tail))
+		case <-done:
+			killProcessGroup(cmd)
+			done = nil
+		case <-deadline.C:
+			killProcessGroup(cmd)
+			<-exited
+			return text(), &CommandTimeoutError{Timeout: c.commandTimeout()}
+		case <-idle.C:
+			mu.Lock()
+			raw := buf.String()
+			mu.Unlock()
+			prompt, answerable, waiting := detectPrompt(raw)
+			if !waiting {
+				// A quiet command may be reading stdin without a prompt, like cat; end its input
+				// rather than leave it waiting until the command timeout
+				if !eofSent && sendEOF != nil {
+					sendEOF()
+					eofSent = true
+				}
+				idle.Reset(timeout)
+				continue
+			}
+			if answerable && c.ForwardPrompts && ask != nil && input != nil {
+				answer, ok := ask(fmt.Sprintf("`%s` is waiting for input: %s\nAnswer (empty to stop the command):", command, prompt))
+				if ok && answer != "" {
+					io.WriteString(input, answer+"\n")
+					idle.Reset(timeout)
+					continue
+				}
+			}
+			killProcessGroup(cmd)
+			<-exited
+			return text(), &InteractiveError{Prompt: prompt, Timeout: timeout}
+		}
+	}
+}
+
+// fullScreenSequences switch a terminal to its alternate screen, which editors and pagers do when
+// they start
+var fullScreenSequences = []string{"\x1b[?1049h", "\x1b[?47h", "\x1b[?1047h"}
+
+// promptWords mark the last line of output as a prompt wherever they appear in it
+var promptWords = []string{"password", "passphrase", "y/n", "yes/no", "continue?"}
+
+// promptEndings end the last line of output when a command is asking for something. The trailing
+// space matters: progress bars and counters that stall also end in brackets, parentheses, or "%".
+var promptEndings = []string{": ", "? "}
+
+// detectPrompt reports whether raw output that has stopped changing looks like a command waiting for
+// input, and the prompt it's waiting at. Full-screen programs like editors can't be answered a line
+// at a time, so answerable is false for them.
+func detectPrompt(raw string) (prompt string, answerable, waiting bool) {
+	for _, sequence := range fullScreenSequences {
+		if strings.Contains(raw, sequence) {
+			return "a full-screen program such as an editor or pager", false, true
+		}
+	}
+
+	// Prompts leave the cursor on the line they printed, so the output doesn't end with a newline
+	text := terminalText(raw)
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return "", false, false
+	}
+	line := text[strings.LastIndex(text, "\n")+1:]
+	prompt = strings.TrimSpace(line)
+	if prompt == "" {
+		return "", false, false
+	}
+	lower := strings.ToLower(prompt)
+	for _, word := range promptWords {
+		if strings.Contains(lower, word) {
+			return prompt, true, true
+		}
+	}
+	for _, ending := range promptEndings {
+		if strings.HasSuffix(line, ending) {
+			return prompt, true, true
+		}
+	}
+	return "", false, false
+}
+
+// lastLine returns the last non-empty line of terminal output, what a user watching it would see
+func lastLine(raw string) string {
+	lines := strings.Split(strings.TrimRight(terminalText(raw), " \n"), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// terminalText converts terminal output to plain text: escape sequences are dropped, and lines
+// rewritten with carriage returns, like progress bars, keep only what was last written.
+func terminalText(raw string) string {
+	lines := strings.Split(ansi.Strip(strings.ReplaceAll(raw, "\r\n", "\n")), "\n")
+	for i, line := range lines {
+		if cr := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); cr >= 0 {
+			lines[i] = line[cr+1:]
+		}
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"agent/logging"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Defaults for ShellOutputConfig
+const (
+	DefaultShellHeadLines = 50
+	DefaultShellTailLines = 100
+)
+
+// ShellOutputConfig limits how much command output the shell tool returns to the model, so one
+// verbose build can't use up the context budget. Zero values use the defaults.
+type ShellOutputConfig struct {
+	HeadLines     int `json:"head_lines"`     // lines kept from the start of long output (default 50)
+	TailLines     int `json:"tail_lines"`     // lines kept from the end of long output (default 100)
+	SummarizeOver int `json:"summarize_over"` // lines above which a miniagent summarizes the output (0 = never)
+}
+
+// OutputSummarizer condenses long command output for the model
+type OutputSummarizer func(ctx context.Context, command, output string) (string, error)
+
+func (c ShellOutputConfig) limits() (int, int) {
+	head, tail := c.HeadLines, c.TailLines
+	if head <= 0 {
+		head = DefaultShellHeadLines
+	}
+	if tail <= 0 {
+		tail = DefaultShellTailLines
+	}
+	return head, tail
+}
+
+// limitOutput returns output as the model should see it: whole when it's short, otherwise its first
+// and last lines with a count of what was left out. Past SummarizeOver lines, a summary of the whole
+// output replaces the first lines when summarize succeeds.
+func (c ShellOutputConfig) limitOutput(ctx context.Context, command, output string, summarize OutputSummarizer) string {
+	lines := strings.Split(output, "\n")
+	head, tail := c.limits()
+
+	if c.SummarizeOver > 0 && len(lines) > c.SummarizeOver && summarize != nil {
+		summary, err := summarize(ctx, command, output)
+		if err == nil && strings.TrimSpace(summary) != "" {
+			kept := min(tail, len(lines))
+			return fmt.Sprintf("(%d lines, summarized)\n%s\n\nLast %d lines:\n%s", len(lines), strings.TrimSpace(summary), kept, strings.Join(lines[len(lines)-kept:], "\n"))
+		}
+		logging.Debugf("Not summarizing output of %q: %v", command, err)
+	}
+
+	if len(lines) <= head+tail {
+		return output
+	}
+	omitted := len(lines) - head - tail
+	return fmt.Sprintf("%s\n... (%d lines omitted) ...\n%s", strings.Join(lines[:head], "\n"), omitted, strings.Join(lines[len(lines)-tail:], "\n"))
+}
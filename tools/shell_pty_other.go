@@ -0,0 +1,26 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// openPTY is only implemented on Linux; elsewhere commands run with pipes
+func openPTY() (pty, tty *os.File, err error) {
+	return nil, nil, errors.New("pseudo-terminals are not supported on this platform")
+}
+
+func attachTTY(cmd *exec.Cmd, tty *os.File) {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+}
+
+func newProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -2,15 +2,18 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestShell(t *testing.T) {
 	ctx := context.Background()
 
 	// Test parameter validations
-	tool := NewShellTool(nil)
+	history := NewShellHistory(2)
+	tool := NewShellTool(SandboxConfig{}, nil, history, nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, ShellInteractiveConfig{}, nil)
 	tests := []struct {
 		name    string
 		params  map[string]interface{}
@@ -113,4 +116,183 @@ func TestShell(t *testing.T) {
 	if !strings.Contains(agentMsg, "error message") {
 		t.Errorf("expected agent message to contain stderr output, got %q", agentMsg)
 	}
+
+	// Only the last two commands are kept
+	entries := history.Entries()
+	if len(entries) != 2 || entries[0].Command != "true" || entries[1].ExitCode != 0 {
+		t.Errorf("expected the last two commands in history, got %+v", entries)
+	}
+}
+
+func TestShellHistorySummary(t *testing.T) {
+	history := NewShellHistory(5)
+	history.Record("go test ./...", 1, 2340*time.Millisecond)
+	history.Record("make\n  build", -1, 0)
+
+	expected := "- `go test ./...` → exit 1 (2.3s)\n- `make build` → failed to start (0s)\n"
+	if summary := history.Summary(); summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, summary)
+	}
+
+	var disabled *ShellHistory
+	disabled.Record("ls", 0, 0)
+	if summary := disabled.Summary(); summary != "" {
+		t.Errorf("expected an empty summary from a nil history, got %q", summary)
+	}
+}
+
+func TestShellOutputLimits(t *testing.T) {
+	ctx := context.Background()
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	output := strings.Join(lines, "\n")
+
+	limited := ShellOutputConfig{HeadLines: 2, TailLines: 3}.limitOutput(ctx, "build", output, nil)
+	if want := "line 1\nline 2\n... (25 lines omitted) ...\nline 28\nline 29\nline 30"; limited != want {
+		t.Errorf("expected %q, got %q", want, limited)
+	}
+	if limited := (ShellOutputConfig{}).limitOutput(ctx, "build", output, nil); limited != output {
+		t.Errorf("expected short output to be kept whole, got %q", limited)
+	}
+
+	// Past the threshold, the summary replaces the first lines
+	summarize := func(ctx context.Context, command, output string) (string, error) {
+		return "build failed in line 30", nil
+	}
+	summarized := ShellOutputConfig{TailLines: 1, SummarizeOver: 20}.limitOutput(ctx, "build", output, summarize)
+	if want := "(30 lines, summarized)\nbuild failed in line 30\n\nLast 1 lines:\nline 30"; summarized != want {
+		t.Errorf("expected %q, got %q", want, summarized)
+	}
+
+	// A failed summary falls back to truncation
+	failing := func(ctx context.Context, command, output string) (string, error) {
+		return "", fmt.Errorf("budget exhausted")
+	}
+	if fallback := (ShellOutputConfig{HeadLines: 2, TailLines: 3, SummarizeOver: 20}).limitOutput(ctx, "build", output, failing); fallback != limited {
+		t.Errorf("expected truncated output, got %q", fallback)
+	}
+}
+
+func TestShellEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/home/me", "AWS_SECRET_ACCESS_KEY=s3cret", "GOPATH=/go", "CI=0"}
+
+	env := ShellEnvConfig{Deny: []string{"AWS_*"}, Set: map[string]string{"CI": "1"}}.filter(environ)
+	if want := []string{"PATH=/bin", "HOME=/home/me", "GOPATH=/go", "CI=1"}; strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+
+	env = ShellEnvConfig{Allow: []string{"PATH", "GO*", "AWS_*"}, Deny: []string{"AWS_SECRET*"}}.filter(environ)
+	if want := []string{"PATH=/bin", "GOPATH=/go"}; strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+
+	// Commands only see what the config lets through
+	t.Setenv("AGENT_TEST_SECRET", "hidden")
+	tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{Deny: []string{"AGENT_TEST_*"}, Set: map[string]string{"AGENT_MODE": "ci"}}, ShellOutputConfig{}, nil, ShellInteractiveConfig{}, nil)
+	_, agentMsg, err := tool.Func(context.Background(), map[string]interface{}{"command": "echo \"[$AGENT_TEST_SECRET][$AGENT_MODE]\""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(agentMsg, "Output: [][ci]") {
+		t.Errorf("expected the secret to be removed and the variable set, got %q", agentMsg)
+	}
+}
+
+func TestShellInteractive(t *testing.T) {
+	for _, tt := range []struct {
+		raw        string
+		prompt     string
+		waiting    bool
+		answerable bool
+	}{
+		{"Building...\n", "", false, false},
+		{"Downloading 10%\rDownloading 90%", "", false, false},
+		{"\x1b[1mpackage name:\x1b[0m ", "package name:", true, true},
+		{"Proceed with the install? ", "Proceed with the install?", true, true},
+		{"Press enter to continue?", "Press enter to continue?", true, true},
+		{"Password:", "Password:", true, true},
+		// Stalled progress bars and counters aren't prompts
+		{"Downloading [=====>      ] 45%", "", false, false},
+		{"Downloading\r[##########          ]", "", false, false},
+		{"Compiling (3/10)", "", false, false},
+		{"Step 2 of 5 >", "", false, false},
+		{"Waiting for lock:", "", false, false},
+		{"Overwrite config.json? [y/N] ", "Overwrite config.json? [y/N]", true, true},
+		{"Enter passphrase for key", "Enter passphrase for key", true, true},
+		{"\x1b[?1049h~\n~\n", "a full-screen program such as an editor or pager", true, false},
+	} {
+		prompt, answerable, waiting := detectPrompt(tt.raw)
+		if prompt != tt.prompt || waiting != tt.waiting || answerable != tt.answerable {
+			t.Errorf("detectPrompt(%q) = %q, %v, %v; want %q, %v, %v", tt.raw, prompt, answerable, waiting, tt.prompt, tt.answerable, tt.waiting)
+		}
+	}
+
+	ctx := context.Background()
+	command := `printf 'Continue? [y/N] '; read answer; echo "got $answer"`
+	interactive := ShellInteractiveConfig{PromptTimeoutSeconds: 1}
+
+	// Without forwarding, a command waiting at a prompt is stopped
+	for _, disablePTY := range []bool{false, true} {
+		interactive.DisablePTY = disablePTY
+		tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, interactive, nil)
+		start := time.Now()
+		_, agentMsg, err := tool.Func(ctx, map[string]interface{}{"command": command})
+		if disablePTY {
+			// Without a terminal the command reads end of input and carries on
+			if err != nil || !strings.Contains(agentMsg, "got") {
+				t.Errorf("expected the command to finish without a terminal, got %q, %v", agentMsg, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "interactive command not supported") || !strings.Contains(err.Error(), "Continue? [y/N]") {
+			t.Errorf("expected an interactive command error naming the prompt, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the command to be stopped after the prompt timeout, took %v", elapsed)
+		}
+	}
+
+	// A command reading stdin without a prompt gets end of input once it goes quiet
+	for _, interactive := range []ShellInteractiveConfig{{PromptTimeoutSeconds: 1}, {PromptTimeoutSeconds: 1, DisablePTY: true, ForwardPrompts: true}} {
+		tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, interactive, func(string) (string, bool) { return "", false })
+		_, agentMsg, err := tool.Func(ctx, map[string]interface{}{"command": "cat; echo done"})
+		if err != nil || !strings.Contains(agentMsg, "done") {
+			t.Errorf("expected cat to end at end of input (pty disabled: %v), got %q, %v", interactive.DisablePTY, agentMsg, err)
+		}
+	}
+
+	// Every command is stopped after the command timeout
+	tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, ShellInteractiveConfig{TimeoutSeconds: 1}, nil)
+	start := time.Now()
+	_, _, err := tool.Func(ctx, map[string]interface{}{"command": "echo started; sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "stopped after running for 1s") || !strings.Contains(err.Error(), "started") {
+		t.Errorf("expected a timeout error with the output so far, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be stopped after the timeout, took %v", elapsed)
+	}
+
+	// With forwarding, the user's answer is sent to the command
+	interactive = ShellInteractiveConfig{PromptTimeoutSeconds: 1, ForwardPrompts: true}
+	var asked string
+	ask := func(question string) (string, bool) {
+		asked = question
+		return "yes", true
+	}
+	for _, disablePTY := range []bool{false, true} {
+		interactive.DisablePTY = disablePTY
+		tool := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), nil, ShellEnvConfig{}, ShellOutputConfig{}, nil, interactive, ask)
+		_, agentMsg, err := tool.Func(ctx, map[string]interface{}{"command": command})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(asked, "Continue? [y/N]") {
+			t.Errorf("expected the user to be asked the prompt, got %q", asked)
+		}
+		if !strings.Contains(agentMsg, "got yes") {
+			t.Errorf("expected the answer to reach the command, got %q", agentMsg)
+		}
+	}
 }
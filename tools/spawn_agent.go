@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SpawnFunc runs a sub-agent on task with access to the named tools and returns its report
+type SpawnFunc func(ctx context.Context, id int, task string, toolNames []string) (string, error)
+
+// NewSpawnAgentTool creates the spawn_agent tool
+func NewSpawnAgentTool(spawn SpawnFunc) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "spawn_agent",
+		Description: "Delegate self-contained tasks (e.g. \"find every place that writes to the cache and explain the invalidation rules\") to sub-agents. Each gets its own history and live context, works until done, and returns only a short report, so exploration doesn't fill your context. Multiple tasks run concurrently. By default sub-agents can only read code; grant more tools explicitly.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"tasks": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "One complete, self-contained instruction per sub-agent, including what the report should contain",
+					"minItems":    1,
+				},
+				"tools": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Optional: Extra tools to grant beyond the read-only defaults (e.g. shell, edit_file)",
+				},
+			},
+			"required": []interface{}{"tasks"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return spawnAgents(ctx, params, spawn)
+		},
+	}
+}
+
+func spawnAgents(ctx context.Context, params map[string]interface{}, spawn SpawnFunc) (string, string, error) {
+	var tasks []string
+	if rawTasks, ok := params["tasks"].([]interface{}); ok {
+		for _, raw := range rawTasks {
+			if task, ok := raw.(string); ok && strings.TrimSpace(task) != "" {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	if len(tasks) == 0 {
+		return "", "", fmt.Errorf("tasks must contain at least one non-empty task")
+	}
+
+	var toolNames []string
+	if rawTools, ok := params["tools"].([]interface{}); ok {
+		for _, raw := range rawTools {
+			if name, ok := raw.(string); ok {
+				toolNames = append(toolNames, name)
+			}
+		}
+	}
+
+	reports := make([]string, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := spawn(ctx, i+1, task, toolNames)
+			if err != nil {
+				report = fmt.Sprintf("Sub-agent failed: %v", err)
+			}
+			reports[i] = fmt.Sprintf("## Sub-agent %d: %s\n\n%s", i+1, task, strings.TrimSpace(report))
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%d sub-agents finished\n", len(tasks)), strings.Join(reports, "\n\n"), nil
+}
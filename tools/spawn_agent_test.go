@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpawnAgentReportsInTaskOrder(t *testing.T) {
+	var calls atomic.Int32
+	spawn := func(ctx context.Context, id int, task string, toolNames []string) (string, error) {
+		calls.Add(1)
+		assert.Equal(t, []string{"shell"}, toolNames)
+		if task == "fail" {
+			return "", fmt.Errorf("boom")
+		}
+		return fmt.Sprintf("report %d", id), nil
+	}
+
+	_, agentMsg, err := NewSpawnAgentTool(spawn).Func(context.Background(), map[string]interface{}{
+		"tasks": []interface{}{"first", "fail", "third"},
+		"tools": []interface{}{"shell"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, "## Sub-agent 1: first\n\nreport 1\n\n## Sub-agent 2: fail\n\nSub-agent failed: boom\n\n## Sub-agent 3: third\n\nreport 3", agentMsg)
+}
+
+func TestSpawnAgentRequiresTasks(t *testing.T) {
+	spawn := func(ctx context.Context, id int, task string, toolNames []string) (string, error) {
+		return "", nil
+	}
+	_, _, err := NewSpawnAgentTool(spawn).Func(context.Background(), map[string]interface{}{"tasks": []interface{}{" "}})
+	assert.Error(t, err)
+}
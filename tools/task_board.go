@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Statuses of a task board item
+const (
+	BoardPending    = "pending"
+	BoardInProgress = "in_progress"
+	BoardDone       = "done"
+	BoardSkipped    = "skipped"
+)
+
+// BoardItem is one step of the model's plan on the task board
+type BoardItem struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+// TaskBoard is the checklist the model keeps while it carries out a multi-step plan in a session.
+// Unlike tasks, which outlast sessions, the board is cleared with the conversation.
+type TaskBoard struct {
+	mu     sync.Mutex
+	items  []BoardItem
+	nextID int
+}
+
+// NewTaskBoard creates an empty task board
+func NewTaskBoard() *TaskBoard {
+	return &TaskBoard{nextID: 1}
+}
+
+// Add appends pending items with the given titles and returns them
+func (b *TaskBoard) Add(titles ...string) []BoardItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var added []BoardItem
+	for _, title := range titles {
+		item := BoardItem{ID: b.nextID, Title: title, Status: BoardPending}
+		b.nextID++
+		b.items = append(b.items, item)
+		added = append(added, item)
+	}
+	return added
+}
+
+// Update changes an item's status, title, or note; empty values leave them unchanged
+func (b *TaskBoard) Update(id int, status, title, note string) (BoardItem, error) {
+	switch status {
+	case "", BoardPending, BoardInProgress, BoardDone, BoardSkipped:
+	default:
+		return BoardItem{}, fmt.Errorf("status must be one of: %s, %s, %s, %s", BoardPending, BoardInProgress, BoardDone, BoardSkipped)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.items {
+		if b.items[i].ID != id {
+			continue
+		}
+		if status != "" {
+			b.items[i].Status = status
+		}
+		if title != "" {
+			b.items[i].Title = title
+		}
+		if note != "" {
+			b.items[i].Note = note
+		}
+		return b.items[i], nil
+	}
+	return BoardItem{}, fmt.Errorf("no task board item %d", id)
+}
+
+// Items returns a copy of the items in the order they were added. A nil TaskBoard has none.
+func (b *TaskBoard) Items() []BoardItem {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BoardItem(nil), b.items...)
+}
+
+// Clear removes every item
+func (b *TaskBoard) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = nil
+	b.nextID = 1
+}
+
+// boardMarks are the checkboxes of each status in a checklist
+var boardMarks = map[string]string{BoardPending: "[ ]", BoardInProgress: "[~]", BoardDone: "[x]", BoardSkipped: "[-]"}
+
+// Checklist renders the items as a checklist, one line each
+func (b *TaskBoard) Checklist() string {
+	var checklist strings.Builder
+	for _, item := range b.Items() {
+		checklist.WriteString(fmt.Sprintf("%s %d. %s", boardMarks[item.Status], item.ID, item.Title))
+		if item.Note != "" {
+			checklist.WriteString(" — " + item.Note)
+		}
+		checklist.WriteString("\n")
+	}
+	return checklist.String()
+}
+
+// Summary renders the board compactly for the system prompt: finished items are counted rather than
+// listed, since only what's left matters for the next step
+func (b *TaskBoard) Summary() string {
+	items := b.Items()
+	var summary strings.Builder
+	finished := 0
+	for _, item := range items {
+		if item.Status == BoardDone || item.Status == BoardSkipped {
+			finished++
+			continue
+		}
+		summary.WriteString(fmt.Sprintf("- %d [%s] %s", item.ID, item.Status, item.Title))
+		if item.Note != "" {
+			summary.WriteString(" — " + item.Note)
+		}
+		summary.WriteString("\n")
+	}
+	if finished > 0 {
+		summary.WriteString(fmt.Sprintf("(%d of %d items finished)\n", finished, len(items)))
+	}
+	return summary.String()
+}
+
+// NewTaskAddTool creates the task_add tool
+func NewTaskAddTool(board *TaskBoard) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "task_add",
+		Description: "Add steps to the task board, the checklist you keep while carrying out a plan with several steps. The user sees the board in their terminal, and its unfinished items are listed in the reference data of every request. Add the steps up front, then mark them with task_update as you go.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"titles": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "One short title per step, in the order you'll do them",
+				},
+			},
+			"required": []interface{}{"titles"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return taskAdd(board, params)
+		},
+	}
+}
+
+// NewTaskUpdateTool creates the task_update tool
+func NewTaskUpdateTool(board *TaskBoard) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "task_update",
+		Description: "Update a step on the task board: mark it in_progress when you start it and done when it's finished, or skipped if it turned out to be unnecessary. Keep only one step in progress at a time.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of the step",
+				},
+				"status": map[string]interface{}{
+					"type": "string",
+					"enum": []interface{}{BoardPending, BoardInProgress, BoardDone, BoardSkipped},
+				},
+				"title": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: New title for the step",
+				},
+				"note": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: A few words on the outcome or what's blocking it",
+				},
+			},
+			"required": []interface{}{"id"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return taskUpdate(board, params)
+		},
+	}
+}
+
+// NewTaskListTool creates the task_list tool
+func NewTaskListTool(board *TaskBoard) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "task_list",
+		Description: "List every step on the task board with its status, including finished ones.",
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			checklist := board.Checklist()
+			if checklist == "" {
+				return "", "The task board is empty", nil
+			}
+			return "", checklist, nil
+		},
+	}
+}
+
+func taskAdd(board *TaskBoard, params map[string]interface{}) (string, string, error) {
+	rawTitles, ok := params["titles"].([]interface{})
+	if !ok || len(rawTitles) == 0 {
+		return "", "", fmt.Errorf("titles must be a non-empty array of strings")
+	}
+	var titles []string
+	for _, raw := range rawTitles {
+		title, ok := raw.(string)
+		if !ok || strings.TrimSpace(title) == "" {
+			return "", "", fmt.Errorf("titles must be non-empty strings")
+		}
+		titles = append(titles, strings.TrimSpace(title))
+	}
+
+	added := board.Add(titles...)
+	return board.Checklist(), fmt.Sprintf("Added %d steps (%d-%d)", len(added), added[0].ID, added[len(added)-1].ID), nil
+}
+
+func taskUpdate(board *TaskBoard, params map[string]interface{}) (string, string, error) {
+	id, ok := params["id"].(float64)
+	if !ok {
+		return "", "", fmt.Errorf("id must be a number")
+	}
+	status, _ := params["status"].(string)
+	title, _ := params["title"].(string)
+	note, _ := params["note"].(string)
+
+	item, err := board.Update(int(id), status, title, note)
+	if err != nil {
+		return "", "", WrapToolError("task_update", err)
+	}
+	return board.Checklist(), fmt.Sprintf("Step %d is %s: %s", item.ID, item.Status, item.Title), nil
+}
@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskBoard(t *testing.T) {
+	ctx := context.Background()
+	board := NewTaskBoard()
+
+	userMsg, agentMsg, err := NewTaskAddTool(board).Func(ctx, map[string]interface{}{"titles": []interface{}{"Write the parser", "Add tests", "Update docs"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Added 3 steps (1-3)", agentMsg)
+	assert.Equal(t, "[ ] 1. Write the parser\n[ ] 2. Add tests\n[ ] 3. Update docs\n", userMsg)
+
+	_, _, err = NewTaskUpdateTool(board).Func(ctx, map[string]interface{}{"id": float64(1), "status": BoardDone})
+	assert.NoError(t, err)
+	userMsg, agentMsg, err = NewTaskUpdateTool(board).Func(ctx, map[string]interface{}{"id": float64(2), "status": BoardInProgress, "note": "table tests"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Step 2 is in_progress: Add tests", agentMsg)
+	assert.Contains(t, userMsg, "[x] 1. Write the parser\n[~] 2. Add tests — table tests\n")
+
+	_, _, err = NewTaskUpdateTool(board).Func(ctx, map[string]interface{}{"id": float64(9), "status": BoardDone})
+	assert.Error(t, err)
+	_, _, err = NewTaskUpdateTool(board).Func(ctx, map[string]interface{}{"id": float64(3), "status": "blocked"})
+	assert.Error(t, err)
+
+	// The system prompt only lists what's left
+	assert.Equal(t, "- 2 [in_progress] Add tests — table tests\n- 3 [pending] Update docs\n(1 of 3 items finished)\n", board.Summary())
+
+	_, agentMsg, err = NewTaskListTool(board).Func(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, board.Checklist(), agentMsg)
+
+	board.Clear()
+	assert.Empty(t, board.Summary())
+	assert.Equal(t, 1, board.Add("Start over")[0].ID)
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var goPackageClause = regexp.MustCompile(`^package\s+(\w+)`)
+
+// FileTemplates applies project templates from .agent/templates to files the agent creates. A
+// template is named after the extension it applies to (go.tmpl for .go files) and is rendered with
+// TemplateData. Templates that don't use {{.Content}} are treated as headers and prepended.
+type FileTemplates struct {
+	root string
+	dir  string
+}
+
+// TemplateData is the data available to file templates
+type TemplateData struct {
+	Path    string // workspace-relative path with forward slashes
+	Dir     string // workspace-relative directory
+	Name    string // file name
+	Stem    string // file name without extension
+	Package string // Go package name for .go files, otherwise the directory as a dotted path
+	Year    int
+	Content string // content written by the agent
+}
+
+// NewFileTemplates reads templates from .agent/templates under root
+func NewFileTemplates(root string) *FileTemplates {
+	return &FileTemplates{root: root, dir: filepath.Join(root, ".agent", "templates")}
+}
+
+// Apply renders the template for path's extension around content. It returns the template file that
+// was applied, or "" when there is none or content already starts with the rendered header.
+func (t *FileTemplates) Apply(path, content string) (string, string, error) {
+	if t == nil {
+		return content, "", nil
+	}
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return content, "", nil
+	}
+	templatePath := filepath.Join(t.dir, ext+".tmpl")
+	source, err := os.ReadFile(templatePath)
+	if os.IsNotExist(err) {
+		return content, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(template.FuncMap{
+		"contains":  strings.Contains,
+		"hasPrefix": strings.HasPrefix,
+	}).Parse(string(source))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid template %s: %w", templatePath, err)
+	}
+
+	// A shebang has to stay on the first line for the file to run, so templates go after it
+	shebang, body := "", content
+	if strings.HasPrefix(content, "#!") {
+		line, rest, _ := strings.Cut(content, "\n")
+		shebang, body = line+"\n", rest
+	}
+
+	data := t.templateData(path, body)
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", "", fmt.Errorf("failed to render template %s: %w", templatePath, err)
+	}
+
+	if strings.Contains(string(source), ".Content") {
+		return shebang + rendered.String(), templatePath, nil
+	}
+	header := strings.TrimSpace(rendered.String())
+	if header == "" || strings.HasPrefix(strings.TrimSpace(body), header) {
+		return content, "", nil
+	}
+	return shebang + header + "\n\n" + body, templatePath, nil
+}
+
+func (t *FileTemplates) templateData(path, content string) TemplateData {
+	rel, err := filepath.Rel(t.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	name := filepath.Base(rel)
+
+	pkg := strings.ReplaceAll(strings.Trim(dir, "./"), "/", ".")
+	if strings.HasSuffix(name, ".go") {
+		pkg = goPackageName(filepath.Dir(path), t.root)
+	}
+
+	return TemplateData{
+		Path:    rel,
+		Dir:     dir,
+		Name:    name,
+		Stem:    strings.TrimSuffix(name, filepath.Ext(name)),
+		Package: pkg,
+		Year:    time.Now().Year(),
+		Content: content,
+	}
+}
+
+// goPackageName returns the package declared by other Go files in dir, or one derived from the
+// directory name ("main" at the workspace root)
+func goPackageName(dir, root string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		if name := readGoPackage(file); name != "" {
+			return strings.TrimSuffix(name, "_test")
+		}
+	}
+
+	if filepath.Clean(dir) == filepath.Clean(root) {
+		return "main"
+	}
+	var name strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9' && name.Len() > 0) {
+			name.WriteRune(r)
+		}
+	}
+	if name.Len() == 0 {
+		return "main"
+	}
+	return name.String()
+}
+
+func readGoPackage(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := goPackageClause.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTemplate(t *testing.T, root, name, content string) {
+	dir := filepath.Join(root, ".agent", "templates")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestFileTemplatesHeader(t *testing.T) {
+	root := t.TempDir()
+	writeTemplate(t, root, "py.tmpl", "# Copyright {{.Year}} Example\n# Module {{.Package}}.{{.Stem}}\n")
+	templates := NewFileTemplates(root)
+
+	path := filepath.Join(root, "pkg", "sub", "util.py")
+	content, applied, err := templates.Apply(path, "x = 1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, ".agent", "templates", "py.tmpl"), applied)
+	header := fmt.Sprintf("# Copyright %d Example\n# Module pkg.sub.util", time.Now().Year())
+	assert.Equal(t, header+"\n\nx = 1\n", content)
+
+	// Content that already has the header is left alone
+	content, applied, err = templates.Apply(path, header+"\n\nx = 1\n")
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, header+"\n\nx = 1\n", content)
+
+	// The header goes after a shebang, which has to stay on the first line
+	content, _, err = templates.Apply(path, "#!/usr/bin/env python3\nx = 1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/usr/bin/env python3\n"+header+"\n\nx = 1\n", content)
+	content, applied, err = templates.Apply(path, "#!/usr/bin/env python3\n"+header+"\n\nx = 1\n")
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, "#!/usr/bin/env python3\n"+header+"\n\nx = 1\n", content)
+
+	writeTemplate(t, root, "sh.tmpl", "# {{.Name}}\nset -eu\n{{.Content}}")
+	content, _, err = templates.Apply(filepath.Join(root, "run.sh"), "#!/bin/sh\necho hi\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n# run.sh\nset -eu\necho hi\n", content)
+
+	// Extensions without a template are untouched
+	content, applied, err = templates.Apply(filepath.Join(root, "main.js"), "let x")
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, "let x", content)
+}
+
+func TestFileTemplatesGoPackage(t *testing.T) {
+	root := t.TempDir()
+	writeTemplate(t, root, "go.tmpl", "// Licensed under MIT\n{{if not (contains .Content \"package \")}}\npackage {{.Package}}\n{{end}}\n{{.Content}}")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "server"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "server", "http.go"), []byte("// doc\npackage httpserver\n"), 0644))
+	templates := NewFileTemplates(root)
+
+	content, _, err := templates.Apply(filepath.Join(root, "server", "routes.go"), "func routes() {}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "// Licensed under MIT\n\npackage httpserver\n\nfunc routes() {}\n", content)
+
+	content, _, err = templates.Apply(filepath.Join(root, "My-Store", "db.go"), "package store\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "// Licensed under MIT\n\npackage store\n", content)
+
+	assert.Equal(t, "mystore", goPackageName(filepath.Join(root, "My-Store"), root))
+	assert.Equal(t, "main", goPackageName(root, root))
+}
+
+func TestCreateFileAppliesTemplateToNewFilesOnly(t *testing.T) {
+	root := t.TempDir()
+	writeTemplate(t, root, "txt.tmpl", "HEADER")
+	templates := NewFileTemplates(root)
+	path := filepath.Join(root, "notes.txt")
+
+	_, agentMsg, err := createFile(context.Background(), map[string]interface{}{"path": path, "content": "body"}, nil, templates, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "txt.tmpl")
+	written, _ := os.ReadFile(path)
+	assert.Equal(t, "HEADER\n\nbody", string(written))
+
+	_, agentMsg, err = createFile(context.Background(), map[string]interface{}{"path": path, "content": "replaced"}, nil, templates, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Updated", agentMsg)
+	written, _ = os.ReadFile(path)
+	assert.Equal(t, "replaced", string(written))
+}
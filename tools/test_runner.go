@@ -0,0 +1,586 @@
+package tools
+
+import (
+	"agent/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxFailures caps the failures listed in a run_tests result
+const defaultMaxFailures = 20
+
+// TestFailure is one failing test, or one compile error that kept tests from running, located where
+// the output reported it
+type TestFailure struct {
+	Test    string
+	File    string
+	Line    int
+	Message string
+}
+
+// TestReport is the parsed outcome of a test run
+type TestReport struct {
+	Framework   string
+	Command     string
+	Passed      int
+	Failed      int
+	Skipped     int
+	Failures    []TestFailure
+	BuildErrors []TestFailure
+	ExitCode    int
+	Duration    time.Duration
+	Output      string // the raw output, shown when a failed run couldn't be parsed
+}
+
+// testFramework knows how to run one kind of test suite and read its output
+type testFramework struct {
+	markers []string // files in the working directory that mark a project using the framework
+	command func(path, filter string) []string
+	parse   func(output string, report *TestReport)
+}
+
+// testFrameworks are checked in order when the framework isn't given
+var testFrameworks = map[string]testFramework{
+	"go":     {markers: []string{"go.mod"}, command: goTestCommand, parse: parseGoTest},
+	"cargo":  {markers: []string{"Cargo.toml"}, command: cargoTestCommand, parse: parseCargoTest},
+	"npm":    {markers: []string{"package.json"}, command: npmTestCommand, parse: parseJSTest},
+	"pytest": {markers: []string{"pytest.ini", "pyproject.toml", "setup.cfg", "tox.ini", "conftest.py", "setup.py"}, command: pytestCommand, parse: parsePytest},
+}
+
+var testFrameworkOrder = []string{"go", "cargo", "npm", "pytest"}
+
+// detectTestFramework picks the framework from the project files in dir
+func detectTestFramework(dir string) (string, error) {
+	for _, name := range testFrameworkOrder {
+		for _, marker := range testFrameworks[name].markers {
+			if fileExists(filepath.Join(dir, marker)) {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("couldn't detect the test framework from go.mod, Cargo.toml, package.json, or pytest config; pass framework or run the tests with shell")
+}
+
+// NewRunTestsTool creates the run_tests tool. Tests run in the configured sandbox and workDir with
+// the environment env allows, like shell commands.
+func NewRunTestsTool(sandbox SandboxConfig, workDir *WorkingDirectory, env ShellEnvConfig) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "run_tests",
+		Description: "Run the project's tests (go test, cargo test, npm test, or pytest, detected from the project files) and return a compact summary: pass/fail counts and each failure with its file, line, and message, instead of the full test output. The user sees the summary in their terminal. Use this instead of running tests with shell.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Package, directory, or file to test, relative to the working directory (e.g. ./tools/..., tests/test_api.py); defaults to the whole project",
+				},
+				"filter": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: Only run tests whose names match (go test -run, pytest -k, cargo test <filter>, jest/vitest -t)",
+				},
+				"framework": map[string]interface{}{
+					"type":        "string",
+					"enum":        []interface{}{"go", "cargo", "npm", "pytest"},
+					"description": "Optional: Test framework, when it can't be detected",
+				},
+				"max_failures": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Optional: Maximum failures to list (default: %d)", defaultMaxFailures),
+					"minimum":     1,
+				},
+			},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			return runTests(ctx, sandbox, workDir.Root(), workDir.Dir(), env, params)
+		},
+	}
+}
+
+func runTests(ctx context.Context, sandbox SandboxConfig, root, cwd string, env ShellEnvConfig, params map[string]interface{}) (string, string, error) {
+	path, _ := params["path"].(string)
+	filter, _ := params["filter"].(string)
+	name, _ := params["framework"].(string)
+	maxFailures := defaultMaxFailures
+	if value, ok := params["max_failures"].(float64); ok && value > 0 {
+		maxFailures = int(value)
+	}
+
+	if name == "" {
+		detected, err := detectTestFramework(cwd)
+		if err != nil {
+			return "", "", WrapToolError("run_tests", err)
+		}
+		name = detected
+	}
+	framework, ok := testFrameworks[name]
+	if !ok {
+		return "", "", WrapToolError("run_tests", fmt.Errorf("unknown framework %q (use go, cargo, npm, or pytest)", name))
+	}
+
+	args := framework.command(path, filter)
+	if !sandbox.Enabled() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return "", "", WrapToolError("run_tests", fmt.Errorf("%s isn't installed", args[0]))
+		}
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	command := strings.Join(quoted, " ")
+	cmd, err := sandbox.Command(ctx, command, root, cwd)
+	if err != nil {
+		return "", "", WrapToolError("run_tests", err)
+	}
+	cmd.Env = env.Environ()
+
+	ReportProgress(ctx, "running %s", command)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	report := &TestReport{Framework: name, Command: command, Duration: time.Since(start), Output: string(output)}
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return "", "", WrapToolError("run_tests", fmt.Errorf("failed to run `%s`: %w", command, err))
+		}
+		report.ExitCode = exitErr.ExitCode()
+	}
+	framework.parse(report.Output, report)
+
+	summary := report.Summary(maxFailures)
+	return summary, summary, nil
+}
+
+// Summary renders the report compactly, listing at most maxFailures failures
+func (r *TestReport) Summary(maxFailures int) string {
+	var summary strings.Builder
+	status := "passed"
+	if r.ExitCode != 0 {
+		status = fmt.Sprintf("FAILED (exit code %d)", r.ExitCode)
+	}
+	summary.WriteString(fmt.Sprintf("%s: %s — %d passed, %d failed, %d skipped in %s\n", r.Command, status, r.Passed, r.Failed, r.Skipped, r.Duration.Round(10*time.Millisecond)))
+
+	writeList := func(title string, failures []TestFailure) {
+		if len(failures) == 0 {
+			return
+		}
+		summary.WriteString(title + ":\n")
+		for i, failure := range failures {
+			if i == maxFailures {
+				summary.WriteString(fmt.Sprintf("... and %d more\n", len(failures)-maxFailures))
+				break
+			}
+			summary.WriteString(fmt.Sprintf("%d. %s\n", i+1, failure))
+		}
+	}
+	writeList("Build errors", r.BuildErrors)
+	writeList("Failures", r.Failures)
+
+	if r.ExitCode != 0 && len(r.Failures) == 0 && len(r.BuildErrors) == 0 {
+		summary.WriteString("No failures could be parsed; the end of the output was:\n")
+		summary.WriteString(lastLines(r.Output, 40))
+	}
+	return strings.TrimRight(summary.String(), "\n")
+}
+
+func (f TestFailure) String() string {
+	var text strings.Builder
+	text.WriteString(f.Test)
+	if f.File != "" {
+		if f.Test != "" {
+			text.WriteString(" at ")
+		}
+		text.WriteString(f.File)
+		if f.Line > 0 {
+			text.WriteString(":" + strconv.Itoa(f.Line))
+		}
+	}
+	if f.Message != "" {
+		text.WriteString(": " + f.Message)
+	}
+	return text.String()
+}
+
+// lastLines returns the last n lines of output
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shellQuote quotes an argument for sh
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// joinMessage joins the lines of a failure message into one, capped so one failure can't flood the
+// summary
+func joinMessage(lines []string) string {
+	message := strings.Join(lines, " ")
+	if len(message) > 300 {
+		message = message[:300] + "..."
+	}
+	return message
+}
+
+func goTestCommand(path, filter string) []string {
+	args := []string{"go", "test", "-json"}
+	if filter != "" {
+		args = append(args, "-run", filter)
+	}
+	if path == "" {
+		path = "./..."
+	}
+	return append(args, path)
+}
+
+// goLocation matches the file:line prefix of t.Error output and compile errors
+var goLocation = regexp.MustCompile(`^\s*(?:\./)?([^\s:]+\.go):(\d+)(?::\d+)?: (.*)$`)
+
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// parseGoTest reads go test -json events. Compile errors arrive as plain lines (or build-output
+// events in newer Go versions) between the events.
+func parseGoTest(output string, report *TestReport) {
+	testOutput := make(map[string][]string)
+	var failedTests []string // keyed like testOutput, "<package> <test>"
+	failures := make(map[string]TestFailure)
+	var failedPackages []string
+
+	addBuildError := func(line string) {
+		if match := goLocation.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[2])
+			report.BuildErrors = append(report.BuildErrors, TestFailure{File: match[1], Line: lineNumber, Message: strings.TrimSpace(match[3])})
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Action == "" {
+			addBuildError(scanner.Text())
+			continue
+		}
+		key := event.Package + " " + event.Test
+		switch event.Action {
+		case "output":
+			testOutput[key] = append(testOutput[key], strings.TrimRight(event.Output, "\n"))
+		case "build-output":
+			addBuildError(strings.TrimRight(event.Output, "\n"))
+		case "pass":
+			if event.Test != "" {
+				report.Passed++
+			}
+		case "skip":
+			if event.Test != "" {
+				report.Skipped++
+			}
+		case "fail":
+			if event.Test == "" {
+				failedPackages = append(failedPackages, event.Package)
+				continue
+			}
+			report.Failed++
+			failedTests = append(failedTests, key)
+			failures[key] = goFailure(event, testOutput[key])
+		}
+	}
+
+	// A test whose subtests failed is listed only through them
+	for _, key := range failedTests {
+		hasFailedSubtest := slices.ContainsFunc(failedTests, func(other string) bool { return strings.HasPrefix(other, key+"/") })
+		if !hasFailedSubtest {
+			report.Failures = append(report.Failures, failures[key])
+		}
+	}
+
+	// Packages can fail without a failing test, e.g. when TestMain panics or the build fails
+	for _, pkg := range failedPackages {
+		explained := len(report.BuildErrors) > 0 || slices.ContainsFunc(failedTests, func(key string) bool { return strings.HasPrefix(key, pkg+" ") })
+		if !explained {
+			report.Failures = append(report.Failures, TestFailure{Test: pkg, Message: joinMessage(lastMeaningfulLines(testOutput[pkg+" "], 3))})
+		}
+	}
+}
+
+// goFailure locates a failed test from its output: the first file:line message, or else the first
+// line that isn't test framing
+func goFailure(event goTestEvent, output []string) TestFailure {
+	failure := TestFailure{Test: event.Package + " " + event.Test}
+	for i, line := range output {
+		if match := goLocation.FindStringSubmatch(line); match != nil {
+			failure.File = match[1]
+			failure.Line, _ = strconv.Atoi(match[2])
+			message := []string{strings.TrimSpace(match[3])}
+			// Continuation lines of the message are indented further
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			for _, next := range output[i+1:] {
+				if strings.TrimSpace(next) == "" || len(next)-len(strings.TrimLeft(next, " \t")) <= indent || len(message) == 5 {
+					break
+				}
+				message = append(message, strings.TrimSpace(next))
+			}
+			failure.Message = joinMessage(message)
+			break
+		}
+	}
+	if failure.Message == "" {
+		failure.Message = joinMessage(lastMeaningfulLines(output, 3))
+	}
+	// The test name alone is clearer than the package path, which the file already implies
+	if failure.File != "" {
+		failure.Test = event.Test
+	}
+	return failure
+}
+
+// lastMeaningfulLines returns up to n trailing lines of test output, skipping framing like
+// "=== RUN" and "--- FAIL"
+func lastMeaningfulLines(output []string, n int) []string {
+	var lines []string
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "FAIL" || strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") || strings.HasPrefix(trimmed, "FAIL\t") || strings.HasPrefix(trimmed, "ok  \t") {
+			continue
+		}
+		lines = append(lines, trimmed)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func pytestCommand(path, filter string) []string {
+	args := []string{"pytest"}
+	if _, err := exec.LookPath("pytest"); err != nil {
+		args = []string{"python3", "-m", "pytest"}
+	}
+	args = append(args, "-q", "--tb=short", "-p", "no:cacheprovider")
+	if filter != "" {
+		args = append(args, "-k", filter)
+	}
+	if path != "" {
+		args = append(args, path)
+	}
+	return args
+}
+
+var (
+	pytestHeader   = regexp.MustCompile(`^_{3,} (.+?) _{3,}$`)
+	pytestLocation = regexp.MustCompile(`^(\S+\.py):(\d+): `)
+	pytestCounts   = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?)\b`)
+)
+
+// parsePytest reads the --tb=short failure sections: each failure is headed by its name between
+// underscores, its last file:line frame is where it failed, and "E" lines carry the message
+func parsePytest(output string, report *TestReport) {
+	var current *TestFailure
+	var message []string
+	finish := func() {
+		if current != nil {
+			current.Message = joinMessage(message)
+			report.Failures = append(report.Failures, *current)
+		}
+		current, message = nil, nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if match := pytestHeader.FindStringSubmatch(line); match != nil {
+			finish()
+			current = &TestFailure{Test: match[1]}
+			continue
+		}
+		if strings.HasPrefix(line, "===") {
+			finish()
+		}
+		if current != nil {
+			if match := pytestLocation.FindStringSubmatch(line); match != nil {
+				current.File = match[1]
+				current.Line, _ = strconv.Atoi(match[2])
+			} else if strings.HasPrefix(line, "E ") && len(message) < 5 {
+				message = append(message, strings.TrimSpace(line[1:]))
+			}
+		}
+	}
+	finish()
+	countTests(output, pytestCounts, report)
+}
+
+// countTests adds up the counts in summary lines like "3 passed, 1 failed"; the last summary wins
+func countTests(output string, pattern *regexp.Regexp, report *TestReport) {
+	for _, line := range strings.Split(output, "\n") {
+		matches := pattern.FindAllStringSubmatch(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		report.Passed, report.Failed, report.Skipped = 0, 0, 0
+		for _, match := range matches {
+			count, _ := strconv.Atoi(match[1])
+			switch match[2] {
+			case "passed":
+				report.Passed += count
+			case "failed", "error", "errors":
+				report.Failed += count
+			case "skipped", "todo":
+				report.Skipped += count
+			}
+		}
+	}
+}
+
+func cargoTestCommand(path, filter string) []string {
+	args := []string{"cargo", "test"}
+	if path != "" {
+		args = append(args, "--package", path)
+	}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	return args
+}
+
+var (
+	cargoResult   = regexp.MustCompile(`^test result: .*`)
+	cargoCounts   = regexp.MustCompile(`(\d+) (passed|failed|ignored)\b`)
+	cargoBlock    = regexp.MustCompile(`^---- (\S+) stdout ----$`)
+	cargoPanic    = regexp.MustCompile(`panicked at (?:'(.*)', )?([^\s:]+\.rs):(\d+):\d+:?$`)
+	cargoError    = regexp.MustCompile(`^error(?:\[\w+\])?: (.*)$`)
+	cargoErrorPos = regexp.MustCompile(`^\s*--> ([^\s:]+\.rs):(\d+):\d+$`)
+)
+
+// parseCargoTest reads the "---- test stdout ----" sections of failing tests and the compile errors
+// that stop a test build
+func parseCargoTest(output string, report *TestReport) {
+	lines := strings.Split(output, "\n")
+	var results []string
+	for i, line := range lines {
+		if cargoResult.MatchString(line) {
+			results = append(results, line)
+		}
+		if match := cargoError.FindStringSubmatch(line); match != nil && i+1 < len(lines) {
+			if pos := cargoErrorPos.FindStringSubmatch(lines[i+1]); pos != nil {
+				lineNumber, _ := strconv.Atoi(pos[2])
+				report.BuildErrors = append(report.BuildErrors, TestFailure{File: pos[1], Line: lineNumber, Message: match[1]})
+			}
+		}
+		match := cargoBlock.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		failure := TestFailure{Test: match[1]}
+		for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "---- ") && strings.TrimSpace(lines[j]) != ""; j++ {
+			if panic := cargoPanic.FindStringSubmatch(lines[j]); panic != nil {
+				failure.File = panic[2]
+				failure.Line, _ = strconv.Atoi(panic[3])
+				failure.Message = panic[1]
+				// Newer versions print the message on the line after the location
+				if failure.Message == "" && j+1 < len(lines) {
+					failure.Message = strings.TrimSpace(lines[j+1])
+				}
+				break
+			}
+		}
+		report.Failures = append(report.Failures, failure)
+	}
+
+	// Each test binary prints its own result line
+	for _, result := range results {
+		for _, match := range cargoCounts.FindAllStringSubmatch(result, -1) {
+			count, _ := strconv.Atoi(match[1])
+			switch match[2] {
+			case "passed":
+				report.Passed += count
+			case "failed":
+				report.Failed += count
+			case "ignored":
+				report.Skipped += count
+			}
+		}
+	}
+}
+
+func npmTestCommand(path, filter string) []string {
+	args := []string{"npm", "test", "--silent", "--"}
+	if path != "" {
+		args = append(args, path)
+	}
+	if filter != "" {
+		args = append(args, "-t", filter)
+	}
+	return args
+}
+
+var (
+	jestHeader   = regexp.MustCompile(`^\s*● (.+)$`)
+	vitestHeader = regexp.MustCompile(`^\s*(?:FAIL|×)\s+(\S+ > .+?)(?:\s+\d+ms)?$`)
+	jsLocation   = regexp.MustCompile(`\(?((?:[^\s()]+/)?[^\s()/]+\.[cm]?[jt]sx?):(\d+):\d+\)?`)
+	jsCounts     = regexp.MustCompile(`(\d+) (passed|failed|skipped|todo)\b`)
+)
+
+// parseJSTest reads jest and vitest output: failures are headed by "●" (jest) or "FAIL file > test"
+// (vitest), followed by the message and a stack with the test file's location
+func parseJSTest(output string, report *TestReport) {
+	lines := strings.Split(output, "\n")
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		var name string
+		if match := jestHeader.FindStringSubmatch(line); match != nil {
+			name = match[1]
+		} else if match := vitestHeader.FindStringSubmatch(line); match != nil {
+			name = match[1]
+		} else {
+			continue
+		}
+		// Vitest names a failure in its list and again above its details
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		failure := TestFailure{Test: name}
+		var message []string
+		for j := i + 1; j < len(lines) && j < i+40; j++ {
+			next := strings.TrimSpace(lines[j])
+			if jestHeader.MatchString(lines[j]) || vitestHeader.MatchString(lines[j]) {
+				break
+			}
+			if match := jsLocation.FindStringSubmatch(next); match != nil && !strings.Contains(next, "node_modules") {
+				failure.File = match[1]
+				failure.Line, _ = strconv.Atoi(match[2])
+				break
+			}
+			if next != "" && len(message) < 3 {
+				message = append(message, next)
+			}
+		}
+		failure.Message = joinMessage(message)
+		report.Failures = append(report.Failures, failure)
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "Tests") {
+			countTests(line, jsCounts, report)
+		}
+	}
+}
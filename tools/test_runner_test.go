@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGoTest(t *testing.T) {
+	output := strings.Join([]string{
+		`{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}`,
+		`{"Action":"pass","Package":"example.com/calc","Test":"TestAdd"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:31: Div(1, 0) = 0, want error\n"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"        got: 0\n"}`,
+		`{"Action":"fail","Package":"example.com/calc","Test":"TestDiv/by_zero"}`,
+		`{"Action":"fail","Package":"example.com/calc","Test":"TestDiv"}`,
+		`{"Action":"skip","Package":"example.com/calc","Test":"TestSlow"}`,
+		`{"Action":"fail","Package":"example.com/calc"}`,
+		`{"Action":"output","Package":"example.com/boot","Output":"panic: missing config\n"}`,
+		`{"Action":"fail","Package":"example.com/boot"}`,
+	}, "\n")
+	report := &TestReport{}
+	parseGoTest(output, report)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []TestFailure{
+		{Test: "TestDiv/by_zero", File: "calc_test.go", Line: 31, Message: "Div(1, 0) = 0, want error got: 0"},
+		{Test: "example.com/boot", Message: "panic: missing config"},
+	}, report.Failures)
+
+	// Compile errors are reported instead of the packages they broke
+	report = &TestReport{}
+	parseGoTest("# example.com/calc\n./calc.go:12:2: undefined: sum\n"+`{"Action":"fail","Package":"example.com/calc"}`, report)
+	assert.Equal(t, []TestFailure{{File: "calc.go", Line: 12, Message: "undefined: sum"}}, report.BuildErrors)
+	assert.Empty(t, report.Failures)
+}
+
+func TestParsePytest(t *testing.T) {
+	output := `..F.s
+=================================== FAILURES ===================================
+__________________________________ test_total __________________________________
+tests/test_cart.py:14: in test_total
+    assert cart.total() == 30
+E   assert 25 == 30
+E    +  where 25 = total()
+=========================== short test summary info ============================
+FAILED tests/test_cart.py::test_total - assert 25 == 30
+1 failed, 3 passed, 1 skipped in 0.12s
+`
+	report := &TestReport{}
+	parsePytest(output, report)
+	assert.Equal(t, []TestFailure{{Test: "test_total", File: "tests/test_cart.py", Line: 14, Message: "assert 25 == 30 +  where 25 = total()"}}, report.Failures)
+	assert.Equal(t, [3]int{3, 1, 1}, [3]int{report.Passed, report.Failed, report.Skipped})
+}
+
+func TestParseCargoTest(t *testing.T) {
+	output := `running 3 tests
+test tests::adds ... ok
+test tests::divides ... FAILED
+
+failures:
+
+---- tests::divides stdout ----
+thread 'tests::divides' panicked at src/lib.rs:20:9:
+assertion failed: divide(4, 2) == 3
+
+failures:
+    tests::divides
+
+test result: FAILED. 1 passed; 1 failed; 1 ignored; 0 measured; 0 filtered out
+`
+	report := &TestReport{}
+	parseCargoTest(output, report)
+	assert.Equal(t, []TestFailure{{Test: "tests::divides", File: "src/lib.rs", Line: 20, Message: "assertion failed: divide(4, 2) == 3"}}, report.Failures)
+	assert.Equal(t, [3]int{1, 1, 1}, [3]int{report.Passed, report.Failed, report.Skipped})
+}
+
+func TestParseJestAndSummary(t *testing.T) {
+	output := `FAIL src/sum.test.js
+  ● sum › adds negatives
+
+    expect(received).toBe(expected) // Object.is equality
+
+      at Object.<anonymous> (src/sum.test.js:9:22)
+
+Tests:       1 failed, 4 passed, 5 total
+`
+	report := &TestReport{Command: "npm test --silent --", ExitCode: 1}
+	parseJSTest(output, report)
+	assert.Equal(t, []TestFailure{{Test: "sum › adds negatives", File: "src/sum.test.js", Line: 9, Message: "expect(received).toBe(expected) // Object.is equality"}}, report.Failures)
+
+	summary := report.Summary(20)
+	assert.True(t, strings.HasPrefix(summary, "npm test --silent --: FAILED (exit code 1) — 4 passed, 1 failed, 0 skipped"))
+	assert.Contains(t, summary, "Failures:\n1. sum › adds negatives at src/sum.test.js:9: expect(received)")
+
+	// Without parsable failures, the end of the output is shown instead
+	report = &TestReport{Command: "npm test", ExitCode: 2, Output: "lots of output\nError: cannot find module"}
+	assert.Contains(t, report.Summary(20), "No failures could be parsed; the end of the output was:\nlots of output\nError: cannot find module")
+}
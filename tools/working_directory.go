@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"agent/models"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WorkingDirectory is the directory the model works in. Shell commands run there and relative paths
+// in tool calls resolve against it. It starts at the workspace root, where the agent was launched,
+// which stays the process's directory so live context and git paths keep their meaning.
+type WorkingDirectory struct {
+	mu   sync.RWMutex
+	root string
+	dir  string
+}
+
+// NewWorkingDirectory creates a working directory at root
+func NewWorkingDirectory(root string) *WorkingDirectory {
+	return &WorkingDirectory{root: root, dir: root}
+}
+
+// Dir returns the absolute working directory. A nil WorkingDirectory is the process's directory.
+func (w *WorkingDirectory) Dir() string {
+	if w == nil {
+		dir, _ := os.Getwd()
+		return dir
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.dir
+}
+
+// Root returns the workspace root. A nil WorkingDirectory is rooted at the process's directory.
+func (w *WorkingDirectory) Root() string {
+	if w == nil {
+		dir, _ := os.Getwd()
+		return dir
+	}
+	return w.root
+}
+
+// Relative returns the working directory relative to the workspace root, or "" at the root. Paths
+// outside the workspace are absolute.
+func (w *WorkingDirectory) Relative() string {
+	if w == nil {
+		return ""
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.relative(w.dir)
+}
+
+func (w *WorkingDirectory) relative(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	if rel == "." {
+		return ""
+	}
+	return rel
+}
+
+// Set changes the working directory to path, relative to the current one, and returns it as
+// Relative does. Empty path returns to the workspace root.
+func (w *WorkingDirectory) Set(path string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dir := w.root
+	if path != "" {
+		dir = w.join(path)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+	w.dir = dir
+	return w.relative(dir), nil
+}
+
+// Reset returns to the workspace root
+func (w *WorkingDirectory) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dir = w.root
+}
+
+// Resolve rewrites a relative path against the working directory. The result is relative to the
+// workspace root when it's inside it, so it names the file the same way as paths the model gave
+// from the root. Absolute paths, and every path while at the root, are returned unchanged.
+func (w *WorkingDirectory) Resolve(path string) string {
+	if w == nil || path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.dir == w.root {
+		return path
+	}
+	resolved := w.relative(w.join(path))
+	if resolved == "" {
+		return "."
+	}
+	return resolved
+}
+
+func (w *WorkingDirectory) join(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(w.dir, path)
+}
+
+// NewSetWorkingDirectoryTool creates the set_working_directory tool
+func NewSetWorkingDirectoryTool(workDir *WorkingDirectory) models.ToolDefinition {
+	return models.ToolDefinition{
+		Name:        "set_working_directory",
+		Description: "Change the directory you work in, like cd: shell commands and tests then run there, and relative paths in every tool call resolve against it. It persists across calls until changed again. Use it when working in one part of a repository (e.g. a subproject with its own build) instead of prefixing every command with cd. File headers in apply_patch stay relative to the workspace root.",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory to work in, relative to the current working directory or absolute; empty to return to the workspace root",
+				},
+			},
+			"required": []interface{}{"path"},
+		},
+		Func: func(ctx context.Context, params map[string]interface{}) (string, string, error) {
+			path, _ := params["path"].(string)
+			dir, err := workDir.Set(path)
+			if err != nil {
+				return "", "", WrapToolError("set_working_directory", err)
+			}
+			if dir == "" {
+				return "", "Working directory is the workspace root", nil
+			}
+			return "", "Working directory is now " + dir, nil
+		},
+	}
+}
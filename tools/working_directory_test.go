@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkingDirectory(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "api", "handlers"), 0755))
+	workDir := NewWorkingDirectory(root)
+
+	// At the root, paths are left as the model gave them
+	assert.Equal(t, "main.go", workDir.Resolve("main.go"))
+
+	dir, err := workDir.Set("api")
+	assert.NoError(t, err)
+	assert.Equal(t, "api", dir)
+	dir, err = workDir.Set("handlers")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("api", "handlers"), dir)
+	assert.Equal(t, filepath.Join(root, "api", "handlers"), workDir.Dir())
+
+	assert.Equal(t, filepath.Join("api", "handlers", "user.go"), workDir.Resolve("user.go"))
+	assert.Equal(t, filepath.Join("api", "routes.go"), workDir.Resolve("../routes.go"))
+	assert.Equal(t, "/etc/hosts", workDir.Resolve("/etc/hosts"))
+	assert.Equal(t, filepath.Dir(root), workDir.Resolve("../../.."))
+
+	_, err = workDir.Set("missing")
+	assert.Error(t, err)
+	assert.Equal(t, filepath.Join("api", "handlers"), workDir.Relative())
+
+	// Shell commands run in the working directory unless the call names another
+	shell := NewShellTool(SandboxConfig{}, nil, NewShellHistory(1), workDir, ShellEnvConfig{}, ShellOutputConfig{}, nil, ShellInteractiveConfig{}, nil)
+	_, agentMsg, err := shell.Func(context.Background(), map[string]interface{}{"command": "pwd"})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Output: "+filepath.Join(root, "api", "handlers"))
+	_, agentMsg, err = shell.Func(context.Background(), map[string]interface{}{"command": "pwd", "cwd": ".."})
+	assert.NoError(t, err)
+	assert.Contains(t, agentMsg, "Output: "+filepath.Join(root, "api"))
+
+	dir, err = workDir.Set("")
+	assert.NoError(t, err)
+	assert.Empty(t, dir)
+	assert.Equal(t, root, workDir.Dir())
+}
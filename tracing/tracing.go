@@ -0,0 +1,290 @@
+// Package tracing sends spans to an OpenTelemetry collector over OTLP/HTTP with JSON encoding, so
+// latency can be broken down in a tracing backend without pulling in the OpenTelemetry SDK.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config says where spans are sent. The standard OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME
+// environment variables fill in whatever is left empty.
+type Config struct {
+	Endpoint    string            `json:"endpoint"` // collector base URL, e.g. http://localhost:4318; spans go to <endpoint>/v1/traces
+	Headers     map[string]string `json:"headers"`  // sent with every export, e.g. an API key for a hosted backend
+	ServiceName string            `json:"service_name"`
+}
+
+// Span kinds and status codes from the OTLP specification
+const (
+	KindInternal = 1
+	KindClient   = 3
+
+	statusOK    = 1
+	statusError = 2
+)
+
+// Tracer collects the spans of each trace and exports them when its root span ends. A nil Tracer
+// records nothing, so callers don't need to check whether tracing is configured.
+type Tracer struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending map[string][]*Span // finished spans by trace ID, waiting for their root span
+	exports sync.WaitGroup
+	onError func(error)
+}
+
+// Span is one timed operation. Its methods may be called on a nil Span.
+type Span struct {
+	tracer     *Tracer
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+}
+
+type spanKey struct{}
+
+// New returns a Tracer for config, or nil when no endpoint is configured. onError is told about
+// exports that fail, which never interrupt the agent.
+func New(config Config, onError func(error)) *Tracer {
+	url := tracesURL(config.Endpoint)
+	if url == "" {
+		return nil
+	}
+	headers := parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for key, value := range config.Headers {
+		headers[key] = value
+	}
+	service := config.ServiceName
+	if service == "" {
+		service = os.Getenv("OTEL_SERVICE_NAME")
+	}
+	if service == "" {
+		service = "agent"
+	}
+	return &Tracer{
+		url:     url,
+		headers: headers,
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		pending: make(map[string][]*Span),
+		onError: onError,
+	}
+}
+
+// tracesURL resolves the URL spans are posted to from the configured endpoint or the environment
+func tracesURL(endpoint string) string {
+	if endpoint == "" {
+		if url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); url != "" {
+			return url
+		}
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return ""
+	}
+	return strings.TrimRight(endpoint, "/") + "/v1/traces"
+}
+
+// parseHeaders reads the key=value,key=value format of OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+// Start begins a span as a child of the span in ctx, or as the root of a new trace, and returns a
+// context holding it
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	span := &Span{tracer: t, spanID: randomID(8), name: name, kind: kind, start: time.Now(), attributes: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute records a string, bool, integer, or float value on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.err = err
+}
+
+// End finishes the span. Ending a root span exports its whole trace in the background.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	t := s.tracer
+	t.mu.Lock()
+	s.end = time.Now()
+	t.pending[s.traceID] = append(t.pending[s.traceID], s)
+	if s.parentID != "" {
+		t.mu.Unlock()
+		return
+	}
+	spans := t.pending[s.traceID]
+	delete(t.pending, s.traceID)
+	payload, err := json.Marshal(t.request(spans))
+	t.mu.Unlock()
+	if err != nil {
+		t.report(err)
+		return
+	}
+
+	t.exports.Add(1)
+	go func() {
+		defer t.exports.Done()
+		t.report(t.export(payload))
+	}()
+}
+
+// Close waits for exports in flight, up to timeout
+func (t *Tracer) Close(timeout time.Duration) {
+	if t == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		t.exports.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func (t *Tracer) report(err error) {
+	if err != nil && t.onError != nil {
+		t.onError(err)
+	}
+}
+
+func (t *Tracer) export(payload []byte) error {
+	request, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+	response, err := t.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 500))
+		return fmt.Errorf("exporting spans: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// request builds an OTLP ExportTraceServiceRequest in its JSON encoding. The caller holds t.mu.
+func (t *Tracer) request(spans []*Span) map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		status := map[string]interface{}{"code": statusOK}
+		if span.err != nil {
+			status = map[string]interface{}{"code": statusError, "message": span.err.Error()}
+		}
+		item := map[string]interface{}{
+			"traceId":           span.traceID,
+			"spanId":            span.spanID,
+			"name":              span.name,
+			"kind":              span.kind,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        attributes(span.attributes),
+			"status":            status,
+		}
+		if span.parentID != "" {
+			item["parentSpanId"] = span.parentID
+		}
+		encoded = append(encoded, item)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]interface{}{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "agent"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// attributes encodes attribute values as OTLP AnyValues
+func attributes(values map[string]interface{}) []interface{} {
+	encoded := make([]interface{}, 0, len(values))
+	for key, value := range values {
+		var any map[string]interface{}
+		switch value := value.(type) {
+		case bool:
+			any = map[string]interface{}{"boolValue": value}
+		case int:
+			any = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			any = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			any = map[string]interface{}{"doubleValue": value}
+		default:
+			any = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": any})
+	}
+	return encoded
+}
+
+func randomID(bytes int) string {
+	id := make([]byte, bytes)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func TestTracerExportsTraceWhenRootEnds(t *testing.T) {
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer server.Close()
+
+	tracer := New(Config{Endpoint: server.URL + "/", Headers: map[string]string{"X-Api-Key": "secret"}}, func(err error) { t.Error(err) })
+	require.NotNil(t, tracer)
+
+	ctx, turn := tracer.Start(context.Background(), "turn", KindInternal)
+	_, invoke := tracer.Start(ctx, "api.invoke", KindClient)
+	invoke.SetAttribute("gen_ai.request.model", "gpt-4o")
+	invoke.SetAttribute("agent.iteration", 1)
+	invoke.SetError(errors.New("rate limited"))
+	invoke.End()
+	turn.End()
+	tracer.Close(5 * time.Second)
+
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []exportedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(<-received, &request))
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	child, root := spans[0], spans[1]
+	assert.Equal(t, "api.invoke", child.Name)
+	assert.Equal(t, KindClient, child.Kind)
+	assert.Equal(t, root.TraceID, child.TraceID)
+	assert.Equal(t, root.SpanID, child.ParentSpanID)
+	assert.Len(t, root.TraceID, 32)
+	assert.Empty(t, root.ParentSpanID)
+	assert.Equal(t, statusError, child.Status.Code)
+	assert.Equal(t, "rate limited", child.Status.Message)
+	assert.Equal(t, statusOK, root.Status.Code)
+	assert.Len(t, child.Attributes, 2)
+}
+
+func TestTracerIsOptional(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	tracer := New(Config{}, nil)
+	assert.Nil(t, tracer)
+
+	ctx, span := tracer.Start(context.Background(), "turn", KindInternal)
+	assert.NotNil(t, ctx)
+	span.SetAttribute("key", "value")
+	span.End()
+	tracer.Close(time.Second)
+}
+
+func TestEndpointFromEnvironment(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	assert.Equal(t, "http://collector:4318/v1/traces", tracesURL(""))
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/custom")
+	assert.Equal(t, "http://collector:4318/custom", tracesURL(""))
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, parseHeaders("a=1, b=2,broken"))
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"agent/theme"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	tuiInputHeight = 3
+	tuiStatusEvery = 500 * time.Millisecond
+	tuiQueueSize   = 64 // messages sent but not yet read by the chat loop
+)
+
+// Messages delivered to the TUI from the agent's goroutines
+type (
+	tuiOutputMsg     string // text the agent printed to stdout
+	tuiToolOutputMsg string // a tool result, shown in the tool output pane
+	tuiProgressMsg   ProgressEvent
+	tuiStatusMsg     struct{}
+	tuiLoopDoneMsg   struct{}
+)
+
+// transcript accumulates streamed output and wraps it to the viewport width. Complete lines are
+// wrapped once; only the line still being written is rewrapped as text arrives.
+type transcript struct {
+	width   int
+	lines   []string // raw complete lines, kept to rewrap on resize
+	wrapped []string
+	partial string // raw text after the last newline
+}
+
+func (t *transcript) Write(text string) {
+	text = t.partial + text
+	complete := strings.Split(text, "\n")
+	t.partial = complete[len(complete)-1]
+	for _, line := range complete[:len(complete)-1] {
+		line = overwriteCarriageReturns(line)
+		t.lines = append(t.lines, line)
+		t.wrapped = append(t.wrapped, t.wrap(line))
+	}
+}
+
+func (t *transcript) Resize(width int) {
+	t.width = width
+	t.wrapped = t.wrapped[:0]
+	for _, line := range t.lines {
+		t.wrapped = append(t.wrapped, t.wrap(line))
+	}
+}
+
+func (t *transcript) String() string {
+	lines := t.wrapped
+	if t.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], t.wrap(overwriteCarriageReturns(t.partial)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (t *transcript) LineCount() int {
+	return len(t.lines)
+}
+
+func (t *transcript) wrap(line string) string {
+	if t.width <= 0 {
+		return line
+	}
+	return ansi.Wrap(line, t.width, "")
+}
+
+// overwriteCarriageReturns keeps what a terminal would show for a line redrawn with \r, as progress
+// counters do
+func overwriteCarriageReturns(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		return line[i+1:]
+	}
+	return line
+}
+
+// tuiWriter forwards writes to the TUI as messages
+type tuiWriter struct {
+	program *tea.Program
+	message func(string) tea.Msg
+}
+
+func (w tuiWriter) Write(p []byte) (int, error) {
+	w.program.Send(w.message(string(p)))
+	return len(p), nil
+}
+
+type tuiModel struct {
+	agent        *Agent
+	submit       chan<- string // lines for the agent's input; Update must never wait on the chat loop
+	conversation viewport.Model
+	toolPane     viewport.Model
+	input        textarea.Model
+	transcript   *transcript
+	toolLog      *transcript
+	toolsOpen    bool
+	progress     ProgressEvent // the latest heartbeat of the running turn
+	status       AgentStatus
+	width        int
+	height       int
+}
+
+func newTUIModel(agent *Agent, submit chan<- string) *tuiModel {
+	input := textarea.New()
+	input.Placeholder = "Message the agent, or /help"
+	input.ShowLineNumbers = false
+	input.SetHeight(tuiInputHeight)
+	input.MaxHeight = 0
+	// Enter sends the message; pastes keep their newlines and alt+enter adds one
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	input.Focus()
+
+	return &tuiModel{
+		agent:        agent,
+		submit:       submit,
+		conversation: viewport.New(0, 0),
+		toolPane:     viewport.New(0, 0),
+		input:        input,
+		transcript:   &transcript{},
+		toolLog:      &transcript{},
+		status:       agent.Status(),
+	}
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, tuiStatusTick())
+}
+
+func tuiStatusTick() tea.Cmd {
+	return tea.Tick(tuiStatusEvery, func(time.Time) tea.Msg { return tuiStatusMsg{} })
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.transcript.Resize(msg.Width)
+		m.toolLog.Resize(msg.Width)
+		m.layout()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			action := m.agent.Interrupt()
+			if action == interruptExit {
+				return m, tea.Quit
+			}
+			if m.agent.isAsking() {
+				// The turn waits on the question before it can stop; an empty answer declines it
+				m.send("")
+			}
+			return m, func() tea.Msg { return tuiOutputMsg("\n" + interruptNotice(action) + "\n") }
+		case "esc":
+			// Esc only stops a running turn, so it is safe to press at any time
+			if m.agent.CancelTurn() {
+				return m, nil
+			}
+		case "ctrl+d":
+			return m, tea.Quit
+		case "ctrl+t":
+			m.toolsOpen = !m.toolsOpen
+			m.layout()
+			return m, nil
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.conversation, cmd = m.conversation.Update(msg)
+			return m, cmd
+		case "shift+up", "shift+down":
+			if m.toolsOpen {
+				if msg.String() == "shift+up" {
+					m.toolPane.LineUp(1)
+				} else {
+					m.toolPane.LineDown(1)
+				}
+			}
+			return m, nil
+		case "enter":
+			message := m.input.Value()
+			if strings.Contains(message, "\n") {
+				// Sent as a paste so the chat loop reads it as one message
+				message = pasteStart + message + pasteEnd
+			}
+			if !m.send(message) {
+				return m, func() tea.Msg {
+					return tuiOutputMsg(theme.WarningText("Too many messages are waiting; send this one when the agent catches up") + "\n")
+				}
+			}
+			m.input.Reset()
+			return m, nil
+		}
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.conversation, cmd = m.conversation.Update(msg)
+		return m, cmd
+
+	case tuiOutputMsg:
+		atBottom := m.conversation.AtBottom()
+		m.transcript.Write(string(msg))
+		m.conversation.SetContent(m.transcript.String())
+		if atBottom {
+			m.conversation.GotoBottom()
+		}
+		return m, nil
+
+	case tuiToolOutputMsg:
+		m.toolLog.Write(string(msg))
+		m.toolPane.SetContent(m.toolLog.String())
+		m.toolPane.GotoBottom()
+		return m, nil
+
+	case tuiProgressMsg:
+		m.progress = ProgressEvent(msg)
+		return m, nil
+
+	case tuiStatusMsg:
+		m.status = m.agent.Status()
+		if !m.status.Busy {
+			m.progress = ProgressEvent{}
+		}
+		return m, tuiStatusTick()
+
+	case tuiLoopDoneMsg:
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+// send queues a line for the chat loop without waiting for it, reporting false when the queue is full
+func (m *tuiModel) send(line string) bool {
+	select {
+	case m.submit <- line:
+		return true
+	default:
+		return false
+	}
+}
+
+// layout splits the screen between the conversation, tool pane, status bar, and input
+func (m *tuiModel) layout() {
+	toolHeight := 0
+	if m.toolsOpen {
+		toolHeight = max(m.height/3, 3)
+	}
+	// The tool pane header and status bar take a line each
+	conversationHeight := max(m.height-tuiInputHeight-toolHeight-2, 1)
+
+	m.conversation.Width = m.width
+	m.conversation.Height = conversationHeight
+	m.conversation.SetContent(m.transcript.String())
+	m.toolPane.Width = m.width
+	m.toolPane.Height = toolHeight
+	m.toolPane.SetContent(m.toolLog.String())
+	m.toolPane.GotoBottom()
+	m.input.SetWidth(m.width)
+}
+
+func (m *tuiModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	bar := lipgloss.NewStyle().Reverse(true).Width(m.width)
+	dim := lipgloss.NewStyle().Faint(true).Width(m.width)
+
+	sections := []string{m.conversation.View()}
+	if m.toolsOpen {
+		sections = append(sections, dim.Render(fmt.Sprintf("▾ tool output (%d lines) · ctrl+t hides · shift+↑/↓ scrolls", m.toolLog.LineCount())))
+		sections = append(sections, m.toolPane.View())
+	} else {
+		sections = append(sections, dim.Render(fmt.Sprintf("▸ tool output (%d lines) · ctrl+t shows", m.toolLog.LineCount())))
+	}
+	sections = append(sections, bar.Render(ansi.Truncate(m.statusText(), m.width, "…")))
+	sections = append(sections, m.input.View())
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+func (m *tuiModel) statusText() string {
+	parts := []string{" " + m.status.Model}
+	parts = append(parts, fmt.Sprintf("~%s tokens", formatTokenCount(m.status.HistoryTokens)))
+	parts = append(parts, fmt.Sprintf("context %.0f%%", m.status.ContextPercent))
+	switch {
+	case m.progress.Tool != "":
+		parts = append(parts, fmt.Sprintf("running %s · %s", m.progress.Tool, m.progress.Elapsed))
+		if m.progress.Status != "" {
+			parts = append(parts, ansi.Truncate(m.progress.Status, 60, "…"))
+		}
+	case m.status.Busy:
+		parts = append(parts, "working…")
+	}
+	if m.status.Queued > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued", m.status.Queued))
+	}
+	parts = append(parts, "pgup/pgdn scroll · esc stop · ctrl+c ×2 quit · ctrl+d quit")
+	return strings.Join(parts, " · ")
+}
+
+func formatTokenCount(tokens int) string {
+	if tokens < 1000 {
+		return fmt.Sprint(tokens)
+	}
+	return fmt.Sprintf("%.1fk", float64(tokens)/1000)
+}
+
+// runTUI runs the chat loop behind a full-screen interface. Everything the agent prints is captured
+// from stdout into the conversation viewport and tool results go to their own pane, so streaming
+// output can't interleave with the input line.
+func runTUI(agent *Agent) error {
+	terminal := os.Stdout
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	inputReader, inputWriter := io.Pipe()
+	submitted := make(chan string, tuiQueueSize)
+	go func() {
+		for line := range submitted {
+			fmt.Fprintln(inputWriter, line)
+		}
+	}()
+
+	model := newTUIModel(agent, submitted)
+	program := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithOutput(terminal))
+
+	os.Stdout = outputWriter
+	log.SetOutput(outputWriter)
+	agent.input = newLineReader(inputReader, agent.interceptInput)
+	agent.toolOutput = tuiWriter{program: program, message: func(text string) tea.Msg { return tuiToolOutputMsg(text) }}
+	agent.OnProgress(func(event ProgressEvent) { program.Send(tuiProgressMsg(event)) })
+	defer func() {
+		os.Stdout = terminal
+		log.SetOutput(os.Stderr)
+		agent.toolOutput = nil
+	}()
+
+	go func() {
+		buffer := make([]byte, 4096)
+		for {
+			n, err := outputReader.Read(buffer)
+			if n > 0 {
+				program.Send(tuiOutputMsg(string(buffer[:n])))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		if welcome := agent.welcomeMessage(); welcome != "" {
+			fmt.Println(welcome)
+		}
+		chatLoop(agent, false)
+		program.Send(tuiLoopDoneMsg{})
+	}()
+
+	_, runErr := program.Run()
+
+	// Stop the loop: cancel any running turn and end its input
+	agent.CancelTurn()
+	inputWriter.Close()
+	close(submitted)
+	<-loopDone
+	outputWriter.Close()
+	return runErr
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	transcript := &transcript{}
+	transcript.Write("hello ")
+	transcript.Write("world\nprogress 10%\rprogress 50%")
+	assert.Equal(t, "hello world\nprogress 50%", transcript.String())
+	assert.Equal(t, 1, transcript.LineCount())
+
+	transcript.Write("\rprogress 100%\n")
+	assert.Equal(t, "hello world\nprogress 100%", transcript.String())
+
+	transcript.Resize(5)
+	assert.Equal(t, "hello\nworld\nprogr\ness\n100%", transcript.String())
+}
+
+func TestTUISubmitDoesNotWait(t *testing.T) {
+	submitted := make(chan string, 1)
+	model := newTUIModel(&Agent{LiveContext: NewLiveContext()}, submitted)
+
+	model.input.SetValue("first")
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+	assert.Equal(t, "first", <-submitted)
+	assert.Empty(t, model.input.Value())
+
+	model.input.SetValue("line one\nline two")
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, pasteStart+"line one\nline two"+pasteEnd, <-submitted)
+
+	// With nothing reading the queue, a full queue keeps the message in the input instead of blocking
+	submitted <- "waiting"
+	model.input.SetValue("second")
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	require.NotNil(t, cmd)
+	assert.Contains(t, string(cmd().(tuiOutputMsg)), "Too many messages")
+	assert.Equal(t, "second", model.input.Value())
+}
+
+func TestTUICtrlCCancelsTurn(t *testing.T) {
+	submitted := make(chan string, 1)
+	agent := &Agent{LiveContext: NewLiveContext()}
+	model := newTUIModel(agent, submitted)
+
+	cancelled := false
+	agent.inProgress, agent.cancelFunc = true, func() { cancelled = true }
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	assert.True(t, cancelled)
+	assert.Contains(t, string(cmd().(tuiOutputMsg)), "Stopping")
+	assert.Empty(t, submitted, "nothing is answered when no question is waiting")
+
+	// A second Ctrl+C right away quits
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	assert.Equal(t, tea.QuitMsg{}, cmd())
+
+	// A question the turn is waiting on is declined, so the turn can stop
+	agent.interrupts = interruptTracker{}
+	agent.asking = true
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	assert.Equal(t, "", <-submitted)
+}
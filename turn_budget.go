@@ -0,0 +1,109 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"fmt"
+)
+
+// TurnBudgetConfig limits the tokens and money one turn may spend; 0 means unlimited. Together with
+// max_iterations, the user is asked whether to continue when a turn reaches a limit.
+type TurnBudgetConfig struct {
+	MaxTokens int     `json:"max_tokens"` // prompt and completion tokens of the turn's requests
+	MaxCost   float64 `json:"max_cost"`   // dollars, counting models with pricing in their config
+}
+
+// turnBudget tracks what the running turn has spent against max_iterations and turn_budget. Token
+// counts come from the usage providers report, or are estimated from characters when they don't.
+type turnBudget struct {
+	maxIterations int
+	maxTokens     int
+	maxCost       float64
+	config        *Config // the limits each extension adds
+
+	startTokens int
+	startCost   float64
+	estimated   int // tokens of requests whose usage wasn't reported
+}
+
+func newTurnBudget(config *Config) *turnBudget {
+	budget := &turnBudget{maxIterations: config.MaxIterations, maxTokens: config.TurnBudget.MaxTokens, maxCost: config.TurnBudget.MaxCost, config: config}
+	budget.startTokens, budget.startCost = reportedUsage()
+	return budget
+}
+
+// reportedUsage totals the tokens and cost providers have reported this session
+func reportedUsage() (int, float64) {
+	tokens, cost := 0, 0.0
+	for _, model := range api.Usage() {
+		tokens += model.PromptTokens + model.CompletionTokens
+		if dollars, ok := model.Cost(); ok {
+			cost += dollars
+		}
+	}
+	return tokens, cost
+}
+
+// spent returns the tokens and cost of the turn so far
+func (b *turnBudget) spent() (int, float64) {
+	tokens, cost := reportedUsage()
+	return tokens - b.startTokens + b.estimated, cost - b.startCost
+}
+
+// recordRequest estimates the tokens of a request of chars characters when its provider reported
+// no usage, i.e. the reported total is still tokensBefore
+func (b *turnBudget) recordRequest(tokensBefore, chars int) {
+	if tokensAfter, _ := reportedUsage(); tokensAfter == tokensBefore {
+		b.estimated += (chars + 3) / 4
+	}
+}
+
+// requestChars counts the characters sent and received in a request
+func requestChars(systemPrompt string, messages []models.Message, content string, toolCalls []models.ToolCall) int {
+	chars := len(systemPrompt) + len(content)
+	for _, message := range messages {
+		chars += len(message.Content)
+		for _, call := range message.ToolCalls {
+			chars += len(call.Function.Arguments)
+		}
+	}
+	for _, call := range toolCalls {
+		chars += len(call.Function.Arguments)
+	}
+	return chars
+}
+
+// exhausted returns why the turn can't start another iteration without asking, or "" if it can
+func (b *turnBudget) exhausted(iteration int) string {
+	if b.maxIterations > 0 && iteration >= b.maxIterations {
+		return fmt.Sprintf("reached %d iterations (max_iterations)", b.maxIterations)
+	}
+	tokens, cost := b.spent()
+	if b.maxTokens > 0 && tokens >= b.maxTokens {
+		return fmt.Sprintf("used %d tokens of the %d token turn budget", tokens, b.maxTokens)
+	}
+	if b.maxCost > 0 && cost >= b.maxCost {
+		return fmt.Sprintf("spent $%.4f of the $%.2f turn budget", cost, b.maxCost)
+	}
+	return ""
+}
+
+// extend grants another budget's worth of every limit once the user chooses to continue
+func (b *turnBudget) extend() {
+	b.maxIterations += b.config.MaxIterations
+	b.maxTokens += b.config.TurnBudget.MaxTokens
+	b.maxCost += b.config.TurnBudget.MaxCost
+}
+
+// checkTurnBudget asks the user whether to continue a turn that has exhausted its budget
+func (a *Agent) checkTurnBudget(budget *turnBudget, iteration int) error {
+	reason := budget.exhausted(iteration)
+	if reason == "" {
+		return nil
+	}
+	if a.Confirm(fmt.Sprintf("⏸ This turn %s. Continue?", reason)) {
+		budget.extend()
+		return nil
+	}
+	return fmt.Errorf("%w: the turn %s", errStoppedByUser, reason)
+}
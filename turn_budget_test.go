@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTurnBudget(t *testing.T) {
+	unlimited := newTurnBudget(&Config{})
+	unlimited.recordRequest(0, 4000)
+	assert.Equal(t, "", unlimited.exhausted(1000))
+
+	budget := newTurnBudget(&Config{MaxIterations: 3, TurnBudget: TurnBudgetConfig{MaxTokens: 2000}})
+	assert.Equal(t, "", budget.exhausted(2))
+	assert.Equal(t, "reached 3 iterations (max_iterations)", budget.exhausted(3))
+
+	// Continuing grants another budget's worth
+	budget.extend()
+	assert.Equal(t, "", budget.exhausted(3))
+	assert.Equal(t, "reached 6 iterations (max_iterations)", budget.exhausted(6))
+
+	// Requests without reported usage are estimated from their size
+	tokensBefore, _ := reportedUsage()
+	budget.recordRequest(tokensBefore, 8000)
+	assert.Equal(t, "", budget.exhausted(4))
+	budget.recordRequest(tokensBefore, 8000)
+	assert.Equal(t, "used 4000 tokens of the 4000 token turn budget", budget.exhausted(4))
+}
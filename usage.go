@@ -0,0 +1,60 @@
+package main
+
+import (
+	"agent/api"
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+// contextWindowWarning returns a warning when a request looks too large for the model's context
+// window, or an empty string when it fits or the window isn't configured
+func contextWindowWarning(model *models.Model, systemPrompt string, messages []models.Message) string {
+	window := model.Config.ContextWindow
+	if window <= 0 {
+		return ""
+	}
+	tokens := estimateRequestTokens(model, systemPrompt, messages)
+	if tokens <= window {
+		return ""
+	}
+	return fmt.Sprintf("⚠ This request needs about %d tokens (including %d for the response), over the %d token context window of %s. Trim it with /prune or set budget.total_chars in the config.", tokens, model.Config.MaxTokens, window, model.ID)
+}
+
+// capabilityNotes describes what a model can't do, for when it is selected
+func capabilityNotes(model *models.Model) []string {
+	var notes []string
+	if !model.AcceptsTools() {
+		notes = append(notes, "it doesn't support tools, so it can only answer from the conversation and live context")
+	}
+	return notes
+}
+
+func handleUsage(a *Agent, args []string) string {
+	usage := api.Usage()
+	if len(usage) == 0 {
+		return theme.InfoText("No usage reported yet this session")
+	}
+
+	var result strings.Builder
+	result.WriteString(theme.InfoText("Token usage this session:") + "\n")
+	total, priced := 0.0, true
+	for _, model := range usage {
+		cost := "no pricing in the config"
+		if dollars, ok := model.Cost(); ok {
+			cost = fmt.Sprintf("$%.4f", dollars)
+			total += dollars
+		} else {
+			priced = false
+		}
+		result.WriteString(theme.InfoText(fmt.Sprintf("  %s: %d requests, %d prompt tokens (%d cached), %d completion tokens, %s",
+			model.Model, model.Requests, model.PromptTokens, model.CachedTokens, model.CompletionTokens, cost)) + "\n")
+	}
+	line := fmt.Sprintf("Total cost: $%.4f", total)
+	if !priced {
+		line += " (models without pricing aren't included; add config.pricing to their config)"
+	}
+	result.WriteString(theme.InfoText(line))
+	return result.String()
+}
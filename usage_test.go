@@ -0,0 +1,27 @@
+package main
+
+import (
+	"agent/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWindowWarning(t *testing.T) {
+	model := &models.Model{ID: "small", Config: models.ModelConfig{MaxTokens: 1000}}
+	messages := []models.Message{{Role: "user", Content: strings.Repeat("x", 20000)}}
+	assert.Empty(t, contextWindowWarning(model, "system", messages), "no warning without a configured window")
+
+	model.Config.ContextWindow = 4000
+	assert.Contains(t, contextWindowWarning(model, "system", messages), "over the 4000 token context window of small")
+
+	model.Config.ContextWindow = 8000
+	assert.Empty(t, contextWindowWarning(model, "system", messages))
+}
+
+func TestCapabilityNotes(t *testing.T) {
+	supportsTools := false
+	assert.Empty(t, capabilityNotes(&models.Model{}))
+	assert.Len(t, capabilityNotes(&models.Model{Config: models.ModelConfig{SupportsTools: &supportsTools}}), 1)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"agent/models"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errStoppedByUser is returned when the user stops a turn the watchdog paused
+var errStoppedByUser = errors.New("stopped by the user")
+
+// WatchdogConfig sets when a turn that looks stuck in a loop is paused for the user. Zero values use
+// the defaults; -1 disables a check.
+type WatchdogConfig struct {
+	RepeatedCalls int `json:"repeated_calls"` // identical tool calls in one turn (default 3)
+	Oscillations  int `json:"oscillations"`   // times one file is edited back to an earlier state in one turn (default 2)
+}
+
+// watchdog tracks the current turn's tool calls and file states to detect runaway loops. A nil
+// watchdog, as outside a turn, tracks nothing.
+type watchdog struct {
+	calls        map[string]int
+	fileStates   map[string][][32]byte // content hashes after each change, per path
+	oscillations map[string]int
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{
+		calls:        make(map[string]int),
+		fileStates:   make(map[string][][32]byte),
+		oscillations: make(map[string]int),
+	}
+}
+
+func thresholdOrDefault(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// observeCall records a tool call and returns a reason when the same call has repeated too often
+func (w *watchdog) observeCall(cfg WatchdogConfig, toolCall models.ToolCall) string {
+	limit := thresholdOrDefault(cfg.RepeatedCalls, 3)
+	if w == nil || limit < 0 {
+		return ""
+	}
+
+	// Re-encode arguments so key order and whitespace don't hide repeats
+	arguments := toolCall.Function.Arguments
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &params); err == nil {
+		if canonical, err := json.Marshal(params); err == nil {
+			arguments = string(canonical)
+		}
+	}
+
+	key := toolCall.Function.Name + " " + arguments
+	w.calls[key]++
+	if w.calls[key] >= limit {
+		return fmt.Sprintf("%s has been called %d times this turn with the same arguments", toolCall.Function.Name, w.calls[key])
+	}
+	return ""
+}
+
+// observeFile records a file's content after a change (before is its content prior to the change)
+// and returns a reason when edits keep returning it to an earlier state
+func (w *watchdog) observeFile(cfg WatchdogConfig, path, before string) string {
+	limit := thresholdOrDefault(cfg.Oscillations, 2)
+	if w == nil || limit < 0 {
+		return ""
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return ""
+	}
+	hash := sha256.Sum256(content)
+
+	if _, seen := w.fileStates[path]; !seen {
+		w.fileStates[path] = [][32]byte{sha256.Sum256([]byte(before))}
+	}
+	states := w.fileStates[path]
+	// A write that left the file as it was isn't a new state
+	if states[len(states)-1] == hash {
+		return ""
+	}
+	// Revisiting any state other than the current one means an earlier edit was undone
+	for i := 0; i < len(states)-1; i++ {
+		if states[i] == hash {
+			w.oscillations[path]++
+			break
+		}
+	}
+	w.fileStates[path] = append(states, hash)
+
+	if w.oscillations[path] >= limit {
+		return fmt.Sprintf("%s has been edited back to an earlier version %d times this turn", path, w.oscillations[path])
+	}
+	return ""
+}
+
+// watch runs a watchdog check on the turn's watchdog and pauses the turn when it finds a loop
+func (a *Agent) watch(observe func(w *watchdog) string) error {
+	a.turnStateMu.Lock()
+	defer a.turnStateMu.Unlock()
+	return a.checkWatchdog(observe(a.watchdog))
+}
+
+// checkWatchdog pauses the turn to ask the user whether to continue when reason is set. Continuing
+// resets the watchdog so the user isn't asked again for the same loop right away.
+func (a *Agent) checkWatchdog(reason string) error {
+	if reason == "" {
+		return nil
+	}
+	if a.Confirm(fmt.Sprintf("⚠ The agent may be stuck in a loop: %s. Continue?", reason)) {
+		a.watchdog = newWatchdog()
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errStoppedByUser, reason)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"agent/models"
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogRepeatedCalls(t *testing.T) {
+	call := func(arguments string) models.ToolCall {
+		return models.ToolCall{Function: models.FunctionCall{Name: "read_file", Arguments: arguments}}
+	}
+	cases := []struct {
+		name    string
+		cfg     WatchdogConfig
+		calls   []models.ToolCall
+		flagged int // index of the first call flagged, or -1
+	}{
+		{"default threshold", WatchdogConfig{}, []models.ToolCall{call(`{"path":"a"}`), call(`{"path":"a"}`), call(`{"path":"a"}`)}, 2},
+		{"configured threshold", WatchdogConfig{RepeatedCalls: 2}, []models.ToolCall{call(`{"path":"a"}`), call(`{"path":"a"}`)}, 1},
+		{"different arguments", WatchdogConfig{}, []models.ToolCall{call(`{"path":"a"}`), call(`{"path":"b"}`), call(`{"path":"a"}`), call(`{"path":"b"}`)}, -1},
+		{"key order and whitespace", WatchdogConfig{}, []models.ToolCall{call(`{"path":"a","limit":5}`), call(`{"limit": 5, "path": "a"}`), call(`{ "limit":5,"path":"a" }`)}, 2},
+		{"disabled", WatchdogConfig{RepeatedCalls: -1}, []models.ToolCall{call(`{}`), call(`{}`), call(`{}`), call(`{}`)}, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := newWatchdog()
+			flagged := -1
+			for i, toolCall := range c.calls {
+				if reason := w.observeCall(c.cfg, toolCall); reason != "" && flagged < 0 {
+					flagged = i
+					assert.Contains(t, reason, "read_file has been called")
+				}
+			}
+			assert.Equal(t, c.flagged, flagged)
+		})
+	}
+}
+
+func TestWatchdogOscillations(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      WatchdogConfig
+		states   []string // file contents after each change, starting from "A"
+		reasonAt int      // index of the first change flagged, or -1
+	}{
+		{"moving forward", WatchdogConfig{}, []string{"B", "C", "D"}, -1},
+		{"A to B to A, once", WatchdogConfig{}, []string{"B", "A"}, -1},
+		{"A to B to A, twice", WatchdogConfig{}, []string{"B", "A", "B"}, 2},
+		{"configured threshold", WatchdogConfig{Oscillations: 1}, []string{"B", "A"}, 1},
+		{"rewriting the same content", WatchdogConfig{Oscillations: 1}, []string{"B", "B", "B"}, -1},
+		{"deleted and recreated", WatchdogConfig{Oscillations: 1}, []string{"", "A"}, 1},
+		{"disabled", WatchdogConfig{Oscillations: -1}, []string{"B", "A", "B", "A", "B"}, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			w := newWatchdog()
+			before := "A"
+			reasonAt := -1
+			for i, state := range c.states {
+				require.NoError(t, os.WriteFile(path, []byte(state), 0644))
+				if reason := w.observeFile(c.cfg, path, before); reason != "" && reasonAt < 0 {
+					reasonAt = i
+					assert.Contains(t, reason, "edited back to an earlier version")
+				}
+				before = state
+			}
+			assert.Equal(t, c.reasonAt, reasonAt)
+		})
+	}
+}
+
+func TestWatchdogOutsideTurn(t *testing.T) {
+	var w *watchdog
+	toolCall := models.ToolCall{Function: models.FunctionCall{Name: "read_file", Arguments: `{}`}}
+	for i := 0; i < 5; i++ {
+		assert.Empty(t, w.observeCall(WatchdogConfig{RepeatedCalls: 1}, toolCall))
+		assert.Empty(t, w.observeFile(WatchdogConfig{Oscillations: 1}, "missing.txt", "A"))
+	}
+
+	a := &Agent{config: &Config{}}
+	assert.NoError(t, a.watch(func(w *watchdog) string { return w.observeCall(a.config.Watchdog, toolCall) }))
+}
+
+func TestCheckWatchdog(t *testing.T) {
+	a := &Agent{watchdog: newWatchdog(), input: bufio.NewScanner(strings.NewReader("y\nn\n"))}
+	assert.NoError(t, a.checkWatchdog(""), "nothing to ask about")
+
+	a.watchdog.calls["read_file {}"] = 3
+	assert.NoError(t, a.checkWatchdog("read_file has been called 3 times"))
+	assert.Empty(t, a.watchdog.calls, "continuing starts the watchdog over")
+
+	err := a.checkWatchdog("read_file has been called 3 times")
+	assert.ErrorIs(t, err, errStoppedByUser)
+	assert.ErrorContains(t, err, "read_file has been called 3 times")
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"agent/models"
+	"agent/theme"
+	"fmt"
+	"strings"
+)
+
+// instructionsOnly blanks the per-request placeholders of the system prompt template, leaving the
+// static instructions
+func instructionsOnly(template string) string {
+	for _, variable := range promptTemplateVariables() {
+		template = strings.ReplaceAll(template, variable.Name, "")
+	}
+	return template
+}
+
+// promptSection is one part of the system prompt as it was sent
+type promptSection struct {
+	Name string
+	Text string
+}
+
+// requestExplanation records what went into a model request and what was trimmed to fit, for /why
+type requestExplanation struct {
+	Turn      int
+	Iteration int
+	Model     string
+	Placement string
+
+	SystemBudget      int // character budgets; zero when budgeting is disabled
+	LiveContextBudget int
+	HistoryBudget     int
+
+	Sections []promptSection
+	Entries  []ContextEntry
+
+	HistorySent    int // messages
+	HistoryChars   int
+	HistoryDropped int // oldest messages trimmed to fit the history budget
+	TurnsDropped   int
+}
+
+// explainRequest records the explanation of the request about to be sent, keeping the previous one
+// so /why can show what changed
+func (a *Agent) explainRequest(iteration int, model *models.Model, sections []promptSection, sent []models.Message, dropped []models.Message) {
+	explanation := &requestExplanation{
+		Turn:         a.turn,
+		Iteration:    iteration,
+		Model:        model.Provider.ID + ":" + model.ID,
+		Placement:    a.ContextPlacement(),
+		Sections:     sections,
+		Entries:      a.LiveContext.LastSerialization(),
+		HistorySent:  len(sent),
+		HistoryChars: historySize(sent),
+	}
+	explanation.SystemBudget, explanation.LiveContextBudget, _ = a.config.Budget.Limits()
+	explanation.HistoryBudget = a.historyBudget(sections)
+	explanation.HistoryDropped = len(dropped)
+	for _, message := range dropped {
+		if message.Role == "user" {
+			explanation.TurnsDropped++
+		}
+	}
+
+	a.mu.Lock()
+	a.previousRequest, a.lastRequest = a.lastRequest, explanation
+	a.mu.Unlock()
+}
+
+func historySize(messages []models.Message) int {
+	size := 0
+	for _, message := range messages {
+		size += messageSize(message)
+	}
+	return size
+}
+
+func handleWhy(a *Agent, args []string) string {
+	a.mu.RLock()
+	last, previous := a.lastRequest, a.previousRequest
+	a.mu.RUnlock()
+	if last == nil {
+		return theme.InfoText("No request has been sent yet this session")
+	}
+
+	var result strings.Builder
+	line := func(format string, args ...interface{}) {
+		result.WriteString(theme.InfoText(fmt.Sprintf(format, args...)) + "\n")
+	}
+
+	line("Last request: turn %d, iteration %d, %s", last.Turn, last.Iteration, last.Model)
+	if last.SystemBudget+last.LiveContextBudget+last.HistoryBudget == 0 {
+		line("Budget: not enforced (set budget.total_chars in the config to trim requests)")
+	} else {
+		line("Budget: system %d, live context %d, history %d characters", last.SystemBudget, last.LiveContextBudget, last.HistoryBudget)
+		if over := systemSize(last.Sections) - last.SystemBudget; over > 0 {
+			line("  The system prompt is %d characters over its budget, taken from the history budget", over)
+		}
+	}
+
+	result.WriteString("\n")
+	total := 0
+	for _, section := range last.Sections {
+		total += len(section.Text)
+	}
+	if last.Placement == PlacementMessage {
+		line("Prompt sections (%d characters; live context and the sections after it went in the context message):", total)
+	} else {
+		line("Prompt sections (%d characters; the sections after live context went in the context message):", total)
+	}
+	for _, section := range last.Sections {
+		note := ""
+		if section.Text == "" {
+			note = " (empty)"
+		}
+		line("  %-28s %7d%s", section.Name, len(section.Text), note)
+	}
+
+	result.WriteString("\n")
+	placement := "in the system prompt"
+	if last.Placement == PlacementMessage {
+		placement = "in a context message after the history"
+	}
+	if len(last.Entries) == 0 {
+		line("Live context: empty")
+	} else {
+		line("Live context (sent %s):", placement)
+		for _, entry := range last.Entries {
+			line("  %s", describeEntry(entry))
+		}
+	}
+
+	result.WriteString("\n")
+	line("History: %d messages sent (%d characters)", last.HistorySent, last.HistoryChars)
+	if last.HistoryDropped > 0 {
+		line("  %d oldest messages (%d turns) dropped to fit the %d character history budget; the model never saw them", last.HistoryDropped, last.TurnsDropped, last.HistoryBudget)
+	}
+
+	if previous != nil {
+		result.WriteString("\n")
+		line("Since the previous request (turn %d, iteration %d):", previous.Turn, previous.Iteration)
+		changes := diffRequests(previous, last)
+		if len(changes) == 0 {
+			line("  nothing changed besides new messages")
+		}
+		for _, change := range changes {
+			line("  %s", change)
+		}
+	}
+
+	return strings.TrimRight(result.String(), "\n")
+}
+
+// describeEntry explains how a live-context entry was serialized
+func describeEntry(entry ContextEntry) string {
+	kind := "file"
+	if entry.Directory {
+		kind = "directory"
+	}
+	var description string
+	switch entry.Status {
+	case EntryIncluded:
+		description = fmt.Sprintf("✓ %s %s (%d characters)", kind, entry.Path, entry.Chars)
+	case EntryPartial:
+		description = fmt.Sprintf("◐ %s %s cut to the whole symbols that fit the budget (%d characters)", kind, entry.Path, entry.Chars)
+	case EntryOmitted:
+		description = fmt.Sprintf("✗ %s %s omitted: over the live context budget, only its path was sent", kind, entry.Path)
+	case EntryError:
+		description = fmt.Sprintf("✗ %s %s couldn't be read; the error was sent instead", kind, entry.Path)
+	}
+	if entry.Untrusted {
+		description += ", wrapped as untrusted content"
+	}
+	if entry.Priority != "" && entry.Priority != PriorityNormal {
+		description += fmt.Sprintf(" [%s]", entry.Priority)
+	}
+	return description
+}
+
+// diffRequests lists how the sections, live context, and history trimming differ between two requests
+func diffRequests(previous, last *requestExplanation) []string {
+	var changes []string
+	if previous.Model != last.Model {
+		changes = append(changes, fmt.Sprintf("model: %s → %s", previous.Model, last.Model))
+	}
+	if previous.Placement != last.Placement {
+		changes = append(changes, fmt.Sprintf("live context placement: %s → %s", previous.Placement, last.Placement))
+	}
+
+	before := make(map[string]string)
+	for _, section := range previous.Sections {
+		before[section.Name] = section.Text
+	}
+	for _, section := range last.Sections {
+		if old, ok := before[section.Name]; ok && old != section.Text {
+			changes = append(changes, fmt.Sprintf("%s changed (%+d characters)", section.Name, len(section.Text)-len(old)))
+		}
+	}
+
+	statuses := make(map[string]string)
+	for _, entry := range previous.Entries {
+		statuses[entry.Path] = entry.Status
+	}
+	for _, entry := range last.Entries {
+		old, ok := statuses[entry.Path]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("+ %s added to live context (%s)", entry.Path, entry.Status))
+		case old != entry.Status:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", entry.Path, old, entry.Status))
+		}
+		delete(statuses, entry.Path)
+	}
+	for _, entry := range previous.Entries {
+		if _, ok := statuses[entry.Path]; ok {
+			changes = append(changes, fmt.Sprintf("- %s removed from live context", entry.Path))
+		}
+	}
+
+	if dropped := last.HistoryDropped - previous.HistoryDropped; dropped > 0 {
+		changes = append(changes, fmt.Sprintf("%d more old messages dropped from history", dropped))
+	}
+	return changes
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"agent/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhy(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.txt")
+	assert.NoError(t, os.WriteFile(small, []byte("hello"), 0644))
+	assert.NoError(t, os.WriteFile(large, []byte(strings.Repeat("x", 5000)), 0644))
+
+	agent := &Agent{LiveContext: NewLiveContext(), config: &Config{Budget: BudgetConfig{TotalChars: 40000, History: 0.025, LiveContext: 0.025, System: 0.95}}}
+	defer agent.LiveContext.Close()
+	assert.Contains(t, handleWhy(agent, nil), "No request has been sent yet")
+
+	model := &models.Model{ID: "gpt-4o", Provider: &models.Provider{ID: "openai"}}
+	assert.NoError(t, agent.LiveContext.AddFile(small, 1, nil))
+	_, _, sections := agent.buildSystemPrompt()
+	history := []models.Message{
+		{Role: "user", Content: strings.Repeat("old question ", 100)},
+		{Role: "assistant", Content: "old answer"},
+		{Role: "user", Content: "new question"},
+	}
+	sent, dropped := trimHistoryToBudget(history, 1000)
+	agent.explainRequest(1, model, sections, sent, history[:dropped])
+
+	why := handleWhy(agent, nil)
+	assert.Contains(t, why, "Last request: turn 0, iteration 1, openai:gpt-4o")
+	assert.Contains(t, why, "Budget: system 38000, live context 1000, history 1000 characters")
+	assert.NotContains(t, why, "over its budget")
+	assert.Contains(t, why, "✓ file "+small)
+	assert.Contains(t, why, "History: 1 messages sent")
+	assert.Contains(t, why, "2 oldest messages (1 turns) dropped")
+	assert.NotContains(t, why, "Since the previous request")
+
+	assert.NoError(t, agent.LiveContext.AddFile(large, 1, nil))
+	_, _, sections = agent.buildSystemPrompt()
+	agent.explainRequest(2, model, sections, sent, history[:dropped])
+
+	why = handleWhy(agent, nil)
+	assert.Contains(t, why, "✗ file "+large+" omitted: over the live context budget")
+	assert.Contains(t, why, "Since the previous request (turn 0, iteration 1):")
+	assert.Contains(t, why, "+ "+large+" added to live context (omitted)")
+	assert.Contains(t, why, "live context files changed")
+}
+
+func TestHistoryBudget(t *testing.T) {
+	sections := []promptSection{
+		{"instructions", strings.Repeat("i", 1500)},
+		{"live context files", strings.Repeat("f", 5000)},
+		{"git", strings.Repeat("g", 100)},
+	}
+	agent := &Agent{config: &Config{}}
+	assert.Equal(t, 0, agent.historyBudget(sections), "no limit without a budget")
+
+	// The system prompt's overrun comes out of the history budget; live context doesn't count
+	agent.config.Budget = BudgetConfig{TotalChars: 10000}
+	assert.Equal(t, 4400, agent.historyBudget(sections))
+
+	agent.config.Budget = BudgetConfig{TotalChars: 10000, System: 0.2, LiveContext: 0.3, History: 0.5}
+	assert.Equal(t, 5000, agent.historyBudget(sections))
+
+	agent.config.Budget = BudgetConfig{TotalChars: 1000, System: 0.1, LiveContext: 0.1, History: 0.8}
+	assert.Equal(t, 1, agent.historyBudget(sections), "the budget never becomes unlimited")
+}